@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"regexp"
 	"strings"
 
 	"github.com/Masterminds/squirrel"
@@ -184,6 +185,8 @@ func (api authAPI) getMe(c *fiber.Ctx) error {
 		Username:    user.Username,
 		DisplayName: user.DisplayName,
 		Role:        user.Role,
+
+		PreferredAudioLanguage: user.PreferredAudioLanguage,
 	})
 }
 
@@ -205,7 +208,7 @@ func (api authAPI) updateMe(c *fiber.Ctx) error {
 		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
 	}
 
-	if updateReq.DisplayName == "" && updateReq.Password == "" {
+	if updateReq.DisplayName == "" && updateReq.Password == "" && updateReq.PreferredAudioLanguage == nil {
 		return errorResponse(c, fiber.StatusBadRequest, "No data to update", nil)
 	}
 
@@ -213,6 +216,15 @@ func (api authAPI) updateMe(c *fiber.Ctx) error {
 		user.DisplayName = updateReq.DisplayName
 	}
 
+	if updateReq.PreferredAudioLanguage != nil {
+		language := strings.ToLower(strings.TrimSpace(*updateReq.PreferredAudioLanguage))
+		if !validLanguage(language) {
+			return errorResponse(c, fiber.StatusBadRequest, "Invalid preferred audio language", nil)
+		}
+
+		user.PreferredAudioLanguage = language
+	}
+
 	if updateReq.Password != "" {
 		if !auth.ComparePassword(user.PasswordHash, updateReq.CurrentPassword) {
 			return errorResponse(c, fiber.StatusBadRequest, "Invalid current password", nil)
@@ -231,6 +243,8 @@ func (api authAPI) updateMe(c *fiber.Ctx) error {
 		Username:    user.Username,
 		DisplayName: user.DisplayName,
 		Role:        user.Role,
+
+		PreferredAudioLanguage: user.PreferredAudioLanguage,
 	})
 }
 
@@ -290,3 +304,13 @@ func (api authAPI) getUserByPrincipal(ctx context.Context, principal types.Princ
 	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.USER_TABLE_ID: principal.UserID})
 	return api.r.appDao.GetUser(ctx, dbOpts)
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// languageRegex matches a lowercase ISO 639 language code, such as `eng`
+var languageRegex = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// validLanguage returns true when the language is empty or an ISO 639 language code
+func validLanguage(language string) bool {
+	return language == "" || languageRegex.MatchString(language)
+}
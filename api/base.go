@@ -103,10 +103,13 @@ func (r *Router) initRoutes() {
 	r.initCourseRoutes()
 	r.initScanRoutes()
 	r.initTagRoutes()
+	r.initLibraryRoutes()
 	r.initUserRoutes()
 	r.initLogRoutes()
 	r.initRecoveryRoutes()
 	r.initHlsRoutes()
+	r.initPretranscodeRoutes()
+	r.initTranscodeRoutes()
 	r.initVersionRoutes()
 }
 
@@ -27,6 +27,7 @@ var (
 	defaultTagsOrderBy                    = []string{models.TAG_TABLE_TAG + " asc"}
 	defaultUsersOrderBy                   = []string{models.USER_TABLE_CREATED_AT + " desc"}
 	defaultLogsOrderBy                    = []string{models.LOG_TABLE_CREATED_AT + " desc", "rowid desc"}
+	defaultScanHistoryOrderBy             = []string{models.SCAN_HISTORY_TABLE_STARTED_AT + " desc"}
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -147,9 +148,9 @@ func principalCtx(c *fiber.Ctx) (types.Principal, context.Context, error) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// handleVideo handles the video streaming logic
-func handleVideo(c *fiber.Ctx, appFs *appfs.AppFs, asset *models.Asset) error {
-	// Open the video
+// handleMedia handles the video and audio streaming logic
+func handleMedia(c *fiber.Ctx, appFs *appfs.AppFs, asset *models.Asset) error {
+	// Open the file
 	file, err := appFs.Fs.Open(asset.Path)
 	if err != nil {
 		return errorResponse(c, fiber.StatusInternalServerError, "Error opening file", err)
@@ -162,7 +163,7 @@ func handleVideo(c *fiber.Ctx, appFs *appfs.AppFs, asset *models.Asset) error {
 		return errorResponse(c, fiber.StatusInternalServerError, "Error getting file info", err)
 	}
 
-	// Get the range header and return the entire video if there is no range header
+	// Get the range header and return the entire file if there is no range header
 	rangeHeader := c.Get("Range", "")
 	if rangeHeader == "" {
 		return filesystem.SendFile(c, afero.NewHttpFs(appFs.Fs), asset.Path)
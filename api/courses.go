@@ -1,23 +1,34 @@
 package api
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"mime/multipart"
+	"net/url"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/geerew/off-course/dao"
 	"github.com/geerew/off-course/models"
 	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/appfs"
 	"github.com/geerew/off-course/utils/coursemetadata"
+	"github.com/geerew/off-course/utils/coursemove"
+	"github.com/geerew/off-course/utils/coursescan"
 	"github.com/geerew/off-course/utils/queryparser"
 	"github.com/geerew/off-course/utils/types"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	"github.com/spf13/afero"
 	"github.com/spf13/cast"
+	"github.com/valyala/fasthttp"
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -42,12 +53,23 @@ func (r *Router) initCourseRoutes() {
 	g.Post("", protectedRoute, coursesAPI.createCourse)
 	g.Delete("/:id", protectedRoute, coursesAPI.deleteCourse)
 
+	// Move
+	g.Post("/move", protectedRoute, coursesAPI.moveCourses)
+	g.Post("/:id/move", protectedRoute, coursesAPI.moveCourse)
+
+	// Metadata
+	g.Put("/:id/metadata", protectedRoute, coursesAPI.updateCourseMetadata)
+
+	// Settings
+	g.Put("/:id/settings", protectedRoute, coursesAPI.updateCourseSettings)
+
 	// Progress
 	g.Delete("/:id/progress", coursesAPI.deleteCourseProgress)
 
 	// Card
 	g.Head("/:id/card", coursesAPI.getCard)
 	g.Get("/:id/card", coursesAPI.getCard)
+	g.Put("/:id/card", protectedRoute, coursesAPI.updateCard)
 
 	// Lessons
 	g.Get("/:id/lessons", coursesAPI.getLessons)
@@ -74,6 +96,13 @@ func (r *Router) initCourseRoutes() {
 	// Favourites
 	g.Post("/:id/favourite", protectedRoute, coursesAPI.favouriteCourse)
 	g.Delete("/:id/favourite", protectedRoute, coursesAPI.unfavouriteCourse)
+
+	// Watch
+	g.Post("/:id/watch", protectedRoute, coursesAPI.watchCourse)
+	g.Delete("/:id/watch", protectedRoute, coursesAPI.unwatchCourse)
+
+	// Scan history
+	g.Get("/:id/scans", protectedRoute, coursesAPI.getScanHistory)
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -179,13 +208,14 @@ func (api coursesAPI) createCourse(c *fiber.Ctx) error {
 		Path:  utils.NormalizeWindowsDrive(req.Path),
 	}
 
-	// Validate the path
-	if exists, err := afero.DirExists(api.r.app.AppFs.Fs, course.Path); err != nil || !exists {
+	// Validate the path. This is either a directory or an archive (zip, tar)
+	if exists, err := appfs.DirOrArchiveExists(api.r.app.AppFs.Fs, course.Path); err != nil || !exists {
 		return errorResponse(c, fiber.StatusBadRequest, "Invalid course path", err)
 	}
 
-	// Set the course to available
+	// Set the course to available and watch it for changes
 	course.Available = true
+	course.Watch = true
 
 	_, ctx, err := principalCtx(c)
 	if err != nil {
@@ -200,15 +230,205 @@ func (api coursesAPI) createCourse(c *fiber.Ctx) error {
 	}
 
 	// Start a scan job
-	if _, err := api.r.app.CourseScan.Add(ctx, course.ID); err != nil {
+	if _, err := api.r.app.CourseScan.AddWithPriority(ctx, course.ID, coursescan.ScanPriorityHigh); err != nil {
 		return errorResponse(c, fiber.StatusInternalServerError, "Error creating scan job", err)
 	}
 
+	// Start watching the course. A failure is not fatal as the course can still be scanned manually
+	if err := api.r.app.CourseWatch.Watch(course.ID, course.Path); err != nil {
+		api.r.app.Logger.Warn().
+			Err(err).
+			Str("course_id", course.ID).
+			Str("course_path", course.Path).
+			Msg("Failed to watch course")
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(courseResponseHelper([]*models.Course{course}, true)[0])
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// updateCourseMetadata updates the course details and writes them to the course.json file,
+// so they survive a rescan. An empty title keeps the current title
+func (api coursesAPI) updateCourseMetadata(c *fiber.Ctx) error {
+	courseId := c.Params("id")
+
+	req := &courseMetadataRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	if req.ReleaseDate != "" {
+		if _, err := time.Parse(time.DateOnly, req.ReleaseDate); err != nil {
+			return errorResponse(c, fiber.StatusBadRequest, "Release date must be in the format YYYY-MM-DD", err)
+		}
+	}
+
+	if req.SourceURL != "" {
+		u, err := url.Parse(req.SourceURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errorResponse(c, fiber.StatusBadRequest, "Source URL must be an http or https URL", err)
+		}
+	}
+
+	authors := []string{}
+	for _, author := range req.Authors {
+		if author = strings.TrimSpace(author); author != "" {
+			authors = append(authors, author)
+		}
+	}
+
+	modules := []coursemetadata.ModuleMetadata{}
+	for _, module := range req.Modules {
+		if strings.TrimSpace(module.Name) == "" {
+			return errorResponse(c, fiber.StatusBadRequest, "A module name is required", nil)
+		}
+
+		modules = append(modules, coursemetadata.ModuleMetadata{
+			Name:  module.Name,
+			Title: strings.TrimSpace(module.Title),
+			Order: module.Order,
+		})
+	}
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	course, err := api.getCourseByID(ctx, courseId)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course", err)
+	}
+
+	if course == nil {
+		return errorResponse(c, fiber.StatusNotFound, "Course not found", nil)
+	}
+
+	if exists, err := afero.DirExists(api.r.app.AppFs.Fs, course.Path); err != nil || !exists {
+		return errorResponse(c, fiber.StatusBadRequest, "Course path is unavailable", err)
+	}
+
+	if appfs.IsArchive(course.Path) {
+		return errorResponse(c, fiber.StatusBadRequest, "Course details cannot be written to an archive", nil)
+	}
+
+	title := strings.TrimSpace(req.Title)
+	description := strings.TrimSpace(req.Description)
+
+	// Write course.json first. The next scan reads the details from it
+	if err := api.r.app.MetadataWriter.UpdateMetadataSync(course.ID, course.Path, func(metadata *coursemetadata.CourseMetadata) {
+		metadata.Title = title
+		metadata.Description = description
+		metadata.Authors = authors
+		metadata.Language = strings.TrimSpace(req.Language)
+		metadata.Difficulty = strings.TrimSpace(req.Difficulty)
+		metadata.ReleaseDate = req.ReleaseDate
+		metadata.SourceURL = req.SourceURL
+		metadata.Modules = modules
+	}); err != nil {
+		if errors.Is(err, coursemetadata.ErrUnreadableMetadata) {
+			return errorResponse(c, fiber.StatusBadRequest, "course.json could not be read", err)
+		}
+
+		return errorResponse(c, fiber.StatusInternalServerError, "Error writing course metadata", err)
+	}
+
+	if title != "" {
+		course.Title = title
+	}
+
+	// An empty description or authors list falls back to the sidecar files, as it would on a
+	// rescan
+	if description == "" || len(authors) == 0 {
+		sidecars, err := coursemetadata.ReadSidecars(api.r.app.AppFs.Fs, course.Path)
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error reading course sidecar files", err)
+		}
+
+		if description == "" {
+			description = sidecars.Description
+		}
+
+		if len(authors) == 0 {
+			authors = sidecars.Authors
+		}
+	}
+
+	course.Description = description
+	course.Authors = authors
+	course.Language = strings.TrimSpace(req.Language)
+	course.Difficulty = strings.TrimSpace(req.Difficulty)
+	course.ReleaseDate = req.ReleaseDate
+	course.SourceURL = req.SourceURL
+
+	course.Modules = models.CourseModules{}
+	for _, module := range modules {
+		course.Modules = append(course.Modules, models.CourseModule{
+			Name:  module.Name,
+			Title: module.Title,
+			Order: module.Order,
+		})
+	}
+
+	if err := api.r.appDao.UpdateCourse(ctx, course); err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error updating course", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(courseResponseHelper([]*models.Course{course}, true)[0])
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// updateCourseSettings updates the playback settings of a course. They apply to streams started
+// afterwards
+func (api coursesAPI) updateCourseSettings(c *fiber.Ctx) error {
+	courseId := c.Params("id")
+
+	req := &courseSettingsRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	course, err := api.getCourseByID(ctx, courseId)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course", err)
+	}
+
+	if course == nil {
+		return errorResponse(c, fiber.StatusNotFound, "Course not found", nil)
+	}
+
+	changed := course.NormalizeAudio != req.NormalizeAudio
+	course.NormalizeAudio = req.NormalizeAudio
+
+	if err := api.r.appDao.UpdateCourse(ctx, course); err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error updating course", err)
+	}
+
+	// Open streams keep the gain they were created with, so they are destroyed and start over on
+	// the next request
+	if changed {
+		assets, err := api.r.appDao.ListAssets(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_COURSE_ID: course.ID}))
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course assets", err)
+		}
+
+		for _, asset := range assets {
+			api.r.app.Transcoder.KillSession(asset.ID)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(courseResponseHelper([]*models.Course{course}, true)[0])
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func (api coursesAPI) deleteCourse(c *fiber.Ctx) error {
 	id := c.Params("id")
 
@@ -217,7 +437,8 @@ func (api coursesAPI) deleteCourse(c *fiber.Ctx) error {
 		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
 	}
 
-	// Cancel and remove any ongoing scans for this course
+	// Stop watching the course, then cancel and remove any ongoing scans for this course
+	api.r.app.CourseWatch.Unwatch(id)
 	api.r.app.CourseScan.CancelAndRemoveScansByCourseID(id)
 
 	// Delete optimized card file if it exists
@@ -231,15 +452,183 @@ func (api coursesAPI) deleteCourse(c *fiber.Ctx) error {
 			Msg("Failed to delete optimized card during course deletion")
 	}
 
+	// Get the video assets before the course is deleted, so their thumbnails can be deleted
+	assetsOpts := dao.NewOptions().WithWhere(squirrel.Eq{
+		models.ASSET_TABLE_COURSE_ID: id,
+		models.ASSET_TABLE_TYPE:      types.AssetVideo,
+	})
+
+	videos, err := api.r.appDao.ListAssets(ctx, assetsOpts)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course assets", err)
+	}
+
 	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: id})
 	if err := api.r.appDao.DeleteCourses(ctx, dbOpts); err != nil {
 		return errorResponse(c, fiber.StatusInternalServerError, "Error deleting course", err)
 	}
+
+	for _, video := range videos {
+		if err := api.r.app.Thumbnails.Delete(video.ID); err != nil {
+			api.r.app.Logger.Warn().
+				Err(err).
+				Str("course_id", id).
+				Str("asset_id", video.ID).
+				Msg("Failed to delete thumbnails during course deletion")
+		}
+	}
+
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// moveCourse moves a course to a new path, or relinks it to a path it was already moved to.
+// When the client accepts text/event-stream, the progress of the move is streamed using
+// Server-Sent Events (SSE)
+func (api coursesAPI) moveCourse(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	req := &courseMoveRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	mode, err := parseCourseMoveMode(req.Mode)
+	if err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Invalid mode", nil)
+	}
+
+	if strings.TrimSpace(req.Path) == "" {
+		return errorResponse(c, fiber.StatusBadRequest, "A path is required", nil)
+	}
+
+	course, err := api.getCourseByID(ctx, id)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course", err)
+	}
+
+	if course == nil {
+		return errorResponse(c, fiber.StatusNotFound, "Course not found", nil)
+	}
+
+	if !strings.Contains(c.Get(fiber.HeaderAccept), "text/event-stream") {
+		result, err := api.r.app.CourseMove.Move(ctx, course.ID, req.Path, mode, nil)
+		if err != nil {
+			return errorResponse(c, courseMoveErrorStatus(err), courseMoveErrorMessage(err), err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(courseMoveResponseHelper([]*coursemove.Result{result})[0])
+	}
+
+	// Set SSE headers
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	// The move runs to completion even when the client goes away, as stopping part way would
+	// leave the files to be restored
+	moveCtx := context.WithoutCancel(ctx)
+	events := make(chan any, 16)
+
+	go func() {
+		defer close(events)
+
+		result, err := api.r.app.CourseMove.Move(moveCtx, course.ID, req.Path, mode, func(p coursemove.Progress) {
+			events <- map[string]any{
+				"type": "progress",
+				"data": &courseMoveProgressResponse{
+					CourseID: p.CourseID,
+					Stage:    string(p.Stage),
+					Done:     p.Done,
+					Total:    p.Total,
+				},
+			}
+		})
+
+		if err != nil {
+			events <- map[string]any{
+				"type": "error",
+				"data": fiber.Map{"message": courseMoveErrorMessage(err), "error": err.Error()},
+			}
+			return
+		}
+
+		events <- map[string]any{
+			"type": "done",
+			"data": courseMoveResponseHelper([]*coursemove.Result{result})[0],
+		}
+	}()
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		connected := true
+
+		// Drain every event so the move is never blocked on a client that went away
+		for event := range events {
+			if !connected {
+				continue
+			}
+
+			eventBytes, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+
+			if _, err := w.WriteString("data: " + string(eventBytes) + "\n\n"); err != nil {
+				connected = false
+				continue
+			}
+
+			if err := w.Flush(); err != nil {
+				connected = false
+			}
+		}
+	}))
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// moveCourses moves a number of courses into a new root directory. Each course keeps the name
+// of its directory. A failure for one course does not stop the others from being moved
+func (api coursesAPI) moveCourses(c *fiber.Ctx) error {
+	req := &coursesMoveRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	mode, err := parseCourseMoveMode(req.Mode)
+	if err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Invalid mode", nil)
+	}
+
+	if strings.TrimSpace(req.Root) == "" {
+		return errorResponse(c, fiber.StatusBadRequest, "A root is required", nil)
+	}
+
+	if len(req.IDs) == 0 {
+		return errorResponse(c, fiber.StatusBadRequest, "At least one course is required", nil)
+	}
+
+	results := api.r.app.CourseMove.MoveAll(ctx, req.IDs, req.Root, mode, nil)
+
+	return c.Status(fiber.StatusOK).JSON(courseMoveResponseHelper(results))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // TODO add tests
 func (api coursesAPI) deleteCourseProgress(c *fiber.Ctx) error {
 	courseId := c.Params("id")
@@ -313,6 +702,161 @@ func (api coursesAPI) getCard(c *fiber.Ctx) error {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// updateCard changes the card for a course. A multipart request uploads a custom image (in
+// the `card` field). Otherwise, a frame is grabbed from a video in the course, either at the
+// requested timestamp or, when no timestamp is given, automatically
+func (api coursesAPI) updateCard(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	course, err := api.getCourseByID(ctx, id)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course", err)
+	}
+
+	if course == nil {
+		return errorResponse(c, fiber.StatusNotFound, "Course not found", nil)
+	}
+
+	// The card is generated next to the current one, which it replaces once the course is updated
+	cardPath := api.r.app.CardCache.GetCardPath(course.ID)
+	pendingPath := strings.TrimSuffix(cardPath, filepath.Ext(cardPath)) + ".pending" + filepath.Ext(cardPath)
+	defer api.r.app.AppFs.Fs.Remove(pendingPath)
+
+	source := types.CardSourceCustom
+
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		file, err := c.FormFile("card")
+		if err != nil {
+			return errorResponse(c, fiber.StatusBadRequest, "A card image is required", err)
+		}
+
+		ext := types.CardExtension(strings.ToLower(strings.TrimPrefix(filepath.Ext(file.Filename), ".")))
+		if !ext.IsValid() {
+			return errorResponse(c, fiber.StatusBadRequest, "Unsupported card image type", nil)
+		}
+
+		if err := api.saveUploadedCard(ctx, file, ext, pendingPath); err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error generating card", err)
+		}
+	} else {
+		req := &courseCardRequest{}
+		if err := c.BodyParser(req); err != nil {
+			return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+		}
+
+		if req.Timestamp != nil && *req.Timestamp < 0 {
+			return errorResponse(c, fiber.StatusBadRequest, "Timestamp cannot be negative", nil)
+		}
+
+		video, err := api.cardVideo(ctx, course.ID, req.AssetID)
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error looking up assets", err)
+		}
+
+		if video == nil {
+			return errorResponse(c, fiber.StatusBadRequest, "Video not found", nil)
+		}
+
+		durationSec := 0
+		if video.AssetMetadata != nil {
+			durationSec = video.AssetMetadata.DurationSec()
+		}
+
+		if req.Timestamp == nil {
+			source = types.CardSourceGenerated
+			err = api.r.app.CardCache.GenerateVideoCard(ctx, video.Path, pendingPath, durationSec)
+		} else {
+			if durationSec > 0 && *req.Timestamp >= durationSec {
+				return errorResponse(c, fiber.StatusBadRequest, "Timestamp is past the end of the video", nil)
+			}
+
+			err = api.r.app.CardCache.GenerateVideoCardAt(ctx, video.Path, pendingPath, *req.Timestamp)
+		}
+
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error generating card", err)
+		}
+	}
+
+	// The hash of the card is used to bust the card cache in the UI
+	cardHash, err := coursescan.HashFile(api.r.app.AppFs.Fs, pendingPath)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error hashing card", err)
+	}
+
+	course.CardPath = ""
+	course.CardHash = cardHash
+	course.CardModTime = ""
+	course.CardSource = source
+
+	if err := api.r.appDao.UpdateCourse(ctx, course); err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error updating course", err)
+	}
+
+	if err := api.r.app.AppFs.Fs.Rename(pendingPath, cardPath); err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error saving card", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(courseResponseHelper([]*models.Course{course}, true)[0])
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// saveUploadedCard writes an uploaded card image next to the card and runs it through the
+// card optimization
+func (api coursesAPI) saveUploadedCard(ctx context.Context, file *multipart.FileHeader, ext types.CardExtension, cardPath string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	uploadPath := strings.TrimSuffix(cardPath, filepath.Ext(cardPath)) + ".upload." + ext.String()
+	defer api.r.app.AppFs.Fs.Remove(uploadPath)
+
+	if err := afero.WriteReader(api.r.app.AppFs.Fs, uploadPath, src); err != nil {
+		return err
+	}
+
+	return api.r.app.CardCache.GenerateOptimizedCard(ctx, uploadPath, cardPath)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// cardVideo returns the video asset to grab a card frame from. When no asset ID is given, the
+// first video in the course is used. Nil is returned when there is no such video
+func (api coursesAPI) cardVideo(ctx context.Context, courseID, assetID string) (*models.Asset, error) {
+	where := squirrel.And{squirrel.Eq{models.ASSET_TABLE_COURSE_ID: courseID}}
+	if assetID != "" {
+		where = append(where, squirrel.Eq{models.ASSET_TABLE_ID: assetID})
+	}
+
+	dbOpts := dao.NewOptions().
+		WithWhere(where).
+		WithOrderBy(models.ASSET_TABLE_MODULE+" asc", models.ASSET_TABLE_PREFIX+" asc", models.ASSET_TABLE_SUB_PREFIX+" asc").
+		WithAssetMetadata()
+
+	assets, err := api.r.appDao.ListAssets(ctx, dbOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, asset := range assets {
+		if asset.Type.IsVideo() {
+			return asset, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // TODO support chaptered query param
 func (api coursesAPI) getLessons(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -422,7 +966,28 @@ func (api coursesAPI) getModules(c *fiber.Ctx) error {
 		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up lessons", err)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(modulesResponseHelper(lessons))
+	// The course holds the module titles and ordering overrides, while the scanned modules hold
+	// the prefix and title of each module directory
+	var courseModules models.CourseModules
+	var modules []*models.Module
+	if len(lessons) > 0 {
+		course, err := api.getCourseByID(ctx, id)
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course", err)
+		}
+
+		if course != nil {
+			courseModules = course.Modules
+		}
+
+		moduleOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.MODULE_TABLE_COURSE_ID: id})
+		modules, err = api.r.appDao.ListModules(ctx, moduleOpts)
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error looking up modules", err)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(modulesResponseHelper(lessons, modules, courseModules))
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -573,8 +1138,8 @@ func (api coursesAPI) serveAsset(c *fiber.Ctx) error {
 		return errorResponse(c, fiber.StatusBadRequest, "Asset does not exist", nil)
 	}
 
-	if asset.Type.IsVideo() {
-		return handleVideo(c, api.r.app.AppFs, asset)
+	if asset.Type.IsMedia() {
+		return handleMedia(c, api.r.app.AppFs, asset)
 	} else if asset.Type.IsText() || asset.Type.IsMarkdown() {
 		return handleText(c, api.r.app.AppFs, asset)
 	}
@@ -767,10 +1332,7 @@ func (api coursesAPI) createTag(c *fiber.Ctx) error {
 			allTags = append(allTags, ct.Tag)
 		}
 		allTags = append(allTags, tagRequest.Tag)
-		metadata := &coursemetadata.CourseMetadata{
-			Tags: allTags,
-		}
-		api.r.app.MetadataWriter.WriteMetadataAsync(courseId, course.Path, metadata)
+		api.r.app.MetadataWriter.WriteTagsAsync(courseId, course.Path, allTags)
 	} else {
 		// Build list from actual DB state
 		allTags := make([]string, 0, len(allCourseTags))
@@ -779,10 +1341,7 @@ func (api coursesAPI) createTag(c *fiber.Ctx) error {
 		}
 
 		// Queue async file write (fire and forget)
-		metadata := &coursemetadata.CourseMetadata{
-			Tags: allTags,
-		}
-		api.r.app.MetadataWriter.WriteMetadataAsync(courseId, course.Path, metadata)
+		api.r.app.MetadataWriter.WriteTagsAsync(courseId, course.Path, allTags)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(courseTagResponseHelper([]*models.CourseTag{courseTag})[0])
@@ -860,10 +1419,7 @@ func (api coursesAPI) deleteTag(c *fiber.Ctx) error {
 	}
 
 	// Queue async file write (fire and forget)
-	metadata := &coursemetadata.CourseMetadata{
-		Tags: remainingTags,
-	}
-	api.r.app.MetadataWriter.WriteMetadataAsync(courseId, course.Path, metadata)
+	api.r.app.MetadataWriter.WriteTagsAsync(courseId, course.Path, remainingTags)
 
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
@@ -928,6 +1484,114 @@ func (api coursesAPI) unfavouriteCourse(c *fiber.Ctx) error {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func (api coursesAPI) watchCourse(c *fiber.Ctx) error {
+	return api.setCourseWatch(c, true)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func (api coursesAPI) unwatchCourse(c *fiber.Ctx) error {
+	return api.setCourseWatch(c, false)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// setCourseWatch enables or disables watching a course for changes
+func (api coursesAPI) setCourseWatch(c *fiber.Ctx, watch bool) error {
+	courseId := c.Params("id")
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	course, err := api.getCourseByID(ctx, courseId)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course", err)
+	}
+
+	if course == nil {
+		return errorResponse(c, fiber.StatusNotFound, "Course not found", nil)
+	}
+
+	if course.Watch != watch {
+		course.Watch = watch
+		if err := api.r.appDao.UpdateCourse(ctx, course); err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error updating course", err)
+		}
+	}
+
+	if !watch {
+		api.r.app.CourseWatch.Unwatch(course.ID)
+		return c.Status(fiber.StatusNoContent).Send(nil)
+	}
+
+	// The course may not be available right now. It will be picked up by the watcher's periodic sync
+	if err := api.r.app.CourseWatch.Watch(course.ID, course.Path); err != nil {
+		api.r.app.Logger.Warn().
+			Err(err).
+			Str("course_id", course.ID).
+			Str("course_path", course.Path).
+			Msg("Failed to watch course")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// getScanHistory lists the finished scans for a course
+func (api coursesAPI) getScanHistory(c *fiber.Ctx) error {
+	courseId := c.Params("id")
+
+	builderOpts := builderOptions{
+		DefaultOrderBy: defaultScanHistoryOrderBy,
+		Paginate:       true,
+		AllowedFilters: scanHistoryFilters,
+		AfterParseHook: scanHistoryAfterParseHook,
+	}
+
+	principal, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	course, err := api.getCourseByID(ctx, courseId)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course", err)
+	}
+
+	if course == nil {
+		return errorResponse(c, fiber.StatusNotFound, "Course not found", nil)
+	}
+
+	dbOpts, err := optionsBuilder(c, builderOpts, principal.UserID)
+	if err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing query", err)
+	}
+
+	courseWhere := squirrel.Eq{models.SCAN_HISTORY_TABLE_COURSE_ID: course.ID}
+	if dbOpts.Where != nil {
+		dbOpts.WithWhere(squirrel.And{courseWhere, dbOpts.Where})
+	} else {
+		dbOpts.WithWhere(courseWhere)
+	}
+
+	histories, err := api.r.appDao.ListScanHistory(ctx, dbOpts)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up scan history", err)
+	}
+
+	pResult, err := dbOpts.Pagination.BuildResult(scanHistoryResponseHelper(histories))
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error building pagination result", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(pResult)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // coursesAfterParseHook runs after parsing the query expression and is used to build the
 // WHERE/JOIN clauses
 func coursesAfterParseHook(parsed *queryparser.QueryResult, dbOpts *dao.Options, userID string) {
@@ -1067,6 +1731,67 @@ func courseTagsBuilder(tags []string) squirrel.Sqlizer {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// parseCourseMoveMode parses the mode of a move, defaulting to moving the files
+func parseCourseMoveMode(raw string) (coursemove.Mode, error) {
+	switch mode := coursemove.Mode(strings.ToLower(strings.TrimSpace(raw))); mode {
+	case "":
+		return coursemove.ModeMove, nil
+	case coursemove.ModeMove, coursemove.ModeRelink:
+		return mode, nil
+	default:
+		return "", utils.ErrMoveMode
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// courseMoveErrorStatus maps an error from moving a course to a status code
+func courseMoveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrCourseNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, utils.ErrMoveHashMismatch):
+		return fiber.StatusConflict
+	case errors.Is(err, utils.ErrMoveMode),
+		errors.Is(err, utils.ErrMoveSamePath),
+		errors.Is(err, utils.ErrMoveIntoCourse),
+		errors.Is(err, utils.ErrMoveDestExists),
+		errors.Is(err, utils.ErrMoveDestMissing),
+		errors.Is(err, utils.ErrMoveSourceMissing):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// courseMoveErrorMessage maps an error from moving a course to a message
+func courseMoveErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, utils.ErrCourseNotFound):
+		return "Course not found"
+	case errors.Is(err, utils.ErrMoveMode):
+		return "Invalid mode"
+	case errors.Is(err, utils.ErrMoveSamePath):
+		return "The course is already at this path"
+	case errors.Is(err, utils.ErrMoveIntoCourse):
+		return "A course cannot be moved within itself"
+	case errors.Is(err, utils.ErrMoveDestExists):
+		return "The destination already exists"
+	case errors.Is(err, utils.ErrMoveDestMissing):
+		return "The destination does not exist"
+	case errors.Is(err, utils.ErrMoveSourceMissing):
+		return "The course path does not exist"
+	case errors.Is(err, utils.ErrMoveHashMismatch):
+		return "The files at the destination do not match the course"
+	default:
+		return "Error moving course"
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // getCourseByID retrieves a course by its ID with optional database options
 func (api coursesAPI) getCourseByID(ctx context.Context, courseID string, opts ...func(*dao.Options)) (*models.Course, error) {
 	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseID})
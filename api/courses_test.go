@@ -1,10 +1,13 @@
 package api
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -17,6 +20,9 @@ import (
 	"github.com/Masterminds/squirrel"
 	"github.com/geerew/off-course/dao"
 	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/coursemetadata"
+	"github.com/geerew/off-course/utils/coursescan"
 	"github.com/geerew/off-course/utils/pagination"
 	"github.com/geerew/off-course/utils/security"
 	"github.com/geerew/off-course/utils/types"
@@ -396,6 +402,272 @@ func TestCourses_GetCourse(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func TestCourses_UpdateCourseMetadata(t *testing.T) {
+	t.Run("200 (updated)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+		require.NoError(t, router.app.AppFs.Fs.MkdirAll(course.Path, os.ModePerm))
+
+		// Existing fields in course.json are kept
+		require.NoError(t, coursemetadata.WriteMetadata(router.app.AppFs.Fs, course.Path, &coursemetadata.CourseMetadata{Tags: []string{"Go"}}))
+
+		body := `{
+			"title": "Learn Go",
+			"description": "# Learn Go",
+			"authors": ["Jane", " "],
+			"language": "en",
+			"difficulty": "beginner",
+			"releaseDate": "2024-01-02",
+			"sourceUrl": "https://example.com/go",
+			"modules": [{"name": "01 Basics", "title": "Basics", "order": 1}]
+		}`
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/metadata", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var courseResp courseResponse
+		require.NoError(t, json.Unmarshal(respBody, &courseResp))
+		require.Equal(t, "Learn Go", courseResp.Title)
+		require.Equal(t, []string{"Jane"}, courseResp.Authors)
+
+		// Database
+		status, respBody, err = requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/courses/"+course.ID, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		courseResp = courseResponse{}
+		require.NoError(t, json.Unmarshal(respBody, &courseResp))
+		require.Equal(t, "Learn Go", courseResp.Title)
+		require.Equal(t, "# Learn Go", courseResp.Description)
+		require.Equal(t, []string{"Jane"}, courseResp.Authors)
+		require.Equal(t, "en", courseResp.Language)
+		require.Equal(t, "beginner", courseResp.Difficulty)
+		require.Equal(t, "2024-01-02", courseResp.ReleaseDate)
+		require.Equal(t, "https://example.com/go", courseResp.SourceURL)
+		require.Len(t, courseResp.Modules, 1)
+		require.Equal(t, "Basics", courseResp.Modules[0].Title)
+
+		// course.json
+		metadata, err := coursemetadata.ReadMetadata(router.app.AppFs.Fs, course.Path)
+		require.NoError(t, err)
+		require.Equal(t, "Learn Go", metadata.Title)
+		require.Equal(t, []string{"Jane"}, metadata.Authors)
+		require.Equal(t, "https://example.com/go", metadata.SourceURL)
+		require.Equal(t, []string{"Go"}, metadata.Tags)
+		require.Len(t, metadata.Modules, 1)
+	})
+
+	t.Run("200 (sidecar fallback)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+		require.NoError(t, router.app.AppFs.Fs.MkdirAll(course.Path, os.ModePerm))
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, filepath.Join(course.Path, coursemetadata.AuthorFileName), []byte("John"), os.ModePerm))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/metadata", strings.NewReader(`{"language": "fr"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var courseResp courseResponse
+		require.NoError(t, json.Unmarshal(respBody, &courseResp))
+		require.Equal(t, "course 1", courseResp.Title)
+		require.Equal(t, "fr", courseResp.Language)
+		require.Equal(t, []string{"John"}, courseResp.Authors)
+	})
+
+	t.Run("400 (invalid)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+		require.NoError(t, router.app.AppFs.Fs.MkdirAll(course.Path, os.ModePerm))
+
+		tests := []struct {
+			body    string
+			message string
+		}{
+			{`{`, "Error parsing data"},
+			{`{"releaseDate": "02/01/2024"}`, "Release date must be in the format YYYY-MM-DD"},
+			{`{"sourceUrl": "ftp://example.com"}`, "Source URL must be an http or https URL"},
+			{`{"modules": [{"title": "Basics"}]}`, "A module name is required"},
+		}
+
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/metadata", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+			status, respBody, err := requestHelper(t, router, req)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, status)
+			require.Contains(t, string(respBody), tt.message)
+		}
+	})
+
+	t.Run("400 (unavailable)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/metadata", strings.NewReader(`{}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(respBody), "Course path is unavailable")
+	})
+
+	t.Run("400 (unreadable course.json)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, course.Path+"/course.json", []byte(`{`), os.ModePerm))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/metadata", strings.NewReader(`{"title": "New title"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(respBody), "course.json could not be read")
+
+		// The file is left as is
+		data, err := afero.ReadFile(router.app.AppFs.Fs, course.Path+"/course.json")
+		require.NoError(t, err)
+		require.Equal(t, `{`, string(data))
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/metadata", strings.NewReader(`{}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Contains(t, string(respBody), "User is not an admin")
+	})
+
+	t.Run("404 (course not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/invalid/metadata", strings.NewReader(`{}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Contains(t, string(respBody), "Course not found")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourses_UpdateCourseSettings(t *testing.T) {
+	t.Run("200 (updated)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/settings", strings.NewReader(`{"normalizeAudio": true}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var courseResp courseResponse
+		require.NoError(t, json.Unmarshal(respBody, &courseResp))
+		require.NotNil(t, courseResp.NormalizeAudio)
+		require.True(t, *courseResp.NormalizeAudio)
+
+		// Database
+		record, err := router.appDao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+		require.NoError(t, err)
+		require.True(t, record.NormalizeAudio)
+		require.Equal(t, "course 1", record.Title)
+	})
+
+	t.Run("200 (streams destroyed)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		_, err := router.app.Transcoder.GetQualities(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+asset.CourseID+"/settings", strings.NewReader(`{"normalizeAudio": true}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, _, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		// The open streams were destroyed, so the next request normalizes the audio
+		require.False(t, router.app.Transcoder.KillSession(asset.ID))
+	})
+
+	t.Run("400 (bind error)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/settings", strings.NewReader(`{`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(respBody), "Error parsing data")
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/settings", strings.NewReader(`{"normalizeAudio": true}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Contains(t, string(respBody), "User is not an admin")
+	})
+
+	t.Run("404 (course not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/invalid/settings", strings.NewReader(`{}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Contains(t, string(respBody), "Course not found")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func TestCourses_CreateCourse(t *testing.T) {
 	t.Run("201 (created)", func(t *testing.T) {
 		router, _ := setupAdmin(t)
@@ -416,6 +688,26 @@ func TestCourses_CreateCourse(t *testing.T) {
 		require.Equal(t, "course 1", courseResp.Title)
 		require.Equal(t, "/course 1", courseResp.Path)
 		require.True(t, courseResp.Available)
+		require.NotNil(t, courseResp.Watch)
+		require.True(t, *courseResp.Watch)
+	})
+
+	t.Run("201 (archive)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+		router.app.AppFs.Fs = appfs.NewArchiveFs(router.app.AppFs.Fs, "/archives")
+
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, "/course 1.zip", zipHelper(t, map[string]string{"01 intro.mp4": "video"}), os.ModePerm))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/courses/", strings.NewReader(`{"title": "course 1", "path": "/course 1.zip" }`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, status)
+
+		var courseResp courseResponse
+		require.NoError(t, json.Unmarshal(body, &courseResp))
+		require.Equal(t, "/course 1.zip", courseResp.Path)
 	})
 
 	t.Run("400 (bind error)", func(t *testing.T) {
@@ -558,6 +850,21 @@ func TestCourses_DeleteCourse(t *testing.T) {
 		require.False(t, exists, "Card should be deleted when course is deleted")
 	})
 
+	t.Run("204 (deleted with thumbnails)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		vttPath := router.app.Thumbnails.VttPath(asset.ID)
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, vttPath, []byte("WEBVTT\n"), os.ModePerm))
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/courses/"+asset.CourseID, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, status)
+
+		require.False(t, router.app.Thumbnails.Exists(asset.ID))
+	})
+
 	t.Run("204 (not found)", func(t *testing.T) {
 		router, _ := setupAdmin(t)
 
@@ -657,6 +964,146 @@ func TestCourses_GetCard(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func TestCourses_UpdateCard(t *testing.T) {
+	// createCardCourse creates a course with a lesson holding a video asset
+	createCardCourse := func(t *testing.T, router *Router, ctx context.Context) (*models.Course, *models.Asset) {
+		t.Helper()
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		lesson := &models.Lesson{CourseID: course.ID, Title: "lesson 1", Prefix: sql.NullInt16{Int16: 1, Valid: true}, Module: "Module 1"}
+		require.NoError(t, router.appDao.CreateLesson(ctx, lesson))
+
+		asset := &models.Asset{
+			CourseID: course.ID,
+			LessonID: lesson.ID,
+			Title:    "asset 1",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+			Module:   "Module 1",
+			Type:     types.MustAsset("mp4"),
+			Path:     "/course 1/01 asset 1.mp4",
+			FileSize: 1024,
+			ModTime:  time.Now().Format(time.RFC3339Nano),
+			Hash:     "1234",
+		}
+		require.NoError(t, router.appDao.CreateAsset(ctx, asset))
+
+		require.NoError(t, router.appDao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 60},
+		}))
+
+		return course, asset
+	}
+
+	// uploadRequest creates a multipart request uploading a card image
+	uploadRequest := func(t *testing.T, courseID, filename string) *http.Request {
+		t.Helper()
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+
+		part, err := writer.CreateFormFile("card", filename)
+		require.NoError(t, err)
+		_, err = part.Write([]byte("image"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+courseID+"/card", body)
+		req.Header.Set(fiber.HeaderContentType, writer.FormDataContentType())
+
+		return req
+	}
+
+	t.Run("400 (invalid)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course, asset := createCardCourse(t, router, ctx)
+
+		tests := []struct {
+			body    string
+			message string
+		}{
+			{`{`, "Error parsing data"},
+			{`{"timestamp": -1}`, "Timestamp cannot be negative"},
+			{`{"timestamp": 60}`, "Timestamp is past the end of the video"},
+			{`{"assetId": "invalid"}`, "Video not found"},
+			{fmt.Sprintf(`{"assetId": "%s", "timestamp": 600}`, asset.ID), "Timestamp is past the end of the video"},
+		}
+
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/card", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+			status, body, err := requestHelper(t, router, req)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, status, tt.body)
+			require.Contains(t, string(body), tt.message)
+		}
+	})
+
+	t.Run("400 (no video)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/card", strings.NewReader(`{}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Video not found")
+	})
+
+	t.Run("400 (invalid upload)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course, _ := createCardCourse(t, router, ctx)
+
+		status, body, err := requestHelper(t, router, uploadRequest(t, course.ID, "card.gif"))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Unsupported card image type")
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/"+course.ID+"/card", strings.NewReader(""))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEMultipartForm+"; boundary=abc")
+
+		status, body, err = requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "A card image is required")
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/test/card", strings.NewReader(`{}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Contains(t, string(body), "User is not an admin")
+	})
+
+	t.Run("404 (course not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/courses/invalid/card", strings.NewReader(`{}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Contains(t, string(body), "Course not found")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func TestCourses_GetLessons(t *testing.T) {
 	t.Run("200 (empty)", func(t *testing.T) {
 		router, ctx := setupAdmin(t)
@@ -1100,6 +1547,10 @@ func TestCourses_GetLesson(t *testing.T) {
 						FPSDen:      1,
 					},
 					AudioMetadata: nil,
+					ChapterMetadata: []*models.ChapterMetadata{
+						{ChapterIndex: 0, StartSec: 0, EndSec: 45.5, Title: "Introduction"},
+						{ChapterIndex: 1, StartSec: 45.5, EndSec: 120},
+					},
 				}
 				require.NoError(t, router.appDao.CreateAssetMetadata(ctx, meta))
 
@@ -1142,6 +1593,12 @@ func TestCourses_GetLesson(t *testing.T) {
 		require.Len(t, resp.Assets, 2)
 		for _, a := range resp.Assets {
 			require.NotNil(t, a.Progress)
+
+			// Chapters, with a title for untitled chapters
+			require.Equal(t, []assetChapterResponse{
+				{Index: 0, StartSec: 0, EndSec: 45.5, Title: "Introduction"},
+				{Index: 1, StartSec: 45.5, EndSec: 120, Title: "Chapter 2"},
+			}, a.Metadata.Chapters)
 		}
 	})
 
@@ -1301,10 +1758,183 @@ func TestCourses_GetModules(t *testing.T) {
 		require.Equal(t, attachments[3].Title, response.Modules[1].Lessons[0].Attachments[0].Title)
 	})
 
-	t.Run("200 (withUserProgress)", func(t *testing.T) {
+	t.Run("200 (module overrides)", func(t *testing.T) {
 		router, ctx := setupAdmin(t)
 
-		courses := []*models.Course{}
+		first, second := 1, 2
+		course := &models.Course{
+			Title: "Course 1",
+			Path:  "/course-1",
+			Modules: models.CourseModules{
+				{Name: "Chapter 3", Title: "Wrap Up", Order: &first},
+				{Name: "Chapter 1", Order: &second},
+				{Name: "Chapter 2", Title: "The Middle"},
+			},
+		}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		for i := range 3 {
+			lesson := &models.Lesson{
+				CourseID: course.ID,
+				Title:    fmt.Sprintf("lesson %d", i+1),
+				Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+				Module:   fmt.Sprintf("Chapter %d", i+1),
+			}
+			require.NoError(t, router.appDao.CreateLesson(ctx, lesson))
+		}
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/courses/"+course.ID+"/modules", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var response modulesResponse
+		require.NoError(t, json.Unmarshal(body, &response))
+		require.Len(t, response.Modules, 3)
+
+		require.Equal(t, "Chapter 3", response.Modules[0].Module)
+		require.Equal(t, "Wrap Up", response.Modules[0].Title)
+		require.Equal(t, 1, response.Modules[0].Prefix)
+
+		require.Equal(t, "Chapter 1", response.Modules[1].Module)
+		require.Empty(t, response.Modules[1].Title)
+		require.Equal(t, 2, response.Modules[1].Prefix)
+
+		require.Equal(t, "Chapter 2", response.Modules[2].Module)
+		require.Equal(t, "The Middle", response.Modules[2].Title)
+		require.Equal(t, 3, response.Modules[2].Prefix)
+	})
+
+	t.Run("200 (nested module overrides)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{
+			Title: "Course 1",
+			Path:  "/course-1",
+			Modules: models.CourseModules{
+				{Name: "Setup", Title: "Getting Started"},
+				{Name: "Part 2/Setup", Title: "Setting Up Again"},
+			},
+		}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		for i, module := range []string{"Part 1/Setup", "Part 2/Setup"} {
+			lesson := &models.Lesson{
+				CourseID: course.ID,
+				Title:    fmt.Sprintf("lesson %d", i+1),
+				Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+				Module:   module,
+			}
+			require.NoError(t, router.appDao.CreateLesson(ctx, lesson))
+		}
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/courses/"+course.ID+"/modules", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var response modulesResponse
+		require.NoError(t, json.Unmarshal(body, &response))
+		require.Len(t, response.Modules, 2)
+
+		// The directory name matches in any parent, the path only in its own
+		require.Len(t, response.Modules[0].Modules, 1)
+		require.Equal(t, "Getting Started", response.Modules[0].Modules[0].Title)
+
+		require.Len(t, response.Modules[1].Modules, 1)
+		require.Equal(t, "Setting Up Again", response.Modules[1].Modules[0].Title)
+	})
+
+	t.Run("200 (nested)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		// Module records, as created by the scanner
+		partOne := &models.Module{CourseID: course.ID, Path: "9 Part One", Title: "Part One", Prefix: sql.NullInt16{Int16: 9, Valid: true}}
+		require.NoError(t, router.appDao.CreateModule(ctx, partOne))
+
+		setup := &models.Module{
+			CourseID: course.ID,
+			ParentID: sql.NullString{String: partOne.ID, Valid: true},
+			Path:     "9 Part One/01 Setup",
+			Title:    "Setup",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+		}
+		require.NoError(t, router.appDao.CreateModule(ctx, setup))
+
+		partTwo := &models.Module{CourseID: course.ID, Path: "10 Part Two", Title: "Part Two", Prefix: sql.NullInt16{Int16: 10, Valid: true}}
+		require.NoError(t, router.appDao.CreateModule(ctx, partTwo))
+
+		assets := []*models.Asset{}
+		for i, module := range []string{"9 Part One/01 Setup", "9 Part One", "10 Part Two"} {
+			lesson := &models.Lesson{
+				CourseID: course.ID,
+				Title:    fmt.Sprintf("lesson %d", i+1),
+				Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+				Module:   module,
+			}
+			require.NoError(t, router.appDao.CreateLesson(ctx, lesson))
+
+			asset := &models.Asset{
+				CourseID: course.ID,
+				LessonID: lesson.ID,
+				Title:    "asset 1",
+				Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+				Module:   module,
+				Type:     types.MustAsset("mp4"),
+				Path:     fmt.Sprintf("%s/%s/01 asset.mp4", course.Path, module),
+				FileSize: 1024,
+				ModTime:  time.Now().Format(time.RFC3339Nano),
+				Hash:     security.RandomString(64),
+			}
+			require.NoError(t, router.appDao.CreateAsset(ctx, asset))
+			assets = append(assets, asset)
+		}
+
+		require.NoError(t, router.appDao.UpsertAssetProgress(ctx, &models.AssetProgress{AssetID: assets[0].ID, Position: 10, Completed: true}))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/courses/"+course.ID+"/modules?withUserProgress=true", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var response modulesResponse
+		require.NoError(t, json.Unmarshal(body, &response))
+		require.Len(t, response.Modules, 2)
+
+		// Part One is ordered first by its prefix
+		require.Equal(t, "9 Part One", response.Modules[0].Module)
+		require.Equal(t, partOne.ID, response.Modules[0].ID)
+		require.Equal(t, "Part One", response.Modules[0].Title)
+		require.Equal(t, 1, response.Modules[0].Prefix)
+		require.Equal(t, 0, response.Modules[0].Depth)
+		require.Len(t, response.Modules[0].Lessons, 1)
+		require.Equal(t, 2, response.Modules[0].LessonsCount)
+		require.Equal(t, 1, response.Modules[0].LessonsCompleted)
+		require.Equal(t, 50, response.Modules[0].Percent)
+		require.True(t, response.Modules[0].Started)
+		require.False(t, response.Modules[0].Completed)
+
+		require.Len(t, response.Modules[0].Modules, 1)
+		child := response.Modules[0].Modules[0]
+		require.Equal(t, "9 Part One/01 Setup", child.Module)
+		require.Equal(t, partOne.ID, child.ParentID)
+		require.Equal(t, "Setup", child.Title)
+		require.Equal(t, 1, child.Depth)
+		require.Equal(t, 100, child.Percent)
+		require.True(t, child.Completed)
+
+		require.Equal(t, "10 Part Two", response.Modules[1].Module)
+		require.Equal(t, 2, response.Modules[1].Prefix)
+		require.Equal(t, 1, response.Modules[1].LessonsCount)
+		require.Zero(t, response.Modules[1].Percent)
+		require.False(t, response.Modules[1].Started)
+		require.Empty(t, response.Modules[1].Modules)
+	})
+
+	t.Run("200 (withUserProgress)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		courses := []*models.Course{}
 		lessons := []*models.Lesson{}
 		assets := []*models.Asset{}
 		attachments := []*models.Attachment{}
@@ -1993,6 +2623,86 @@ func TestCourses_ServeAsset(t *testing.T) {
 		require.Equal(t, "video", string(body))
 	})
 
+	t.Run("206 (stream audio)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/Course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		lesson := &models.Lesson{
+			CourseID: course.ID,
+			Title:    "lesson 1",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+			Module:   "Module 1",
+		}
+		require.NoError(t, router.appDao.CreateLesson(ctx, lesson))
+
+		asset := &models.Asset{
+			CourseID: course.ID,
+			LessonID: lesson.ID,
+			Title:    "asset 1",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+			Module:   "Module 1",
+			Type:     types.MustAsset("mp3"),
+			Path:     fmt.Sprintf("/%s/asset 1.mp3", security.RandomString(4)),
+			FileSize: 1024,
+			ModTime:  time.Now().Format(time.RFC3339Nano),
+			Hash:     security.RandomString(64),
+		}
+		require.NoError(t, router.appDao.CreateAsset(ctx, asset))
+
+		require.Nil(t, router.app.AppFs.Fs.MkdirAll(filepath.Dir(asset.Path), os.ModePerm))
+		require.Nil(t, afero.WriteFile(router.app.AppFs.Fs, asset.Path, []byte("audio"), os.ModePerm))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/courses/"+course.ID+"/lessons/"+lesson.ID+"/assets/"+asset.ID+"/serve", nil)
+		req.Header.Add("Range", "bytes=1-")
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusPartialContent, status)
+		require.Equal(t, "udio", string(body))
+	})
+
+	t.Run("206 (archive member)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+		router.app.AppFs.Fs = appfs.NewArchiveFs(router.app.AppFs.Fs, "/archives")
+
+		course := &models.Course{Title: "Course 1", Path: "/Course 1.zip"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		lesson := &models.Lesson{
+			CourseID: course.ID,
+			Title:    "lesson 1",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+			Module:   "Module 1",
+		}
+		require.NoError(t, router.appDao.CreateLesson(ctx, lesson))
+
+		asset := &models.Asset{
+			CourseID: course.ID,
+			LessonID: lesson.ID,
+			Title:    "asset 1",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+			Module:   "Module 1",
+			Type:     types.MustAsset("mp4"),
+			Path:     "/Course 1.zip/Module 1/01 asset 1.mp4",
+			FileSize: 5,
+			ModTime:  time.Now().Format(time.RFC3339Nano),
+			Hash:     security.RandomString(64),
+		}
+		require.NoError(t, router.appDao.CreateAsset(ctx, asset))
+
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, course.Path, zipHelper(t, map[string]string{"Module 1/01 asset 1.mp4": "video"}), os.ModePerm))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/courses/"+course.ID+"/lessons/"+lesson.ID+"/assets/"+asset.ID+"/serve", nil)
+		req.Header.Add("Range", "bytes=1-")
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusPartialContent, status)
+		require.Equal(t, "ideo", string(body))
+	})
+
 	t.Run("400 (invalid path)", func(t *testing.T) {
 		router, ctx := setupAdmin(t)
 
@@ -2680,6 +3390,307 @@ func TestCourses_UnfavouriteCourse(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func TestCourses_WatchCourse(t *testing.T) {
+	t.Run("204 (enabled)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+		require.False(t, course.Watch)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/courses/"+course.ID+"/watch", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, status)
+
+		record, err := router.appDao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+		require.NoError(t, err)
+		require.True(t, record.Watch)
+	})
+
+	t.Run("204 (disabled)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1", Watch: true}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/courses/"+course.ID+"/watch", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, status)
+
+		record, err := router.appDao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+		require.NoError(t, err)
+		require.False(t, record.Watch)
+		require.False(t, router.app.CourseWatch.IsWatching(course.ID))
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		course := &models.Course{Title: "course 1", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/courses/"+course.ID+"/watch", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Contains(t, string(body), "User is not an admin")
+	})
+
+	t.Run("404 (course not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/courses/invalid/watch", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Contains(t, string(body), "Course not found")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourses_MoveCourse(t *testing.T) {
+	// createMoveCourse creates a course with an asset on disk and progress for the asset
+	createMoveCourse := func(t *testing.T, router *Router, ctx context.Context, path string) (*models.Course, *models.Asset) {
+		t.Helper()
+
+		assetPath := filepath.Join(path, "01 Intro", "01 Welcome.mp4")
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, assetPath, []byte("welcome "+path), os.ModePerm))
+
+		hash, err := coursescan.HashFile(router.app.AppFs.Fs, assetPath)
+		require.NoError(t, err)
+
+		course := &models.Course{Title: filepath.Base(path), Path: path}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		lesson := &models.Lesson{CourseID: course.ID, Title: "Intro", Prefix: sql.NullInt16{Int16: 1, Valid: true}, Module: "01 Intro"}
+		require.NoError(t, router.appDao.CreateLesson(ctx, lesson))
+
+		asset := &models.Asset{
+			CourseID: course.ID,
+			LessonID: lesson.ID,
+			Title:    "Welcome",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+			Module:   "01 Intro",
+			Type:     types.MustAsset("mp4"),
+			Path:     assetPath,
+			FileSize: 1024,
+			ModTime:  time.Now().Format(time.RFC3339Nano),
+			Hash:     hash,
+		}
+		require.NoError(t, router.appDao.CreateAsset(ctx, asset))
+
+		require.NoError(t, router.appDao.UpsertAssetProgress(ctx, &models.AssetProgress{AssetID: asset.ID, Position: 10}))
+
+		return course, asset
+	}
+
+	t.Run("200 (move)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course, asset := createMoveCourse(t, router, ctx, "/courses/Course 1")
+
+		req := httptest.NewRequest(http.MethodPost, "/api/courses/"+course.ID+"/move", strings.NewReader(`{"path": "/archive/Course 1"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var resp courseMoveResponse
+		require.NoError(t, json.Unmarshal(body, &resp))
+		require.Equal(t, "/courses/Course 1", resp.From)
+		require.Equal(t, "/archive/Course 1", resp.To)
+		require.Equal(t, "move", resp.Mode)
+		require.Equal(t, 1, resp.Verified)
+
+		record, err := router.appDao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+		require.NoError(t, err)
+		require.Equal(t, "/archive/Course 1", record.Path)
+
+		// The asset, and its progress, are kept
+		movedAsset, err := router.appDao.GetAsset(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_ID: asset.ID}).WithUserProgress())
+		require.NoError(t, err)
+		require.Equal(t, "/archive/Course 1/01 Intro/01 Welcome.mp4", movedAsset.Path)
+		require.NotNil(t, movedAsset.Progress)
+		require.Equal(t, 10, movedAsset.Progress.Position)
+
+		exists, err := afero.Exists(router.app.AppFs.Fs, movedAsset.Path)
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+
+	t.Run("200 (relink)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course, _ := createMoveCourse(t, router, ctx, "/courses/Course 1")
+
+		require.NoError(t, router.app.AppFs.Fs.MkdirAll("/new", os.ModePerm))
+		require.NoError(t, router.app.AppFs.Fs.Rename("/courses/Course 1", "/new/Course 1"))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/courses/"+course.ID+"/move", strings.NewReader(`{"path": "/new/Course 1", "mode": "relink"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		status, _, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		record, err := router.appDao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+		require.NoError(t, err)
+		require.Equal(t, "/new/Course 1", record.Path)
+	})
+
+	t.Run("200 (stream)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course, _ := createMoveCourse(t, router, ctx, "/courses/Course 1")
+
+		req := httptest.NewRequest(http.MethodPost, "/api/courses/"+course.ID+"/move", strings.NewReader(`{"path": "/archive/Course 1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, string(body), `"type":"progress"`)
+		require.Contains(t, string(body), `"stage":"verifying"`)
+		require.Contains(t, string(body), `"type":"done"`)
+	})
+
+	t.Run("400 (invalid)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course, _ := createMoveCourse(t, router, ctx, "/courses/Course 1")
+
+		tests := []struct {
+			body    string
+			message string
+		}{
+			{`{`, "Error parsing data"},
+			{`{"path": ""}`, "A path is required"},
+			{`{"path": "/new", "mode": "copy"}`, "Invalid mode"},
+			{`{"path": "/courses/Course 1"}`, "The course is already at this path"},
+			{`{"path": "/courses/Course 1/nested"}`, "A course cannot be moved within itself"},
+			{`{"path": "/missing", "mode": "relink"}`, "The destination does not exist"},
+		}
+
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodPost, "/api/courses/"+course.ID+"/move", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			status, body, err := requestHelper(t, router, req)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, status, tt.body)
+			require.Contains(t, string(body), tt.message)
+		}
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/courses/test/move", strings.NewReader(`{"path": "/new"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Contains(t, string(body), "User is not an admin")
+	})
+
+	t.Run("404 (course not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/courses/invalid/move", strings.NewReader(`{"path": "/new"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Contains(t, string(body), "Course not found")
+	})
+
+	t.Run("409 (hash mismatch)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course, _ := createMoveCourse(t, router, ctx, "/courses/Course 1")
+
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, "/new/Course 1/01 Intro/01 Welcome.mp4", []byte("other"), os.ModePerm))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/courses/"+course.ID+"/move", strings.NewReader(`{"path": "/new/Course 1", "mode": "relink"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusConflict, status)
+		require.Contains(t, string(body), "The files at the destination do not match the course")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourses_MoveCourses(t *testing.T) {
+	t.Run("200", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		courses := []*models.Course{}
+		for _, path := range []string{"/courses/Course 1", "/courses/Course 2"} {
+			require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, filepath.Join(path, "01 video.mp4"), []byte(path), os.ModePerm))
+
+			course := &models.Course{Title: filepath.Base(path), Path: path}
+			require.NoError(t, router.appDao.CreateCourse(ctx, course))
+			courses = append(courses, course)
+		}
+
+		body := fmt.Sprintf(`{"ids": ["%s", "invalid", "%s"], "root": "/library"}`, courses[0].ID, courses[1].ID)
+		req := httptest.NewRequest(http.MethodPost, "/api/courses/move", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var resp []courseMoveResponse
+		require.NoError(t, json.Unmarshal(respBody, &resp))
+		require.Len(t, resp, 3)
+
+		require.Empty(t, resp[0].Error)
+		require.Equal(t, "/library/Course 1", resp[0].To)
+		require.Equal(t, "Course not found", resp[1].Error)
+		require.Empty(t, resp[2].Error)
+		require.Equal(t, "/library/Course 2", resp[2].To)
+
+		for _, course := range courses {
+			record, err := router.appDao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+			require.NoError(t, err)
+			require.Equal(t, filepath.Join("/library", course.Title), record.Path)
+		}
+	})
+
+	t.Run("400 (invalid)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		tests := []struct {
+			body    string
+			message string
+		}{
+			{`{`, "Error parsing data"},
+			{`{"ids": ["1"]}`, "A root is required"},
+			{`{"root": "/library"}`, "At least one course is required"},
+			{`{"ids": ["1"], "root": "/library", "mode": "copy"}`, "Invalid mode"},
+		}
+
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodPost, "/api/courses/move", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			status, body, err := requestHelper(t, router, req)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, status, tt.body)
+			require.Contains(t, string(body), tt.message)
+		}
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func TestCourses_GetCourses_FavouriteFilter(t *testing.T) {
 	t.Run("200 (favourited)", func(t *testing.T) {
 		router, ctx := setupAdmin(t)
@@ -2785,3 +3796,89 @@ func TestCourses_GetCourses_FavouriteFilter(t *testing.T) {
 		require.False(t, coursesResp[0].Favourited)
 	})
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourses_GetScanHistory(t *testing.T) {
+	t.Run("200 (found)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		courses := []*models.Course{}
+		for i := range 2 {
+			course := &models.Course{Title: fmt.Sprintf("course %d", i+1), Path: fmt.Sprintf("/course %d", i+1)}
+			require.NoError(t, router.appDao.CreateCourse(ctx, course))
+			courses = append(courses, course)
+		}
+
+		for i, status := range []types.ScanStatusType{types.ScanStatusCompleted, types.ScanStatusFailed} {
+			history := &models.ScanHistory{
+				CourseID:  courses[0].ID,
+				Status:    status,
+				StartedAt: types.DateTime(time.Now().Add(time.Duration(i) * time.Minute)),
+			}
+			require.NoError(t, router.appDao.CreateScanHistory(ctx, history))
+		}
+
+		require.NoError(t, router.appDao.CreateScanHistory(ctx, &models.ScanHistory{CourseID: courses[1].ID, Status: types.ScanStatusCompleted}))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/courses/"+courses[0].ID+"/scans", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		paginationResp, historyResp := unmarshalHelper[scanHistoryResponse](t, body)
+		require.Equal(t, 2, int(paginationResp.TotalItems))
+		require.Equal(t, types.ScanStatusFailed, historyResp[0].Status)
+		require.Equal(t, types.ScanStatusCompleted, historyResp[1].Status)
+		require.Equal(t, courses[0].Title, historyResp[0].CourseTitle)
+
+		// Filter by status
+		q := url.QueryEscape("status:completed")
+		status, body, err = requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/courses/"+courses[0].ID+"/scans?q="+q, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		paginationResp, historyResp = unmarshalHelper[scanHistoryResponse](t, body)
+		require.Equal(t, 1, int(paginationResp.TotalItems))
+		require.Equal(t, types.ScanStatusCompleted, historyResp[0].Status)
+	})
+
+	t.Run("404 (course not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/courses/invalid/scans", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Contains(t, string(body), "Course not found")
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/courses/test/scans", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Equal(t, `{"message":"User is not an admin"}`, string(body))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// zipHelper builds a zip archive containing the files
+func zipHelper(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
@@ -2,15 +2,21 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/geerew/off-course/dao"
 	"github.com/geerew/off-course/models"
 	"github.com/geerew/off-course/utils/media/hls"
+	"github.com/geerew/off-course/utils/media/subtitles"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	"github.com/houseme/mobiledetect/ua"
+	"github.com/spf13/afero"
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -32,6 +38,9 @@ func (r *Router) initHlsRoutes() {
 	// Master playlist
 	g.Get("/:asset_id/master.m3u8", hlsApi.GetMaster)
 
+	// Playback decision
+	g.Post("/:asset_id/playback-info", hlsApi.GetPlaybackInfo)
+
 	// Video streams
 	g.Get("/:asset_id/video/:index/:quality/index.m3u8", hlsApi.GetVideoIndex)
 	g.Get("/:asset_id/video/:index/:quality/segment-:num.ts", hlsApi.GetVideoSegment)
@@ -39,14 +48,55 @@ func (r *Router) initHlsRoutes() {
 	// Audio streams
 	g.Get("/:asset_id/audio/:index/index.m3u8", hlsApi.GetAudioIndex)
 	g.Get("/:asset_id/audio/:index/segment-:num.ts", hlsApi.GetAudioSegment)
+	g.Get("/:asset_id/audio/:index/:mode/index.m3u8", hlsApi.GetAudioIndex)
+	g.Get("/:asset_id/audio/:index/:mode/segment-:num.ts", hlsApi.GetAudioSegment)
+
+	// fMP4 video and audio streams
+	g.Get("/:asset_id/fmp4/video/:index/:quality/index.m3u8", hlsApi.GetVideoIndex)
+	g.Get("/:asset_id/fmp4/video/:index/:quality/init.mp4", hlsApi.GetVideoInit)
+	g.Get("/:asset_id/fmp4/video/:index/:quality/segment-:num.m4s", hlsApi.GetVideoSegment)
+	g.Get("/:asset_id/fmp4/audio/:index/index.m3u8", hlsApi.GetAudioIndex)
+	g.Get("/:asset_id/fmp4/audio/:index/init.mp4", hlsApi.GetAudioInit)
+	g.Get("/:asset_id/fmp4/audio/:index/segment-:num.m4s", hlsApi.GetAudioSegment)
+	g.Get("/:asset_id/fmp4/audio/:index/:mode/index.m3u8", hlsApi.GetAudioIndex)
+	g.Get("/:asset_id/fmp4/audio/:index/:mode/init.mp4", hlsApi.GetAudioInit)
+	g.Get("/:asset_id/fmp4/audio/:index/:mode/segment-:num.m4s", hlsApi.GetAudioSegment)
+
+	// Subtitles
+	g.Get("/:asset_id/subtitles/embedded/:index/index.m3u8", hlsApi.GetEmbeddedSubtitleIndex)
+	g.Get("/:asset_id/subtitles/embedded/:index/segment-:num.vtt", hlsApi.GetEmbeddedSubtitleSegment)
+	g.Get("/:asset_id/subtitles/:subtitle_id/index.m3u8", hlsApi.GetSubtitleIndex)
+	g.Get("/:asset_id/subtitles/:subtitle_id/subtitles.vtt", hlsApi.GetSubtitle)
+
+	// Chapters
+	g.Get("/:asset_id/chapters.vtt", hlsApi.GetChapters)
 
 	// Qualities endpoint
 	g.Get("/:asset_id/qualities", hlsApi.GetQualities)
+
+	// Thumbnails
+	g.Get("/:asset_id/thumbnail.webp", hlsApi.GetThumbnail)
+	g.Get("/:asset_id/thumbnails.vtt", hlsApi.GetThumbnails)
+	g.Get("/:asset_id/sprite-:num.jpg", hlsApi.GetThumbnailSprite)
+
+	// Cache
+	g.Get("/cache", protectedRoute, hlsApi.GetCacheStats)
+	g.Delete("/cache", protectedRoute, hlsApi.PurgeCache)
+
+	// Transcoding profile
+	g.Get("/profile", protectedRoute, hlsApi.GetProfile)
+	g.Put("/profile", protectedRoute, hlsApi.UpdateProfile)
+
+	// MPEG-DASH manifest, over the fMP4 streams
+	r.apiGroup("dash").Get("/:asset_id/manifest.mpd", hlsApi.GetDashManifest)
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetMaster returns the master playlist (single stream based on device type)
+// GetMaster returns the master playlist (single stream based on device type). The segment
+// format is taken from the `format` query param, falling back to the best format for the client.
+// The `playback` and `quality` query params, as returned by the playback info, select the
+// playlist of a playback decision instead
 func (api *hlsAPI) GetMaster(c *fiber.Ctx) error {
 	assetID := c.Params("asset_id")
 	if assetID == "" {
@@ -55,8 +105,24 @@ func (api *hlsAPI) GetMaster(c *fiber.Ctx) error {
 		})
 	}
 
+	ua := ua.New(c.Get("User-Agent"))
+
+	format, err := masterFormat(c, ua)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid format",
+		})
+	}
+
+	decision, err := masterPlayback(c, format)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid playback",
+		})
+	}
+
 	// Verify authentication
-	_, ctx, err := principalCtx(c)
+	principal, ctx, err := principalCtx(c)
 	if err != nil {
 		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
 	}
@@ -81,10 +147,43 @@ func (api *hlsAPI) GetMaster(c *fiber.Ctx) error {
 		})
 	}
 
-	ua := ua.New(c.Get("User-Agent"))
+	// Subtitles are offered as renditions in the master playlist
+	subtitleOpts := dao.NewOptions().
+		WithWhere(squirrel.Eq{models.ASSET_SUBTITLE_TABLE_ASSET_ID: assetID}).
+		WithOrderBy(models.ASSET_SUBTITLE_TABLE_LANGUAGE+" asc", models.ASSET_SUBTITLE_TABLE_PATH+" asc")
+
+	assetSubtitles, err := api.r.appDao.ListAssetSubtitles(ctx, subtitleOpts)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to lookup subtitles",
+		})
+	}
+
+	subtitles := make([]hls.Subtitle, 0, len(assetSubtitles))
+	for _, subtitle := range assetSubtitles {
+		subtitles = append(subtitles, hls.Subtitle{ID: subtitle.ID, Language: subtitle.Language})
+	}
+
+	// The audio track in the preferred language of the user is the default
+	audioLanguage, err := api.preferredAudioLanguage(ctx, principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to lookup user",
+		})
+	}
 
-	// Get simple master playlist (single stream based on device type)
-	master, err := api.r.app.Transcoder.GetMasterPlaylistSingle(ctx, asset.Path, assetID, ua.Mobile())
+	var master string
+	if decision != nil {
+		master, err = api.r.app.Transcoder.GetMasterPlaylistPlayback(ctx, asset.Path, assetID, *decision, audioLanguage, subtitles)
+		if errors.Is(err, hls.ErrQualityUnavailable) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid playback",
+			})
+		}
+	} else {
+		// Get simple master playlist (single stream based on device type)
+		master, err = api.r.app.Transcoder.GetMasterPlaylistSingle(ctx, asset.Path, assetID, format, ua.Mobile(), audioLanguage, subtitles)
+	}
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate master playlist",
@@ -97,6 +196,108 @@ func (api *hlsAPI) GetMaster(c *fiber.Ctx) error {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// GetPlaybackInfo decides, from the capabilities posted by the client, whether it direct plays,
+// remuxes or transcodes the asset. The decision is returned with the URL to play
+func (api *hlsAPI) GetPlaybackInfo(c *fiber.Ctx) error {
+	assetID := c.Params("asset_id")
+
+	req := &hlsPlaybackInfoRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	format, err := masterFormat(c, ua.New(c.Get("User-Agent")))
+	if err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Invalid format", err)
+	}
+
+	// Verify authentication
+	principal, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	// Get asset with metadata and verify it belongs to a course
+	asset, err := api.getAssetWithMetadataAndCourse(ctx, assetID)
+	if err != nil || asset == nil {
+		return errorResponse(c, fiber.StatusNotFound, "Asset not found", err)
+	}
+
+	// Check if transcoder is available
+	if api.r.app.Transcoder == nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Transcoder not available", nil)
+	}
+
+	// The audio track in the preferred language of the user is the one played
+	audioLanguage, err := api.preferredAudioLanguage(ctx, principal.UserID)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up user", err)
+	}
+
+	caps := hls.ClientCapabilities{
+		VideoCodecs: req.VideoCodecs,
+		AudioCodecs: req.AudioCodecs,
+		Containers:  req.Containers,
+		MaxHeight:   req.MaxHeight,
+		MaxBitrate:  req.MaxBitrate,
+	}
+
+	decision, err := api.r.app.Transcoder.DecidePlayback(ctx, asset.Path, assetID, caps, format, audioLanguage)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error deciding playback", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(hlsPlaybackInfoResponseHelper(asset, decision))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetDashManifest returns the MPEG-DASH manifest. Its segments are served by the fMP4 routes
+func (api *hlsAPI) GetDashManifest(c *fiber.Ctx) error {
+	assetID := c.Params("asset_id")
+
+	// Verify authentication
+	principal, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	// Get asset and verify it belongs to a course
+	asset, err := api.getAssetWithMetadataAndCourse(ctx, assetID)
+	if err != nil || asset == nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Asset not found",
+		})
+	}
+
+	// Check if transcoder is available
+	if api.r.app.Transcoder == nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Transcoder not available",
+		})
+	}
+
+	// The audio track in the preferred language of the user is the main track
+	audioLanguage, err := api.preferredAudioLanguage(ctx, principal.UserID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to lookup user",
+		})
+	}
+
+	manifest, err := api.r.app.Transcoder.GetDashManifest(ctx, asset.Path, assetID, audioLanguage)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate manifest",
+		})
+	}
+
+	c.Set("Content-Type", "application/dash+xml")
+	return c.Status(http.StatusOK).SendString(manifest)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // GetVideoIndex returns the video index playlist
 func (api *hlsAPI) GetVideoIndex(c *fiber.Ctx) error {
 	assetID := c.Params("asset_id")
@@ -137,7 +338,7 @@ func (api *hlsAPI) GetVideoIndex(c *fiber.Ctx) error {
 	}
 
 	// Get video index
-	indexPlaylist, err := api.r.app.Transcoder.GetVideoIndex(ctx, asset.Path, uint32(index), quality, assetID)
+	indexPlaylist, err := api.r.app.Transcoder.GetVideoIndex(ctx, asset.Path, uint32(index), quality, routeFormat(c), assetID)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate video index",
@@ -198,8 +399,13 @@ func (api *hlsAPI) GetVideoSegment(c *fiber.Ctx) error {
 	}
 
 	// Get video segment
-	segmentPath, err := api.r.app.Transcoder.GetVideoSegment(ctx, asset.Path, uint32(index), quality, int32(segment), assetID)
+	segmentPath, err := api.r.app.Transcoder.GetVideoSegment(ctx, asset.Path, uint32(index), quality, routeFormat(c), int32(segment), assetID)
 	if err != nil {
+		if errors.Is(err, hls.ErrTranscodeLimit) {
+			return c.Status(http.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Transcode limit reached",
+			})
+		}
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate video segment",
 		})
@@ -223,6 +429,13 @@ func (api *hlsAPI) GetAudioIndex(c *fiber.Ctx) error {
 		})
 	}
 
+	mode, ok := audioMode(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid audio mode",
+		})
+	}
+
 	// Verify authentication
 	_, ctx, err := principalCtx(c)
 	if err != nil {
@@ -243,7 +456,7 @@ func (api *hlsAPI) GetAudioIndex(c *fiber.Ctx) error {
 	}
 
 	// Get audio index
-	indexPlaylist, err := api.r.app.Transcoder.GetAudioIndex(ctx, asset.Path, uint32(index), assetID)
+	indexPlaylist, err := api.r.app.Transcoder.GetAudioIndex(ctx, asset.Path, uint32(index), mode, routeFormat(c), assetID)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate audio index",
@@ -276,6 +489,13 @@ func (api *hlsAPI) GetAudioSegment(c *fiber.Ctx) error {
 		})
 	}
 
+	mode, ok := audioMode(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid audio mode",
+		})
+	}
+
 	// Verify authentication
 	_, ctx, err := principalCtx(c)
 	if err != nil {
@@ -296,7 +516,7 @@ func (api *hlsAPI) GetAudioSegment(c *fiber.Ctx) error {
 	}
 
 	// Get audio segment
-	segmentPath, err := api.r.app.Transcoder.GetAudioSegment(ctx, asset.Path, uint32(index), int32(segment), assetID)
+	segmentPath, err := api.r.app.Transcoder.GetAudioSegment(ctx, asset.Path, uint32(index), mode, routeFormat(c), int32(segment), assetID)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate audio segment",
@@ -309,6 +529,313 @@ func (api *hlsAPI) GetAudioSegment(c *fiber.Ctx) error {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// GetVideoInit returns the init segment of a fMP4 video variant
+func (api *hlsAPI) GetVideoInit(c *fiber.Ctx) error {
+	assetID := c.Params("asset_id")
+	indexStr := c.Params("index")
+	qualityStr := c.Params("quality")
+
+	index, err := strconv.ParseUint(indexStr, 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid video index",
+		})
+	}
+
+	quality, err := hls.QualityFromString(qualityStr)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid quality",
+		})
+	}
+
+	// Verify authentication
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	// Get asset and verify it belongs to a course
+	asset, err := api.getAssetWithMetadataAndCourse(ctx, assetID)
+	if err != nil || asset == nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Asset not found",
+		})
+	}
+
+	// Get init segment
+	initPath, err := api.r.app.Transcoder.GetVideoInit(ctx, asset.Path, uint32(index), quality, assetID)
+	if err != nil {
+		if errors.Is(err, hls.ErrTranscodeLimit) {
+			return c.Status(http.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Transcode limit reached",
+			})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate video init segment",
+		})
+	}
+
+	return c.Status(http.StatusOK).SendFile(initPath)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetAudioInit returns the init segment of a fMP4 audio stream
+func (api *hlsAPI) GetAudioInit(c *fiber.Ctx) error {
+	assetID := c.Params("asset_id")
+	indexStr := c.Params("index")
+
+	index, err := strconv.ParseUint(indexStr, 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid audio index",
+		})
+	}
+
+	mode, ok := audioMode(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid audio mode",
+		})
+	}
+
+	// Verify authentication
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	// Get asset and verify it belongs to a course
+	asset, err := api.getAssetWithMetadataAndCourse(ctx, assetID)
+	if err != nil || asset == nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Asset not found",
+		})
+	}
+
+	// Get init segment
+	initPath, err := api.r.app.Transcoder.GetAudioInit(ctx, asset.Path, uint32(index), mode, assetID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate audio init segment",
+		})
+	}
+
+	return c.Status(http.StatusOK).SendFile(initPath)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetEmbeddedSubtitleIndex returns the index playlist for an embedded subtitle stream
+func (api *hlsAPI) GetEmbeddedSubtitleIndex(c *fiber.Ctx) error {
+	assetID := c.Params("asset_id")
+	indexStr := c.Params("index")
+
+	index, err := strconv.ParseUint(indexStr, 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid subtitle index",
+		})
+	}
+
+	// Verify authentication
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	// Get asset and verify it belongs to a course
+	asset, err := api.getAssetWithMetadataAndCourse(ctx, assetID)
+	if err != nil || asset == nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Asset not found",
+		})
+	}
+
+	// Get subtitle index
+	indexPlaylist, err := api.r.app.Transcoder.GetEmbeddedSubtitleIndex(ctx, asset.Path, uint32(index), assetID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate subtitle index",
+		})
+	}
+
+	c.Set("Content-Type", "application/vnd.apple.mpegurl")
+	return c.Status(http.StatusOK).SendString(indexPlaylist)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetEmbeddedSubtitleSegment returns a WebVTT segment of an embedded subtitle stream
+func (api *hlsAPI) GetEmbeddedSubtitleSegment(c *fiber.Ctx) error {
+	assetID := c.Params("asset_id")
+	indexStr := c.Params("index")
+	segmentStr := c.Params("num")
+
+	index, err := strconv.ParseUint(indexStr, 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid subtitle index",
+		})
+	}
+
+	segment, err := strconv.ParseInt(segmentStr, 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid segment number",
+		})
+	}
+
+	// Verify authentication
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	// Get asset and verify it belongs to a course
+	asset, err := api.getAssetWithMetadataAndCourse(ctx, assetID)
+	if err != nil || asset == nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Asset not found",
+		})
+	}
+
+	// Get subtitle segment
+	segmentPath, err := api.r.app.Transcoder.GetEmbeddedSubtitleSegment(ctx, asset.Path, uint32(index), int32(segment), assetID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate subtitle segment",
+		})
+	}
+
+	// Serve the segment file
+	c.Set("Content-Type", "text/vtt; charset=utf-8")
+	return c.Status(http.StatusOK).SendFile(segmentPath)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetSubtitleIndex returns the index playlist for a subtitle rendition
+func (api *hlsAPI) GetSubtitleIndex(c *fiber.Ctx) error {
+	assetID := c.Params("asset_id")
+
+	// Verify authentication
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	// Get asset and verify it belongs to a course
+	asset, err := api.getAssetWithMetadataAndCourse(ctx, assetID)
+	if err != nil || asset == nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Asset not found",
+		})
+	}
+
+	subtitle, err := api.getAssetSubtitle(ctx, assetID, c.Params("subtitle_id"))
+	if err != nil || subtitle == nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Subtitle not found",
+		})
+	}
+
+	// Check if transcoder is available
+	if api.r.app.Transcoder == nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Transcoder not available",
+		})
+	}
+
+	// Get subtitle index
+	indexPlaylist, err := api.r.app.Transcoder.GetSubtitleIndex(ctx, asset.Path, assetID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate subtitle index",
+		})
+	}
+
+	c.Set("Content-Type", "application/vnd.apple.mpegurl")
+	return c.Status(http.StatusOK).SendString(indexPlaylist)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetSubtitle returns a subtitle file as WebVTT, converting it when necessary
+func (api *hlsAPI) GetSubtitle(c *fiber.Ctx) error {
+	assetID := c.Params("asset_id")
+
+	// Verify authentication
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	subtitle, err := api.getAssetSubtitle(ctx, assetID, c.Params("subtitle_id"))
+	if err != nil || subtitle == nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Subtitle not found",
+		})
+	}
+
+	data, err := afero.ReadFile(api.r.app.AppFs.Fs, subtitle.Path)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Subtitle file not found",
+		})
+	}
+
+	vtt, err := subtitles.ToWebVTT(data, subtitles.Format(subtitle.Format))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to convert subtitle",
+		})
+	}
+
+	c.Set("Content-Type", "text/vtt; charset=utf-8")
+	return c.Status(http.StatusOK).Send(vtt)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetChapters returns the chapters embedded in a media file as a WebVTT chapters track
+func (api *hlsAPI) GetChapters(c *fiber.Ctx) error {
+	assetID := c.Params("asset_id")
+
+	// Verify authentication
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	metadata, err := api.r.appDao.GetAssetMetadata(ctx, assetID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Error looking up asset metadata",
+		})
+	}
+
+	if metadata == nil || len(metadata.ChapterMetadata) == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Chapters not found",
+		})
+	}
+
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n")
+
+	for i, chapter := range metadata.ChapterMetadata {
+		fmt.Fprintf(&vtt, "\n%d\n%s --> %s\n%s\n", i+1,
+			subtitles.FormatVTTTime(chapter.StartSec), subtitles.FormatVTTTime(chapter.EndSec), chapter.DisplayTitle())
+	}
+
+	c.Set("Content-Type", "text/vtt; charset=utf-8")
+	return c.Status(http.StatusOK).SendString(vtt.String())
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // GetQualities returns the available qualities for a video
 func (api *hlsAPI) GetQualities(c *fiber.Ctx) error {
 	assetID := c.Params("asset_id")
@@ -365,6 +892,193 @@ func (api *hlsAPI) GetQualities(c *fiber.Ctx) error {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// GetCacheStats returns the size and limits of the HLS cache, along with each cached quality
+// and audio track
+func (api *hlsAPI) GetCacheStats(c *fiber.Ctx) error {
+	stats, err := api.r.app.Transcoder.Cache().Stats()
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error reading HLS cache", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(hlsCacheStatsResponseHelper(stats))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// PurgeCache removes the transcoded segments of every asset or, when a `courseId` is given, the
+// assets of a course. Segments being transcoded are skipped
+func (api *hlsAPI) PurgeCache(c *fiber.Ctx) error {
+	var assetIDs []string
+
+	if courseID := c.Query("courseId"); courseID != "" {
+		_, ctx, err := principalCtx(c)
+		if err != nil {
+			return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+		}
+
+		course, err := api.r.appDao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseID}))
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course", err)
+		}
+
+		if course == nil {
+			return errorResponse(c, fiber.StatusNotFound, "Course not found", nil)
+		}
+
+		assets, err := api.r.appDao.ListAssets(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_COURSE_ID: courseID}))
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error looking up course assets", err)
+		}
+
+		assetIDs = make([]string, 0, len(assets))
+		for _, asset := range assets {
+			assetIDs = append(assetIDs, asset.ID)
+		}
+	}
+
+	result, err := api.r.app.Transcoder.Cache().Purge(assetIDs)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error purging HLS cache", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&hlsCachePurgeResponse{
+		Purged:  result.Purged,
+		Skipped: result.Skipped,
+		Freed:   result.Freed,
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetProfile returns the transcoding profile
+func (api *hlsAPI) GetProfile(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(hlsProfileResponseHelper(api.r.app.Transcoder.Profile()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// UpdateProfile replaces the transcoding profile. It applies to the next request for an asset
+func (api *hlsAPI) UpdateProfile(c *fiber.Ctx) error {
+	req := &hlsProfileRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	profile := &hls.Profile{
+		Preset:          req.Preset,
+		CRF:             req.CRF,
+		MaxQuality:      hls.Quality(req.MaxQuality),
+		AudioBitrate:    req.AudioBitrate,
+		AudioChannels:   req.AudioChannels,
+		SegmentDuration: req.SegmentDuration,
+		MaxHeads:        req.MaxHeads,
+		MaxUserHeads:    req.MaxUserHeads,
+
+		SurroundPassthrough: req.SurroundPassthrough,
+
+		NormalizeLoudness: req.NormalizeLoudness,
+		LoudnessTarget:    req.LoudnessTarget,
+	}
+
+	for _, rung := range req.Rungs {
+		profile.Rungs = append(profile.Rungs, hls.Rung{
+			Quality:        hls.Quality(rung.Quality),
+			Enabled:        rung.Enabled,
+			AverageBitrate: rung.AverageBitrate,
+			MaxBitrate:     rung.MaxBitrate,
+		})
+	}
+
+	if err := api.r.app.Transcoder.SetProfile(ctx, profile); err != nil {
+		if errors.Is(err, hls.ErrInvalidProfile) {
+			return errorResponse(c, fiber.StatusBadRequest, "Invalid profile", err)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, "Error updating profile", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(hlsProfileResponseHelper(api.r.app.Transcoder.Profile()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetThumbnail returns the thumbnail of a video
+func (api *hlsAPI) GetThumbnail(c *fiber.Ctx) error {
+	return api.sendThumbnailFile(c, api.r.app.Thumbnails.ThumbnailPath(c.Params("asset_id")))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetThumbnails returns the WebVTT thumbnail track of a video, which points at the frames of
+// the sprite sheets
+func (api *hlsAPI) GetThumbnails(c *fiber.Ctx) error {
+	c.Set("Content-Type", "text/vtt; charset=utf-8")
+	return api.sendThumbnailFile(c, api.r.app.Thumbnails.VttPath(c.Params("asset_id")))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetThumbnailSprite returns a sprite sheet of a video
+func (api *hlsAPI) GetThumbnailSprite(c *fiber.Ctx) error {
+	sprite, err := strconv.Atoi(c.Params("num"))
+	if err != nil || sprite < 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sprite number",
+		})
+	}
+
+	return api.sendThumbnailFile(c, api.r.app.Thumbnails.SpritePath(c.Params("asset_id"), sprite))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// sendThumbnailFile sends a generated thumbnail file of a video asset. When the thumbnails
+// have not been generated, the asset is queued and a 404 is returned
+func (api *hlsAPI) sendThumbnailFile(c *fiber.Ctx, path string) error {
+	assetID := c.Params("asset_id")
+
+	// Verify authentication
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	// Get asset and verify it belongs to a course
+	asset, err := api.getAssetWithMetadataAndCourse(ctx, assetID)
+	if err != nil || asset == nil || !asset.Type.IsVideo() {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Asset not found",
+		})
+	}
+
+	if !api.r.app.Thumbnails.Exists(assetID) {
+		api.r.app.Thumbnails.Add(assetID)
+
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Thumbnails not generated",
+		})
+	}
+
+	exists, err := afero.Exists(api.r.app.AppFs.Fs, path)
+	if err != nil || !exists {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Thumbnail not found",
+		})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, no-cache")
+
+	// The fiber function sendFile(...) does not support using a custom FS. Therefore, use
+	// SendFile() from the filesystem middleware
+	return filesystem.SendFile(c, afero.NewHttpFs(api.r.app.AppFs.Fs), path)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // getAssetWithMetadataAndCourse retrieves an asset with its metadata by asset ID
 // and verifies it belongs to a course
 func (api *hlsAPI) getAssetWithMetadataAndCourse(ctx context.Context, assetID string) (*models.Asset, error) {
@@ -385,3 +1099,112 @@ func (api *hlsAPI) getAssetWithMetadataAndCourse(ctx context.Context, assetID st
 
 	return asset, nil
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// getAssetSubtitle retrieves a subtitle by ID and verifies it belongs to the asset and that
+// the asset belongs to a course
+func (api *hlsAPI) getAssetSubtitle(ctx context.Context, assetID, subtitleID string) (*models.AssetSubtitle, error) {
+	dbOpts := dao.NewOptions().
+		WithWhere(squirrel.Eq{
+			models.ASSET_SUBTITLE_TABLE_ID:       subtitleID,
+			models.ASSET_SUBTITLE_TABLE_ASSET_ID: assetID,
+		})
+
+	subtitle, err := api.r.appDao.GetAssetSubtitle(ctx, dbOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtitle == nil || subtitle.CourseID == "" {
+		return nil, nil
+	}
+
+	return subtitle, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// preferredAudioLanguage returns the preferred audio language of a user, or an empty string when
+// the user has none
+func (api *hlsAPI) preferredAudioLanguage(ctx context.Context, userID string) (string, error) {
+	user, err := api.r.appDao.GetUser(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.USER_TABLE_ID: userID}))
+	if err != nil {
+		return "", err
+	}
+
+	if user == nil {
+		return "", nil
+	}
+
+	return user.PreferredAudioLanguage, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// audioMode returns the audio mode of the route, such as the surround passthrough or remux of
+// an audio track. It returns false for ok when the mode is unknown
+func audioMode(c *fiber.Ctx) (hls.AudioMode, bool) {
+	mode, err := hls.AudioModeFromString(c.Params("mode"))
+	return mode, err == nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// routeFormat returns the segment format served by the route. fMP4 streams are served under
+// /fmp4/
+func routeFormat(c *fiber.Ctx) hls.Format {
+	if strings.Contains(c.Route().Path, "/fmp4/") {
+		return hls.FormatFMP4
+	}
+
+	return hls.FormatTS
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// masterFormat returns the segment format of the master playlist. The `format` query param wins.
+// Otherwise Safari and iOS, which play fMP4 natively and prefer it, get fMP4 and every other
+// client gets MPEG-TS
+func masterFormat(c *fiber.Ctx, userAgent *ua.UserAgent) (hls.Format, error) {
+	if format := c.Query("format"); format != "" {
+		return hls.FormatFromString(format)
+	}
+
+	if userAgent.IsSafari() || userAgent.IsIOS() {
+		return hls.FormatFMP4, nil
+	}
+
+	return hls.FormatTS, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// masterPlayback returns the playback decision of the master playlist from the `playback` and
+// `quality` query params, or nil when there is none. Direct play has no master playlist
+func masterPlayback(c *fiber.Ctx, format hls.Format) (*hls.PlaybackDecision, error) {
+	if c.Query("playback") == "" {
+		return nil, nil
+	}
+
+	method, err := hls.PlaybackMethodFromString(c.Query("playback"))
+	if err != nil {
+		return nil, err
+	}
+
+	if method == hls.PlaybackDirectPlay {
+		return nil, errors.New("direct play has no master playlist")
+	}
+
+	quality, err := hls.QualityFromString(c.Query("quality", string(hls.Original)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Transcoding is always to a quality of the ladder
+	if method == hls.PlaybackTranscode && (quality == hls.Original || quality == hls.NoResize) {
+		return nil, errors.New("transcoding requires a quality")
+	}
+
+	return &hls.PlaybackDecision{Method: method, Format: format, Quality: quality}, nil
+}
@@ -0,0 +1,711 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/media/hls"
+	"github.com/geerew/off-course/utils/security"
+	"github.com/geerew/off-course/utils/thumbnails"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_GetSubtitle(t *testing.T) {
+	t.Run("200 (srt)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset, subtitle := createSubtitleHelper(t, router, ctx, "/course-1/01 - Intro.en.srt", "srt")
+
+		srt := "1\r\n00:00:01,000 --> 00:00:02,500\r\nHello\r\n"
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, subtitle.Path, []byte(srt), 0644))
+
+		resp, err := router.Test(httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/subtitles/"+subtitle.ID+"/subtitles.vtt", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Contains(t, resp.Header.Get("Content-Type"), "text/vtt")
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nHello\n", string(body))
+	})
+
+	t.Run("404 (wrong asset)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		_, subtitle := createSubtitleHelper(t, router, ctx, "/course-1/01 - Intro.en.vtt", "vtt")
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, subtitle.Path, []byte("WEBVTT\n"), 0644))
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/invalid/subtitles/"+subtitle.ID+"/subtitles.vtt", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("404 (missing file)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset, subtitle := createSubtitleHelper(t, router, ctx, "/course-1/01 - Intro.en.vtt", "vtt")
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/subtitles/"+subtitle.ID+"/subtitles.vtt", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("500 (invalid subtitle)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset, subtitle := createSubtitleHelper(t, router, ctx, "/course-1/01 - Intro.en.vtt", "vtt")
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, subtitle.Path, []byte("not a subtitle"), 0644))
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/subtitles/"+subtitle.ID+"/subtitles.vtt", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusInternalServerError, status)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_GetChapters(t *testing.T) {
+	t.Run("200", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		require.NoError(t, router.appDao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 3700, Width: 1920, Height: 1080},
+			ChapterMetadata: []*models.ChapterMetadata{
+				{ChapterIndex: 0, StartSec: 0, EndSec: 62.5, Title: "Introduction"},
+				{ChapterIndex: 1, StartSec: 62.5, EndSec: 3700},
+			},
+		}))
+
+		resp, err := router.Test(httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/chapters.vtt", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Contains(t, resp.Header.Get("Content-Type"), "text/vtt")
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "WEBVTT\n\n1\n00:00:00.000 --> 00:01:02.500\nIntroduction\n\n2\n00:01:02.500 --> 01:01:40.000\nChapter 2\n", string(body))
+	})
+
+	t.Run("404 (no chapters)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		require.NoError(t, router.appDao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 10, Width: 1920, Height: 1080},
+		}))
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/chapters.vtt", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+
+		status, _, err = requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/invalid/chapters.vtt", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_GetMaster(t *testing.T) {
+	t.Run("200 (preferred audio language)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		user, err := router.appDao.GetUser(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.USER_TABLE_ID: "user"}))
+		require.NoError(t, err)
+
+		user.PreferredAudioLanguage = "fre"
+		require.NoError(t, router.appDao.UpdateUser(ctx, user))
+
+		asset := createVideoHelper(t, router, ctx)
+		require.NoError(t, router.appDao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 10, Width: 1920, Height: 1080},
+			AudioTrackMetadata: []*models.AudioTrackMetadata{
+				{StreamIndex: 1, TrackIndex: 0, Language: "eng", Codec: "aac", Channels: 2, IsDefault: true},
+				{StreamIndex: 2, TrackIndex: 1, Language: "fre", Codec: "aac", Channels: 2},
+			},
+		}))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/master.m3u8", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, string(body), "LANGUAGE=\"fre\",NAME=\"fre\",DEFAULT=YES,")
+		require.Contains(t, string(body), "LANGUAGE=\"eng\",NAME=\"eng\",AUTOSELECT=YES,")
+	})
+
+	t.Run("200 (format)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		require.NoError(t, router.appDao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 10, Width: 1920, Height: 1080, VideoCodec: "hevc"},
+			AudioMetadata: &models.AudioMetadata{Codec: "aac", Channels: 2},
+		}))
+
+		// MPEG-TS by default
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/master.m3u8", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, string(body), "/api/hls/"+asset.ID+"/video/0/original/index.m3u8")
+		require.Contains(t, string(body), "URI=\"audio/0/index.m3u8\"")
+
+		// fMP4 when asked for
+		status, body, err = requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/master.m3u8?format=fmp4", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, string(body), "CODECS=\"hvc1.1.6.L150.90,mp4a.40.2\"")
+		require.Contains(t, string(body), "/api/hls/"+asset.ID+"/fmp4/video/0/original/index.m3u8")
+		require.Contains(t, string(body), "URI=\"fmp4/audio/0/index.m3u8\"")
+
+		// fMP4 for Safari
+		req := httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/master.m3u8", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15")
+		status, body, err = requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, string(body), "/fmp4/video/")
+
+		// MPEG-TS for Chrome, which also claims to be Safari
+		req = httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/master.m3u8", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36")
+		status, body, err = requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.NotContains(t, string(body), "/fmp4/")
+	})
+
+	t.Run("200 (playback)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		require.NoError(t, router.appDao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 10, Width: 1920, Height: 1080, VideoCodec: "h264"},
+			AudioMetadata: &models.AudioMetadata{Codec: "aac", Channels: 2},
+		}))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/master.m3u8?playback=remux&format=ts", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, string(body), "URI=\"audio/0/remux/index.m3u8\"")
+		require.Contains(t, string(body), "/api/hls/"+asset.ID+"/video/0/original/index.m3u8")
+
+		status, body, err = requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/master.m3u8?playback=transcode&quality=720p", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, string(body), "/api/hls/"+asset.ID+"/video/0/720p/index.m3u8")
+	})
+
+	t.Run("400 (invalid format)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/master.m3u8?format=webm", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Invalid format")
+	})
+
+	t.Run("400 (invalid playback)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		for _, query := range []string{
+			"playback=stream",
+			"playback=direct_play",
+			"playback=transcode&quality=4k",
+			"playback=transcode&quality=transcode",
+			"playback=transcode&quality=original",
+			"playback=transcode",
+		} {
+			status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/master.m3u8?"+query, nil))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, status, query)
+			require.Contains(t, string(body), "Invalid playback")
+		}
+	})
+
+	t.Run("400 (unavailable quality)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		require.NoError(t, router.appDao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 10, Width: 1920, Height: 1080, VideoCodec: "h264"},
+			AudioMetadata: &models.AudioMetadata{Codec: "aac", Channels: 2},
+		}))
+
+		// 1440p is higher than the video
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/master.m3u8?playback=transcode&quality=1440p", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Invalid playback")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_GetPlaybackInfo(t *testing.T) {
+	playbackInfo := func(t *testing.T, router *Router, assetID, body string) (int, *hlsPlaybackInfoResponse) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/hls/"+assetID+"/playback-info", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+
+		if status != http.StatusOK {
+			return status, nil
+		}
+
+		var respData hlsPlaybackInfoResponse
+		require.NoError(t, json.Unmarshal(respBody, &respData))
+		return status, &respData
+	}
+
+	t.Run("200", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		require.NoError(t, router.appDao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 10, Width: 1920, Height: 1080, VideoCodec: "hevc"},
+			AudioMetadata: &models.AudioMetadata{Codec: "aac", Channels: 2},
+		}))
+
+		// Direct play
+		status, respData := playbackInfo(t, router, asset.ID, `{"videoCodecs": ["hevc"], "audioCodecs": ["aac"], "containers": ["mp4"]}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "direct_play", respData.Method)
+		require.Empty(t, respData.Format)
+		require.Equal(t, "/api/courses/"+asset.CourseID+"/lessons/"+asset.LessonID+"/assets/"+asset.ID+"/serve", respData.URL)
+
+		// Remux, into fMP4 for HEVC
+		status, respData = playbackInfo(t, router, asset.ID, `{"videoCodecs": ["hevc"], "audioCodecs": ["aac"], "containers": ["mkv"]}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "remux", respData.Method)
+		require.Equal(t, "container mp4 is not supported", respData.Reason)
+		require.Equal(t, "fmp4", respData.Format)
+		require.Equal(t, "/api/hls/"+asset.ID+"/master.m3u8?format=fmp4&playback=remux", respData.URL)
+
+		// Transcode
+		status, respData = playbackInfo(t, router, asset.ID, `{"videoCodecs": ["h264"], "audioCodecs": ["aac"], "containers": ["mp4"], "maxHeight": 720}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "transcode", respData.Method)
+		require.Equal(t, "720p", respData.Quality)
+		require.Equal(t, "/api/hls/"+asset.ID+"/master.m3u8?format=ts&playback=transcode&quality=720p", respData.URL)
+	})
+
+	t.Run("400 (invalid data)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		status, _ := playbackInfo(t, router, asset.ID, `{"maxHeight": "tall"}`)
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("404 (invalid asset)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, _ := playbackInfo(t, router, "invalid", `{}`)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_GetDashManifest(t *testing.T) {
+	t.Run("200", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		require.NoError(t, router.appDao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 10, Width: 1920, Height: 1080, VideoCodec: "hevc"},
+			AudioMetadata: &models.AudioMetadata{Codec: "aac", Channels: 2},
+		}))
+
+		resp, err := router.Test(httptest.NewRequest(http.MethodGet, "/api/dash/"+asset.ID+"/manifest.mpd", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Contains(t, resp.Header.Get("Content-Type"), "application/dash+xml")
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), `type="static" mediaPresentationDuration="PT10.000S"`)
+		require.Contains(t, string(body), `initialization="/api/hls/`+asset.ID+`/fmp4/video/0/$RepresentationID$/init.mp4"`)
+		require.Contains(t, string(body), `<Representation id="original" codecs="hvc1.1.6.L150.90"`)
+		require.Contains(t, string(body), `media="/api/hls/`+asset.ID+`/fmp4/audio/0/segment-$Number$.m4s"`)
+	})
+
+	t.Run("404 (invalid asset)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/dash/invalid/manifest.mpd", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_GetAudioIndex(t *testing.T) {
+	t.Run("400 (invalid mode)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/audio/0/atmos/index.m3u8", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Invalid audio mode")
+	})
+
+	t.Run("500 (no surround stream)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/audio/0/surround/index.m3u8", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusInternalServerError, status)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_GetThumbnails(t *testing.T) {
+	t.Run("200", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		thumbs := router.app.Thumbnails
+		vtt := thumbnails.BuildVtt([]float64{0}, 5, 160, 90)
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, thumbs.VttPath(asset.ID), []byte(vtt), 0644))
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, thumbs.SpritePath(asset.ID, 0), []byte("sprite"), 0644))
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, thumbs.ThumbnailPath(asset.ID), []byte("thumbnail"), 0644))
+
+		resp, err := router.Test(httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/thumbnails.vtt", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Contains(t, resp.Header.Get("Content-Type"), "text/vtt")
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, vtt, string(body))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/sprite-0.jpg", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "sprite", string(body))
+
+		status, body, err = requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/thumbnail.webp", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "thumbnail", string(body))
+	})
+
+	t.Run("400 (invalid sprite)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/sprite-abc.jpg", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("404 (not generated)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/thumbnails.vtt", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+
+		// The asset is queued
+		require.Equal(t, 1, router.app.Thumbnails.Pending())
+	})
+
+	t.Run("404 (missing sprite)", func(t *testing.T) {
+		router, ctx := setupUser(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, router.app.Thumbnails.VttPath(asset.ID), []byte("WEBVTT\n"), 0644))
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/"+asset.ID+"/sprite-5.jpg", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("404 (invalid asset)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/invalid/thumbnails.vtt", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Zero(t, router.app.Thumbnails.Pending())
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_GetCacheStats(t *testing.T) {
+	t.Run("200 (entries)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		segment := filepath.Join(router.app.Config.DataDir, "hls", "asset1", "720p", "segment-0-0.ts")
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, segment, make([]byte, 100), 0644))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/cache", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData hlsCacheStatsResponse
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.Equal(t, int64(100), respData.Size)
+		require.Len(t, respData.Entries, 1)
+		require.Equal(t, "asset1", respData.Entries[0].AssetID)
+		require.Equal(t, "720p", respData.Entries[0].Stream)
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/cache", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Equal(t, `{"message":"User is not an admin"}`, string(body))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_PurgeCache(t *testing.T) {
+	t.Run("200 (all)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		root := filepath.Join(router.app.Config.DataDir, "hls")
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, filepath.Join(root, "asset1", "720p", "segment-0-0.ts"), make([]byte, 100), 0644))
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, filepath.Join(root, "asset2", "720p", "segment-0-0.ts"), make([]byte, 100), 0644))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/hls/cache", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData hlsCachePurgeResponse
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.Equal(t, 2, respData.Purged)
+		require.Equal(t, int64(200), respData.Freed)
+	})
+
+	t.Run("200 (course)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		root := filepath.Join(router.app.Config.DataDir, "hls")
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, filepath.Join(root, asset.ID, "720p", "segment-0-0.ts"), make([]byte, 100), 0644))
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, filepath.Join(root, "other", "720p", "segment-0-0.ts"), make([]byte, 100), 0644))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/hls/cache?courseId="+asset.CourseID, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData hlsCachePurgeResponse
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.Equal(t, 1, respData.Purged)
+
+		exists, err := afero.DirExists(router.app.AppFs.Fs, filepath.Join(root, "other", "720p"))
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+
+	t.Run("404 (invalid course)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/hls/cache?courseId=invalid", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/hls/cache", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_GetProfile(t *testing.T) {
+	t.Run("200 (default)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/profile", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData hlsProfileResponse
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.Equal(t, "2160p", respData.MaxQuality)
+		require.Equal(t, uint32(128_000), respData.AudioBitrate)
+		require.Equal(t, 2, respData.AudioChannels)
+		require.Len(t, respData.Rungs, 7)
+		require.False(t, respData.Rungs[6].Enabled)
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/hls/profile", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHls_UpdateProfile(t *testing.T) {
+	t.Run("200 (updated)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		body := `{
+			"rungs": [{"quality": "720p", "enabled": true, "averageBitrate": 3000000, "maxBitrate": 5000000}],
+			"preset": "slow",
+			"crf": 23,
+			"maxQuality": "1080p",
+			"audioBitrate": 192000,
+			"audioChannels": 2,
+			"segmentDuration": 6,
+			"maxHeads": 4,
+			"maxUserHeads": 2,
+			"normalizeLoudness": true,
+			"loudnessTarget": -23
+		}`
+
+		req := httptest.NewRequest(http.MethodPut, "/api/hls/profile", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData hlsProfileResponse
+		require.NoError(t, json.Unmarshal(respBody, &respData))
+		require.Equal(t, "1080p", respData.MaxQuality)
+		require.Len(t, respData.Rungs, 7)
+
+		profile := router.app.Transcoder.Profile()
+		require.Equal(t, []hls.Quality{hls.P720}, profile.Qualities())
+		require.Equal(t, "slow", profile.Preset)
+		require.Equal(t, 6.0, profile.SegmentDuration)
+		require.Equal(t, 4, profile.MaxHeads)
+		require.Equal(t, 2, profile.MaxUserHeads)
+		require.True(t, profile.NormalizeLoudness)
+		require.Equal(t, -23.0, profile.LoudnessTarget)
+	})
+
+	t.Run("400 (invalid)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/hls/profile", strings.NewReader(`{"maxQuality": "4k", "audioBitrate": 128000, "audioChannels": 2}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Invalid profile")
+		require.Equal(t, hls.P2160, router.app.Transcoder.Profile().MaxQuality)
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/hls/profile", strings.NewReader(`{}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, _, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// createSubtitleHelper creates a course with a single video asset and a subtitle for that asset
+func createSubtitleHelper(t *testing.T, router *Router, ctx context.Context, path, format string) (*models.Asset, *models.AssetSubtitle) {
+	t.Helper()
+
+	asset := createVideoHelper(t, router, ctx)
+
+	subtitle := &models.AssetSubtitle{
+		AssetID:  asset.ID,
+		Path:     path,
+		Language: "en",
+		Format:   format,
+	}
+	require.NoError(t, router.appDao.CreateAssetSubtitle(ctx, subtitle))
+
+	return asset, subtitle
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// createVideoHelper creates a course with a single video asset
+func createVideoHelper(t *testing.T, router *Router, ctx context.Context) *models.Asset {
+	t.Helper()
+
+	course := &models.Course{Title: "Course 1", Path: "/course-1"}
+	require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+	lesson := &models.Lesson{
+		CourseID: course.ID,
+		Title:    "Intro",
+		Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+	}
+	require.NoError(t, router.appDao.CreateLesson(ctx, lesson))
+
+	asset := &models.Asset{
+		CourseID: course.ID,
+		LessonID: lesson.ID,
+		Title:    "Intro",
+		Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+		Type:     types.MustAsset("mp4"),
+		Path:     "/course-1/01 - Intro.mp4",
+		FileSize: 1024,
+		ModTime:  time.Now().Format(time.RFC3339Nano),
+		Hash:     security.RandomString(64),
+	}
+	require.NoError(t, router.appDao.CreateAsset(ctx, asset))
+
+	return asset
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const defaultLibraryRootMaxDepth = 2
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type libraryAPI struct {
+	r *Router
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// initLibraryRoutes initializes the library root routes
+func (r *Router) initLibraryRoutes() {
+	libraryAPI := libraryAPI{
+		r: r,
+	}
+
+	g := r.apiGroup("library-roots")
+
+	g.Get("", protectedRoute, libraryAPI.getLibraryRoots)
+	g.Get("/:id", protectedRoute, libraryAPI.getLibraryRoot)
+	g.Post("", protectedRoute, libraryAPI.createLibraryRoot)
+	g.Put("/:id", protectedRoute, libraryAPI.updateLibraryRoot)
+	g.Delete("/:id", protectedRoute, libraryAPI.deleteLibraryRoot)
+	g.Post("/:id/discover", protectedRoute, libraryAPI.discoverLibraryRoot)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func (api libraryAPI) getLibraryRoots(c *fiber.Ctx) error {
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	dbOpts := dao.NewOptions().WithOrderBy(models.LIBRARY_ROOT_TABLE_PATH + " ASC")
+	roots, err := api.r.appDao.ListLibraryRoots(ctx, dbOpts)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up library roots", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(libraryRootResponseHelper(roots))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func (api libraryAPI) getLibraryRoot(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: id})
+	root, err := api.r.appDao.GetLibraryRoot(ctx, dbOpts)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up library root", err)
+	}
+
+	if root == nil {
+		return errorResponse(c, fiber.StatusNotFound, "Library root not found", nil)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(libraryRootResponseHelper([]*models.LibraryRoot{root})[0])
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func (api libraryAPI) createLibraryRoot(c *fiber.Ctx) error {
+	req := &libraryRootRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	if req.Path == "" {
+		return errorResponse(c, fiber.StatusBadRequest, "A path is required", nil)
+	}
+
+	if req.MaxDepth == 0 {
+		req.MaxDepth = defaultLibraryRootMaxDepth
+	}
+
+	root := &models.LibraryRoot{
+		Path:            filepath.Clean(utils.NormalizeWindowsDrive(req.Path)),
+		MaxDepth:        req.MaxDepth,
+		RequireVideo:    req.RequireVideo,
+		RequireNumbered: req.RequireNumbered,
+		FlagMissing:     req.FlagMissing,
+	}
+
+	if root.MaxDepth < 1 {
+		return errorResponse(c, fiber.StatusBadRequest, "Max depth must be greater than zero", nil)
+	}
+
+	// Validate the path
+	if exists, err := afero.DirExists(api.r.app.AppFs.Fs, root.Path); err != nil || !exists {
+		return errorResponse(c, fiber.StatusBadRequest, "Invalid library root path", err)
+	}
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	if err := api.r.appDao.CreateLibraryRoot(ctx, root); err != nil {
+		if strings.HasPrefix(err.Error(), "UNIQUE constraint failed") {
+			return errorResponse(c, fiber.StatusBadRequest, "A library root with this path already exists", err)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, "Error creating library root", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(libraryRootResponseHelper([]*models.LibraryRoot{root})[0])
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// updateLibraryRoot updates the discovery settings of a library root. The path cannot be
+// changed
+func (api libraryAPI) updateLibraryRoot(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	req := &libraryRootRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	if req.MaxDepth < 1 {
+		return errorResponse(c, fiber.StatusBadRequest, "Max depth must be greater than zero", nil)
+	}
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: id})
+	root, err := api.r.appDao.GetLibraryRoot(ctx, dbOpts)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up library root", err)
+	}
+
+	if root == nil {
+		return errorResponse(c, fiber.StatusNotFound, "Library root not found", nil)
+	}
+
+	root.MaxDepth = req.MaxDepth
+	root.RequireVideo = req.RequireVideo
+	root.RequireNumbered = req.RequireNumbered
+	root.FlagMissing = req.FlagMissing
+
+	if err := api.r.appDao.UpdateLibraryRoot(ctx, root); err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error updating library root", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(libraryRootResponseHelper([]*models.LibraryRoot{root})[0])
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// deleteLibraryRoot deletes a library root. Courses discovered under the root are kept
+func (api libraryAPI) deleteLibraryRoot(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: id})
+	if err := api.r.appDao.DeleteLibraryRoots(ctx, dbOpts); err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error deleting library root", err)
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// discoverLibraryRoot runs discovery for a library root immediately, rather than waiting for
+// the scheduled run
+func (api libraryAPI) discoverLibraryRoot(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: id})
+	root, err := api.r.appDao.GetLibraryRoot(ctx, dbOpts)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up library root", err)
+	}
+
+	if root == nil {
+		return errorResponse(c, fiber.StatusNotFound, "Library root not found", nil)
+	}
+
+	result, err := api.r.app.CourseDiscovery.Discover(ctx, root)
+	if err != nil {
+		if errors.Is(err, utils.ErrDiscoveryRunning) {
+			return errorResponse(c, fiber.StatusConflict, "Discovery is already running", err)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, "Error discovering courses", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&libraryDiscoveryResponse{
+		Created: courseResponseHelper(result.Created, true),
+		Queued:  result.Queued,
+		Missing: result.Missing,
+	})
+}
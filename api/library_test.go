@@ -0,0 +1,314 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestLibrary_GetLibraryRoots(t *testing.T) {
+	t.Run("200 (empty)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/library-roots/", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var rootsResp []*libraryRootResponse
+		require.NoError(t, json.Unmarshal(body, &rootsResp))
+		require.Empty(t, rootsResp)
+	})
+
+	t.Run("200 (found)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		for _, path := range []string{"/library-b", "/library-a"} {
+			require.NoError(t, router.appDao.CreateLibraryRoot(ctx, &models.LibraryRoot{Path: path, MaxDepth: 2}))
+		}
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/library-roots/", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var rootsResp []*libraryRootResponse
+		require.NoError(t, json.Unmarshal(body, &rootsResp))
+		require.Len(t, rootsResp, 2)
+		require.Equal(t, "/library-a", rootsResp[0].Path)
+		require.Equal(t, "/library-b", rootsResp[1].Path)
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/library-roots/", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Contains(t, string(body), "User is not an admin")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestLibrary_GetLibraryRoot(t *testing.T) {
+	t.Run("200 (found)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 3}
+		require.NoError(t, router.appDao.CreateLibraryRoot(ctx, root))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/library-roots/"+root.ID, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var rootResp libraryRootResponse
+		require.NoError(t, json.Unmarshal(body, &rootResp))
+		require.Equal(t, root.ID, rootResp.ID)
+		require.Equal(t, 3, rootResp.MaxDepth)
+	})
+
+	t.Run("404 (not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/library-roots/invalid", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Contains(t, string(body), "Library root not found")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestLibrary_CreateLibraryRoot(t *testing.T) {
+	t.Run("201 (created)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		require.NoError(t, router.app.AppFs.Fs.MkdirAll("/library", os.ModePerm))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/library-roots/", strings.NewReader(`{"path": "/library", "requireVideo": true}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, status)
+
+		var rootResp libraryRootResponse
+		require.NoError(t, json.Unmarshal(body, &rootResp))
+		require.NotEmpty(t, rootResp.ID)
+		require.Equal(t, "/library", rootResp.Path)
+		require.Equal(t, defaultLibraryRootMaxDepth, rootResp.MaxDepth)
+		require.True(t, rootResp.RequireVideo)
+		require.False(t, rootResp.RequireNumbered)
+		require.False(t, rootResp.FlagMissing)
+	})
+
+	t.Run("400 (bind error)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/library-roots/", strings.NewReader(`{`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Error parsing data")
+	})
+
+	t.Run("400 (invalid data)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		require.NoError(t, router.app.AppFs.Fs.MkdirAll("/library", os.ModePerm))
+
+		// Missing path
+		req := httptest.NewRequest(http.MethodPost, "/api/library-roots/", strings.NewReader(`{"path": ""}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "A path is required")
+
+		// Invalid max depth
+		req = httptest.NewRequest(http.MethodPost, "/api/library-roots/", strings.NewReader(`{"path": "/library", "maxDepth": -1}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err = requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Max depth must be greater than zero")
+
+		// Invalid path
+		req = httptest.NewRequest(http.MethodPost, "/api/library-roots/", strings.NewReader(`{"path": "/missing"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err = requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Invalid library root path")
+	})
+
+	t.Run("400 (existing path)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		require.NoError(t, router.app.AppFs.Fs.MkdirAll("/library", os.ModePerm))
+		require.NoError(t, router.appDao.CreateLibraryRoot(ctx, &models.LibraryRoot{Path: "/library", MaxDepth: 2}))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/library-roots/", strings.NewReader(`{"path": "/library"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "A library root with this path already exists")
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/library-roots/", strings.NewReader(`{"path": "/library"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Contains(t, string(body), "User is not an admin")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestLibrary_UpdateLibraryRoot(t *testing.T) {
+	t.Run("200 (updated)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, router.appDao.CreateLibraryRoot(ctx, root))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/library-roots/"+root.ID, strings.NewReader(`{"maxDepth": 4, "flagMissing": true}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, _, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		record, err := router.appDao.GetLibraryRoot(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: root.ID}))
+		require.NoError(t, err)
+		require.Equal(t, "/library", record.Path)
+		require.Equal(t, 4, record.MaxDepth)
+		require.True(t, record.FlagMissing)
+	})
+
+	t.Run("400 (invalid max depth)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, router.appDao.CreateLibraryRoot(ctx, root))
+
+		req := httptest.NewRequest(http.MethodPut, "/api/library-roots/"+root.ID, strings.NewReader(`{"maxDepth": 0}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Max depth must be greater than zero")
+	})
+
+	t.Run("404 (not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/library-roots/invalid", strings.NewReader(`{"maxDepth": 2}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Contains(t, string(body), "Library root not found")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestLibrary_DeleteLibraryRoot(t *testing.T) {
+	t.Run("204 (deleted)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, router.appDao.CreateLibraryRoot(ctx, root))
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/library-roots/"+root.ID, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, status)
+
+		record, err := router.appDao.GetLibraryRoot(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: root.ID}))
+		require.NoError(t, err)
+		require.Nil(t, record)
+	})
+
+	t.Run("204 (not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/library-roots/invalid", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, status)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestLibrary_DiscoverLibraryRoot(t *testing.T) {
+	t.Run("200 (discovered)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		for _, file := range []string{"/library/Course A/01 intro.mp4", "/library/Course B/01 Module/01 intro.mp4"} {
+			require.NoError(t, router.app.AppFs.Fs.MkdirAll(filepath.Dir(file), os.ModePerm))
+			require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, file, []byte("video"), 0644))
+		}
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, router.appDao.CreateLibraryRoot(ctx, root))
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/library-roots/"+root.ID+"/discover", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var discoveryResp libraryDiscoveryResponse
+		require.NoError(t, json.Unmarshal(body, &discoveryResp))
+		require.Len(t, discoveryResp.Created, 2)
+		require.Equal(t, 2, discoveryResp.Queued)
+		require.Zero(t, discoveryResp.Missing)
+
+		courses, err := router.appDao.ListCourses(ctx, nil)
+		require.NoError(t, err)
+		require.Len(t, courses, 2)
+	})
+
+	t.Run("404 (not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/library-roots/invalid/discover", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Contains(t, string(body), "Library root not found")
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/library-roots/invalid/discover", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Contains(t, string(body), "User is not an admin")
+	})
+}
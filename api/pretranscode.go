@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/media/hls"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type pretranscodeAPI struct {
+	r *Router
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// initPretranscodeRoutes initializes the pre-transcode queue routes
+func (r *Router) initPretranscodeRoutes() {
+	pretranscodeAPI := pretranscodeAPI{
+		r: r,
+	}
+
+	g := r.apiGroup("pretranscode")
+
+	g.Get("/", protectedRoute, pretranscodeAPI.getQueue)
+	g.Post("/", protectedRoute, pretranscodeAPI.createJobs)
+	g.Post("/pause", protectedRoute, pretranscodeAPI.pauseQueue)
+	g.Post("/resume", protectedRoute, pretranscodeAPI.resumeQueue)
+	g.Put("/window", protectedRoute, pretranscodeAPI.updateWindow)
+	g.Delete("/:id", protectedRoute, pretranscodeAPI.cancelJob)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// getQueue returns the state of the pre-transcode queue, including the progress of each job
+func (api *pretranscodeAPI) getQueue(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(pretranscodeQueueResponseHelper(api.r.app.Pretranscoder.GetQueueStatus()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// createJobs queues the given courses and assets to be transcoded to the given qualities. Each
+// video and audio asset of a course gets its own job
+func (api *pretranscodeAPI) createJobs(c *fiber.Ctx) error {
+	req := &pretranscodeRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	if len(req.CourseIDs) == 0 && len(req.AssetIDs) == 0 {
+		return errorResponse(c, fiber.StatusBadRequest, "A course or asset ID is required", nil)
+	}
+
+	if len(req.Qualities) == 0 {
+		return errorResponse(c, fiber.StatusBadRequest, "At least one quality is required", nil)
+	}
+
+	qualities := make([]hls.Quality, 0, len(req.Qualities))
+	for _, q := range req.Qualities {
+		quality, err := hls.QualityFromString(q)
+		if err != nil {
+			return errorResponse(c, fiber.StatusBadRequest, "Invalid quality", err)
+		}
+
+		qualities = append(qualities, quality)
+	}
+
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	jobs := []hls.PretranscodeJob{}
+
+	for _, courseID := range req.CourseIDs {
+		courseJobs, err := api.r.app.Pretranscoder.AddCourse(ctx, courseID, qualities)
+		if err != nil {
+			if errors.Is(err, utils.ErrCourseNotFound) {
+				return errorResponse(c, fiber.StatusBadRequest, "Invalid course ID", nil)
+			}
+			return errorResponse(c, fiber.StatusInternalServerError, "Error queuing course", err)
+		}
+
+		jobs = append(jobs, courseJobs...)
+	}
+
+	for _, assetID := range req.AssetIDs {
+		job, err := api.r.app.Pretranscoder.AddAsset(ctx, assetID, qualities)
+		if err != nil {
+			switch {
+			case errors.Is(err, hls.ErrAssetNotFound):
+				return errorResponse(c, fiber.StatusBadRequest, "Invalid asset ID", nil)
+			case errors.Is(err, hls.ErrAssetNotPlayable):
+				return errorResponse(c, fiber.StatusBadRequest, "Asset is not a video or audio file", nil)
+			}
+			return errorResponse(c, fiber.StatusInternalServerError, "Error queuing asset", err)
+		}
+
+		jobs = append(jobs, *job)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(pretranscodeJobResponseHelper(jobs))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// pauseQueue stops the processing job and prevents new jobs from starting. The stopped job
+// is queued again
+func (api *pretranscodeAPI) pauseQueue(c *fiber.Ctx) error {
+	api.r.app.Pretranscoder.Pause()
+	return c.Status(fiber.StatusOK).JSON(pretranscodeQueueResponseHelper(api.r.app.Pretranscoder.GetQueueStatus()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// resumeQueue allows jobs to run again
+func (api *pretranscodeAPI) resumeQueue(c *fiber.Ctx) error {
+	api.r.app.Pretranscoder.Resume()
+	return c.Status(fiber.StatusOK).JSON(pretranscodeQueueResponseHelper(api.r.app.Pretranscoder.GetQueueStatus()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// updateWindow changes the time of day during which jobs run. An empty window allows jobs to
+// run at any time
+func (api *pretranscodeAPI) updateWindow(c *fiber.Ctx) error {
+	req := &pretranscodeWindowRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	if err := api.r.app.Pretranscoder.SetWindow(req.Window); err != nil {
+		if errors.Is(err, hls.ErrInvalidTimeWindow) {
+			return errorResponse(c, fiber.StatusBadRequest, "Window must be in the form HH:MM-HH:MM", nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, "Error updating window", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(pretranscodeQueueResponseHelper(api.r.app.Pretranscoder.GetQueueStatus()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// cancelJob removes a job from the queue, stopping it when it is processing
+func (api *pretranscodeAPI) cancelJob(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	if err := api.r.app.Pretranscoder.Cancel(id); err != nil {
+		if errors.Is(err, hls.ErrPretranscodeJobNotFound) {
+			return errorResponse(c, fiber.StatusNotFound, "Job not found", nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, "Error cancelling job", err)
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
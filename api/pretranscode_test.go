@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/geerew/off-course/utils/media/hls"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestPretranscode_GetQueue(t *testing.T) {
+	t.Run("200 (jobs)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		_, err := router.app.Pretranscoder.AddAsset(ctx, asset.ID, []hls.Quality{hls.P720})
+		require.NoError(t, err)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/pretranscode/", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData pretranscodeQueueResponse
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.False(t, respData.Paused)
+		require.Equal(t, "", respData.Window)
+		require.True(t, respData.InWindow)
+		require.Len(t, respData.Jobs, 1)
+		require.Equal(t, asset.ID, respData.Jobs[0].AssetID)
+		require.Equal(t, hls.PretranscodeWaiting, respData.Jobs[0].Status)
+		require.Equal(t, []hls.Quality{hls.P720}, respData.Jobs[0].Qualities)
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/pretranscode/", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Equal(t, `{"message":"User is not an admin"}`, string(body))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestPretranscode_CreateJobs(t *testing.T) {
+	t.Run("201 (created)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		body := fmt.Sprintf(`{"courseIds": ["%s"], "assetIds": ["%s"], "qualities": ["original", "720p"]}`, asset.CourseID, asset.ID)
+		req := httptest.NewRequest(http.MethodPost, "/api/pretranscode/", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, respBody, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, status)
+
+		var respData []pretranscodeJobResponse
+		require.NoError(t, json.Unmarshal(respBody, &respData))
+		require.Len(t, respData, 2)
+
+		// The asset is queued once, through its course
+		require.Equal(t, respData[0].ID, respData[1].ID)
+		require.Len(t, router.app.Pretranscoder.GetQueueStatus().Jobs, 1)
+	})
+
+	t.Run("400 (invalid)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		asset := createVideoHelper(t, router, ctx)
+
+		tests := []struct {
+			body    string
+			message string
+		}{
+			{`{"qualities": ["original"]}`, "A course or asset ID is required"},
+			{fmt.Sprintf(`{"assetIds": ["%s"]}`, asset.ID), "At least one quality is required"},
+			{fmt.Sprintf(`{"assetIds": ["%s"], "qualities": ["4k"]}`, asset.ID), "Invalid quality"},
+			{`{"assetIds": ["missing"], "qualities": ["original"]}`, "Invalid asset ID"},
+			{`{"courseIds": ["missing"], "qualities": ["original"]}`, "Invalid course ID"},
+		}
+
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodPost, "/api/pretranscode/", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+			status, body, err := requestHelper(t, router, req)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, status, tt.body)
+			require.Contains(t, string(body), tt.message)
+		}
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestPretranscode_PauseResume(t *testing.T) {
+	router, _ := setupAdmin(t)
+
+	status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/pretranscode/pause", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+
+	var respData pretranscodeQueueResponse
+	require.NoError(t, json.Unmarshal(body, &respData))
+	require.True(t, respData.Paused)
+	require.True(t, router.app.Pretranscoder.IsPaused())
+
+	status, body, err = requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/pretranscode/resume", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+
+	require.NoError(t, json.Unmarshal(body, &respData))
+	require.False(t, respData.Paused)
+	require.False(t, router.app.Pretranscoder.IsPaused())
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestPretranscode_UpdateWindow(t *testing.T) {
+	t.Run("200 (updated)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/pretranscode/window", strings.NewReader(`{"window": "23:00-06:00"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData pretranscodeQueueResponse
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.Equal(t, "23:00-06:00", respData.Window)
+
+		// An empty window removes it
+		req = httptest.NewRequest(http.MethodPut, "/api/pretranscode/window", strings.NewReader(`{"window": ""}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err = requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.Equal(t, "", respData.Window)
+		require.True(t, respData.InWindow)
+	})
+
+	t.Run("400 (invalid)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/pretranscode/window", strings.NewReader(`{"window": "nightly"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Window must be in the form HH:MM-HH:MM")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestPretranscode_CancelJob(t *testing.T) {
+	t.Run("204 (cancelled)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		job, err := router.app.Pretranscoder.AddAsset(ctx, asset.ID, []hls.Quality{hls.Original})
+		require.NoError(t, err)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/pretranscode/"+job.ID, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, status)
+		require.Empty(t, router.app.Pretranscoder.GetQueueStatus().Jobs)
+	})
+
+	t.Run("404 (not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/pretranscode/missing", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+}
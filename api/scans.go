@@ -4,10 +4,16 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
 	"github.com/geerew/off-course/utils"
 	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/queryparser"
 	"github.com/geerew/off-course/utils/types"
 	"github.com/gofiber/fiber/v2"
 	"github.com/valyala/fasthttp"
@@ -31,8 +37,14 @@ func (r *Router) initScanRoutes() {
 
 	g.Get("/", protectedRoute, scansAPI.getScans)
 	g.Get("/stream", protectedRoute, scansAPI.streamScans)
+	g.Get("/history", protectedRoute, scansAPI.getScanHistory)
+	g.Get("/queue", protectedRoute, scansAPI.getScanQueue)
+	g.Post("/queue/pause", protectedRoute, scansAPI.pauseScanQueue)
+	g.Post("/queue/resume", protectedRoute, scansAPI.resumeScanQueue)
+	g.Delete("/queue", protectedRoute, scansAPI.cancelWaitingScans)
 	g.Get("/:courseId", protectedRoute, scansAPI.getScan)
 	g.Post("", protectedRoute, scansAPI.createScan)
+	g.Put("/:id/position", protectedRoute, scansAPI.moveScan)
 	g.Delete("/:id", protectedRoute, scansAPI.deleteScan)
 }
 
@@ -71,6 +83,43 @@ func (api *scansAPI) getScan(c *fiber.Ctx) error {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// getScanHistory lists finished scans across all courses
+func (api *scansAPI) getScanHistory(c *fiber.Ctx) error {
+	builderOpts := builderOptions{
+		DefaultOrderBy: defaultScanHistoryOrderBy,
+		Paginate:       true,
+		AllowedFilters: scanHistoryFilters,
+		AfterParseHook: scanHistoryAfterParseHook,
+	}
+
+	principal, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	dbOpts, err := optionsBuilder(c, builderOpts, principal.UserID)
+	if err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing query", err)
+	}
+
+	histories, err := api.r.appDao.ListScanHistory(ctx, dbOpts)
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error looking up scan history", err)
+	}
+
+	pResult, err := dbOpts.Pagination.BuildResult(scanHistoryResponseHelper(histories))
+	if err != nil {
+		return errorResponse(c, fiber.StatusInternalServerError, "Error building pagination result", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(pResult)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// createScan queues a scan for a course. When the `dryRun` query param is set, the course is
+// scanned and reconciled immediately and the resulting operations are returned without being
+// applied
 func (api *scansAPI) createScan(c *fiber.Ctx) error {
 	req := &ScanRequest{}
 	if err := c.BodyParser(req); err != nil {
@@ -86,7 +135,11 @@ func (api *scansAPI) createScan(c *fiber.Ctx) error {
 		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
 	}
 
-	scanState, err := api.r.app.CourseScan.Add(ctx, req.CourseID)
+	if c.QueryBool("dryRun") {
+		return api.dryRunScan(c, ctx, req.CourseID)
+	}
+
+	scanState, err := api.r.app.CourseScan.AddWithPriority(ctx, req.CourseID, coursescan.ScanPriorityHigh)
 	if err != nil {
 		if err == utils.ErrCourseNotFound {
 			return errorResponse(c, fiber.StatusBadRequest, "Invalid course ID", nil)
@@ -99,6 +152,34 @@ func (api *scansAPI) createScan(c *fiber.Ctx) error {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// dryRunScan scans a course and returns the operations a scan would apply
+func (api *scansAPI) dryRunScan(c *fiber.Ctx, ctx context.Context, courseID string) error {
+	plan, err := api.r.app.CourseScan.DryRun(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, utils.ErrCourseNotFound) {
+			return errorResponse(c, fiber.StatusBadRequest, "Invalid course ID", nil)
+		}
+
+		if errors.Is(err, coursescan.ErrCourseUnavailable) {
+			return errorResponse(c, fiber.StatusBadRequest, "Course path does not exist", nil)
+		}
+
+		if errors.Is(err, coursescan.ErrInvalidFilenameRule) {
+			return errorResponse(c, fiber.StatusBadRequest, "Invalid filename rule in course.json", err)
+		}
+
+		if errors.Is(err, coursescan.ErrCourseMetadata) {
+			return errorResponse(c, fiber.StatusBadRequest, "Invalid course metadata", err)
+		}
+
+		return errorResponse(c, fiber.StatusInternalServerError, "Error running dry-run scan", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(plan)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func (api scansAPI) deleteScan(c *fiber.Ctx) error {
 	id := c.Params("id")
 
@@ -117,6 +198,71 @@ func (api scansAPI) deleteScan(c *fiber.Ctx) error {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// getScanQueue returns the state of the scan queue
+func (api *scansAPI) getScanQueue(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(scanQueueResponseHelper(api.r.app.CourseScan.GetQueueStatus()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// pauseScanQueue stops new scans from starting. Scans that are already processing are not
+// affected
+func (api *scansAPI) pauseScanQueue(c *fiber.Ctx) error {
+	api.r.app.CourseScan.Pause()
+	return c.Status(fiber.StatusOK).JSON(scanQueueResponseHelper(api.r.app.CourseScan.GetQueueStatus()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// resumeScanQueue allows waiting scans to start again
+func (api *scansAPI) resumeScanQueue(c *fiber.Ctx) error {
+	api.r.app.CourseScan.Resume()
+	return c.Status(fiber.StatusOK).JSON(scanQueueResponseHelper(api.r.app.CourseScan.GetQueueStatus()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// cancelWaitingScans cancels all scans that have not started processing
+func (api *scansAPI) cancelWaitingScans(c *fiber.Ctx) error {
+	cancelled := api.r.app.CourseScan.CancelWaitingScans()
+	return c.Status(fiber.StatusOK).JSON(&scanQueueCancelResponse{Cancelled: cancelled})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// moveScan moves a waiting scan to a new position in the queue, where 0 is the next scan to
+// be processed. The updated list of scans is returned
+func (api *scansAPI) moveScan(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	req := &scanMoveRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, "Error parsing data", err)
+	}
+
+	principal, _, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	if err := api.r.app.CourseScan.MoveScan(id, req.Position); err != nil {
+		switch {
+		case errors.Is(err, coursescan.ErrScanNotFound):
+			return errorResponse(c, fiber.StatusNotFound, "Scan not found", nil)
+		case errors.Is(err, coursescan.ErrScanNotWaiting):
+			return errorResponse(c, fiber.StatusBadRequest, "Only waiting scans can be moved", nil)
+		case errors.Is(err, coursescan.ErrInvalidQueuePosition):
+			return errorResponse(c, fiber.StatusBadRequest, "Position cannot be negative", nil)
+		}
+		return errorResponse(c, fiber.StatusInternalServerError, "Error moving scan", err)
+	}
+
+	scanStates := api.r.app.CourseScan.GetAllScans()
+	return c.Status(fiber.StatusOK).JSON(scanResponseHelper(scanStates, principal.Role == types.UserRoleAdmin))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // streamScans streams scan updates using Server-Sent Events (SSE)
 func (api *scansAPI) streamScans(c *fiber.Ctx) error {
 	principal, ctx, err := principalCtx(c)
@@ -230,3 +376,77 @@ func (api *scansAPI) streamScans(c *fiber.Ctx) error {
 
 	return nil
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// scanHistoryFilters are the filters supported when querying the scan history
+var scanHistoryFilters = []string{"status", "course", "date", "after", "before"}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// scanHistoryAfterParseHook builds the dao.Options.Where based on the query expression
+func scanHistoryAfterParseHook(parsed *queryparser.QueryResult, options *dao.Options, _ string) {
+	options.Where = scanHistoryWhereBuilder(parsed.Expr)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// scanHistoryWhereBuilder builds a squirrel.Sqlizer, for use in a WHERE clause, based on a query
+// expression
+//
+// Dates are in the form YYYY-MM-DD and are matched against the time the scan started. `after` and
+// `before` are inclusive
+func scanHistoryWhereBuilder(expr queryparser.QueryExpr) squirrel.Sqlizer {
+	switch node := expr.(type) {
+	case *queryparser.ValueExpr:
+		return squirrel.Like{models.COURSE_TABLE_TITLE: "%" + node.Value + "%"}
+	case *queryparser.FilterExpr:
+		switch node.Key {
+		case "status":
+			return squirrel.Eq{models.SCAN_HISTORY_TABLE_STATUS: strings.ToLower(node.Value)}
+		case "course":
+			return squirrel.Or{
+				squirrel.Eq{models.SCAN_HISTORY_TABLE_COURSE_ID: node.Value},
+				squirrel.Like{models.COURSE_TABLE_TITLE: "%" + node.Value + "%"},
+			}
+		case "date", "after", "before":
+			day, err := time.ParseInLocation(time.DateOnly, node.Value, time.UTC)
+			if err != nil {
+				return squirrel.Expr("1=0")
+			}
+
+			start := types.DateTime(day).String()
+			end := types.DateTime(day.AddDate(0, 0, 1)).String()
+
+			switch node.Key {
+			case "after":
+				return squirrel.GtOrEq{models.SCAN_HISTORY_TABLE_STARTED_AT: start}
+			case "before":
+				return squirrel.Lt{models.SCAN_HISTORY_TABLE_STARTED_AT: end}
+			default:
+				return squirrel.And{
+					squirrel.GtOrEq{models.SCAN_HISTORY_TABLE_STARTED_AT: start},
+					squirrel.Lt{models.SCAN_HISTORY_TABLE_STARTED_AT: end},
+				}
+			}
+		default:
+			return nil
+		}
+	case *queryparser.AndExpr:
+		var andSlice []squirrel.Sqlizer
+		for _, child := range node.Children {
+			andSlice = append(andSlice, scanHistoryWhereBuilder(child))
+		}
+
+		return squirrel.And(andSlice)
+	case *queryparser.OrExpr:
+		var orSlice []squirrel.Sqlizer
+		for _, child := range node.Children {
+			orSlice = append(orSlice, scanHistoryWhereBuilder(child))
+		}
+
+		return squirrel.Or(orSlice)
+	default:
+		return nil
+	}
+}
@@ -5,11 +5,17 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/types"
 	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
@@ -107,6 +113,85 @@ func TestScans_GetScan(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func TestScans_GetScanHistory(t *testing.T) {
+	t.Run("200 (empty)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/scans/history", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		paginationResp, _ := unmarshalHelper[scanHistoryResponse](t, body)
+		require.Zero(t, int(paginationResp.TotalItems))
+	})
+
+	t.Run("200 (filters)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course1 := &models.Course{Title: "Go Course", Path: "/course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course1))
+
+		course2 := &models.Course{Title: "Rust Course", Path: "/course 2"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course2))
+
+		day := func(d string) types.DateTime {
+			parsed, err := time.Parse(time.DateOnly, d)
+			require.NoError(t, err)
+			return types.DateTime(parsed.Add(12 * time.Hour))
+		}
+
+		histories := []*models.ScanHistory{
+			{CourseID: course1.ID, Status: types.ScanStatusCompleted, StartedAt: day("2024-01-01")},
+			{CourseID: course1.ID, Status: types.ScanStatusFailed, Error: "boom", StartedAt: day("2024-01-02")},
+			{CourseID: course2.ID, Status: types.ScanStatusCompleted, StartedAt: day("2024-01-03")},
+		}
+		for _, history := range histories {
+			require.NoError(t, router.appDao.CreateScanHistory(ctx, history))
+		}
+
+		tests := []struct {
+			query    string
+			expected []string
+		}{
+			{"", []string{histories[2].ID, histories[1].ID, histories[0].ID}},
+			{"status:failed", []string{histories[1].ID}},
+			{"status:completed", []string{histories[2].ID, histories[0].ID}},
+			{"course:" + course2.ID, []string{histories[2].ID}},
+			{"course:go", []string{histories[1].ID, histories[0].ID}},
+			{"rust", []string{histories[2].ID}},
+			{"date:2024-01-02", []string{histories[1].ID}},
+			{"after:2024-01-02", []string{histories[2].ID, histories[1].ID}},
+			{"before:2024-01-02", []string{histories[1].ID, histories[0].ID}},
+			{"status:completed AND after:2024-01-02", []string{histories[2].ID}},
+			{"date:invalid", []string{}},
+		}
+
+		for _, tt := range tests {
+			status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/scans/history?q="+url.QueryEscape(tt.query), nil))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, status, tt.query)
+
+			_, historyResp := unmarshalHelper[scanHistoryResponse](t, body)
+			ids := []string{}
+			for _, h := range historyResp {
+				ids = append(ids, h.ID)
+			}
+			require.Equal(t, tt.expected, ids, tt.query)
+		}
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/scans/history", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Equal(t, `{"message":"User is not an admin"}`, string(body))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func TestScans_CreateScan(t *testing.T) {
 	t.Run("201 (created)", func(t *testing.T) {
 		router, ctx := setupAdmin(t)
@@ -125,6 +210,103 @@ func TestScans_CreateScan(t *testing.T) {
 		err = json.Unmarshal(body, &respData)
 		require.NoError(t, err)
 		require.Equal(t, course.ID, respData.CourseID)
+		require.Equal(t, "high", respData.Priority)
+	})
+
+	t.Run("200 (dry run)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/Course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		require.NoError(t, router.app.AppFs.Fs.MkdirAll(course.Path, os.ModePerm))
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, course.Path+"/01 file 1.mkv", []byte("hash 1"), os.ModePerm))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/scans/?dryRun=true", strings.NewReader(fmt.Sprintf(`{"courseID": "%s"}`, course.ID)))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData coursescan.DryRunPlan
+		err = json.Unmarshal(body, &respData)
+		require.NoError(t, err)
+		require.Equal(t, course.ID, respData.CourseID)
+		require.Len(t, respData.Lessons, 1)
+		require.Equal(t, coursescan.CreateOp, respData.Lessons[0].Op)
+		require.Equal(t, 1, respData.Summary.AssetsCreated)
+
+		// Nothing was queued or created
+		require.Nil(t, router.app.CourseScan.GetScanByCourseID(course.ID))
+
+		lessons, err := router.appDao.ListLessons(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, lessons)
+	})
+
+	t.Run("400 (dry run invalid course id)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/scans/?dryRun=true", strings.NewReader(`{"courseID": "test"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Invalid course ID")
+	})
+
+	t.Run("400 (dry run course unavailable)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/Course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/scans/?dryRun=true", strings.NewReader(fmt.Sprintf(`{"courseID": "%s"}`, course.ID)))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Course path does not exist")
+	})
+
+	t.Run("400 (dry run invalid filename rule)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/Course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		require.NoError(t, router.app.AppFs.Fs.MkdirAll(course.Path, os.ModePerm))
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, course.Path+"/course.json", []byte(`{"filenameRules": [{"type": "unknown"}]}`), os.ModePerm))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/scans/?dryRun=true", strings.NewReader(fmt.Sprintf(`{"courseID": "%s"}`, course.ID)))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Invalid filename rule in course.json")
+		require.Contains(t, string(body), `unknown type \"unknown\"`)
+	})
+
+	t.Run("400 (dry run invalid course.json)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/Course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		require.NoError(t, router.app.AppFs.Fs.MkdirAll(course.Path, os.ModePerm))
+		require.NoError(t, afero.WriteFile(router.app.AppFs.Fs, course.Path+"/course.json", []byte(`{`), os.ModePerm))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/scans/?dryRun=true", strings.NewReader(fmt.Sprintf(`{"courseID": "%s"}`, course.ID)))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Invalid course metadata")
 	})
 
 	t.Run("400 (bind error)", func(t *testing.T) {
@@ -173,3 +355,162 @@ func TestScans_CreateScan(t *testing.T) {
 	})
 
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScans_Queue(t *testing.T) {
+	t.Run("200 (status)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		for i := range 3 {
+			course := &models.Course{Title: fmt.Sprintf("course %d", i), Path: fmt.Sprintf("/course %d", i)}
+			require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+			_, err := router.app.CourseScan.Add(ctx, course.ID)
+			require.NoError(t, err)
+		}
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/scans/queue", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData scanQueueResponse
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.False(t, respData.Paused)
+		require.Equal(t, 1, respData.Workers)
+		require.Equal(t, 3, respData.Waiting)
+		require.Zero(t, respData.Processing)
+	})
+
+	t.Run("200 (pause and resume)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/scans/queue/pause", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData scanQueueResponse
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.True(t, respData.Paused)
+		require.True(t, router.app.CourseScan.IsPaused())
+
+		status, body, err = requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/scans/queue/resume", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.False(t, respData.Paused)
+		require.False(t, router.app.CourseScan.IsPaused())
+	})
+
+	t.Run("200 (cancel waiting)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		for i := range 3 {
+			course := &models.Course{Title: fmt.Sprintf("course %d", i), Path: fmt.Sprintf("/course %d", i)}
+			require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+			_, err := router.app.CourseScan.Add(ctx, course.ID)
+			require.NoError(t, err)
+		}
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/scans/queue", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData scanQueueCancelResponse
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.Equal(t, 3, respData.Cancelled)
+		require.Empty(t, router.app.CourseScan.GetAllScans())
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodPost, "/api/scans/queue/pause", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Equal(t, `{"message":"User is not an admin"}`, string(body))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScans_MoveScan(t *testing.T) {
+	t.Run("200 (moved)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		scanIDs := []string{}
+		for i := range 3 {
+			course := &models.Course{Title: fmt.Sprintf("course %d", i), Path: fmt.Sprintf("/course %d", i)}
+			require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+			scan, err := router.app.CourseScan.Add(ctx, course.ID)
+			require.NoError(t, err)
+			scanIDs = append(scanIDs, scan.ID)
+		}
+
+		req := httptest.NewRequest(http.MethodPut, "/api/scans/"+scanIDs[2]+"/position", strings.NewReader(`{"position": 0}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var scansResp []scanResponse
+		require.NoError(t, json.Unmarshal(body, &scansResp))
+		require.Len(t, scansResp, 3)
+		require.Equal(t, scanIDs[2], scansResp[0].ID)
+		require.Equal(t, scanIDs[0], scansResp[1].ID)
+		require.Equal(t, scanIDs[1], scansResp[2].ID)
+	})
+
+	t.Run("400 (negative position)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/Course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		scan, err := router.app.CourseScan.Add(ctx, course.ID)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/scans/"+scan.ID+"/position", strings.NewReader(`{"position": -1}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Position cannot be negative")
+	})
+
+	t.Run("400 (not waiting)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/Course 1"}
+		require.NoError(t, router.appDao.CreateCourse(ctx, course))
+
+		scan, err := router.app.CourseScan.Add(ctx, course.ID)
+		require.NoError(t, err)
+		scan.UpdateStatusAndMessage(types.ScanStatusProcessing, "")
+
+		req := httptest.NewRequest(http.MethodPut, "/api/scans/"+scan.ID+"/position", strings.NewReader(`{"position": 0}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, string(body), "Only waiting scans can be moved")
+	})
+
+	t.Run("404 (not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/scans/test/position", strings.NewReader(`{"position": 0}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		status, body, err := requestHelper(t, router, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Contains(t, string(body), "Scan not found")
+	})
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/Masterminds/squirrel"
 	"github.com/geerew/off-course/dao"
 	"github.com/geerew/off-course/models"
-	"github.com/geerew/off-course/utils/coursemetadata"
 	"github.com/geerew/off-course/utils/queryparser"
 	"github.com/gofiber/fiber/v2"
 )
@@ -258,10 +257,7 @@ func (api *tagsAPI) deleteTag(c *fiber.Ctx) error {
 		}
 
 		// Update course.json file with actual remaining tags
-		metadata := &coursemetadata.CourseMetadata{
-			Tags: remainingTags,
-		}
-		api.r.app.MetadataWriter.WriteMetadataAsync(courseID, coursePath, metadata)
+		api.r.app.MetadataWriter.WriteTagsAsync(courseID, coursePath, remainingTags)
 	}
 
 	return c.Status(fiber.StatusNoContent).Send(nil)
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type transcodesAPI struct {
+	r *Router
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// initTranscodeRoutes initializes the routes of the active transcoding sessions
+func (r *Router) initTranscodeRoutes() {
+	transcodesAPI := transcodesAPI{
+		r: r,
+	}
+
+	g := r.apiGroup("admin")
+
+	g.Get("/transcodes", protectedRoute, transcodesAPI.getTranscodes)
+	g.Delete("/transcodes/:asset_id", protectedRoute, transcodesAPI.killTranscode)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// getTranscodes returns the assets being transcoded on demand, with the users, courses and
+// encoders of each
+func (api *transcodesAPI) getTranscodes(c *fiber.Ctx) error {
+	_, ctx, err := principalCtx(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusUnauthorized, "Missing principal", nil)
+	}
+
+	sessions := api.r.app.Transcoder.Sessions()
+
+	assetIDs := []string{}
+	userIDs := []string{}
+	for _, session := range sessions {
+		assetIDs = append(assetIDs, session.AssetID)
+		userIDs = append(userIDs, session.Users...)
+	}
+
+	assets := map[string]*models.Asset{}
+	courses := map[string]*models.Course{}
+	users := map[string]*models.User{}
+
+	if len(assetIDs) > 0 {
+		assetList, err := api.r.appDao.ListAssets(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_ID: assetIDs}))
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error looking up assets", err)
+		}
+
+		courseIDs := []string{}
+		for _, asset := range assetList {
+			assets[asset.ID] = asset
+			courseIDs = append(courseIDs, asset.CourseID)
+		}
+
+		courseList, err := api.r.appDao.ListCourses(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseIDs}))
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error looking up courses", err)
+		}
+
+		for _, course := range courseList {
+			courses[course.ID] = course
+		}
+	}
+
+	if len(userIDs) > 0 {
+		userList, err := api.r.appDao.ListUsers(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.USER_TABLE_ID: userIDs}))
+		if err != nil {
+			return errorResponse(c, fiber.StatusInternalServerError, "Error looking up users", err)
+		}
+
+		for _, user := range userList {
+			users[user.ID] = user
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(transcodeSessionResponseHelper(sessions, assets, courses, users))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// killTranscode stops the encoders of an asset and removes its transcoded segments
+func (api *transcodesAPI) killTranscode(c *fiber.Ctx) error {
+	assetID := c.Params("asset_id")
+
+	if !api.r.app.Transcoder.KillSession(assetID) {
+		return errorResponse(c, fiber.StatusNotFound, "Transcode not found", nil)
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
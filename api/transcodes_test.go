@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestTranscodes_GetTranscodes(t *testing.T) {
+	t.Run("200 (empty)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		// Open streams without running encoders are not listed
+		asset := createVideoHelper(t, router, ctx)
+		_, err := router.app.Transcoder.GetQualities(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/admin/transcodes", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		var respData []*transcodeSessionResponse
+		require.NoError(t, json.Unmarshal(body, &respData))
+		require.Empty(t, respData)
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, body, err := requestHelper(t, router, httptest.NewRequest(http.MethodGet, "/api/admin/transcodes", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Equal(t, `{"message":"User is not an admin"}`, string(body))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestTranscodes_KillTranscode(t *testing.T) {
+	t.Run("204 (killed)", func(t *testing.T) {
+		router, ctx := setupAdmin(t)
+
+		asset := createVideoHelper(t, router, ctx)
+		_, err := router.app.Transcoder.GetQualities(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/admin/transcodes/"+asset.ID, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, status)
+
+		// The streams are gone
+		status, _, err = requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/admin/transcodes/"+asset.ID, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("404 (not found)", func(t *testing.T) {
+		router, _ := setupAdmin(t)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/admin/transcodes/missing", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("403 (not admin)", func(t *testing.T) {
+		router, _ := setupUser(t)
+
+		status, _, err := requestHelper(t, router, httptest.NewRequest(http.MethodDelete, "/api/admin/transcodes/missing", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, status)
+	})
+}
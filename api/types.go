@@ -1,11 +1,16 @@
 package api
 
 import (
+	"fmt"
+	"net/url"
+	"path"
 	"sort"
 	"strings"
 
 	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/coursemove"
 	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/media/hls"
 	"github.com/geerew/off-course/utils/types"
 )
 
@@ -40,6 +45,77 @@ type courseRequest struct {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+type courseCardRequest struct {
+	// The video to grab the frame from. Defaults to the first video in the course
+	AssetID string `json:"assetId"`
+
+	// The position of the frame, in seconds. When not set, a frame is picked automatically
+	Timestamp *int `json:"timestamp"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type courseMoveRequest struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type coursesMoveRequest struct {
+	IDs  []string `json:"ids"`
+	Root string   `json:"root"`
+	Mode string   `json:"mode"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type courseMoveResponse struct {
+	CourseID string `json:"courseId"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Mode     string `json:"mode"`
+	Copied   bool   `json:"copied"`
+	Verified int    `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func courseMoveResponseHelper(results []*coursemove.Result) []*courseMoveResponse {
+	responses := []*courseMoveResponse{}
+
+	for _, result := range results {
+		response := &courseMoveResponse{
+			CourseID: result.CourseID,
+			From:     result.From,
+			To:       result.To,
+			Mode:     string(result.Mode),
+			Copied:   result.Copied,
+			Verified: result.Verified,
+		}
+
+		if result.Err != nil {
+			response.Error = courseMoveErrorMessage(result.Err)
+		}
+
+		responses = append(responses, response)
+	}
+
+	return responses
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type courseMoveProgressResponse struct {
+	CourseID string `json:"courseId"`
+	Stage    string `json:"stage"`
+	Done     int64  `json:"done"`
+	Total    int64  `json:"total"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 type courseProgressResponse struct {
 	Started     bool           `json:"started"`
 	StartedAt   types.DateTime `json:"startedAt"`
@@ -55,13 +131,25 @@ type courseResponse struct {
 	Path        string         `json:"path,omitempty"`
 	HasCard     bool           `json:"hasCard"`
 	CardHash    string         `json:"cardHash,omitempty"`
+	CardSource  string         `json:"cardSource,omitempty"`
 	Available   bool           `json:"available"`
 	Duration    int            `json:"duration"`
 	InitialScan *bool          `json:"initialScan,omitempty"`
+	Watch       *bool          `json:"watch,omitempty"`
+	Missing     *bool          `json:"missing,omitempty"`
 	Maintenance bool           `json:"maintenance"`
 	CreatedAt   types.DateTime `json:"createdAt"`
 	UpdatedAt   types.DateTime `json:"updatedAt"`
 
+	// Details from course.json and sidecar files
+	Description string                 `json:"description,omitempty"`
+	Authors     []string               `json:"authors,omitempty"`
+	Language    string                 `json:"language,omitempty"`
+	Difficulty  string                 `json:"difficulty,omitempty"`
+	ReleaseDate string                 `json:"releaseDate,omitempty"`
+	SourceURL   string                 `json:"sourceUrl,omitempty"`
+	Modules     []courseModuleResponse `json:"modules,omitempty"`
+
 	// Scan status
 	ScanStatus string `json:"scanStatus,omitempty"`
 
@@ -70,6 +158,9 @@ type courseResponse struct {
 
 	// Favourite
 	Favourited bool `json:"favourited,omitempty"`
+
+	// Settings, only set for admins
+	NormalizeAudio *bool `json:"normalizeAudio,omitempty"`
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -90,8 +181,10 @@ func courseResponseHelper(courses []*models.Course, isAdmin bool) []*courseRespo
 		}
 
 		// Get first 12 chars. The frontend can use this to cache bust the card image
+		hasCard := course.CardPath != "" || course.CardSource != types.CardSourceNone
+
 		cardHash := ""
-		if course.CardPath == "" {
+		if !hasCard {
 			cardHash = "fallback"
 		} else if course.CardHash != "" && len(course.CardHash) >= 12 {
 			cardHash = course.CardHash[:12]
@@ -102,14 +195,24 @@ func courseResponseHelper(courses []*models.Course, isAdmin bool) []*courseRespo
 		response := &courseResponse{
 			ID:          course.ID,
 			Title:       course.Title,
-			HasCard:     course.CardPath != "",
+			HasCard:     hasCard,
 			CardHash:    cardHash,
+			CardSource:  string(course.CardSource),
 			Available:   course.Available,
 			Duration:    course.Duration,
 			Maintenance: course.Maintenance,
 			CreatedAt:   course.CreatedAt,
 			UpdatedAt:   course.UpdatedAt,
 
+			// Details
+			Description: course.Description,
+			Authors:     course.Authors,
+			Language:    course.Language,
+			Difficulty:  course.Difficulty,
+			ReleaseDate: course.ReleaseDate,
+			SourceURL:   course.SourceURL,
+			Modules:     courseModuleResponseHelper(course.Modules),
+
 			// Progress
 			Progress: progress,
 
@@ -120,6 +223,9 @@ func courseResponseHelper(courses []*models.Course, isAdmin bool) []*courseRespo
 		if isAdmin {
 			response.Path = course.Path
 			response.InitialScan = &course.InitialScan
+			response.Watch = &course.Watch
+			response.Missing = &course.Missing
+			response.NormalizeAudio = &course.NormalizeAudio
 		}
 
 		responses = append(responses, response)
@@ -128,6 +234,64 @@ func courseResponseHelper(courses []*models.Course, isAdmin bool) []*courseRespo
 	return responses
 }
 
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Course Metadata
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type courseModuleRequest struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	Order *int   `json:"order"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type courseMetadataRequest struct {
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	Authors     []string              `json:"authors"`
+	Language    string                `json:"language"`
+	Difficulty  string                `json:"difficulty"`
+	ReleaseDate string                `json:"releaseDate"`
+	SourceURL   string                `json:"sourceUrl"`
+	Modules     []courseModuleRequest `json:"modules"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Course Settings
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type courseSettingsRequest struct {
+	NormalizeAudio bool `json:"normalizeAudio"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type courseModuleResponse struct {
+	Name  string `json:"name"`
+	Title string `json:"title,omitempty"`
+	Order *int   `json:"order,omitempty"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func courseModuleResponseHelper(modules models.CourseModules) []courseModuleResponse {
+	if len(modules) == 0 {
+		return nil
+	}
+
+	responses := make([]courseModuleResponse, 0, len(modules))
+	for _, module := range modules {
+		responses = append(responses, courseModuleResponse{
+			Name:  module.Name,
+			Title: module.Title,
+			Order: module.Order,
+		})
+	}
+
+	return responses
+}
+
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 // Course Tag
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -195,10 +359,8 @@ func lessonResponseHelper(lessons []*models.Lesson) []*lessonResponse {
 
 		// Counts + Duration
 		for _, a := range lesson.Assets {
-			// Set the total duration
-			if a.AssetMetadata != nil && a.AssetMetadata.VideoMetadata != nil {
-				response.TotalVideoDuration += a.AssetMetadata.VideoMetadata.DurationSec
-			}
+			// Set the total duration (video and audio)
+			response.TotalVideoDuration += a.AssetMetadata.DurationSec()
 
 			// Set the number of completed assets and whether the lesson has started
 			if a.Progress != nil {
@@ -226,9 +388,23 @@ func lessonResponseHelper(lessons []*models.Lesson) []*lessonResponse {
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 type moduleResponse struct {
-	Prefix  int              `json:"prefix"`
-	Module  string           `json:"module"`
-	Lessons []lessonResponse `json:"lessons"`
+	ID       string           `json:"id,omitempty"`
+	ParentID string           `json:"parentId,omitempty"`
+	Prefix   int              `json:"prefix"`
+	Module   string           `json:"module"`
+	Title    string           `json:"title,omitempty"`
+	Depth    int              `json:"depth"`
+	Lessons  []lessonResponse `json:"lessons"`
+	Modules  []moduleResponse `json:"modules,omitempty"`
+
+	// Generated during the response helper, rolled up from the lessons of this module and
+	// its child modules
+	LessonsCount     int  `json:"lessonsCount"`
+	LessonsCompleted int  `json:"lessonsCompleted"`
+	Percent          int  `json:"percent"`
+	DurationSec      int  `json:"durationSec"`
+	Started          bool `json:"started"`
+	Completed        bool `json:"completed"`
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -238,42 +414,153 @@ type modulesResponse struct {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-func modulesResponseHelper(lessons []*models.Lesson) modulesResponse {
+// moduleNode is a module being built by modulesResponseHelper
+type moduleNode struct {
+	path     string
+	record   *models.Module
+	index    int
+	lessons  []lessonResponse
+	children []*moduleNode
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// modulesResponseHelper builds the module tree for the lessons. A lesson module is a path, such
+// as `01 Part One/02 Setup`, and every directory in the path becomes a module. The module
+// records from the scanner provide the prefix and title of each module, while the titles and
+// ordering overrides from the course take precedence. Siblings with an override order are
+// placed first, followed by those with a prefix and then in the order they were found
+func modulesResponseHelper(lessons []*models.Lesson, records []*models.Module, courseModules models.CourseModules) modulesResponse {
 	const noChapter = "(no chapter)"
 
-	modulesMap := make(map[string][]lessonResponse)
-	order := []string{}
+	recordsByPath := make(map[string]*models.Module, len(records))
+	for _, record := range records {
+		recordsByPath[record.Path] = record
+	}
 
-	for _, g := range lessons {
-		moduleName := strings.TrimSpace(g.Module)
-		if moduleName == "" {
-			moduleName = noChapter
+	nodes := map[string]*moduleNode{}
+	roots := []*moduleNode{}
+
+	var ensure func(p string) *moduleNode
+	ensure = func(p string) *moduleNode {
+		if node, ok := nodes[p]; ok {
+			return node
 		}
 
-		lesson := lessonResponseHelper([]*models.Lesson{g})[0]
+		node := &moduleNode{path: p, record: recordsByPath[p], index: len(nodes)}
+		nodes[p] = node
 
-		if _, ok := modulesMap[moduleName]; !ok {
-			order = append(order, moduleName)
-			modulesMap[moduleName] = []lessonResponse{*lesson}
+		if p != noChapter && strings.Contains(p, "/") {
+			parent := ensure(path.Dir(p))
+			parent.children = append(parent.children, node)
 		} else {
-			modulesMap[moduleName] = append(modulesMap[moduleName], *lesson)
+			roots = append(roots, node)
 		}
+
+		return node
+	}
+
+	for _, g := range lessons {
+		moduleName := strings.Trim(strings.TrimSpace(g.Module), "/")
+		if moduleName == "" {
+			moduleName = noChapter
+		}
+
+		node := ensure(moduleName)
+		node.lessons = append(node.lessons, *lessonResponseHelper([]*models.Lesson{g})[0])
 	}
 
-	// Build ordered modules with 1-based index, ensuring lessons are ordered by prefix
-	modules := make([]moduleResponse, 0, len(order))
-	for i, name := range order {
-		lessons := modulesMap[name]
-		sort.SliceStable(lessons, func(i, j int) bool { return lessons[i].Prefix < lessons[j].Prefix })
+	var build func(siblings []*moduleNode, depth int) []moduleResponse
+	build = func(siblings []*moduleNode, depth int) []moduleResponse {
+		sort.SliceStable(siblings, func(i, j int) bool {
+			iModule, jModule := courseModules.Find(siblings[i].path), courseModules.Find(siblings[j].path)
+
+			iHasOrder := iModule != nil && iModule.Order != nil
+			jHasOrder := jModule != nil && jModule.Order != nil
+
+			if iHasOrder || jHasOrder {
+				if iHasOrder && jHasOrder {
+					return *iModule.Order < *jModule.Order
+				}
+
+				return iHasOrder
+			}
+
+			iHasPrefix := siblings[i].record != nil && siblings[i].record.Prefix.Valid
+			jHasPrefix := siblings[j].record != nil && siblings[j].record.Prefix.Valid
+
+			if iHasPrefix && jHasPrefix && siblings[i].record.Prefix.Int16 != siblings[j].record.Prefix.Int16 {
+				return siblings[i].record.Prefix.Int16 < siblings[j].record.Prefix.Int16
+			}
+
+			if iHasPrefix != jHasPrefix {
+				return iHasPrefix
+			}
 
-		modules = append(modules, moduleResponse{
-			Prefix:  i + 1,
-			Module:  name,
-			Lessons: lessons,
+			return siblings[i].index < siblings[j].index
 		})
+
+		// Build ordered modules with 1-based index, ensuring lessons are ordered by prefix
+		modules := make([]moduleResponse, 0, len(siblings))
+		for i, node := range siblings {
+			lessons := node.lessons
+			if lessons == nil {
+				lessons = []lessonResponse{}
+			}
+
+			sort.SliceStable(lessons, func(i, j int) bool { return lessons[i].Prefix < lessons[j].Prefix })
+
+			module := moduleResponse{
+				Prefix:  i + 1,
+				Module:  node.path,
+				Depth:   depth,
+				Lessons: lessons,
+				Modules: build(node.children, depth+1),
+			}
+
+			// The scanned title is only used when it differs from the directory name
+			if node.record != nil {
+				module.ID = node.record.ID
+				module.ParentID = node.record.ParentID.String
+
+				if node.record.Title != path.Base(node.path) {
+					module.Title = node.record.Title
+				}
+			}
+
+			if override := courseModules.Find(node.path); override != nil && override.Title != "" {
+				module.Title = override.Title
+			}
+
+			for _, lesson := range lessons {
+				module.LessonsCount++
+				module.DurationSec += lesson.TotalVideoDuration
+				module.Started = module.Started || lesson.Started
+
+				if lesson.Completed {
+					module.LessonsCompleted++
+				}
+			}
+
+			for _, child := range module.Modules {
+				module.LessonsCount += child.LessonsCount
+				module.LessonsCompleted += child.LessonsCompleted
+				module.DurationSec += child.DurationSec
+				module.Started = module.Started || child.Started
+			}
+
+			if module.LessonsCount > 0 {
+				module.Percent = module.LessonsCompleted * 100 / module.LessonsCount
+				module.Completed = module.LessonsCompleted == module.LessonsCount
+			}
+
+			modules = append(modules, module)
+		}
+
+		return modules
 	}
 
-	return modulesResponse{Modules: modules}
+	return modulesResponse{Modules: build(roots, 0)}
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -318,13 +605,33 @@ type assetAudioMetadataResponse struct {
 	ChannelLayout string `json:"channelLayout"`
 	SampleRate    int    `json:"sampleRate"`
 	BitRate       int    `json:"bitRate"`
+
+	// Audio assets only
+	DurationSec int    `json:"durationSec,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Artist      string `json:"artist,omitempty"`
+	Album       string `json:"album,omitempty"`
+	HasCoverArt bool   `json:"hasCoverArt,omitempty"`
+
+	// LoudnessLUFS is only set once the loudness is measured
+	LoudnessLUFS *float64 `json:"loudnessLufs,omitempty"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type assetChapterResponse struct {
+	Index    int     `json:"index"`
+	StartSec float64 `json:"startSec"`
+	EndSec   float64 `json:"endSec"`
+	Title    string  `json:"title"`
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 type assetMetadataResponse struct {
-	Video assetVideoMetadataResponse `json:"video,omitempty"`
-	Audio assetAudioMetadataResponse `json:"audio,omitempty"`
+	Video    assetVideoMetadataResponse `json:"video,omitempty"`
+	Audio    assetAudioMetadataResponse `json:"audio,omitempty"`
+	Chapters []assetChapterResponse     `json:"chapters"`
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -355,7 +662,7 @@ func assetResponseHelper(assets []*models.Asset) []*assetResponse {
 	for _, asset := range assets {
 
 		// Asset metadata
-		assetMetadata := &assetMetadataResponse{}
+		assetMetadata := &assetMetadataResponse{Chapters: []assetChapterResponse{}}
 		if asset.AssetMetadata != nil {
 			if asset.AssetMetadata.VideoMetadata != nil {
 				assetMetadata.Video = assetVideoMetadataResponse{
@@ -381,8 +688,26 @@ func assetResponseHelper(assets []*models.Asset) []*assetResponse {
 					ChannelLayout: asset.AssetMetadata.AudioMetadata.ChannelLayout,
 					SampleRate:    asset.AssetMetadata.AudioMetadata.SampleRate,
 					BitRate:       asset.AssetMetadata.AudioMetadata.BitRate,
+					DurationSec:   asset.AssetMetadata.AudioMetadata.DurationSec,
+					Title:         asset.AssetMetadata.AudioMetadata.Title,
+					Artist:        asset.AssetMetadata.AudioMetadata.Artist,
+					Album:         asset.AssetMetadata.AudioMetadata.Album,
+					HasCoverArt:   asset.AssetMetadata.AudioMetadata.HasCoverArt,
+				}
+
+				if lufs := asset.AssetMetadata.AudioMetadata.LoudnessLUFS; lufs.Valid {
+					assetMetadata.Audio.LoudnessLUFS = &lufs.Float64
 				}
 			}
+
+			for _, chapter := range asset.AssetMetadata.ChapterMetadata {
+				assetMetadata.Chapters = append(assetMetadata.Chapters, assetChapterResponse{
+					Index:    chapter.ChapterIndex,
+					StartSec: chapter.StartSec,
+					EndSec:   chapter.EndSec,
+					Title:    chapter.DisplayTitle(),
+				})
+			}
 		}
 
 		// Asset progress
@@ -466,12 +791,19 @@ type ScanRequest struct {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+type scanMoveRequest struct {
+	Position int `json:"position"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 type scanResponse struct {
 	ID          string               `json:"id"`
 	CourseID    string               `json:"courseId"`
 	CourseTitle string               `json:"courseTitle"`
 	Status      types.ScanStatusType `json:"status"`
 	Message     string               `json:"message"`
+	Priority    string               `json:"priority"`
 	CreatedAt   types.DateTime       `json:"createdAt"`
 }
 
@@ -486,6 +818,7 @@ func scanResponseHelper(scanStates []*coursescan.ScanState, isAdmin bool) []*sca
 			CourseTitle: scanState.CourseTitle,
 			Status:      scanState.GetStatus(),
 			Message:     scanState.GetMessage(),
+			Priority:    scanState.GetPriority().String(),
 			CreatedAt:   types.DateTime(scanState.CreatedAt),
 		}
 
@@ -497,6 +830,88 @@ func scanResponseHelper(scanStates []*coursescan.ScanState, isAdmin bool) []*sca
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+type scanQueueResponse struct {
+	Paused     bool `json:"paused"`
+	Workers    int  `json:"workers"`
+	Processing int  `json:"processing"`
+	Waiting    int  `json:"waiting"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func scanQueueResponseHelper(status coursescan.QueueStatus) *scanQueueResponse {
+	return &scanQueueResponse{
+		Paused:     status.Paused,
+		Workers:    status.Workers,
+		Processing: status.Processing,
+		Waiting:    status.Waiting,
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type scanQueueCancelResponse struct {
+	Cancelled int `json:"cancelled"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type scanHistoryResponse struct {
+	ID                 string                  `json:"id"`
+	CourseID           string                  `json:"courseId"`
+	CourseTitle        string                  `json:"courseTitle"`
+	Status             types.ScanStatusType    `json:"status"`
+	Error              string                  `json:"error"`
+	StartedAt          types.DateTime          `json:"startedAt"`
+	FinishedAt         types.DateTime          `json:"finishedAt"`
+	Duration           int                     `json:"duration"`
+	LessonsCreated     int                     `json:"lessonsCreated"`
+	LessonsUpdated     int                     `json:"lessonsUpdated"`
+	LessonsDeleted     int                     `json:"lessonsDeleted"`
+	AssetsCreated      int                     `json:"assetsCreated"`
+	AssetsUpdated      int                     `json:"assetsUpdated"`
+	AssetsDeleted      int                     `json:"assetsDeleted"`
+	AttachmentsCreated int                     `json:"attachmentsCreated"`
+	AttachmentsDeleted int                     `json:"attachmentsDeleted"`
+	Skipped            models.ScanSkippedFiles `json:"skipped"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func scanHistoryResponseHelper(histories []*models.ScanHistory) []*scanHistoryResponse {
+	responses := []*scanHistoryResponse{}
+	for _, history := range histories {
+		skipped := history.Skipped
+		if skipped == nil {
+			skipped = models.ScanSkippedFiles{}
+		}
+
+		responses = append(responses, &scanHistoryResponse{
+			ID:                 history.ID,
+			CourseID:           history.CourseID,
+			CourseTitle:        history.CourseTitle,
+			Status:             history.Status,
+			Error:              history.Error,
+			StartedAt:          history.StartedAt,
+			FinishedAt:         history.FinishedAt,
+			Duration:           history.Duration,
+			LessonsCreated:     history.LessonsCreated,
+			LessonsUpdated:     history.LessonsUpdated,
+			LessonsDeleted:     history.LessonsDeleted,
+			AssetsCreated:      history.AssetsCreated,
+			AssetsUpdated:      history.AssetsUpdated,
+			AssetsDeleted:      history.AssetsDeleted,
+			AttachmentsCreated: history.AttachmentsCreated,
+			AttachmentsDeleted: history.AttachmentsDeleted,
+			Skipped:            skipped,
+		})
+	}
+
+	return responses
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 type tagRequest struct {
 	Tag string `json:"tag"`
 }
@@ -532,6 +947,62 @@ func tagResponseHelper(tags []*models.Tag) []*tagResponse {
 	return responses
 }
 
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Library Root
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type libraryRootRequest struct {
+	Path            string `json:"path"`
+	MaxDepth        int    `json:"maxDepth"`
+	RequireVideo    bool   `json:"requireVideo"`
+	RequireNumbered bool   `json:"requireNumbered"`
+	FlagMissing     bool   `json:"flagMissing"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type libraryRootResponse struct {
+	ID               string         `json:"id"`
+	Path             string         `json:"path"`
+	MaxDepth         int            `json:"maxDepth"`
+	RequireVideo     bool           `json:"requireVideo"`
+	RequireNumbered  bool           `json:"requireNumbered"`
+	FlagMissing      bool           `json:"flagMissing"`
+	LastDiscoveredAt types.DateTime `json:"lastDiscoveredAt"`
+	CreatedAt        types.DateTime `json:"createdAt"`
+	UpdatedAt        types.DateTime `json:"updatedAt"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func libraryRootResponseHelper(roots []*models.LibraryRoot) []*libraryRootResponse {
+	responses := []*libraryRootResponse{}
+
+	for _, root := range roots {
+		responses = append(responses, &libraryRootResponse{
+			ID:               root.ID,
+			Path:             root.Path,
+			MaxDepth:         root.MaxDepth,
+			RequireVideo:     root.RequireVideo,
+			RequireNumbered:  root.RequireNumbered,
+			FlagMissing:      root.FlagMissing,
+			LastDiscoveredAt: root.LastDiscoveredAt,
+			CreatedAt:        root.CreatedAt,
+			UpdatedAt:        root.UpdatedAt,
+		})
+	}
+
+	return responses
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type libraryDiscoveryResponse struct {
+	Created []*courseResponse `json:"created"`
+	Queued  int               `json:"queued"`
+	Missing int               `json:"missing"`
+}
+
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 // User
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -551,6 +1022,8 @@ type userResponse struct {
 	Username    string         `json:"username"`
 	DisplayName string         `json:"displayName"`
 	Role        types.UserRole `json:"role"`
+
+	PreferredAudioLanguage string `json:"preferredAudioLanguage"`
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -564,6 +1037,8 @@ func userResponseHelper(users []*models.User) []*userResponse {
 			Username:    user.Username,
 			DisplayName: user.DisplayName,
 			Role:        user.Role,
+
+			PreferredAudioLanguage: user.PreferredAudioLanguage,
 		})
 	}
 
@@ -599,6 +1074,9 @@ type selfUpdateRequest struct {
 	DisplayName     string `json:"displayName"`
 	CurrentPassword string `json:"currentPassword"`
 	Password        string `json:"password"`
+
+	// PreferredAudioLanguage is a pointer so that it can be cleared with an empty string
+	PreferredAudioLanguage *string `json:"preferredAudioLanguage"`
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -634,3 +1112,346 @@ func logsResponseHelper(logs []*models.Log) []*logResponse {
 
 	return responses
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type pretranscodeRequest struct {
+	CourseIDs []string `json:"courseIds"`
+	AssetIDs  []string `json:"assetIds"`
+	Qualities []string `json:"qualities"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type pretranscodeWindowRequest struct {
+	Window string `json:"window"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type pretranscodeJobResponse struct {
+	ID        string                    `json:"id"`
+	AssetID   string                    `json:"assetId"`
+	Qualities []hls.Quality             `json:"qualities"`
+	Status    hls.PretranscodeJobStatus `json:"status"`
+	Error     string                    `json:"error"`
+	Done      int                       `json:"done"`
+	Total     int                       `json:"total"`
+	CreatedAt types.DateTime            `json:"createdAt"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func pretranscodeJobResponseHelper(jobs []hls.PretranscodeJob) []*pretranscodeJobResponse {
+	responses := []*pretranscodeJobResponse{}
+
+	for _, job := range jobs {
+		responses = append(responses, &pretranscodeJobResponse{
+			ID:        job.ID,
+			AssetID:   job.AssetID,
+			Qualities: job.Qualities,
+			Status:    job.Status,
+			Error:     job.Error,
+			Done:      job.Done,
+			Total:     job.Total,
+			CreatedAt: types.DateTime(job.CreatedAt),
+		})
+	}
+
+	return responses
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type pretranscodeQueueResponse struct {
+	Paused   bool                       `json:"paused"`
+	Window   string                     `json:"window"`
+	InWindow bool                       `json:"inWindow"`
+	Jobs     []*pretranscodeJobResponse `json:"jobs"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func pretranscodeQueueResponseHelper(status hls.PretranscodeQueueStatus) *pretranscodeQueueResponse {
+	return &pretranscodeQueueResponse{
+		Paused:   status.Paused,
+		Window:   status.Window.String(),
+		InWindow: status.InWindow,
+		Jobs:     pretranscodeJobResponseHelper(status.Jobs),
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type hlsCacheEntryResponse struct {
+	AssetID    string         `json:"assetId"`
+	Stream     string         `json:"stream"`
+	Complete   bool           `json:"complete"`
+	Size       int64          `json:"size"`
+	LastAccess types.DateTime `json:"lastAccess"`
+	Active     bool           `json:"active"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type hlsCacheStatsResponse struct {
+	Size    int64                    `json:"size"`
+	MaxSize int64                    `json:"maxSize"`
+	MaxAge  string                   `json:"maxAge"`
+	Entries []*hlsCacheEntryResponse `json:"entries"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func hlsCacheStatsResponseHelper(stats *hls.CacheStats) *hlsCacheStatsResponse {
+	response := &hlsCacheStatsResponse{
+		Size:    stats.Size,
+		MaxSize: stats.MaxSize,
+		Entries: []*hlsCacheEntryResponse{},
+	}
+
+	if stats.MaxAge > 0 {
+		response.MaxAge = stats.MaxAge.String()
+	}
+
+	for _, entry := range stats.Entries {
+		response.Entries = append(response.Entries, &hlsCacheEntryResponse{
+			AssetID:    entry.AssetID,
+			Stream:     entry.Stream,
+			Complete:   entry.Complete,
+			Size:       entry.Size,
+			LastAccess: types.DateTime(entry.LastAccess),
+			Active:     entry.Active,
+		})
+	}
+
+	return response
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type transcodeUserResponse struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type transcodeHeadResponse struct {
+	Stream       string         `json:"stream"`
+	UserID       string         `json:"userId"`
+	PID          int            `json:"pid"`
+	StartSegment int32          `json:"startSegment"`
+	EndSegment   int32          `json:"endSegment"`
+	Segment      int32          `json:"segment"`
+	Segments     int32          `json:"segments"`
+	StartedAt    types.DateTime `json:"startedAt"`
+	CPUTime      float64        `json:"cpuTime"`
+	Speed        float64        `json:"speed"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type transcodeSessionResponse struct {
+	AssetID     string                   `json:"assetId"`
+	AssetTitle  string                   `json:"assetTitle"`
+	CourseID    string                   `json:"courseId"`
+	CourseTitle string                   `json:"courseTitle"`
+	Users       []*transcodeUserResponse `json:"users"`
+	Qualities   []hls.Quality            `json:"qualities"`
+	Heads       []*transcodeHeadResponse `json:"heads"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func transcodeSessionResponseHelper(
+	sessions []hls.Session,
+	assets map[string]*models.Asset,
+	courses map[string]*models.Course,
+	users map[string]*models.User,
+) []*transcodeSessionResponse {
+	responses := []*transcodeSessionResponse{}
+
+	for _, session := range sessions {
+		response := &transcodeSessionResponse{
+			AssetID:   session.AssetID,
+			Users:     []*transcodeUserResponse{},
+			Qualities: session.Qualities,
+			Heads:     []*transcodeHeadResponse{},
+		}
+
+		if asset, ok := assets[session.AssetID]; ok {
+			response.AssetTitle = asset.Title
+			response.CourseID = asset.CourseID
+
+			if course, ok := courses[asset.CourseID]; ok {
+				response.CourseTitle = course.Title
+			}
+		}
+
+		for _, userID := range session.Users {
+			user := &transcodeUserResponse{ID: userID}
+			if u, ok := users[userID]; ok {
+				user.Username = u.Username
+			}
+
+			response.Users = append(response.Users, user)
+		}
+
+		for _, head := range session.Heads {
+			response.Heads = append(response.Heads, &transcodeHeadResponse{
+				Stream:       head.Stream,
+				UserID:       head.UserID,
+				PID:          head.PID,
+				StartSegment: head.StartSegment,
+				EndSegment:   head.EndSegment,
+				Segment:      head.Segment,
+				Segments:     head.Segments,
+				StartedAt:    types.DateTime(head.Started),
+				CPUTime:      head.CPUTime.Seconds(),
+				Speed:        head.Speed,
+			})
+		}
+
+		responses = append(responses, response)
+	}
+
+	return responses
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type hlsCachePurgeResponse struct {
+	Purged  int   `json:"purged"`
+	Skipped int   `json:"skipped"`
+	Freed   int64 `json:"freed"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type hlsPlaybackInfoRequest struct {
+	VideoCodecs []string `json:"videoCodecs"`
+	AudioCodecs []string `json:"audioCodecs"`
+	Containers  []string `json:"containers"`
+	MaxHeight   uint32   `json:"maxHeight"`
+	MaxBitrate  uint32   `json:"maxBitrate"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type hlsPlaybackInfoResponse struct {
+	Method  string `json:"method"`
+	Reason  string `json:"reason"`
+	Format  string `json:"format,omitempty"`
+	Quality string `json:"quality,omitempty"`
+	URL     string `json:"url"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func hlsPlaybackInfoResponseHelper(asset *models.Asset, decision hls.PlaybackDecision) *hlsPlaybackInfoResponse {
+	response := &hlsPlaybackInfoResponse{
+		Method: string(decision.Method),
+		Reason: decision.Reason,
+	}
+
+	// The original file is served as-is
+	if decision.Method == hls.PlaybackDirectPlay {
+		response.URL = fmt.Sprintf("/api/courses/%s/lessons/%s/assets/%s/serve", asset.CourseID, asset.LessonID, asset.ID)
+		return response
+	}
+
+	query := url.Values{}
+	query.Set("playback", string(decision.Method))
+	query.Set("format", string(decision.Format))
+
+	response.Format = string(decision.Format)
+
+	if decision.Method == hls.PlaybackTranscode {
+		query.Set("quality", string(decision.Quality))
+		response.Quality = string(decision.Quality)
+	}
+
+	response.URL = fmt.Sprintf("/api/hls/%s/master.m3u8?%s", asset.ID, query.Encode())
+
+	return response
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type hlsRung struct {
+	Quality        string `json:"quality"`
+	Enabled        bool   `json:"enabled"`
+	AverageBitrate uint32 `json:"averageBitrate"`
+	MaxBitrate     uint32 `json:"maxBitrate"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type hlsProfileRequest struct {
+	Rungs           []hlsRung `json:"rungs"`
+	Preset          string    `json:"preset"`
+	CRF             int       `json:"crf"`
+	MaxQuality      string    `json:"maxQuality"`
+	AudioBitrate    uint32    `json:"audioBitrate"`
+	AudioChannels   int       `json:"audioChannels"`
+	SegmentDuration float64   `json:"segmentDuration"`
+	MaxHeads        int       `json:"maxHeads"`
+	MaxUserHeads    int       `json:"maxUserHeads"`
+
+	SurroundPassthrough bool `json:"surroundPassthrough"`
+
+	NormalizeLoudness bool    `json:"normalizeLoudness"`
+	LoudnessTarget    float64 `json:"loudnessTarget"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type hlsProfileResponse struct {
+	Rungs           []hlsRung `json:"rungs"`
+	Preset          string    `json:"preset"`
+	CRF             int       `json:"crf"`
+	MaxQuality      string    `json:"maxQuality"`
+	AudioBitrate    uint32    `json:"audioBitrate"`
+	AudioChannels   int       `json:"audioChannels"`
+	SegmentDuration float64   `json:"segmentDuration"`
+	MaxHeads        int       `json:"maxHeads"`
+	MaxUserHeads    int       `json:"maxUserHeads"`
+
+	SurroundPassthrough bool `json:"surroundPassthrough"`
+
+	NormalizeLoudness bool    `json:"normalizeLoudness"`
+	LoudnessTarget    float64 `json:"loudnessTarget"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func hlsProfileResponseHelper(profile *hls.Profile) *hlsProfileResponse {
+	response := &hlsProfileResponse{
+		Rungs:           []hlsRung{},
+		Preset:          profile.Preset,
+		CRF:             profile.CRF,
+		MaxQuality:      string(profile.MaxQuality),
+		AudioBitrate:    profile.AudioBitrate,
+		AudioChannels:   profile.AudioChannels,
+		SegmentDuration: profile.SegmentDuration,
+		MaxHeads:        profile.MaxHeads,
+		MaxUserHeads:    profile.MaxUserHeads,
+
+		SurroundPassthrough: profile.SurroundPassthrough,
+
+		NormalizeLoudness: profile.NormalizeLoudness,
+		LoudnessTarget:    profile.LoudnessTarget,
+	}
+
+	for _, rung := range profile.Rungs {
+		response.Rungs = append(response.Rungs, hlsRung{
+			Quality:        string(rung.Quality),
+			Enabled:        rung.Enabled,
+			AverageBitrate: rung.AverageBitrate,
+			MaxBitrate:     rung.MaxBitrate,
+		})
+	}
+
+	return response
+}
@@ -2,17 +2,23 @@ package app
 
 import (
 	"context"
+	"path/filepath"
 	"time"
 
 	"github.com/geerew/off-course/dao"
 	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/utils"
 	"github.com/geerew/off-course/utils/appfs"
 	"github.com/geerew/off-course/utils/cardcache"
+	"github.com/geerew/off-course/utils/coursediscovery"
 	"github.com/geerew/off-course/utils/coursemetadata"
+	"github.com/geerew/off-course/utils/coursemove"
 	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/coursewatch"
 	"github.com/geerew/off-course/utils/logger"
 	"github.com/geerew/off-course/utils/media"
 	"github.com/geerew/off-course/utils/media/hls"
+	"github.com/geerew/off-course/utils/thumbnails"
 	"github.com/spf13/afero"
 )
 
@@ -27,10 +33,15 @@ type App struct {
 	DbManager *database.DatabaseManager
 
 	// Services
-	CourseScan     *coursescan.CourseScan
-	Transcoder     *hls.Transcoder
-	CardCache      *cardcache.CardCache
-	MetadataWriter *coursemetadata.MetadataWriter
+	CourseScan      *coursescan.CourseScan
+	CourseWatch     *coursewatch.CourseWatch
+	CourseDiscovery *coursediscovery.CourseDiscovery
+	CourseMove      *coursemove.CourseMove
+	Transcoder      *hls.Transcoder
+	Pretranscoder   *hls.Pretranscoder
+	CardCache       *cardcache.CardCache
+	Thumbnails      *thumbnails.Thumbnails
+	MetadataWriter  *coursemetadata.MetadataWriter
 
 	// Configuration
 	Config *Config
@@ -48,6 +59,29 @@ type Config struct {
 	IsDev        bool
 	EnableSignup bool
 	IsDebug      bool
+	EnableWatch  bool
+	ScanWorkers  int
+	ScanProbes   int
+
+	// PretranscodeWindow is the time of day, in the form HH:MM-HH:MM, during which queued
+	// pre-transcodes run. When empty, they run at any time
+	PretranscodeWindow string
+
+	// HlsCacheMaxSize is the max size of the HLS cache, such as 20GB. When empty, the size is
+	// unlimited
+	HlsCacheMaxSize string
+
+	// HlsCacheMaxAge is how long transcoded segments are kept after they were last watched.
+	// When 0, they are kept until evicted for size
+	HlsCacheMaxAge time.Duration
+
+	// ArchiveCacheMaxSize is the max size of the archive members extracted for ffmpeg, such as
+	// 20GB. When empty, the size is unlimited
+	ArchiveCacheMaxSize string
+
+	// ArchiveCacheMaxAge is how long extracted archive members are kept after they were last
+	// used. When 0, they are kept until evicted for size
+	ArchiveCacheMaxAge time.Duration
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -60,8 +94,31 @@ func New(ctx context.Context, config *Config) (*App, error) {
 		logLevel = logger.LevelDebug
 	}
 
-	// AppFS (filesystem)
-	appFs := appfs.New(afero.NewOsFs())
+	// AppFS (filesystem). Zip and tar archives can be read as course directories, with members
+	// extracted to the archive cache when ffmpeg needs a real file
+	archiveCachePath, err := filepath.Abs(filepath.Join(config.DataDir, "archives"))
+	if err != nil {
+		return nil, &InitializationError{Message: "Failed to resolve archive cache path", Err: err}
+	}
+
+	archiveCacheMaxSize, err := utils.ParseByteSize(config.ArchiveCacheMaxSize)
+	if err != nil {
+		return nil, &InitializationError{Message: "Invalid archive cache max size", Err: err}
+	}
+
+	archiveFs := appfs.NewArchiveFs(afero.NewOsFs(), archiveCachePath)
+	archiveFs.SetCacheLimits(archiveCacheMaxSize, config.ArchiveCacheMaxAge)
+
+	appFs := appfs.New(archiveFs)
+
+	// Extracted members are only reused within a run
+	if err := appFs.Fs.MkdirAll(archiveCachePath, 0o755); err != nil {
+		return nil, &InitializationError{Message: "Failed to create archive cache directory", Err: err}
+	}
+
+	if err := appFs.RemoveAllContents(archiveCachePath); err != nil {
+		return nil, &InitializationError{Message: "Failed to empty archive cache directory", Err: err}
+	}
 
 	// FFmpeg
 	ffmpeg, err := media.NewFFmpeg()
@@ -111,12 +168,19 @@ func New(ctx context.Context, config *Config) (*App, error) {
 	}
 
 	// HLS Transcoder
+	cacheMaxSize, err := utils.ParseByteSize(app.Config.HlsCacheMaxSize)
+	if err != nil {
+		return nil, &InitializationError{Message: "Invalid HLS cache max size", Err: err}
+	}
+
 	transcoder, err := hls.NewTranscoder(&hls.TranscoderConfig{
-		CachePath: app.Config.DataDir,
-		HwAccel:   hls.DetectHardwareAccel(app.Logger.WithHLS()),
-		AppFs:     app.AppFs,
-		Logger:    app.Logger.WithHLS(),
-		Dao:       dao.New(app.DbManager.DataDb),
+		CachePath:    app.Config.DataDir,
+		HwAccel:      hls.DetectHardwareAccel(app.Logger.WithHLS()),
+		AppFs:        app.AppFs,
+		Logger:       app.Logger.WithHLS(),
+		Dao:          dao.New(app.DbManager.DataDb),
+		CacheMaxSize: cacheMaxSize,
+		CacheMaxAge:  app.Config.HlsCacheMaxAge,
 	})
 
 	if err != nil {
@@ -125,6 +189,18 @@ func New(ctx context.Context, config *Config) (*App, error) {
 
 	app.Transcoder = transcoder
 
+	// Pre-transcode queue
+	pretranscoder, err := hls.NewPretranscoder(&hls.PretranscoderConfig{
+		Transcoder: transcoder,
+		Window:     app.Config.PretranscodeWindow,
+	})
+
+	if err != nil {
+		return nil, &InitializationError{Message: "Failed to create pre-transcode queue", Err: err}
+	}
+
+	app.Pretranscoder = pretranscoder
+
 	// Card Cache
 	cardCache, err := cardcache.NewCardCache(&cardcache.CardCacheConfig{
 		CachePath: app.Config.DataDir,
@@ -139,13 +215,65 @@ func New(ctx context.Context, config *Config) (*App, error) {
 
 	app.CardCache = cardCache
 
-	// Course scanner
-	app.CourseScan = coursescan.New(&coursescan.CourseScanConfig{
+	// Thumbnails
+	thumbs, err := thumbnails.New(&thumbnails.ThumbnailsConfig{
+		CachePath: app.Config.DataDir,
 		Db:        app.DbManager.DataDb,
 		AppFs:     app.AppFs,
-		Logger:    app.Logger.WithCourseScan(),
+		Logger:    app.Logger.WithThumbnails(),
 		FFmpeg:    app.FFmpeg,
-		CardCache: cardCache,
+	})
+
+	if err != nil {
+		return nil, &InitializationError{Message: "Failed to create thumbnails", Err: err}
+	}
+
+	app.Thumbnails = thumbs
+
+	// Course scanner
+	app.CourseScan = coursescan.New(&coursescan.CourseScanConfig{
+		Db:         app.DbManager.DataDb,
+		AppFs:      app.AppFs,
+		Logger:     app.Logger.WithCourseScan(),
+		FFmpeg:     app.FFmpeg,
+		CardCache:  cardCache,
+		Thumbnails: thumbs,
+		Workers:    app.Config.ScanWorkers,
+		MaxProbes:  app.Config.ScanProbes,
+	})
+
+	// Course watcher
+	app.CourseWatch = coursewatch.New(&coursewatch.CourseWatchConfig{
+		Db:         app.DbManager.DataDb,
+		AppFs:      app.AppFs,
+		Logger:     app.Logger.WithCourseWatch(),
+		CourseScan: app.CourseScan,
+	})
+
+	// Course discovery
+	app.CourseDiscovery = coursediscovery.New(&coursediscovery.CourseDiscoveryConfig{
+		Db:          app.DbManager.DataDb,
+		AppFs:       app.AppFs,
+		Logger:      app.Logger.WithCourseDiscovery(),
+		CourseScan:  app.CourseScan,
+		CourseWatch: app.CourseWatch,
+	})
+
+	// Course move
+	app.CourseMove = coursemove.New(&coursemove.CourseMoveConfig{
+		Db:          app.DbManager.DataDb,
+		AppFs:       app.AppFs,
+		Logger:      app.Logger.WithCourseMove(),
+		CourseScan:  app.CourseScan,
+		CourseWatch: app.CourseWatch,
+
+		// Open streams keep the old paths, so they are destroyed and start over on the next
+		// request
+		OnRelocated: func(_ string, assetIDs []string) {
+			for _, assetID := range assetIDs {
+				transcoder.KillSession(assetID)
+			}
+		},
 	})
 
 	// Metadata writer for course.json files
@@ -8,11 +8,15 @@ import (
 	"github.com/geerew/off-course/database"
 	"github.com/geerew/off-course/utils/appfs"
 	"github.com/geerew/off-course/utils/cardcache"
+	"github.com/geerew/off-course/utils/coursediscovery"
 	"github.com/geerew/off-course/utils/coursemetadata"
+	"github.com/geerew/off-course/utils/coursemove"
 	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/coursewatch"
 	"github.com/geerew/off-course/utils/logger"
 	"github.com/geerew/off-course/utils/media"
 	"github.com/geerew/off-course/utils/media/hls"
+	"github.com/geerew/off-course/utils/thumbnails"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
@@ -90,6 +94,11 @@ func NewTestApp(t *testing.T) *App {
 	require.NoError(t, err)
 	app.Transcoder = transcoder
 
+	// Initialize the pre-transcode queue (worker not started)
+	pretranscoder, err := hls.NewPretranscoder(&hls.PretranscoderConfig{Transcoder: transcoder})
+	require.NoError(t, err)
+	app.Pretranscoder = pretranscoder
+
 	// Initialize CardCache
 	cardCache, err := cardcache.NewCardCache(&cardcache.CardCacheConfig{
 		CachePath: app.Config.DataDir,
@@ -105,13 +114,51 @@ func NewTestApp(t *testing.T) *App {
 	err = cardCache.EnsureFallbackCard(fallbackPath)
 	require.NoError(t, err)
 
-	// Initialize CourseScan
-	app.CourseScan = coursescan.New(&coursescan.CourseScanConfig{
+	// Initialize Thumbnails (worker not started)
+	thumbs, err := thumbnails.New(&thumbnails.ThumbnailsConfig{
+		CachePath: app.Config.DataDir,
 		Db:        app.DbManager.DataDb,
 		AppFs:     app.AppFs,
-		Logger:    app.Logger.WithCourseScan(),
+		Logger:    app.Logger.WithThumbnails(),
 		FFmpeg:    app.FFmpeg,
-		CardCache: cardCache,
+	})
+	require.NoError(t, err)
+	app.Thumbnails = thumbs
+
+	// Initialize CourseScan
+	app.CourseScan = coursescan.New(&coursescan.CourseScanConfig{
+		Db:         app.DbManager.DataDb,
+		AppFs:      app.AppFs,
+		Logger:     app.Logger.WithCourseScan(),
+		FFmpeg:     app.FFmpeg,
+		CardCache:  cardCache,
+		Thumbnails: thumbs,
+	})
+
+	// Initialize CourseWatch (not started)
+	app.CourseWatch = coursewatch.New(&coursewatch.CourseWatchConfig{
+		Db:         app.DbManager.DataDb,
+		AppFs:      app.AppFs,
+		Logger:     app.Logger.WithCourseWatch(),
+		CourseScan: app.CourseScan,
+	})
+
+	// Initialize CourseDiscovery
+	app.CourseDiscovery = coursediscovery.New(&coursediscovery.CourseDiscoveryConfig{
+		Db:          app.DbManager.DataDb,
+		AppFs:       app.AppFs,
+		Logger:      app.Logger.WithCourseDiscovery(),
+		CourseScan:  app.CourseScan,
+		CourseWatch: app.CourseWatch,
+	})
+
+	// Initialize CourseMove
+	app.CourseMove = coursemove.New(&coursemove.CourseMoveConfig{
+		Db:          app.DbManager.DataDb,
+		AppFs:       app.AppFs,
+		Logger:      app.Logger.WithCourseMove(),
+		CourseScan:  app.CourseScan,
+		CourseWatch: app.CourseWatch,
 	})
 
 	// Initialize MetadataWriter
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/coursemove"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/spf13/cobra"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+var courseMoveCmd = &cobra.Command{
+	Use:   "move <course id | path>... <destination>",
+	Short: "Move a course to a new path, keeping its progress",
+	Long: "Move a course to a new path. The files are moved (or copied and removed when the " +
+		"destination is on another filesystem), verified against their recorded hashes and the " +
+		"course is updated in place, keeping progress, favourites and tags.\n\n" +
+		"With --relink, the files are expected to have already been moved to the destination.\n\n" +
+		"When more than one course is given, or --root is set, the destination is a directory " +
+		"the courses are moved into.",
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		relink, _ := cmd.Flags().GetBool("relink")
+		root, _ := cmd.Flags().GetBool("root")
+
+		dbManager, appFs, err := openDataDb()
+		if err != nil {
+			errorMessage("%s", err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		appDao := dao.New(dbManager.DataDb)
+
+		dest, err := filepath.Abs(args[len(args)-1])
+		if err != nil {
+			errorMessage("Invalid destination: %s", err)
+			os.Exit(1)
+		}
+
+		courseIDs := []string{}
+		for _, arg := range args[:len(args)-1] {
+			course, err := findCourse(ctx, appDao, arg)
+			if err != nil {
+				errorMessage("Failed to look up course: %s", err)
+				os.Exit(1)
+			}
+
+			if course == nil {
+				errorMessage("Course not found: %s", arg)
+				os.Exit(1)
+			}
+
+			courseIDs = append(courseIDs, course.ID)
+		}
+
+		mode := coursemove.ModeMove
+		if relink {
+			mode = coursemove.ModeRelink
+		}
+
+		courseMove := coursemove.New(&coursemove.CourseMoveConfig{
+			Db:     dbManager.DataDb,
+			AppFs:  appFs,
+			Logger: logger.New(&logger.Config{Level: logger.LevelError, ConsoleOutput: true}).WithCourseMove(),
+		})
+
+		var results []*coursemove.Result
+		if root || len(courseIDs) > 1 {
+			results = courseMove.MoveAll(ctx, courseIDs, dest, mode, printMoveProgress)
+		} else {
+			result, err := courseMove.Move(ctx, courseIDs[0], dest, mode, printMoveProgress)
+			if err != nil {
+				result = &coursemove.Result{CourseID: courseIDs[0], To: dest, Mode: mode, Err: err}
+			}
+
+			results = append(results, result)
+		}
+
+		failed := false
+		for _, result := range results {
+			if result.Err != nil {
+				failed = true
+				errorMessage("Failed to move course %s to '%s': %s", result.CourseID, result.To, result.Err)
+				continue
+			}
+
+			successMessage("Moved course %s from '%s' to '%s' (%d assets verified)", result.CourseID, result.From, result.To, result.Verified)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// findCourse finds a course by its ID or, failing that, by its path. The path does not need to
+// exist as the course may have already been moved
+func findCourse(ctx context.Context, appDao *dao.DAO, arg string) (*models.Course, error) {
+	course, err := appDao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: arg}))
+	if err != nil || course != nil {
+		return course, err
+	}
+
+	path, err := filepath.Abs(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return appDao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_PATH: utils.NormalizeWindowsDrive(path)}))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// printMoveProgress prints the stage of a move and, when copying, the bytes copied
+func printMoveProgress(p coursemove.Progress) {
+	switch p.Stage {
+	case coursemove.StageCopying:
+		if p.Total > 0 {
+			fmt.Printf("\r%s: copying %d%%", p.CourseID, p.Done*100/p.Total)
+		}
+
+		if p.Done == p.Total {
+			fmt.Println()
+		}
+	case coursemove.StageVerifying:
+		if p.Done == p.Total {
+			fmt.Printf("%s: verified %d assets\n", p.CourseID, p.Total)
+		}
+	case coursemove.StageMoving, coursemove.StageUpdating:
+		fmt.Printf("%s: %s\n", p.CourseID, p.Stage)
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func init() {
+	courseCmd.AddCommand(courseMoveCmd)
+	courseMoveCmd.Flags().Bool("relink", false, "Point the course at a destination the files were already moved to")
+	courseMoveCmd.Flags().Bool("root", false, "Move the course into the destination directory, keeping its name")
+}
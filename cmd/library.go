@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// libraryCmd represents the library command
+var libraryCmd = &cobra.Command{
+	Use:   "library",
+	Short: "Library root commands",
+	Long:  "Commands for managing library roots, which are parent folders that are searched for courses.",
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func init() {
+	rootCmd.AddCommand(libraryCmd)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+var libraryAddCmd = &cobra.Command{
+	Use:   "add <path>",
+	Short: "Add a library root",
+	Long:  "Add a library root. Courses beneath it are discovered on the next discovery run, or immediately with `library discover`.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		maxDepth, _ := cmd.Flags().GetInt("max-depth")
+		requireVideo, _ := cmd.Flags().GetBool("require-video")
+		requireNumbered, _ := cmd.Flags().GetBool("require-numbered")
+		flagMissing, _ := cmd.Flags().GetBool("flag-missing")
+
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			errorMessage("Invalid path: %s", err)
+			os.Exit(1)
+		}
+
+		dbManager, appFs, err := openDataDb()
+		if err != nil {
+			errorMessage("%s", err)
+			os.Exit(1)
+		}
+
+		root := &models.LibraryRoot{
+			Path:            utils.NormalizeWindowsDrive(path),
+			MaxDepth:        maxDepth,
+			RequireVideo:    requireVideo,
+			RequireNumbered: requireNumbered,
+			FlagMissing:     flagMissing,
+		}
+
+		if exists, err := afero.DirExists(appFs.Fs, root.Path); err != nil || !exists {
+			errorMessage("Path '%s' is not a directory", root.Path)
+			os.Exit(1)
+		}
+
+		if err := dao.New(dbManager.DataDb).CreateLibraryRoot(context.Background(), root); err != nil {
+			if strings.HasPrefix(err.Error(), "UNIQUE constraint failed") {
+				errorMessage("A library root with path '%s' already exists", root.Path)
+			} else {
+				errorMessage("Failed to add library root: %s", err)
+			}
+			os.Exit(1)
+		}
+
+		successMessage("Library root '%s' added", root.Path)
+	},
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func init() {
+	libraryCmd.AddCommand(libraryAddCmd)
+
+	libraryAddCmd.Flags().Int("max-depth", 2, "How many directory levels below the root are searched for courses")
+	libraryAddCmd.Flags().Bool("require-video", false, "Only consider directories containing a video to be courses")
+	libraryAddCmd.Flags().Bool("require-numbered", false, "Only consider numbered files when looking for courses")
+	libraryAddCmd.Flags().Bool("flag-missing", false, "Flag discovered courses whose directory no longer exists")
+}
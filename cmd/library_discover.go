@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/coursediscovery"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/spf13/cobra"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+var libraryDiscoverCmd = &cobra.Command{
+	Use:   "discover [path]",
+	Short: "Discover courses beneath library roots",
+	Long: "Discover courses beneath a library root, or all library roots when no path is given. " +
+		"Discovered courses are scanned by the running application on its next discovery run.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dbManager, appFs, err := openDataDb()
+		if err != nil {
+			errorMessage("%s", err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		appDao := dao.New(dbManager.DataDb)
+
+		var dbOpts *dao.Options
+		if len(args) == 1 {
+			path, err := filepath.Abs(args[0])
+			if err != nil {
+				errorMessage("Invalid path: %s", err)
+				os.Exit(1)
+			}
+
+			dbOpts = dao.NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_PATH: utils.NormalizeWindowsDrive(path)})
+		}
+
+		roots, err := appDao.ListLibraryRoots(ctx, dbOpts)
+		if err != nil {
+			errorMessage("Failed to look up library roots: %s", err)
+			os.Exit(1)
+		}
+
+		if len(roots) == 0 {
+			errorMessage("No library roots found")
+			os.Exit(1)
+		}
+
+		discovery := coursediscovery.New(&coursediscovery.CourseDiscoveryConfig{
+			Db:     dbManager.DataDb,
+			AppFs:  appFs,
+			Logger: logger.New(&logger.Config{Level: logger.LevelError, ConsoleOutput: true}).WithCourseDiscovery(),
+		})
+
+		failed := false
+		for _, root := range roots {
+			result, err := discovery.Discover(ctx, root)
+			if err != nil {
+				errorMessage("Failed to discover '%s': %s", root.Path, err)
+				failed = true
+				continue
+			}
+
+			successMessage("%s: %d course(s) added, %d missing", root.Path, len(result.Created), result.Missing)
+			for _, course := range result.Created {
+				successMessage("  + %s", course.Path)
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func init() {
+	libraryCmd.AddCommand(libraryDiscoverCmd)
+}
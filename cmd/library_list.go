@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/spf13/cobra"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+var libraryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List library roots",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dbManager, _, err := openDataDb()
+		if err != nil {
+			errorMessage("%s", err)
+			os.Exit(1)
+		}
+
+		dbOpts := dao.NewOptions().WithOrderBy(models.LIBRARY_ROOT_TABLE_PATH + " ASC")
+		roots, err := dao.New(dbManager.DataDb).ListLibraryRoots(context.Background(), dbOpts)
+		if err != nil {
+			errorMessage("Failed to list library roots: %s", err)
+			os.Exit(1)
+		}
+
+		if len(roots) == 0 {
+			fmt.Println("No library roots")
+			return
+		}
+
+		for _, root := range roots {
+			lastDiscovered := "never"
+			if !root.LastDiscoveredAt.IsZero() {
+				lastDiscovered = root.LastDiscoveredAt.String()
+			}
+
+			fmt.Printf("%s\n", root.Path)
+			fmt.Printf("  max depth: %d, require video: %t, require numbered: %t, flag missing: %t\n",
+				root.MaxDepth, root.RequireVideo, root.RequireNumbered, root.FlagMissing)
+			fmt.Printf("  last discovered: %s\n", lastDiscovered)
+		}
+	},
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func init() {
+	libraryCmd.AddCommand(libraryListCmd)
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/spf13/cobra"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+var libraryRemoveCmd = &cobra.Command{
+	Use:   "remove <path>",
+	Short: "Remove a library root",
+	Long:  "Remove a library root. Courses that were discovered under it are kept.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			errorMessage("Invalid path: %s", err)
+			os.Exit(1)
+		}
+
+		path = utils.NormalizeWindowsDrive(path)
+
+		dbManager, _, err := openDataDb()
+		if err != nil {
+			errorMessage("%s", err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		appDao := dao.New(dbManager.DataDb)
+
+		dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_PATH: path})
+		root, err := appDao.GetLibraryRoot(ctx, dbOpts)
+		if err != nil {
+			errorMessage("Failed to look up library root: %s", err)
+			os.Exit(1)
+		}
+
+		if root == nil {
+			errorMessage("Library root '%s' not found", path)
+			os.Exit(1)
+		}
+
+		if err := appDao.DeleteLibraryRoots(ctx, dbOpts); err != nil {
+			errorMessage("Failed to remove library root: %s", err)
+			os.Exit(1)
+		}
+
+		successMessage("Library root '%s' removed", path)
+	},
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func init() {
+	libraryCmd.AddCommand(libraryRemoveCmd)
+}
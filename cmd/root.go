@@ -2,11 +2,17 @@ package cmd
 
 import (
 	"bufio"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/fatih/color"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"golang.org/x/term"
 )
 
@@ -82,6 +88,26 @@ func questionPlainWithDefault(question, def string) string {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// openDataDb opens the data database for commands that work on the library directly
+func openDataDb() (*database.DatabaseManager, *appfs.AppFs, error) {
+	dataDir := viper.GetString("data-dir")
+	appFs := appfs.New(appfs.NewArchiveFs(afero.NewOsFs(), filepath.Join(dataDir, "archives")))
+
+	dbManager, err := database.NewSQLiteManager(&database.DatabaseManagerConfig{
+		DataDir: dataDir,
+		AppFs:   appFs,
+		Testing: false,
+	})
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+
+	return dbManager, appFs, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // errorMessage prints an error message
 func errorMessage(message string, a ...any) {
 	c := color.New(color.FgRed)
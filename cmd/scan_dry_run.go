@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/spf13/cobra"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+var scanDryRunCmd = &cobra.Command{
+	Use:   "dry-run <course id | path>",
+	Short: "Preview the changes a scan would make to a course",
+	Long: "Scan a course and print, as JSON, the lesson, asset and attachment changes a scan would " +
+		"make. Nothing is written to the database.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dbManager, appFs, err := openDataDb()
+		if err != nil {
+			errorMessage("%s", err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		appDao := dao.New(dbManager.DataDb)
+
+		// The argument is either a course path or a course ID
+		where := squirrel.Eq{models.COURSE_TABLE_ID: args[0]}
+		if exists, _ := appfs.DirOrArchiveExists(appFs.Fs, args[0]); exists {
+			path, err := filepath.Abs(args[0])
+			if err != nil {
+				errorMessage("Invalid path: %s", err)
+				os.Exit(1)
+			}
+
+			where = squirrel.Eq{models.COURSE_TABLE_PATH: utils.NormalizeWindowsDrive(path)}
+		}
+
+		course, err := appDao.GetCourse(ctx, dao.NewOptions().WithWhere(where))
+		if err != nil {
+			errorMessage("Failed to look up course: %s", err)
+			os.Exit(1)
+		}
+
+		if course == nil {
+			errorMessage("Course not found")
+			os.Exit(1)
+		}
+
+		courseScan := coursescan.New(&coursescan.CourseScanConfig{
+			Db:     dbManager.DataDb,
+			AppFs:  appFs,
+			Logger: logger.New(&logger.Config{Level: logger.LevelError, ConsoleOutput: true}).WithCourseScan(),
+		})
+
+		plan, err := courseScan.DryRun(ctx, course.ID)
+		if err != nil {
+			if errors.Is(err, coursescan.ErrCourseUnavailable) {
+				errorMessage("Course path '%s' does not exist", course.Path)
+			} else {
+				errorMessage("Failed to run dry-run scan: %s", err)
+			}
+			os.Exit(1)
+		}
+
+		out, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			errorMessage("Failed to encode plan: %s", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(out))
+	},
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func init() {
+	scanCmd.AddCommand(scanDryRunCmd)
+}
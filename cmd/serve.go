@@ -32,6 +32,14 @@ var serveCmd = &cobra.Command{
 		dataDir := viper.GetString("data-dir")
 		enableSignup := viper.GetBool("enable-signup")
 		isDebug := viper.GetBool("debug")
+		enableWatch := viper.GetBool("watch")
+		scanWorkers := viper.GetInt("scan-workers")
+		scanProbes := viper.GetInt("scan-probes")
+		pretranscodeWindow := viper.GetString("pretranscode-window")
+		hlsCacheMaxSize := viper.GetString("hls-cache-max-size")
+		hlsCacheMaxAge := viper.GetDuration("hls-cache-max-age")
+		archiveCacheMaxSize := viper.GetString("archive-cache-max-size")
+		archiveCacheMaxAge := viper.GetDuration("archive-cache-max-age")
 
 		// Create app with all dependencies
 		application, err := app.New(ctx, &app.Config{
@@ -40,6 +48,15 @@ var serveCmd = &cobra.Command{
 			IsDev:        isDev,
 			EnableSignup: enableSignup,
 			IsDebug:      isDebug,
+			EnableWatch:  enableWatch,
+			ScanWorkers:  scanWorkers,
+			ScanProbes:   scanProbes,
+
+			PretranscodeWindow:  pretranscodeWindow,
+			HlsCacheMaxSize:     hlsCacheMaxSize,
+			HlsCacheMaxAge:      hlsCacheMaxAge,
+			ArchiveCacheMaxSize: archiveCacheMaxSize,
+			ArchiveCacheMaxAge:  archiveCacheMaxAge,
 		})
 
 		if err != nil {
@@ -58,6 +75,22 @@ var serveCmd = &cobra.Command{
 		// Start the course scan worker
 		go application.CourseScan.Worker(ctx, coursescan.Processor)
 
+		// Start the thumbnails worker
+		go application.Thumbnails.Worker(ctx)
+
+		// Start the pre-transcode worker
+		go application.Pretranscoder.Worker(ctx)
+
+		// Start the HLS cache worker
+		go application.Transcoder.Cache().Worker(ctx)
+
+		// Start the course watcher
+		if application.Config.EnableWatch {
+			if err := application.CourseWatch.Start(ctx); err != nil {
+				appLogger.Warn().Err(err).Msg("Failed to start course watcher, courses will not be rescanned automatically")
+			}
+		}
+
 		// Start cron
 		cron.StartCron(application)
 
@@ -123,6 +156,14 @@ func init() {
 	serveCmd.Flags().String("data-dir", "./oc_data", "Directory to store data files")
 	serveCmd.Flags().Bool("enable-signup", false, "Allow users to create new accounts")
 	serveCmd.Flags().Bool("debug", false, "Enable debug logging")
+	serveCmd.Flags().Bool("watch", true, "Watch course folders and rescan them when they change")
+	serveCmd.Flags().Int("scan-workers", coursescan.DefaultWorkers, "Number of course scans to run at the same time")
+	serveCmd.Flags().Int("scan-probes", coursescan.DefaultMaxProbes, "Number of videos to probe at the same time, across all scans")
+	serveCmd.Flags().String("pretranscode-window", "", "Time of day to run queued pre-transcodes, such as 01:00-06:00 (default any time)")
+	serveCmd.Flags().String("hls-cache-max-size", "", "Max size of the HLS cache, such as 20GB (default unlimited)")
+	serveCmd.Flags().Duration("hls-cache-max-age", 0, "Remove transcoded segments not watched within this time, such as 72h (default never)")
+	serveCmd.Flags().String("archive-cache-max-size", "", "Max size of the files extracted from course archives, such as 20GB (default unlimited)")
+	serveCmd.Flags().Duration("archive-cache-max-age", 0, "Remove files extracted from course archives not used within this time, such as 72h (default never)")
 
 	// Bind flags
 	viper.SetEnvPrefix("OC")
@@ -135,4 +176,12 @@ func init() {
 	_ = viper.BindPFlag("data-dir", serveCmd.Flags().Lookup("data-dir"))
 	_ = viper.BindPFlag("enable-signup", serveCmd.Flags().Lookup("enable-signup"))
 	_ = viper.BindPFlag("debug", serveCmd.Flags().Lookup("debug"))
+	_ = viper.BindPFlag("watch", serveCmd.Flags().Lookup("watch"))
+	_ = viper.BindPFlag("scan-workers", serveCmd.Flags().Lookup("scan-workers"))
+	_ = viper.BindPFlag("scan-probes", serveCmd.Flags().Lookup("scan-probes"))
+	_ = viper.BindPFlag("pretranscode-window", serveCmd.Flags().Lookup("pretranscode-window"))
+	_ = viper.BindPFlag("hls-cache-max-size", serveCmd.Flags().Lookup("hls-cache-max-size"))
+	_ = viper.BindPFlag("hls-cache-max-age", serveCmd.Flags().Lookup("hls-cache-max-age"))
+	_ = viper.BindPFlag("archive-cache-max-size", serveCmd.Flags().Lookup("archive-cache-max-size"))
+	_ = viper.BindPFlag("archive-cache-max-age", serveCmd.Flags().Lookup("archive-cache-max-age"))
 }
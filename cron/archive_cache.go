@@ -0,0 +1,31 @@
+package cron
+
+import (
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/logger"
+)
+
+type archiveCache struct {
+	appFs  *appfs.AppFs
+	logger *logger.Logger
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func (ac *archiveCache) run() error {
+	result, err := ac.appFs.EnforceArchiveCache()
+	if err != nil {
+		ac.logger.Error().Err(err).Msg("Failed to enforce archive cache limits")
+		return err
+	}
+
+	if result.Evicted > 0 {
+		ac.logger.Info().
+			Int("evicted", result.Evicted).
+			Int64("freed", result.Freed).
+			Int64("size", result.Size).
+			Msg("Evicted files extracted from course archives")
+	}
+
+	return nil
+}
@@ -34,6 +34,25 @@ func StartCron(app *app.App) {
 
 	c.AddFunc("@every 5m", func() { ca.run() })
 
+	// Library discovery
+	ld := &libraryDiscovery{
+		discovery: app.CourseDiscovery,
+		logger:    app.Logger.WithCron(),
+	}
+
+	// When cron is started, run the library discovery job immediately
+	go func() { ld.run() }()
+
+	c.AddFunc("@every 30m", func() { ld.run() })
+
+	// Archive cache
+	ac := &archiveCache{
+		appFs:  app.AppFs,
+		logger: app.Logger.WithCron(),
+	}
+
+	c.AddFunc("@every 5m", func() { ac.run() })
+
 	// Release checker
 	ReleaseChecker = &releaseChecker{
 		logger:     app.Logger.WithCron(),
@@ -0,0 +1,32 @@
+package cron
+
+import (
+	"context"
+
+	"github.com/geerew/off-course/utils/coursediscovery"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/geerew/off-course/utils/types"
+)
+
+type libraryDiscovery struct {
+	discovery *coursediscovery.CourseDiscovery
+	logger    *logger.Logger
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func (ld *libraryDiscovery) run() error {
+	// Create an admin principal context for the cron job
+	principal := types.Principal{
+		UserID: "library-discovery-cron",
+		Role:   types.UserRoleAdmin,
+	}
+	ctx := context.WithValue(context.Background(), types.PrincipalContextKey, principal)
+
+	if err := ld.discovery.DiscoverAll(ctx); err != nil {
+		ld.logger.Error().Err(err).Msg("Failed to discover library roots")
+		return err
+	}
+
+	return nil
+}
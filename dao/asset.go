@@ -137,7 +137,15 @@ func (dao *DAO) GetAsset(ctx context.Context, dbOpts *Options) (*models.Asset, e
 	}
 
 	// Map to domain
-	return row.ToDomain(includeProgress, includeMetadata), nil
+	asset := row.ToDomain(includeProgress, includeMetadata)
+
+	if includeMetadata {
+		if err := dao.attachChapterMetadata(ctx, []*models.Asset{asset}); err != nil {
+			return nil, err
+		}
+	}
+
+	return asset, nil
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -222,11 +230,48 @@ func (dao *DAO) ListAssets(ctx context.Context, dbOpts *Options) ([]*models.Asse
 		records = append(records, rows[i].ToDomain(includeProgress, includeMetadata))
 	}
 
+	if includeMetadata {
+		if err := dao.attachChapterMetadata(ctx, records); err != nil {
+			return nil, err
+		}
+	}
+
 	return records, nil
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// attachChapterMetadata sets the chapters on the metadata of the assets. Chapters are not
+// joined as an asset has many, so they are fetched for all assets in one query
+func (dao *DAO) attachChapterMetadata(ctx context.Context, assets []*models.Asset) error {
+	ids := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		ids = append(ids, asset.ID)
+	}
+
+	chapters, err := dao.ListChapterMetadata(ctx, NewOptions().
+		WithWhere(squirrel.Eq{models.MEDIA_CHAPTER_TABLE_ASSET_ID: ids}).
+		WithOrderBy(models.MEDIA_CHAPTER_TABLE_ASSET_ID+" asc", models.MEDIA_CHAPTER_TABLE_CHAPTER_INDEX+" asc"))
+	if err != nil {
+		return err
+	}
+
+	chapterMap := make(map[string][]*models.ChapterMetadata)
+	for _, chapter := range chapters {
+		chapterMap[chapter.AssetID] = append(chapterMap[chapter.AssetID], chapter)
+	}
+
+	for _, asset := range assets {
+		if asset.AssetMetadata != nil {
+			asset.AssetMetadata.ChapterMetadata = chapterMap[asset.ID]
+		}
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // UpdateAsset updates an asset record
 func (dao *DAO) UpdateAsset(ctx context.Context, asset *models.Asset) error {
 	if err := assetValidation(asset); err != nil {
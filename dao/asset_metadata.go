@@ -8,6 +8,7 @@ import (
 	"github.com/geerew/off-course/database"
 	"github.com/geerew/off-course/models"
 	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/types"
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -19,7 +20,9 @@ func (dao *DAO) CreateAssetMetadata(ctx context.Context, metadata *models.AssetM
 	}
 
 	// Nothing to do
-	if metadata.VideoMetadata == nil && metadata.AudioMetadata == nil {
+	if metadata.VideoMetadata == nil && metadata.AudioMetadata == nil &&
+		len(metadata.SubtitleMetadata) == 0 && len(metadata.AudioTrackMetadata) == 0 &&
+		len(metadata.ChapterMetadata) == 0 {
 		return nil
 	}
 
@@ -38,20 +41,21 @@ func (dao *DAO) CreateAssetMetadata(ctx context.Context, metadata *models.AssetM
 			builderOpts := newBuilderOptions(models.MEDIA_VIDEO_TABLE).
 				WithData(
 					map[string]interface{}{
-						models.BASE_ID:                 vm.ID,
-						models.META_ASSET_ID:           metadata.AssetID,
-						models.MEDIA_VIDEO_DURATION:    vm.DurationSec,
-						models.MEDIA_VIDEO_CONTAINER:   vm.Container,
-						models.MEDIA_VIDEO_MIME_TYPE:   vm.MIMEType,
-						models.MEDIA_VIDEO_SIZE_BYTES:  vm.SizeBytes,
-						models.MEDIA_VIDEO_OVERALL_BPS: vm.OverallBPS,
-						models.MEDIA_VIDEO_CODEC:       vm.VideoCodec,
-						models.MEDIA_VIDEO_WIDTH:       vm.Width,
-						models.MEDIA_VIDEO_HEIGHT:      vm.Height,
-						models.MEDIA_VIDEO_FPS_NUM:     vm.FPSNum,
-						models.MEDIA_VIDEO_FPS_DEN:     vm.FPSDen,
-						models.BASE_CREATED_AT:         vm.CreatedAt,
-						models.BASE_UPDATED_AT:         vm.UpdatedAt,
+						models.BASE_ID:                   vm.ID,
+						models.META_ASSET_ID:             metadata.AssetID,
+						models.MEDIA_VIDEO_DURATION:      vm.DurationSec,
+						models.MEDIA_VIDEO_CONTAINER:     vm.Container,
+						models.MEDIA_VIDEO_MIME_TYPE:     vm.MIMEType,
+						models.MEDIA_VIDEO_SIZE_BYTES:    vm.SizeBytes,
+						models.MEDIA_VIDEO_OVERALL_BPS:   vm.OverallBPS,
+						models.MEDIA_VIDEO_CODEC:         vm.VideoCodec,
+						models.MEDIA_VIDEO_WIDTH:         vm.Width,
+						models.MEDIA_VIDEO_HEIGHT:        vm.Height,
+						models.MEDIA_VIDEO_FPS_NUM:       vm.FPSNum,
+						models.MEDIA_VIDEO_FPS_DEN:       vm.FPSDen,
+						models.MEDIA_VIDEO_PROBE_VERSION: vm.ProbeVersion,
+						models.BASE_CREATED_AT:           vm.CreatedAt,
+						models.BASE_UPDATED_AT:           vm.UpdatedAt,
 					})
 
 			err := createGeneric(txCtx, dao, *builderOpts)
@@ -83,6 +87,13 @@ func (dao *DAO) CreateAssetMetadata(ctx context.Context, metadata *models.AssetM
 						models.MEDIA_AUDIO_CHANNEL_LAYOUT: am.ChannelLayout,
 						models.MEDIA_AUDIO_SAMPLE_RATE:    am.SampleRate,
 						models.MEDIA_AUDIO_BIT_RATE:       am.BitRate,
+						models.MEDIA_AUDIO_DURATION:       am.DurationSec,
+						models.MEDIA_AUDIO_TITLE:          am.Title,
+						models.MEDIA_AUDIO_ARTIST:         am.Artist,
+						models.MEDIA_AUDIO_ALBUM:          am.Album,
+						models.MEDIA_AUDIO_HAS_COVER_ART:  am.HasCoverArt,
+						models.MEDIA_AUDIO_LOUDNESS_LUFS:  am.LoudnessLUFS,
+						models.MEDIA_AUDIO_PROBE_VERSION:  am.ProbeVersion,
 						models.BASE_CREATED_AT:            am.CreatedAt,
 						models.BASE_UPDATED_AT:            am.UpdatedAt,
 					})
@@ -93,6 +104,100 @@ func (dao *DAO) CreateAssetMetadata(ctx context.Context, metadata *models.AssetM
 			}
 		}
 
+		// Create subtitle metadata
+		for _, sm := range metadata.SubtitleMetadata {
+			if sm.ID == "" {
+				sm.RefreshId()
+			}
+
+			sm.RefreshCreatedAt()
+			sm.RefreshUpdatedAt()
+			sm.AssetID = metadata.AssetID
+
+			builderOpts := newBuilderOptions(models.MEDIA_SUBTITLE_TABLE).
+				WithData(
+					map[string]interface{}{
+						models.BASE_ID:                     sm.ID,
+						models.META_ASSET_ID:               sm.AssetID,
+						models.MEDIA_SUBTITLE_STREAM_INDEX: sm.StreamIndex,
+						models.MEDIA_SUBTITLE_LANGUAGE:     sm.Language,
+						models.MEDIA_SUBTITLE_TITLE:        sm.Title,
+						models.MEDIA_SUBTITLE_CODEC:        sm.Codec,
+						models.MEDIA_SUBTITLE_IS_DEFAULT:   sm.IsDefault,
+						models.MEDIA_SUBTITLE_IS_FORCED:    sm.IsForced,
+						models.BASE_CREATED_AT:             sm.CreatedAt,
+						models.BASE_UPDATED_AT:             sm.UpdatedAt,
+					})
+
+			err := createGeneric(txCtx, dao, *builderOpts)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Create audio track metadata
+		for _, tm := range metadata.AudioTrackMetadata {
+			if tm.ID == "" {
+				tm.RefreshId()
+			}
+
+			tm.RefreshCreatedAt()
+			tm.RefreshUpdatedAt()
+			tm.AssetID = metadata.AssetID
+
+			builderOpts := newBuilderOptions(models.MEDIA_AUDIO_TRACK_TABLE).
+				WithData(
+					map[string]interface{}{
+						models.BASE_ID:                          tm.ID,
+						models.META_ASSET_ID:                    tm.AssetID,
+						models.MEDIA_AUDIO_TRACK_STREAM_INDEX:   tm.StreamIndex,
+						models.MEDIA_AUDIO_TRACK_TRACK_INDEX:    tm.TrackIndex,
+						models.MEDIA_AUDIO_TRACK_LANGUAGE:       tm.Language,
+						models.MEDIA_AUDIO_TRACK_TITLE:          tm.Title,
+						models.MEDIA_AUDIO_TRACK_CODEC:          tm.Codec,
+						models.MEDIA_AUDIO_TRACK_CHANNELS:       tm.Channels,
+						models.MEDIA_AUDIO_TRACK_CHANNEL_LAYOUT: tm.ChannelLayout,
+						models.MEDIA_AUDIO_TRACK_IS_DEFAULT:     tm.IsDefault,
+						models.MEDIA_AUDIO_TRACK_LOUDNESS_LUFS:  tm.LoudnessLUFS,
+						models.BASE_CREATED_AT:                  tm.CreatedAt,
+						models.BASE_UPDATED_AT:                  tm.UpdatedAt,
+					})
+
+			err := createGeneric(txCtx, dao, *builderOpts)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Create chapter metadata
+		for _, cm := range metadata.ChapterMetadata {
+			if cm.ID == "" {
+				cm.RefreshId()
+			}
+
+			cm.RefreshCreatedAt()
+			cm.RefreshUpdatedAt()
+			cm.AssetID = metadata.AssetID
+
+			builderOpts := newBuilderOptions(models.MEDIA_CHAPTER_TABLE).
+				WithData(
+					map[string]interface{}{
+						models.BASE_ID:                     cm.ID,
+						models.META_ASSET_ID:               cm.AssetID,
+						models.MEDIA_CHAPTER_CHAPTER_INDEX: cm.ChapterIndex,
+						models.MEDIA_CHAPTER_START_SEC:     cm.StartSec,
+						models.MEDIA_CHAPTER_END_SEC:       cm.EndSec,
+						models.MEDIA_CHAPTER_TITLE:         cm.Title,
+						models.BASE_CREATED_AT:             cm.CreatedAt,
+						models.BASE_UPDATED_AT:             cm.UpdatedAt,
+					})
+
+			err := createGeneric(txCtx, dao, *builderOpts)
+			if err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 }
@@ -127,7 +232,72 @@ func (dao *DAO) GetAssetMetadata(ctx context.Context, assetID string) (*models.A
 		return nil, nil
 	}
 
-	return row.ToDomain(), nil
+	metadata := row.ToDomain()
+
+	subtitles, err := dao.ListSubtitleMetadata(ctx, NewOptions().
+		WithWhere(squirrel.Eq{models.MEDIA_SUBTITLE_TABLE_ASSET_ID: assetID}).
+		WithOrderBy(models.MEDIA_SUBTITLE_TABLE_STREAM_INDEX+" asc"))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.SubtitleMetadata = subtitles
+
+	audioTracks, err := dao.ListAudioTrackMetadata(ctx, NewOptions().
+		WithWhere(squirrel.Eq{models.MEDIA_AUDIO_TRACK_TABLE_ASSET_ID: assetID}).
+		WithOrderBy(models.MEDIA_AUDIO_TRACK_TABLE_TRACK_INDEX+" asc"))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.AudioTrackMetadata = audioTracks
+
+	chapters, err := dao.ListChapterMetadata(ctx, NewOptions().
+		WithWhere(squirrel.Eq{models.MEDIA_CHAPTER_TABLE_ASSET_ID: assetID}).
+		WithOrderBy(models.MEDIA_CHAPTER_TABLE_CHAPTER_INDEX+" asc"))
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.ChapterMetadata = chapters
+
+	return metadata, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ListSubtitleMetadata gets all records from the subtitle metadata table based upon the where
+// clause and pagination in the options
+func (dao *DAO) ListSubtitleMetadata(ctx context.Context, dbOpts *Options) ([]*models.SubtitleMetadata, error) {
+	builderOpts := newBuilderOptions(models.MEDIA_SUBTITLE_TABLE).
+		WithColumns(models.SubtitleMetadataColumns()...).
+		SetDbOpts(dbOpts)
+
+	return listGeneric[models.SubtitleMetadata](ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ListAudioTrackMetadata gets all records from the audio track metadata table based upon the
+// where clause and pagination in the options
+func (dao *DAO) ListAudioTrackMetadata(ctx context.Context, dbOpts *Options) ([]*models.AudioTrackMetadata, error) {
+	builderOpts := newBuilderOptions(models.MEDIA_AUDIO_TRACK_TABLE).
+		WithColumns(models.AudioTrackMetadataColumns()...).
+		SetDbOpts(dbOpts)
+
+	return listGeneric[models.AudioTrackMetadata](ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ListChapterMetadata gets all records from the chapter metadata table based upon the where
+// clause and pagination in the options
+func (dao *DAO) ListChapterMetadata(ctx context.Context, dbOpts *Options) ([]*models.ChapterMetadata, error) {
+	builderOpts := newBuilderOptions(models.MEDIA_CHAPTER_TABLE).
+		WithColumns(models.ChapterMetadataColumns()...).
+		SetDbOpts(dbOpts)
+
+	return listGeneric[models.ChapterMetadata](ctx, dao, *builderOpts)
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -197,17 +367,18 @@ func (dao *DAO) UpdateAssetMetadata(ctx context.Context, metadata *models.AssetM
 
 			builder := newBuilderOptions(models.MEDIA_VIDEO_TABLE).
 				WithData(map[string]interface{}{
-					models.MEDIA_VIDEO_DURATION:    vm.DurationSec,
-					models.MEDIA_VIDEO_CONTAINER:   vm.Container,
-					models.MEDIA_VIDEO_MIME_TYPE:   vm.MIMEType,
-					models.MEDIA_VIDEO_SIZE_BYTES:  vm.SizeBytes,
-					models.MEDIA_VIDEO_OVERALL_BPS: vm.OverallBPS,
-					models.MEDIA_VIDEO_CODEC:       vm.VideoCodec,
-					models.MEDIA_VIDEO_WIDTH:       vm.Width,
-					models.MEDIA_VIDEO_HEIGHT:      vm.Height,
-					models.MEDIA_VIDEO_FPS_NUM:     vm.FPSNum,
-					models.MEDIA_VIDEO_FPS_DEN:     vm.FPSDen,
-					models.BASE_UPDATED_AT:         vm.UpdatedAt,
+					models.MEDIA_VIDEO_DURATION:      vm.DurationSec,
+					models.MEDIA_VIDEO_CONTAINER:     vm.Container,
+					models.MEDIA_VIDEO_MIME_TYPE:     vm.MIMEType,
+					models.MEDIA_VIDEO_SIZE_BYTES:    vm.SizeBytes,
+					models.MEDIA_VIDEO_OVERALL_BPS:   vm.OverallBPS,
+					models.MEDIA_VIDEO_CODEC:         vm.VideoCodec,
+					models.MEDIA_VIDEO_WIDTH:         vm.Width,
+					models.MEDIA_VIDEO_HEIGHT:        vm.Height,
+					models.MEDIA_VIDEO_FPS_NUM:       vm.FPSNum,
+					models.MEDIA_VIDEO_FPS_DEN:       vm.FPSDen,
+					models.MEDIA_VIDEO_PROBE_VERSION: vm.ProbeVersion,
+					models.BASE_UPDATED_AT:           vm.UpdatedAt,
 				}).
 				SetDbOpts(dbOpts)
 
@@ -232,6 +403,13 @@ func (dao *DAO) UpdateAssetMetadata(ctx context.Context, metadata *models.AssetM
 					models.MEDIA_AUDIO_CHANNEL_LAYOUT: am.ChannelLayout,
 					models.MEDIA_AUDIO_SAMPLE_RATE:    am.SampleRate,
 					models.MEDIA_AUDIO_BIT_RATE:       am.BitRate,
+					models.MEDIA_AUDIO_DURATION:       am.DurationSec,
+					models.MEDIA_AUDIO_TITLE:          am.Title,
+					models.MEDIA_AUDIO_ARTIST:         am.Artist,
+					models.MEDIA_AUDIO_ALBUM:          am.Album,
+					models.MEDIA_AUDIO_HAS_COVER_ART:  am.HasCoverArt,
+					models.MEDIA_AUDIO_LOUDNESS_LUFS:  am.LoudnessLUFS,
+					models.MEDIA_AUDIO_PROBE_VERSION:  am.ProbeVersion,
 					models.BASE_UPDATED_AT:            am.UpdatedAt,
 				}).
 				SetDbOpts(dbOpts)
@@ -247,8 +425,55 @@ func (dao *DAO) UpdateAssetMetadata(ctx context.Context, metadata *models.AssetM
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// DeleteAssetMetadataByAssetIDs deletes records from the video and audio metadata tables
-// for the given asset IDs
+// UpdateAudioLoudness sets the measured loudness on the audio metadata of an asset. Returns
+// false when the asset has no audio metadata
+func (dao *DAO) UpdateAudioLoudness(ctx context.Context, assetID string, lufs float64) (bool, error) {
+	if assetID == "" {
+		return false, utils.ErrId
+	}
+
+	dbOpts := NewOptions().
+		WithWhere(squirrel.Eq{models.MEDIA_AUDIO_TABLE_ASSET_ID: assetID})
+
+	builder := newBuilderOptions(models.MEDIA_AUDIO_TABLE).
+		WithData(map[string]interface{}{
+			models.MEDIA_AUDIO_LOUDNESS_LUFS: lufs,
+			models.BASE_UPDATED_AT:           types.NowDateTime(),
+		}).
+		SetDbOpts(dbOpts)
+
+	return updateGeneric(ctx, dao, *builder)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// UpdateAudioTrackLoudness sets the measured loudness on an audio track of an asset. Returns
+// false when the asset has no such track
+func (dao *DAO) UpdateAudioTrackLoudness(ctx context.Context, assetID string, trackIndex int, lufs float64) (bool, error) {
+	if assetID == "" {
+		return false, utils.ErrId
+	}
+
+	dbOpts := NewOptions().
+		WithWhere(squirrel.Eq{
+			models.MEDIA_AUDIO_TRACK_TABLE_ASSET_ID:    assetID,
+			models.MEDIA_AUDIO_TRACK_TABLE_TRACK_INDEX: trackIndex,
+		})
+
+	builder := newBuilderOptions(models.MEDIA_AUDIO_TRACK_TABLE).
+		WithData(map[string]interface{}{
+			models.MEDIA_AUDIO_TRACK_LOUDNESS_LUFS: lufs,
+			models.BASE_UPDATED_AT:                 types.NowDateTime(),
+		}).
+		SetDbOpts(dbOpts)
+
+	return updateGeneric(ctx, dao, *builder)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DeleteAssetMetadataByAssetIDs deletes records from the video, audio, audio track, subtitle and
+// chapter metadata tables for the given asset IDs
 func (dao *DAO) DeleteAssetMetadataByAssetIDs(ctx context.Context, assetIDs ...string) error {
 	ids := sanitizeIDs(assetIDs)
 	if len(ids) == 0 {
@@ -267,8 +492,28 @@ func (dao *DAO) DeleteAssetMetadataByAssetIDs(ctx context.Context, assetIDs ...s
 			return err
 		}
 
-		// Video metadata
+		// Audio track metadata
+		builder = newBuilderOptions(models.MEDIA_AUDIO_TRACK_TABLE).SetDbOpts(dbOpts)
+		sqlStr, args, _ = deleteBuilder(*builder)
+		if _, err := q.ExecContext(txCtx, sqlStr, args...); err != nil {
+			return err
+		}
+
+		// Subtitle metadata
+		builder = newBuilderOptions(models.MEDIA_SUBTITLE_TABLE).SetDbOpts(dbOpts)
+		sqlStr, args, _ = deleteBuilder(*builder)
+		if _, err := q.ExecContext(txCtx, sqlStr, args...); err != nil {
+			return err
+		}
 
+		// Chapter metadata
+		builder = newBuilderOptions(models.MEDIA_CHAPTER_TABLE).SetDbOpts(dbOpts)
+		sqlStr, args, _ = deleteBuilder(*builder)
+		if _, err := q.ExecContext(txCtx, sqlStr, args...); err != nil {
+			return err
+		}
+
+		// Video metadata
 		builder = newBuilderOptions(models.MEDIA_VIDEO_TABLE).SetDbOpts(dbOpts)
 		sqlStr, args, _ = deleteBuilder(*builder)
 		if _, err := q.ExecContext(txCtx, sqlStr, args...); err != nil {
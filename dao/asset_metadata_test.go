@@ -125,16 +125,17 @@ func Test_CreateVideoMetadata(t *testing.T) {
 		meta := &models.AssetMetadata{
 			AssetID: asset.ID,
 			VideoMetadata: &models.VideoMetadata{
-				DurationSec: 120,
-				Container:   "mov,mp4,m4a,3gp,3g2,mj2",
-				MIMEType:    "video/mp4",
-				SizeBytes:   1024,
-				OverallBPS:  200000,
-				VideoCodec:  "h264",
-				Width:       1280,
-				Height:      720,
-				FPSNum:      30,
-				FPSDen:      1,
+				DurationSec:  120,
+				Container:    "mov,mp4,m4a,3gp,3g2,mj2",
+				MIMEType:     "video/mp4",
+				SizeBytes:    1024,
+				OverallBPS:   200000,
+				VideoCodec:   "h264",
+				Width:        1280,
+				Height:       720,
+				FPSNum:       30,
+				FPSDen:       1,
+				ProbeVersion: 1,
 			},
 			AudioMetadata: nil,
 		}
@@ -153,6 +154,122 @@ func Test_CreateVideoMetadata(t *testing.T) {
 		require.Equal(t, 720, record.VideoMetadata.Height)
 		require.Equal(t, 30, record.VideoMetadata.FPSNum)
 		require.Equal(t, 1, record.VideoMetadata.FPSDen)
+		require.Equal(t, 1, record.VideoMetadata.ProbeVersion)
+	})
+
+	t.Run("success (subtitles)", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		assets, _ := helper_createAssetMetadata(t, ctx, dao, 1)
+
+		// Subtitles only, as the video and audio rows already exist
+		meta := &models.AssetMetadata{
+			AssetID: assets[0].ID,
+			SubtitleMetadata: []*models.SubtitleMetadata{
+				{StreamIndex: 4, Language: "fre", Codec: "subrip", IsForced: true},
+				{StreamIndex: 2, Language: "eng", Title: "English", Codec: "mov_text", IsDefault: true},
+			},
+		}
+		require.NoError(t, dao.CreateAssetMetadata(ctx, meta))
+
+		record, err := dao.GetAssetMetadata(ctx, assets[0].ID)
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		require.Len(t, record.SubtitleMetadata, 2)
+
+		require.Equal(t, assets[0].ID, record.SubtitleMetadata[0].AssetID)
+		require.Equal(t, 2, record.SubtitleMetadata[0].StreamIndex)
+		require.Equal(t, "eng", record.SubtitleMetadata[0].Language)
+		require.Equal(t, "English", record.SubtitleMetadata[0].Title)
+		require.Equal(t, "mov_text", record.SubtitleMetadata[0].Codec)
+		require.True(t, record.SubtitleMetadata[0].IsDefault)
+		require.False(t, record.SubtitleMetadata[0].IsForced)
+
+		require.Equal(t, 4, record.SubtitleMetadata[1].StreamIndex)
+		require.True(t, record.SubtitleMetadata[1].IsForced)
+
+		// Stream indexes are unique per asset
+		dup := &models.AssetMetadata{
+			AssetID:          assets[0].ID,
+			SubtitleMetadata: []*models.SubtitleMetadata{{StreamIndex: 2, Codec: "ass"}},
+		}
+		require.ErrorContains(t, dao.CreateAssetMetadata(ctx, dup), "UNIQUE constraint failed")
+	})
+
+	t.Run("success (audio tracks)", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		assets, _ := helper_createAssetMetadata(t, ctx, dao, 1)
+
+		meta := &models.AssetMetadata{
+			AssetID: assets[0].ID,
+			AudioTrackMetadata: []*models.AudioTrackMetadata{
+				{StreamIndex: 2, TrackIndex: 1, Language: "fre", Codec: "eac3", Channels: 6, ChannelLayout: "5.1(side)"},
+				{StreamIndex: 1, TrackIndex: 0, Language: "eng", Title: "English", Codec: "aac", Channels: 2, IsDefault: true},
+			},
+		}
+		require.NoError(t, dao.CreateAssetMetadata(ctx, meta))
+
+		record, err := dao.GetAssetMetadata(ctx, assets[0].ID)
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		require.Len(t, record.AudioTrackMetadata, 2)
+
+		require.Equal(t, assets[0].ID, record.AudioTrackMetadata[0].AssetID)
+		require.Equal(t, 0, record.AudioTrackMetadata[0].TrackIndex)
+		require.Equal(t, 1, record.AudioTrackMetadata[0].StreamIndex)
+		require.Equal(t, "eng", record.AudioTrackMetadata[0].Language)
+		require.Equal(t, "English", record.AudioTrackMetadata[0].Title)
+		require.Equal(t, 2, record.AudioTrackMetadata[0].Channels)
+		require.True(t, record.AudioTrackMetadata[0].IsDefault)
+
+		require.Equal(t, "eac3", record.AudioTrackMetadata[1].Codec)
+		require.Equal(t, 6, record.AudioTrackMetadata[1].Channels)
+		require.Equal(t, "5.1(side)", record.AudioTrackMetadata[1].ChannelLayout)
+		require.False(t, record.AudioTrackMetadata[1].IsDefault)
+
+		// Track indexes are unique per asset
+		dup := &models.AssetMetadata{
+			AssetID:            assets[0].ID,
+			AudioTrackMetadata: []*models.AudioTrackMetadata{{StreamIndex: 3, TrackIndex: 1, Codec: "aac"}},
+		}
+		require.ErrorContains(t, dao.CreateAssetMetadata(ctx, dup), "UNIQUE constraint failed")
+	})
+
+	t.Run("success (chapters)", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		assets, _ := helper_createAssetMetadata(t, ctx, dao, 1)
+
+		meta := &models.AssetMetadata{
+			AssetID: assets[0].ID,
+			ChapterMetadata: []*models.ChapterMetadata{
+				{ChapterIndex: 1, StartSec: 62.5, EndSec: 120, Title: "Setup"},
+				{ChapterIndex: 0, StartSec: 0, EndSec: 62.5, Title: "Introduction"},
+			},
+		}
+		require.NoError(t, dao.CreateAssetMetadata(ctx, meta))
+
+		record, err := dao.GetAssetMetadata(ctx, assets[0].ID)
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		require.Len(t, record.ChapterMetadata, 2)
+
+		require.Equal(t, assets[0].ID, record.ChapterMetadata[0].AssetID)
+		require.Equal(t, 0, record.ChapterMetadata[0].ChapterIndex)
+		require.Equal(t, "Introduction", record.ChapterMetadata[0].Title)
+		require.Equal(t, 62.5, record.ChapterMetadata[0].EndSec)
+
+		require.Equal(t, 62.5, record.ChapterMetadata[1].StartSec)
+		require.Equal(t, 120.0, record.ChapterMetadata[1].EndSec)
+		require.Equal(t, "Setup", record.ChapterMetadata[1].Title)
+
+		// Chapter indexes are unique per asset
+		dup := &models.AssetMetadata{
+			AssetID:         assets[0].ID,
+			ChapterMetadata: []*models.ChapterMetadata{{ChapterIndex: 1}},
+		}
+		require.ErrorContains(t, dao.CreateAssetMetadata(ctx, dup), "UNIQUE constraint failed")
 	})
 
 	t.Run("success (video + audio)", func(t *testing.T) {
@@ -221,6 +338,63 @@ func Test_CreateVideoMetadata(t *testing.T) {
 		require.GreaterOrEqual(t, record.AudioMetadata.Channels, 1)
 	})
 
+	t.Run("success (audio only)", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 3", Path: "/course-3"}
+		require.NoError(t, dao.CreateCourse(ctx, course))
+
+		lesson := &models.Lesson{
+			CourseID: course.ID,
+			Title:    "Episode 1",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+		}
+		require.NoError(t, dao.CreateLesson(ctx, lesson))
+
+		asset := &models.Asset{
+			CourseID: course.ID,
+			LessonID: lesson.ID,
+			Title:    "Episode 1",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+			Type:     types.MustAsset("mp3"),
+			Path:     filepath.ToSlash("/course-3/01 episode.mp3"),
+			FileSize: 4096,
+			ModTime:  time.Now().Format(time.RFC3339Nano),
+			Hash:     "9012",
+		}
+		require.NoError(t, dao.CreateAsset(ctx, asset))
+
+		meta := &models.AssetMetadata{
+			AssetID: asset.ID,
+			AudioMetadata: &models.AudioMetadata{
+				Codec:         "mp3",
+				Channels:      2,
+				ChannelLayout: "stereo",
+				SampleRate:    44100,
+				BitRate:       192000,
+				DurationSec:   1803,
+				Title:         "Episode 1",
+				Artist:        "Jane",
+				Album:         "Podcast",
+				HasCoverArt:   true,
+			},
+		}
+		require.NoError(t, dao.CreateAssetMetadata(ctx, meta))
+
+		record, err := dao.GetAssetMetadata(ctx, asset.ID)
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		require.Nil(t, record.VideoMetadata)
+		require.NotNil(t, record.AudioMetadata)
+
+		require.Equal(t, 1803, record.AudioMetadata.DurationSec)
+		require.Equal(t, "Episode 1", record.AudioMetadata.Title)
+		require.Equal(t, "Jane", record.AudioMetadata.Artist)
+		require.Equal(t, "Podcast", record.AudioMetadata.Album)
+		require.True(t, record.AudioMetadata.HasCoverArt)
+		require.Equal(t, 1803, record.DurationSec())
+	})
+
 	t.Run("nil input", func(t *testing.T) {
 		dao, ctx := setup(t)
 		require.ErrorIs(t, dao.CreateAssetMetadata(ctx, nil), utils.ErrNilPtr)
@@ -687,6 +861,48 @@ func Test_UpdateAssetMetadata(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func Test_UpdateAudioLoudness(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		assets, _ := helper_createAssetMetadata(t, ctx, dao, 1)
+
+		// Not measured
+		record, err := dao.GetAssetMetadata(ctx, assets[0].ID)
+		require.NoError(t, err)
+		require.False(t, record.AudioMetadata.LoudnessLUFS.Valid)
+
+		updated, err := dao.UpdateAudioLoudness(ctx, assets[0].ID, -19.5)
+		require.NoError(t, err)
+		require.True(t, updated)
+
+		record, err = dao.GetAssetMetadata(ctx, assets[0].ID)
+		require.NoError(t, err)
+		require.Equal(t, sql.NullFloat64{Float64: -19.5, Valid: true}, record.AudioMetadata.LoudnessLUFS)
+
+		// The other columns are untouched
+		require.Equal(t, "aac", record.AudioMetadata.Codec)
+		require.Equal(t, 48000, record.AudioMetadata.SampleRate)
+	})
+
+	t.Run("no audio metadata", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		updated, err := dao.UpdateAudioLoudness(ctx, "missing", -19.5)
+		require.NoError(t, err)
+		require.False(t, updated)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		_, err := dao.UpdateAudioLoudness(ctx, "", -19.5)
+		require.ErrorIs(t, err, utils.ErrId)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func Test_DeleteAssetMetadataByAssetIDs(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		dao, ctx := setup(t)
@@ -706,8 +922,30 @@ func Test_DeleteAssetMetadataByAssetIDs(t *testing.T) {
 		require.NotNil(t, record.VideoMetadata)
 		require.NotNil(t, record.AudioMetadata)
 
+		require.NoError(t, dao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:            assets[0].ID,
+			SubtitleMetadata:   []*models.SubtitleMetadata{{StreamIndex: 2, Codec: "subrip"}},
+			AudioTrackMetadata: []*models.AudioTrackMetadata{{StreamIndex: 1, Codec: "aac"}},
+			ChapterMetadata:    []*models.ChapterMetadata{{ChapterIndex: 0, EndSec: 10}},
+		}))
+
 		require.NoError(t, dao.DeleteAssetMetadataByAssetIDs(ctx, assets[0].ID, assets[1].ID))
 
+		subtitles, err := dao.ListSubtitleMetadata(ctx, NewOptions().
+			WithWhere(squirrel.Eq{models.MEDIA_SUBTITLE_TABLE_ASSET_ID: assets[0].ID}))
+		require.NoError(t, err)
+		require.Empty(t, subtitles)
+
+		audioTracks, err := dao.ListAudioTrackMetadata(ctx, NewOptions().
+			WithWhere(squirrel.Eq{models.MEDIA_AUDIO_TRACK_TABLE_ASSET_ID: assets[0].ID}))
+		require.NoError(t, err)
+		require.Empty(t, audioTracks)
+
+		chapters, err := dao.ListChapterMetadata(ctx, NewOptions().
+			WithWhere(squirrel.Eq{models.MEDIA_CHAPTER_TABLE_ASSET_ID: assets[0].ID}))
+		require.NoError(t, err)
+		require.Empty(t, chapters)
+
 		record, err = dao.GetAssetMetadata(ctx, assets[0].ID)
 		require.NoError(t, err)
 		require.Nil(t, record)
@@ -751,3 +989,49 @@ func Test_DeleteAssetMetadataByAssetIDs(t *testing.T) {
 		require.Nil(t, record)
 	})
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_UpdateAudioTrackLoudness(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		assets, _ := helper_createAssetMetadata(t, ctx, dao, 1)
+
+		require.NoError(t, dao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID: assets[0].ID,
+			AudioTrackMetadata: []*models.AudioTrackMetadata{
+				{StreamIndex: 1, TrackIndex: 0, Language: "eng", Codec: "aac", Channels: 2, IsDefault: true},
+				{StreamIndex: 2, TrackIndex: 1, Language: "fre", Codec: "aac", Channels: 2},
+			},
+		}))
+
+		updated, err := dao.UpdateAudioTrackLoudness(ctx, assets[0].ID, 1, -23.5)
+		require.NoError(t, err)
+		require.True(t, updated)
+
+		// Only the given track is measured
+		record, err := dao.GetAssetMetadata(ctx, assets[0].ID)
+		require.NoError(t, err)
+		require.False(t, record.AudioTrackMetadata[0].LoudnessLUFS.Valid)
+		require.Equal(t, sql.NullFloat64{Float64: -23.5, Valid: true}, record.AudioTrackMetadata[1].LoudnessLUFS)
+		require.Equal(t, "fre", record.AudioTrackMetadata[1].Language)
+	})
+
+	t.Run("no track", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		assets, _ := helper_createAssetMetadata(t, ctx, dao, 1)
+
+		updated, err := dao.UpdateAudioTrackLoudness(ctx, assets[0].ID, 3, -23.5)
+		require.NoError(t, err)
+		require.False(t, updated)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		_, err := dao.UpdateAudioTrackLoudness(ctx, "", 0, -23.5)
+		require.ErrorIs(t, err, utils.ErrId)
+	})
+}
@@ -218,7 +218,8 @@ ON CONFLICT(%s, %s) DO UPDATE SET
 // Computes progress_frac purely from server-side data.
 //   - completed = 1 => 1.0
 //   - if there is video metadata => position/duration clamped to 1.0
-//   - else (non-video or unknown duration) => 0.0
+//   - if there is audio metadata with a duration (audio assets) => position/duration clamped to 1.0
+//   - else (non-media or unknown duration) => 0.0
 func progressFracCaseExpr() squirrel.Sqlizer {
 	return squirrel.Expr(fmt.Sprintf(`
 CASE
@@ -236,6 +237,19 @@ CASE
       WHERE v2.%s = %s.%s
     ), 0)
   )
+  WHEN EXISTS (
+    SELECT 1
+    FROM %s a
+    WHERE a.%s = %s.%s AND a.%s > 0
+  )
+  THEN MIN(
+    1.0,
+    (1.0 * %s) / (
+      SELECT a2.%s
+      FROM %s a2
+      WHERE a2.%s = %s.%s
+    )
+  )
   ELSE 0.0
 END`,
 		// completed
@@ -252,5 +266,17 @@ END`,
 		models.MEDIA_VIDEO_DURATION,
 		models.MEDIA_VIDEO_TABLE,
 		models.META_ASSET_ID, models.ASSET_PROGRESS_TABLE, models.ASSET_PROGRESS_ASSET_ID,
+
+		// EXISTS asset_media_audio with a duration
+		models.MEDIA_AUDIO_TABLE,
+		models.META_ASSET_ID, models.ASSET_PROGRESS_TABLE, models.ASSET_PROGRESS_ASSET_ID, models.MEDIA_AUDIO_DURATION,
+
+		// numerator: position
+		models.ASSET_PROGRESS_POSITION,
+
+		// denominator: audio duration_sec subselect
+		models.MEDIA_AUDIO_DURATION,
+		models.MEDIA_AUDIO_TABLE,
+		models.META_ASSET_ID, models.ASSET_PROGRESS_TABLE, models.ASSET_PROGRESS_ASSET_ID,
 	))
 }
@@ -95,6 +95,68 @@ func Test_UpsertAssetProgress(t *testing.T) {
 		require.False(t, record.CompletedAt.IsZero())
 	})
 
+	t.Run("success (audio with duration)", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		// Course + lesson
+		course := &models.Course{Title: "Course 3", Path: "/course-3"}
+		require.NoError(t, dao.CreateCourse(ctx, course))
+
+		lesson := &models.Lesson{
+			CourseID: course.ID,
+			Title:    "L3",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+		}
+		require.NoError(t, dao.CreateLesson(ctx, lesson))
+
+		// Asset (audio)
+		asset := &models.Asset{
+			CourseID: course.ID,
+			LessonID: lesson.ID,
+			Title:    "Episode A",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+			Type:     types.MustAsset("mp3"),
+			Path:     "/course-3/01-episode-a.mp3",
+			FileSize: 1024,
+			ModTime:  time.Now().Format(time.RFC3339Nano),
+			Hash:     "hash-c",
+		}
+		require.NoError(t, dao.CreateAsset(ctx, asset))
+
+		// Attach audio metadata with duration=200s so pos=50 -> 0.25
+		meta := &models.AssetMetadata{
+			AssetID: asset.ID,
+			AudioMetadata: &models.AudioMetadata{
+				Codec:       "mp3",
+				Channels:    2,
+				SampleRate:  44100,
+				DurationSec: 200,
+			},
+		}
+		require.NoError(t, dao.CreateAssetMetadata(ctx, meta))
+
+		assetProgress := &models.AssetProgress{
+			AssetID:  asset.ID,
+			Position: 50,
+		}
+		require.NoError(t, dao.UpsertAssetProgress(ctx, assetProgress))
+
+		opts := NewOptions().WithWhere(squirrel.Eq{models.ASSET_PROGRESS_TABLE_ID: assetProgress.ID})
+		record, err := dao.GetAssetProgress(ctx, opts)
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		require.Equal(t, 50, record.Position)
+		require.InEpsilon(t, 0.25, record.ProgressFrac, 1e-9)
+
+		// Past the end is clamped
+		assetProgress.Position = 250
+		require.NoError(t, dao.UpsertAssetProgress(ctx, assetProgress))
+
+		record, err = dao.GetAssetProgress(ctx, opts)
+		require.NoError(t, err)
+		require.InEpsilon(t, 1.0, record.ProgressFrac, 1e-9)
+	})
+
 	t.Run("success (video without duration)", func(t *testing.T) {
 		dao, ctx := setup(t)
 
@@ -0,0 +1,130 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CreateAssetSubtitle inserts a new asset subtitle record
+func (dao *DAO) CreateAssetSubtitle(ctx context.Context, subtitle *models.AssetSubtitle) error {
+	if subtitle == nil {
+		return utils.ErrNilPtr
+	}
+
+	if subtitle.AssetID == "" {
+		return utils.ErrAssetId
+	}
+
+	if subtitle.Path == "" {
+		return utils.ErrPath
+	}
+
+	if subtitle.ID == "" {
+		subtitle.RefreshId()
+	}
+
+	subtitle.RefreshCreatedAt()
+	subtitle.RefreshUpdatedAt()
+
+	builderOpts := newBuilderOptions(models.ASSET_SUBTITLE_TABLE).
+		WithData(
+			map[string]interface{}{
+				models.BASE_ID:                 subtitle.ID,
+				models.ASSET_SUBTITLE_ASSET_ID: subtitle.AssetID,
+				models.ASSET_SUBTITLE_PATH:     subtitle.Path,
+				models.ASSET_SUBTITLE_LANGUAGE: subtitle.Language,
+				models.ASSET_SUBTITLE_FORMAT:   subtitle.Format,
+				models.BASE_CREATED_AT:         subtitle.CreatedAt,
+				models.BASE_UPDATED_AT:         subtitle.UpdatedAt,
+			},
+		)
+
+	return createGeneric(ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetAssetSubtitle gets a record from the asset subtitles table based upon the where clause in
+// the options. If there is no where clause, it will return the first record in the table
+func (dao *DAO) GetAssetSubtitle(ctx context.Context, dbOpts *Options) (*models.AssetSubtitle, error) {
+	builderOpts := newBuilderOptions(models.ASSET_SUBTITLE_TABLE).
+		WithColumns(models.AssetSubtitleColumns()...).
+		WithJoin(models.ASSET_TABLE, fmt.Sprintf("%s = %s", models.ASSET_TABLE_ID, models.ASSET_SUBTITLE_TABLE_ASSET_ID)).
+		SetDbOpts(dbOpts).
+		WithLimit(1)
+
+	return getGeneric[models.AssetSubtitle](ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ListAssetSubtitles gets all records from the asset subtitles table based upon the where
+// clause and pagination in the options
+func (dao *DAO) ListAssetSubtitles(ctx context.Context, dbOpts *Options) ([]*models.AssetSubtitle, error) {
+	builderOpts := newBuilderOptions(models.ASSET_SUBTITLE_TABLE).
+		WithColumns(models.AssetSubtitleColumns()...).
+		WithJoin(models.ASSET_TABLE, fmt.Sprintf("%s = %s", models.ASSET_TABLE_ID, models.ASSET_SUBTITLE_TABLE_ASSET_ID)).
+		SetDbOpts(dbOpts)
+
+	return listGeneric[models.AssetSubtitle](ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// UpdateAssetSubtitle updates an asset subtitle record
+func (dao *DAO) UpdateAssetSubtitle(ctx context.Context, subtitle *models.AssetSubtitle) error {
+	if subtitle == nil {
+		return utils.ErrNilPtr
+	}
+
+	if subtitle.ID == "" {
+		return utils.ErrId
+	}
+
+	if subtitle.AssetID == "" {
+		return utils.ErrAssetId
+	}
+
+	subtitle.RefreshUpdatedAt()
+
+	dbOpts := NewOptions().WithWhere(squirrel.Eq{models.BASE_ID: subtitle.ID})
+
+	builderOpts := newBuilderOptions(models.ASSET_SUBTITLE_TABLE).
+		WithData(
+			map[string]interface{}{
+				models.ASSET_SUBTITLE_ASSET_ID: subtitle.AssetID,
+				models.ASSET_SUBTITLE_LANGUAGE: subtitle.Language,
+				models.ASSET_SUBTITLE_FORMAT:   subtitle.Format,
+				models.BASE_UPDATED_AT:         subtitle.UpdatedAt,
+			},
+		).
+		SetDbOpts(dbOpts)
+
+	_, err := updateGeneric(ctx, dao, *builderOpts)
+	return err
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DeleteAssetSubtitles deletes records from the asset subtitles table
+//
+// Errors when a where clause is not provided
+func (dao *DAO) DeleteAssetSubtitles(ctx context.Context, dbOpts *Options) error {
+	if dbOpts == nil || dbOpts.Where == nil {
+		return utils.ErrWhere
+	}
+
+	builderOpts := newBuilderOptions(models.ASSET_SUBTITLE_TABLE).SetDbOpts(dbOpts)
+	sqlStr, args, _ := deleteBuilder(*builderOpts)
+
+	q := database.QuerierFromContext(ctx, dao.db)
+	_, err := q.ExecContext(ctx, sqlStr, args...)
+	return err
+}
@@ -0,0 +1,184 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func helper_createSubtitleAsset(t *testing.T, ctx context.Context, dao *DAO) *models.Asset {
+	t.Helper()
+
+	course := &models.Course{Title: "Course 1", Path: "/course-1"}
+	require.NoError(t, dao.CreateCourse(ctx, course))
+
+	lesson := &models.Lesson{
+		CourseID: course.ID,
+		Title:    "Lesson 1",
+		Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+	}
+	require.NoError(t, dao.CreateLesson(ctx, lesson))
+
+	asset := &models.Asset{
+		CourseID: course.ID,
+		LessonID: lesson.ID,
+		Title:    "Intro",
+		Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+		Type:     types.MustAsset("mp4"),
+		Path:     "/course-1/01 Intro.mp4",
+		FileSize: 1024,
+		ModTime:  time.Now().Format(time.RFC3339Nano),
+		Hash:     "1234",
+	}
+	require.NoError(t, dao.CreateAsset(ctx, asset))
+
+	return asset
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_CreateAssetSubtitle(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		asset := helper_createSubtitleAsset(t, ctx, dao)
+
+		subtitle := &models.AssetSubtitle{AssetID: asset.ID, Path: "/course-1/01 Intro.en.srt", Language: "en", Format: "srt"}
+		require.NoError(t, dao.CreateAssetSubtitle(ctx, subtitle))
+		require.NotEmpty(t, subtitle.ID)
+	})
+
+	t.Run("nil pointer", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.CreateAssetSubtitle(ctx, nil), utils.ErrNilPtr)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		// Asset ID
+		subtitle := &models.AssetSubtitle{Path: "/course-1/01 Intro.srt", Format: "srt"}
+		require.ErrorIs(t, dao.CreateAssetSubtitle(ctx, subtitle), utils.ErrAssetId)
+
+		// Path
+		subtitle = &models.AssetSubtitle{AssetID: "1234", Format: "srt"}
+		require.ErrorIs(t, dao.CreateAssetSubtitle(ctx, subtitle), utils.ErrPath)
+
+		// Asset
+		subtitle = &models.AssetSubtitle{AssetID: "1234", Path: "/course-1/01 Intro.srt", Format: "srt"}
+		require.ErrorContains(t, dao.CreateAssetSubtitle(ctx, subtitle), "FOREIGN KEY constraint failed")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_ListAssetSubtitles(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		asset := helper_createSubtitleAsset(t, ctx, dao)
+
+		for _, lang := range []string{"en", "fr"} {
+			subtitle := &models.AssetSubtitle{AssetID: asset.ID, Path: "/course-1/01 Intro." + lang + ".srt", Language: lang, Format: "srt"}
+			require.NoError(t, dao.CreateAssetSubtitle(ctx, subtitle))
+		}
+
+		dbOpts := NewOptions().
+			WithWhere(squirrel.Eq{models.ASSET_TABLE_COURSE_ID: asset.CourseID}).
+			WithOrderBy(models.ASSET_SUBTITLE_TABLE_LANGUAGE + " asc")
+
+		records, err := dao.ListAssetSubtitles(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, "en", records[0].Language)
+		require.Equal(t, asset.ID, records[0].AssetID)
+		require.Equal(t, asset.CourseID, records[0].CourseID)
+		require.Equal(t, "fr", records[1].Language)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		records, err := dao.ListAssetSubtitles(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_UpdateAssetSubtitle(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		asset := helper_createSubtitleAsset(t, ctx, dao)
+
+		subtitle := &models.AssetSubtitle{AssetID: asset.ID, Path: "/course-1/01 Intro.srt", Format: "srt"}
+		require.NoError(t, dao.CreateAssetSubtitle(ctx, subtitle))
+
+		subtitle.Language = "de"
+		require.NoError(t, dao.UpdateAssetSubtitle(ctx, subtitle))
+
+		dbOpts := NewOptions().WithWhere(squirrel.Eq{models.ASSET_SUBTITLE_TABLE_ID: subtitle.ID})
+		record, err := dao.GetAssetSubtitle(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Equal(t, "de", record.Language)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.UpdateAssetSubtitle(ctx, nil), utils.ErrNilPtr)
+		require.ErrorIs(t, dao.UpdateAssetSubtitle(ctx, &models.AssetSubtitle{}), utils.ErrId)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_DeleteAssetSubtitles(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		asset := helper_createSubtitleAsset(t, ctx, dao)
+
+		subtitle := &models.AssetSubtitle{AssetID: asset.ID, Path: "/course-1/01 Intro.srt", Format: "srt"}
+		require.NoError(t, dao.CreateAssetSubtitle(ctx, subtitle))
+
+		require.NoError(t, dao.DeleteAssetSubtitles(ctx, NewOptions().WithWhere(squirrel.Eq{models.BASE_ID: subtitle.ID})))
+
+		records, err := dao.ListAssetSubtitles(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+
+	t.Run("cascade", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		asset := helper_createSubtitleAsset(t, ctx, dao)
+
+		subtitle := &models.AssetSubtitle{AssetID: asset.ID, Path: "/course-1/01 Intro.srt", Format: "srt"}
+		require.NoError(t, dao.CreateAssetSubtitle(ctx, subtitle))
+
+		require.NoError(t, dao.DeleteAssets(ctx, NewOptions().WithWhere(squirrel.Eq{models.BASE_ID: asset.ID})))
+
+		records, err := dao.ListAssetSubtitles(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+
+	t.Run("missing where", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.DeleteAssetSubtitles(ctx, nil), utils.ErrWhere)
+	})
+}
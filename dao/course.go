@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/geerew/off-course/database"
@@ -43,18 +44,29 @@ func (dao *DAO) CreateCourse(ctx context.Context, course *models.Course) error {
 	builderOpts := newBuilderOptions(models.COURSE_TABLE).
 		WithData(
 			map[string]interface{}{
-				models.BASE_ID:              course.ID,
-				models.COURSE_TITLE:         course.Title,
-				models.COURSE_PATH:          course.Path,
-				models.COURSE_CARD_PATH:     course.CardPath,
-				models.COURSE_CARD_HASH:     course.CardHash,
-				models.COURSE_CARD_MOD_TIME: course.CardModTime,
-				models.COURSE_AVAILABLE:     course.Available,
-				models.COURSE_DURATION:      course.Duration,
-				models.COURSE_INITIAL_SCAN:  course.InitialScan,
-				models.COURSE_MAINTENANCE:   course.Maintenance,
-				models.BASE_CREATED_AT:      course.CreatedAt,
-				models.BASE_UPDATED_AT:      course.UpdatedAt,
+				models.BASE_ID:                course.ID,
+				models.COURSE_TITLE:           course.Title,
+				models.COURSE_PATH:            course.Path,
+				models.COURSE_CARD_PATH:       course.CardPath,
+				models.COURSE_CARD_HASH:       course.CardHash,
+				models.COURSE_CARD_MOD_TIME:   course.CardModTime,
+				models.COURSE_CARD_SOURCE:     course.CardSource,
+				models.COURSE_AVAILABLE:       course.Available,
+				models.COURSE_DURATION:        course.Duration,
+				models.COURSE_INITIAL_SCAN:    course.InitialScan,
+				models.COURSE_MAINTENANCE:     course.Maintenance,
+				models.COURSE_WATCH:           course.Watch,
+				models.COURSE_LIBRARY_ROOT_ID: course.LibraryRootID,
+				models.COURSE_MISSING:         course.Missing,
+				models.COURSE_DESCRIPTION:     course.Description,
+				models.COURSE_AUTHORS:         course.Authors,
+				models.COURSE_LANGUAGE:        course.Language,
+				models.COURSE_DIFFICULTY:      course.Difficulty,
+				models.COURSE_RELEASE_DATE:    course.ReleaseDate,
+				models.COURSE_SOURCE_URL:      course.SourceURL,
+				models.COURSE_MODULES:         course.Modules,
+				models.BASE_CREATED_AT:        course.CreatedAt,
+				models.BASE_UPDATED_AT:        course.UpdatedAt,
 			},
 		)
 
@@ -179,16 +191,28 @@ func (dao *DAO) UpdateCourse(ctx context.Context, course *models.Course) error {
 	builderOpts := newBuilderOptions(models.COURSE_TABLE).
 		WithData(
 			map[string]interface{}{
-				models.COURSE_TITLE:         course.Title,
-				models.COURSE_PATH:          course.Path,
-				models.COURSE_CARD_PATH:     course.CardPath,
-				models.COURSE_CARD_HASH:     course.CardHash,
-				models.COURSE_CARD_MOD_TIME: course.CardModTime,
-				models.COURSE_AVAILABLE:     course.Available,
-				models.COURSE_DURATION:      course.Duration,
-				models.COURSE_INITIAL_SCAN:  course.InitialScan,
-				models.COURSE_MAINTENANCE:   course.Maintenance,
-				models.BASE_UPDATED_AT:      course.UpdatedAt,
+				models.COURSE_TITLE:           course.Title,
+				models.COURSE_PATH:            course.Path,
+				models.COURSE_CARD_PATH:       course.CardPath,
+				models.COURSE_CARD_HASH:       course.CardHash,
+				models.COURSE_CARD_MOD_TIME:   course.CardModTime,
+				models.COURSE_CARD_SOURCE:     course.CardSource,
+				models.COURSE_AVAILABLE:       course.Available,
+				models.COURSE_DURATION:        course.Duration,
+				models.COURSE_INITIAL_SCAN:    course.InitialScan,
+				models.COURSE_MAINTENANCE:     course.Maintenance,
+				models.COURSE_WATCH:           course.Watch,
+				models.COURSE_LIBRARY_ROOT_ID: course.LibraryRootID,
+				models.COURSE_MISSING:         course.Missing,
+				models.COURSE_DESCRIPTION:     course.Description,
+				models.COURSE_AUTHORS:         course.Authors,
+				models.COURSE_LANGUAGE:        course.Language,
+				models.COURSE_DIFFICULTY:      course.Difficulty,
+				models.COURSE_RELEASE_DATE:    course.ReleaseDate,
+				models.COURSE_SOURCE_URL:      course.SourceURL,
+				models.COURSE_MODULES:         course.Modules,
+				models.COURSE_NORMALIZE_AUDIO: course.NormalizeAudio,
+				models.BASE_UPDATED_AT:        course.UpdatedAt,
 			},
 		).
 		SetDbOpts(dbOpts)
@@ -217,6 +241,69 @@ func (dao *DAO) DeleteCourses(ctx context.Context, dbOpts *Options) error {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// RelocateCourse rewrites the path of a course, and the paths of its card, assets, attachments
+// and subtitles, from one course path to another. Progress, favourites and tags are kept as
+// the records themselves are not replaced
+func (dao *DAO) RelocateCourse(ctx context.Context, courseID, from, to string) error {
+	if courseID == "" {
+		return utils.ErrCourseId
+	}
+
+	if from == "" || to == "" {
+		return utils.ErrPath
+	}
+
+	// SQLite SUBSTR() is 1-based and counts characters
+	rest := utf8.RuneCountInString(from) + 1
+	relocate := func(column string) squirrel.Sqlizer {
+		return squirrel.Expr("? || SUBSTR("+column+", ?)", to, rest)
+	}
+
+	assetIDs := squirrel.Select(models.BASE_ID).From(models.ASSET_TABLE).Where(squirrel.Eq{models.ASSET_COURSE_ID: courseID})
+	lessonIDs := squirrel.Select(models.BASE_ID).From(models.LESSON_TABLE).Where(squirrel.Eq{models.LESSON_COURSE_ID: courseID})
+
+	assetIDsSql, assetIDsArgs, _ := assetIDs.ToSql()
+	lessonIDsSql, lessonIDsArgs, _ := lessonIDs.ToSql()
+
+	updates := []squirrel.UpdateBuilder{
+		squirrel.Update(models.COURSE_TABLE).
+			Set(models.COURSE_PATH, to).
+			Set(models.BASE_UPDATED_AT, types.NowDateTime()).
+			Where(squirrel.Eq{models.BASE_ID: courseID}),
+		squirrel.Update(models.COURSE_TABLE).
+			Set(models.COURSE_CARD_PATH, relocate(models.COURSE_CARD_PATH)).
+			Where(squirrel.And{squirrel.Eq{models.BASE_ID: courseID}, squirrel.NotEq{models.COURSE_CARD_PATH: nil}}),
+		squirrel.Update(models.ASSET_TABLE).
+			Set(models.ASSET_PATH, relocate(models.ASSET_PATH)).
+			Where(squirrel.Eq{models.ASSET_COURSE_ID: courseID}),
+		squirrel.Update(models.ATTACHMENT_TABLE).
+			Set(models.ATTACHMENT_PATH, relocate(models.ATTACHMENT_PATH)).
+			Where(squirrel.Expr(models.ATTACHMENT_LESSON_ID+" IN ("+lessonIDsSql+")", lessonIDsArgs...)),
+		squirrel.Update(models.ASSET_SUBTITLE_TABLE).
+			Set(models.ASSET_SUBTITLE_PATH, relocate(models.ASSET_SUBTITLE_PATH)).
+			Where(squirrel.Expr(models.ASSET_SUBTITLE_ASSET_ID+" IN ("+assetIDsSql+")", assetIDsArgs...)),
+	}
+
+	return dao.db.RunInTransaction(ctx, func(txCtx context.Context) error {
+		q := database.QuerierFromContext(txCtx, dao.db)
+
+		for _, update := range updates {
+			sqlStr, args, err := update.ToSql()
+			if err != nil {
+				return err
+			}
+
+			if _, err := q.ExecContext(txCtx, sqlStr, args...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // ClassifyCoursePaths classifies the given paths into one of the following categories:
 //   - PathClassificationNone: The path does not exist in the courses table
 //   - PathClassificationAncestor: The path is an ancestor of a course path
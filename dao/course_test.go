@@ -459,6 +459,7 @@ func Test_UpdateCourse(t *testing.T) {
 			Duration:    100,
 			InitialScan: true,
 			Maintenance: false,
+			Watch:       true,
 		}
 		require.NoError(t, dao.CreateCourse(ctx, originalCourse))
 
@@ -473,6 +474,17 @@ func Test_UpdateCourse(t *testing.T) {
 			Duration:    200,
 			InitialScan: false,
 			Maintenance: true,
+			Watch:       false,
+			Missing:     true,
+			Description: "# Course 2",
+			Authors:     models.CourseAuthors{"Jane"},
+			Language:    "en",
+			Difficulty:  "beginner",
+			ReleaseDate: "2024-01-02",
+			SourceURL:   "https://example.com",
+			Modules:     models.CourseModules{{Name: "01 Basics", Title: "Basics"}},
+
+			NormalizeAudio: true,
 		}
 		require.NoError(t, dao.UpdateCourse(ctx, updatedCourse))
 
@@ -488,7 +500,18 @@ func Test_UpdateCourse(t *testing.T) {
 		require.Equal(t, updatedCourse.Duration, record.Duration)         // Changed
 		require.Equal(t, updatedCourse.InitialScan, record.InitialScan)   // Changed
 		require.Equal(t, updatedCourse.Maintenance, record.Maintenance)   // Changed
+		require.Equal(t, updatedCourse.Watch, record.Watch)               // Changed
+		require.Equal(t, updatedCourse.Missing, record.Missing)           // Changed
+		require.Equal(t, updatedCourse.Description, record.Description)   // Changed
+		require.Equal(t, updatedCourse.Authors, record.Authors)           // Changed
+		require.Equal(t, updatedCourse.Language, record.Language)         // Changed
+		require.Equal(t, updatedCourse.Difficulty, record.Difficulty)     // Changed
+		require.Equal(t, updatedCourse.ReleaseDate, record.ReleaseDate)   // Changed
+		require.Equal(t, updatedCourse.SourceURL, record.SourceURL)       // Changed
+		require.Equal(t, updatedCourse.Modules, record.Modules)           // Changed
 		require.NotEqual(t, originalCourse.UpdatedAt, record.UpdatedAt)   // Changed
+
+		require.True(t, record.NormalizeAudio) // Changed
 	})
 
 	t.Run("invalid", func(t *testing.T) {
@@ -565,6 +588,74 @@ func Test_DeleteCourses(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func Test_RelocateCourse(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/old/Course 1", CardPath: "/old/Course 1/card.jpg"}
+		require.NoError(t, dao.CreateCourse(ctx, course))
+
+		other := &models.Course{Title: "Course 2", Path: "/old/Course 2"}
+		require.NoError(t, dao.CreateCourse(ctx, other))
+
+		lesson := &models.Lesson{CourseID: course.ID, Title: "Lesson 1", Prefix: sql.NullInt16{Int16: 1, Valid: true}}
+		require.NoError(t, dao.CreateLesson(ctx, lesson))
+
+		asset := &models.Asset{
+			CourseID: course.ID,
+			LessonID: lesson.ID,
+			Title:    "Intro",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+			Type:     types.MustAsset("mp4"),
+			Path:     "/old/Course 1/01 Intro.mp4",
+			FileSize: 1024,
+			ModTime:  time.Now().Format(time.RFC3339Nano),
+			Hash:     "1234",
+		}
+		require.NoError(t, dao.CreateAsset(ctx, asset))
+
+		attachment := &models.Attachment{LessonID: lesson.ID, Title: "Notes", Path: "/old/Course 1/01 Notes.txt"}
+		require.NoError(t, dao.CreateAttachment(ctx, attachment))
+
+		subtitle := &models.AssetSubtitle{AssetID: asset.ID, Path: "/old/Course 1/01 Intro.en.srt", Language: "en", Format: "srt"}
+		require.NoError(t, dao.CreateAssetSubtitle(ctx, subtitle))
+
+		require.NoError(t, dao.RelocateCourse(ctx, course.ID, "/old/Course 1", "/new/Kurs 1"))
+
+		record, err := dao.GetCourse(ctx, NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+		require.NoError(t, err)
+		require.Equal(t, "/new/Kurs 1", record.Path)
+		require.Equal(t, "/new/Kurs 1/card.jpg", record.CardPath)
+
+		assetRecord, err := dao.GetAsset(ctx, NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_ID: asset.ID}))
+		require.NoError(t, err)
+		require.Equal(t, "/new/Kurs 1/01 Intro.mp4", assetRecord.Path)
+
+		attachmentRecord, err := dao.GetAttachment(ctx, NewOptions().WithWhere(squirrel.Eq{models.ATTACHMENT_TABLE_ID: attachment.ID}))
+		require.NoError(t, err)
+		require.Equal(t, "/new/Kurs 1/01 Notes.txt", attachmentRecord.Path)
+
+		subtitleRecord, err := dao.GetAssetSubtitle(ctx, NewOptions().WithWhere(squirrel.Eq{models.ASSET_SUBTITLE_TABLE_ID: subtitle.ID}))
+		require.NoError(t, err)
+		require.Equal(t, "/new/Kurs 1/01 Intro.en.srt", subtitleRecord.Path)
+
+		// Other courses are untouched
+		record, err = dao.GetCourse(ctx, NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: other.ID}))
+		require.NoError(t, err)
+		require.Equal(t, "/old/Course 2", record.Path)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.RelocateCourse(ctx, "", "/old", "/new"), utils.ErrCourseId)
+		require.ErrorIs(t, dao.RelocateCourse(ctx, "1234", "", "/new"), utils.ErrPath)
+		require.ErrorIs(t, dao.RelocateCourse(ctx, "1234", "/old", ""), utils.ErrPath)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func Test_ClassifyCoursePaths(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		dao, ctx := setup(t)
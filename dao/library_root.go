@@ -0,0 +1,137 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CreateLibraryRoot inserts a new library root record
+func (dao *DAO) CreateLibraryRoot(ctx context.Context, root *models.LibraryRoot) error {
+	if root == nil {
+		return utils.ErrNilPtr
+	}
+
+	if root.Path == "" {
+		return utils.ErrPath
+	}
+
+	if root.MaxDepth < 1 {
+		return utils.ErrMaxDepth
+	}
+
+	if root.ID == "" {
+		root.RefreshId()
+	}
+
+	root.RefreshCreatedAt()
+	root.RefreshUpdatedAt()
+
+	builderOpts := newBuilderOptions(models.LIBRARY_ROOT_TABLE).
+		WithData(
+			map[string]interface{}{
+				models.BASE_ID:                         root.ID,
+				models.LIBRARY_ROOT_PATH:               root.Path,
+				models.LIBRARY_ROOT_MAX_DEPTH:          root.MaxDepth,
+				models.LIBRARY_ROOT_REQUIRE_VIDEO:      root.RequireVideo,
+				models.LIBRARY_ROOT_REQUIRE_NUMBERED:   root.RequireNumbered,
+				models.LIBRARY_ROOT_FLAG_MISSING:       root.FlagMissing,
+				models.LIBRARY_ROOT_LAST_DISCOVERED_AT: root.LastDiscoveredAt,
+				models.BASE_CREATED_AT:                 root.CreatedAt,
+				models.BASE_UPDATED_AT:                 root.UpdatedAt,
+			},
+		)
+
+	return createGeneric(ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetLibraryRoot gets a record from the library roots table based upon the where clause in the
+// options. If there is no where clause, it will return the first record in the table
+func (dao *DAO) GetLibraryRoot(ctx context.Context, dbOpts *Options) (*models.LibraryRoot, error) {
+	builderOpts := newBuilderOptions(models.LIBRARY_ROOT_TABLE).
+		WithColumns(models.LibraryRootColumns()...).
+		SetDbOpts(dbOpts).
+		WithLimit(1)
+
+	return getGeneric[models.LibraryRoot](ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ListLibraryRoots gets all records from the library roots table based upon the where clause
+// and pagination in the options
+func (dao *DAO) ListLibraryRoots(ctx context.Context, dbOpts *Options) ([]*models.LibraryRoot, error) {
+	builderOpts := newBuilderOptions(models.LIBRARY_ROOT_TABLE).
+		WithColumns(models.LibraryRootColumns()...).
+		SetDbOpts(dbOpts)
+
+	return listGeneric[models.LibraryRoot](ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// UpdateLibraryRoot updates a library root record
+func (dao *DAO) UpdateLibraryRoot(ctx context.Context, root *models.LibraryRoot) error {
+	if root == nil {
+		return utils.ErrNilPtr
+	}
+
+	if root.ID == "" {
+		return utils.ErrId
+	}
+
+	if root.Path == "" {
+		return utils.ErrPath
+	}
+
+	if root.MaxDepth < 1 {
+		return utils.ErrMaxDepth
+	}
+
+	root.RefreshUpdatedAt()
+
+	dbOpts := NewOptions().WithWhere(squirrel.Eq{models.BASE_ID: root.ID})
+
+	builderOpts := newBuilderOptions(models.LIBRARY_ROOT_TABLE).
+		WithData(
+			map[string]interface{}{
+				models.LIBRARY_ROOT_PATH:               root.Path,
+				models.LIBRARY_ROOT_MAX_DEPTH:          root.MaxDepth,
+				models.LIBRARY_ROOT_REQUIRE_VIDEO:      root.RequireVideo,
+				models.LIBRARY_ROOT_REQUIRE_NUMBERED:   root.RequireNumbered,
+				models.LIBRARY_ROOT_FLAG_MISSING:       root.FlagMissing,
+				models.LIBRARY_ROOT_LAST_DISCOVERED_AT: root.LastDiscoveredAt,
+				models.BASE_UPDATED_AT:                 root.UpdatedAt,
+			},
+		).
+		SetDbOpts(dbOpts)
+
+	_, err := updateGeneric(ctx, dao, *builderOpts)
+	return err
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DeleteLibraryRoots deletes records from the library roots table. Courses discovered under a
+// deleted root are kept
+//
+// Errors when a where clause is not provided
+func (dao *DAO) DeleteLibraryRoots(ctx context.Context, dbOpts *Options) error {
+	if dbOpts == nil || dbOpts.Where == nil {
+		return utils.ErrWhere
+	}
+
+	builderOpts := newBuilderOptions(models.LIBRARY_ROOT_TABLE).SetDbOpts(dbOpts)
+	sqlStr, args, _ := deleteBuilder(*builderOpts)
+
+	q := database.QuerierFromContext(ctx, dao.db)
+	_, err := q.ExecContext(ctx, sqlStr, args...)
+	return err
+}
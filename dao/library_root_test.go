@@ -0,0 +1,211 @@
+package dao
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_CreateLibraryRoot(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, dao.CreateLibraryRoot(ctx, root))
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, dao.CreateLibraryRoot(ctx, root))
+
+		duplicate := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.ErrorContains(t, dao.CreateLibraryRoot(ctx, duplicate), "UNIQUE constraint failed: "+models.LIBRARY_ROOT_TABLE_PATH)
+	})
+
+	t.Run("nil pointer", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.CreateLibraryRoot(ctx, nil), utils.ErrNilPtr)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		// Path
+		root := &models.LibraryRoot{Path: "", MaxDepth: 2}
+		require.ErrorIs(t, dao.CreateLibraryRoot(ctx, root), utils.ErrPath)
+
+		// Max depth
+		root = &models.LibraryRoot{Path: "/library", MaxDepth: 0}
+		require.ErrorIs(t, dao.CreateLibraryRoot(ctx, root), utils.ErrMaxDepth)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_GetLibraryRoot(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 3, RequireVideo: true, FlagMissing: true}
+		require.NoError(t, dao.CreateLibraryRoot(ctx, root))
+
+		dbOpts := NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: root.ID})
+		record, err := dao.GetLibraryRoot(ctx, dbOpts)
+		require.Nil(t, err)
+		require.Equal(t, root.ID, record.ID)
+		require.Equal(t, root.Path, record.Path)
+		require.Equal(t, 3, record.MaxDepth)
+		require.True(t, record.RequireVideo)
+		require.False(t, record.RequireNumbered)
+		require.True(t, record.FlagMissing)
+		require.True(t, record.LastDiscoveredAt.IsZero())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		record, err := dao.GetLibraryRoot(ctx, nil)
+		require.Nil(t, err)
+		require.Nil(t, record)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_ListLibraryRoots(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		roots := []*models.LibraryRoot{}
+		for i := range 3 {
+			root := &models.LibraryRoot{Path: fmt.Sprintf("/library-%d", i), MaxDepth: 2}
+			roots = append(roots, root)
+			require.NoError(t, dao.CreateLibraryRoot(ctx, root))
+			time.Sleep(1 * time.Millisecond)
+		}
+
+		dbOpts := NewOptions().WithOrderBy(models.LIBRARY_ROOT_TABLE_CREATED_AT + " ASC")
+		records, err := dao.ListLibraryRoots(ctx, dbOpts)
+		require.Nil(t, err)
+		require.Len(t, records, 3)
+
+		for i, record := range records {
+			require.Equal(t, roots[i].ID, record.ID)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		records, err := dao.ListLibraryRoots(ctx, nil)
+		require.Nil(t, err)
+		require.Empty(t, records)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_UpdateLibraryRoot(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, dao.CreateLibraryRoot(ctx, root))
+
+		time.Sleep(1 * time.Millisecond)
+
+		newRoot := &models.LibraryRoot{
+			Base:             root.Base,
+			Path:             "/library-new",
+			MaxDepth:         4,
+			RequireVideo:     true,
+			RequireNumbered:  true,
+			FlagMissing:      true,
+			LastDiscoveredAt: types.NowDateTime(),
+		}
+		require.NoError(t, dao.UpdateLibraryRoot(ctx, newRoot))
+
+		dbOpts := NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: root.ID})
+		record, err := dao.GetLibraryRoot(ctx, dbOpts)
+		require.Nil(t, err)
+		require.Equal(t, newRoot.Path, record.Path)
+		require.Equal(t, newRoot.MaxDepth, record.MaxDepth)
+		require.True(t, record.RequireVideo)
+		require.True(t, record.RequireNumbered)
+		require.True(t, record.FlagMissing)
+		require.False(t, record.LastDiscoveredAt.IsZero())
+		require.NotEqual(t, root.UpdatedAt, record.UpdatedAt)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, dao.CreateLibraryRoot(ctx, root))
+
+		// Empty ID
+		root.ID = ""
+		require.ErrorIs(t, dao.UpdateLibraryRoot(ctx, root), utils.ErrId)
+
+		// Nil
+		require.ErrorIs(t, dao.UpdateLibraryRoot(ctx, nil), utils.ErrNilPtr)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_DeleteLibraryRoots(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, dao.CreateLibraryRoot(ctx, root))
+
+		opts := NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: root.ID})
+		require.Nil(t, dao.DeleteLibraryRoots(ctx, opts))
+
+		record, err := dao.GetLibraryRoot(ctx, opts)
+		require.Nil(t, err)
+		require.Nil(t, record)
+	})
+
+	t.Run("courses kept", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, dao.CreateLibraryRoot(ctx, root))
+
+		course := &models.Course{
+			Title:         "Course 1",
+			Path:          "/library/course-1",
+			LibraryRootID: sql.NullString{String: root.ID, Valid: true},
+		}
+		require.NoError(t, dao.CreateCourse(ctx, course))
+
+		opts := NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: root.ID})
+		require.Nil(t, dao.DeleteLibraryRoots(ctx, opts))
+
+		record, err := dao.GetCourse(ctx, NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+		require.Nil(t, err)
+		require.NotNil(t, record)
+		require.False(t, record.LibraryRootID.Valid)
+	})
+
+	t.Run("no db options", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.DeleteLibraryRoots(ctx, nil), utils.ErrWhere)
+	})
+}
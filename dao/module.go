@@ -0,0 +1,130 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CreateModule inserts a new module record
+func (dao *DAO) CreateModule(ctx context.Context, module *models.Module) error {
+	if module == nil {
+		return utils.ErrNilPtr
+	}
+
+	if module.CourseID == "" {
+		return utils.ErrCourseId
+	}
+
+	if module.Path == "" {
+		return utils.ErrPath
+	}
+
+	if module.ID == "" {
+		module.RefreshId()
+	}
+
+	module.RefreshCreatedAt()
+	module.RefreshUpdatedAt()
+
+	builderOpts := newBuilderOptions(models.MODULE_TABLE).
+		WithData(
+			map[string]interface{}{
+				models.BASE_ID:          module.ID,
+				models.MODULE_COURSE_ID: module.CourseID,
+				models.MODULE_PARENT_ID: module.ParentID,
+				models.MODULE_PATH:      module.Path,
+				models.MODULE_TITLE:     module.Title,
+				models.MODULE_PREFIX:    module.Prefix,
+				models.BASE_CREATED_AT:  module.CreatedAt,
+				models.BASE_UPDATED_AT:  module.UpdatedAt,
+			},
+		)
+
+	return createGeneric(ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetModule gets a record from the modules table based upon the where clause in the options. If
+// there is no where clause, it will return the first record in the table
+func (dao *DAO) GetModule(ctx context.Context, dbOpts *Options) (*models.Module, error) {
+	builderOpts := newBuilderOptions(models.MODULE_TABLE).
+		WithColumns(models.ModuleColumns()...).
+		SetDbOpts(dbOpts).
+		WithLimit(1)
+
+	return getGeneric[models.Module](ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ListModules gets all records from the modules table based upon the where clause and
+// pagination in the options. Modules are ordered by path when no order is given, so parents
+// always come before their children
+func (dao *DAO) ListModules(ctx context.Context, dbOpts *Options) ([]*models.Module, error) {
+	builderOpts := newBuilderOptions(models.MODULE_TABLE).
+		WithColumns(models.ModuleColumns()...).
+		SetDbOpts(dbOpts)
+
+	if len(builderOpts.DbOpts.OrderBy) == 0 {
+		builderOpts.DbOpts.WithOrderBy(models.MODULE_TABLE_PATH + " ASC")
+	}
+
+	return listGeneric[models.Module](ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// UpdateModule updates a module record
+func (dao *DAO) UpdateModule(ctx context.Context, module *models.Module) error {
+	if module == nil {
+		return utils.ErrNilPtr
+	}
+
+	if module.ID == "" {
+		return utils.ErrId
+	}
+
+	module.RefreshUpdatedAt()
+
+	dbOpts := NewOptions().WithWhere(squirrel.Eq{models.BASE_ID: module.ID})
+
+	builderOpts := newBuilderOptions(models.MODULE_TABLE).
+		WithData(
+			map[string]interface{}{
+				models.MODULE_PARENT_ID: module.ParentID,
+				models.MODULE_TITLE:     module.Title,
+				models.MODULE_PREFIX:    module.Prefix,
+				models.BASE_UPDATED_AT:  module.UpdatedAt,
+			},
+		).
+		SetDbOpts(dbOpts)
+
+	_, err := updateGeneric(ctx, dao, *builderOpts)
+	return err
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DeleteModules deletes records from the modules table. Child modules are removed by the
+// foreign key cascade
+//
+// Errors when a where clause is not provided
+func (dao *DAO) DeleteModules(ctx context.Context, dbOpts *Options) error {
+	if dbOpts == nil || dbOpts.Where == nil {
+		return utils.ErrWhere
+	}
+
+	builderOpts := newBuilderOptions(models.MODULE_TABLE).SetDbOpts(dbOpts)
+	sqlStr, args, _ := deleteBuilder(*builderOpts)
+
+	q := database.QuerierFromContext(ctx, dao.db)
+	_, err := q.ExecContext(ctx, sqlStr, args...)
+	return err
+}
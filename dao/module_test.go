@@ -0,0 +1,175 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func helper_createModuleCourse(t *testing.T, ctx context.Context, dao *DAO) *models.Course {
+	t.Helper()
+
+	course := &models.Course{Title: "Course 1", Path: "/course-1"}
+	require.NoError(t, dao.CreateCourse(ctx, course))
+
+	return course
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_CreateModule(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := helper_createModuleCourse(t, ctx, dao)
+
+		parent := &models.Module{CourseID: course.ID, Path: "01 Part One", Title: "Part One", Prefix: sql.NullInt16{Int16: 1, Valid: true}}
+		require.NoError(t, dao.CreateModule(ctx, parent))
+		require.NotEmpty(t, parent.ID)
+
+		child := &models.Module{
+			CourseID: course.ID,
+			ParentID: sql.NullString{String: parent.ID, Valid: true},
+			Path:     "01 Part One/02 Setup",
+			Title:    "Setup",
+		}
+		require.NoError(t, dao.CreateModule(ctx, child))
+	})
+
+	t.Run("nil pointer", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.CreateModule(ctx, nil), utils.ErrNilPtr)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		// Course ID
+		module := &models.Module{Path: "01 Part One"}
+		require.ErrorIs(t, dao.CreateModule(ctx, module), utils.ErrCourseId)
+
+		// Path
+		module = &models.Module{CourseID: "1234"}
+		require.ErrorIs(t, dao.CreateModule(ctx, module), utils.ErrPath)
+
+		// Course
+		module = &models.Module{CourseID: "1234", Path: "01 Part One"}
+		require.ErrorContains(t, dao.CreateModule(ctx, module), "FOREIGN KEY constraint failed")
+	})
+
+	t.Run("duplicate path", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := helper_createModuleCourse(t, ctx, dao)
+
+		require.NoError(t, dao.CreateModule(ctx, &models.Module{CourseID: course.ID, Path: "01 Part One"}))
+		require.ErrorContains(t, dao.CreateModule(ctx, &models.Module{CourseID: course.ID, Path: "01 Part One"}), "UNIQUE constraint failed")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_ListModules(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := helper_createModuleCourse(t, ctx, dao)
+
+		for _, path := range []string{"02 Part Two", "01 Part One/01 Setup", "01 Part One"} {
+			require.NoError(t, dao.CreateModule(ctx, &models.Module{CourseID: course.ID, Path: path}))
+		}
+
+		records, err := dao.ListModules(ctx, NewOptions().WithWhere(squirrel.Eq{models.MODULE_TABLE_COURSE_ID: course.ID}))
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		require.Equal(t, "01 Part One", records[0].Path)
+		require.Equal(t, "01 Part One/01 Setup", records[1].Path)
+		require.Equal(t, "02 Part Two", records[2].Path)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		records, err := dao.ListModules(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_UpdateModule(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := helper_createModuleCourse(t, ctx, dao)
+
+		module := &models.Module{CourseID: course.ID, Path: "01 Part One", Title: "Part One"}
+		require.NoError(t, dao.CreateModule(ctx, module))
+
+		module.Title = "Part 1"
+		module.Prefix = sql.NullInt16{Int16: 1, Valid: true}
+		require.NoError(t, dao.UpdateModule(ctx, module))
+
+		record, err := dao.GetModule(ctx, NewOptions().WithWhere(squirrel.Eq{models.MODULE_TABLE_ID: module.ID}))
+		require.NoError(t, err)
+		require.Equal(t, "Part 1", record.Title)
+		require.Equal(t, int16(1), record.Prefix.Int16)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.UpdateModule(ctx, nil), utils.ErrNilPtr)
+		require.ErrorIs(t, dao.UpdateModule(ctx, &models.Module{}), utils.ErrId)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_DeleteModules(t *testing.T) {
+	t.Run("cascade to children", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := helper_createModuleCourse(t, ctx, dao)
+
+		parent := &models.Module{CourseID: course.ID, Path: "01 Part One"}
+		require.NoError(t, dao.CreateModule(ctx, parent))
+
+		child := &models.Module{CourseID: course.ID, ParentID: sql.NullString{String: parent.ID, Valid: true}, Path: "01 Part One/01 Setup"}
+		require.NoError(t, dao.CreateModule(ctx, child))
+
+		require.NoError(t, dao.DeleteModules(ctx, NewOptions().WithWhere(squirrel.Eq{models.BASE_ID: parent.ID})))
+
+		records, err := dao.ListModules(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+
+	t.Run("cascade from course", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := helper_createModuleCourse(t, ctx, dao)
+		require.NoError(t, dao.CreateModule(ctx, &models.Module{CourseID: course.ID, Path: "01 Part One"}))
+
+		require.NoError(t, dao.DeleteCourses(ctx, NewOptions().WithWhere(squirrel.Eq{models.BASE_ID: course.ID})))
+
+		records, err := dao.ListModules(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+
+	t.Run("missing where", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.DeleteModules(ctx, nil), utils.ErrWhere)
+	})
+}
@@ -0,0 +1,105 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CreateScanHistory inserts a new scan history record
+func (dao *DAO) CreateScanHistory(ctx context.Context, history *models.ScanHistory) error {
+	if history == nil {
+		return utils.ErrNilPtr
+	}
+
+	if history.CourseID == "" {
+		return utils.ErrCourseId
+	}
+
+	if !history.Status.IsValid() {
+		return utils.ErrScanStatus
+	}
+
+	if history.ID == "" {
+		history.RefreshId()
+	}
+
+	history.RefreshCreatedAt()
+	history.RefreshUpdatedAt()
+
+	builderOpts := newBuilderOptions(models.SCAN_HISTORY_TABLE).
+		WithData(
+			map[string]interface{}{
+				models.BASE_ID:                          history.ID,
+				models.SCAN_HISTORY_COURSE_ID:           history.CourseID,
+				models.SCAN_HISTORY_STATUS:              history.Status,
+				models.SCAN_HISTORY_ERROR:               history.Error,
+				models.SCAN_HISTORY_STARTED_AT:          history.StartedAt,
+				models.SCAN_HISTORY_FINISHED_AT:         history.FinishedAt,
+				models.SCAN_HISTORY_DURATION:            history.Duration,
+				models.SCAN_HISTORY_LESSONS_CREATED:     history.LessonsCreated,
+				models.SCAN_HISTORY_LESSONS_UPDATED:     history.LessonsUpdated,
+				models.SCAN_HISTORY_LESSONS_DELETED:     history.LessonsDeleted,
+				models.SCAN_HISTORY_ASSETS_CREATED:      history.AssetsCreated,
+				models.SCAN_HISTORY_ASSETS_UPDATED:      history.AssetsUpdated,
+				models.SCAN_HISTORY_ASSETS_DELETED:      history.AssetsDeleted,
+				models.SCAN_HISTORY_ATTACHMENTS_CREATED: history.AttachmentsCreated,
+				models.SCAN_HISTORY_ATTACHMENTS_DELETED: history.AttachmentsDeleted,
+				models.SCAN_HISTORY_SKIPPED:             history.Skipped,
+				models.BASE_CREATED_AT:                  history.CreatedAt,
+				models.BASE_UPDATED_AT:                  history.UpdatedAt,
+			},
+		)
+
+	return createGeneric(ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetScanHistory gets a record from the scan history table based upon the where clause in the
+// options. If there is no where clause, it will return the first record in the table
+func (dao *DAO) GetScanHistory(ctx context.Context, dbOpts *Options) (*models.ScanHistory, error) {
+	builderOpts := newBuilderOptions(models.SCAN_HISTORY_TABLE).
+		WithColumns(models.ScanHistoryColumns()...).
+		WithJoin(models.COURSE_TABLE, fmt.Sprintf("%s = %s", models.COURSE_TABLE_ID, models.SCAN_HISTORY_TABLE_COURSE_ID)).
+		SetDbOpts(dbOpts).
+		WithLimit(1)
+
+	return getGeneric[models.ScanHistory](ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ListScanHistory gets all records from the scan history table based upon the where clause and
+// pagination in the options
+func (dao *DAO) ListScanHistory(ctx context.Context, dbOpts *Options) ([]*models.ScanHistory, error) {
+	builderOpts := newBuilderOptions(models.SCAN_HISTORY_TABLE).
+		WithColumns(models.ScanHistoryColumns()...).
+		WithJoin(models.COURSE_TABLE, fmt.Sprintf("%s = %s", models.COURSE_TABLE_ID, models.SCAN_HISTORY_TABLE_COURSE_ID)).
+		SetDbOpts(dbOpts)
+
+	return listGeneric[models.ScanHistory](ctx, dao, *builderOpts)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DeleteScanHistory deletes records from the scan history table
+//
+// Errors when a where clause is not provided
+func (dao *DAO) DeleteScanHistory(ctx context.Context, dbOpts *Options) error {
+	if dbOpts == nil || dbOpts.Where == nil {
+		return utils.ErrWhere
+	}
+
+	builderOpts := newBuilderOptions(models.SCAN_HISTORY_TABLE).SetDbOpts(dbOpts)
+	sqlStr, args, _ := deleteBuilder(*builderOpts)
+
+	q := database.QuerierFromContext(ctx, dao.db)
+	_, err := q.ExecContext(ctx, sqlStr, args...)
+	return err
+}
@@ -0,0 +1,179 @@
+package dao
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_CreateScanHistory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, dao.CreateCourse(ctx, course))
+
+		history := &models.ScanHistory{CourseID: course.ID, Status: types.ScanStatusCompleted}
+		require.NoError(t, dao.CreateScanHistory(ctx, history))
+	})
+
+	t.Run("nil pointer", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.CreateScanHistory(ctx, nil), utils.ErrNilPtr)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		// Course ID
+		history := &models.ScanHistory{Status: types.ScanStatusCompleted}
+		require.ErrorIs(t, dao.CreateScanHistory(ctx, history), utils.ErrCourseId)
+
+		// Status
+		history = &models.ScanHistory{CourseID: "1234"}
+		require.ErrorIs(t, dao.CreateScanHistory(ctx, history), utils.ErrScanStatus)
+
+		// Course
+		history = &models.ScanHistory{CourseID: "1234", Status: types.ScanStatusCompleted}
+		require.ErrorContains(t, dao.CreateScanHistory(ctx, history), "FOREIGN KEY constraint failed")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_GetScanHistory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, dao.CreateCourse(ctx, course))
+
+		started := types.NowDateTime()
+		history := &models.ScanHistory{
+			CourseID:       course.ID,
+			Status:         types.ScanStatusFailed,
+			Error:          "something went wrong",
+			StartedAt:      started,
+			FinishedAt:     types.NowDateTime(),
+			Duration:       1500,
+			LessonsCreated: 2,
+			AssetsCreated:  3,
+			AssetsDeleted:  1,
+			Skipped: models.ScanSkippedFiles{
+				{Path: "/course-1/notes.txt", Reason: "unparseable"},
+			},
+		}
+		require.NoError(t, dao.CreateScanHistory(ctx, history))
+
+		dbOpts := NewOptions().WithWhere(squirrel.Eq{models.SCAN_HISTORY_TABLE_ID: history.ID})
+		record, err := dao.GetScanHistory(ctx, dbOpts)
+		require.Nil(t, err)
+		require.Equal(t, history.ID, record.ID)
+		require.Equal(t, course.ID, record.CourseID)
+		require.Equal(t, course.Title, record.CourseTitle)
+		require.Equal(t, types.ScanStatusFailed, record.Status)
+		require.Equal(t, "something went wrong", record.Error)
+		require.True(t, started.Equal(record.StartedAt))
+		require.Equal(t, 1500, record.Duration)
+		require.Equal(t, 2, record.LessonsCreated)
+		require.Equal(t, 3, record.AssetsCreated)
+		require.Equal(t, 1, record.AssetsDeleted)
+		require.Equal(t, history.Skipped, record.Skipped)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		record, err := dao.GetScanHistory(ctx, nil)
+		require.Nil(t, err)
+		require.Nil(t, record)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_ListScanHistory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, dao.CreateCourse(ctx, course))
+
+		histories := []*models.ScanHistory{}
+		for range 3 {
+			history := &models.ScanHistory{CourseID: course.ID, Status: types.ScanStatusCompleted}
+			histories = append(histories, history)
+			require.NoError(t, dao.CreateScanHistory(ctx, history))
+			time.Sleep(1 * time.Millisecond)
+		}
+
+		dbOpts := NewOptions().WithOrderBy(models.SCAN_HISTORY_TABLE_CREATED_AT + " ASC")
+		records, err := dao.ListScanHistory(ctx, dbOpts)
+		require.Nil(t, err)
+		require.Len(t, records, 3)
+
+		for i, record := range records {
+			require.Equal(t, histories[i].ID, record.ID)
+			require.Empty(t, record.Skipped)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		records, err := dao.ListScanHistory(ctx, nil)
+		require.Nil(t, err)
+		require.Empty(t, records)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_DeleteScanHistory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, dao.CreateCourse(ctx, course))
+
+		history := &models.ScanHistory{CourseID: course.ID, Status: types.ScanStatusCompleted}
+		require.NoError(t, dao.CreateScanHistory(ctx, history))
+
+		opts := NewOptions().WithWhere(squirrel.Eq{models.SCAN_HISTORY_TABLE_ID: history.ID})
+		require.Nil(t, dao.DeleteScanHistory(ctx, opts))
+
+		record, err := dao.GetScanHistory(ctx, opts)
+		require.Nil(t, err)
+		require.Nil(t, record)
+	})
+
+	t.Run("cascade", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, dao.CreateCourse(ctx, course))
+
+		history := &models.ScanHistory{CourseID: course.ID, Status: types.ScanStatusCompleted}
+		require.NoError(t, dao.CreateScanHistory(ctx, history))
+
+		require.Nil(t, dao.DeleteCourses(ctx, NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID})))
+
+		record, err := dao.GetScanHistory(ctx, NewOptions().WithWhere(squirrel.Eq{models.SCAN_HISTORY_TABLE_ID: history.ID}))
+		require.Nil(t, err)
+		require.Nil(t, record)
+	})
+
+	t.Run("no db options", func(t *testing.T) {
+		dao, ctx := setup(t)
+
+		require.ErrorIs(t, dao.DeleteScanHistory(ctx, nil), utils.ErrWhere)
+	})
+}
@@ -42,6 +42,8 @@ func (dao *DAO) CreateUser(ctx context.Context, user *models.User) error {
 				models.USER_ROLE:          user.Role,
 				models.BASE_CREATED_AT:    user.CreatedAt,
 				models.BASE_UPDATED_AT:    user.UpdatedAt,
+
+				models.USER_PREFERRED_AUDIO_LANGUAGE: user.PreferredAudioLanguage,
 			},
 		)
 
@@ -108,6 +110,8 @@ func (dao *DAO) UpdateUser(ctx context.Context, user *models.User) error {
 				models.USER_PASSWORD_HASH: user.PasswordHash,
 				models.USER_ROLE:          user.Role,
 				models.BASE_UPDATED_AT:    user.UpdatedAt,
+
+				models.USER_PREFERRED_AUDIO_LANGUAGE: user.PreferredAudioLanguage,
 			},
 		).
 		SetDbOpts(dbOpts)
@@ -258,6 +258,8 @@ func Test_UpdateUser(t *testing.T) {
 			DisplayName:  "Bob",              // Mutable
 			Role:         types.UserRoleUser, // Mutable
 			PasswordHash: "new password",     // Mutable
+
+			PreferredAudioLanguage: "fre", // Mutable
 		}
 		require.NoError(t, dao.UpdateUser(ctx, updatedUser))
 
@@ -270,6 +272,7 @@ func Test_UpdateUser(t *testing.T) {
 		require.Equal(t, updatedUser.DisplayName, record.DisplayName)    // Changed
 		require.Equal(t, updatedUser.PasswordHash, record.PasswordHash)  // Changed
 		require.Equal(t, updatedUser.Role, record.Role)                  // Changed
+		require.Equal(t, "fre", record.PreferredAudioLanguage)           // Changed
 		require.False(t, record.UpdatedAt.Equal(OriginalUser.UpdatedAt)) // Changed
 	})
 
@@ -0,0 +1,4 @@
+-- +goose Up
+
+-- Whether the course folder is watched for changes, which automatically queues a scan
+ALTER TABLE courses ADD COLUMN watch BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- Library roots are parent folders that are searched for course directories
+CREATE TABLE library_roots (
+	id                 TEXT PRIMARY KEY NOT NULL,
+	path               TEXT UNIQUE NOT NULL,
+	max_depth          INTEGER NOT NULL DEFAULT 2,
+	require_video      BOOLEAN NOT NULL DEFAULT FALSE,
+	require_numbered   BOOLEAN NOT NULL DEFAULT FALSE,
+	flag_missing       BOOLEAN NOT NULL DEFAULT FALSE,
+	last_discovered_at TEXT,
+	created_at         TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	updated_at         TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW'))
+);
+
+-- The library root a course was discovered under, if any
+ALTER TABLE courses ADD COLUMN library_root_id TEXT REFERENCES library_roots (id) ON DELETE SET NULL;
+
+-- Whether the course folder was found to be missing during library discovery
+ALTER TABLE courses ADD COLUMN missing BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- A record of each finished course scan
+CREATE TABLE scans_history (
+	id                  TEXT PRIMARY KEY NOT NULL,
+	course_id           TEXT NOT NULL,
+	status              TEXT NOT NULL,
+	error               TEXT NOT NULL DEFAULT '',
+	started_at          TEXT NOT NULL,
+	finished_at         TEXT NOT NULL,
+	duration            INTEGER NOT NULL DEFAULT 0,
+	lessons_created     INTEGER NOT NULL DEFAULT 0,
+	lessons_updated     INTEGER NOT NULL DEFAULT 0,
+	lessons_deleted     INTEGER NOT NULL DEFAULT 0,
+	assets_created      INTEGER NOT NULL DEFAULT 0,
+	assets_updated      INTEGER NOT NULL DEFAULT 0,
+	assets_deleted      INTEGER NOT NULL DEFAULT 0,
+	attachments_created INTEGER NOT NULL DEFAULT 0,
+	attachments_deleted INTEGER NOT NULL DEFAULT 0,
+	skipped             TEXT NOT NULL DEFAULT '[]',
+	created_at          TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	updated_at          TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	--
+	FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+);
+
+-- Scan history by course, newest first
+CREATE INDEX idx_scans_history_course_started ON scans_history(course_id, started_at);
@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- Course details read from course.json and the description.md, author.txt and README.md sidecars
+ALTER TABLE courses ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE courses ADD COLUMN authors TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE courses ADD COLUMN language TEXT NOT NULL DEFAULT '';
+ALTER TABLE courses ADD COLUMN difficulty TEXT NOT NULL DEFAULT '';
+ALTER TABLE courses ADD COLUMN release_date TEXT NOT NULL DEFAULT '';
+ALTER TABLE courses ADD COLUMN source_url TEXT NOT NULL DEFAULT '';
+
+-- Module display names and ordering overrides, stored as JSON
+ALTER TABLE courses ADD COLUMN modules TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Subtitle files that sit alongside a video asset, such as `01 - Intro.en.srt`
+CREATE TABLE asset_subtitles (
+	id         TEXT PRIMARY KEY NOT NULL,
+	asset_id   TEXT NOT NULL,
+	path       TEXT UNIQUE NOT NULL,
+	language   TEXT NOT NULL DEFAULT '',
+	format     TEXT NOT NULL,
+	created_at TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	updated_at TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	--
+	FOREIGN KEY (asset_id) REFERENCES assets (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_asset_subtitles_asset_id ON asset_subtitles(asset_id);
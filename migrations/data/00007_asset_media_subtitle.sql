@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- Text subtitle streams embedded in a video container, such as mov_text tracks in an MP4 or
+-- subrip/ass tracks in an MKV
+CREATE TABLE asset_media_subtitle (
+	id           TEXT PRIMARY KEY NOT NULL,
+	asset_id     TEXT NOT NULL,
+	stream_index INTEGER NOT NULL,               -- absolute stream index in the container
+	language     TEXT NOT NULL DEFAULT '',       -- "eng", "und"
+	title        TEXT NOT NULL DEFAULT '',       -- "English (SDH)"
+	codec        TEXT NOT NULL DEFAULT '',       -- "mov_text", "subrip", "ass"
+	is_default   BOOLEAN NOT NULL DEFAULT FALSE,
+	is_forced    BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at   TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	updated_at   TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	--
+	UNIQUE (asset_id, stream_index),
+	FOREIGN KEY (asset_id) REFERENCES assets (id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Audio assets have no video row, so the duration and the container tags are held on the
+-- audio row. These are left at their defaults for the audio stream of a video
+ALTER TABLE asset_media_audio ADD COLUMN duration_sec INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE asset_media_audio ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE asset_media_audio ADD COLUMN artist TEXT NOT NULL DEFAULT '';
+ALTER TABLE asset_media_audio ADD COLUMN album TEXT NOT NULL DEFAULT '';
+ALTER TABLE asset_media_audio ADD COLUMN has_cover_art BOOLEAN NOT NULL DEFAULT FALSE;
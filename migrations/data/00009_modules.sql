@@ -0,0 +1,21 @@
+-- +goose Up
+
+-- Modules are the directories of a course that hold lessons. A module may sit within another
+-- module, such as `01 Part One/02 Getting Started`, and lessons reference their module by path
+CREATE TABLE modules (
+	id         TEXT PRIMARY KEY NOT NULL,
+	course_id  TEXT NOT NULL,
+	parent_id  TEXT,
+	path       TEXT NOT NULL,
+	title      TEXT NOT NULL,
+	prefix     INTEGER,
+	created_at TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	updated_at TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	--
+	FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+	FOREIGN KEY (parent_id) REFERENCES modules (id) ON DELETE CASCADE,
+	UNIQUE(course_id, path)
+);
+
+CREATE INDEX idx_modules_course_id ON modules(course_id);
+CREATE INDEX idx_modules_parent_id ON modules(parent_id);
@@ -0,0 +1,6 @@
+-- +goose Up
+
+-- Where the course card comes from (file, generated or custom). Courses without a card are empty
+ALTER TABLE courses ADD COLUMN card_source TEXT NOT NULL DEFAULT '';
+
+UPDATE courses SET card_source = 'file' WHERE card_path IS NOT NULL AND card_path != '';
@@ -0,0 +1,25 @@
+-- +goose Up
+
+-- Audio streams in a video container, such as the dubbed tracks and 5.1 mixes of an MKV. The
+-- single-track asset_media_audio table still holds the default stream
+CREATE TABLE asset_media_audio_track (
+	id             TEXT PRIMARY KEY NOT NULL,
+	asset_id       TEXT NOT NULL,
+	stream_index   INTEGER NOT NULL,             -- absolute stream index in the container
+	track_index    INTEGER NOT NULL,             -- index among the audio streams (0:a:N)
+	language       TEXT NOT NULL DEFAULT '',     -- "eng", "und"
+	title          TEXT NOT NULL DEFAULT '',     -- "English (Commentary)"
+	codec          TEXT NOT NULL DEFAULT '',     -- "aac", "ac3", "eac3"
+	channels       INTEGER NOT NULL DEFAULT 0,
+	channel_layout TEXT NOT NULL DEFAULT '',     -- "stereo", "5.1(side)"
+	is_default     BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at     TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	updated_at     TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	--
+	UNIQUE (asset_id, track_index),
+	FOREIGN KEY (asset_id) REFERENCES assets (id) ON DELETE CASCADE
+);
+
+-- The audio language a user prefers. When an asset has a track in this language, it is the
+-- default rendition of the master playlists
+ALTER TABLE users ADD COLUMN preferred_audio_language TEXT NOT NULL DEFAULT '';
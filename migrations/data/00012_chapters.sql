@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Chapters embedded in the container of an asset, such as MKV chapters and MP4 chapter atoms
+CREATE TABLE asset_media_chapter (
+	id            TEXT PRIMARY KEY NOT NULL,
+	asset_id      TEXT NOT NULL,
+	chapter_index INTEGER NOT NULL,             -- position of the chapter in the container
+	start_sec     REAL NOT NULL DEFAULT 0,
+	end_sec       REAL NOT NULL DEFAULT 0,
+	title         TEXT NOT NULL DEFAULT '',     -- "Introduction"
+	created_at    TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	updated_at    TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')),
+	--
+	UNIQUE (asset_id, chapter_index),
+	FOREIGN KEY (asset_id) REFERENCES assets (id) ON DELETE CASCADE
+);
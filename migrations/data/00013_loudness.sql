@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- The EBU R128 integrated loudness of the default audio stream, in LUFS. NULL until it is
+-- measured, which happens the first time the asset is played with normalization enabled
+ALTER TABLE asset_media_audio ADD COLUMN loudness_lufs REAL;
+
+-- Whether the audio of the course is normalized, regardless of the transcoding profile
+ALTER TABLE courses ADD COLUMN normalize_audio BOOLEAN NOT NULL DEFAULT FALSE;
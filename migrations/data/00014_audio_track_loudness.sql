@@ -0,0 +1,5 @@
+-- +goose Up
+
+-- The EBU R128 integrated loudness of each audio stream, in LUFS. NULL until it is measured.
+-- Tracks of a video can be mixed at different levels, so each gets its own gain
+ALTER TABLE asset_media_audio_track ADD COLUMN loudness_lufs REAL;
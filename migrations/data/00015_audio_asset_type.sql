@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- Audio files used to be classified as videos. Their media rows were probed as videos, so they
+-- are cleared and the next scan probes them again as audio. LIKE is case-insensitive, matching
+-- how extensions are classified
+CREATE TEMP TABLE audio_assets AS
+	SELECT id FROM assets
+	WHERE type = 'video' AND (
+		path LIKE '%.flac' OR path LIKE '%.m4a' OR path LIKE '%.mp3' OR path LIKE '%.ogg' OR
+		path LIKE '%.oga' OR path LIKE '%.opus' OR path LIKE '%.wav'
+	);
+
+DELETE FROM asset_media_video WHERE asset_id IN (SELECT id FROM audio_assets);
+DELETE FROM asset_media_audio WHERE asset_id IN (SELECT id FROM audio_assets);
+DELETE FROM asset_media_audio_track WHERE asset_id IN (SELECT id FROM audio_assets);
+DELETE FROM asset_media_subtitle WHERE asset_id IN (SELECT id FROM audio_assets);
+DELETE FROM asset_media_chapter WHERE asset_id IN (SELECT id FROM audio_assets);
+DELETE FROM asset_keyframes WHERE asset_id IN (SELECT id FROM audio_assets);
+
+UPDATE assets SET type = 'audio' WHERE id IN (SELECT id FROM audio_assets);
+
+DROP TABLE audio_assets;
@@ -0,0 +1,7 @@
+-- +goose Up
+
+-- The version of the probe that stored the metadata of a video or audio asset. Assets probed by
+-- an older version are probed again on the next scan, so they gain what newer versions store,
+-- such as audio tracks and chapters
+ALTER TABLE asset_media_video ADD COLUMN probe_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE asset_media_audio ADD COLUMN probe_version INTEGER NOT NULL DEFAULT 0;
@@ -12,23 +12,27 @@ import (
 
 const (
 	// Tables
-	MEDIA_VIDEO_TABLE = "asset_media_video"
-	MEDIA_AUDIO_TABLE = "asset_media_audio"
+	MEDIA_VIDEO_TABLE       = "asset_media_video"
+	MEDIA_AUDIO_TABLE       = "asset_media_audio"
+	MEDIA_SUBTITLE_TABLE    = "asset_media_subtitle"
+	MEDIA_AUDIO_TRACK_TABLE = "asset_media_audio_track"
+	MEDIA_CHAPTER_TABLE     = "asset_media_chapter"
 
 	// Shared columns
 	META_ASSET_ID = "asset_id"
 
 	// Video table columns
-	MEDIA_VIDEO_DURATION    = "duration_sec"
-	MEDIA_VIDEO_CONTAINER   = "container"
-	MEDIA_VIDEO_MIME_TYPE   = "mime_type"
-	MEDIA_VIDEO_SIZE_BYTES  = "size_bytes"
-	MEDIA_VIDEO_OVERALL_BPS = "overall_bps"
-	MEDIA_VIDEO_CODEC       = "video_codec"
-	MEDIA_VIDEO_WIDTH       = "width"
-	MEDIA_VIDEO_HEIGHT      = "height"
-	MEDIA_VIDEO_FPS_NUM     = "fps_num"
-	MEDIA_VIDEO_FPS_DEN     = "fps_den"
+	MEDIA_VIDEO_DURATION      = "duration_sec"
+	MEDIA_VIDEO_CONTAINER     = "container"
+	MEDIA_VIDEO_MIME_TYPE     = "mime_type"
+	MEDIA_VIDEO_SIZE_BYTES    = "size_bytes"
+	MEDIA_VIDEO_OVERALL_BPS   = "overall_bps"
+	MEDIA_VIDEO_CODEC         = "video_codec"
+	MEDIA_VIDEO_WIDTH         = "width"
+	MEDIA_VIDEO_HEIGHT        = "height"
+	MEDIA_VIDEO_FPS_NUM       = "fps_num"
+	MEDIA_VIDEO_FPS_DEN       = "fps_den"
+	MEDIA_VIDEO_PROBE_VERSION = "probe_version"
 
 	// Audio table columns
 	MEDIA_AUDIO_LANGUAGE       = "language"
@@ -38,22 +42,55 @@ const (
 	MEDIA_AUDIO_CHANNEL_LAYOUT = "channel_layout"
 	MEDIA_AUDIO_SAMPLE_RATE    = "sample_rate"
 	MEDIA_AUDIO_BIT_RATE       = "bit_rate"
+	MEDIA_AUDIO_DURATION       = "duration_sec"
+	MEDIA_AUDIO_TITLE          = "title"
+	MEDIA_AUDIO_ARTIST         = "artist"
+	MEDIA_AUDIO_ALBUM          = "album"
+	MEDIA_AUDIO_HAS_COVER_ART  = "has_cover_art"
+	MEDIA_AUDIO_LOUDNESS_LUFS  = "loudness_lufs"
+	MEDIA_AUDIO_PROBE_VERSION  = "probe_version"
+
+	// Subtitle table columns
+	MEDIA_SUBTITLE_STREAM_INDEX = "stream_index"
+	MEDIA_SUBTITLE_LANGUAGE     = "language"
+	MEDIA_SUBTITLE_TITLE        = "title"
+	MEDIA_SUBTITLE_CODEC        = "codec"
+	MEDIA_SUBTITLE_IS_DEFAULT   = "is_default"
+	MEDIA_SUBTITLE_IS_FORCED    = "is_forced"
+
+	// Audio track table columns
+	MEDIA_AUDIO_TRACK_STREAM_INDEX   = "stream_index"
+	MEDIA_AUDIO_TRACK_TRACK_INDEX    = "track_index"
+	MEDIA_AUDIO_TRACK_LANGUAGE       = "language"
+	MEDIA_AUDIO_TRACK_TITLE          = "title"
+	MEDIA_AUDIO_TRACK_CODEC          = "codec"
+	MEDIA_AUDIO_TRACK_CHANNELS       = "channels"
+	MEDIA_AUDIO_TRACK_CHANNEL_LAYOUT = "channel_layout"
+	MEDIA_AUDIO_TRACK_IS_DEFAULT     = "is_default"
+	MEDIA_AUDIO_TRACK_LOUDNESS_LUFS  = "loudness_lufs"
+
+	// Chapter table columns
+	MEDIA_CHAPTER_CHAPTER_INDEX = "chapter_index"
+	MEDIA_CHAPTER_START_SEC     = "start_sec"
+	MEDIA_CHAPTER_END_SEC       = "end_sec"
+	MEDIA_CHAPTER_TITLE         = "title"
 
 	// Qualified video columns
-	MEDIA_VIDEO_TABLE_ID          = MEDIA_VIDEO_TABLE + "." + BASE_ID
-	MEDIA_VIDEO_TABLE_ASSET_ID    = MEDIA_VIDEO_TABLE + "." + META_ASSET_ID
-	MEDIA_VIDEO_TABLE_DURATION    = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_DURATION
-	MEDIA_VIDEO_TABLE_CONTAINER   = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_CONTAINER
-	MEDIA_VIDEO_TABLE_MIME_TYPE   = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_MIME_TYPE
-	MEDIA_VIDEO_TABLE_SIZE_BYTES  = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_SIZE_BYTES
-	MEDIA_VIDEO_TABLE_OVERALL_BPS = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_OVERALL_BPS
-	MEDIA_VIDEO_TABLE_CODEC       = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_CODEC
-	MEDIA_VIDEO_TABLE_WIDTH       = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_WIDTH
-	MEDIA_VIDEO_TABLE_HEIGHT      = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_HEIGHT
-	MEDIA_VIDEO_TABLE_FPS_NUM     = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_FPS_NUM
-	MEDIA_VIDEO_TABLE_FPS_DEN     = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_FPS_DEN
-	MEDIA_VIDEO_TABLE_CREATED_AT  = MEDIA_VIDEO_TABLE + "." + BASE_CREATED_AT
-	MEDIA_VIDEO_TABLE_UPDATED_AT  = MEDIA_VIDEO_TABLE + "." + BASE_UPDATED_AT
+	MEDIA_VIDEO_TABLE_ID            = MEDIA_VIDEO_TABLE + "." + BASE_ID
+	MEDIA_VIDEO_TABLE_ASSET_ID      = MEDIA_VIDEO_TABLE + "." + META_ASSET_ID
+	MEDIA_VIDEO_TABLE_DURATION      = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_DURATION
+	MEDIA_VIDEO_TABLE_CONTAINER     = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_CONTAINER
+	MEDIA_VIDEO_TABLE_MIME_TYPE     = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_MIME_TYPE
+	MEDIA_VIDEO_TABLE_SIZE_BYTES    = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_SIZE_BYTES
+	MEDIA_VIDEO_TABLE_OVERALL_BPS   = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_OVERALL_BPS
+	MEDIA_VIDEO_TABLE_CODEC         = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_CODEC
+	MEDIA_VIDEO_TABLE_WIDTH         = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_WIDTH
+	MEDIA_VIDEO_TABLE_HEIGHT        = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_HEIGHT
+	MEDIA_VIDEO_TABLE_FPS_NUM       = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_FPS_NUM
+	MEDIA_VIDEO_TABLE_FPS_DEN       = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_FPS_DEN
+	MEDIA_VIDEO_TABLE_PROBE_VERSION = MEDIA_VIDEO_TABLE + "." + MEDIA_VIDEO_PROBE_VERSION
+	MEDIA_VIDEO_TABLE_CREATED_AT    = MEDIA_VIDEO_TABLE + "." + BASE_CREATED_AT
+	MEDIA_VIDEO_TABLE_UPDATED_AT    = MEDIA_VIDEO_TABLE + "." + BASE_UPDATED_AT
 
 	// Qualified audio columns
 	MEDIA_AUDIO_TABLE_ID             = MEDIA_AUDIO_TABLE + "." + BASE_ID
@@ -65,8 +102,52 @@ const (
 	MEDIA_AUDIO_TABLE_CHANNEL_LAYOUT = MEDIA_AUDIO_TABLE + "." + MEDIA_AUDIO_CHANNEL_LAYOUT
 	MEDIA_AUDIO_TABLE_SAMPLE_RATE    = MEDIA_AUDIO_TABLE + "." + MEDIA_AUDIO_SAMPLE_RATE
 	MEDIA_AUDIO_TABLE_BIT_RATE       = MEDIA_AUDIO_TABLE + "." + MEDIA_AUDIO_BIT_RATE
+	MEDIA_AUDIO_TABLE_DURATION       = MEDIA_AUDIO_TABLE + "." + MEDIA_AUDIO_DURATION
+	MEDIA_AUDIO_TABLE_TITLE          = MEDIA_AUDIO_TABLE + "." + MEDIA_AUDIO_TITLE
+	MEDIA_AUDIO_TABLE_ARTIST         = MEDIA_AUDIO_TABLE + "." + MEDIA_AUDIO_ARTIST
+	MEDIA_AUDIO_TABLE_ALBUM          = MEDIA_AUDIO_TABLE + "." + MEDIA_AUDIO_ALBUM
+	MEDIA_AUDIO_TABLE_HAS_COVER_ART  = MEDIA_AUDIO_TABLE + "." + MEDIA_AUDIO_HAS_COVER_ART
+	MEDIA_AUDIO_TABLE_LOUDNESS_LUFS  = MEDIA_AUDIO_TABLE + "." + MEDIA_AUDIO_LOUDNESS_LUFS
+	MEDIA_AUDIO_TABLE_PROBE_VERSION  = MEDIA_AUDIO_TABLE + "." + MEDIA_AUDIO_PROBE_VERSION
 	MEDIA_AUDIO_TABLE_CREATED_AT     = MEDIA_AUDIO_TABLE + "." + BASE_CREATED_AT
 	MEDIA_AUDIO_TABLE_UPDATED_AT     = MEDIA_AUDIO_TABLE + "." + BASE_UPDATED_AT
+
+	// Qualified subtitle columns
+	MEDIA_SUBTITLE_TABLE_ID           = MEDIA_SUBTITLE_TABLE + "." + BASE_ID
+	MEDIA_SUBTITLE_TABLE_ASSET_ID     = MEDIA_SUBTITLE_TABLE + "." + META_ASSET_ID
+	MEDIA_SUBTITLE_TABLE_STREAM_INDEX = MEDIA_SUBTITLE_TABLE + "." + MEDIA_SUBTITLE_STREAM_INDEX
+	MEDIA_SUBTITLE_TABLE_LANGUAGE     = MEDIA_SUBTITLE_TABLE + "." + MEDIA_SUBTITLE_LANGUAGE
+	MEDIA_SUBTITLE_TABLE_TITLE        = MEDIA_SUBTITLE_TABLE + "." + MEDIA_SUBTITLE_TITLE
+	MEDIA_SUBTITLE_TABLE_CODEC        = MEDIA_SUBTITLE_TABLE + "." + MEDIA_SUBTITLE_CODEC
+	MEDIA_SUBTITLE_TABLE_IS_DEFAULT   = MEDIA_SUBTITLE_TABLE + "." + MEDIA_SUBTITLE_IS_DEFAULT
+	MEDIA_SUBTITLE_TABLE_IS_FORCED    = MEDIA_SUBTITLE_TABLE + "." + MEDIA_SUBTITLE_IS_FORCED
+	MEDIA_SUBTITLE_TABLE_CREATED_AT   = MEDIA_SUBTITLE_TABLE + "." + BASE_CREATED_AT
+	MEDIA_SUBTITLE_TABLE_UPDATED_AT   = MEDIA_SUBTITLE_TABLE + "." + BASE_UPDATED_AT
+
+	// Qualified audio track columns
+	MEDIA_AUDIO_TRACK_TABLE_ID             = MEDIA_AUDIO_TRACK_TABLE + "." + BASE_ID
+	MEDIA_AUDIO_TRACK_TABLE_ASSET_ID       = MEDIA_AUDIO_TRACK_TABLE + "." + META_ASSET_ID
+	MEDIA_AUDIO_TRACK_TABLE_STREAM_INDEX   = MEDIA_AUDIO_TRACK_TABLE + "." + MEDIA_AUDIO_TRACK_STREAM_INDEX
+	MEDIA_AUDIO_TRACK_TABLE_TRACK_INDEX    = MEDIA_AUDIO_TRACK_TABLE + "." + MEDIA_AUDIO_TRACK_TRACK_INDEX
+	MEDIA_AUDIO_TRACK_TABLE_LANGUAGE       = MEDIA_AUDIO_TRACK_TABLE + "." + MEDIA_AUDIO_TRACK_LANGUAGE
+	MEDIA_AUDIO_TRACK_TABLE_TITLE          = MEDIA_AUDIO_TRACK_TABLE + "." + MEDIA_AUDIO_TRACK_TITLE
+	MEDIA_AUDIO_TRACK_TABLE_CODEC          = MEDIA_AUDIO_TRACK_TABLE + "." + MEDIA_AUDIO_TRACK_CODEC
+	MEDIA_AUDIO_TRACK_TABLE_CHANNELS       = MEDIA_AUDIO_TRACK_TABLE + "." + MEDIA_AUDIO_TRACK_CHANNELS
+	MEDIA_AUDIO_TRACK_TABLE_CHANNEL_LAYOUT = MEDIA_AUDIO_TRACK_TABLE + "." + MEDIA_AUDIO_TRACK_CHANNEL_LAYOUT
+	MEDIA_AUDIO_TRACK_TABLE_IS_DEFAULT     = MEDIA_AUDIO_TRACK_TABLE + "." + MEDIA_AUDIO_TRACK_IS_DEFAULT
+	MEDIA_AUDIO_TRACK_TABLE_LOUDNESS_LUFS  = MEDIA_AUDIO_TRACK_TABLE + "." + MEDIA_AUDIO_TRACK_LOUDNESS_LUFS
+	MEDIA_AUDIO_TRACK_TABLE_CREATED_AT     = MEDIA_AUDIO_TRACK_TABLE + "." + BASE_CREATED_AT
+	MEDIA_AUDIO_TRACK_TABLE_UPDATED_AT     = MEDIA_AUDIO_TRACK_TABLE + "." + BASE_UPDATED_AT
+
+	// Qualified chapter columns
+	MEDIA_CHAPTER_TABLE_ID            = MEDIA_CHAPTER_TABLE + "." + BASE_ID
+	MEDIA_CHAPTER_TABLE_ASSET_ID      = MEDIA_CHAPTER_TABLE + "." + META_ASSET_ID
+	MEDIA_CHAPTER_TABLE_CHAPTER_INDEX = MEDIA_CHAPTER_TABLE + "." + MEDIA_CHAPTER_CHAPTER_INDEX
+	MEDIA_CHAPTER_TABLE_START_SEC     = MEDIA_CHAPTER_TABLE + "." + MEDIA_CHAPTER_START_SEC
+	MEDIA_CHAPTER_TABLE_END_SEC       = MEDIA_CHAPTER_TABLE + "." + MEDIA_CHAPTER_END_SEC
+	MEDIA_CHAPTER_TABLE_TITLE         = MEDIA_CHAPTER_TABLE + "." + MEDIA_CHAPTER_TITLE
+	MEDIA_CHAPTER_TABLE_CREATED_AT    = MEDIA_CHAPTER_TABLE + "." + BASE_CREATED_AT
+	MEDIA_CHAPTER_TABLE_UPDATED_AT    = MEDIA_CHAPTER_TABLE + "." + BASE_UPDATED_AT
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -78,6 +159,38 @@ type AssetMetadata struct {
 	// Joins
 	VideoMetadata *VideoMetadata
 	AudioMetadata *AudioMetadata
+
+	// Embedded subtitle streams. These are not joined and are only populated by
+	// `GetAssetMetadata()`
+	SubtitleMetadata []*SubtitleMetadata
+
+	// Every audio stream of a video, in container order. These are not joined and are only
+	// populated by `GetAssetMetadata()`
+	AudioTrackMetadata []*AudioTrackMetadata
+
+	// Chapters embedded in the container, in order. These are not joined and are populated by
+	// `GetAssetMetadata()` and when assets are fetched with their metadata
+	ChapterMetadata []*ChapterMetadata
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DurationSec returns the duration of the asset. Videos hold the duration on the video
+// metadata and audio assets on the audio metadata
+func (m *AssetMetadata) DurationSec() int {
+	if m == nil {
+		return 0
+	}
+
+	if m.VideoMetadata != nil {
+		return m.VideoMetadata.DurationSec
+	}
+
+	if m.AudioMetadata != nil {
+		return m.AudioMetadata.DurationSec
+	}
+
+	return 0
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -99,6 +212,9 @@ type VideoMetadata struct {
 	Height     int
 	FPSNum     int
 	FPSDen     int
+
+	// ProbeVersion is the version of the probe that stored the metadata of the video
+	ProbeVersion int
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -116,6 +232,7 @@ type VideoMetaJoinedRow struct {
 	Height       sql.NullInt64  `db:"height"`
 	FPSNum       sql.NullInt64  `db:"fps_num"`
 	FPSDen       sql.NullInt64  `db:"fps_den"`
+	ProbeVersion sql.NullInt64  `db:"probe_version"`
 	VideoCreated types.DateTime `db:"video_created_at"`
 	VideoUpdated types.DateTime `db:"video_updated_at"`
 }
@@ -132,22 +249,155 @@ type AudioMetadata struct {
 	ChannelLayout string
 	SampleRate    int
 	BitRate       int
+
+	// Audio assets only
+	DurationSec int
+	Title       string
+	Artist      string
+	Album       string
+	HasCoverArt bool
+
+	// LoudnessLUFS is the integrated loudness of the stream. It is not valid until measured
+	LoudnessLUFS sql.NullFloat64
+
+	// ProbeVersion is the version of the probe that stored the metadata of an audio asset
+	ProbeVersion int
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // AudioMetaJoinedRow is for use in scanning joined audio metadata rows
 type AudioMetaJoinedRow struct {
-	AudioID            sql.NullString `db:"audio_id"`
-	AudioLanguage      sql.NullString `db:"audio_language"`
-	AudioCodec         sql.NullString `db:"audio_codec"`
-	AudioProfile       sql.NullString `db:"audio_profile"`
-	AudioChannels      sql.NullInt64  `db:"audio_channels"`
-	AudioChannelLayout sql.NullString `db:"audio_channel_layout"`
-	AudioSampleRate    sql.NullInt64  `db:"audio_sample_rate"`
-	AudioBitRate       sql.NullInt64  `db:"audio_bit_rate"`
-	AudioCreated       types.DateTime `db:"audio_created_at"`
-	AudioUpdated       types.DateTime `db:"audio_updated_at"`
+	AudioID            sql.NullString  `db:"audio_id"`
+	AudioLanguage      sql.NullString  `db:"audio_language"`
+	AudioCodec         sql.NullString  `db:"audio_codec"`
+	AudioProfile       sql.NullString  `db:"audio_profile"`
+	AudioChannels      sql.NullInt64   `db:"audio_channels"`
+	AudioChannelLayout sql.NullString  `db:"audio_channel_layout"`
+	AudioSampleRate    sql.NullInt64   `db:"audio_sample_rate"`
+	AudioBitRate       sql.NullInt64   `db:"audio_bit_rate"`
+	AudioDurationSec   sql.NullInt64   `db:"audio_duration_sec"`
+	AudioTitle         sql.NullString  `db:"audio_title"`
+	AudioArtist        sql.NullString  `db:"audio_artist"`
+	AudioAlbum         sql.NullString  `db:"audio_album"`
+	AudioHasCoverArt   sql.NullBool    `db:"audio_has_cover_art"`
+	AudioLoudnessLUFS  sql.NullFloat64 `db:"audio_loudness_lufs"`
+	AudioProbeVersion  sql.NullInt64   `db:"audio_probe_version"`
+	AudioCreated       types.DateTime  `db:"audio_created_at"`
+	AudioUpdated       types.DateTime  `db:"audio_updated_at"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// SubtitleMetadata defines an embedded subtitle stream for an asset
+type SubtitleMetadata struct {
+	Base
+	AssetID     string `db:"asset_id"`     // Immutable
+	StreamIndex int    `db:"stream_index"` // Immutable
+	Language    string `db:"language"`     // Immutable
+	Title       string `db:"title"`        // Immutable
+	Codec       string `db:"codec"`        // Immutable
+	IsDefault   bool   `db:"is_default"`   // Immutable
+	IsForced    bool   `db:"is_forced"`    // Immutable
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// SubtitleMetadataColumns returns the list of columns to use when populating `SubtitleMetadata`
+func SubtitleMetadataColumns() []string {
+	return []string{
+		fmt.Sprintf("%s AS id", MEDIA_SUBTITLE_TABLE_ID),
+		fmt.Sprintf("%s AS created_at", MEDIA_SUBTITLE_TABLE_CREATED_AT),
+		fmt.Sprintf("%s AS updated_at", MEDIA_SUBTITLE_TABLE_UPDATED_AT),
+		fmt.Sprintf("%s AS asset_id", MEDIA_SUBTITLE_TABLE_ASSET_ID),
+		fmt.Sprintf("%s AS stream_index", MEDIA_SUBTITLE_TABLE_STREAM_INDEX),
+		fmt.Sprintf("%s AS language", MEDIA_SUBTITLE_TABLE_LANGUAGE),
+		fmt.Sprintf("%s AS title", MEDIA_SUBTITLE_TABLE_TITLE),
+		fmt.Sprintf("%s AS codec", MEDIA_SUBTITLE_TABLE_CODEC),
+		fmt.Sprintf("%s AS is_default", MEDIA_SUBTITLE_TABLE_IS_DEFAULT),
+		fmt.Sprintf("%s AS is_forced", MEDIA_SUBTITLE_TABLE_IS_FORCED),
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AudioTrackMetadata defines an audio stream of an asset
+type AudioTrackMetadata struct {
+	Base
+	AssetID       string `db:"asset_id"`       // Immutable
+	StreamIndex   int    `db:"stream_index"`   // Immutable
+	TrackIndex    int    `db:"track_index"`    // Immutable
+	Language      string `db:"language"`       // Immutable
+	Title         string `db:"title"`          // Immutable
+	Codec         string `db:"codec"`          // Immutable
+	Channels      int    `db:"channels"`       // Immutable
+	ChannelLayout string `db:"channel_layout"` // Immutable
+	IsDefault     bool   `db:"is_default"`     // Immutable
+
+	// LoudnessLUFS is the integrated loudness of the track. It is not valid until measured
+	LoudnessLUFS sql.NullFloat64 `db:"loudness_lufs"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AudioTrackMetadataColumns returns the list of columns to use when populating
+// `AudioTrackMetadata`
+func AudioTrackMetadataColumns() []string {
+	return []string{
+		fmt.Sprintf("%s AS id", MEDIA_AUDIO_TRACK_TABLE_ID),
+		fmt.Sprintf("%s AS created_at", MEDIA_AUDIO_TRACK_TABLE_CREATED_AT),
+		fmt.Sprintf("%s AS updated_at", MEDIA_AUDIO_TRACK_TABLE_UPDATED_AT),
+		fmt.Sprintf("%s AS asset_id", MEDIA_AUDIO_TRACK_TABLE_ASSET_ID),
+		fmt.Sprintf("%s AS stream_index", MEDIA_AUDIO_TRACK_TABLE_STREAM_INDEX),
+		fmt.Sprintf("%s AS track_index", MEDIA_AUDIO_TRACK_TABLE_TRACK_INDEX),
+		fmt.Sprintf("%s AS language", MEDIA_AUDIO_TRACK_TABLE_LANGUAGE),
+		fmt.Sprintf("%s AS title", MEDIA_AUDIO_TRACK_TABLE_TITLE),
+		fmt.Sprintf("%s AS codec", MEDIA_AUDIO_TRACK_TABLE_CODEC),
+		fmt.Sprintf("%s AS channels", MEDIA_AUDIO_TRACK_TABLE_CHANNELS),
+		fmt.Sprintf("%s AS channel_layout", MEDIA_AUDIO_TRACK_TABLE_CHANNEL_LAYOUT),
+		fmt.Sprintf("%s AS is_default", MEDIA_AUDIO_TRACK_TABLE_IS_DEFAULT),
+		fmt.Sprintf("%s AS loudness_lufs", MEDIA_AUDIO_TRACK_TABLE_LOUDNESS_LUFS),
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ChapterMetadata defines a chapter embedded in the container of an asset
+type ChapterMetadata struct {
+	Base
+	AssetID      string  `db:"asset_id"`      // Immutable
+	ChapterIndex int     `db:"chapter_index"` // Immutable
+	StartSec     float64 `db:"start_sec"`     // Immutable
+	EndSec       float64 `db:"end_sec"`       // Immutable
+	Title        string  `db:"title"`         // Immutable
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DisplayTitle returns the title of the chapter, falling back to its position for chapters
+// without a title
+func (c *ChapterMetadata) DisplayTitle() string {
+	if c.Title != "" {
+		return c.Title
+	}
+
+	return fmt.Sprintf("Chapter %d", c.ChapterIndex+1)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ChapterMetadataColumns returns the list of columns to use when populating `ChapterMetadata`
+func ChapterMetadataColumns() []string {
+	return []string{
+		fmt.Sprintf("%s AS id", MEDIA_CHAPTER_TABLE_ID),
+		fmt.Sprintf("%s AS created_at", MEDIA_CHAPTER_TABLE_CREATED_AT),
+		fmt.Sprintf("%s AS updated_at", MEDIA_CHAPTER_TABLE_UPDATED_AT),
+		fmt.Sprintf("%s AS asset_id", MEDIA_CHAPTER_TABLE_ASSET_ID),
+		fmt.Sprintf("%s AS chapter_index", MEDIA_CHAPTER_TABLE_CHAPTER_INDEX),
+		fmt.Sprintf("%s AS start_sec", MEDIA_CHAPTER_TABLE_START_SEC),
+		fmt.Sprintf("%s AS end_sec", MEDIA_CHAPTER_TABLE_END_SEC),
+		fmt.Sprintf("%s AS title", MEDIA_CHAPTER_TABLE_TITLE),
+	}
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -174,16 +424,17 @@ func (r *AssetMetadataRow) ToDomain() *AssetMetadata {
 				CreatedAt: r.VideoCreated,
 				UpdatedAt: r.VideoUpdated,
 			},
-			DurationSec: int(r.DurationSec.Int64),
-			Container:   r.Container.String,
-			MIMEType:    r.MIMEType.String,
-			SizeBytes:   r.SizeBytes.Int64,
-			OverallBPS:  int(r.OverallBPS.Int64),
-			VideoCodec:  strings.ToLower(r.VideoCodec.String),
-			Width:       int(r.Width.Int64),
-			Height:      int(r.Height.Int64),
-			FPSNum:      int(r.FPSNum.Int64),
-			FPSDen:      int(r.FPSDen.Int64),
+			DurationSec:  int(r.DurationSec.Int64),
+			Container:    r.Container.String,
+			MIMEType:     r.MIMEType.String,
+			SizeBytes:    r.SizeBytes.Int64,
+			OverallBPS:   int(r.OverallBPS.Int64),
+			VideoCodec:   strings.ToLower(r.VideoCodec.String),
+			Width:        int(r.Width.Int64),
+			Height:       int(r.Height.Int64),
+			FPSNum:       int(r.FPSNum.Int64),
+			FPSDen:       int(r.FPSDen.Int64),
+			ProbeVersion: int(r.ProbeVersion.Int64),
 		}
 	}
 
@@ -214,6 +465,13 @@ func (r *AssetMetadataRow) ToDomain() *AssetMetadata {
 			ChannelLayout: r.AudioChannelLayout.String,
 			SampleRate:    int(r.AudioSampleRate.Int64),
 			BitRate:       int(r.AudioBitRate.Int64),
+			DurationSec:   int(r.AudioDurationSec.Int64),
+			Title:         r.AudioTitle.String,
+			Artist:        r.AudioArtist.String,
+			Album:         r.AudioAlbum.String,
+			HasCoverArt:   r.AudioHasCoverArt.Bool,
+			LoudnessLUFS:  r.AudioLoudnessLUFS,
+			ProbeVersion:  int(r.AudioProbeVersion.Int64),
 		}
 	}
 
@@ -239,6 +497,7 @@ func AssetMetadataRowColumns() []string {
 		fmt.Sprintf("%s AS height", MEDIA_VIDEO_TABLE_HEIGHT),
 		fmt.Sprintf("%s AS fps_num", MEDIA_VIDEO_TABLE_FPS_NUM),
 		fmt.Sprintf("%s AS fps_den", MEDIA_VIDEO_TABLE_FPS_DEN),
+		fmt.Sprintf("%s AS probe_version", MEDIA_VIDEO_TABLE_PROBE_VERSION),
 		fmt.Sprintf("%s AS video_created_at", MEDIA_VIDEO_TABLE_CREATED_AT),
 		fmt.Sprintf("%s AS video_updated_at", MEDIA_VIDEO_TABLE_UPDATED_AT),
 
@@ -251,6 +510,13 @@ func AssetMetadataRowColumns() []string {
 		fmt.Sprintf("%s AS audio_channel_layout", MEDIA_AUDIO_TABLE_CHANNEL_LAYOUT),
 		fmt.Sprintf("%s AS audio_sample_rate", MEDIA_AUDIO_TABLE_SAMPLE_RATE),
 		fmt.Sprintf("%s AS audio_bit_rate", MEDIA_AUDIO_BIT_RATE),
+		fmt.Sprintf("%s AS audio_duration_sec", MEDIA_AUDIO_TABLE_DURATION),
+		fmt.Sprintf("%s AS audio_title", MEDIA_AUDIO_TABLE_TITLE),
+		fmt.Sprintf("%s AS audio_artist", MEDIA_AUDIO_TABLE_ARTIST),
+		fmt.Sprintf("%s AS audio_album", MEDIA_AUDIO_TABLE_ALBUM),
+		fmt.Sprintf("%s AS audio_has_cover_art", MEDIA_AUDIO_TABLE_HAS_COVER_ART),
+		fmt.Sprintf("%s AS audio_loudness_lufs", MEDIA_AUDIO_TABLE_LOUDNESS_LUFS),
+		fmt.Sprintf("%s AS audio_probe_version", MEDIA_AUDIO_TABLE_PROBE_VERSION),
 		fmt.Sprintf("%s AS audio_created_at", MEDIA_AUDIO_TABLE_CREATED_AT),
 		fmt.Sprintf("%s AS audio_updated_at", MEDIA_AUDIO_TABLE_UPDATED_AT),
 	}
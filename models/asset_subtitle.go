@@ -0,0 +1,52 @@
+package models
+
+import "fmt"
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	ASSET_SUBTITLE_TABLE = "asset_subtitles"
+
+	ASSET_SUBTITLE_ASSET_ID = "asset_id"
+	ASSET_SUBTITLE_PATH     = "path"
+	ASSET_SUBTITLE_LANGUAGE = "language"
+	ASSET_SUBTITLE_FORMAT   = "format"
+
+	ASSET_SUBTITLE_TABLE_ID         = ASSET_SUBTITLE_TABLE + "." + BASE_ID
+	ASSET_SUBTITLE_TABLE_CREATED_AT = ASSET_SUBTITLE_TABLE + "." + BASE_CREATED_AT
+	ASSET_SUBTITLE_TABLE_UPDATED_AT = ASSET_SUBTITLE_TABLE + "." + BASE_UPDATED_AT
+	ASSET_SUBTITLE_TABLE_ASSET_ID   = ASSET_SUBTITLE_TABLE + "." + ASSET_SUBTITLE_ASSET_ID
+	ASSET_SUBTITLE_TABLE_PATH       = ASSET_SUBTITLE_TABLE + "." + ASSET_SUBTITLE_PATH
+	ASSET_SUBTITLE_TABLE_LANGUAGE   = ASSET_SUBTITLE_TABLE + "." + ASSET_SUBTITLE_LANGUAGE
+	ASSET_SUBTITLE_TABLE_FORMAT     = ASSET_SUBTITLE_TABLE + "." + ASSET_SUBTITLE_FORMAT
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AssetSubtitle defines the model for a subtitle file that belongs to a video asset
+type AssetSubtitle struct {
+	Base
+	AssetID  string `db:"asset_id"` // Mutable
+	Path     string `db:"path"`     // Immutable
+	Language string `db:"language"` // Mutable
+	Format   string `db:"format"`   // Mutable
+
+	// Joins
+	CourseID string `db:"course_id"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AssetSubtitleColumns returns the list of columns to use when populating `AssetSubtitle`
+func AssetSubtitleColumns() []string {
+	return []string{
+		fmt.Sprintf("%s AS id", ASSET_SUBTITLE_TABLE_ID),
+		fmt.Sprintf("%s AS created_at", ASSET_SUBTITLE_TABLE_CREATED_AT),
+		fmt.Sprintf("%s AS updated_at", ASSET_SUBTITLE_TABLE_UPDATED_AT),
+		fmt.Sprintf("%s AS asset_id", ASSET_SUBTITLE_TABLE_ASSET_ID),
+		fmt.Sprintf("%s AS path", ASSET_SUBTITLE_TABLE_PATH),
+		fmt.Sprintf("%s AS language", ASSET_SUBTITLE_TABLE_LANGUAGE),
+		fmt.Sprintf("%s AS format", ASSET_SUBTITLE_TABLE_FORMAT),
+		fmt.Sprintf("%s AS course_id", ASSET_TABLE_COURSE_ID),
+	}
+}
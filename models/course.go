@@ -2,7 +2,12 @@ package models
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
+	"path"
+
+	"github.com/geerew/off-course/utils/types"
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -10,28 +15,52 @@ import (
 const (
 	COURSE_TABLE = "courses"
 
-	COURSE_TITLE         = "title"
-	COURSE_PATH          = "path"
-	COURSE_CARD_PATH     = "card_path"
-	COURSE_CARD_HASH     = "card_hash"
-	COURSE_CARD_MOD_TIME = "card_mod_time"
-	COURSE_AVAILABLE     = "available"
-	COURSE_DURATION      = "duration"
-	COURSE_INITIAL_SCAN  = "initial_scan"
-	COURSE_MAINTENANCE   = "maintenance"
-
-	COURSE_TABLE_ID            = COURSE_TABLE + "." + BASE_ID
-	COURSE_TABLE_CREATED_AT    = COURSE_TABLE + "." + BASE_CREATED_AT
-	COURSE_TABLE_UPDATED_AT    = COURSE_TABLE + "." + BASE_UPDATED_AT
-	COURSE_TABLE_TITLE         = COURSE_TABLE + "." + COURSE_TITLE
-	COURSE_TABLE_PATH          = COURSE_TABLE + "." + COURSE_PATH
-	COURSE_TABLE_CARD_PATH     = COURSE_TABLE + "." + COURSE_CARD_PATH
-	COURSE_TABLE_CARD_HASH     = COURSE_TABLE + "." + COURSE_CARD_HASH
-	COURSE_TABLE_CARD_MOD_TIME = COURSE_TABLE + "." + COURSE_CARD_MOD_TIME
-	COURSE_TABLE_AVAILABLE     = COURSE_TABLE + "." + COURSE_AVAILABLE
-	COURSE_TABLE_DURATION      = COURSE_TABLE + "." + COURSE_DURATION
-	COURSE_TABLE_INITIAL_SCAN  = COURSE_TABLE + "." + COURSE_INITIAL_SCAN
-	COURSE_TABLE_MAINTENANCE   = COURSE_TABLE + "." + COURSE_MAINTENANCE
+	COURSE_TITLE           = "title"
+	COURSE_PATH            = "path"
+	COURSE_CARD_PATH       = "card_path"
+	COURSE_CARD_HASH       = "card_hash"
+	COURSE_CARD_MOD_TIME   = "card_mod_time"
+	COURSE_CARD_SOURCE     = "card_source"
+	COURSE_AVAILABLE       = "available"
+	COURSE_DURATION        = "duration"
+	COURSE_INITIAL_SCAN    = "initial_scan"
+	COURSE_MAINTENANCE     = "maintenance"
+	COURSE_WATCH           = "watch"
+	COURSE_LIBRARY_ROOT_ID = "library_root_id"
+	COURSE_MISSING         = "missing"
+	COURSE_DESCRIPTION     = "description"
+	COURSE_AUTHORS         = "authors"
+	COURSE_LANGUAGE        = "language"
+	COURSE_DIFFICULTY      = "difficulty"
+	COURSE_RELEASE_DATE    = "release_date"
+	COURSE_SOURCE_URL      = "source_url"
+	COURSE_MODULES         = "modules"
+	COURSE_NORMALIZE_AUDIO = "normalize_audio"
+
+	COURSE_TABLE_ID              = COURSE_TABLE + "." + BASE_ID
+	COURSE_TABLE_CREATED_AT      = COURSE_TABLE + "." + BASE_CREATED_AT
+	COURSE_TABLE_UPDATED_AT      = COURSE_TABLE + "." + BASE_UPDATED_AT
+	COURSE_TABLE_TITLE           = COURSE_TABLE + "." + COURSE_TITLE
+	COURSE_TABLE_PATH            = COURSE_TABLE + "." + COURSE_PATH
+	COURSE_TABLE_CARD_PATH       = COURSE_TABLE + "." + COURSE_CARD_PATH
+	COURSE_TABLE_CARD_HASH       = COURSE_TABLE + "." + COURSE_CARD_HASH
+	COURSE_TABLE_CARD_MOD_TIME   = COURSE_TABLE + "." + COURSE_CARD_MOD_TIME
+	COURSE_TABLE_CARD_SOURCE     = COURSE_TABLE + "." + COURSE_CARD_SOURCE
+	COURSE_TABLE_AVAILABLE       = COURSE_TABLE + "." + COURSE_AVAILABLE
+	COURSE_TABLE_DURATION        = COURSE_TABLE + "." + COURSE_DURATION
+	COURSE_TABLE_INITIAL_SCAN    = COURSE_TABLE + "." + COURSE_INITIAL_SCAN
+	COURSE_TABLE_MAINTENANCE     = COURSE_TABLE + "." + COURSE_MAINTENANCE
+	COURSE_TABLE_WATCH           = COURSE_TABLE + "." + COURSE_WATCH
+	COURSE_TABLE_LIBRARY_ROOT_ID = COURSE_TABLE + "." + COURSE_LIBRARY_ROOT_ID
+	COURSE_TABLE_MISSING         = COURSE_TABLE + "." + COURSE_MISSING
+	COURSE_TABLE_DESCRIPTION     = COURSE_TABLE + "." + COURSE_DESCRIPTION
+	COURSE_TABLE_AUTHORS         = COURSE_TABLE + "." + COURSE_AUTHORS
+	COURSE_TABLE_LANGUAGE        = COURSE_TABLE + "." + COURSE_LANGUAGE
+	COURSE_TABLE_DIFFICULTY      = COURSE_TABLE + "." + COURSE_DIFFICULTY
+	COURSE_TABLE_RELEASE_DATE    = COURSE_TABLE + "." + COURSE_RELEASE_DATE
+	COURSE_TABLE_SOURCE_URL      = COURSE_TABLE + "." + COURSE_SOURCE_URL
+	COURSE_TABLE_MODULES         = COURSE_TABLE + "." + COURSE_MODULES
+	COURSE_TABLE_NORMALIZE_AUDIO = COURSE_TABLE + "." + COURSE_NORMALIZE_AUDIO
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -39,15 +68,32 @@ const (
 // Course defines the model for a course
 type Course struct {
 	Base
-	Title       string `db:"title"`         // Mutable
-	Path        string `db:"path"`          // Mutable
-	CardPath    string `db:"card_path"`     // Mutable
-	CardHash    string `db:"card_hash"`     // Mutable
-	CardModTime string `db:"card_mod_time"` // Mutable
-	Available   bool   `db:"available"`     // Mutable
-	Duration    int    `db:"duration"`      // Mutable
-	InitialScan bool   `db:"initial_scan"`  // Mutable
-	Maintenance bool   `db:"maintenance"`   // Mutable
+	Title         string           `db:"title"`           // Mutable
+	Path          string           `db:"path"`            // Mutable
+	CardPath      string           `db:"card_path"`       // Mutable
+	CardHash      string           `db:"card_hash"`       // Mutable
+	CardModTime   string           `db:"card_mod_time"`   // Mutable
+	CardSource    types.CardSource `db:"card_source"`     // Mutable
+	Available     bool             `db:"available"`       // Mutable
+	Duration      int              `db:"duration"`        // Mutable
+	InitialScan   bool             `db:"initial_scan"`    // Mutable
+	Maintenance   bool             `db:"maintenance"`     // Mutable
+	Watch         bool             `db:"watch"`           // Mutable
+	LibraryRootID sql.NullString   `db:"library_root_id"` // Mutable
+	Missing       bool             `db:"missing"`         // Mutable
+
+	// Details from course.json and sidecar files
+	Description string        `db:"description"`  // Mutable
+	Authors     CourseAuthors `db:"authors"`      // Mutable
+	Language    string        `db:"language"`     // Mutable
+	Difficulty  string        `db:"difficulty"`   // Mutable
+	ReleaseDate string        `db:"release_date"` // Mutable
+	SourceURL   string        `db:"source_url"`   // Mutable
+	Modules     CourseModules `db:"modules"`      // Mutable
+
+	// NormalizeAudio applies loudness normalization to the course, even when it is disabled in
+	// the transcoding profile
+	NormalizeAudio bool `db:"normalize_audio"` // Mutable
 
 	// Relation
 	Progress   *CourseProgress `db:"-"`
@@ -67,11 +113,142 @@ func CourseColumns() []string {
 		fmt.Sprintf("%s AS card_path", COURSE_TABLE_CARD_PATH),
 		fmt.Sprintf("%s AS card_hash", COURSE_TABLE_CARD_HASH),
 		fmt.Sprintf("%s AS card_mod_time", COURSE_TABLE_CARD_MOD_TIME),
+		fmt.Sprintf("%s AS card_source", COURSE_TABLE_CARD_SOURCE),
 		fmt.Sprintf("%s AS available", COURSE_TABLE_AVAILABLE),
 		fmt.Sprintf("%s AS duration", COURSE_TABLE_DURATION),
 		fmt.Sprintf("%s AS initial_scan", COURSE_TABLE_INITIAL_SCAN),
 		fmt.Sprintf("%s AS maintenance", COURSE_TABLE_MAINTENANCE),
+		fmt.Sprintf("%s AS watch", COURSE_TABLE_WATCH),
+		fmt.Sprintf("%s AS library_root_id", COURSE_TABLE_LIBRARY_ROOT_ID),
+		fmt.Sprintf("%s AS missing", COURSE_TABLE_MISSING),
+		fmt.Sprintf("%s AS description", COURSE_TABLE_DESCRIPTION),
+		fmt.Sprintf("%s AS authors", COURSE_TABLE_AUTHORS),
+		fmt.Sprintf("%s AS language", COURSE_TABLE_LANGUAGE),
+		fmt.Sprintf("%s AS difficulty", COURSE_TABLE_DIFFICULTY),
+		fmt.Sprintf("%s AS release_date", COURSE_TABLE_RELEASE_DATE),
+		fmt.Sprintf("%s AS source_url", COURSE_TABLE_SOURCE_URL),
+		fmt.Sprintf("%s AS modules", COURSE_TABLE_MODULES),
+		fmt.Sprintf("%s AS normalize_audio", COURSE_TABLE_NORMALIZE_AUDIO),
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CourseAuthors is a list of course authors that is stored as JSON
+type CourseAuthors []string
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Value implements the `driver.Valuer` interface
+func (a CourseAuthors) Value() (driver.Value, error) {
+	if a == nil {
+		a = CourseAuthors{}
+	}
+
+	data, err := json.Marshal([]string(a))
+
+	return string(data), err
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Scan implements `sql.Scanner` interface
+func (a *CourseAuthors) Scan(value any) error {
+	data, err := jsonListData(value, "CourseAuthors")
+	if err != nil {
+		return err
 	}
+
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CourseModule overrides how a module (a directory within the course) is displayed
+type CourseModule struct {
+	// The module directory name, or its path within the course (e.g. `01 Basics/02 Setup`) to
+	// tell apart nested modules with the same name
+	Name string `json:"name"`
+
+	// The display name. Empty to use the directory name
+	Title string `json:"title,omitempty"`
+
+	// The display position. Modules without an order are shown after those with one
+	Order *int `json:"order,omitempty"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CourseModules is a list of module overrides that is stored as JSON
+type CourseModules []CourseModule
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Value implements the `driver.Valuer` interface
+func (m CourseModules) Value() (driver.Value, error) {
+	if m == nil {
+		m = CourseModules{}
+	}
+
+	data, err := json.Marshal([]CourseModule(m))
+
+	return string(data), err
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Scan implements `sql.Scanner` interface
+func (m *CourseModules) Scan(value any) error {
+	data, err := jsonListData(value, "CourseModules")
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, (*[]CourseModule)(m))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Find returns the override for the module at the given path within the course, or nil. An
+// override naming the full path wins over one naming only the directory
+func (m CourseModules) Find(modulePath string) *CourseModule {
+	for i := range m {
+		if m[i].Name == modulePath {
+			return &m[i]
+		}
+	}
+
+	name := path.Base(modulePath)
+	for i := range m {
+		if m[i].Name == name {
+			return &m[i]
+		}
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// jsonListData returns the raw JSON of a database value holding a JSON list
+func jsonListData(value any, name string) ([]byte, error) {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		// no cast needed
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil, fmt.Errorf("failed to unmarshal %s value: %q", name, value)
+	}
+
+	if len(data) == 0 {
+		data = []byte("[]")
+	}
+
+	return data, nil
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -97,15 +274,28 @@ func (r *CourseRow) ToDomain() *Course {
 			CreatedAt: r.CreatedAt,
 			UpdatedAt: r.UpdatedAt,
 		},
-		Title:       r.Title,
-		Path:        r.Path,
-		CardPath:    r.CardPath,
-		CardHash:    r.CardHash,
-		CardModTime: r.CardModTime,
-		Available:   r.Available,
-		Duration:    r.Duration,
-		InitialScan: r.InitialScan,
-		Maintenance: r.Maintenance,
+		Title:         r.Title,
+		Path:          r.Path,
+		CardPath:      r.CardPath,
+		CardHash:      r.CardHash,
+		CardModTime:   r.CardModTime,
+		CardSource:    r.CardSource,
+		Available:     r.Available,
+		Duration:      r.Duration,
+		InitialScan:   r.InitialScan,
+		Maintenance:   r.Maintenance,
+		Watch:         r.Watch,
+		LibraryRootID: r.LibraryRootID,
+		Missing:       r.Missing,
+		Description:   r.Description,
+		Authors:       r.Authors,
+		Language:      r.Language,
+		Difficulty:    r.Difficulty,
+		ReleaseDate:   r.ReleaseDate,
+		SourceURL:     r.SourceURL,
+		Modules:       r.Modules,
+
+		NormalizeAudio: r.NormalizeAudio,
 	}
 
 	c.Progress = r.CourseProgressRow.ToDomain()
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/geerew/off-course/utils/types"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	LIBRARY_ROOT_TABLE = "library_roots"
+
+	LIBRARY_ROOT_PATH               = "path"
+	LIBRARY_ROOT_MAX_DEPTH          = "max_depth"
+	LIBRARY_ROOT_REQUIRE_VIDEO      = "require_video"
+	LIBRARY_ROOT_REQUIRE_NUMBERED   = "require_numbered"
+	LIBRARY_ROOT_FLAG_MISSING       = "flag_missing"
+	LIBRARY_ROOT_LAST_DISCOVERED_AT = "last_discovered_at"
+
+	LIBRARY_ROOT_TABLE_ID                 = LIBRARY_ROOT_TABLE + "." + BASE_ID
+	LIBRARY_ROOT_TABLE_CREATED_AT         = LIBRARY_ROOT_TABLE + "." + BASE_CREATED_AT
+	LIBRARY_ROOT_TABLE_UPDATED_AT         = LIBRARY_ROOT_TABLE + "." + BASE_UPDATED_AT
+	LIBRARY_ROOT_TABLE_PATH               = LIBRARY_ROOT_TABLE + "." + LIBRARY_ROOT_PATH
+	LIBRARY_ROOT_TABLE_MAX_DEPTH          = LIBRARY_ROOT_TABLE + "." + LIBRARY_ROOT_MAX_DEPTH
+	LIBRARY_ROOT_TABLE_REQUIRE_VIDEO      = LIBRARY_ROOT_TABLE + "." + LIBRARY_ROOT_REQUIRE_VIDEO
+	LIBRARY_ROOT_TABLE_REQUIRE_NUMBERED   = LIBRARY_ROOT_TABLE + "." + LIBRARY_ROOT_REQUIRE_NUMBERED
+	LIBRARY_ROOT_TABLE_FLAG_MISSING       = LIBRARY_ROOT_TABLE + "." + LIBRARY_ROOT_FLAG_MISSING
+	LIBRARY_ROOT_TABLE_LAST_DISCOVERED_AT = LIBRARY_ROOT_TABLE + "." + LIBRARY_ROOT_LAST_DISCOVERED_AT
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// LibraryRoot defines the model for a library root, which is a parent directory that is
+// searched for course directories
+type LibraryRoot struct {
+	Base
+	Path             string         `db:"path"`               // Mutable
+	MaxDepth         int            `db:"max_depth"`          // Mutable
+	RequireVideo     bool           `db:"require_video"`      // Mutable
+	RequireNumbered  bool           `db:"require_numbered"`   // Mutable
+	FlagMissing      bool           `db:"flag_missing"`       // Mutable
+	LastDiscoveredAt types.DateTime `db:"last_discovered_at"` // Mutable
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// LibraryRootColumns returns the list of columns to use when populating `LibraryRoot`
+func LibraryRootColumns() []string {
+	return []string{
+		fmt.Sprintf("%s AS id", LIBRARY_ROOT_TABLE_ID),
+		fmt.Sprintf("%s AS created_at", LIBRARY_ROOT_TABLE_CREATED_AT),
+		fmt.Sprintf("%s AS updated_at", LIBRARY_ROOT_TABLE_UPDATED_AT),
+		fmt.Sprintf("%s AS path", LIBRARY_ROOT_TABLE_PATH),
+		fmt.Sprintf("%s AS max_depth", LIBRARY_ROOT_TABLE_MAX_DEPTH),
+		fmt.Sprintf("%s AS require_video", LIBRARY_ROOT_TABLE_REQUIRE_VIDEO),
+		fmt.Sprintf("%s AS require_numbered", LIBRARY_ROOT_TABLE_REQUIRE_NUMBERED),
+		fmt.Sprintf("%s AS flag_missing", LIBRARY_ROOT_TABLE_FLAG_MISSING),
+		fmt.Sprintf("%s AS last_discovered_at", LIBRARY_ROOT_TABLE_LAST_DISCOVERED_AT),
+	}
+}
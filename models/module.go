@@ -0,0 +1,56 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	MODULE_TABLE = "modules"
+
+	MODULE_COURSE_ID = "course_id"
+	MODULE_PARENT_ID = "parent_id"
+	MODULE_PATH      = "path"
+	MODULE_TITLE     = "title"
+	MODULE_PREFIX    = "prefix"
+
+	MODULE_TABLE_ID         = MODULE_TABLE + "." + BASE_ID
+	MODULE_TABLE_CREATED_AT = MODULE_TABLE + "." + BASE_CREATED_AT
+	MODULE_TABLE_UPDATED_AT = MODULE_TABLE + "." + BASE_UPDATED_AT
+	MODULE_TABLE_COURSE_ID  = MODULE_TABLE + "." + MODULE_COURSE_ID
+	MODULE_TABLE_PARENT_ID  = MODULE_TABLE + "." + MODULE_PARENT_ID
+	MODULE_TABLE_PATH       = MODULE_TABLE + "." + MODULE_PATH
+	MODULE_TABLE_TITLE      = MODULE_TABLE + "." + MODULE_TITLE
+	MODULE_TABLE_PREFIX     = MODULE_TABLE + "." + MODULE_PREFIX
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Module defines the model for a module (a directory of lessons) within a course. The path is
+// relative to the course and uses forward slashes, matching `Lesson.Module`
+type Module struct {
+	Base
+	CourseID string         `db:"course_id"` // Immutable
+	ParentID sql.NullString `db:"parent_id"` // Mutable
+	Path     string         `db:"path"`      // Immutable
+	Title    string         `db:"title"`     // Mutable
+	Prefix   sql.NullInt16  `db:"prefix"`    // Mutable
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ModuleColumns returns the list of columns to use when populating `Module`
+func ModuleColumns() []string {
+	return []string{
+		fmt.Sprintf("%s AS id", MODULE_TABLE_ID),
+		fmt.Sprintf("%s AS created_at", MODULE_TABLE_CREATED_AT),
+		fmt.Sprintf("%s AS updated_at", MODULE_TABLE_UPDATED_AT),
+		fmt.Sprintf("%s AS course_id", MODULE_TABLE_COURSE_ID),
+		fmt.Sprintf("%s AS parent_id", MODULE_TABLE_PARENT_ID),
+		fmt.Sprintf("%s AS path", MODULE_TABLE_PATH),
+		fmt.Sprintf("%s AS title", MODULE_TABLE_TITLE),
+		fmt.Sprintf("%s AS prefix", MODULE_TABLE_PREFIX),
+	}
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/geerew/off-course/utils/types"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	SCAN_HISTORY_TABLE = "scans_history"
+
+	SCAN_HISTORY_COURSE_ID           = "course_id"
+	SCAN_HISTORY_STATUS              = "status"
+	SCAN_HISTORY_ERROR               = "error"
+	SCAN_HISTORY_STARTED_AT          = "started_at"
+	SCAN_HISTORY_FINISHED_AT         = "finished_at"
+	SCAN_HISTORY_DURATION            = "duration"
+	SCAN_HISTORY_LESSONS_CREATED     = "lessons_created"
+	SCAN_HISTORY_LESSONS_UPDATED     = "lessons_updated"
+	SCAN_HISTORY_LESSONS_DELETED     = "lessons_deleted"
+	SCAN_HISTORY_ASSETS_CREATED      = "assets_created"
+	SCAN_HISTORY_ASSETS_UPDATED      = "assets_updated"
+	SCAN_HISTORY_ASSETS_DELETED      = "assets_deleted"
+	SCAN_HISTORY_ATTACHMENTS_CREATED = "attachments_created"
+	SCAN_HISTORY_ATTACHMENTS_DELETED = "attachments_deleted"
+	SCAN_HISTORY_SKIPPED             = "skipped"
+
+	SCAN_HISTORY_TABLE_ID                  = SCAN_HISTORY_TABLE + "." + BASE_ID
+	SCAN_HISTORY_TABLE_CREATED_AT          = SCAN_HISTORY_TABLE + "." + BASE_CREATED_AT
+	SCAN_HISTORY_TABLE_UPDATED_AT          = SCAN_HISTORY_TABLE + "." + BASE_UPDATED_AT
+	SCAN_HISTORY_TABLE_COURSE_ID           = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_COURSE_ID
+	SCAN_HISTORY_TABLE_STATUS              = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_STATUS
+	SCAN_HISTORY_TABLE_ERROR               = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_ERROR
+	SCAN_HISTORY_TABLE_STARTED_AT          = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_STARTED_AT
+	SCAN_HISTORY_TABLE_FINISHED_AT         = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_FINISHED_AT
+	SCAN_HISTORY_TABLE_DURATION            = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_DURATION
+	SCAN_HISTORY_TABLE_LESSONS_CREATED     = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_LESSONS_CREATED
+	SCAN_HISTORY_TABLE_LESSONS_UPDATED     = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_LESSONS_UPDATED
+	SCAN_HISTORY_TABLE_LESSONS_DELETED     = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_LESSONS_DELETED
+	SCAN_HISTORY_TABLE_ASSETS_CREATED      = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_ASSETS_CREATED
+	SCAN_HISTORY_TABLE_ASSETS_UPDATED      = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_ASSETS_UPDATED
+	SCAN_HISTORY_TABLE_ASSETS_DELETED      = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_ASSETS_DELETED
+	SCAN_HISTORY_TABLE_ATTACHMENTS_CREATED = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_ATTACHMENTS_CREATED
+	SCAN_HISTORY_TABLE_ATTACHMENTS_DELETED = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_ATTACHMENTS_DELETED
+	SCAN_HISTORY_TABLE_SKIPPED             = SCAN_HISTORY_TABLE + "." + SCAN_HISTORY_SKIPPED
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ScanHistory defines the model for a finished course scan
+type ScanHistory struct {
+	Base
+	CourseID           string               `db:"course_id"`           // Immutable
+	Status             types.ScanStatusType `db:"status"`              // Immutable
+	Error              string               `db:"error"`               // Immutable
+	StartedAt          types.DateTime       `db:"started_at"`          // Immutable
+	FinishedAt         types.DateTime       `db:"finished_at"`         // Immutable
+	Duration           int                  `db:"duration"`            // Immutable (milliseconds)
+	LessonsCreated     int                  `db:"lessons_created"`     // Immutable
+	LessonsUpdated     int                  `db:"lessons_updated"`     // Immutable
+	LessonsDeleted     int                  `db:"lessons_deleted"`     // Immutable
+	AssetsCreated      int                  `db:"assets_created"`      // Immutable
+	AssetsUpdated      int                  `db:"assets_updated"`      // Immutable
+	AssetsDeleted      int                  `db:"assets_deleted"`      // Immutable
+	AttachmentsCreated int                  `db:"attachments_created"` // Immutable
+	AttachmentsDeleted int                  `db:"attachments_deleted"` // Immutable
+	Skipped            ScanSkippedFiles     `db:"skipped"`             // Immutable
+
+	// Joins
+	CourseTitle string `db:"course_title"` // Alias for course title
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ScanSkippedFile is a file that was found during a scan but was not added to the course
+type ScanSkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ScanSkippedFiles is a list of skipped files that is stored as JSON
+type ScanSkippedFiles []ScanSkippedFile
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Value implements the `driver.Valuer` interface
+func (s ScanSkippedFiles) Value() (driver.Value, error) {
+	if s == nil {
+		s = ScanSkippedFiles{}
+	}
+
+	data, err := json.Marshal([]ScanSkippedFile(s))
+
+	return string(data), err
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Scan implements `sql.Scanner` interface
+func (s *ScanSkippedFiles) Scan(value any) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		// no cast needed
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("failed to unmarshal ScanSkippedFiles value: %q", value)
+	}
+
+	if len(data) == 0 {
+		data = []byte("[]")
+	}
+
+	return json.Unmarshal(data, (*[]ScanSkippedFile)(s))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ScanHistoryColumns returns the list of columns to use when populating `ScanHistory`
+func ScanHistoryColumns() []string {
+	return []string{
+		fmt.Sprintf("%s AS id", SCAN_HISTORY_TABLE_ID),
+		fmt.Sprintf("%s AS created_at", SCAN_HISTORY_TABLE_CREATED_AT),
+		fmt.Sprintf("%s AS updated_at", SCAN_HISTORY_TABLE_UPDATED_AT),
+		fmt.Sprintf("%s AS course_id", SCAN_HISTORY_TABLE_COURSE_ID),
+		fmt.Sprintf("%s AS status", SCAN_HISTORY_TABLE_STATUS),
+		fmt.Sprintf("%s AS error", SCAN_HISTORY_TABLE_ERROR),
+		fmt.Sprintf("%s AS started_at", SCAN_HISTORY_TABLE_STARTED_AT),
+		fmt.Sprintf("%s AS finished_at", SCAN_HISTORY_TABLE_FINISHED_AT),
+		fmt.Sprintf("%s AS duration", SCAN_HISTORY_TABLE_DURATION),
+		fmt.Sprintf("%s AS lessons_created", SCAN_HISTORY_TABLE_LESSONS_CREATED),
+		fmt.Sprintf("%s AS lessons_updated", SCAN_HISTORY_TABLE_LESSONS_UPDATED),
+		fmt.Sprintf("%s AS lessons_deleted", SCAN_HISTORY_TABLE_LESSONS_DELETED),
+		fmt.Sprintf("%s AS assets_created", SCAN_HISTORY_TABLE_ASSETS_CREATED),
+		fmt.Sprintf("%s AS assets_updated", SCAN_HISTORY_TABLE_ASSETS_UPDATED),
+		fmt.Sprintf("%s AS assets_deleted", SCAN_HISTORY_TABLE_ASSETS_DELETED),
+		fmt.Sprintf("%s AS attachments_created", SCAN_HISTORY_TABLE_ATTACHMENTS_CREATED),
+		fmt.Sprintf("%s AS attachments_deleted", SCAN_HISTORY_TABLE_ATTACHMENTS_DELETED),
+		fmt.Sprintf("%s AS skipped", SCAN_HISTORY_TABLE_SKIPPED),
+		// Join columns
+		fmt.Sprintf("%s AS course_title", COURSE_TABLE_TITLE),
+	}
+}
@@ -16,6 +16,8 @@ const (
 	USER_PASSWORD_HASH = "password_hash"
 	USER_ROLE          = "role"
 
+	USER_PREFERRED_AUDIO_LANGUAGE = "preferred_audio_language"
+
 	USER_TABLE_ID            = USER_TABLE + "." + BASE_ID
 	USER_TABLE_CREATED_AT    = USER_TABLE + "." + BASE_CREATED_AT
 	USER_TABLE_UPDATED_AT    = USER_TABLE + "." + BASE_UPDATED_AT
@@ -23,6 +25,8 @@ const (
 	USER_TABLE_DISPLAY_NAME  = USER_TABLE + "." + USER_DISPLAY_NAME
 	USER_TABLE_PASSWORD_HASH = USER_TABLE + "." + USER_PASSWORD_HASH
 	USER_TABLE_ROLE          = USER_TABLE + "." + USER_ROLE
+
+	USER_TABLE_PREFERRED_AUDIO_LANGUAGE = USER_TABLE + "." + USER_PREFERRED_AUDIO_LANGUAGE
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -35,6 +39,10 @@ type User struct {
 	DisplayName  string         `db:"display_name"`  // Mutable
 	PasswordHash string         `db:"password_hash"` // Mutable
 	Role         types.UserRole `db:"role"`          // Mutable
+
+	// PreferredAudioLanguage is the language, such as "eng", of the audio track played by
+	// default
+	PreferredAudioLanguage string `db:"preferred_audio_language"` // Mutable
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -49,5 +57,6 @@ func UserColumns() []string {
 		fmt.Sprintf("%s AS display_name", USER_TABLE_DISPLAY_NAME),
 		fmt.Sprintf("%s AS password_hash", USER_TABLE_PASSWORD_HASH),
 		fmt.Sprintf("%s AS role", USER_TABLE_ROLE),
+		fmt.Sprintf("%s AS preferred_audio_language", USER_TABLE_PREFERRED_AUDIO_LANGUAGE),
 	}
 }
@@ -4,6 +4,7 @@ import {
 	CoursePaginationSchema,
 	CourseSchema,
 	CourseTagSchema,
+	type CourseCardUpdateModel,
 	type CourseCreateModel,
 	type CourseModel,
 	type CoursePaginationModel,
@@ -11,7 +12,12 @@ import {
 	type CourseTagCreateModel,
 	type CourseTagsModel
 } from '$lib/models/course-model';
-import { ModulesSchema, type ModulesModel, type ModulesReqParams } from '$lib/models/module-model';
+import {
+	flattenModules,
+	ModulesSchema,
+	type ModulesModel,
+	type ModulesReqParams
+} from '$lib/models/module-model';
 import { buildQueryString } from '$lib/utils';
 import { array, safeParse } from 'valibot';
 import { apiFetch } from './fetch';
@@ -91,6 +97,41 @@ export async function DeleteCourse(courseId: string): Promise<void> {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// Update the card for a course, either from a frame of a video or an uploaded image
+export async function UpdateCourseCard(
+	courseId: string,
+	data: CourseCardUpdateModel | File
+): Promise<CourseModel> {
+	let init: RequestInit;
+
+	if (data instanceof File) {
+		const form = new FormData();
+		form.append('card', data);
+		init = { method: 'PUT', body: form };
+	} else {
+		init = {
+			method: 'PUT',
+			headers: { 'Content-Type': 'application/json' },
+			body: JSON.stringify(data)
+		};
+	}
+
+	const response = await apiFetch(`/api/courses/${courseId}/card`, init);
+
+	if (response.ok) {
+		const data = (await response.json()) as CourseModel;
+		const result = safeParse(CourseSchema, data);
+
+		if (!result.success) throw new APIError(response.status, 'Invalid response from the server');
+		return result.output;
+	} else {
+		const data = await response.json();
+		throw new APIError(response.status, data.message || 'Unknown error');
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // Delete course progress (for a specific user)
 export async function DeleteCourseProgress(courseId: string): Promise<void> {
 	const response = await apiFetch(`/api/courses/${courseId}/progress`, {
@@ -239,7 +280,8 @@ export async function UnfavouriteCourse(courseId: string): Promise<void> {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// Get the structured modules (chapters/lessons) for a course
+// Get the structured modules (chapters/lessons) for a course. Nested modules are flattened
+// into a list of the modules that hold lessons
 export async function GetCourseModules(
 	courseId: string,
 	params?: ModulesReqParams
@@ -252,7 +294,7 @@ export async function GetCourseModules(
 		const result = safeParse(ModulesSchema, data);
 
 		if (!result.success) throw new APIError(response.status, 'Invalid response from the server');
-		return result.output;
+		return { modules: flattenModules(result.output.modules) };
 	} else {
 		const data = await response.json();
 		throw new APIError(response.status, data.message || 'Unknown error');
@@ -6,8 +6,10 @@ export { default as DeleteCourseDialog } from './delete-course.svelte';
 export { default as DeleteScanDialog } from './delete-scan.svelte';
 export { default as DeleteTagDialog } from './delete-tag.svelte';
 export { default as DeleteUserDialog } from './delete-user.svelte';
+export { default as EditCourseCardDialog } from './edit-course-card.svelte';
 export { default as EditCourseTagsDialog } from './edit-course-tags.svelte';
 export { default as EditTagNameDialog } from './edit-tag-name.svelte';
+export { default as EditUserAudioLanguageDialog } from './edit-user-audio-language.svelte';
 export { default as EditUserDisplayNameDialog } from './edit-user-display-name.svelte';
 export { default as EditUserPasswordDialog } from './edit-user-password.svelte';
 export { default as EditUserRoleDialog } from './edit-user-role.svelte';
@@ -14,7 +14,7 @@ import { BasePaginationSchema, type PaginationReqParams } from './pagination-mod
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // Asset type schema
-const AssetTypeSchema = picklist(['video', 'pdf', 'markdown', 'text']);
+const AssetTypeSchema = picklist(['video', 'audio', 'pdf', 'markdown', 'text']);
 export type AssetType = InferOutput<typeof AssetTypeSchema>;
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -66,7 +66,12 @@ export const AssetAudioMetadataSchema = object({
 	channels: number(),
 	channelLayout: string(),
 	sampleRate: number(),
-	bitRate: number()
+	bitRate: number(),
+	durationSec: optional(number()),
+	title: optional(string()),
+	artist: optional(string()),
+	album: optional(string()),
+	hasCoverArt: optional(boolean())
 });
 
 export type AssetAudioMetadataModel = InferOutput<typeof AssetAudioMetadataSchema>;
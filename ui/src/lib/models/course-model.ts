@@ -48,6 +48,7 @@ export const CourseSchema = object({
 	path: optional(string()),
 	hasCard: boolean(),
 	cardHash: optional(string()),
+	cardSource: optional(string()),
 	available: boolean(),
 	duration: number(),
 	initialScan: optional(boolean()),
@@ -72,6 +73,16 @@ export type CourseCreateModel = InferOutput<typeof CourseCreateSchema>;
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// Course card update schema. Without a timestamp, a frame is picked automatically
+export const CourseCardUpdateSchema = object({
+	assetId: optional(string()),
+	timestamp: optional(number())
+});
+
+export type CourseCardUpdateModel = InferOutput<typeof CourseCardUpdateSchema>;
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // Paginated courses schema
 export const CoursePaginationSchema = object({
 	...BasePaginationSchema.entries,
@@ -1,4 +1,15 @@
-import { array, boolean, number, object, picklist, string, type InferOutput } from 'valibot';
+import {
+	array,
+	boolean,
+	lazy,
+	number,
+	object,
+	optional,
+	picklist,
+	string,
+	type GenericSchema,
+	type InferOutput
+} from 'valibot';
 import { AssetSchema } from './asset-model';
 import { AttachmentSchema } from './attachment-model';
 
@@ -28,15 +39,43 @@ export type LessonModel = InferOutput<typeof LessonSchema>;
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// Module model. Modules may be nested, with each module rolling up the progress of its
+// lessons and child modules
+export type ModuleModel = {
+	id?: string;
+	parentId?: string;
+	prefix: number;
+	module: string;
+	title?: string;
+	depth: number;
+	lessonsCount: number;
+	lessonsCompleted: number;
+	percent: number;
+	durationSec: number;
+	started: boolean;
+	completed: boolean;
+	lessons: LessonModel[];
+	modules?: ModuleModel[];
+};
+
 // Module schema
-export const ModuleSchema = object({
+export const ModuleSchema: GenericSchema<ModuleModel> = object({
+	id: optional(string()),
+	parentId: optional(string()),
 	prefix: number(),
 	module: string(),
-	lessons: array(LessonSchema)
+	title: optional(string()),
+	depth: number(),
+	lessonsCount: number(),
+	lessonsCompleted: number(),
+	percent: number(),
+	durationSec: number(),
+	started: boolean(),
+	completed: boolean(),
+	lessons: array(LessonSchema),
+	modules: optional(array(lazy(() => ModuleSchema)))
 });
 
-export type ModuleModel = InferOutput<typeof ModuleSchema>;
-
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // Modules schema
@@ -47,6 +86,36 @@ export type ModulesModel = InferOutput<typeof ModulesSchema>;
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// Flatten the module tree into a list of the modules that hold lessons, in display order. The
+// prefix becomes the position in the list and the module name includes the names of its
+// parents, such as `Part One / Setup`
+export function flattenModules(modules: ModuleModel[]): ModuleModel[] {
+	const flat: ModuleModel[] = [];
+
+	const walk = (list: ModuleModel[], parents: string[]) => {
+		for (const m of list) {
+			const name = m.title || m.module.split('/').pop() || m.module;
+			const names = [...parents, name];
+
+			if (m.lessons.length > 0) {
+				flat.push({
+					...m,
+					prefix: flat.length + 1,
+					module: names.join(' / '),
+					modules: undefined
+				});
+			}
+
+			walk(m.modules ?? [], names);
+		}
+	};
+
+	walk(modules, []);
+	return flat;
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 export type ModulesReqParams = {
 	withUserProgress?: boolean;
 };
@@ -22,7 +22,8 @@ export const UserSchema = object({
 	id: string(),
 	username: string(),
 	displayName: string(),
-	role: UserRoleSchema
+	role: UserRoleSchema,
+	preferredAudioLanguage: string()
 });
 
 export type UserModel = InferOutput<typeof UserSchema>;
@@ -60,7 +61,8 @@ export type UserUpdateModel = InferOutput<typeof UserUpdateSchema>;
 export const SelfUpdateSchema = partial(
 	object({
 		...omit(UserCreateSchema, ['username', 'role']).entries,
-		currentPassword: string()
+		currentPassword: string(),
+		preferredAudioLanguage: string()
 	})
 );
 
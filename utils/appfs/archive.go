@@ -0,0 +1,1201 @@
+package appfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/geerew/off-course/utils"
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// archiveKind is the format of an archive
+type archiveKind int
+
+const (
+	archiveZip archiveKind = iota
+	archiveTar
+	archiveTarGz
+)
+
+// archiveExts maps the supported archive extensions to their format
+var archiveExts = []struct {
+	ext  string
+	kind archiveKind
+}{
+	{".zip", archiveZip},
+	{".tar.gz", archiveTarGz},
+	{".tgz", archiveTarGz},
+	{".tar", archiveTar},
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// IsArchive returns true when the path has a supported archive extension (zip, tar, tar.gz
+// or tgz)
+func IsArchive(path string) bool {
+	_, ok := archiveKindOf(path)
+	return ok
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DirOrArchiveExists returns true when the path is a directory or an archive file. It is the
+// archive-aware version of afero.DirExists, used to validate course paths
+func DirOrArchiveExists(fs afero.Fs, path string) (bool, error) {
+	info, err := fs.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return info.IsDir() || (info.Mode().IsRegular() && IsArchive(path)), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// archiveKindOf returns the format of an archive, based on its extension
+func archiveKindOf(path string) (archiveKind, bool) {
+	lower := strings.ToLower(path)
+	for _, e := range archiveExts {
+		if strings.HasSuffix(lower, e.ext) {
+			return e.kind, true
+		}
+	}
+
+	return 0, false
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ArchiveFs is a read-only afero.Fs that makes the members of zip and tar archives available
+// beneath the archive path. For example, `/courses/go.zip/01 Intro/01 Welcome.mp4` reads the
+// member `01 Intro/01 Welcome.mp4` from `/courses/go.zip`
+//
+// The archive itself remains a regular file, so it can still be served as an attachment, but
+// opening it also allows its top level members to be listed. All other paths are passed
+// through to the base filesystem
+//
+// Stored (uncompressed) members are read directly from the archive and support cheap seeking.
+// Compressed members are decompressed on the fly, with seeking backwards restarting the stream.
+// As ffmpeg needs a real file, members can be extracted to a cache directory via LocalPath
+type ArchiveFs struct {
+	base     afero.Fs
+	cacheDir string
+
+	// lock protects indexes and builds. It is never held while an archive is read
+	lock    sync.Mutex
+	indexes map[string]*archiveIndex
+
+	// builds holds the index builds in progress, so concurrent callers for the same archive
+	// wait for the one build while other archives are unaffected
+	builds map[string]*indexBuild
+
+	// extractLocks holds a lock per extracted member, so concurrent callers wait for the
+	// first extraction rather than extracting the same member twice
+	extractLocks sync.Map
+
+	// cacheMaxSize and cacheMaxAge limit the extracted members. A zero limit is disabled
+	cacheMaxSize int64
+	cacheMaxAge  time.Duration
+
+	// lastAccess holds when each extracted member was last returned by LocalPath (protected by
+	// cacheLock)
+	cacheLock  sync.Mutex
+	lastAccess map[string]time.Time
+
+	// now returns the current time (overridable for tests)
+	now func() time.Time
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewArchiveFs creates a new ArchiveFs over a base filesystem. Members are extracted to
+// cacheDir when a local path is required
+func NewArchiveFs(base afero.Fs, cacheDir string) *ArchiveFs {
+	return &ArchiveFs{
+		base:     base,
+		cacheDir: cacheDir,
+		indexes:  make(map[string]*archiveIndex),
+		builds:   make(map[string]*indexBuild),
+
+		lastAccess: make(map[string]time.Time),
+		now:        time.Now,
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Name returns the name of the filesystem
+func (a *ArchiveFs) Name() string {
+	return "ArchiveFs"
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Create creates a file in the base filesystem. Members cannot be created
+func (a *ArchiveFs) Create(name string) (afero.File, error) {
+	if _, _, ok := a.split(name); ok {
+		return nil, readOnlyError("create", name)
+	}
+
+	return a.base.Create(name)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Mkdir creates a directory in the base filesystem. Directories cannot be created within an
+// archive
+func (a *ArchiveFs) Mkdir(name string, perm os.FileMode) error {
+	if _, _, ok := a.split(name); ok {
+		return readOnlyError("mkdir", name)
+	}
+
+	return a.base.Mkdir(name, perm)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// MkdirAll creates a directory, along with any parents, in the base filesystem. Directories
+// cannot be created within an archive
+func (a *ArchiveFs) MkdirAll(name string, perm os.FileMode) error {
+	if _, _, ok := a.split(name); ok {
+		return readOnlyError("mkdir", name)
+	}
+
+	return a.base.MkdirAll(name, perm)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Open opens a file for reading. When the name is a member of an archive, the member is
+// opened. When the name is an archive, the archive is opened as a regular file that can also
+// list its top level members
+func (a *ArchiveFs) Open(name string) (afero.File, error) {
+	if archivePath, member, ok := a.split(name); ok {
+		return a.openMember(name, archivePath, member)
+	}
+
+	file, err := a.base.Open(name)
+	if err != nil || !IsArchive(name) {
+		return file, err
+	}
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return file, nil
+	}
+
+	return &archiveRootFile{File: file, fs: a, archivePath: filepath.Clean(name)}, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// OpenFile opens a file using the given flags. Members can only be opened for reading
+func (a *ArchiveFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if _, _, ok := a.split(name); ok {
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+			return nil, readOnlyError("open", name)
+		}
+
+		return a.Open(name)
+	}
+
+	if flag == os.O_RDONLY {
+		return a.Open(name)
+	}
+
+	return a.base.OpenFile(name, flag, perm)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Remove removes a file or empty directory from the base filesystem. Members cannot be
+// removed
+func (a *ArchiveFs) Remove(name string) error {
+	if _, _, ok := a.split(name); ok {
+		return readOnlyError("remove", name)
+	}
+
+	return a.base.Remove(name)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// RemoveAll removes a path, and any children, from the base filesystem. Members cannot be
+// removed
+func (a *ArchiveFs) RemoveAll(name string) error {
+	if _, _, ok := a.split(name); ok {
+		return readOnlyError("removeall", name)
+	}
+
+	return a.base.RemoveAll(name)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Rename renames a file in the base filesystem. Members cannot be renamed, nor can a file be
+// renamed into an archive
+func (a *ArchiveFs) Rename(oldname, newname string) error {
+	if _, _, ok := a.split(oldname); ok {
+		return readOnlyError("rename", oldname)
+	}
+
+	if _, _, ok := a.split(newname); ok {
+		return readOnlyError("rename", newname)
+	}
+
+	return a.base.Rename(oldname, newname)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Stat returns the file info for a path. For members, the info comes from the archive index
+func (a *ArchiveFs) Stat(name string) (os.FileInfo, error) {
+	archivePath, member, ok := a.split(name)
+	if !ok {
+		return a.base.Stat(name)
+	}
+
+	index, err := a.index(archivePath)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	entry, ok := index.entries[member]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return entry.info(), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Chmod changes the mode of a file in the base filesystem. Members cannot be changed
+func (a *ArchiveFs) Chmod(name string, mode os.FileMode) error {
+	if _, _, ok := a.split(name); ok {
+		return readOnlyError("chmod", name)
+	}
+
+	return a.base.Chmod(name, mode)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Chown changes the owner of a file in the base filesystem. Members cannot be changed
+func (a *ArchiveFs) Chown(name string, uid, gid int) error {
+	if _, _, ok := a.split(name); ok {
+		return readOnlyError("chown", name)
+	}
+
+	return a.base.Chown(name, uid, gid)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Chtimes changes the access and modification times of a file in the base filesystem. Members
+// cannot be changed
+func (a *ArchiveFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if _, _, ok := a.split(name); ok {
+		return readOnlyError("chtimes", name)
+	}
+
+	return a.base.Chtimes(name, atime, mtime)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// LocalPath returns a path that can be read by external tools, such as ffmpeg. Members are
+// extracted to the cache directory, once per archive version, while other paths are returned
+// as is
+//
+// Extracted members remain until evicted by EnforceCache, so callers that hold on to the path
+// should call LocalPath again before each use
+func (a *ArchiveFs) LocalPath(name string) (string, error) {
+	archivePath, member, ok := a.split(name)
+	if !ok {
+		return name, nil
+	}
+
+	localPath, size, err := a.cachePath(name, archivePath, member)
+	if err != nil {
+		return "", err
+	}
+
+	lock := a.extractLock(localPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if info, err := a.base.Stat(localPath); err != nil || info.Size() != size {
+		if err := a.extract(name, localPath); err != nil {
+			return "", fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+	}
+
+	a.touch(localPath)
+
+	return localPath, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// cachePath returns where a member is extracted to within the cache directory, along with the
+// size of the member
+func (a *ArchiveFs) cachePath(name, archivePath, member string) (string, int64, error) {
+	archiveInfo, err := a.base.Stat(archivePath)
+	if err != nil {
+		return "", 0, err
+	}
+
+	entryInfo, err := a.Stat(name)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if entryInfo.IsDir() {
+		return "", 0, &fs.PathError{Op: "extract", Path: name, Err: syscall.EISDIR}
+	}
+
+	// The archive path, size and modification time are hashed so a changed archive is
+	// extracted again rather than serving a stale member
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d", archivePath, archiveInfo.Size(), archiveInfo.ModTime().UnixNano())))
+	localPath := filepath.Join(a.cacheDir, hex.EncodeToString(sum[:8]), filepath.FromSlash(member))
+
+	return localPath, entryInfo.Size(), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// extractLock returns the lock for an extracted member
+func (a *ArchiveFs) extractLock(localPath string) *sync.Mutex {
+	lock, _ := a.extractLocks.LoadOrStore(localPath, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// extract copies a member to the local path. It is written to a temporary file first, so a
+// partially extracted member is never used
+func (a *ArchiveFs) extract(name, localPath string) error {
+	if err := a.base.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return err
+	}
+
+	src, err := a.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := localPath + ".tmp"
+	dst, err := a.base.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		_ = a.base.Remove(tmpPath)
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		_ = a.base.Remove(tmpPath)
+		return err
+	}
+
+	return a.base.Rename(tmpPath, localPath)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// split splits a path into the archive it is within and the member path, using forward
+// slashes. ok is false when the path is not within an archive. The archive itself is not
+// considered to be within an archive
+//
+// Only the first archive in a path is considered, so archives nested in archives are read as
+// regular members
+func (a *ArchiveFs) split(name string) (archivePath, member string, ok bool) {
+	name = filepath.Clean(utils.NormalizeWindowsDrive(name))
+	sep := string(filepath.Separator)
+
+	for i := 0; i < len(name); i++ {
+		if name[i] != filepath.Separator || i == 0 {
+			continue
+		}
+
+		prefix := name[:i]
+		if !IsArchive(prefix) {
+			continue
+		}
+
+		if info, err := a.base.Stat(prefix); err == nil && info.Mode().IsRegular() {
+			member = strings.TrimPrefix(name[i:], sep)
+			if member == "" {
+				return "", "", false
+			}
+
+			return prefix, filepath.ToSlash(member), true
+		}
+	}
+
+	return "", "", false
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// index returns the index for an archive, building it when the archive has not been read or
+// has changed since it was last read. The build happens outside of the lock, with one build
+// in flight per archive
+func (a *ArchiveFs) index(archivePath string) (*archiveIndex, error) {
+	info, err := a.base.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	a.lock.Lock()
+
+	if index, ok := a.indexes[archivePath]; ok && index.size == info.Size() && index.modTime.Equal(info.ModTime()) {
+		a.lock.Unlock()
+		return index, nil
+	}
+
+	if build, ok := a.builds[archivePath]; ok && build.size == info.Size() && build.modTime.Equal(info.ModTime()) {
+		a.lock.Unlock()
+		<-build.done
+		return build.index, build.err
+	}
+
+	build := &indexBuild{size: info.Size(), modTime: info.ModTime(), done: make(chan struct{})}
+	a.builds[archivePath] = build
+	a.lock.Unlock()
+
+	build.index, build.err = buildIndex(a.base, archivePath, info)
+
+	// A build for a newer version of the archive may have started in the meantime, in which
+	// case that build owns the cached index
+	a.lock.Lock()
+	if a.builds[archivePath] == build {
+		delete(a.builds, archivePath)
+		if build.err == nil {
+			a.indexes[archivePath] = build.index
+		}
+	}
+	a.lock.Unlock()
+
+	close(build.done)
+
+	return build.index, build.err
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// indexBuild is an index build in progress. done is closed once index and err are set
+type indexBuild struct {
+	size    int64
+	modTime time.Time
+	done    chan struct{}
+
+	index *archiveIndex
+	err   error
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// buildIndex reads the members of an archive into a new index
+func buildIndex(base afero.Fs, archivePath string, info os.FileInfo) (*archiveIndex, error) {
+	kind, _ := archiveKindOf(archivePath)
+	index := &archiveIndex{
+		kind:    kind,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		entries: map[string]*archiveEntry{
+			"": {name: filepath.Base(archivePath), dir: true, modTime: info.ModTime()},
+		},
+	}
+
+	var err error
+	if kind == archiveZip {
+		err = index.readZip(base, archivePath, info.Size())
+	} else {
+		err = index.readTar(base, archivePath)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", archivePath, err)
+	}
+
+	index.sortChildren()
+
+	return index, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// openMember opens a member of an archive
+func (a *ArchiveFs) openMember(name, archivePath, member string) (afero.File, error) {
+	index, err := a.index(archivePath)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	entry, ok := index.entries[member]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	file := &archiveFile{name: name, index: index, member: member, entry: entry}
+	if entry.dir {
+		return file, nil
+	}
+
+	base, err := a.base.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := entry.reader(index.kind, base)
+	if err != nil {
+		base.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	file.base = base
+	file.reader = reader
+
+	return file, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// readOnlyError builds the error returned when writing to an archive
+func readOnlyError(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: utils.ErrArchiveReadOnly}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// archiveIndex is the list of members within an archive, keyed by their slash separated path.
+// The root of the archive has the key ""
+type archiveIndex struct {
+	kind    archiveKind
+	size    int64
+	modTime time.Time
+	entries map[string]*archiveEntry
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// archiveEntry is a file or directory within an archive
+type archiveEntry struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+
+	// children is the sorted list of member names within a directory
+	children []string
+
+	// offset is where the member data starts. For zip and tar files this is the offset within
+	// the archive, while for tar.gz files it is the offset within the decompressed stream
+	offset int64
+
+	// compressedSize and method are only set for zip members
+	compressedSize int64
+	method         uint16
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// readZip adds the members of a zip archive to the index
+func (idx *archiveIndex) readZip(base afero.Fs, archivePath string, size int64) error {
+	file, err := base.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := zip.NewReader(file, size)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range reader.File {
+		member, ok := cleanMember(f.Name)
+		if !ok {
+			continue
+		}
+
+		if f.FileInfo().IsDir() {
+			idx.addDir(member, f.Modified)
+			continue
+		}
+
+		offset, err := f.DataOffset()
+		if err != nil {
+			return err
+		}
+
+		idx.addFile(member, &archiveEntry{
+			size:           int64(f.UncompressedSize64),
+			modTime:        f.Modified,
+			offset:         offset,
+			compressedSize: int64(f.CompressedSize64),
+			method:         f.Method,
+		})
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// readTar adds the members of a tar or tar.gz archive to the index
+func (idx *archiveIndex) readTar(base afero.Fs, archivePath string) error {
+	file, err := base.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// An uncompressed tar is given the file itself, as the tar reader seeks past member data
+	// when it can. The file position is then the offset of the member data once the header is
+	// read. A tar.gz stream cannot seek, so the data is skipped by reading it and the bytes
+	// read are counted instead
+	var reader *tar.Reader
+	var offset func() (int64, error)
+
+	if idx.kind == archiveTarGz {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+
+		counter := &countingReader{r: gz}
+		reader = tar.NewReader(counter)
+		offset = func() (int64, error) { return counter.n, nil }
+	} else {
+		reader = tar.NewReader(file)
+		offset = func() (int64, error) { return file.Seek(0, io.SeekCurrent) }
+	}
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		member, ok := cleanMember(header.Name)
+		if !ok {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			idx.addDir(member, header.ModTime)
+		case tar.TypeReg:
+			dataOffset, err := offset()
+			if err != nil {
+				return err
+			}
+
+			idx.addFile(member, &archiveEntry{
+				size:    header.Size,
+				modTime: header.ModTime,
+				offset:  dataOffset,
+			})
+		}
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// addDir adds a directory, along with any missing parents, to the index
+func (idx *archiveIndex) addDir(member string, modTime time.Time) *archiveEntry {
+	if entry, ok := idx.entries[member]; ok {
+		if entry.dir && !modTime.IsZero() {
+			entry.modTime = modTime
+		}
+
+		return entry
+	}
+
+	parent := idx.addDir(parentMember(member), time.Time{})
+	parent.children = append(parent.children, path.Base(member))
+
+	if modTime.IsZero() {
+		modTime = parent.modTime
+	}
+
+	entry := &archiveEntry{name: path.Base(member), dir: true, modTime: modTime}
+	idx.entries[member] = entry
+
+	return entry
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// addFile adds a file, along with any missing parent directories, to the index. When the
+// member is listed more than once, the last one wins
+func (idx *archiveIndex) addFile(member string, entry *archiveEntry) {
+	entry.name = path.Base(member)
+
+	if existing, ok := idx.entries[member]; ok {
+		if existing.dir {
+			return
+		}
+
+		idx.entries[member] = entry
+		return
+	}
+
+	parent := idx.addDir(parentMember(member), time.Time{})
+	parent.children = append(parent.children, entry.name)
+	idx.entries[member] = entry
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// sortChildren sorts the children of each directory
+func (idx *archiveIndex) sortChildren() {
+	for _, entry := range idx.entries {
+		if entry.dir {
+			sort.Strings(entry.children)
+		}
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// info returns the file info for the entry
+func (e *archiveEntry) info() os.FileInfo {
+	info := &archiveFileInfo{name: e.name, size: e.size, modTime: e.modTime, mode: 0o444}
+	if e.dir {
+		info.size = 0
+		info.mode = fs.ModeDir | 0o555
+	}
+
+	return info
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// reader returns a seekable reader for the member data
+func (e *archiveEntry) reader(kind archiveKind, base afero.File) (io.ReadSeeker, error) {
+	switch kind {
+	case archiveZip:
+		switch e.method {
+		case zip.Store:
+			return io.NewSectionReader(base, e.offset, e.size), nil
+		case zip.Deflate:
+			return &streamReader{size: e.size, open: func() (io.ReadCloser, error) {
+				return flate.NewReader(io.NewSectionReader(base, e.offset, e.compressedSize)), nil
+			}}, nil
+		default:
+			return nil, utils.ErrArchiveUnsupported
+		}
+	case archiveTar:
+		return io.NewSectionReader(base, e.offset, e.size), nil
+	default:
+		return &streamReader{size: e.size, open: func() (io.ReadCloser, error) {
+			if _, err := base.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+
+			gz, err := gzip.NewReader(base)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := io.CopyN(io.Discard, gz, e.offset); err != nil {
+				gz.Close()
+				return nil, err
+			}
+
+			return gz, nil
+		}}, nil
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// cleanMember cleans a member name from an archive. ok is false for the root and for names
+// that would escape the archive
+func cleanMember(name string) (string, bool) {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimPrefix(name, "/")
+
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+
+	return name, true
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// parentMember returns the parent of a member, with "" being the archive root
+func parentMember(member string) string {
+	parent := path.Dir(member)
+	if parent == "." {
+		return ""
+	}
+
+	return parent
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// countingReader counts the bytes read from the underlying reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read reads from the underlying reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// streamReader is a seekable reader over a compressed member. Seeking forward discards the
+// data in between, while seeking backward restarts the stream
+type streamReader struct {
+	open   func() (io.ReadCloser, error)
+	size   int64
+	r      io.ReadCloser
+	pos    int64
+	offset int64
+}
+
+// Read reads from the current offset, (re)opening the stream as needed
+func (s *streamReader) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		return 0, io.EOF
+	}
+
+	if s.r == nil || s.offset < s.pos {
+		if s.r != nil {
+			s.r.Close()
+		}
+
+		r, err := s.open()
+		if err != nil {
+			s.r = nil
+			return 0, err
+		}
+
+		s.r = r
+		s.pos = 0
+	}
+
+	if s.offset > s.pos {
+		n, err := io.CopyN(io.Discard, s.r, s.offset-s.pos)
+		s.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if remaining := s.size - s.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := s.r.Read(p)
+	s.pos += int64(n)
+	s.offset += int64(n)
+
+	if err == io.EOF && s.offset < s.size {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// Seek sets the offset for the next read
+func (s *streamReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+
+	s.offset = offset
+	return offset, nil
+}
+
+// Close closes the underlying stream
+func (s *streamReader) Close() error {
+	if s.r == nil {
+		return nil
+	}
+
+	return s.r.Close()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// archiveFileInfo is the os.FileInfo for a member
+type archiveFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *archiveFileInfo) Name() string       { return i.name }
+func (i *archiveFileInfo) Size() int64        { return i.size }
+func (i *archiveFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *archiveFileInfo) ModTime() time.Time { return i.modTime }
+func (i *archiveFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *archiveFileInfo) Sys() any           { return nil }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// archiveFile is an open member of an archive. Directories can be listed while files can be
+// read and seeked
+type archiveFile struct {
+	name   string
+	index  *archiveIndex
+	member string
+	entry  *archiveEntry
+
+	base   afero.File
+	reader io.ReadSeeker
+
+	// dirOffset is how many children have been returned by Readdir and Readdirnames
+	dirOffset int
+
+	lock sync.Mutex
+}
+
+// Name returns the full path of the member
+func (f *archiveFile) Name() string {
+	return f.name
+}
+
+// Stat returns the file info for the member
+func (f *archiveFile) Stat() (os.FileInfo, error) {
+	return f.entry.info(), nil
+}
+
+// Read reads from the member. Like os.File, io.EOF is only returned once there is no more
+// data, as some callers ignore data returned alongside an error
+func (f *archiveFile) Read(p []byte) (int, error) {
+	if f.reader == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	n, err := f.reader.Read(p)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+
+	return n, err
+}
+
+// ReadAt reads from the member at the given offset, without changing the offset for the next
+// read
+func (f *archiveFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.reader == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+
+	if r, ok := f.reader.(io.ReaderAt); ok {
+		return r.ReadAt(p, off)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	current, err := f.reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := f.reader.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(f.reader, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	if _, seekErr := f.reader.Seek(current, io.SeekStart); seekErr != nil && err == nil {
+		err = seekErr
+	}
+
+	return n, err
+}
+
+// Seek sets the offset for the next read
+func (f *archiveFile) Seek(offset int64, whence int) (int64, error) {
+	if f.reader == nil {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: syscall.EISDIR}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.reader.Seek(offset, whence)
+}
+
+// Readdir returns the file info for the children of a directory
+func (f *archiveFile) Readdir(count int) ([]os.FileInfo, error) {
+	names, err := f.Readdirnames(count)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(names))
+	for _, name := range names {
+		infos = append(infos, f.index.entries[path.Join(f.member, name)].info())
+	}
+
+	return infos, nil
+}
+
+// Readdirnames returns the names of the children of a directory
+func (f *archiveFile) Readdirnames(n int) ([]string, error) {
+	if !f.entry.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return readdirnames(f.entry.children, &f.dirOffset, n)
+}
+
+// Close closes the member
+func (f *archiveFile) Close() error {
+	if closer, ok := f.reader.(io.Closer); ok {
+		closer.Close()
+	}
+
+	if f.base != nil {
+		return f.base.Close()
+	}
+
+	return nil
+}
+
+func (f *archiveFile) Sync() error { return nil }
+
+func (f *archiveFile) Write(p []byte) (int, error) {
+	return 0, readOnlyError("write", f.name)
+}
+
+func (f *archiveFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, readOnlyError("write", f.name)
+}
+
+func (f *archiveFile) WriteString(s string) (int, error) {
+	return 0, readOnlyError("write", f.name)
+}
+
+func (f *archiveFile) Truncate(size int64) error {
+	return readOnlyError("truncate", f.name)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// archiveRootFile is an archive opened as a regular file. Reads return the raw archive while
+// Readdir and Readdirnames list the top level members
+type archiveRootFile struct {
+	afero.File
+	fs          *ArchiveFs
+	archivePath string
+	dirOffset   int
+}
+
+// Readdir returns the file info for the top level members
+func (f *archiveRootFile) Readdir(count int) ([]os.FileInfo, error) {
+	index, err := f.fs.index(f.archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := readdirnames(index.entries[""].children, &f.dirOffset, count)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(names))
+	for _, name := range names {
+		infos = append(infos, index.entries[name].info())
+	}
+
+	return infos, nil
+}
+
+// Readdirnames returns the names of the top level members
+func (f *archiveRootFile) Readdirnames(n int) ([]string, error) {
+	index, err := f.fs.index(f.archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return readdirnames(index.entries[""].children, &f.dirOffset, n)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// readdirnames returns the next n names, following the semantics of os.File.Readdirnames. When
+// n <= 0, all remaining names are returned
+func readdirnames(children []string, offset *int, n int) ([]string, error) {
+	remaining := children[min(*offset, len(children)):]
+
+	if n <= 0 {
+		*offset = len(children)
+		return append([]string{}, remaining...), nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	remaining = remaining[:min(n, len(remaining))]
+	*offset += len(remaining)
+
+	return append([]string{}, remaining...), nil
+}
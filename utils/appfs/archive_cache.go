@@ -0,0 +1,247 @@
+package appfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// archiveTempDirName is the directory, within the cache directory, holding members extracted
+// by TempLocalPath. It is not part of the cache, so it is never evicted
+const archiveTempDirName = "tmp"
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ArchiveCacheResult describes the outcome of enforcing the archive cache limits
+type ArchiveCacheResult struct {
+	Evicted int
+	Freed   int64
+	Size    int64
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// archiveCacheEntry is an extracted member within the cache directory
+type archiveCacheEntry struct {
+	path       string
+	size       int64
+	lastAccess time.Time
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// SetCacheLimits sets the max size and max age of the extracted members. A zero limit is
+// disabled
+func (a *ArchiveFs) SetCacheLimits(maxSize int64, maxAge time.Duration) {
+	a.cacheLock.Lock()
+	defer a.cacheLock.Unlock()
+
+	a.cacheMaxSize = maxSize
+	a.cacheMaxAge = maxAge
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// TempLocalPath is like LocalPath, but a member that is not already in the cache is extracted
+// to a temporary file rather than being cached. It suits one-off reads, such as probing during
+// a scan, which would otherwise leave a copy of every member on disk
+//
+// The release func removes the temporary file, and must be called once the path is no longer
+// needed
+func (a *ArchiveFs) TempLocalPath(name string) (string, func(), error) {
+	noop := func() {}
+
+	archivePath, member, ok := a.split(name)
+	if !ok {
+		return name, noop, nil
+	}
+
+	localPath, size, err := a.cachePath(name, archivePath, member)
+	if err != nil {
+		return "", noop, err
+	}
+
+	// Reuse the member when it has already been extracted, such as when it is being played
+	lock := a.extractLock(localPath)
+	lock.Lock()
+	if info, err := a.base.Stat(localPath); err == nil && info.Size() == size {
+		a.touch(localPath)
+		lock.Unlock()
+		return localPath, noop, nil
+	}
+	lock.Unlock()
+
+	tempRoot := filepath.Join(a.cacheDir, archiveTempDirName)
+	if err := a.base.MkdirAll(tempRoot, 0o755); err != nil {
+		return "", noop, err
+	}
+
+	tempDir, err := afero.TempDir(a.base, tempRoot, "")
+	if err != nil {
+		return "", noop, err
+	}
+
+	release := func() {
+		_ = a.base.RemoveAll(tempDir)
+	}
+
+	// The member name is kept so tools that look at the extension still work
+	tempPath := filepath.Join(tempDir, filepath.Base(filepath.FromSlash(member)))
+	if err := a.extract(name, tempPath); err != nil {
+		release()
+		return "", noop, fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+
+	return tempPath, release, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// EnforceCache evicts extracted members that have not been accessed within the max age, then
+// the least recently used members until the cache is within the max size. A zero limit is
+// disabled
+//
+// A member in use by ffmpeg may be evicted. Callers of LocalPath request the path again
+// before starting ffmpeg, which extracts the member again when needed
+func (a *ArchiveFs) EnforceCache() (*ArchiveCacheResult, error) {
+	a.cacheLock.Lock()
+	maxSize := a.cacheMaxSize
+	maxAge := a.cacheMaxAge
+	a.cacheLock.Unlock()
+
+	result := &ArchiveCacheResult{}
+
+	if maxSize <= 0 && maxAge <= 0 {
+		return result, nil
+	}
+
+	entries, err := a.cacheEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.now()
+	kept := []archiveCacheEntry{}
+
+	for _, entry := range entries {
+		if maxAge > 0 && now.Sub(entry.lastAccess) > maxAge && a.evict(entry, result) {
+			continue
+		}
+
+		kept = append(kept, entry)
+		result.Size += entry.size
+	}
+
+	if maxSize <= 0 || result.Size <= maxSize {
+		return result, nil
+	}
+
+	slices.SortFunc(kept, func(x, y archiveCacheEntry) int {
+		return x.lastAccess.Compare(y.lastAccess)
+	})
+
+	for _, entry := range kept {
+		if result.Size <= maxSize {
+			break
+		}
+
+		if a.evict(entry, result) {
+			result.Size -= entry.size
+		}
+	}
+
+	return result, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// touch records that an extracted member was accessed
+func (a *ArchiveFs) touch(localPath string) {
+	a.cacheLock.Lock()
+	defer a.cacheLock.Unlock()
+
+	a.lastAccess[localPath] = a.now()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// evict removes an extracted member, along with any directories left empty, and adds it to the
+// result. The extraction lock is held so a member is never removed while being extracted
+func (a *ArchiveFs) evict(entry archiveCacheEntry, result *ArchiveCacheResult) bool {
+	lock := a.extractLock(entry.path)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := a.base.Remove(entry.path); err != nil && !os.IsNotExist(err) {
+		return false
+	}
+
+	a.cacheLock.Lock()
+	delete(a.lastAccess, entry.path)
+	a.cacheLock.Unlock()
+
+	for dir := filepath.Dir(entry.path); dir != a.cacheDir && strings.HasPrefix(dir, a.cacheDir); dir = filepath.Dir(dir) {
+		if empty, err := afero.IsEmpty(a.base, dir); err != nil || !empty {
+			break
+		}
+
+		if err := a.base.Remove(dir); err != nil {
+			break
+		}
+	}
+
+	result.Evicted++
+	result.Freed += entry.size
+
+	return true
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// cacheEntries lists the extracted members in the cache directory. Partially extracted
+// members and temporary extractions are skipped
+func (a *ArchiveFs) cacheEntries() ([]archiveCacheEntry, error) {
+	entries := []archiveCacheEntry{}
+	tempRoot := filepath.Join(a.cacheDir, archiveTempDirName)
+
+	err := afero.Walk(a.base, a.cacheDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			if path == tempRoot {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+
+		lastAccess := info.ModTime()
+
+		a.cacheLock.Lock()
+		if accessed, ok := a.lastAccess[path]; ok && accessed.After(lastAccess) {
+			lastAccess = accessed
+		}
+		a.cacheLock.Unlock()
+
+		entries = append(entries, archiveCacheEntry{path: path, size: info.Size(), lastAccess: lastAccess})
+
+		return nil
+	})
+
+	return entries, err
+}
@@ -0,0 +1,170 @@
+package appfs
+
+import (
+	"archive/zip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestArchiveFs_TempLocalPath(t *testing.T) {
+	t.Run("member", func(t *testing.T) {
+		base := afero.NewMemMapFs()
+		writeZip(t, base, "/course.zip", map[string]string{"01 intro.mp4": "video"}, zip.Deflate)
+
+		appFs := New(NewArchiveFs(base, "/cache"))
+
+		tempPath, release, err := appFs.TempLocalPath("/course.zip/01 intro.mp4")
+		require.NoError(t, err)
+		require.Equal(t, "01 intro.mp4", filepath.Base(tempPath))
+
+		data, err := afero.ReadFile(base, tempPath)
+		require.NoError(t, err)
+		require.Equal(t, "video", string(data))
+
+		// The member is not cached, and the copy is removed on release
+		result, err := appFs.EnforceArchiveCache()
+		require.NoError(t, err)
+		require.Zero(t, result.Size)
+
+		release()
+
+		exists, err := afero.Exists(base, tempPath)
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		base := afero.NewMemMapFs()
+		writeZip(t, base, "/course.zip", map[string]string{"01 intro.mp4": "video"}, zip.Store)
+
+		appFs := New(NewArchiveFs(base, "/cache"))
+
+		localPath, err := appFs.LocalPath("/course.zip/01 intro.mp4")
+		require.NoError(t, err)
+
+		// An already extracted member is reused and kept on release
+		tempPath, release, err := appFs.TempLocalPath("/course.zip/01 intro.mp4")
+		require.NoError(t, err)
+		require.Equal(t, localPath, tempPath)
+
+		release()
+
+		exists, err := afero.Exists(base, localPath)
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+
+	t.Run("not an archive", func(t *testing.T) {
+		appFs := New(NewArchiveFs(afero.NewMemMapFs(), "/cache"))
+
+		tempPath, release, err := appFs.TempLocalPath("/course/01 intro.mp4")
+		require.NoError(t, err)
+		require.Equal(t, "/course/01 intro.mp4", tempPath)
+		release()
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestArchiveFs_EnforceCache(t *testing.T) {
+	setup := func(t *testing.T) (*ArchiveFs, []string) {
+		t.Helper()
+
+		base := afero.NewMemMapFs()
+		writeZip(t, base, "/course.zip", map[string]string{
+			"01 Intro/01 intro.mp4": "0123456789",
+			"02 setup.mp4":          "0123456789",
+			"03 outro.mp4":          "0123456789",
+		}, zip.Store)
+
+		archiveFs := NewArchiveFs(base, "/cache")
+		now := time.Now()
+
+		// Extract the members, each accessed an hour after the previous
+		paths := []string{}
+		for i, member := range []string{"01 Intro/01 intro.mp4", "02 setup.mp4", "03 outro.mp4"} {
+			accessed := now.Add(time.Duration(i) * time.Hour)
+			archiveFs.now = func() time.Time { return accessed }
+
+			localPath, err := archiveFs.LocalPath(filepath.Join("/course.zip", member))
+			require.NoError(t, err)
+			paths = append(paths, localPath)
+		}
+
+		return archiveFs, paths
+	}
+
+	exists := func(t *testing.T, archiveFs *ArchiveFs, paths []string) []bool {
+		t.Helper()
+
+		result := []bool{}
+		for _, path := range paths {
+			ok, err := afero.Exists(archiveFs.base, path)
+			require.NoError(t, err)
+			result = append(result, ok)
+		}
+
+		return result
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		archiveFs, paths := setup(t)
+
+		result, err := archiveFs.EnforceCache()
+		require.NoError(t, err)
+		require.Zero(t, result.Evicted)
+		require.Equal(t, []bool{true, true, true}, exists(t, archiveFs, paths))
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		archiveFs, paths := setup(t)
+		archiveFs.SetCacheLimits(20, 0)
+
+		result, err := archiveFs.EnforceCache()
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Evicted)
+		require.Equal(t, int64(10), result.Freed)
+		require.Equal(t, int64(20), result.Size)
+
+		// The least recently used member goes first, along with its empty directory
+		require.Equal(t, []bool{false, true, true}, exists(t, archiveFs, paths))
+
+		dirExists, err := afero.DirExists(archiveFs.base, filepath.Dir(paths[0]))
+		require.NoError(t, err)
+		require.False(t, dirExists)
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		archiveFs, paths := setup(t)
+		archiveFs.SetCacheLimits(0, 90*time.Minute)
+
+		result, err := archiveFs.EnforceCache()
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Evicted)
+		require.Equal(t, []bool{false, true, true}, exists(t, archiveFs, paths))
+	})
+
+	t.Run("extracted again", func(t *testing.T) {
+		archiveFs, paths := setup(t)
+		archiveFs.SetCacheLimits(1, 0)
+
+		result, err := archiveFs.EnforceCache()
+		require.NoError(t, err)
+		require.Equal(t, 3, result.Evicted)
+
+		// An evicted member is extracted again when next requested
+		localPath, err := archiveFs.LocalPath("/course.zip/02 setup.mp4")
+		require.NoError(t, err)
+		require.Equal(t, paths[1], localPath)
+
+		data, err := afero.ReadFile(archiveFs.base, localPath)
+		require.NoError(t, err)
+		require.Equal(t, "0123456789", string(data))
+	})
+}
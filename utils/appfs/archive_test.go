@@ -0,0 +1,306 @@
+package appfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/geerew/off-course/utils"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_IsArchive(t *testing.T) {
+	for _, path := range []string{"/a.zip", "/a.ZIP", "/a.tar", "/a.tar.gz", "/a.tgz"} {
+		require.True(t, IsArchive(path), path)
+	}
+
+	for _, path := range []string{"/a", "/a.mp4", "/a.gz", "/zip"} {
+		require.False(t, IsArchive(path), path)
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_DirOrArchiveExists(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/dir", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/file.txt", []byte("a"), 0644))
+	writeZip(t, fs, "/course.zip", map[string]string{"01 intro.mp4": "video"}, zip.Store)
+
+	for path, expected := range map[string]bool{
+		"/dir":        true,
+		"/course.zip": true,
+		"/file.txt":   false,
+		"/missing":    false,
+	} {
+		exists, err := DirOrArchiveExists(fs, path)
+		require.NoError(t, err)
+		require.Equal(t, expected, exists, path)
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestArchiveFs_Read(t *testing.T) {
+	files := map[string]string{
+		"01 Intro/01 Welcome.mp4": "welcome video",
+		"01 Intro/02 Notes.md":    "# notes",
+		"02 Setup.mp4":            "setup video",
+	}
+
+	archives := map[string]func(afero.Fs, string){
+		"/course.zip":    func(fs afero.Fs, path string) { writeZip(t, fs, path, files, zip.Store) },
+		"/deflate.zip":   func(fs afero.Fs, path string) { writeZip(t, fs, path, files, zip.Deflate) },
+		"/course.tar":    func(fs afero.Fs, path string) { writeTar(t, fs, path, files, false) },
+		"/course.tar.gz": func(fs afero.Fs, path string) { writeTar(t, fs, path, files, true) },
+	}
+
+	for archivePath, write := range archives {
+		t.Run(filepath.Base(archivePath), func(t *testing.T) {
+			base := afero.NewMemMapFs()
+			write(base, archivePath)
+
+			appFs := New(NewArchiveFs(base, "/cache"))
+
+			// The archive itself is a regular file
+			info, err := appFs.Fs.Stat(archivePath)
+			require.NoError(t, err)
+			require.False(t, info.IsDir())
+
+			// Members can be listed
+			paths, err := appFs.ReadDirFlat(archivePath, 2)
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{
+				filepath.Join(archivePath, "01 Intro", "01 Welcome.mp4"),
+				filepath.Join(archivePath, "01 Intro", "02 Notes.md"),
+				filepath.Join(archivePath, "02 Setup.mp4"),
+			}, paths)
+
+			info, err = appFs.Fs.Stat(filepath.Join(archivePath, "01 Intro"))
+			require.NoError(t, err)
+			require.True(t, info.IsDir())
+
+			// Members can be read and seeked
+			memberPath := filepath.Join(archivePath, "01 Intro", "01 Welcome.mp4")
+
+			info, err = appFs.Fs.Stat(memberPath)
+			require.NoError(t, err)
+			require.Equal(t, int64(len("welcome video")), info.Size())
+
+			data, err := afero.ReadFile(appFs.Fs, memberPath)
+			require.NoError(t, err)
+			require.Equal(t, "welcome video", string(data))
+
+			file, err := appFs.Fs.Open(memberPath)
+			require.NoError(t, err)
+			defer file.Close()
+
+			_, err = file.Seek(8, io.SeekStart)
+			require.NoError(t, err)
+
+			data, err = io.ReadAll(file)
+			require.NoError(t, err)
+			require.Equal(t, "video", string(data))
+
+			_, err = file.Seek(0, io.SeekStart)
+			require.NoError(t, err)
+
+			buf := make([]byte, 7)
+			_, err = io.ReadFull(file, buf)
+			require.NoError(t, err)
+			require.Equal(t, "welcome", string(buf))
+
+			// Missing members
+			_, err = appFs.Fs.Stat(filepath.Join(archivePath, "missing.mp4"))
+			require.ErrorIs(t, err, afero.ErrFileNotFound)
+		})
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestArchiveFs_ReadOnly(t *testing.T) {
+	base := afero.NewMemMapFs()
+	writeZip(t, base, "/course.zip", map[string]string{"01 intro.mp4": "video"}, zip.Store)
+
+	archiveFs := NewArchiveFs(base, "/cache")
+
+	_, err := archiveFs.Create("/course.zip/new.txt")
+	require.True(t, errors.Is(err, utils.ErrArchiveReadOnly))
+
+	err = archiveFs.MkdirAll("/course.zip/dir", 0755)
+	require.True(t, errors.Is(err, utils.ErrArchiveReadOnly))
+
+	err = archiveFs.Remove("/course.zip/01 intro.mp4")
+	require.True(t, errors.Is(err, utils.ErrArchiveReadOnly))
+
+	err = afero.WriteFile(archiveFs, "/course.zip/01 intro.mp4", []byte("a"), 0644)
+	require.True(t, errors.Is(err, utils.ErrArchiveReadOnly))
+
+	// Paths outside an archive are writable
+	require.NoError(t, afero.WriteFile(archiveFs, "/other.txt", []byte("a"), 0644))
+
+	// The archive itself can be removed
+	require.NoError(t, archiveFs.Remove("/course.zip"))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestArchiveFs_Changed(t *testing.T) {
+	base := afero.NewMemMapFs()
+	writeZip(t, base, "/course.zip", map[string]string{"01 intro.mp4": "video"}, zip.Store)
+
+	archiveFs := NewArchiveFs(base, "/cache")
+
+	exists, err := afero.Exists(archiveFs, "/course.zip/02 outro.mp4")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// Replace the archive, ensuring the mod time changes
+	writeZip(t, base, "/course.zip", map[string]string{"01 intro.mp4": "video", "02 outro.mp4": "outro"}, zip.Store)
+	require.NoError(t, base.Chtimes("/course.zip", time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+
+	exists, err = afero.Exists(archiveFs, "/course.zip/02 outro.mp4")
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestArchiveFs_ConcurrentIndex(t *testing.T) {
+	base := afero.NewMemMapFs()
+	writeTar(t, base, "/course.tar", map[string]string{"01 intro.mp4": "video"}, false)
+
+	archiveFs := NewArchiveFs(base, "/cache")
+
+	// Concurrent callers get the same index, built once
+	var wg sync.WaitGroup
+	indexes := make([]*archiveIndex, 8)
+	for i := range indexes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			index, err := archiveFs.index("/course.tar")
+			require.NoError(t, err)
+			indexes[i] = index
+		}()
+	}
+
+	wg.Wait()
+
+	for _, index := range indexes {
+		require.Same(t, indexes[0], index)
+	}
+
+	require.Empty(t, archiveFs.builds)
+	require.Contains(t, archiveFs.indexes, "/course.tar")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestArchiveFs_LocalPath(t *testing.T) {
+	t.Run("member", func(t *testing.T) {
+		base := afero.NewMemMapFs()
+		writeZip(t, base, "/course.zip", map[string]string{"01 Intro/01 intro.mp4": "video"}, zip.Deflate)
+
+		appFs := New(NewArchiveFs(base, "/cache"))
+
+		localPath, err := appFs.LocalPath("/course.zip/01 Intro/01 intro.mp4")
+		require.NoError(t, err)
+		require.True(t, filepath.IsAbs(localPath))
+		require.Equal(t, "/cache", filepath.Dir(filepath.Dir(filepath.Dir(localPath))))
+
+		data, err := afero.ReadFile(base, localPath)
+		require.NoError(t, err)
+		require.Equal(t, "video", string(data))
+
+		// A second call reuses the extracted member
+		again, err := appFs.LocalPath("/course.zip/01 Intro/01 intro.mp4")
+		require.NoError(t, err)
+		require.Equal(t, localPath, again)
+	})
+
+	t.Run("not an archive", func(t *testing.T) {
+		appFs := New(NewArchiveFs(afero.NewMemMapFs(), "/cache"))
+
+		localPath, err := appFs.LocalPath("/course/01 intro.mp4")
+		require.NoError(t, err)
+		require.Equal(t, "/course/01 intro.mp4", localPath)
+	})
+
+	t.Run("plain fs", func(t *testing.T) {
+		appFs := New(afero.NewMemMapFs())
+
+		localPath, err := appFs.LocalPath("/course.zip/01 intro.mp4")
+		require.NoError(t, err)
+		require.Equal(t, "/course.zip/01 intro.mp4", localPath)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// writeZip writes a zip archive containing the files to the fs
+func writeZip(t *testing.T, fs afero.Fs, path string, files map[string]string, method uint16) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	for name, content := range files {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		require.NoError(t, err)
+
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+	require.NoError(t, afero.WriteFile(fs, path, buf.Bytes(), 0644))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// writeTar writes a tar archive, optionally gzipped, containing the files to the fs
+func writeTar(t *testing.T, fs afero.Fs, path string, files map[string]string, gz bool) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var out io.Writer = &buf
+
+	var gzw *gzip.Writer
+	if gz {
+		gzw = gzip.NewWriter(&buf)
+		out = gzw
+	}
+
+	w := tar.NewWriter(out)
+	for name, content := range files {
+		require.NoError(t, w.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+			ModTime:  time.Now(),
+		}))
+
+		_, err := w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+	if gzw != nil {
+		require.NoError(t, gzw.Close())
+	}
+
+	require.NoError(t, afero.WriteFile(fs, path, buf.Bytes(), 0644))
+}
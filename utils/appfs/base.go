@@ -104,6 +104,44 @@ func (appFs AppFs) ReadDirFlat(path string, depth int) ([]string, error) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// LocalPath returns a path that external tools, such as ffmpeg, can read. When the filesystem
+// is an ArchiveFs, archive members are extracted to its cache directory. Other paths are
+// returned as is
+func (appFs AppFs) LocalPath(path string) (string, error) {
+	if archiveFs, ok := appFs.Fs.(*ArchiveFs); ok {
+		return archiveFs.LocalPath(path)
+	}
+
+	return path, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// TempLocalPath is like LocalPath, but archive members that are not already cached are
+// extracted to a temporary file that is removed by calling release. Other paths are returned
+// as is, with a release that does nothing
+func (appFs AppFs) TempLocalPath(path string) (string, func(), error) {
+	if archiveFs, ok := appFs.Fs.(*ArchiveFs); ok {
+		return archiveFs.TempLocalPath(path)
+	}
+
+	return path, func() {}, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// EnforceArchiveCache enforces the limits of the archive cache when the filesystem is an
+// ArchiveFs. Otherwise an empty result is returned
+func (appFs AppFs) EnforceArchiveCache() (*ArchiveCacheResult, error) {
+	if archiveFs, ok := appFs.Fs.(*ArchiveFs); ok {
+		return archiveFs.EnforceCache()
+	}
+
+	return &ArchiveCacheResult{}, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // AvailableDrives returns a string slice of available drives on this system. For non-wsl
 // systems `gopsutil` is used. For WSL systems, the string slice is generated manually
 func (appFs AppFs) AvailableDrives() ([]string, error) {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/geerew/off-course/utils/appfs"
@@ -19,6 +20,7 @@ import (
 // CardCacher defines the interface for card cache operations
 type CardCacher interface {
 	GenerateOptimizedCard(ctx context.Context, originalPath, outputPath string) error
+	GenerateVideoCard(ctx context.Context, videoPath, outputPath string, durationSec int) error
 	GetCardPath(courseID string) string
 	DeleteCard(cardPath string) error
 }
@@ -89,12 +91,18 @@ func (c *CardCache) GenerateOptimizedCard(ctx context.Context, originalPath, out
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	// Cards within an archive are extracted, as ffmpeg needs a real file
+	inputPath, err := c.config.AppFs.LocalPath(originalPath)
+	if err != nil {
+		return fmt.Errorf("failed to extract card: %w", err)
+	}
+
 	// Build FFmpeg command for image conversion
 	args := []string{
 		"-nostats",
 		"-hide_banner",
 		"-loglevel", "warning",
-		"-i", originalPath,
+		"-i", inputPath,
 		"-vf", "scale=800:-1",
 		"-quality", "85",
 		"-y", // Overwrite output file
@@ -141,6 +149,202 @@ func (c *CardCache) GenerateOptimizedCard(ctx context.Context, originalPath, out
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// cardFrameSkipSec is how many seconds at the start of a video are skipped when looking for a
+// card frame, as videos often open on a black or title screen
+const cardFrameSkipSec = 5
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GenerateVideoCard generates an optimized WebP card from a representative frame of a video.
+// Frames are tried at a number of points in the video, skipping the first few seconds, and the
+// first that is not black is used. When every frame is black, the first is used
+func (c *CardCache) GenerateVideoCard(ctx context.Context, videoPath, outputPath string, durationSec int) error {
+	defer c.config.AppFs.Fs.Remove(framePath(outputPath))
+
+	var lastErr error
+	fallback := -1
+
+	for _, at := range cardFrameCandidates(durationSec) {
+		black, err := c.extractFrame(ctx, videoPath, outputPath, at, true)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			lastErr = err
+			continue
+		}
+
+		if !black {
+			return c.optimizeFrame(ctx, videoPath, outputPath, at)
+		}
+
+		if fallback == -1 {
+			fallback = at
+		}
+	}
+
+	if fallback != -1 {
+		if _, err := c.extractFrame(ctx, videoPath, outputPath, fallback, false); err != nil {
+			return err
+		}
+
+		return c.optimizeFrame(ctx, videoPath, outputPath, fallback)
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no frame found")
+	}
+
+	return lastErr
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GenerateVideoCardAt generates an optimized WebP card from the frame of a video at the
+// timestamp, in seconds
+func (c *CardCache) GenerateVideoCardAt(ctx context.Context, videoPath, outputPath string, timestamp int) error {
+	defer c.config.AppFs.Fs.Remove(framePath(outputPath))
+
+	if _, err := c.extractFrame(ctx, videoPath, outputPath, timestamp, false); err != nil {
+		return err
+	}
+
+	return c.optimizeFrame(ctx, videoPath, outputPath, timestamp)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// cardFrameCandidates returns the timestamps, in seconds, to try when looking for a card frame.
+// When the duration is unknown, fixed timestamps are tried, falling back to the first frame
+func cardFrameCandidates(durationSec int) []int {
+	if durationSec <= 0 {
+		return []int{cardFrameSkipSec * 2, cardFrameSkipSec * 6, 0}
+	}
+
+	if durationSec <= cardFrameSkipSec*2 {
+		return []int{durationSec / 2}
+	}
+
+	candidates := []int{}
+	for _, fraction := range []float64{0.1, 0.25, 0.5} {
+		at := max(int(float64(durationSec)*fraction), cardFrameSkipSec)
+		if len(candidates) == 0 || candidates[len(candidates)-1] != at {
+			candidates = append(candidates, at)
+		}
+	}
+
+	return candidates
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// framePath returns the path of the temporary frame for an output card
+func framePath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".frame.png"
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// extractFrame extracts a frame of a video to the temporary frame path for the output card. When
+// detectBlack is set, the most representative of the frames that follow the timestamp is
+// picked, and whether it is black is returned
+func (c *CardCache) extractFrame(ctx context.Context, videoPath, outputPath string, at int, detectBlack bool) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if err := c.config.AppFs.Fs.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return false, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Videos within an archive are extracted, as ffmpeg needs a real file
+	inputPath, err := c.config.AppFs.LocalPath(videoPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to extract video: %w", err)
+	}
+
+	// blackframe logs (at the info level) a line for each frame that is mostly black
+	logLevel := "warning"
+	if detectBlack {
+		logLevel = "info"
+	}
+
+	args := []string{
+		"-nostats",
+		"-hide_banner",
+		"-loglevel", logLevel,
+		"-ss", strconv.Itoa(at),
+		"-i", inputPath,
+		"-an",
+		"-sn",
+	}
+
+	if detectBlack {
+		args = append(args, "-vf", "thumbnail=30,blackframe=amount=98:threshold=32")
+	}
+
+	// A frame left by an earlier attempt would pass for this one
+	frame := framePath(outputPath)
+	if err := c.config.AppFs.Fs.Remove(frame); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to remove previous frame: %w", err)
+	}
+
+	args = append(args, "-frames:v", "1", "-y", frame)
+
+	cmd := exec.CommandContext(ctx, c.config.FFmpeg.GetFFmpegPath(), args...)
+
+	c.config.Logger.Debug().
+		Str("video_path", videoPath).
+		Int("timestamp", at).
+		Str("command", strings.Join(cmd.Args, " ")).
+		Msg("Running FFmpeg to extract card frame")
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+
+		c.config.Logger.Debug().
+			Err(err).
+			Str("video_path", videoPath).
+			Int("timestamp", at).
+			Str("stderr", stderr.String()).
+			Msg("Failed to extract card frame")
+
+		return false, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	// A timestamp past the end of the video produces no frame
+	if exists, _ := afero.Exists(c.config.AppFs.Fs, frame); !exists {
+		return false, fmt.Errorf("no frame at %ds", at)
+	}
+
+	return detectBlack && strings.Contains(stderr.String(), "pblack:"), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// optimizeFrame runs the extracted frame through the card optimization
+func (c *CardCache) optimizeFrame(ctx context.Context, videoPath, outputPath string, at int) error {
+	if err := c.GenerateOptimizedCard(ctx, framePath(outputPath), outputPath); err != nil {
+		return err
+	}
+
+	c.config.Logger.Debug().
+		Str("video_path", videoPath).
+		Str("output_path", outputPath).
+		Int("timestamp", at).
+		Msg("Generated card from video frame")
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // EnsureFallbackCard ensures the fallback card exists by copying from embedded assets
 func (c *CardCache) EnsureFallbackCard(outputPath string) error {
 	outputDir := filepath.Dir(outputPath)
@@ -214,6 +214,111 @@ func TestGenerateOptimizedCard(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func TestGenerateVideoCard(t *testing.T) {
+	// setupVideo creates a card cache and a test video using FFmpeg
+	setupVideo := func(t *testing.T) (*CardCache, string, string) {
+		t.Helper()
+
+		ffmpeg, err := media.NewFFmpeg()
+		if err != nil {
+			t.Skip("FFmpeg not available for testing")
+		}
+
+		tmpDir := t.TempDir()
+
+		cache, err := NewCardCache(&CardCacheConfig{
+			CachePath: tmpDir,
+			AppFs:     appfs.New(afero.NewOsFs()),
+			Logger:    logger.NilLogger(),
+			FFmpeg:    ffmpeg,
+		})
+		require.NoError(t, err)
+
+		videoPath := filepath.Join(tmpDir, "video.mp4")
+		createVideoCmd := exec.Command(ffmpeg.GetFFmpegPath(),
+			"-f", "lavfi",
+			"-i", "testsrc=duration=20:size=160x120:rate=5",
+			"-y",
+			videoPath,
+		)
+		if err := createVideoCmd.Run(); err != nil {
+			t.Skipf("Failed to create test video: %v", err)
+		}
+
+		return cache, videoPath, filepath.Join(tmpDir, "card.webp")
+	}
+
+	t.Run("generates card", func(t *testing.T) {
+		cache, videoPath, outputPath := setupVideo(t)
+
+		require.NoError(t, cache.GenerateVideoCard(context.Background(), videoPath, outputPath, 20))
+
+		exists, err := cache.CardExists(outputPath)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		// The temporary frame is removed
+		exists, err = cache.CardExists(framePath(outputPath))
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("generates card at timestamp", func(t *testing.T) {
+		cache, videoPath, outputPath := setupVideo(t)
+
+		require.NoError(t, cache.GenerateVideoCardAt(context.Background(), videoPath, outputPath, 12))
+
+		exists, err := cache.CardExists(outputPath)
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+
+	t.Run("timestamp past the end", func(t *testing.T) {
+		cache, videoPath, outputPath := setupVideo(t)
+
+		require.Error(t, cache.GenerateVideoCardAt(context.Background(), videoPath, outputPath, 600))
+	})
+
+	t.Run("leftover frame is not reused", func(t *testing.T) {
+		cache, videoPath, outputPath := setupVideo(t)
+
+		require.NoError(t, afero.WriteFile(cache.config.AppFs.Fs, framePath(outputPath), []byte("frame"), 0o644))
+
+		_, err := cache.extractFrame(context.Background(), videoPath, outputPath, 600, false)
+		require.Error(t, err)
+	})
+
+	t.Run("handles context cancellation", func(t *testing.T) {
+		cache, videoPath, outputPath := setupVideo(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := cache.GenerateVideoCard(ctx, videoPath, outputPath, 20)
+		require.Equal(t, context.Canceled, err)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_cardFrameCandidates(t *testing.T) {
+	tests := []struct {
+		duration int
+		expected []int
+	}{
+		{0, []int{10, 30, 0}},
+		{8, []int{4}},
+		{20, []int{5, 10}},
+		{600, []int{60, 150, 300}},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, cardFrameCandidates(tt.duration), tt.duration)
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func TestDeleteCard(t *testing.T) {
 	t.Run("deletes existing card", func(t *testing.T) {
 		appFs := appfs.New(afero.NewOsFs())
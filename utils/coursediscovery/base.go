@@ -0,0 +1,398 @@
+package coursediscovery
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/coursewatch"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/geerew/off-course/utils/types"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// numberedRegex matches names that start with a number, which is how lessons and modules are
+// ordered by the scanner
+var numberedRegex = regexp.MustCompile(`^\s*\d+`)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CourseDiscovery finds course directories beneath library roots, creates the courses and
+// queues a scan for them
+type CourseDiscovery struct {
+	appFs       *appfs.AppFs
+	dao         *dao.DAO
+	logger      *logger.Logger
+	courseScan  *coursescan.CourseScan
+	courseWatch *coursewatch.CourseWatch
+
+	// Only one discovery runs at a time
+	lock sync.Mutex
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CourseDiscoveryConfig is the config for a CourseDiscovery
+type CourseDiscoveryConfig struct {
+	Db     database.Database
+	AppFs  *appfs.AppFs
+	Logger *logger.Logger
+
+	// Optional. When nil, discovered courses are not scanned until the next discovery run
+	// that has a CourseScan (e.g. when discovery is run from the CLI)
+	CourseScan *coursescan.CourseScan
+
+	// Optional. When nil, discovered courses are picked up by the watcher on its next sync
+	CourseWatch *coursewatch.CourseWatch
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Result is the outcome of discovering a library root
+type Result struct {
+	// Courses that were created
+	Created []*models.Course
+
+	// Courses that had a scan queued
+	Queued int
+
+	// Courses whose directory no longer exists
+	Missing int
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// New creates a new CourseDiscovery
+func New(config *CourseDiscoveryConfig) *CourseDiscovery {
+	return &CourseDiscovery{
+		appFs:       config.AppFs,
+		dao:         dao.New(config.Db),
+		logger:      config.Logger,
+		courseScan:  config.CourseScan,
+		courseWatch: config.CourseWatch,
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DiscoverAll discovers courses for every library root. A failure for one root is logged and
+// does not stop the remaining roots from being discovered
+func (d *CourseDiscovery) DiscoverAll(ctx context.Context) error {
+	roots, err := d.dao.ListLibraryRoots(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, root := range roots {
+		if _, err := d.Discover(ctx, root); err != nil {
+			d.logger.Error().
+				Err(err).
+				Str("library_root_id", root.ID).
+				Str("library_root_path", root.Path).
+				Msg("Failed to discover library root")
+		}
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Discover walks a library root looking for course directories. New courses are created and
+// scans are queued for any course under the root that has not completed an initial scan.
+// Courses whose directory has disappeared are flagged as missing when the root has
+// `FlagMissing` enabled
+//
+// Returns utils.ErrDiscoveryRunning when another discovery is in progress
+func (d *CourseDiscovery) Discover(ctx context.Context, root *models.LibraryRoot) (*Result, error) {
+	if root == nil {
+		return nil, utils.ErrNilPtr
+	}
+
+	if !d.lock.TryLock() {
+		return nil, utils.ErrDiscoveryRunning
+	}
+	defer d.lock.Unlock()
+
+	rootPath := filepath.Clean(utils.NormalizeWindowsDrive(root.Path))
+
+	d.logger.Info().
+		Str("library_root_id", root.ID).
+		Str("library_root_path", rootPath).
+		Msg("Discovering courses")
+
+	// Existing courses at, beneath or above the root, so they (and their ancestors and
+	// descendants) are not created again. This is filtered in Go rather than with a LIKE as
+	// the path may contain LIKE wildcards and a plain prefix would match sibling directories
+	existing, err := d.dao.ListCourses(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	coursePaths := make([]string, 0, len(existing))
+	for _, course := range existing {
+		coursePath := filepath.Clean(course.Path)
+		if classify(rootPath, []string{coursePath}) == types.PathClassificationNone {
+			continue
+		}
+
+		coursePaths = append(coursePaths, coursePath)
+	}
+
+	candidates, err := d.findCandidates(root, rootPath, coursePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Created: []*models.Course{}}
+
+	for _, path := range candidates {
+		course := &models.Course{
+			Title:         filepath.Base(path),
+			Path:          path,
+			Available:     true,
+			Watch:         true,
+			LibraryRootID: sql.NullString{String: root.ID, Valid: true},
+		}
+
+		if err := d.dao.CreateCourse(ctx, course); err != nil {
+			d.logger.Error().
+				Err(err).
+				Str("library_root_id", root.ID).
+				Str("course_path", path).
+				Msg("Failed to create discovered course")
+			continue
+		}
+
+		result.Created = append(result.Created, course)
+
+		if d.courseWatch != nil {
+			if err := d.courseWatch.Watch(course.ID, course.Path); err != nil {
+				d.logger.Warn().
+					Err(err).
+					Str("course_id", course.ID).
+					Str("course_path", course.Path).
+					Msg("Failed to watch discovered course")
+			}
+		}
+	}
+
+	if err := d.reconcileCourses(ctx, root, result); err != nil {
+		return nil, err
+	}
+
+	root.LastDiscoveredAt = types.NowDateTime()
+	if err := d.dao.UpdateLibraryRoot(ctx, root); err != nil {
+		return nil, err
+	}
+
+	d.logger.Info().
+		Str("library_root_id", root.ID).
+		Str("library_root_path", rootPath).
+		Int("created", len(result.Created)).
+		Int("queued", result.Queued).
+		Int("missing", result.Missing).
+		Msg("Discovering courses completed")
+
+	return result, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// reconcileCourses queues a scan for courses under the root that have not had an initial
+// scan and updates the missing flag for courses whose directory has disappeared (or
+// reappeared)
+func (d *CourseDiscovery) reconcileCourses(ctx context.Context, root *models.LibraryRoot, result *Result) error {
+	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_LIBRARY_ROOT_ID: root.ID})
+	courses, err := d.dao.ListCourses(ctx, dbOpts)
+	if err != nil {
+		return err
+	}
+
+	for _, course := range courses {
+		_, statErr := d.appFs.Fs.Stat(course.Path)
+		if statErr != nil && !os.IsNotExist(statErr) {
+			d.logger.Error().
+				Err(statErr).
+				Str("course_id", course.ID).
+				Str("course_path", course.Path).
+				Msg("Failed to stat course")
+			continue
+		}
+
+		exists := statErr == nil
+		if !exists {
+			result.Missing++
+		}
+
+		missing := root.FlagMissing && !exists
+		if course.Missing != missing {
+			course.Missing = missing
+			if err := d.dao.UpdateCourse(ctx, course); err != nil {
+				return err
+			}
+		}
+
+		if !exists || course.InitialScan || d.courseScan == nil || d.courseScan.IsScanning(course.ID) {
+			continue
+		}
+
+		if _, err := d.courseScan.AddWithPriority(ctx, course.ID, coursescan.ScanPriorityLow); err != nil {
+			d.logger.Error().
+				Err(err).
+				Str("course_id", course.ID).
+				Msg("Failed to queue scan for discovered course")
+			continue
+		}
+
+		result.Queued++
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// findCandidates walks the root, down to the max depth, and returns the directories that look
+// like courses. Once a directory is considered a course, its sub-directories are not walked.
+// Existing courses and their descendants are skipped, while directories containing a course
+// are walked but never considered a course themselves
+func (d *CourseDiscovery) findCandidates(root *models.LibraryRoot, rootPath string, coursePaths []string) ([]string, error) {
+	candidates := []string{}
+
+	var walk func(path string, depth int) error
+	walk = func(path string, depth int) error {
+		contents, err := d.appFs.ReadDir(path, true)
+		if err != nil {
+			return err
+		}
+
+		for _, dir := range contents.Directories {
+			if strings.HasPrefix(dir.Name(), ".") {
+				continue
+			}
+
+			dirPath := filepath.Join(path, dir.Name())
+
+			switch classify(dirPath, coursePaths) {
+			case types.PathClassificationCourse, types.PathClassificationDescendant:
+				continue
+			case types.PathClassificationAncestor:
+				if depth < root.MaxDepth {
+					if err := walk(dirPath, depth+1); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			isCourse, err := d.isCourse(root, dirPath)
+			if err != nil {
+				d.logger.Debug().
+					Err(err).
+					Str("path", dirPath).
+					Msg("Unable to read directory")
+				continue
+			}
+
+			if isCourse {
+				candidates = append(candidates, dirPath)
+				continue
+			}
+
+			if depth < root.MaxDepth {
+				if err := walk(dirPath, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(rootPath, 1); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// isCourse returns true when a directory looks like a course. This is the case when the
+// directory has assets directly within it, or has numbered sub-directories (modules) that
+// contain assets
+//
+// When the root has `RequireNumbered` enabled, only numbered files are considered assets. When
+// the root has `RequireVideo` enabled, at least one of the assets must be a video
+func (d *CourseDiscovery) isCourse(root *models.LibraryRoot, path string) (bool, error) {
+	contents, err := d.appFs.ReadDir(path, false)
+	if err != nil {
+		return false, err
+	}
+
+	files := contents.Files
+	for _, dir := range contents.Directories {
+		if !numberedRegex.MatchString(dir.Name()) {
+			continue
+		}
+
+		moduleContents, err := d.appFs.ReadDir(filepath.Join(path, dir.Name()), false)
+		if err != nil {
+			continue
+		}
+
+		files = append(files, moduleContents.Files...)
+	}
+
+	for _, file := range files {
+		if root.RequireNumbered && !numberedRegex.MatchString(file.Name()) {
+			continue
+		}
+
+		assetType, err := types.NewAsset(strings.TrimPrefix(filepath.Ext(file.Name()), "."))
+		if err != nil {
+			continue
+		}
+
+		// Audio counts as video here so that audio-only courses are still discovered
+		if !root.RequireVideo || assetType.IsMedia() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// classify classifies a path against the existing course paths. Unlike a plain prefix match,
+// a path is only an ancestor or descendant when the match ends on a path separator
+func classify(path string, coursePaths []string) types.PathClassification {
+	classification := types.PathClassificationNone
+
+	for _, coursePath := range coursePaths {
+		switch {
+		case coursePath == path:
+			return types.PathClassificationCourse
+		case strings.HasPrefix(path, coursePath+string(filepath.Separator)):
+			return types.PathClassificationDescendant
+		case strings.HasPrefix(coursePath, path+string(filepath.Separator)):
+			classification = types.PathClassificationAncestor
+		}
+	}
+
+	return classification
+}
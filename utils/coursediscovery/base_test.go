@@ -0,0 +1,271 @@
+package coursediscovery
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func setup(t *testing.T) (*CourseDiscovery, context.Context) {
+	t.Helper()
+
+	testLogger := logger.NilLogger()
+
+	appFs := appfs.New(afero.NewMemMapFs())
+
+	dbManager, err := database.NewSQLiteManager(&database.DatabaseManagerConfig{
+		DataDir: "./oc_data",
+		AppFs:   appFs,
+		Testing: true,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, dbManager)
+
+	courseScan := coursescan.New(&coursescan.CourseScanConfig{
+		Db:     dbManager.DataDb,
+		AppFs:  appFs,
+		Logger: testLogger.WithCourseScan(),
+	})
+
+	discovery := New(&CourseDiscoveryConfig{
+		Db:         dbManager.DataDb,
+		AppFs:      appFs,
+		Logger:     testLogger.WithCourseDiscovery(),
+		CourseScan: courseScan,
+	})
+
+	return discovery, context.Background()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func createRoot(t *testing.T, d *CourseDiscovery, ctx context.Context, root *models.LibraryRoot, files ...string) *models.LibraryRoot {
+	t.Helper()
+
+	require.NoError(t, d.appFs.Fs.MkdirAll(root.Path, 0755))
+	for _, file := range files {
+		require.NoError(t, afero.WriteFile(d.appFs.Fs, filepath.Join(root.Path, file), []byte("file"), 0644))
+	}
+
+	require.NoError(t, d.dao.CreateLibraryRoot(ctx, root))
+
+	return root
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func createdPaths(result *Result) []string {
+	return utils.Map(result.Created, func(c *models.Course) string { return c.Path })
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourseDiscovery_Discover(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		d, ctx := setup(t)
+
+		root := createRoot(t, d, ctx, &models.LibraryRoot{Path: "/library", MaxDepth: 2},
+			"Course A/01 intro.mp4",
+			"Category/Course B/01 Module/01 video.mp4",
+			"Category/Course C/README.txt",
+			"Other/notes.doc",
+			".trash/Course D/01 intro.mp4",
+		)
+
+		result, err := d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{
+			"/library/Course A",
+			"/library/Category/Course B",
+			"/library/Category/Course C",
+		}, createdPaths(result))
+		require.Equal(t, 3, result.Queued)
+		require.Zero(t, result.Missing)
+
+		for _, course := range result.Created {
+			require.Equal(t, filepath.Base(course.Path), course.Title)
+			require.True(t, course.Available)
+			require.True(t, course.Watch)
+			require.Equal(t, root.ID, course.LibraryRootID.String)
+			require.NotNil(t, d.courseScan.GetScanByCourseID(course.ID))
+		}
+
+		record, err := d.dao.GetLibraryRoot(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.LIBRARY_ROOT_TABLE_ID: root.ID}))
+		require.NoError(t, err)
+		require.False(t, record.LastDiscoveredAt.IsZero())
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		d, ctx := setup(t)
+
+		root := createRoot(t, d, ctx, &models.LibraryRoot{Path: "/library", MaxDepth: 1},
+			"Course A/01 intro.mp4",
+			"Category/Course B/01 intro.mp4",
+		)
+
+		result, err := d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/library/Course A"}, createdPaths(result))
+	})
+
+	t.Run("require video", func(t *testing.T) {
+		d, ctx := setup(t)
+
+		root := createRoot(t, d, ctx, &models.LibraryRoot{Path: "/library", MaxDepth: 2, RequireVideo: true},
+			"Course A/01 intro.mp4",
+			"Course B/01 notes.pdf",
+		)
+
+		result, err := d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/library/Course A"}, createdPaths(result))
+	})
+
+	t.Run("require numbered", func(t *testing.T) {
+		d, ctx := setup(t)
+
+		root := createRoot(t, d, ctx, &models.LibraryRoot{Path: "/library", MaxDepth: 2, RequireNumbered: true},
+			"Course A/01 intro.mp4",
+			"Course B/intro.mp4",
+		)
+
+		result, err := d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/library/Course A"}, createdPaths(result))
+	})
+
+	t.Run("existing courses", func(t *testing.T) {
+		d, ctx := setup(t)
+
+		root := createRoot(t, d, ctx, &models.LibraryRoot{Path: "/library", MaxDepth: 3},
+			"Course/01 intro.mp4",
+			"Course 2/01 intro.mp4",
+			"Category/Nested/01 intro.mp4",
+		)
+
+		// An existing course, and an existing course within a category
+		require.NoError(t, d.dao.CreateCourse(ctx, &models.Course{Title: "Course", Path: "/library/Course"}))
+		require.NoError(t, d.dao.CreateCourse(ctx, &models.Course{Title: "Nested", Path: "/library/Category/Nested"}))
+
+		result, err := d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/library/Course 2"}, createdPaths(result))
+
+		// Running again finds nothing new
+		result, err = d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.Empty(t, result.Created)
+	})
+
+	t.Run("existing courses in sibling directory", func(t *testing.T) {
+		d, ctx := setup(t)
+
+		root := createRoot(t, d, ctx, &models.LibraryRoot{Path: "/library", MaxDepth: 2},
+			"Course/01 intro.mp4",
+		)
+
+		// A course in a directory sharing the root's prefix, and one whose name holds LIKE
+		// wildcards, are not existing courses of this root
+		require.NoError(t, d.dao.CreateCourse(ctx, &models.Course{Title: "Course", Path: "/library2/Course"}))
+		require.NoError(t, d.dao.CreateCourse(ctx, &models.Course{Title: "Course", Path: "/librar_/Course"}))
+
+		result, err := d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/library/Course"}, createdPaths(result))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		d, ctx := setup(t)
+
+		root := createRoot(t, d, ctx, &models.LibraryRoot{Path: "/library", MaxDepth: 2, FlagMissing: true},
+			"Course A/01 intro.mp4",
+		)
+
+		result, err := d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.Len(t, result.Created, 1)
+
+		course := result.Created[0]
+		require.NoError(t, d.appFs.Fs.RemoveAll(course.Path))
+
+		result, err = d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Missing)
+
+		dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID})
+		record, err := d.dao.GetCourse(ctx, dbOpts)
+		require.NoError(t, err)
+		require.True(t, record.Missing)
+
+		// The directory returns
+		require.NoError(t, d.appFs.Fs.MkdirAll(course.Path, 0755))
+
+		result, err = d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.Zero(t, result.Missing)
+
+		record, err = d.dao.GetCourse(ctx, dbOpts)
+		require.NoError(t, err)
+		require.False(t, record.Missing)
+	})
+
+	t.Run("missing not flagged", func(t *testing.T) {
+		d, ctx := setup(t)
+
+		root := createRoot(t, d, ctx, &models.LibraryRoot{Path: "/library", MaxDepth: 2})
+
+		course := &models.Course{
+			Title:         "Course A",
+			Path:          "/library/Course A",
+			LibraryRootID: sql.NullString{String: root.ID, Valid: true},
+		}
+		require.NoError(t, d.dao.CreateCourse(ctx, course))
+
+		result, err := d.Discover(ctx, root)
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Missing)
+
+		dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID})
+		record, err := d.dao.GetCourse(ctx, dbOpts)
+		require.NoError(t, err)
+		require.False(t, record.Missing)
+	})
+
+	t.Run("running", func(t *testing.T) {
+		d, ctx := setup(t)
+
+		root := createRoot(t, d, ctx, &models.LibraryRoot{Path: "/library", MaxDepth: 2})
+
+		d.lock.Lock()
+		defer d.lock.Unlock()
+
+		result, err := d.Discover(ctx, root)
+		require.ErrorIs(t, err, utils.ErrDiscoveryRunning)
+		require.Nil(t, result)
+	})
+
+	t.Run("invalid root path", func(t *testing.T) {
+		d, ctx := setup(t)
+
+		root := &models.LibraryRoot{Path: "/library", MaxDepth: 2}
+		require.NoError(t, d.dao.CreateLibraryRoot(ctx, root))
+
+		result, err := d.Discover(ctx, root)
+		require.Error(t, err)
+		require.Nil(t, result)
+	})
+}
@@ -3,6 +3,7 @@ package coursemetadata
 import (
 	"encoding/json"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/afero"
 )
@@ -12,13 +13,96 @@ import (
 const (
 	// MetadataFileName is the name of the course metadata file
 	MetadataFileName = "course.json"
+
+	// DescriptionFileName is the name of the optional markdown description file
+	DescriptionFileName = "description.md"
+
+	// ReadmeFileName is used for the description when there is no description file
+	ReadmeFileName = "README.md"
+
+	// AuthorFileName is the name of the optional authors file, with one author per line
+	AuthorFileName = "author.txt"
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // CourseMetadata represents the metadata stored in course.json
 type CourseMetadata struct {
+	// The display title. When empty, the existing course title is kept
+	Title string `json:"title,omitempty"`
+
+	// A markdown description. Takes precedence over description.md and README.md
+	Description string `json:"description,omitempty"`
+
+	// Takes precedence over author.txt
+	Authors []string `json:"authors,omitempty"`
+
+	Language    string `json:"language,omitempty"`
+	Difficulty  string `json:"difficulty,omitempty"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+	SourceURL   string `json:"sourceUrl,omitempty"`
+
+	// Display names and ordering overrides for the modules (directories) of the course
+	Modules []ModuleMetadata `json:"modules,omitempty"`
+
 	Tags []string `json:"tags,omitempty"`
+
+	// FilenameRules are tried, in order, for files that do not follow the default
+	// `<prefix> - <title>` naming convention
+	FilenameRules []FilenameRule `json:"filenameRules,omitempty"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ModuleMetadata overrides how a module is displayed
+type ModuleMetadata struct {
+	// The module directory name, or its path within the course
+	Name string `json:"name"`
+
+	Title string `json:"title,omitempty"`
+
+	// The display position. Modules without an order are shown after those with one
+	Order *int `json:"order,omitempty"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Sidecars holds the details read from the optional files that sit alongside course.json
+type Sidecars struct {
+	Description string
+	Authors     []string
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// FilenameRuleType is the strategy a filename rule uses to parse a filename
+type FilenameRuleType string
+
+const (
+	// FilenameRuleRegex parses the filename (without its extension) using a regex with the
+	// named groups `Prefix` (required), `Title`, `SubPrefix` and `SubTitle`
+	FilenameRuleRegex FilenameRuleType = "regex"
+
+	// FilenameRuleNatural numbers the remaining files in each module by their natural sort
+	// order (`2 intro` before `10 outro`)
+	FilenameRuleNatural FilenameRuleType = "natural"
+
+	// FilenameRuleMtime numbers the remaining files in each module by their modification
+	// time, oldest first
+	FilenameRuleMtime FilenameRuleType = "mtime"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// FilenameRule is an alternative way of parsing filenames for a course
+type FilenameRule struct {
+	// An optional name, used when reporting how a file was classified
+	Name string `json:"name,omitempty"`
+
+	Type FilenameRuleType `json:"type"`
+
+	// The regex for a regex rule
+	Pattern string `json:"pattern,omitempty"`
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -55,6 +139,71 @@ func ReadMetadata(fs afero.Fs, coursePath string) (*CourseMetadata, error) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// ReadSidecars reads description.md (falling back to README.md) and author.txt from the
+// course root directory. Files that don't exist are ignored
+func ReadSidecars(fs afero.Fs, coursePath string) (*Sidecars, error) {
+	sidecars := &Sidecars{}
+
+	description, err := readOptionalFile(fs, filepath.Join(coursePath, DescriptionFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	if description == "" {
+		if description, err = readOptionalFile(fs, filepath.Join(coursePath, ReadmeFileName)); err != nil {
+			return nil, err
+		}
+	}
+
+	sidecars.Description = description
+
+	authors, err := readOptionalFile(fs, filepath.Join(coursePath, AuthorFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(authors, "\n") {
+		if author := strings.TrimSpace(line); author != "" {
+			sidecars.Authors = append(sidecars.Authors, author)
+		}
+	}
+
+	return sidecars, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// IsMetadataFile returns true when the filename is course.json or one of the sidecar files.
+// These are read from the course root directory and are never added as lessons
+func IsMetadataFile(filename string) bool {
+	switch filename {
+	case MetadataFileName, DescriptionFileName, ReadmeFileName, AuthorFileName:
+		return true
+	}
+
+	return false
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// readOptionalFile returns the trimmed content of a file, or an empty string when the file
+// doesn't exist
+func readOptionalFile(fs afero.Fs, path string) (string, error) {
+	exists, err := afero.Exists(fs, path)
+	if err != nil || !exists {
+		return "", err
+	}
+
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // WriteMetadata writes the course.json file to the course root directory.
 // Creates the file if it doesn't exist, overwrites if it does.
 func WriteMetadata(fs afero.Fs, coursePath string, metadata *CourseMetadata) error {
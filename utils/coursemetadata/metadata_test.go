@@ -50,6 +50,70 @@ func TestReadMetadata(t *testing.T) {
 		require.Empty(t, metadata.Tags)
 	})
 
+	t.Run("filename rules", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		coursePath := "/test-course"
+
+		require.NoError(t, fs.MkdirAll(coursePath, 0755))
+
+		metadataPath := filepath.Join(coursePath, MetadataFileName)
+		data := `{
+  "filenameRules": [
+    {"name": "lessons", "type": "regex", "pattern": "^Lesson (?P<Prefix>\\d+) - (?P<Title>.+)$"},
+    {"type": "natural"}
+  ]
+}`
+		require.NoError(t, afero.WriteFile(fs, metadataPath, []byte(data), 0644))
+
+		metadata, err := ReadMetadata(fs, coursePath)
+		require.NoError(t, err)
+		require.NotNil(t, metadata)
+		require.Equal(t, []FilenameRule{
+			{Name: "lessons", Type: FilenameRuleRegex, Pattern: `^Lesson (?P<Prefix>\d+) - (?P<Title>.+)$`},
+			{Type: FilenameRuleNatural},
+		}, metadata.FilenameRules)
+	})
+
+	t.Run("details", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		coursePath := "/test-course"
+
+		require.NoError(t, fs.MkdirAll(coursePath, 0755))
+
+		metadataPath := filepath.Join(coursePath, MetadataFileName)
+		data := `{
+  "title": "Learn Go",
+  "description": "# Learn Go",
+  "authors": ["Jane", "John"],
+  "language": "en",
+  "difficulty": "beginner",
+  "releaseDate": "2024-01-02",
+  "sourceUrl": "https://example.com/go",
+  "modules": [
+    {"name": "01 Basics", "title": "The Basics", "order": 2},
+    {"name": "02 Advanced"}
+  ]
+}`
+		require.NoError(t, afero.WriteFile(fs, metadataPath, []byte(data), 0644))
+
+		metadata, err := ReadMetadata(fs, coursePath)
+		require.NoError(t, err)
+		require.NotNil(t, metadata)
+		require.Equal(t, "Learn Go", metadata.Title)
+		require.Equal(t, "# Learn Go", metadata.Description)
+		require.Equal(t, []string{"Jane", "John"}, metadata.Authors)
+		require.Equal(t, "en", metadata.Language)
+		require.Equal(t, "beginner", metadata.Difficulty)
+		require.Equal(t, "2024-01-02", metadata.ReleaseDate)
+		require.Equal(t, "https://example.com/go", metadata.SourceURL)
+
+		order := 2
+		require.Equal(t, []ModuleMetadata{
+			{Name: "01 Basics", Title: "The Basics", Order: &order},
+			{Name: "02 Advanced"},
+		}, metadata.Modules)
+	})
+
 	t.Run("file doesn't exist", func(t *testing.T) {
 		fs := afero.NewMemMapFs()
 		coursePath := "/test-course"
@@ -78,6 +142,49 @@ func TestReadMetadata(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func TestReadSidecars(t *testing.T) {
+	t.Run("none", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		coursePath := "/test-course"
+
+		require.NoError(t, fs.MkdirAll(coursePath, 0755))
+
+		sidecars, err := ReadSidecars(fs, coursePath)
+		require.NoError(t, err)
+		require.Empty(t, sidecars.Description)
+		require.Empty(t, sidecars.Authors)
+	})
+
+	t.Run("description and authors", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		coursePath := "/test-course"
+
+		require.NoError(t, fs.MkdirAll(coursePath, 0755))
+		require.NoError(t, afero.WriteFile(fs, filepath.Join(coursePath, DescriptionFileName), []byte("\n# Description\n"), 0644))
+		require.NoError(t, afero.WriteFile(fs, filepath.Join(coursePath, ReadmeFileName), []byte("# Readme"), 0644))
+		require.NoError(t, afero.WriteFile(fs, filepath.Join(coursePath, AuthorFileName), []byte("Jane\n\n  John  \n"), 0644))
+
+		sidecars, err := ReadSidecars(fs, coursePath)
+		require.NoError(t, err)
+		require.Equal(t, "# Description", sidecars.Description)
+		require.Equal(t, []string{"Jane", "John"}, sidecars.Authors)
+	})
+
+	t.Run("readme fallback", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		coursePath := "/test-course"
+
+		require.NoError(t, fs.MkdirAll(coursePath, 0755))
+		require.NoError(t, afero.WriteFile(fs, filepath.Join(coursePath, ReadmeFileName), []byte("# Readme"), 0644))
+
+		sidecars, err := ReadSidecars(fs, coursePath)
+		require.NoError(t, err)
+		require.Equal(t, "# Readme", sidecars.Description)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func TestWriteMetadata(t *testing.T) {
 	t.Run("write new file", func(t *testing.T) {
 		fs := afero.NewMemMapFs()
@@ -2,6 +2,8 @@ package coursemetadata
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"path/filepath"
 	"sync"
 
@@ -12,6 +14,12 @@ import (
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// ErrUnreadableMetadata is returned when an existing course.json cannot be read, so it cannot
+// be updated without losing what it holds
+var ErrUnreadableMetadata = errors.New("course metadata could not be read")
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // MetadataWriter handles concurrent writes to course.json files
 // Ensures sequential writes per course while allowing concurrent writes across courses
 type MetadataWriter struct {
@@ -70,6 +78,72 @@ func (w *MetadataWriter) WriteMetadataAsync(courseID, coursePath string, metadat
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// WriteTagsAsync queues a write of the tags for the given course. Unlike WriteMetadataAsync,
+// the existing course.json is read first so that other fields are kept
+func (w *MetadataWriter) WriteTagsAsync(courseID, coursePath string, tags []string) {
+	mutex, _ := w.mutexes.GetOrCreate(courseID, func() *sync.Mutex {
+		return &sync.Mutex{}
+	})
+
+	go func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err := w.updateMetadata(coursePath, func(metadata *CourseMetadata) {
+			metadata.Tags = tags
+		}); err != nil {
+			w.logger.Error().
+				Err(err).
+				Str("course_id", courseID).
+				Str("course_path", coursePath).
+				Msg("Failed to write course metadata")
+		} else {
+			w.logger.Debug().
+				Str("course_id", courseID).
+				Str("course_path", coursePath).
+				Msg("Successfully wrote course metadata")
+		}
+	}()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// UpdateMetadataSync reads the existing course.json, applies the update and writes it back,
+// keeping any fields the update does not touch. A course.json that cannot be read is left as is
+// and ErrUnreadableMetadata is returned
+func (w *MetadataWriter) UpdateMetadataSync(courseID, coursePath string, update func(*CourseMetadata)) error {
+	mutex, _ := w.mutexes.GetOrCreate(courseID, func() *sync.Mutex {
+		return &sync.Mutex{}
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return w.updateMetadata(coursePath, update)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// updateMetadata does a read-modify-write of course.json. An unreadable file is never
+// overwritten, as that would lose the fields the update does not touch. The caller must hold the
+// course mutex
+func (w *MetadataWriter) updateMetadata(coursePath string, update func(*CourseMetadata)) error {
+	metadata, err := ReadMetadata(w.fs, coursePath)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnreadableMetadata, err)
+	}
+
+	if metadata == nil {
+		metadata = &CourseMetadata{}
+	}
+
+	update(metadata)
+
+	return w.writeMetadataAtomic(coursePath, metadata)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // writeMetadataAtomic writes metadata using atomic file operations (temp file + rename)
 // This helps handle cases where someone is manually editing the file
 func (w *MetadataWriter) writeMetadataAtomic(coursePath string, metadata *CourseMetadata) error {
@@ -155,6 +155,108 @@ func TestMetadataWriter_WriteMetadataAsync(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func TestMetadataWriter_WriteTagsAsync(t *testing.T) {
+	t.Run("new file", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		writer := NewMetadataWriter(fs, logger.NilLogger())
+
+		coursePath := "/test-course"
+		require.NoError(t, fs.MkdirAll(coursePath, 0755))
+
+		writer.WriteTagsAsync("course-1", coursePath, []string{"go"})
+
+		require.Eventually(t, func() bool {
+			readMetadata, err := ReadMetadata(fs, coursePath)
+			return err == nil && readMetadata != nil && len(readMetadata.Tags) == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("keeps other fields", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		writer := NewMetadataWriter(fs, logger.NilLogger())
+
+		coursePath := "/test-course"
+		require.NoError(t, fs.MkdirAll(coursePath, 0755))
+
+		rules := []FilenameRule{{Type: FilenameRuleNatural}}
+		require.NoError(t, WriteMetadata(fs, coursePath, &CourseMetadata{Tags: []string{"old"}, FilenameRules: rules}))
+
+		writer.WriteTagsAsync("course-1", coursePath, []string{"go", "programming"})
+
+		require.Eventually(t, func() bool {
+			readMetadata, err := ReadMetadata(fs, coursePath)
+			return err == nil && readMetadata != nil && len(readMetadata.Tags) == 2
+		}, time.Second, 10*time.Millisecond)
+
+		readMetadata, err := ReadMetadata(fs, coursePath)
+		require.NoError(t, err)
+		require.Equal(t, []string{"go", "programming"}, readMetadata.Tags)
+		require.Equal(t, rules, readMetadata.FilenameRules)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestMetadataWriter_UpdateMetadataSync(t *testing.T) {
+	t.Run("new file", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		writer := NewMetadataWriter(fs, logger.NilLogger())
+
+		coursePath := "/test-course"
+		require.NoError(t, fs.MkdirAll(coursePath, 0755))
+
+		require.NoError(t, writer.UpdateMetadataSync("course-1", coursePath, func(metadata *CourseMetadata) {
+			metadata.Title = "Learn Go"
+		}))
+
+		readMetadata, err := ReadMetadata(fs, coursePath)
+		require.NoError(t, err)
+		require.Equal(t, "Learn Go", readMetadata.Title)
+	})
+
+	t.Run("keeps other fields", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		writer := NewMetadataWriter(fs, logger.NilLogger())
+
+		coursePath := "/test-course"
+		require.NoError(t, fs.MkdirAll(coursePath, 0755))
+
+		rules := []FilenameRule{{Type: FilenameRuleNatural}}
+		require.NoError(t, WriteMetadata(fs, coursePath, &CourseMetadata{Tags: []string{"go"}, FilenameRules: rules}))
+
+		require.NoError(t, writer.UpdateMetadataSync("course-1", coursePath, func(metadata *CourseMetadata) {
+			metadata.Authors = []string{"Jane"}
+		}))
+
+		readMetadata, err := ReadMetadata(fs, coursePath)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Jane"}, readMetadata.Authors)
+		require.Equal(t, []string{"go"}, readMetadata.Tags)
+		require.Equal(t, rules, readMetadata.FilenameRules)
+	})
+
+	t.Run("unreadable file", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		writer := NewMetadataWriter(fs, logger.NilLogger())
+
+		coursePath := "/test-course"
+		metadataPath := filepath.Join(coursePath, MetadataFileName)
+		require.NoError(t, afero.WriteFile(fs, metadataPath, []byte(`{"title": "Learn Go",`), 0644))
+
+		err := writer.UpdateMetadataSync("course-1", coursePath, func(metadata *CourseMetadata) {
+			metadata.Authors = []string{"Jane"}
+		})
+		require.ErrorIs(t, err, ErrUnreadableMetadata)
+
+		// The file is left as is
+		data, err := afero.ReadFile(fs, metadataPath)
+		require.NoError(t, err)
+		require.Equal(t, `{"title": "Learn Go",`, string(data))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func TestMetadataWriter_WriteMetadataSync(t *testing.T) {
 	t.Run("single write", func(t *testing.T) {
 		fs := afero.NewMemMapFs()
@@ -0,0 +1,520 @@
+package coursemove
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/coursewatch"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Mode is how a course is moved
+type Mode string
+
+const (
+	// ModeMove moves the course files to the destination
+	ModeMove Mode = "move"
+
+	// ModeRelink points the course at a destination the files were already moved to
+	ModeRelink Mode = "relink"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Stage is the stage a move is in
+type Stage string
+
+const (
+	StageMoving    Stage = "moving"
+	StageCopying   Stage = "copying"
+	StageVerifying Stage = "verifying"
+	StageUpdating  Stage = "updating"
+	StageDone      Stage = "done"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Progress is reported as a move runs. When copying, done and total are bytes. When verifying,
+// they are assets
+type Progress struct {
+	CourseID string
+	Stage    Stage
+	Done     int64
+	Total    int64
+}
+
+// ProgressFn receives the progress of a move
+type ProgressFn func(Progress)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Result is the outcome of moving a course
+type Result struct {
+	CourseID string
+	From     string
+	To       string
+	Mode     Mode
+
+	// True when the files were copied to the destination, rather than renamed, as the
+	// destination is on another filesystem
+	Copied bool
+
+	// The number of assets whose hash was verified at the destination
+	Verified int
+
+	// Set when moving the course failed, during a bulk move
+	Err error
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CourseMove moves courses to a new path, keeping the course, lessons and assets (and so the
+// progress, favourites and tags) intact
+type CourseMove struct {
+	appFs       *appfs.AppFs
+	dao         *dao.DAO
+	logger      *logger.Logger
+	courseScan  *coursescan.CourseScan
+	courseWatch *coursewatch.CourseWatch
+	onRelocated func(courseID string, assetIDs []string)
+
+	// Only one move runs at a time
+	lock sync.Mutex
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CourseMoveConfig is the config for a CourseMove
+type CourseMoveConfig struct {
+	Db     database.Database
+	AppFs  *appfs.AppFs
+	Logger *logger.Logger
+
+	// Optional. When set, scans for a course are cancelled before it is moved and a scan is
+	// queued once it has moved
+	CourseScan *coursescan.CourseScan
+
+	// Optional. When set, a watched course is watched at its new path once moved
+	CourseWatch *coursewatch.CourseWatch
+
+	// Optional. Called with the course assets once their paths are rewritten, so anything
+	// holding on to the old paths, such as open HLS streams, can be dropped
+	OnRelocated func(courseID string, assetIDs []string)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// New creates a new CourseMove
+func New(config *CourseMoveConfig) *CourseMove {
+	return &CourseMove{
+		appFs:       config.AppFs,
+		dao:         dao.New(config.Db),
+		logger:      config.Logger,
+		courseScan:  config.CourseScan,
+		courseWatch: config.CourseWatch,
+		onRelocated: config.OnRelocated,
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Move moves a course to the destination path.
+//
+// In ModeMove, the course files are renamed to the destination, falling back to a copy when
+// the destination is on another filesystem. In ModeRelink, the files are expected to already be
+// at the destination. In both modes the assets are hashed at the destination and compared to the
+// recorded hashes before the course, asset, attachment and subtitle paths are rewritten in a
+// single transaction. A failed move leaves the files at their original path
+func (m *CourseMove) Move(ctx context.Context, courseID, dest string, mode Mode, progress ProgressFn) (*Result, error) {
+	if mode != ModeMove && mode != ModeRelink {
+		return nil, utils.ErrMoveMode
+	}
+
+	if progress == nil {
+		progress = func(Progress) {}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	course, err := m.dao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseID}))
+	if err != nil {
+		return nil, err
+	}
+
+	if course == nil {
+		return nil, utils.ErrCourseNotFound
+	}
+
+	from := course.Path
+	dest = utils.NormalizeWindowsDrive(filepath.Clean(dest))
+
+	if dest == from {
+		return nil, utils.ErrMoveSamePath
+	}
+
+	if strings.HasPrefix(dest, from+string(filepath.Separator)) {
+		return nil, utils.ErrMoveIntoCourse
+	}
+
+	existing, err := m.dao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_PATH: dest}))
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		return nil, utils.ErrMoveDestExists
+	}
+
+	assets, err := m.dao.ListAssets(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_COURSE_ID: course.ID}))
+	if err != nil {
+		return nil, err
+	}
+
+	// Stop scanning and watching the course while it moves
+	if m.courseScan != nil {
+		m.courseScan.CancelAndRemoveScansByCourseID(course.ID)
+	}
+
+	watching := m.courseWatch != nil && m.courseWatch.IsWatching(course.ID)
+	if watching {
+		m.courseWatch.Unwatch(course.ID)
+	}
+
+	current := from
+	defer func() {
+		if !watching {
+			return
+		}
+
+		if err := m.courseWatch.Watch(course.ID, current); err != nil {
+			m.logger.Warn().
+				Err(err).
+				Str("course_id", course.ID).
+				Str("course_path", current).
+				Msg("Failed to watch moved course")
+		}
+	}()
+
+	course.Maintenance = true
+	if err := m.dao.UpdateCourse(ctx, course); err != nil {
+		return nil, err
+	}
+
+	defer m.clearMaintenance(course.ID)
+
+	result := &Result{CourseID: course.ID, From: from, To: dest, Mode: mode}
+
+	// undo returns the files to their original path when the move fails after they were moved
+	undo := func() {}
+
+	if mode == ModeMove {
+		if exists, err := appfs.DirOrArchiveExists(m.appFs.Fs, from); err != nil || !exists {
+			return nil, utils.ErrMoveSourceMissing
+		}
+
+		if exists, err := afero.Exists(m.appFs.Fs, dest); err != nil || exists {
+			return nil, utils.ErrMoveDestExists
+		}
+
+		if err := m.appFs.Fs.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return nil, err
+		}
+
+		progress(Progress{CourseID: course.ID, Stage: StageMoving})
+
+		if err := m.appFs.Fs.Rename(from, dest); err == nil {
+			undo = func() {
+				if err := m.appFs.Fs.Rename(dest, from); err != nil {
+					m.logger.Error().Err(err).Str("course_id", course.ID).Str("path", dest).Msg("Failed to restore moved course")
+				}
+			}
+		} else {
+			// The destination is likely on another filesystem
+			m.logger.Debug().Err(err).Str("course_id", course.ID).Msg("Rename failed, copying course")
+
+			result.Copied = true
+			undo = func() {
+				if err := m.appFs.Fs.RemoveAll(dest); err != nil {
+					m.logger.Error().Err(err).Str("course_id", course.ID).Str("path", dest).Msg("Failed to remove copied course")
+				}
+			}
+
+			err := copyPath(ctx, m.appFs.Fs, from, dest, func(done, total int64) {
+				progress(Progress{CourseID: course.ID, Stage: StageCopying, Done: done, Total: total})
+			})
+
+			if err != nil {
+				undo()
+				return nil, fmt.Errorf("failed to copy course: %w", err)
+			}
+		}
+	} else if exists, err := appfs.DirOrArchiveExists(m.appFs.Fs, dest); err != nil || !exists {
+		return nil, utils.ErrMoveDestMissing
+	}
+
+	verified, err := m.verify(ctx, course.ID, assets, from, dest, progress)
+	if err != nil {
+		undo()
+		return nil, err
+	}
+
+	result.Verified = verified
+
+	progress(Progress{CourseID: course.ID, Stage: StageUpdating})
+
+	if err := m.dao.RelocateCourse(ctx, course.ID, from, dest); err != nil {
+		undo()
+		return nil, fmt.Errorf("failed to update course paths: %w", err)
+	}
+
+	current = dest
+
+	if m.onRelocated != nil {
+		m.onRelocated(course.ID, utils.Map(assets, func(a *models.Asset) string { return a.ID }))
+	}
+
+	if result.Copied {
+		if err := m.appFs.Fs.RemoveAll(from); err != nil {
+			m.logger.Warn().
+				Err(err).
+				Str("course_id", course.ID).
+				Str("course_path", from).
+				Msg("Failed to remove course from its original path")
+		}
+	}
+
+	m.logger.Info().
+		Str("course_id", course.ID).
+		Str("from", from).
+		Str("to", dest).
+		Str("mode", string(mode)).
+		Bool("copied", result.Copied).
+		Msg("Moved course")
+
+	progress(Progress{CourseID: course.ID, Stage: StageDone})
+
+	// Scan the course at its new path to pick up anything that changed while it was moved
+	if m.courseScan != nil {
+		if _, err := m.courseScan.Add(ctx, course.ID); err != nil {
+			m.logger.Warn().Err(err).Str("course_id", course.ID).Msg("Failed to queue scan for moved course")
+		}
+	}
+
+	return result, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// MoveAll moves each course into the root directory, keeping the name of the course directory
+// (or archive). A failure for one course is recorded in its result and does not stop the
+// remaining courses from being moved
+func (m *CourseMove) MoveAll(ctx context.Context, courseIDs []string, root string, mode Mode, progress ProgressFn) []*Result {
+	results := make([]*Result, 0, len(courseIDs))
+
+	for _, courseID := range courseIDs {
+		if ctx.Err() != nil {
+			results = append(results, &Result{CourseID: courseID, Mode: mode, Err: ctx.Err()})
+			continue
+		}
+
+		course, err := m.dao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseID}))
+		if err == nil && course == nil {
+			err = utils.ErrCourseNotFound
+		}
+
+		if err != nil {
+			results = append(results, &Result{CourseID: courseID, Mode: mode, Err: err})
+			continue
+		}
+
+		dest := filepath.Join(root, filepath.Base(course.Path))
+
+		result, err := m.Move(ctx, courseID, dest, mode, progress)
+		if err != nil {
+			result = &Result{CourseID: courseID, From: course.Path, To: dest, Mode: mode, Err: err}
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// verify hashes each asset at the destination and compares it to the recorded hash, returning
+// the number of assets verified
+func (m *CourseMove) verify(ctx context.Context, courseID string, assets []*models.Asset, from, dest string, progress ProgressFn) (int, error) {
+	total := int64(len(assets))
+
+	for i, asset := range assets {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+
+		path := relocatePath(asset.Path, from, dest)
+
+		hash, err := coursescan.HashFile(m.appFs.Fs, path)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s: %w", utils.ErrMoveHashMismatch, path, err)
+		}
+
+		if asset.Hash != "" && hash != asset.Hash {
+			return 0, fmt.Errorf("%w: %s", utils.ErrMoveHashMismatch, path)
+		}
+
+		progress(Progress{CourseID: courseID, Stage: StageVerifying, Done: int64(i + 1), Total: total})
+	}
+
+	return len(assets), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// clearMaintenance takes the course out of maintenance mode. The course is read again as its
+// path may have changed
+func (m *CourseMove) clearMaintenance(courseID string) {
+	ctx := context.Background()
+
+	course, err := m.dao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseID}))
+	if err != nil || course == nil || !course.Maintenance {
+		return
+	}
+
+	course.Maintenance = false
+	if err := m.dao.UpdateCourse(ctx, course); err != nil {
+		m.logger.Error().Err(err).Str("course_id", courseID).Msg("Failed to clear maintenance mode")
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// relocatePath rewrites a path beneath `from` to be beneath `to`
+func relocatePath(path, from, to string) string {
+	if path == from {
+		return to
+	}
+
+	if strings.HasPrefix(path, from+string(filepath.Separator)) {
+		return to + path[len(from):]
+	}
+
+	return path
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// reportInterval is how many bytes are copied between progress reports
+const reportInterval = 4 * 1024 * 1024
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// copyPath copies a file or directory tree from src to dst, reporting the bytes copied. Mod
+// times are kept so the scanner does not see the files as changed
+func copyPath(ctx context.Context, fs afero.Fs, src, dst string, report func(done, total int64)) error {
+	var total int64
+	err := afero.Walk(fs, src, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	var done, reported int64
+	report(done, total)
+
+	return afero.Walk(fs, src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		target := relocatePath(path, src, dst)
+
+		if info.IsDir() {
+			return fs.MkdirAll(target, os.ModePerm)
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		return copyFile(fs, path, target, info, func(n int64) {
+			done += n
+			if done-reported >= reportInterval || done == total {
+				reported = done
+				report(done, total)
+			}
+		})
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// copyFile copies a single file, reporting the bytes written as it goes
+func copyFile(fs afero.Fs, src, dst string, info os.FileInfo, written func(int64)) error {
+	in, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := fs.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(&progressWriter{w: out, written: written}, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return fs.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// progressWriter reports the number of bytes written
+type progressWriter struct {
+	w       io.Writer
+	written func(int64)
+}
+
+// Write implements io.Writer
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written(int64(n))
+	return n, err
+}
@@ -0,0 +1,340 @@
+package coursemove
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func setup(t *testing.T) (*CourseMove, context.Context) {
+	t.Helper()
+
+	appFs := appfs.New(afero.NewMemMapFs())
+
+	dbManager, err := database.NewSQLiteManager(&database.DatabaseManagerConfig{
+		DataDir: "./oc_data",
+		AppFs:   appFs,
+		Testing: true,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, dbManager)
+
+	move := New(&CourseMoveConfig{
+		Db:     dbManager.DataDb,
+		AppFs:  appFs,
+		Logger: logger.NilLogger().WithCourseMove(),
+	})
+
+	return move, context.Background()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// createCourse creates a course at the path with a lesson, an asset per video and an attachment
+func createCourse(t *testing.T, m *CourseMove, ctx context.Context, path string) *models.Course {
+	t.Helper()
+
+	files := map[string]string{
+		"01 Intro/01 Welcome.mp4": "welcome " + path,
+		"01 Intro/02 Setup.mp4":   "setup " + path,
+		"01 Intro/01 Notes.txt":   "notes",
+	}
+
+	for name, content := range files {
+		require.NoError(t, afero.WriteFile(m.appFs.Fs, filepath.Join(path, name), []byte(content), os.ModePerm))
+	}
+
+	course := &models.Course{Title: filepath.Base(path), Path: path, CardPath: filepath.Join(path, "card.jpg")}
+	require.NoError(t, m.dao.CreateCourse(ctx, course))
+
+	lesson := &models.Lesson{CourseID: course.ID, Title: "Intro", Prefix: sql.NullInt16{Int16: 1, Valid: true}, Module: "01 Intro"}
+	require.NoError(t, m.dao.CreateLesson(ctx, lesson))
+
+	for i, name := range []string{"01 Welcome.mp4", "02 Setup.mp4"} {
+		assetPath := filepath.Join(path, "01 Intro", name)
+
+		hash, err := coursescan.HashFile(m.appFs.Fs, assetPath)
+		require.NoError(t, err)
+
+		asset := &models.Asset{
+			CourseID: course.ID,
+			LessonID: lesson.ID,
+			Title:    name,
+			Prefix:   sql.NullInt16{Int16: int16(i + 1), Valid: true},
+			Module:   "01 Intro",
+			Type:     types.MustAsset("mp4"),
+			Path:     assetPath,
+			FileSize: 1024,
+			ModTime:  time.Now().Format(time.RFC3339Nano),
+			Hash:     hash,
+		}
+		require.NoError(t, m.dao.CreateAsset(ctx, asset))
+	}
+
+	attachment := &models.Attachment{LessonID: lesson.ID, Title: "Notes", Path: filepath.Join(path, "01 Intro", "01 Notes.txt")}
+	require.NoError(t, m.dao.CreateAttachment(ctx, attachment))
+
+	return course
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// requireMoved asserts the course, its assets and attachments point at the path
+func requireMoved(t *testing.T, m *CourseMove, ctx context.Context, courseID, path string) {
+	t.Helper()
+
+	course, err := m.dao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseID}))
+	require.NoError(t, err)
+	require.Equal(t, path, course.Path)
+	require.Equal(t, filepath.Join(path, "card.jpg"), course.CardPath)
+	require.False(t, course.Maintenance)
+
+	assets, err := m.dao.ListAssets(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_COURSE_ID: courseID}))
+	require.NoError(t, err)
+	require.Len(t, assets, 2)
+
+	for _, asset := range assets {
+		require.Equal(t, filepath.Join(path, "01 Intro"), filepath.Dir(asset.Path))
+
+		exists, err := afero.Exists(m.appFs.Fs, asset.Path)
+		require.NoError(t, err)
+		require.True(t, exists, asset.Path)
+	}
+
+	attachments, err := m.dao.ListAttachments(ctx, nil)
+	require.NoError(t, err)
+
+	for _, attachment := range attachments {
+		if filepath.Dir(filepath.Dir(attachment.Path)) == path {
+			return
+		}
+	}
+
+	require.Fail(t, "attachment was not moved")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// renameFailFs fails every rename, as a rename across filesystems does
+type renameFailFs struct {
+	afero.Fs
+}
+
+func (fs renameFailFs) Rename(_, _ string) error {
+	return errors.New("invalid cross-device link")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourseMove_Move(t *testing.T) {
+	t.Run("move", func(t *testing.T) {
+		m, ctx := setup(t)
+
+		course := createCourse(t, m, ctx, "/courses/Course 1")
+
+		stages := []Stage{}
+		result, err := m.Move(ctx, course.ID, "/archive/Course 1", ModeMove, func(p Progress) {
+			if len(stages) == 0 || stages[len(stages)-1] != p.Stage {
+				stages = append(stages, p.Stage)
+			}
+		})
+		require.NoError(t, err)
+		require.False(t, result.Copied)
+		require.Equal(t, 2, result.Verified)
+		require.Equal(t, []Stage{StageMoving, StageVerifying, StageUpdating, StageDone}, stages)
+
+		requireMoved(t, m, ctx, course.ID, "/archive/Course 1")
+
+		exists, err := afero.Exists(m.appFs.Fs, "/courses/Course 1")
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		m, ctx := setup(t)
+		m.appFs.Fs = renameFailFs{m.appFs.Fs}
+
+		course := createCourse(t, m, ctx, "/courses/Course 1")
+
+		modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+		require.NoError(t, m.appFs.Fs.Chtimes("/courses/Course 1/01 Intro/01 Welcome.mp4", modTime, modTime))
+
+		var copied Progress
+		result, err := m.Move(ctx, course.ID, "/other/Course 1", ModeMove, func(p Progress) {
+			if p.Stage == StageCopying {
+				copied = p
+			}
+		})
+		require.NoError(t, err)
+		require.True(t, result.Copied)
+		require.Equal(t, copied.Total, copied.Done)
+		require.NotZero(t, copied.Total)
+
+		requireMoved(t, m, ctx, course.ID, "/other/Course 1")
+
+		// The mod time is kept
+		info, err := m.appFs.Fs.Stat("/other/Course 1/01 Intro/01 Welcome.mp4")
+		require.NoError(t, err)
+		require.True(t, modTime.Equal(info.ModTime()))
+
+		// The original is removed
+		exists, err := afero.Exists(m.appFs.Fs, "/courses/Course 1")
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("relink", func(t *testing.T) {
+		m, ctx := setup(t)
+
+		course := createCourse(t, m, ctx, "/courses/Course 1")
+
+		// Moved outside of the app
+		require.NoError(t, m.appFs.Fs.MkdirAll("/new", os.ModePerm))
+		require.NoError(t, m.appFs.Fs.Rename("/courses/Course 1", "/new/Course 1"))
+
+		result, err := m.Move(ctx, course.ID, "/new/Course 1", ModeRelink, nil)
+		require.NoError(t, err)
+		require.Equal(t, 2, result.Verified)
+
+		requireMoved(t, m, ctx, course.ID, "/new/Course 1")
+	})
+
+	t.Run("on relocated", func(t *testing.T) {
+		m, ctx := setup(t)
+
+		course := createCourse(t, m, ctx, "/courses/Course 1")
+
+		assets, err := m.dao.ListAssets(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_COURSE_ID: course.ID}))
+		require.NoError(t, err)
+
+		var relocatedCourseID string
+		var relocatedAssetIDs []string
+		m.onRelocated = func(courseID string, assetIDs []string) {
+			relocatedCourseID = courseID
+			relocatedAssetIDs = assetIDs
+		}
+
+		// Not called when the move fails
+		require.NoError(t, afero.WriteFile(m.appFs.Fs, "/courses/Course 1/01 Intro/02 Setup.mp4", []byte("changed"), os.ModePerm))
+
+		_, err = m.Move(ctx, course.ID, "/archive/Course 1", ModeMove, nil)
+		require.ErrorIs(t, err, utils.ErrMoveHashMismatch)
+		require.Empty(t, relocatedCourseID)
+
+		require.NoError(t, afero.WriteFile(m.appFs.Fs, "/courses/Course 1/01 Intro/02 Setup.mp4", []byte("setup /courses/Course 1"), os.ModePerm))
+
+		_, err = m.Move(ctx, course.ID, "/archive/Course 1", ModeMove, nil)
+		require.NoError(t, err)
+		require.Equal(t, course.ID, relocatedCourseID)
+		require.ElementsMatch(t, utils.Map(assets, func(a *models.Asset) string { return a.ID }), relocatedAssetIDs)
+	})
+
+	t.Run("relink mismatch", func(t *testing.T) {
+		m, ctx := setup(t)
+
+		course := createCourse(t, m, ctx, "/courses/Course 1")
+
+		require.NoError(t, afero.WriteFile(m.appFs.Fs, "/new/Course 1/01 Intro/01 Welcome.mp4", []byte("other"), os.ModePerm))
+		require.NoError(t, afero.WriteFile(m.appFs.Fs, "/new/Course 1/01 Intro/02 Setup.mp4", []byte("other"), os.ModePerm))
+
+		_, err := m.Move(ctx, course.ID, "/new/Course 1", ModeRelink, nil)
+		require.ErrorIs(t, err, utils.ErrMoveHashMismatch)
+
+		record, err := m.dao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+		require.NoError(t, err)
+		require.Equal(t, "/courses/Course 1", record.Path)
+		require.False(t, record.Maintenance)
+	})
+
+	t.Run("move mismatch restores", func(t *testing.T) {
+		m, ctx := setup(t)
+
+		course := createCourse(t, m, ctx, "/courses/Course 1")
+
+		// The file changed since the last scan
+		require.NoError(t, afero.WriteFile(m.appFs.Fs, "/courses/Course 1/01 Intro/02 Setup.mp4", []byte("changed"), os.ModePerm))
+
+		_, err := m.Move(ctx, course.ID, "/archive/Course 1", ModeMove, nil)
+		require.ErrorIs(t, err, utils.ErrMoveHashMismatch)
+
+		exists, err := afero.Exists(m.appFs.Fs, "/courses/Course 1/01 Intro/01 Welcome.mp4")
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		exists, err = afero.Exists(m.appFs.Fs, "/archive/Course 1")
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		m, ctx := setup(t)
+
+		course := createCourse(t, m, ctx, "/courses/Course 1")
+		other := createCourse(t, m, ctx, "/courses/Course 2")
+
+		_, err := m.Move(ctx, course.ID, "/new", "copy", nil)
+		require.ErrorIs(t, err, utils.ErrMoveMode)
+
+		_, err = m.Move(ctx, "missing", "/new", ModeMove, nil)
+		require.ErrorIs(t, err, utils.ErrCourseNotFound)
+
+		_, err = m.Move(ctx, course.ID, "/courses/Course 1/", ModeMove, nil)
+		require.ErrorIs(t, err, utils.ErrMoveSamePath)
+
+		_, err = m.Move(ctx, course.ID, "/courses/Course 1/nested", ModeMove, nil)
+		require.ErrorIs(t, err, utils.ErrMoveIntoCourse)
+
+		_, err = m.Move(ctx, course.ID, other.Path, ModeMove, nil)
+		require.ErrorIs(t, err, utils.ErrMoveDestExists)
+
+		require.NoError(t, m.appFs.Fs.MkdirAll("/existing", os.ModePerm))
+		_, err = m.Move(ctx, course.ID, "/existing", ModeMove, nil)
+		require.ErrorIs(t, err, utils.ErrMoveDestExists)
+
+		_, err = m.Move(ctx, course.ID, "/missing", ModeRelink, nil)
+		require.ErrorIs(t, err, utils.ErrMoveDestMissing)
+
+		require.NoError(t, m.appFs.Fs.RemoveAll(other.Path))
+		_, err = m.Move(ctx, other.ID, "/new/Course 2", ModeMove, nil)
+		require.ErrorIs(t, err, utils.ErrMoveSourceMissing)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourseMove_MoveAll(t *testing.T) {
+	m, ctx := setup(t)
+
+	course1 := createCourse(t, m, ctx, "/courses/Course 1")
+	course2 := createCourse(t, m, ctx, "/courses/Course 2")
+
+	results := m.MoveAll(ctx, []string{course1.ID, "missing", course2.ID}, "/library", ModeMove, nil)
+	require.Len(t, results, 3)
+
+	require.NoError(t, results[0].Err)
+	require.Equal(t, "/library/Course 1", results[0].To)
+	requireMoved(t, m, ctx, course1.ID, "/library/Course 1")
+
+	require.ErrorIs(t, results[1].Err, utils.ErrCourseNotFound)
+
+	require.NoError(t, results[2].Err)
+	requireMoved(t, m, ctx, course2.ID, "/library/Course 2")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Masterminds/squirrel"
@@ -15,6 +16,7 @@ import (
 	"github.com/geerew/off-course/utils/cardcache"
 	"github.com/geerew/off-course/utils/logger"
 	"github.com/geerew/off-course/utils/media"
+	"github.com/geerew/off-course/utils/thumbnails"
 	"github.com/geerew/off-course/utils/types"
 )
 
@@ -27,18 +29,32 @@ type CourseScanProcessorFn func(context.Context, *CourseScan, *ScanState) error
 
 // CourseScan scans a course and finds assets and attachments
 type CourseScan struct {
-	appFs     *appfs.AppFs
-	db        database.Database
-	dao       *dao.DAO
-	logger    *logger.Logger
-	ffmpeg    *media.FFmpeg
-	cardCache cardcache.CardCacher
+	appFs      *appfs.AppFs
+	db         database.Database
+	dao        *dao.DAO
+	logger     *logger.Logger
+	ffmpeg     *media.FFmpeg
+	cardCache  cardcache.CardCacher
+	thumbnails thumbnails.Thumbnailer
 
 	// In-memory scan state storage
 	scans utils.CMap[string, *ScanState]
 
-	// addMutex protects the Add operation to prevent race conditions
+	// addMutex protects the Add and MoveScan operations to prevent race conditions
 	addMutex sync.Mutex
+
+	// sequence is the position given to the next scan added to the queue (protected by addMutex)
+	sequence int64
+
+	// Number of scans processed concurrently
+	workers int
+
+	// probeSlots limits the number of concurrent ffprobe and keyframe extractions across all
+	// running scans
+	probeSlots chan struct{}
+
+	// When paused, the worker does not start any new scans
+	paused atomic.Bool
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -46,6 +62,12 @@ type CourseScan struct {
 const (
 	// scanPollInterval is how often the worker polls for waiting scans
 	scanPollInterval = 1 * time.Second
+
+	// DefaultWorkers is the number of concurrent scans when not configured
+	DefaultWorkers = 1
+
+	// DefaultMaxProbes is the number of concurrent video probes when not configured
+	DefaultMaxProbes = 2
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -57,27 +79,59 @@ type CourseScanConfig struct {
 	Logger    *logger.Logger
 	FFmpeg    *media.FFmpeg
 	CardCache cardcache.CardCacher
+
+	// Thumbnails, when set, is told which video assets need thumbnails and which were removed
+	Thumbnails thumbnails.Thumbnailer
+
+	// Workers is the number of scans to process concurrently (defaults to 1)
+	Workers int
+
+	// MaxProbes is the number of concurrent ffprobe and keyframe extractions across all
+	// scans (defaults to 2)
+	MaxProbes int
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // New creates a new CourseScan
 func New(config *CourseScanConfig) *CourseScan {
+	workers := config.Workers
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+
+	maxProbes := config.MaxProbes
+	if maxProbes < 1 {
+		maxProbes = DefaultMaxProbes
+	}
+
 	return &CourseScan{
-		appFs:     config.AppFs,
-		db:        config.Db,
-		dao:       dao.New(config.Db),
-		logger:    config.Logger,
-		ffmpeg:    config.FFmpeg,
-		cardCache: config.CardCache,
-		scans:     utils.NewCMap[string, *ScanState](),
+		appFs:      config.AppFs,
+		db:         config.Db,
+		dao:        dao.New(config.Db),
+		logger:     config.Logger,
+		ffmpeg:     config.FFmpeg,
+		cardCache:  config.CardCache,
+		thumbnails: config.Thumbnails,
+		scans:      utils.NewCMap[string, *ScanState](),
+		workers:    workers,
+		probeSlots: make(chan struct{}, maxProbes),
 	}
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// Add creates a course scan job and adds it to the CMap
+// Add creates a course scan job with normal priority and adds it to the CMap
 func (s *CourseScan) Add(ctx context.Context, courseId string) (*ScanState, error) {
+	return s.AddWithPriority(ctx, courseId, ScanPriorityNormal)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AddWithPriority creates a course scan job and adds it to the CMap. Waiting scans with a
+// higher priority are processed first. When a scan for the course is already waiting, its
+// priority is raised (never lowered) to the given priority
+func (s *CourseScan) AddWithPriority(ctx context.Context, courseId string, priority ScanPriority) (*ScanState, error) {
 	// Look up the course to get path and title
 	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseId})
 	course, err := s.dao.GetCourse(ctx, dbOpts)
@@ -95,6 +149,11 @@ func (s *CourseScan) Add(ctx context.Context, courseId string) (*ScanState, erro
 	// Check if the scan job already exists (atomic check within lock)
 	existingScan := s.GetScanByCourseID(courseId)
 	if existingScan != nil {
+		existingPriority, position := existingScan.queuePosition()
+		if priority > existingPriority && existingScan.GetStatus().IsWaiting() {
+			existingScan.setQueuePosition(priority, position)
+		}
+
 		s.logger.Debug().
 			Str("course_id", courseId).
 			Str("course_path", course.Path).
@@ -106,12 +165,15 @@ func (s *CourseScan) Add(ctx context.Context, courseId string) (*ScanState, erro
 
 	// Create and add the new scan
 	scanState := NewScanState(courseId, course.Path, course.Title)
+	scanState.setQueuePosition(priority, s.sequence)
+	s.sequence++
 	s.scans.Set(scanState.ID, scanState)
 
 	s.logger.Info().
 		Str("course_id", courseId).
 		Str("course_path", course.Path).
 		Str("scan_id", scanState.ID).
+		Str("priority", priority.String()).
 		Msg("Added scan job")
 
 	return scanState, nil
@@ -196,124 +258,177 @@ func (s *CourseScan) CancelAndRemoveScansByCourseID(courseID string) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// Worker polls the CMap for waiting scans and processes them sequentially
+// Worker polls the CMap for waiting scans and processes them, running up to the configured
+// number of scans concurrently. No new scans are started while the queue is paused
 func (s *CourseScan) Worker(ctx context.Context, processorFn CourseScanProcessorFn) {
-	s.logger.Debug().Msg("Started course scanner worker")
+	s.logger.Debug().Int("workers", s.workers).Msg("Started course scanner worker")
 
 	ticker := time.NewTicker(scanPollInterval)
 	defer ticker.Stop()
 
+	// slots limits the number of running scans and finished signals when a scan completes, so
+	// the next waiting scan starts without waiting for the ticker
+	slots := make(chan struct{}, s.workers)
+	finished := make(chan struct{}, s.workers)
+
+	var wg sync.WaitGroup
+
 	for {
 		select {
 		case <-ctx.Done():
+			wg.Wait()
 			s.logger.Debug().Msg("Course scanner worker stopped")
 			return
 		case <-ticker.C:
-			var waitingScans []*ScanState
-			s.scans.Range(func(scanID string, scanState *ScanState) bool {
-				if scanState.GetStatus() == types.ScanStatusWaiting {
-					waitingScans = append(waitingScans, scanState)
-				}
-
-				return true
-			})
-
-			sortScans(waitingScans)
-
-			for _, scanState := range waitingScans {
-				// Check if context is cancelled before processing each scan
-				select {
-				case <-ctx.Done():
-					s.logger.Debug().Msg("Course scanner worker stopped during scan processing")
-					return
-				default:
-				}
-
-				existingScan, exists := s.scans.Get(scanState.ID)
-				if !exists {
-					s.logger.Debug().
-						Str("course_id", scanState.CourseID).
-						Str("scan_id", scanState.ID).
-						Msg("Skipping scan that was removed")
-					continue
-				}
-
-				if existingScan.GetStatus() != types.ScanStatusWaiting {
-					s.logger.Debug().
-						Str("course_id", scanState.CourseID).
-						Str("scan_id", scanState.ID).
-						Str("status", string(existingScan.GetStatus())).
-						Msg("Skipping scan that is no longer waiting")
-					continue
-				}
-
-				if existingScan.IsCancelled() {
-					s.logger.Debug().
-						Str("course_id", scanState.CourseID).
-						Str("scan_id", scanState.ID).
-						Msg("Skipping cancelled scan")
-					s.scans.Remove(scanState.ID)
-					continue
-				}
-
-				scanCtx, cancel := context.WithCancel(ctx)
-				defer cancel()
-				existingScan.SetCancel(cancel)
-
-				if finalCheck, stillExists := s.scans.Get(scanState.ID); !stillExists {
-					cancel()
-
-					s.logger.Debug().
-						Str("course_id", scanState.CourseID).
-						Str("scan_id", scanState.ID).
-						Msg("Skipping scan that was removed")
-
-					continue
-				} else if finalCheck.IsCancelled() || finalCheck.GetStatus() != types.ScanStatusWaiting {
-					cancel()
-
-					s.logger.Debug().
-						Str("course_id", scanState.CourseID).
-						Str("scan_id", scanState.ID).
-						Msg("Skipping scan that is cancelled or no longer waiting")
-
-					s.scans.Remove(scanState.ID)
-					continue
-				}
-
-				s.logger.Info().
-					Str("course_id", scanState.CourseID).
-					Str("course_path", scanState.CoursePath).
-					Str("scan_id", scanState.ID).
-					Msg("Processing scan job")
-
-				err := processorFn(scanCtx, s, existingScan)
-				if err != nil {
-					if err == context.Canceled || err == context.DeadlineExceeded {
-						s.logger.Info().
-							Str("course_id", scanState.CourseID).
-							Str("course_path", scanState.CoursePath).
-							Str("scan_id", scanState.ID).
-							Msg("Scan job cancelled")
-					} else {
-						s.logger.Error().
-							Err(err).
-							Str("course_id", scanState.CourseID).
-							Str("course_path", scanState.CoursePath).
-							Str("scan_id", scanState.ID).
-							Msg("Failed to process scan job")
-					}
-				}
+		case <-finished:
+		}
 
-				s.scans.Remove(scanState.ID)
+		if s.IsPaused() {
+			continue
+		}
+
+		for _, scanState := range s.waitingScans() {
+			if ctx.Err() != nil || s.IsPaused() {
+				break
 			}
+
+			// Stop when every worker is busy
+			acquired := false
+			select {
+			case slots <- struct{}{}:
+				acquired = true
+			default:
+			}
+
+			if !acquired {
+				break
+			}
+
+			scanCtx, cancel, ok := s.claimScan(ctx, scanState)
+			if !ok {
+				<-slots
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() {
+					<-slots
+
+					select {
+					case finished <- struct{}{}:
+					default:
+					}
+				}()
+				defer cancel()
+
+				s.runScan(scanCtx, processorFn, scanState)
+			}()
 		}
 	}
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// sortScans sorts scans by status (processing first) then by createdAt (oldest first), then by ID
+// claimScan checks the scan is still waiting and marks it as processing so it is not picked
+// up again. It returns the context the scan should run with
+func (s *CourseScan) claimScan(ctx context.Context, scanState *ScanState) (context.Context, context.CancelFunc, bool) {
+	existingScan, exists := s.scans.Get(scanState.ID)
+	if !exists {
+		s.logger.Debug().
+			Str("course_id", scanState.CourseID).
+			Str("scan_id", scanState.ID).
+			Msg("Skipping scan that was removed")
+		return nil, nil, false
+	}
+
+	if existingScan.GetStatus() != types.ScanStatusWaiting {
+		s.logger.Debug().
+			Str("course_id", scanState.CourseID).
+			Str("scan_id", scanState.ID).
+			Str("status", string(existingScan.GetStatus())).
+			Msg("Skipping scan that is no longer waiting")
+		return nil, nil, false
+	}
+
+	if existingScan.IsCancelled() {
+		s.logger.Debug().
+			Str("course_id", scanState.CourseID).
+			Str("scan_id", scanState.ID).
+			Msg("Skipping cancelled scan")
+		s.scans.Remove(scanState.ID)
+		return nil, nil, false
+	}
+
+	scanCtx, cancel := context.WithCancel(ctx)
+	existingScan.SetCancel(cancel)
+
+	if finalCheck, stillExists := s.scans.Get(scanState.ID); !stillExists {
+		cancel()
+
+		s.logger.Debug().
+			Str("course_id", scanState.CourseID).
+			Str("scan_id", scanState.ID).
+			Msg("Skipping scan that was removed")
+
+		return nil, nil, false
+	} else if finalCheck.IsCancelled() || finalCheck.GetStatus() != types.ScanStatusWaiting {
+		cancel()
+
+		s.logger.Debug().
+			Str("course_id", scanState.CourseID).
+			Str("scan_id", scanState.ID).
+			Msg("Skipping scan that is cancelled or no longer waiting")
+
+		s.scans.Remove(scanState.ID)
+		return nil, nil, false
+	}
+
+	existingScan.UpdateStatusAndMessage(types.ScanStatusProcessing, "Starting scan")
+
+	return scanCtx, cancel, true
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// runScan processes a claimed scan, records it in the scan history and removes it from the
+// CMap
+func (s *CourseScan) runScan(ctx context.Context, processorFn CourseScanProcessorFn, scanState *ScanState) {
+	s.logger.Info().
+		Str("course_id", scanState.CourseID).
+		Str("course_path", scanState.CoursePath).
+		Str("scan_id", scanState.ID).
+		Msg("Processing scan job")
+
+	startedAt := time.Now()
+	err := processorFn(ctx, s, scanState)
+	s.recordHistory(ctx, scanState, startedAt, err)
+
+	if err != nil {
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			s.logger.Info().
+				Str("course_id", scanState.CourseID).
+				Str("course_path", scanState.CoursePath).
+				Str("scan_id", scanState.ID).
+				Msg("Scan job cancelled")
+		} else {
+			s.logger.Error().
+				Err(err).
+				Str("course_id", scanState.CourseID).
+				Str("course_path", scanState.CoursePath).
+				Str("scan_id", scanState.ID).
+				Msg("Failed to process scan job")
+		}
+	}
+
+	s.scans.Remove(scanState.ID)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// sortScans sorts scans by status (processing first), then by priority (highest first), then
+// by queue position, then by ID
 func sortScans(scans []*ScanState) {
 	sort.Slice(scans, func(i, j int) bool {
 		iStatus := scans[i].GetStatus()
@@ -330,15 +445,20 @@ func sortScans(scans []*ScanState) {
 			return false // j comes first
 		}
 
-		// Same status - sort by createdAt (oldest first)
-		if scans[i].CreatedAt.Before(scans[j].CreatedAt) {
-			return true
+		// Higher priority comes first
+		iPriority, iPosition := scans[i].queuePosition()
+		jPriority, jPosition := scans[j].queuePosition()
+
+		if iPriority != jPriority {
+			return iPriority > jPriority
 		}
-		if scans[j].CreatedAt.Before(scans[i].CreatedAt) {
-			return false
+
+		// Same priority - sort by queue position (oldest first)
+		if iPosition != jPosition {
+			return iPosition < jPosition
 		}
 
-		// Same status and createdAt - use ID as tiebreaker for deterministic ordering
+		// Same position - use ID as tiebreaker for deterministic ordering
 		return scans[i].ID < scans[j].ID
 	})
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/geerew/off-course/utils/cardcache"
 	"github.com/geerew/off-course/utils/logger"
 	"github.com/geerew/off-course/utils/media"
+	"github.com/geerew/off-course/utils/thumbnails"
 	"github.com/geerew/off-course/utils/types"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
@@ -55,12 +56,23 @@ func setup(t *testing.T) (*CourseScan, context.Context) {
 	})
 	require.NoError(t, err)
 
-	courseScan := New(&CourseScanConfig{
+	// Create Thumbnails for testing (worker not started)
+	thumbs, err := thumbnails.New(&thumbnails.ThumbnailsConfig{
+		CachePath: "./oc_data",
 		Db:        dbManager.DataDb,
 		AppFs:     appFs,
-		Logger:    testLogger.WithCourseScan(),
+		Logger:    testLogger.WithThumbnails(),
 		FFmpeg:    ffmpeg,
-		CardCache: cardCache,
+	})
+	require.NoError(t, err)
+
+	courseScan := New(&CourseScanConfig{
+		Db:         dbManager.DataDb,
+		AppFs:      appFs,
+		Logger:     testLogger.WithCourseScan(),
+		FFmpeg:     ffmpeg,
+		CardCache:  cardCache,
+		Thumbnails: thumbs,
 	})
 
 	// Create a user for the context
@@ -236,4 +248,104 @@ func TestScanner_Worker(t *testing.T) {
 			return len(scanner.GetAllScans()) == 0
 		}, 2*time.Second, 50*time.Millisecond, "Scan should be processed and removed even on error")
 	})
+	t.Run("concurrent", func(t *testing.T) {
+		scanner, ctx := setup(t)
+		scanner.workers = 2
+
+		for i := range 4 {
+			course := &models.Course{Title: fmt.Sprintf("course %d", i), Path: fmt.Sprintf("/course-%d", i)}
+			require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+			_, err := scanner.Add(ctx, course.ID)
+			require.NoError(t, err)
+		}
+
+		var mu sync.Mutex
+		running, maxRunning := 0, 0
+
+		go scanner.Worker(ctx, func(context.Context, *CourseScan, *ScanState) error {
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+
+			time.Sleep(200 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+
+		require.Eventually(t, func() bool {
+			return len(scanner.GetAllScans()) == 0
+		}, 3*time.Second, 50*time.Millisecond, "Scans should be processed and removed")
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Equal(t, 2, maxRunning)
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		courses := []*models.Course{}
+		for i := range 3 {
+			course := &models.Course{Title: fmt.Sprintf("course %d", i), Path: fmt.Sprintf("/course-%d", i)}
+			require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+			courses = append(courses, course)
+		}
+
+		_, err := scanner.AddWithPriority(ctx, courses[0].ID, ScanPriorityLow)
+		require.NoError(t, err)
+		_, err = scanner.Add(ctx, courses[1].ID)
+		require.NoError(t, err)
+		_, err = scanner.AddWithPriority(ctx, courses[2].ID, ScanPriorityHigh)
+		require.NoError(t, err)
+
+		var mu sync.Mutex
+		processed := []string{}
+
+		go scanner.Worker(ctx, func(_ context.Context, _ *CourseScan, scanState *ScanState) error {
+			mu.Lock()
+			defer mu.Unlock()
+			processed = append(processed, scanState.CourseID)
+			return nil
+		})
+
+		require.Eventually(t, func() bool {
+			return len(scanner.GetAllScans()) == 0
+		}, 3*time.Second, 50*time.Millisecond, "Scans should be processed and removed")
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Equal(t, []string{courses[2].ID, courses[1].ID, courses[0].ID}, processed)
+	})
+
+	t.Run("paused", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		scanner.Pause()
+
+		go scanner.Worker(ctx, func(context.Context, *CourseScan, *ScanState) error {
+			return nil
+		})
+
+		scan, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+
+		// The scan stays in the queue while paused
+		time.Sleep(1500 * time.Millisecond)
+		require.Len(t, scanner.GetAllScans(), 1)
+		require.True(t, scan.GetStatus().IsWaiting())
+
+		scanner.Resume()
+
+		require.Eventually(t, func() bool {
+			return len(scanner.GetAllScans()) == 0
+		}, 2*time.Second, 50*time.Millisecond, "Scan should be processed once resumed")
+	})
 }
@@ -0,0 +1,77 @@
+package coursescan
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/coursemetadata"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// applyCourseDetails sets the course details from course.json and the sidecar files. Values in
+// course.json take precedence over the sidecar files. The course title is only changed when
+// course.json has one. Returns true when the course was changed
+func applyCourseDetails(course *models.Course, metadata *coursemetadata.CourseMetadata, sidecars *coursemetadata.Sidecars) bool {
+	if metadata == nil {
+		metadata = &coursemetadata.CourseMetadata{}
+	}
+
+	if sidecars == nil {
+		sidecars = &coursemetadata.Sidecars{}
+	}
+
+	title := course.Title
+	if metadata.Title != "" {
+		title = metadata.Title
+	}
+
+	description := metadata.Description
+	if description == "" {
+		description = sidecars.Description
+	}
+
+	authors := models.CourseAuthors(metadata.Authors)
+	if len(authors) == 0 {
+		authors = sidecars.Authors
+	}
+
+	modules := models.CourseModules{}
+	for _, module := range metadata.Modules {
+		if module.Name == "" {
+			continue
+		}
+
+		modules = append(modules, models.CourseModule{
+			Name:  module.Name,
+			Title: module.Title,
+			Order: module.Order,
+		})
+	}
+
+	changed := course.Title != title ||
+		course.Description != description ||
+		!slices.Equal(course.Authors, authors) ||
+		course.Language != metadata.Language ||
+		course.Difficulty != metadata.Difficulty ||
+		course.ReleaseDate != metadata.ReleaseDate ||
+		course.SourceURL != metadata.SourceURL ||
+		len(course.Modules) != len(modules) ||
+		(len(modules) > 0 && !reflect.DeepEqual(course.Modules, modules))
+
+	if !changed {
+		return false
+	}
+
+	course.Title = title
+	course.Description = description
+	course.Authors = authors
+	course.Language = metadata.Language
+	course.Difficulty = metadata.Difficulty
+	course.ReleaseDate = metadata.ReleaseDate
+	course.SourceURL = metadata.SourceURL
+	course.Modules = modules
+
+	return true
+}
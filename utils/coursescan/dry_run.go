@@ -0,0 +1,376 @@
+package coursescan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/coursemetadata"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DryRunPlan describes the changes a scan would make to a course, grouped by lesson
+type DryRunPlan struct {
+	CourseID   string `json:"courseId"`
+	CoursePath string `json:"coursePath"`
+
+	// The card found on disk and whether it differs from the current card
+	CardPath    string `json:"cardPath"`
+	CardChanged bool   `json:"cardChanged"`
+
+	// Lessons that would be created, updated or deleted, or that have changed assets or
+	// attachments. Unchanged lessons are omitted
+	Lessons []*DryRunLesson `json:"lessons"`
+
+	Summary DryRunSummary `json:"summary"`
+
+	// How each file found on disk was parsed, and the rule that parsed it
+	Files []*ClassifiedFile `json:"files"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DryRunLesson describes the change to a lesson along with the changes to its assets and
+// attachments
+type DryRunLesson struct {
+	Op OpType `json:"op"`
+
+	// The ID of the existing lesson. Empty when the lesson would be created
+	ID string `json:"id,omitempty"`
+
+	Module        string `json:"module"`
+	Prefix        int    `json:"prefix"`
+	Title         string `json:"title"`
+	PreviousTitle string `json:"previousTitle,omitempty"`
+
+	Assets      []*DryRunAsset      `json:"assets"`
+	Attachments []*DryRunAttachment `json:"attachments"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DryRunAsset describes the change to an asset
+type DryRunAsset struct {
+	Op OpType `json:"op"`
+
+	// The ID of the existing asset the op is based on. Empty when the asset would be created
+	ID string `json:"id,omitempty"`
+
+	// For an overwrite, the ID of the existing asset that would be removed to make way for the
+	// renamed asset
+	ReplacesID string `json:"replacesId,omitempty"`
+
+	Title        string `json:"title"`
+	Path         string `json:"path"`
+	PreviousPath string `json:"previousPath,omitempty"`
+
+	// Whether progress for an existing asset would be lost
+	LosesProgress bool `json:"losesProgress"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DryRunAttachment describes the change to an attachment
+type DryRunAttachment struct {
+	Op OpType `json:"op"`
+
+	// The ID of the existing attachment. Empty when the attachment would be created
+	ID string `json:"id,omitempty"`
+
+	Title string `json:"title"`
+	Path  string `json:"path"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DryRunSummary counts the changes in a dry-run plan
+type DryRunSummary struct {
+	LessonsCreated     int `json:"lessonsCreated"`
+	LessonsUpdated     int `json:"lessonsUpdated"`
+	LessonsDeleted     int `json:"lessonsDeleted"`
+	AssetsCreated      int `json:"assetsCreated"`
+	AssetsUpdated      int `json:"assetsUpdated"`
+	AssetsDeleted      int `json:"assetsDeleted"`
+	AttachmentsCreated int `json:"attachmentsCreated"`
+	AttachmentsDeleted int `json:"attachmentsDeleted"`
+
+	// The number of existing assets that would lose progress
+	ProgressLost int `json:"progressLost"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DryRun scans a course and reconciles it against the database in the same way as the
+// Processor, but returns the resulting operations rather than applying them. The database,
+// card cache and course maintenance flag are not touched and videos are not probed
+//
+// As with a real scan, course.json or a sidecar file that cannot be read fails the dry run with
+// ErrCourseMetadata
+func (s *CourseScan) DryRun(ctx context.Context, courseID string) (*DryRunPlan, error) {
+	course, err := fetchCourse(ctx, s, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	if course == nil {
+		return nil, utils.ErrCourseNotFound
+	}
+
+	if _, err := s.appFs.Fs.Stat(course.Path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrCourseUnavailable
+		}
+
+		return nil, fmt.Errorf("failed to access course path %s: %w", course.Path, err)
+	}
+
+	s.logger.Debug().
+		Str("course_id", course.ID).
+		Str("course_path", course.Path).
+		Msg("Starting dry-run scan for course")
+
+	metadata, err := coursemetadata.ReadMetadata(s.appFs.Fs, course.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrCourseMetadata, coursemetadata.MetadataFileName, err)
+	}
+
+	if _, err := coursemetadata.ReadSidecars(s.appFs.Fs, course.Path); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCourseMetadata, err)
+	}
+
+	scanned, err := scanFiles(s, course, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	scannedAttachments, scannedAssets := flatAttachmentsAndAssets(scanned.lessons)
+
+	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_COURSE_ID: course.ID})
+	existingLessons, err := s.dao.ListLessons(ctx, dbOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	existingAttachments, existingAssets := flatAttachmentsAndAssets(existingLessons)
+
+	// The scan state is only used to report hashing progress and is never added to the queue
+	scanState := NewScanState(course.ID, course.Path, course.Title)
+	if err := populateHashesIfChanged(ctx, s, scannedAssets, existingAssets, course, scanState); err != nil {
+		return nil, err
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	lessonOps := reconcileLessons(scanned.lessons, existingLessons)
+	assetOps := reconcileAssets(scannedAssets, existingAssets)
+	attachmentOps := reconcileAttachments(scannedAttachments, existingAttachments)
+
+	plan := buildDryRunPlan(lessonOps, assetOps, attachmentOps, scanned.lessons, existingLessons)
+	plan.CourseID = course.ID
+	plan.CoursePath = course.Path
+	plan.CardPath = scanned.cardPath
+	plan.CardChanged = course.CardPath != scanned.cardPath
+	plan.Files = scanned.files
+
+	return plan, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// buildDryRunPlan groups the reconcile operations by lesson. Assets are matched to a lesson
+// by module and prefix, while attachments are matched by the lesson they were found in
+func buildDryRunPlan(lessonOps, assetOps, attachmentOps []Op, scannedLessons, existingLessons []*models.Lesson) *DryRunPlan {
+	lessons := map[string]*DryRunLesson{}
+
+	lessonFor := func(module string, prefix int, title string) *DryRunLesson {
+		key := fmt.Sprintf("%s:%d", module, prefix)
+		if l, ok := lessons[key]; ok {
+			return l
+		}
+
+		l := &DryRunLesson{
+			Op:          NoOp,
+			Module:      module,
+			Prefix:      prefix,
+			Title:       title,
+			Assets:      []*DryRunAsset{},
+			Attachments: []*DryRunAttachment{},
+		}
+		lessons[key] = l
+
+		return l
+	}
+
+	assetLesson := func(a *models.Asset) *DryRunLesson {
+		return lessonFor(a.Module, int(a.Prefix.Int16), "")
+	}
+
+	// Lessons
+	for _, op := range lessonOps {
+		switch v := op.(type) {
+		case NoLessonOp:
+			l := lessonFor(v.Existing.Module, int(v.Existing.Prefix.Int16), v.Existing.Title)
+			l.ID = v.Existing.ID
+		case CreateLessonOp:
+			l := lessonFor(v.New.Module, int(v.New.Prefix.Int16), v.New.Title)
+			l.Op = CreateOp
+		case UpdateLessonOp:
+			l := lessonFor(v.Existing.Module, int(v.Existing.Prefix.Int16), v.New.Title)
+			l.Op = UpdateOp
+			l.ID = v.Existing.ID
+			l.PreviousTitle = v.Existing.Title
+		case DeleteLessonOp:
+			l := lessonFor(v.Deleted.Module, int(v.Deleted.Prefix.Int16), v.Deleted.Title)
+			l.Op = DeleteOp
+			l.ID = v.Deleted.ID
+		}
+	}
+
+	summary := DryRunSummary{}
+
+	// Assets
+	for _, op := range assetOps {
+		switch v := op.(type) {
+		case CreateAssetOp:
+			l := assetLesson(v.New)
+			l.Assets = append(l.Assets, &DryRunAsset{Op: CreateOp, Title: v.New.Title, Path: v.New.Path})
+		case UpdateAssetOp:
+			l := assetLesson(v.New)
+			l.Assets = append(l.Assets, &DryRunAsset{
+				Op:           UpdateOp,
+				ID:           v.Existing.ID,
+				Title:        v.New.Title,
+				Path:         v.New.Path,
+				PreviousPath: v.Existing.Path,
+			})
+		case ReplaceAssetOp:
+			l := assetLesson(v.New)
+			l.Assets = append(l.Assets, &DryRunAsset{
+				Op:            ReplaceOp,
+				ID:            v.Existing.ID,
+				Title:         v.New.Title,
+				Path:          v.New.Path,
+				LosesProgress: true,
+			})
+		case OverwriteAssetOp:
+			l := assetLesson(v.Renamed)
+			l.Assets = append(l.Assets, &DryRunAsset{
+				Op:            OverwriteOp,
+				ID:            v.Existing.ID,
+				ReplacesID:    v.Deleted.ID,
+				Title:         v.Renamed.Title,
+				Path:          v.Renamed.Path,
+				PreviousPath:  v.Existing.Path,
+				LosesProgress: true,
+			})
+		case SwapAssetOp:
+			for _, pair := range [][2]*models.Asset{{v.ExistingA, v.NewA}, {v.ExistingB, v.NewB}} {
+				existing, renamed := pair[0], pair[1]
+				l := assetLesson(renamed)
+				l.Assets = append(l.Assets, &DryRunAsset{
+					Op:            SwapOp,
+					ID:            existing.ID,
+					Title:         renamed.Title,
+					Path:          renamed.Path,
+					PreviousPath:  existing.Path,
+					LosesProgress: true,
+				})
+			}
+		case DeleteAssetOp:
+			l := assetLesson(v.Deleted)
+			l.Assets = append(l.Assets, &DryRunAsset{
+				Op:            DeleteOp,
+				ID:            v.Deleted.ID,
+				Title:         v.Deleted.Title,
+				Path:          v.Deleted.Path,
+				LosesProgress: true,
+			})
+		}
+	}
+
+	// Attachments are matched to the lesson (scanned or existing) they belong to
+	attachmentLessons := map[*models.Attachment]*models.Lesson{}
+	for _, lessonList := range [][]*models.Lesson{scannedLessons, existingLessons} {
+		for _, lesson := range lessonList {
+			for _, attachment := range lesson.Attachments {
+				attachmentLessons[attachment] = lesson
+			}
+		}
+	}
+
+	for _, op := range attachmentOps {
+		var attachment *models.Attachment
+		entry := &DryRunAttachment{}
+
+		switch v := op.(type) {
+		case CreateAttachmentOp:
+			attachment = v.New
+			entry.Op = CreateOp
+		case DeleteAttachmentOp:
+			attachment = v.Deleted
+			entry.Op = DeleteOp
+			entry.ID = v.Deleted.ID
+		default:
+			continue
+		}
+
+		entry.Title = attachment.Title
+		entry.Path = attachment.Path
+
+		lesson := attachmentLessons[attachment]
+		if lesson == nil {
+			continue
+		}
+
+		l := lessonFor(lesson.Module, int(lesson.Prefix.Int16), lesson.Title)
+		l.Attachments = append(l.Attachments, entry)
+	}
+
+	// Drop unchanged lessons and sort what remains
+	plan := &DryRunPlan{Lessons: []*DryRunLesson{}}
+	for _, l := range lessons {
+		if l.Op == NoOp && len(l.Assets) == 0 && len(l.Attachments) == 0 {
+			continue
+		}
+
+		sort.Slice(l.Assets, func(i, j int) bool { return l.Assets[i].Path < l.Assets[j].Path })
+		sort.Slice(l.Attachments, func(i, j int) bool { return l.Attachments[i].Path < l.Attachments[j].Path })
+
+		for _, a := range l.Assets {
+			if a.LosesProgress {
+				summary.ProgressLost++
+			}
+		}
+
+		plan.Lessons = append(plan.Lessons, l)
+	}
+
+	sort.Slice(plan.Lessons, func(i, j int) bool {
+		if plan.Lessons[i].Module != plan.Lessons[j].Module {
+			return plan.Lessons[i].Module < plan.Lessons[j].Module
+		}
+		return plan.Lessons[i].Prefix < plan.Lessons[j].Prefix
+	})
+
+	counts := countOperations(lessonOps, assetOps, attachmentOps)
+	summary.LessonsCreated = counts.LessonsCreated
+	summary.LessonsUpdated = counts.LessonsUpdated
+	summary.LessonsDeleted = counts.LessonsDeleted
+	summary.AssetsCreated = counts.AssetsCreated
+	summary.AssetsUpdated = counts.AssetsUpdated
+	summary.AssetsDeleted = counts.AssetsDeleted
+	summary.AttachmentsCreated = counts.AttachmentsCreated
+	summary.AttachmentsDeleted = counts.AttachmentsDeleted
+
+	plan.Summary = summary
+
+	return plan
+}
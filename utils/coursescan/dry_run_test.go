@@ -0,0 +1,225 @@
+package coursescan
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/coursemetadata"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_DryRun(t *testing.T) {
+	t.Run("course not found", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		plan, err := scanner.DryRun(ctx, "1234")
+		require.ErrorIs(t, err, utils.ErrCourseNotFound)
+		require.Nil(t, plan)
+	})
+
+	t.Run("course unavailable", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		plan, err := scanner.DryRun(ctx, course.ID)
+		require.ErrorIs(t, err, ErrCourseUnavailable)
+		require.Nil(t, plan)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		scanner.appFs.Fs.Mkdir(course.Path, os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/01 file 1.mkv", course.Path), []byte("hash 1"), os.ModePerm)
+
+		scanState, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		plan, err := scanner.DryRun(ctx, course.ID)
+		require.NoError(t, err)
+		require.Empty(t, plan.Lessons)
+		require.False(t, plan.CardChanged)
+		require.Equal(t, DryRunSummary{}, plan.Summary)
+	})
+
+	t.Run("ops", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		scanner.appFs.Fs.Mkdir(course.Path, os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/01 file 1.mkv", course.Path), []byte("hash 1"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/02 file 2.pdf", course.Path), []byte("hash 2"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/03 file 3.pdf", course.Path), []byte("hash 3"), os.ModePerm)
+
+		scanState, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		dbOpts := dao.NewOptions().
+			WithWhere(squirrel.Eq{models.LESSON_TABLE_COURSE_ID: course.ID}).
+			WithOrderBy(models.LESSON_TABLE_MODULE+" asc", models.LESSON_TABLE_PREFIX+" asc")
+
+		lessons, err := scanner.dao.ListLessons(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Len(t, lessons, 3)
+
+		// Replace file 1, rename file 2, delete file 3, create file 4 and an attachment
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/01 file 1.mkv", course.Path), []byte("new hash 1"), os.ModePerm)
+		require.NoError(t, scanner.appFs.Fs.Rename(fmt.Sprintf("%s/02 file 2.pdf", course.Path), fmt.Sprintf("%s/02 file two.pdf", course.Path)))
+		require.NoError(t, scanner.appFs.Fs.Remove(fmt.Sprintf("%s/03 file 3.pdf", course.Path)))
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/04 file 4.pdf", course.Path), []byte("hash 4"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/01 attachment 1.url", course.Path), []byte("attachment 1"), os.ModePerm)
+
+		plan, err := scanner.DryRun(ctx, course.ID)
+		require.NoError(t, err)
+		require.Equal(t, course.ID, plan.CourseID)
+		require.Len(t, plan.Lessons, 4)
+
+		// Lesson 1: replaced asset and a new attachment
+		require.Equal(t, NoOp, plan.Lessons[0].Op)
+		require.Equal(t, lessons[0].ID, plan.Lessons[0].ID)
+		require.Len(t, plan.Lessons[0].Assets, 1)
+		require.Equal(t, ReplaceOp, plan.Lessons[0].Assets[0].Op)
+		require.Equal(t, lessons[0].Assets[0].ID, plan.Lessons[0].Assets[0].ID)
+		require.True(t, plan.Lessons[0].Assets[0].LosesProgress)
+		require.Len(t, plan.Lessons[0].Attachments, 1)
+		require.Equal(t, CreateOp, plan.Lessons[0].Attachments[0].Op)
+		require.Equal(t, fmt.Sprintf("%s/01 attachment 1.url", course.Path), plan.Lessons[0].Attachments[0].Path)
+
+		// Lesson 2: renamed asset
+		require.Equal(t, UpdateOp, plan.Lessons[1].Op)
+		require.Equal(t, "file two", plan.Lessons[1].Title)
+		require.Equal(t, "file 2", plan.Lessons[1].PreviousTitle)
+		require.Len(t, plan.Lessons[1].Assets, 1)
+		require.Equal(t, UpdateOp, plan.Lessons[1].Assets[0].Op)
+		require.Equal(t, fmt.Sprintf("%s/02 file two.pdf", course.Path), plan.Lessons[1].Assets[0].Path)
+		require.Equal(t, fmt.Sprintf("%s/02 file 2.pdf", course.Path), plan.Lessons[1].Assets[0].PreviousPath)
+		require.False(t, plan.Lessons[1].Assets[0].LosesProgress)
+
+		// Lesson 3: deleted
+		require.Equal(t, DeleteOp, plan.Lessons[2].Op)
+		require.Equal(t, lessons[2].ID, plan.Lessons[2].ID)
+		require.Len(t, plan.Lessons[2].Assets, 1)
+		require.Equal(t, DeleteOp, plan.Lessons[2].Assets[0].Op)
+		require.True(t, plan.Lessons[2].Assets[0].LosesProgress)
+
+		// Lesson 4: created
+		require.Equal(t, CreateOp, plan.Lessons[3].Op)
+		require.Empty(t, plan.Lessons[3].ID)
+		require.Len(t, plan.Lessons[3].Assets, 1)
+		require.Equal(t, CreateOp, plan.Lessons[3].Assets[0].Op)
+
+		require.Equal(t, DryRunSummary{
+			LessonsCreated:     1,
+			LessonsUpdated:     1,
+			LessonsDeleted:     1,
+			AssetsCreated:      1,
+			AssetsUpdated:      2,
+			AssetsDeleted:      1,
+			AttachmentsCreated: 1,
+			ProgressLost:       2,
+		}, plan.Summary)
+
+		// Nothing was applied
+		after, err := scanner.dao.ListLessons(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Len(t, after, 3)
+		require.Equal(t, lessons[0].Assets[0].Hash, after[0].Assets[0].Hash)
+		require.Equal(t, fmt.Sprintf("%s/02 file 2.pdf", course.Path), after[1].Assets[0].Path)
+		require.Empty(t, after[0].Attachments)
+
+		record, err := scanner.dao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+		require.NoError(t, err)
+		require.False(t, record.Maintenance)
+	})
+	t.Run("classified files", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		scanner.appFs.Fs.Mkdir(course.Path, os.ModePerm)
+		require.NoError(t, coursemetadata.WriteMetadata(scanner.appFs.Fs, course.Path, &coursemetadata.CourseMetadata{
+			FilenameRules: []coursemetadata.FilenameRule{
+				{Name: "lessons", Type: coursemetadata.FilenameRuleRegex, Pattern: `^Lesson (?P<Prefix>\d+) - (?P<Title>.+)$`},
+			},
+		}))
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/01 file 1.mkv", course.Path), []byte("hash 1"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/Lesson 2 - file 2.mkv", course.Path), []byte("hash 2"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/03 notes.zip", course.Path), []byte("hash 3"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/random.mkv", course.Path), []byte("hash 4"), os.ModePerm)
+
+		plan, err := scanner.DryRun(ctx, course.ID)
+		require.NoError(t, err)
+		require.Len(t, plan.Files, 4)
+
+		require.Equal(t, fmt.Sprintf("%s/01 file 1.mkv", course.Path), plan.Files[0].Path)
+		require.Equal(t, defaultRuleName, plan.Files[0].Rule)
+		require.Equal(t, "asset", plan.Files[0].Category)
+		require.Equal(t, 1, *plan.Files[0].Prefix)
+
+		require.Equal(t, fmt.Sprintf("%s/03 notes.zip", course.Path), plan.Files[1].Path)
+		require.Equal(t, "attachment", plan.Files[1].Category)
+		require.Equal(t, skippedNoAsset, plan.Files[1].Skipped)
+
+		require.Equal(t, fmt.Sprintf("%s/Lesson 2 - file 2.mkv", course.Path), plan.Files[2].Path)
+		require.Equal(t, "lessons", plan.Files[2].Rule)
+		require.Equal(t, "asset", plan.Files[2].Category)
+		require.Equal(t, 2, *plan.Files[2].Prefix)
+		require.Equal(t, "file 2", plan.Files[2].Title)
+
+		require.Equal(t, fmt.Sprintf("%s/random.mkv", course.Path), plan.Files[3].Path)
+		require.Empty(t, plan.Files[3].Rule)
+		require.Equal(t, "ignored", plan.Files[3].Category)
+		require.Equal(t, skippedUnparseable, plan.Files[3].Skipped)
+	})
+
+	t.Run("invalid metadata", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		scanner.appFs.Fs.Mkdir(course.Path, os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/course.json", course.Path), []byte("{"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/01 file 1.mkv", course.Path), []byte("hash 1"), os.ModePerm)
+
+		// A real scan would fail, so the dry run does too
+		plan, err := scanner.DryRun(ctx, course.ID)
+		require.ErrorIs(t, err, ErrCourseMetadata)
+		require.Nil(t, plan)
+	})
+
+	t.Run("unreadable sidecar", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		scanner.appFs.Fs.Mkdir(course.Path, os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/01 file 1.mkv", course.Path), []byte("hash 1"), os.ModePerm)
+
+		// A directory in place of the description cannot be read
+		require.NoError(t, scanner.appFs.Fs.Mkdir(fmt.Sprintf("%s/%s", course.Path, coursemetadata.DescriptionFileName), os.ModePerm))
+
+		plan, err := scanner.DryRun(ctx, course.ID)
+		require.ErrorIs(t, err, ErrCourseMetadata)
+		require.Nil(t, plan)
+	})
+}
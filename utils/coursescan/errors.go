@@ -3,5 +3,11 @@ package coursescan
 import "errors"
 
 var (
-	ErrNilScan = errors.New("scan cannot be empty")
+	ErrNilScan              = errors.New("scan cannot be empty")
+	ErrCourseUnavailable    = errors.New("course path does not exist")
+	ErrScanNotFound         = errors.New("scan not found")
+	ErrScanNotWaiting       = errors.New("scan is not waiting")
+	ErrInvalidQueuePosition = errors.New("queue position cannot be negative")
+	ErrInvalidFilenameRule  = errors.New("invalid filename rule")
+	ErrCourseMetadata       = errors.New("failed to read course metadata")
 )
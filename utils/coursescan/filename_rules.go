@@ -0,0 +1,256 @@
+package coursescan
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/coursemetadata"
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// defaultRuleName is the rule name reported for files that follow the default naming
+// convention
+const defaultRuleName = "default"
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ClassifiedFile describes how a file found during a scan was parsed
+type ClassifiedFile struct {
+	Path string `json:"path"`
+
+	// The rule that parsed the file. Empty when no rule matched
+	Rule string `json:"rule,omitempty"`
+
+	Category  string `json:"category"`
+	Module    string `json:"module"`
+	Prefix    *int   `json:"prefix,omitempty"`
+	SubPrefix *int   `json:"subPrefix,omitempty"`
+	Title     string `json:"title,omitempty"`
+
+	// Set when the file is not added to the course
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// scannedFile is a file found on disk, along with how it was parsed
+type scannedFile struct {
+	path     string
+	filename string
+	module   string
+	inRoot   bool
+	parsed   *parsedFile
+	rule     string
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// regexRule is a compiled regex filename rule
+type regexRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// filenameParser parses filenames using the default naming convention, falling back to the
+// filename rules declared in course.json
+type filenameParser struct {
+	regexRules []regexRule
+
+	// An optional ordering rule (natural or mtime) for files no other rule matched
+	orderName string
+	orderType coursemetadata.FilenameRuleType
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// newFilenameParser compiles the filename rules. An error wrapping ErrInvalidFilenameRule is
+// returned when a rule is invalid
+func newFilenameParser(rules []coursemetadata.FilenameRule) (*filenameParser, error) {
+	p := &filenameParser{}
+
+	for i, rule := range rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d (%s)", i+1, rule.Type)
+		}
+
+		switch rule.Type {
+		case coursemetadata.FilenameRuleRegex:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %s", ErrInvalidFilenameRule, name, err)
+			}
+
+			if re.SubexpIndex("Prefix") == -1 {
+				return nil, fmt.Errorf("%w: %s: pattern requires a Prefix group", ErrInvalidFilenameRule, name)
+			}
+
+			p.regexRules = append(p.regexRules, regexRule{name: name, re: re})
+
+		case coursemetadata.FilenameRuleNatural, coursemetadata.FilenameRuleMtime:
+			if p.orderType != "" {
+				return nil, fmt.Errorf("%w: %s: only one ordering rule is allowed", ErrInvalidFilenameRule, name)
+			}
+
+			p.orderName = name
+			p.orderType = rule.Type
+
+		default:
+			return nil, fmt.Errorf("%w: %s: unknown type %q", ErrInvalidFilenameRule, name, rule.Type)
+		}
+	}
+
+	return p, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// parse parses a filename using the default convention then the regex rules, in order. It
+// returns the parsed file and the name of the rule that matched, or nil when nothing matched
+func (p *filenameParser) parse(normalizedPath, filename string) (*parsedFile, string) {
+	if parsed := parseFilename(normalizedPath, filename); parsed != nil {
+		return parsed, defaultRuleName
+	}
+
+	for _, rule := range p.regexRules {
+		if parsed := parseFilenameWithRegex(rule.re, normalizedPath, filename); parsed != nil {
+			return parsed, rule.name
+		}
+	}
+
+	return nil, ""
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// order numbers the unmatched files of each module using the ordering rule, if there is one.
+// Numbering starts after the highest prefix already used in the module. Hidden files are
+// never numbered
+func (p *filenameParser) order(fs afero.Fs, unmatched map[string][]*scannedFile, maxPrefix map[string]int) error {
+	if p.orderType == "" {
+		return nil
+	}
+
+	for module, files := range unmatched {
+		candidates := []*scannedFile{}
+		modTimes := map[string]time.Time{}
+
+		for _, file := range files {
+			if strings.HasPrefix(file.filename, ".") {
+				continue
+			}
+
+			if p.orderType == coursemetadata.FilenameRuleMtime {
+				stat, err := fs.Stat(file.path)
+				if err != nil {
+					return fmt.Errorf("stat %s: %w", file.path, err)
+				}
+				modTimes[file.path] = stat.ModTime()
+			}
+
+			candidates = append(candidates, file)
+		}
+
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if p.orderType == coursemetadata.FilenameRuleMtime {
+				iTime, jTime := modTimes[candidates[i].path], modTimes[candidates[j].path]
+				if !iTime.Equal(jTime) {
+					return iTime.Before(jTime)
+				}
+			}
+
+			return utils.NaturalLess(candidates[i].filename, candidates[j].filename)
+		})
+
+		for i, file := range candidates {
+			ext := filepath.Ext(file.filename)
+			title := strings.TrimSpace(strings.TrimSuffix(file.filename, ext))
+
+			file.parsed = newParsedFile(file.path, file.filename, maxPrefix[module]+i+1, title, nil, "", strings.TrimPrefix(ext, "."))
+			file.rule = p.orderName
+		}
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// parseFilenameWithRegex parses a filename using a regex rule. The regex is matched against
+// the filename without its extension
+func parseFilenameWithRegex(re *regexp.Regexp, normalizedPath, filename string) *parsedFile {
+	ext := filepath.Ext(filename)
+	name := strings.TrimSuffix(filename, ext)
+
+	matches := re.FindStringSubmatch(name)
+	if matches == nil {
+		return nil
+	}
+
+	group := func(name string) string {
+		if idx := re.SubexpIndex(name); idx != -1 {
+			return strings.TrimSpace(matches[idx])
+		}
+		return ""
+	}
+
+	prefix, err := strconv.Atoi(group("Prefix"))
+	if err != nil {
+		return nil
+	}
+
+	var subPrefix *int
+	if sp := group("SubPrefix"); sp != "" {
+		if v, err := strconv.Atoi(sp); err == nil {
+			subPrefix = &v
+		}
+	}
+
+	title := strings.Trim(group("Title"), " -_")
+	subTitle := strings.Trim(group("SubTitle"), " -_")
+
+	return newParsedFile(normalizedPath, filename, prefix, title, subPrefix, subTitle, strings.TrimPrefix(ext, "."))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// classifyFiles describes how each scanned file was parsed and, when it was not added to the
+// course, why
+func classifyFiles(files []*scannedFile, skipped map[string]string) []*ClassifiedFile {
+	classified := make([]*ClassifiedFile, 0, len(files))
+
+	for _, file := range files {
+		c := &ClassifiedFile{
+			Path:     file.path,
+			Rule:     file.rule,
+			Category: categorizeFile(file.parsed).String(),
+			Module:   file.module,
+			Skipped:  skipped[file.path],
+		}
+
+		if file.parsed != nil && !file.parsed.IsCard {
+			prefix := file.parsed.Prefix
+			c.Prefix = &prefix
+			c.SubPrefix = file.parsed.SubPrefix
+			c.Title = file.parsed.Title
+		}
+
+		classified = append(classified, c)
+	}
+
+	sort.Slice(classified, func(i, j int) bool {
+		return utils.NaturalLess(classified[i].Path, classified[j].Path)
+	})
+
+	return classified
+}
@@ -0,0 +1,202 @@
+package coursescan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/coursemetadata"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func scanWithRules(t *testing.T, scanner *CourseScan, ctx context.Context, rules []coursemetadata.FilenameRule, files ...string) []*models.Lesson {
+	t.Helper()
+
+	course := &models.Course{Title: "Course 1", Path: "/course-1"}
+	require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+	require.NoError(t, scanner.appFs.Fs.MkdirAll(course.Path, os.ModePerm))
+	require.NoError(t, coursemetadata.WriteMetadata(scanner.appFs.Fs, course.Path, &coursemetadata.CourseMetadata{FilenameRules: rules}))
+
+	for i, file := range files {
+		path := fmt.Sprintf("%s/%s", course.Path, file)
+		require.NoError(t, afero.WriteFile(scanner.appFs.Fs, path, []byte(file), os.ModePerm))
+
+		// Files are modified in reverse order
+		modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(len(files)-i) * time.Hour)
+		require.NoError(t, scanner.appFs.Fs.Chtimes(path, modTime, modTime))
+	}
+
+	scanState, err := scanner.Add(ctx, course.ID)
+	require.NoError(t, err)
+	require.NoError(t, Processor(ctx, scanner, scanState))
+
+	dbOpts := dao.NewOptions().
+		WithWhere(squirrel.Eq{models.LESSON_TABLE_COURSE_ID: course.ID}).
+		WithOrderBy(models.LESSON_TABLE_MODULE+" asc", models.LESSON_TABLE_PREFIX+" asc")
+
+	lessons, err := scanner.dao.ListLessons(ctx, dbOpts)
+	require.NoError(t, err)
+
+	return lessons
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func lessonTitles(lessons []*models.Lesson) []string {
+	titles := []string{}
+	for _, lesson := range lessons {
+		titles = append(titles, fmt.Sprintf("%d %s", lesson.Prefix.Int16, lesson.Title))
+	}
+	return titles
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_NewFilenameParser(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		parser, err := newFilenameParser([]coursemetadata.FilenameRule{
+			{Type: coursemetadata.FilenameRuleRegex, Pattern: `^Lesson (?P<Prefix>\d+)`},
+			{Name: "fallback", Type: coursemetadata.FilenameRuleMtime},
+		})
+		require.NoError(t, err)
+		require.Len(t, parser.regexRules, 1)
+		require.Equal(t, "rule 1 (regex)", parser.regexRules[0].name)
+		require.Equal(t, "fallback", parser.orderName)
+		require.Equal(t, coursemetadata.FilenameRuleMtime, parser.orderType)
+	})
+
+	tests := []struct {
+		name  string
+		rules []coursemetadata.FilenameRule
+	}{
+		{"invalid regex", []coursemetadata.FilenameRule{{Type: coursemetadata.FilenameRuleRegex, Pattern: `(?P<Prefix>\d+`}}},
+		{"missing prefix", []coursemetadata.FilenameRule{{Type: coursemetadata.FilenameRuleRegex, Pattern: `(?P<Title>.+)`}}},
+		{"unknown type", []coursemetadata.FilenameRule{{Type: "alphabetical"}}},
+		{"two ordering rules", []coursemetadata.FilenameRule{{Type: coursemetadata.FilenameRuleNatural}, {Type: coursemetadata.FilenameRuleMtime}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := newFilenameParser(tt.rules)
+			require.ErrorIs(t, err, ErrInvalidFilenameRule)
+			require.Nil(t, parser)
+		})
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_ParseFilenameWithRegex(t *testing.T) {
+	parser, err := newFilenameParser([]coursemetadata.FilenameRule{
+		{Name: "lesson", Type: coursemetadata.FilenameRuleRegex, Pattern: `^Lesson (?P<Prefix>\d+) - (?P<Title>.+)$`},
+		{Name: "episode", Type: coursemetadata.FilenameRuleRegex, Pattern: `^S\d+E(?P<Prefix>\d+)(?:\.(?P<SubPrefix>\d+))?\s*(?P<Title>.*)$`},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		filename  string
+		rule      string
+		prefix    int
+		subPrefix *int
+		title     string
+		ext       string
+	}{
+		{"01 Intro.mp4", defaultRuleName, 1, nil, "Intro", "mp4"},
+		{"Lesson 3 - Foo.MP4", "lesson", 3, nil, "Foo", "mp4"},
+		{"S01E03 The Episode.mkv", "episode", 3, nil, "The Episode", "mkv"},
+		{"S01E04.2 Part Two.mkv", "episode", 4, intPtr(2), "Part Two", "mkv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			parsed, rule := parser.parse("/course/"+tt.filename, tt.filename)
+			require.NotNil(t, parsed)
+			require.Equal(t, tt.rule, rule)
+			require.Equal(t, tt.prefix, parsed.Prefix)
+			require.Equal(t, tt.subPrefix, parsed.SubPrefix)
+			require.Equal(t, tt.title, parsed.Title)
+			require.Equal(t, tt.ext, parsed.Ext)
+		})
+	}
+
+	t.Run("no match", func(t *testing.T) {
+		parsed, rule := parser.parse("/course/intro.mp4", "intro.mp4")
+		require.Nil(t, parsed)
+		require.Empty(t, rule)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_FilenameRules(t *testing.T) {
+	t.Run("regex", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		lessons := scanWithRules(t, scanner, ctx,
+			[]coursemetadata.FilenameRule{{Type: coursemetadata.FilenameRuleRegex, Pattern: `^Lesson (?P<Prefix>\d+) - (?P<Title>.+)$`}},
+			"Lesson 1 - Intro.mp4",
+			"Lesson 2 - Setup.mp4",
+			"04 Default.mp4",
+			"notes.mp4",
+		)
+
+		require.Equal(t, []string{"1 Intro", "2 Setup", "4 Default"}, lessonTitles(lessons))
+	})
+
+	t.Run("natural", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		lessons := scanWithRules(t, scanner, ctx,
+			[]coursemetadata.FilenameRule{{Type: coursemetadata.FilenameRuleNatural}},
+			"part 10.mp4",
+			"part 2.mp4",
+			"part 1.mp4",
+			"01 Intro.mp4",
+			".hidden.mp4",
+		)
+
+		require.Equal(t, []string{"1 Intro", "2 part 1", "3 part 2", "4 part 10"}, lessonTitles(lessons))
+	})
+
+	t.Run("mtime", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		lessons := scanWithRules(t, scanner, ctx,
+			[]coursemetadata.FilenameRule{{Type: coursemetadata.FilenameRuleMtime}},
+			"a.mp4",
+			"b.mp4",
+			"c.mp4",
+		)
+
+		require.Equal(t, []string{"1 c", "2 b", "3 a"}, lessonTitles(lessons))
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		require.NoError(t, scanner.appFs.Fs.MkdirAll(course.Path, os.ModePerm))
+		require.NoError(t, coursemetadata.WriteMetadata(scanner.appFs.Fs, course.Path, &coursemetadata.CourseMetadata{
+			FilenameRules: []coursemetadata.FilenameRule{{Type: coursemetadata.FilenameRuleRegex, Pattern: `(`}},
+		}))
+
+		scanState, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.ErrorIs(t, Processor(ctx, scanner, scanState), ErrInvalidFilenameRule)
+
+		plan, err := scanner.DryRun(ctx, course.ID)
+		require.ErrorIs(t, err, ErrInvalidFilenameRule)
+		require.Nil(t, plan)
+	})
+}
@@ -0,0 +1,68 @@
+package coursescan
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/types"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// recordHistory writes a scan history record for a finished scan. The final status is derived
+// from the error returned by the processor. Failing to write the record is logged but does not
+// fail the scan
+//
+// Scans that stopped before the course was scanned (deleted course, unavailable path) are not
+// recorded, as they would otherwise show as completed with nothing done
+func (s *CourseScan) recordHistory(ctx context.Context, scanState *ScanState, startedAt time.Time, scanErr error) {
+	if scanErr == nil && scanState.isNotScanned() {
+		s.logger.Debug().
+			Str("course_id", scanState.CourseID).
+			Str("scan_id", scanState.ID).
+			Msg("Scan stopped before scanning, not recording history")
+		return
+	}
+
+	finishedAt := time.Now()
+	counts, skipped := scanState.report()
+
+	history := &models.ScanHistory{
+		CourseID:           scanState.CourseID,
+		Status:             types.ScanStatusCompleted,
+		StartedAt:          types.DateTime(startedAt),
+		FinishedAt:         types.DateTime(finishedAt),
+		Duration:           int(finishedAt.Sub(startedAt).Milliseconds()),
+		LessonsCreated:     counts.LessonsCreated,
+		LessonsUpdated:     counts.LessonsUpdated,
+		LessonsDeleted:     counts.LessonsDeleted,
+		AssetsCreated:      counts.AssetsCreated,
+		AssetsUpdated:      counts.AssetsUpdated,
+		AssetsDeleted:      counts.AssetsDeleted,
+		AttachmentsCreated: counts.AttachmentsCreated,
+		AttachmentsDeleted: counts.AttachmentsDeleted,
+		Skipped:            skipped,
+	}
+
+	if scanErr != nil {
+		if errors.Is(scanErr, context.Canceled) || errors.Is(scanErr, context.DeadlineExceeded) || isCancellationError(scanErr) {
+			history.Status = types.ScanStatusCancelled
+		} else {
+			history.Status = types.ScanStatusFailed
+			history.Error = scanErr.Error()
+		}
+	} else if scanState.IsCancelled() {
+		history.Status = types.ScanStatusCancelled
+	}
+
+	// The scan context may have been cancelled, so write the record without it
+	if err := s.dao.CreateScanHistory(context.WithoutCancel(ctx), history); err != nil {
+		s.logger.Warn().
+			Err(err).
+			Str("course_id", scanState.CourseID).
+			Str("scan_id", scanState.ID).
+			Msg("Failed to record scan history")
+	}
+}
@@ -0,0 +1,127 @@
+package coursescan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_History(t *testing.T) {
+	waitForHistory := func(t *testing.T, scanner *CourseScan, ctx context.Context, courseID string) *models.ScanHistory {
+		t.Helper()
+
+		dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.SCAN_HISTORY_TABLE_COURSE_ID: courseID})
+
+		var history *models.ScanHistory
+		require.Eventually(t, func() bool {
+			var err error
+			history, err = scanner.dao.GetScanHistory(ctx, dbOpts)
+			return err == nil && history != nil
+		}, 2*time.Second, 50*time.Millisecond, "Scan history should be recorded")
+
+		return history
+	}
+
+	t.Run("completed", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		scanner.appFs.Fs.Mkdir(course.Path, os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/01 file 1.mkv", course.Path), []byte("hash 1"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/02 file 2.pdf", course.Path), []byte("hash 2"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/03 notes.url", course.Path), []byte("notes"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/04 lesson 4 {01 video 1}.mkv", course.Path), []byte("hash 4"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/04 links.url", course.Path), []byte("links"), os.ModePerm)
+		afero.WriteFile(scanner.appFs.Fs, fmt.Sprintf("%s/readme.txt", course.Path), []byte("readme"), os.ModePerm)
+
+		go scanner.Worker(ctx, Processor)
+
+		_, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+
+		history := waitForHistory(t, scanner, ctx, course.ID)
+		require.Equal(t, types.ScanStatusCompleted, history.Status)
+		require.Empty(t, history.Error)
+		require.Equal(t, course.Title, history.CourseTitle)
+		require.False(t, history.StartedAt.IsZero())
+		require.False(t, history.FinishedAt.IsZero())
+		require.Equal(t, 3, history.LessonsCreated)
+		require.Equal(t, 3, history.AssetsCreated)
+		require.Equal(t, models.ScanSkippedFiles{
+			{Path: fmt.Sprintf("%s/03 notes.url", course.Path), Reason: skippedNoAsset},
+			{Path: fmt.Sprintf("%s/04 links.url", course.Path), Reason: skippedGrouped},
+			{Path: fmt.Sprintf("%s/readme.txt", course.Path), Reason: skippedUnparseable},
+		}, history.Skipped)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		go scanner.Worker(ctx, func(context.Context, *CourseScan, *ScanState) error {
+			return errors.New("processing error")
+		})
+
+		_, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+
+		history := waitForHistory(t, scanner, ctx, course.ID)
+		require.Equal(t, types.ScanStatusFailed, history.Status)
+		require.Equal(t, "processing error", history.Error)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		go scanner.Worker(ctx, func(context.Context, *CourseScan, *ScanState) error {
+			return context.Canceled
+		})
+
+		_, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+
+		history := waitForHistory(t, scanner, ctx, course.ID)
+		require.Equal(t, types.ScanStatusCancelled, history.Status)
+		require.Empty(t, history.Error)
+	})
+	t.Run("not scanned", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		// The course path does not exist, so the course is unavailable
+		course := &models.Course{Title: "Course 1", Path: "/course-1", Available: true}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		go scanner.Worker(ctx, Processor)
+
+		_, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			return scanner.GetScanByCourseID(course.ID) == nil
+		}, 2*time.Second, 50*time.Millisecond, "Scan should finish")
+
+		dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.SCAN_HISTORY_TABLE_COURSE_ID: course.ID})
+		history, err := scanner.dao.GetScanHistory(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Nil(t, history)
+	})
+}
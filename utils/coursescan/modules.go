@@ -0,0 +1,177 @@
+package coursescan
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// MaxModuleDepth is how many levels of module directories below the course root are scanned,
+// such as `01 Part One/02 Setup/03 Install` for a depth of 3
+const MaxModuleDepth = 3
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// A regex for parsing a module directory name into a prefix and title, such as `01 - Setup`
+var moduleNameRegex = regexp.MustCompile(`^(?P<Prefix>\d+)(?:\s*[-_.]+\s*|\s+)(?P<Title>\S.*)$`)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// scannedModule is a module directory that holds lessons, directly or through a child module
+type scannedModule struct {
+	path       string
+	parentPath string
+	title      string
+	prefix     sql.NullInt16
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// modulePath returns the path of the directory relative to the course path, using forward
+// slashes
+func modulePath(coursePath, dir string) string {
+	rel, err := filepath.Rel(coursePath, dir)
+	if err != nil {
+		return filepath.Base(dir)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// parseModuleName parses a module directory name into a prefix and title. When the name has
+// no prefix the title is the name
+func parseModuleName(name string) (sql.NullInt16, string) {
+	matches := moduleNameRegex.FindStringSubmatch(name)
+	if matches == nil {
+		return sql.NullInt16{}, name
+	}
+
+	prefix, err := strconv.Atoi(matches[1])
+	if err != nil || prefix > 32767 {
+		return sql.NullInt16{}, name
+	}
+
+	return sql.NullInt16{Int16: int16(prefix), Valid: true}, strings.TrimSpace(matches[2])
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// buildModules returns the modules for the lessons, including every ancestor module, ordered
+// so that parents come before their children
+func buildModules(lessons []*models.Lesson) []*scannedModule {
+	byPath := map[string]*scannedModule{}
+
+	for _, lesson := range lessons {
+		for p := lesson.Module; p != "" && p != "." && byPath[p] == nil; {
+			parentPath := path.Dir(p)
+			if parentPath == "." {
+				parentPath = ""
+			}
+
+			prefix, title := parseModuleName(path.Base(p))
+			byPath[p] = &scannedModule{path: p, parentPath: parentPath, title: title, prefix: prefix}
+
+			p = parentPath
+		}
+	}
+
+	modules := make([]*scannedModule, 0, len(byPath))
+	for _, m := range byPath {
+		modules = append(modules, m)
+	}
+
+	sort.Slice(modules, func(i, j int) bool {
+		di, dj := strings.Count(modules[i].path, "/"), strings.Count(modules[j].path, "/")
+		if di != dj {
+			return di < dj
+		}
+
+		return modules[i].path < modules[j].path
+	})
+
+	return modules
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// applyModuleOps syncs the modules in the database with the module directories of the scanned
+// lessons. Modules that no longer hold lessons are deleted
+func applyModuleOps(ctx context.Context, s *CourseScan, courseID string, lessons []*models.Lesson) error {
+	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.MODULE_TABLE_COURSE_ID: courseID})
+	existing, err := s.dao.ListModules(ctx, dbOpts)
+	if err != nil {
+		return fmt.Errorf("failed to list modules: %w", err)
+	}
+
+	existingByPath := make(map[string]*models.Module, len(existing))
+	for _, module := range existing {
+		existingByPath[module.Path] = module
+	}
+
+	ids := map[string]string{}
+
+	for _, m := range buildModules(lessons) {
+		parentID := sql.NullString{}
+		if m.parentPath != "" {
+			parentID = sql.NullString{String: ids[m.parentPath], Valid: true}
+		}
+
+		if module, ok := existingByPath[m.path]; ok {
+			delete(existingByPath, m.path)
+			ids[m.path] = module.ID
+
+			if module.ParentID == parentID && module.Title == m.title && module.Prefix == m.prefix {
+				continue
+			}
+
+			module.ParentID = parentID
+			module.Title = m.title
+			module.Prefix = m.prefix
+
+			if err := s.dao.UpdateModule(ctx, module); err != nil {
+				return fmt.Errorf("failed to update module %s: %w", m.path, err)
+			}
+
+			continue
+		}
+
+		module := &models.Module{
+			CourseID: courseID,
+			ParentID: parentID,
+			Path:     m.path,
+			Title:    m.title,
+			Prefix:   m.prefix,
+		}
+
+		if err := s.dao.CreateModule(ctx, module); err != nil {
+			return fmt.Errorf("failed to create module %s: %w", m.path, err)
+		}
+
+		ids[m.path] = module.ID
+	}
+
+	if len(existingByPath) == 0 {
+		return nil
+	}
+
+	stale := make([]string, 0, len(existingByPath))
+	for _, module := range existingByPath {
+		stale = append(stale, module.ID)
+	}
+
+	return s.dao.DeleteModules(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.BASE_ID: stale}))
+}
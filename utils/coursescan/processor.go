@@ -1,8 +1,5 @@
 package coursescan
 
-// TODO support author.txt/md for course
-// TODO support description.txt/md for course
-
 import (
 	"context"
 	"crypto/sha256"
@@ -61,6 +58,7 @@ func Processor(ctx context.Context, s *CourseScan, scanState *ScanState) error {
 	}
 
 	if course == nil {
+		scanState.setNotScanned()
 		return nil
 	}
 
@@ -94,6 +92,7 @@ func Processor(ctx context.Context, s *CourseScan, scanState *ScanState) error {
 	}
 
 	if !available {
+		scanState.setNotScanned()
 		return nil
 	}
 
@@ -112,6 +111,7 @@ func Processor(ctx context.Context, s *CourseScan, scanState *ScanState) error {
 				Str("course_id", courseID).
 				Str("course_path", coursePath).
 				Msg("Course path does not exist, skipping scan")
+			scanState.setNotScanned()
 			return nil
 		}
 
@@ -123,7 +123,9 @@ func Processor(ctx context.Context, s *CourseScan, scanState *ScanState) error {
 		return fmt.Errorf("failed to access course path %s: %w", course.Path, err)
 	}
 
-	// Read course metadata (course.json) if it exists
+	// Read course metadata (course.json) if it exists. A file that cannot be read or parsed,
+	// such as one that is half saved, fails the scan. Continuing without it would clear the
+	// stored details and regroup the lessons without the course's filename rules
 	scanState.UpdateMessage("Reading course metadata")
 	metadata, err := coursemetadata.ReadMetadata(s.appFs.Fs, course.Path)
 	if err != nil {
@@ -131,13 +133,23 @@ func Processor(ctx context.Context, s *CourseScan, scanState *ScanState) error {
 			Err(err).
 			Str("course_id", courseID).
 			Str("course_path", coursePath).
-			Msg("Failed to read course.json, continuing without metadata")
-		metadata = nil
+			Msg("Failed to read course.json, skipping scan")
+		return fmt.Errorf("%w: %s: %w", ErrCourseMetadata, coursemetadata.MetadataFileName, err)
+	}
+
+	sidecars, err := coursemetadata.ReadSidecars(s.appFs.Fs, course.Path)
+	if err != nil {
+		s.logger.Warn().
+			Err(err).
+			Str("course_id", courseID).
+			Str("course_path", coursePath).
+			Msg("Failed to read course sidecar files, skipping scan")
+		return fmt.Errorf("%w: %w", ErrCourseMetadata, err)
 	}
 
 	scanState.UpdateMessage("Scanning course directory")
 
-	scanned, err := scanFiles(s, course)
+	scanned, err := scanFiles(s, course, metadata)
 	if err != nil {
 		s.logger.Error().
 			Err(err).
@@ -147,6 +159,8 @@ func Processor(ctx context.Context, s *CourseScan, scanState *ScanState) error {
 		return err
 	}
 
+	scanState.setSkipped(scanned.skipped)
+
 	scannedAttachments, scannedAssets := flatAttachmentsAndAssets(scanned.lessons)
 
 	s.logger.Info().
@@ -212,7 +226,11 @@ func Processor(ctx context.Context, s *CourseScan, scanState *ScanState) error {
 		Int("attachment_ops", len(attachmentOps)).
 		Msg("Generated reconciliation operations")
 
-	assetMetadataByPath := probeVideos(ctx, s, assetOps, course, extractedKeyframes, scanState)
+	// Unchanged media assets without metadata, such as audio files that were once classified as
+	// videos, are probed again
+	reprobeAssets := unprobedAssets(scannedAssets, existingAssets)
+
+	assetMetadataByPath := probeMedia(ctx, s, assetOps, reprobeAssets, course, extractedKeyframes, scanState)
 
 	if ctx.Err() != nil {
 		return ctx.Err()
@@ -224,8 +242,19 @@ func Processor(ctx context.Context, s *CourseScan, scanState *ScanState) error {
 		return err
 	}
 
+	// Generate a card from the first video when the course has no card
+	if generated, err := generateCourseCard(ctx, s, course, scannedAssets, existingAssets, assetMetadataByPath, scanState); err != nil {
+		return err
+	} else if generated {
+		cardChanged = true
+	}
+
 	updatedCourse := cardChanged
 
+	if applyCourseDetails(course, metadata, sidecars) {
+		updatedCourse = true
+	}
+
 	opCounts := countOperations(groupOps, assetOps, attachmentOps)
 
 	scanState.UpdateMessage("Applying database changes")
@@ -253,12 +282,26 @@ func Processor(ctx context.Context, s *CourseScan, scanState *ScanState) error {
 			updatedCourse = true
 		}
 
+		if updated, err := applyReprobedMetadata(txCtx, s, course, reprobeAssets, assetMetadataByPath, extractedKeyframes); err != nil {
+			return err
+		} else if updated {
+			updatedCourse = true
+		}
+
 		if updated, err := applyLessonDeleteOps(txCtx, s, groupOps, course); err != nil {
 			return err
 		} else if updated {
 			updatedCourse = true
 		}
 
+		if err := applySubtitleOps(txCtx, s, course.ID, scanned.subtitles); err != nil {
+			return err
+		}
+
+		if err := applyModuleOps(txCtx, s, course.ID, scanned.lessons); err != nil {
+			return err
+		}
+
 		// Apply tags from course.json if metadata exists
 		if metadata != nil && len(metadata.Tags) > 0 {
 			if err := applyTagsFromMetadata(txCtx, s, course.ID, metadata.Tags); err != nil {
@@ -329,6 +372,25 @@ func Processor(ctx context.Context, s *CourseScan, scanState *ScanState) error {
 		return err
 	}
 
+	// Delete the thumbnails of removed assets once the change is committed, so a failed scan
+	// keeps them
+	for _, asset := range removedAssets(assetOps) {
+		deleteThumbnails(s, course, asset)
+	}
+
+	// Queue thumbnails once the assets are committed, so the worker can find them
+	if len(assetOps) > 0 || len(reprobeAssets) > 0 {
+		if err := queueThumbnails(ctx, s, course.ID); err != nil {
+			s.logger.Warn().
+				Err(err).
+				Str("course_id", courseID).
+				Str("course_path", coursePath).
+				Msg("Failed to queue thumbnails")
+		}
+	}
+
+	scanState.setOperationCounts(opCounts)
+
 	duration := time.Since(startTime)
 
 	// When not testing, ensure minimum scan duration of 2 seconds to allow frontend to see the changes
@@ -432,6 +494,11 @@ func handleCourseCard(
 	courseID, coursePath string,
 	scanState *ScanState,
 ) (bool, error) {
+	// A custom card is kept until it is changed through the API
+	if course.CardSource == types.CardSourceCustom {
+		return false, nil
+	}
+
 	cardChanged := course.CardPath != scannedCardPath
 	if !cardChanged {
 		return false, nil
@@ -454,11 +521,13 @@ func handleCourseCard(
 		course.CardPath = ""
 		course.CardHash = ""
 		course.CardModTime = ""
+		course.CardSource = types.CardSourceNone
 		return true, nil
 	}
 
-	// Card was added or changed
+	// Card was added or changed. This replaces a generated card
 	course.CardPath = scannedCardPath
+	course.CardSource = types.CardSourceFile
 
 	// Calculate card hash and mod time
 	cardHash, err := hashFilePartial(s.appFs.Fs, scannedCardPath, 1024*1024)
@@ -520,6 +589,113 @@ func handleCourseCard(
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// generateCourseCard generates a card from a frame of the first video in the course when the
+// course has no card. Returns true if a card was generated, and an error if the context was
+// canceled during generation
+func generateCourseCard(
+	ctx context.Context,
+	s *CourseScan,
+	course *models.Course,
+	scannedAssets []*models.Asset,
+	existingAssets []*models.Asset,
+	assetMetadataByPath map[string]*models.AssetMetadata,
+	scanState *ScanState,
+) (bool, error) {
+	if course.CardSource != types.CardSourceNone {
+		return false, nil
+	}
+
+	var video *models.Asset
+	for _, asset := range scannedAssets {
+		if !asset.Type.IsVideo() {
+			continue
+		}
+
+		if video == nil || assetSortsBefore(asset, video) {
+			video = asset
+		}
+	}
+
+	if video == nil {
+		return false, nil
+	}
+
+	// The duration comes from this scan's probe or, for an unchanged asset, the last scan
+	durationSec := 0
+	if metadata, ok := assetMetadataByPath[video.Path]; ok {
+		durationSec = metadata.DurationSec()
+	} else {
+		for _, existing := range existingAssets {
+			if existing.Path == video.Path && existing.AssetMetadata != nil {
+				durationSec = existing.AssetMetadata.DurationSec()
+				break
+			}
+		}
+	}
+
+	optimizedCardPath := s.cardCache.GetCardPath(course.ID)
+	scanState.UpdateMessage("Generating course card")
+	if err := s.cardCache.GenerateVideoCard(ctx, video.Path, optimizedCardPath, durationSec); err != nil {
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return false, err
+		}
+
+		s.logger.Warn().
+			Err(err).
+			Str("course_id", course.ID).
+			Str("course_path", course.Path).
+			Str("video_path", video.Path).
+			Msg("Failed to generate card from video, course will use fallback")
+		return false, nil
+	}
+
+	// The hash of the generated card is used to bust the card cache in the UI
+	cardHash, err := hashFilePartial(s.appFs.Fs, optimizedCardPath, 1024*1024)
+	if err != nil {
+		s.logger.Warn().
+			Err(err).
+			Str("course_id", course.ID).
+			Str("optimized_path", optimizedCardPath).
+			Msg("Failed to calculate generated card hash")
+	}
+
+	course.CardPath = ""
+	course.CardHash = cardHash
+	course.CardModTime = ""
+	course.CardSource = types.CardSourceGenerated
+
+	s.logger.Info().
+		Str("course_id", course.ID).
+		Str("course_path", course.Path).
+		Str("video_path", video.Path).
+		Str("optimized_path", optimizedCardPath).
+		Msg("Generated card from video")
+
+	return true, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// assetSortsBefore reports whether asset a comes before asset b in the course, ordering by
+// module, prefix and sub-prefix
+func assetSortsBefore(a, b *models.Asset) bool {
+	if a.Module != b.Module {
+		return a.Module < b.Module
+	}
+
+	if a.Prefix.Int16 != b.Prefix.Int16 {
+		return a.Prefix.Int16 < b.Prefix.Int16
+	}
+
+	if a.SubPrefix.Int16 != b.SubPrefix.Int16 {
+		return a.SubPrefix.Int16 < b.SubPrefix.Int16
+	}
+
+	return a.Path < b.Path
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // fetchCourse retrieves the course from the database
 func fetchCourse(ctx context.Context, s *CourseScan, courseID string) (*models.Course, error) {
 	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseID})
@@ -653,54 +829,121 @@ func clearCourseMaintenanceWithRetry(ctx context.Context, s *CourseScan, courseI
 
 // lessonBucket accumulates files for a given module & prefix
 type lessonBucket struct {
-	groupedFiles []*parsedFile
-	soloFiles    []*parsedFile
-	attachFiles  []*parsedFile
+	groupedFiles  []*parsedFile
+	soloFiles     []*parsedFile
+	attachFiles   []*parsedFile
+	subtitleFiles []*parsedFile
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// scannedResults holds all lessons and the card image path
+// scannedResults holds all lessons, the card image path and the files that were skipped
 type scannedResults struct {
-	lessons  []*models.Lesson
-	cardPath string
+	lessons   []*models.Lesson
+	subtitles []*scannedSubtitle
+	cardPath  string
+	skipped   models.ScanSkippedFiles
+	files     []*ClassifiedFile
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// Reasons a file is skipped during a scan
+const (
+	skippedUnparseable = "unparseable filename"
+	skippedNoAsset     = "lesson has no asset"
+	skippedGrouped     = "lesson has grouped assets"
+	skippedCard        = "card not used"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // scanFiles scans the course directory for files. It will return a list of grouped assets,
-// and a card path, if found.
-func scanFiles(s *CourseScan, course *models.Course) (*scannedResults, error) {
+// and a card path, if found. Files that do not follow the default naming convention are
+// parsed using the filename rules from the course metadata (if any)
+func scanFiles(s *CourseScan, course *models.Course, metadata *coursemetadata.CourseMetadata) (*scannedResults, error) {
 	s.logger.Info().
 		Str("course_id", course.ID).
 		Str("course_path", course.Path).
 		Msg("Scanning course directory")
 
-	files, err := s.appFs.ReadDirFlat(course.Path, 2)
+	var rules []coursemetadata.FilenameRule
+	if metadata != nil {
+		rules = metadata.FilenameRules
+	}
+
+	parser, err := newFilenameParser(rules)
 	if err != nil {
 		return nil, err
 	}
 
-	// A bucket is module => prefix => fileBucket
-	buckets := map[string]map[int]*lessonBucket{}
-	cardPath := ""
+	files, err := s.appFs.ReadDirFlat(course.Path, MaxModuleDepth+1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the files on disk. Files that no rule matched are kept per module so they can be
+	// numbered by an ordering rule
+	scanned := []*scannedFile{}
+	unmatched := map[string][]*scannedFile{}
+	maxPrefix := map[string]int{}
 
-	// Scan files on disk and categorize them into buckets
 	for _, fp := range files {
 		normalizedPath := utils.NormalizeWindowsDrive(fp)
 		filename := filepath.Base(normalizedPath)
 		dir := filepath.Dir(normalizedPath)
 		inRoot := dir == utils.NormalizeWindowsDrive(course.Path)
 
+		if inRoot && coursemetadata.IsMetadataFile(filename) {
+			continue
+		}
+
+		// The module is the path of the directory relative to the course, such as
+		// `01 Part One/02 Setup`
 		module := ""
 		if !inRoot {
-			module = filepath.Base(dir)
+			module = modulePath(utils.NormalizeWindowsDrive(course.Path), dir)
 		}
 
-		parsed := parseFilename(normalizedPath, filename)
+		file := &scannedFile{
+			path:     normalizedPath,
+			filename: filename,
+			module:   module,
+			inRoot:   inRoot,
+		}
+		file.parsed, file.rule = parser.parse(normalizedPath, filename)
+		scanned = append(scanned, file)
+
+		if file.parsed == nil {
+			unmatched[module] = append(unmatched[module], file)
+		} else if !file.parsed.IsCard && file.parsed.Prefix > maxPrefix[module] {
+			maxPrefix[module] = file.parsed.Prefix
+		}
+	}
+
+	if err := parser.order(s.appFs.Fs, unmatched, maxPrefix); err != nil {
+		return nil, err
+	}
+
+	// A bucket is module => prefix => fileBucket
+	buckets := map[string]map[int]*lessonBucket{}
+	cardPath := ""
+	skipped := models.ScanSkippedFiles{}
+
+	skip := func(path, reason string) {
+		skipped = append(skipped, models.ScanSkippedFile{Path: path, Reason: reason})
+	}
+
+	// Categorize the parsed files into buckets
+	for _, file := range scanned {
+		normalizedPath := file.path
+		module := file.module
+		inRoot := file.inRoot
+		parsed := file.parsed
 		category := categorizeFile(parsed)
 
 		if category == Ignore {
+			skip(normalizedPath, skippedUnparseable)
 			continue
 		}
 
@@ -719,6 +962,8 @@ func scanFiles(s *CourseScan, course *models.Course) (*scannedResults, error) {
 		case Card:
 			if inRoot && cardPath == "" {
 				cardPath = normalizedPath
+			} else {
+				skip(normalizedPath, skippedCard)
 			}
 
 		case GroupedAsset:
@@ -729,10 +974,14 @@ func scanFiles(s *CourseScan, course *models.Course) (*scannedResults, error) {
 
 		case Attachment:
 			bucket.attachFiles = append(bucket.attachFiles, parsed)
+
+		case Subtitle:
+			bucket.subtitleFiles = append(bucket.subtitleFiles, parsed)
 		}
 	}
 
 	var lessons []*models.Lesson
+	var scannedSubtitles []*scannedSubtitle
 	for module, prefixMap := range buckets {
 		for prefix, bucket := range prefixMap {
 			sort.Slice(bucket.groupedFiles, func(i, j int) bool {
@@ -764,6 +1013,11 @@ func scanFiles(s *CourseScan, course *models.Course) (*scannedResults, error) {
 				for _, parsedFile := range bucket.soloFiles {
 					lesson.Attachments = append(lesson.Attachments, parsedFile.toAttachment())
 				}
+
+				// Grouped lessons only take solo files as attachments
+				for _, parsedFile := range bucket.attachFiles {
+					skip(parsedFile.NormalizedPath, skippedGrouped)
+				}
 			} else if len(bucket.soloFiles) > 0 {
 				if len(bucket.soloFiles) > 1 {
 					idx := pickBest(bucket.soloFiles)
@@ -801,15 +1055,40 @@ func scanFiles(s *CourseScan, course *models.Course) (*scannedResults, error) {
 
 			}
 
+			// Subtitles that cannot be paired with a video are kept as attachments
+			for _, parsedFile := range bucket.subtitleFiles {
+				if subtitle := pairSubtitle(parsedFile, lesson.Assets); subtitle != nil {
+					scannedSubtitles = append(scannedSubtitles, subtitle)
+				} else if len(lesson.Assets) > 0 {
+					lesson.Attachments = append(lesson.Attachments, parsedFile.toAttachment())
+				} else {
+					skip(parsedFile.NormalizedPath, skippedNoAsset)
+				}
+			}
+
 			if len(lesson.Assets) > 0 {
 				lessons = append(lessons, lesson)
+			} else {
+				for _, parsedFile := range bucket.attachFiles {
+					skip(parsedFile.NormalizedPath, skippedNoAsset)
+				}
 			}
 		}
 	}
 
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Path < skipped[j].Path })
+
+	skippedReasons := make(map[string]string, len(skipped))
+	for _, file := range skipped {
+		skippedReasons[file.Path] = file.Reason
+	}
+
 	return &scannedResults{
-		lessons:  lessons,
-		cardPath: cardPath,
+		lessons:   lessons,
+		subtitles: scannedSubtitles,
+		cardPath:  cardPath,
+		skipped:   skipped,
+		files:     classifyFiles(scanned, skippedReasons),
 	}, nil
 }
 
@@ -829,33 +1108,78 @@ func flatAttachmentsAndAssets(lessons []*models.Lesson) ([]*models.Attachment, [
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// probeVideos probes videos assets that match the operations create, replace, swap, or overwrite
-func probeVideos(ctx context.Context, s *CourseScan, ops []Op, course *models.Course, extractedKeyframes map[string][]float64, scanState *ScanState) map[string]*models.AssetMetadata {
+// mediaProbeVersion is stored with the metadata of each probed media asset. Bump it when probing
+// stores more, so existing assets are probed again on the next scan
+//
+//   - 1: audio tracks and chapters
+const mediaProbeVersion = 1
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// unprobedAssets returns the existing media assets that were matched by a scanned asset but have
+// no metadata for their type, or metadata stored by an older probe. A media file that cannot be
+// probed is tried again on every scan
+func unprobedAssets(scanned []*models.Asset, existing []*models.Asset) []*models.Asset {
+	existingByID := make(map[string]*models.Asset, len(existing))
+	for _, e := range existing {
+		existingByID[e.ID] = e
+	}
+
+	var out []*models.Asset
+	for _, s := range scanned {
+		// Only unchanged assets keep the ID of the existing asset
+		e := existingByID[s.ID]
+		if e == nil || !e.Type.IsMedia() {
+			continue
+		}
+
+		metadata := e.AssetMetadata
+		switch {
+		case metadata == nil:
+			out = append(out, e)
+		case e.Type.IsAudio() && (metadata.AudioMetadata == nil || metadata.AudioMetadata.ProbeVersion < mediaProbeVersion):
+			out = append(out, e)
+		case e.Type.IsVideo() && (metadata.VideoMetadata == nil || metadata.VideoMetadata.ProbeVersion < mediaProbeVersion):
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// probeMedia probes video and audio assets that match the operations create, replace, swap, or
+// overwrite, along with the existing assets to probe again. Keyframes are only extracted for
+// videos
+func probeMedia(ctx context.Context, s *CourseScan, ops []Op, reprobe []*models.Asset, course *models.Course, extractedKeyframes map[string][]float64, scanState *ScanState) map[string]*models.AssetMetadata {
 	var targets []*models.Asset
 	for _, op := range ops {
 		switch v := op.(type) {
 		case CreateAssetOp:
-			if v.New.Type.IsVideo() {
+			if v.New.Type.IsMedia() {
 				targets = append(targets, v.New)
 			}
 		case ReplaceAssetOp:
-			if v.New.Type.IsVideo() {
+			if v.New.Type.IsMedia() {
 				targets = append(targets, v.New)
 			}
 		case SwapAssetOp:
-			if v.NewA.Type.IsVideo() {
+			if v.NewA.Type.IsMedia() {
 				targets = append(targets, v.NewA)
 			}
-			if v.NewB.Type.IsVideo() {
+			if v.NewB.Type.IsMedia() {
 				targets = append(targets, v.NewB)
 			}
 		case OverwriteAssetOp:
-			if v.Renamed.Type.IsVideo() {
+			if v.Renamed.Type.IsMedia() {
 				targets = append(targets, v.Renamed)
 			}
 		}
 	}
 
+	targets = append(targets, reprobe...)
+
 	if len(targets) == 0 {
 		return map[string]*models.AssetMetadata{}
 	}
@@ -863,13 +1187,13 @@ func probeVideos(ctx context.Context, s *CourseScan, ops []Op, course *models.Co
 	s.logger.Info().
 		Str("course_id", course.ID).
 		Str("course_path", course.Path).
-		Int("video_assets_count", len(targets)).
-		Msg("Starting video probing and keyframe extraction")
+		Int("media_assets_count", len(targets)).
+		Msg("Starting media probing and keyframe extraction")
 
-	scanState.UpdateMessage("Extracting video keyframes")
+	scanState.UpdateMessage("Probing media")
 	mediaProbe := probe.MediaProbe{FFmpeg: s.ffmpeg}
 	assetMetadataByPath := make(map[string]*models.AssetMetadata)
-	totalVideos := len(targets)
+	totalMedia := len(targets)
 
 	cancelled := false
 	for i, asset := range targets {
@@ -877,15 +1201,80 @@ func probeVideos(ctx context.Context, s *CourseScan, ops []Op, course *models.Co
 			s.logger.Info().
 				Str("course_id", course.ID).
 				Str("course_path", course.Path).
-				Msg("Video probing cancelled")
+				Msg("Media probing cancelled")
 			cancelled = true
 			break
 		}
 
-		scanState.UpdateMessage(fmt.Sprintf("Extracting video keyframes (%d/%d)", i+1, totalVideos))
+		scanState.UpdateMessage(fmt.Sprintf("Probing media (%d/%d)", i+1, totalMedia))
+
+		// Limit the number of concurrent ffprobe calls across all running scans
+		if err := s.acquireProbe(ctx); err != nil {
+			cancelled = true
+			break
+		}
+
+		// Archive members are extracted, as ffprobe needs a real file. The copy is removed once
+		// probed, so a scan does not leave a second copy of the course on disk
+		probePath, release, err := s.appFs.TempLocalPath(asset.Path)
+		if err != nil {
+			s.releaseProbe()
+			s.logger.Warn().
+				Err(err).
+				Str("course_id", course.ID).
+				Str("course_path", course.Path).
+				Str("asset_path", asset.Path).
+				Msg("Failed to extract media file from archive")
+			continue
+		}
+
+		// Audio assets have no keyframes to extract
+		if asset.Type.IsAudio() {
+			info, err := mediaProbe.ProbeAudio(ctx, probePath)
+			s.releaseProbe()
+			release()
+
+			if err != nil {
+				if ctx.Err() != nil || isCancellationError(err) {
+					cancelled = true
+					break
+				}
+				s.logger.Warn().
+					Err(err).
+					Str("course_id", course.ID).
+					Str("course_path", course.Path).
+					Str("audio_path", asset.Path).
+					Msg("Failed to probe audio file")
+				continue
+			}
+
+			assetMetadataByPath[asset.Path] = &models.AssetMetadata{
+				AudioMetadata: &models.AudioMetadata{
+					Language:      info.Audio.Language,
+					Codec:         info.Audio.Codec,
+					Profile:       info.Audio.Profile,
+					Channels:      info.Audio.Channels,
+					ChannelLayout: info.Audio.ChannelLayout,
+					SampleRate:    info.Audio.SampleRate,
+					BitRate:       info.Audio.BitRate,
+					DurationSec:   info.DurationSec,
+					Title:         info.Tags.Title,
+					Artist:        info.Tags.Artist,
+					Album:         info.Tags.Album,
+					HasCoverArt:   info.Tags.HasCoverArt,
+					ProbeVersion:  mediaProbeVersion,
+				},
+				ChapterMetadata: chapterMetadata(info.Chapters),
+			}
 
-		info, _, err := mediaProbe.ProbeVideo(ctx, asset.Path)
+			continue
+		}
+
+		info, _, err := mediaProbe.ProbeVideo(ctx, probePath)
 		if err != nil {
+			s.releaseProbe()
+			release()
+
 			if ctx.Err() != nil || isCancellationError(err) {
 				cancelled = true
 				break
@@ -900,7 +1289,11 @@ func probeVideos(ctx context.Context, s *CourseScan, ops []Op, course *models.Co
 		}
 
 		var keyframes []float64
-		if kf, err := mediaProbe.ExtractKeyframesForVideo(ctx, asset.Path); err == nil {
+		kf, err := mediaProbe.ExtractKeyframesForVideo(ctx, probePath)
+		s.releaseProbe()
+		release()
+
+		if err == nil {
 			keyframes = kf
 			s.logger.Debug().
 				Str("course_id", course.ID).
@@ -923,18 +1316,24 @@ func probeVideos(ctx context.Context, s *CourseScan, ops []Op, course *models.Co
 
 		assetMetadataByPath[asset.Path] = &models.AssetMetadata{
 			VideoMetadata: &models.VideoMetadata{
-				DurationSec: info.DurationSec,
-				Container:   info.File.Container,
-				MIMEType:    info.File.MIMEType,
-				SizeBytes:   info.File.SizeBytes,
-				OverallBPS:  info.File.OverallBPS,
-				VideoCodec:  info.Video.Codec,
-				Width:       info.Video.Width,
-				Height:      info.Video.Height,
-				FPSNum:      info.Video.FPSNum,
-				FPSDen:      info.Video.FPSDen,
+				DurationSec:  info.DurationSec,
+				Container:    info.File.Container,
+				MIMEType:     info.File.MIMEType,
+				SizeBytes:    info.File.SizeBytes,
+				OverallBPS:   info.File.OverallBPS,
+				VideoCodec:   info.Video.Codec,
+				Width:        info.Video.Width,
+				Height:       info.Video.Height,
+				FPSNum:       info.Video.FPSNum,
+				FPSDen:       info.Video.FPSDen,
+				ProbeVersion: mediaProbeVersion,
 			},
-			AudioMetadata: &models.AudioMetadata{
+			ChapterMetadata: chapterMetadata(info.Chapters),
+		}
+
+		// Videos without an audio stream, such as screen recordings, have no audio metadata
+		if info.Audio != nil {
+			assetMetadataByPath[asset.Path].AudioMetadata = &models.AudioMetadata{
 				Language:      info.Audio.Language,
 				Codec:         info.Audio.Codec,
 				Profile:       info.Audio.Profile,
@@ -942,8 +1341,33 @@ func probeVideos(ctx context.Context, s *CourseScan, ops []Op, course *models.Co
 				ChannelLayout: info.Audio.ChannelLayout,
 				SampleRate:    info.Audio.SampleRate,
 				BitRate:       info.Audio.BitRate,
-			},
+			}
 		}
+
+		for _, track := range info.AudioTracks {
+			assetMetadataByPath[asset.Path].AudioTrackMetadata = append(assetMetadataByPath[asset.Path].AudioTrackMetadata, &models.AudioTrackMetadata{
+				StreamIndex:   track.Index,
+				TrackIndex:    track.Track,
+				Language:      track.Language,
+				Title:         track.Title,
+				Codec:         track.Codec,
+				Channels:      track.Channels,
+				ChannelLayout: track.ChannelLayout,
+				IsDefault:     track.IsDefault,
+			})
+		}
+
+		for _, subtitle := range info.Subtitles {
+			assetMetadataByPath[asset.Path].SubtitleMetadata = append(assetMetadataByPath[asset.Path].SubtitleMetadata, &models.SubtitleMetadata{
+				StreamIndex: subtitle.Index,
+				Language:    subtitle.Language,
+				Title:       subtitle.Title,
+				Codec:       subtitle.Codec,
+				IsDefault:   subtitle.IsDefault,
+				IsForced:    subtitle.IsForced,
+			})
+		}
+
 		if len(keyframes) > 0 {
 			extractedKeyframes[asset.Path] = keyframes
 		}
@@ -953,9 +1377,9 @@ func probeVideos(ctx context.Context, s *CourseScan, ops []Op, course *models.Co
 		s.logger.Info().
 			Str("course_id", course.ID).
 			Str("course_path", course.Path).
-			Int("videos_processed", len(assetMetadataByPath)).
-			Int("total_videos", totalVideos).
-			Msg("Completed video probing and keyframe extraction")
+			Int("media_processed", len(assetMetadataByPath)).
+			Int("total_media", totalMedia).
+			Msg("Completed media probing and keyframe extraction")
 	}
 
 	return assetMetadataByPath
@@ -963,6 +1387,24 @@ func probeVideos(ctx context.Context, s *CourseScan, ops []Op, course *models.Co
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// chapterMetadata converts the probed chapters of a media file to chapter metadata
+func chapterMetadata(chapters []probe.Chapter) []*models.ChapterMetadata {
+	var out []*models.ChapterMetadata
+
+	for _, chapter := range chapters {
+		out = append(out, &models.ChapterMetadata{
+			ChapterIndex: chapter.Index,
+			StartSec:     chapter.StartSec,
+			EndSec:       chapter.EndSec,
+			Title:        chapter.Title,
+		})
+	}
+
+	return out
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // isCancellationError checks if an error is related to cancellation
 func isCancellationError(err error) bool {
 	if err == nil {
@@ -1060,9 +1502,7 @@ func applyLessonDeleteOps(
 		case DeleteLessonOp:
 			// Subtract durations of all video assets in the deleted lesson
 			for _, asset := range v.Deleted.Assets {
-				if asset.AssetMetadata != nil && asset.AssetMetadata.VideoMetadata != nil {
-					course.Duration -= asset.AssetMetadata.VideoMetadata.DurationSec
-				}
+				course.Duration -= asset.AssetMetadata.DurationSec()
 			}
 
 			// Delete an existing lesson
@@ -1100,7 +1540,7 @@ func applyAssetOps(
 				return false, err
 			}
 
-			if metadata := assetMetadataByPath[v.New.Path]; metadata != nil && v.New.Type.IsVideo() {
+			if metadata := assetMetadataByPath[v.New.Path]; metadata != nil && v.New.Type.IsMedia() {
 				metadata.AssetID = v.New.ID
 
 				if err := s.dao.CreateAssetMetadata(ctx, metadata); err != nil {
@@ -1129,7 +1569,7 @@ func applyAssetOps(
 					delete(extractedKeyframes, v.New.Path)
 				}
 
-				course.Duration += metadata.VideoMetadata.DurationSec
+				course.Duration += metadata.DurationSec()
 			}
 
 		case UpdateAssetOp:
@@ -1166,23 +1606,21 @@ func applyAssetOps(
 				return false, err
 			}
 
-			if v.Existing.AssetMetadata != nil && v.Existing.AssetMetadata.VideoMetadata != nil {
-				course.Duration -= v.Existing.AssetMetadata.VideoMetadata.DurationSec
-			}
+			course.Duration -= v.Existing.AssetMetadata.DurationSec()
 
 			v.New.LessonID = v.Existing.LessonID
 			if err := s.dao.CreateAsset(ctx, v.New); err != nil {
 				return false, err
 			}
 
-			if metadata := assetMetadataByPath[v.New.Path]; metadata != nil && v.New.Type.IsVideo() {
+			if metadata := assetMetadataByPath[v.New.Path]; metadata != nil && v.New.Type.IsMedia() {
 				metadata.AssetID = v.New.ID
 
 				if err := s.dao.CreateAssetMetadata(ctx, metadata); err != nil {
 					return false, err
 				}
 
-				course.Duration += metadata.VideoMetadata.DurationSec
+				course.Duration += metadata.DurationSec()
 			}
 
 		case OverwriteAssetOp:
@@ -1200,14 +1638,10 @@ func applyAssetOps(
 			}
 
 			// Subtract the deleted asset's duration if it was a video
-			if v.Deleted.AssetMetadata != nil && v.Deleted.AssetMetadata.VideoMetadata != nil {
-				course.Duration -= v.Deleted.AssetMetadata.VideoMetadata.DurationSec
-			}
+			course.Duration -= v.Deleted.AssetMetadata.DurationSec()
 
 			// Subtract the existing asset's duration if it was a video (we're replacing it)
-			if v.Existing.AssetMetadata != nil && v.Existing.AssetMetadata.VideoMetadata != nil {
-				course.Duration -= v.Existing.AssetMetadata.VideoMetadata.DurationSec
-			}
+			course.Duration -= v.Existing.AssetMetadata.DurationSec()
 
 			v.Renamed.ID = v.Existing.ID
 			v.Renamed.LessonID = v.Deleted.LessonID
@@ -1216,7 +1650,7 @@ func applyAssetOps(
 				return false, err
 			}
 
-			if metadata := assetMetadataByPath[v.Renamed.Path]; metadata != nil && v.Renamed.Type.IsVideo() {
+			if metadata := assetMetadataByPath[v.Renamed.Path]; metadata != nil && v.Renamed.Type.IsMedia() {
 				metadata.AssetID = v.Renamed.ID
 
 				if err := s.dao.CreateAssetMetadata(ctx, metadata); err != nil {
@@ -1244,7 +1678,7 @@ func applyAssetOps(
 					delete(extractedKeyframes, v.Renamed.Path)
 				}
 
-				course.Duration += metadata.VideoMetadata.DurationSec
+				course.Duration += metadata.DurationSec()
 			}
 
 		case SwapAssetOp:
@@ -1260,9 +1694,7 @@ func applyAssetOps(
 					return false, err
 				}
 
-				if existing.AssetMetadata != nil && existing.AssetMetadata.VideoMetadata != nil {
-					course.Duration -= existing.AssetMetadata.VideoMetadata.DurationSec
-				}
+				course.Duration -= existing.AssetMetadata.DurationSec()
 			}
 
 			// Swap the new lesson IDs
@@ -1274,14 +1706,14 @@ func applyAssetOps(
 					return false, err
 				}
 
-				if metadata := assetMetadataByPath[newAsset.Path]; metadata != nil && newAsset.Type.IsVideo() {
+				if metadata := assetMetadataByPath[newAsset.Path]; metadata != nil && newAsset.Type.IsMedia() {
 					metadata.AssetID = newAsset.ID
 
 					if err := s.dao.CreateAssetMetadata(ctx, metadata); err != nil {
 						return false, err
 					}
 
-					course.Duration += metadata.VideoMetadata.DurationSec
+					course.Duration += metadata.DurationSec()
 				}
 			}
 
@@ -1292,9 +1724,7 @@ func applyAssetOps(
 				return false, err
 			}
 
-			if v.Deleted.AssetMetadata != nil && v.Deleted.AssetMetadata.VideoMetadata != nil {
-				course.Duration -= v.Deleted.AssetMetadata.VideoMetadata.DurationSec
-			}
+			course.Duration -= v.Deleted.AssetMetadata.DurationSec()
 		}
 	}
 
@@ -1303,7 +1733,129 @@ func applyAssetOps(
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// recalculateCourseDuration recalculates the course duration by summing all video asset durations
+// applyReprobedMetadata replaces the metadata of existing assets that were probed again. Assets
+// that still could not be probed are left alone
+func applyReprobedMetadata(
+	ctx context.Context,
+	s *CourseScan,
+	course *models.Course,
+	assets []*models.Asset,
+	assetMetadataByPath map[string]*models.AssetMetadata,
+	extractedKeyframes map[string][]float64,
+) (bool, error) {
+	updated := false
+
+	for _, asset := range assets {
+		metadata := assetMetadataByPath[asset.Path]
+		if metadata == nil {
+			continue
+		}
+
+		if err := s.dao.DeleteAssetMetadataByAssetIDs(ctx, asset.ID); err != nil {
+			return false, err
+		}
+
+		metadata.AssetID = asset.ID
+		if err := s.dao.CreateAssetMetadata(ctx, metadata); err != nil {
+			return false, err
+		}
+
+		if keyframes := extractedKeyframes[asset.Path]; len(keyframes) > 0 {
+			assetKeyframes := &models.AssetKeyframes{
+				AssetID:    asset.ID,
+				Keyframes:  keyframes,
+				IsComplete: true,
+			}
+
+			if err := s.dao.UpsertAssetKeyframes(ctx, assetKeyframes); err != nil {
+				s.logger.Error().
+					Err(err).
+					Str("course_id", course.ID).
+					Str("course_path", course.Path).
+					Str("asset_id", asset.ID).
+					Str("asset_path", asset.Path).
+					Msg("Failed to store keyframes for probed asset")
+			}
+		}
+
+		delete(extractedKeyframes, asset.Path)
+		updated = true
+	}
+
+	return updated, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// removedAssets returns the existing assets the operations delete or replace
+func removedAssets(ops []Op) []*models.Asset {
+	var out []*models.Asset
+	for _, op := range ops {
+		switch v := op.(type) {
+		case ReplaceAssetOp:
+			out = append(out, v.Existing)
+		case OverwriteAssetOp:
+			out = append(out, v.Deleted)
+		case SwapAssetOp:
+			out = append(out, v.ExistingA, v.ExistingB)
+		case DeleteAssetOp:
+			out = append(out, v.Deleted)
+		}
+	}
+
+	return out
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// deleteThumbnails deletes the thumbnails of an asset that was deleted or replaced. Failing to
+// delete them is logged but does not fail the scan
+func deleteThumbnails(s *CourseScan, course *models.Course, asset *models.Asset) {
+	if s.thumbnails == nil || !asset.Type.IsVideo() {
+		return
+	}
+
+	if err := s.thumbnails.Delete(asset.ID); err != nil {
+		s.logger.Warn().
+			Err(err).
+			Str("course_id", course.ID).
+			Str("course_path", course.Path).
+			Str("asset_id", asset.ID).
+			Str("asset_path", asset.Path).
+			Msg("Failed to delete thumbnails for asset")
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// queueThumbnails queues the video assets of a course to have their thumbnails generated.
+// Assets that already have thumbnails are skipped by the thumbnails worker
+func queueThumbnails(ctx context.Context, s *CourseScan, courseID string) error {
+	if s.thumbnails == nil {
+		return nil
+	}
+
+	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{
+		models.ASSET_TABLE_COURSE_ID: courseID,
+		models.ASSET_TABLE_TYPE:      types.AssetVideo,
+	})
+
+	assets, err := s.dao.ListAssets(ctx, dbOpts)
+	if err != nil {
+		return err
+	}
+
+	for _, asset := range assets {
+		s.thumbnails.Add(asset.ID)
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// recalculateCourseDuration recalculates the course duration by summing all video and audio asset
+// durations
 func recalculateCourseDuration(ctx context.Context, s *CourseScan, courseID string) (int, error) {
 	dbOpts := dao.NewOptions().
 		WithWhere(squirrel.Eq{models.ASSET_COURSE_ID: courseID}).
@@ -1316,9 +1868,7 @@ func recalculateCourseDuration(ctx context.Context, s *CourseScan, courseID stri
 
 	totalDuration := 0
 	for _, asset := range assets {
-		if asset.AssetMetadata != nil && asset.AssetMetadata.VideoMetadata != nil {
-			totalDuration += asset.AssetMetadata.VideoMetadata.DurationSec
-		}
+		totalDuration += asset.AssetMetadata.DurationSec()
 	}
 
 	return totalDuration, nil
@@ -1360,6 +1910,7 @@ func applyAttachmentOps(
 // A priority list for assets when picking a single asset
 var assetPriority = []types.AssetType{
 	types.AssetVideo,
+	types.AssetAudio,
 	types.AssetPDF,
 	types.AssetMarkdown,
 	types.AssetText,
@@ -1398,8 +1949,27 @@ const (
 	Asset
 	GroupedAsset
 	Attachment
+	Subtitle
 )
 
+// String returns the name of the category
+func (c FileCategory) String() string {
+	switch c {
+	case Card:
+		return "card"
+	case Asset:
+		return "asset"
+	case GroupedAsset:
+		return "groupedAsset"
+	case Attachment:
+		return "attachment"
+	case Subtitle:
+		return "subtitle"
+	default:
+		return "ignored"
+	}
+}
+
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // categorizeFile inspects a parsedFile and tells you which it is
@@ -1414,6 +1984,11 @@ func categorizeFile(p *parsedFile) FileCategory {
 		return Card
 	}
 
+	// Subtitle
+	if isSubtitle(p) {
+		return Subtitle
+	}
+
 	// Asset || grouped asset
 	if p.AssetType.IsValid() && p.Title != "" {
 		if p.SubPrefix != nil {
@@ -1568,6 +2143,14 @@ func populateHashesParallel(ctx context.Context, s *CourseScan, assets []*models
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// HashFile computes the hash of a file in the same way as the scanner does for `Asset.Hash`, so
+// a file can be matched against an existing asset
+func HashFile(fs afero.Fs, path string) (string, error) {
+	return hashFilePartial(fs, path, 1024*1024)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // hashFilePartial computes the SHA-256 hash of a file by reading it in chunks
 func hashFilePartial(fs afero.Fs, path string, chunkSize int64) (string, error) {
 	file, err := fs.Open(path)
@@ -1735,15 +2318,7 @@ func parseFilename(normalizedPath, filename string) *parsedFile {
 	title := strings.TrimLeft(strings.TrimSpace(matches[filenameRegex.SubexpIndex("Title")]), " -")
 
 	// Ext
-	ext := strings.ToLower(matches[filenameRegex.SubexpIndex("Ext")])
-
-	// Asset type
-	var assetType types.AssetType
-	if ext != "" {
-		if at, err := types.NewAsset(ext); err == nil {
-			assetType = at
-		}
-	}
+	ext := matches[filenameRegex.SubexpIndex("Ext")]
 
 	var subPrefix *int
 	if sp := matches[filenameRegex.SubexpIndex("SubPrefix")]; sp != "" {
@@ -1754,6 +2329,23 @@ func parseFilename(normalizedPath, filename string) *parsedFile {
 
 	subTitle := strings.Trim(strings.TrimSpace(matches[filenameRegex.SubexpIndex("SubTitle")]), " -")
 
+	return newParsedFile(normalizedPath, filename, prefix, title, subPrefix, subTitle, ext)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// newParsedFile creates a parsedFile from the parts of a filename, setting the asset type from
+// the extension
+func newParsedFile(normalizedPath, filename string, prefix int, title string, subPrefix *int, subTitle, ext string) *parsedFile {
+	ext = strings.ToLower(ext)
+
+	var assetType types.AssetType
+	if ext != "" {
+		if at, err := types.NewAsset(ext); err == nil {
+			assetType = at
+		}
+	}
+
 	return &parsedFile{
 		Prefix:         prefix,
 		Title:          title,
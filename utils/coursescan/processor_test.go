@@ -1,15 +1,23 @@
 package coursescan
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/geerew/off-course/dao"
 	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/coursemetadata"
+	"github.com/geerew/off-course/utils/thumbnails"
 	"github.com/geerew/off-course/utils/types"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
@@ -98,6 +106,7 @@ func TestScanner_Processor(t *testing.T) {
 			record, err := scanner.dao.GetCourse(ctx, dbOpts)
 			require.NoError(t, err)
 			require.Equal(t, filepath.Join(course.Path, "card.jpg"), record.CardPath)
+			require.Equal(t, types.CardSourceFile, record.CardSource)
 		}
 
 		// Ignore card in chapter
@@ -111,6 +120,7 @@ func TestScanner_Processor(t *testing.T) {
 			record, err := scanner.dao.GetCourse(ctx, dbOpts)
 			require.NoError(t, err)
 			require.Empty(t, record.CardPath)
+			require.Equal(t, types.CardSourceNone, record.CardSource)
 		}
 
 		// Ignore additional cards at the root
@@ -127,6 +137,171 @@ func TestScanner_Processor(t *testing.T) {
 		}
 	})
 
+	t.Run("custom card", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1", CardHash: "custom", CardSource: types.CardSourceCustom}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		scanState, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+
+		// A card file does not replace a custom card
+		scanner.appFs.Fs.Mkdir(course.Path, os.ModePerm)
+		scanner.appFs.Fs.Create(filepath.Join(course.Path, "card.jpg"))
+		require.NoError(t, afero.WriteFile(scanner.appFs.Fs, filepath.Join(course.Path, "01 video.mp4"), []byte("video"), os.ModePerm))
+
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		record, err := scanner.dao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID}))
+		require.NoError(t, err)
+		require.Empty(t, record.CardPath)
+		require.Equal(t, "custom", record.CardHash)
+		require.Equal(t, types.CardSourceCustom, record.CardSource)
+	})
+
+	t.Run("thumbnails", func(t *testing.T) {
+		scanner, ctx := setup(t)
+		thumbs := scanner.thumbnails.(*thumbnails.Thumbnails)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		for _, name := range []string{"01 intro.mp4", "02 setup.mp4", "03 notes.pdf"} {
+			require.NoError(t, afero.WriteFile(scanner.appFs.Fs, filepath.Join(course.Path, name), []byte(name), os.ModePerm))
+		}
+
+		scanState, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		// Videos are queued
+		require.Equal(t, 2, thumbs.Pending())
+
+		dbOpts := dao.NewOptions().
+			WithWhere(squirrel.Eq{models.ASSET_TABLE_COURSE_ID: course.ID}).
+			WithOrderBy(models.ASSET_TABLE_PATH + " ASC")
+
+		assets, err := scanner.dao.ListAssets(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Len(t, assets, 3)
+
+		for _, asset := range assets[:2] {
+			require.NoError(t, afero.WriteFile(scanner.appFs.Fs, thumbs.VttPath(asset.ID), []byte("WEBVTT\n"), os.ModePerm))
+		}
+
+		// Thumbnails of deleted and replaced videos are deleted
+		require.NoError(t, scanner.appFs.Fs.Remove(filepath.Join(course.Path, "01 intro.mp4")))
+		require.NoError(t, afero.WriteFile(scanner.appFs.Fs, filepath.Join(course.Path, "02 setup.mp4"), []byte("changed"), os.ModePerm))
+
+		scanState, err = scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		require.False(t, thumbs.Exists(assets[0].ID))
+		require.False(t, thumbs.Exists(assets[1].ID))
+	})
+
+	t.Run("course details", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		require.NoError(t, scanner.appFs.Fs.MkdirAll(course.Path, os.ModePerm))
+		require.NoError(t, afero.WriteFile(scanner.appFs.Fs, filepath.Join(course.Path, coursemetadata.DescriptionFileName), []byte("# Description"), os.ModePerm))
+		require.NoError(t, afero.WriteFile(scanner.appFs.Fs, filepath.Join(course.Path, coursemetadata.AuthorFileName), []byte("Jane\nJohn"), os.ModePerm))
+		require.NoError(t, afero.WriteFile(scanner.appFs.Fs, filepath.Join(course.Path, coursemetadata.ReadmeFileName), []byte("# Readme"), os.ModePerm))
+
+		dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID})
+
+		// Sidecars only
+		scanState, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		record, err := scanner.dao.GetCourse(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Equal(t, "Course 1", record.Title)
+		require.Equal(t, "# Description", record.Description)
+		require.Equal(t, models.CourseAuthors{"Jane", "John"}, record.Authors)
+
+		// course.json takes precedence
+		order := 1
+		require.NoError(t, coursemetadata.WriteMetadata(scanner.appFs.Fs, course.Path, &coursemetadata.CourseMetadata{
+			Title:       "Learn Go",
+			Authors:     []string{"Alex"},
+			Language:    "en",
+			Difficulty:  "beginner",
+			ReleaseDate: "2024-01-02",
+			SourceURL:   "https://example.com/go",
+			Modules:     []coursemetadata.ModuleMetadata{{Name: "02 Advanced", Title: "Advanced", Order: &order}},
+		}))
+
+		scanState, err = scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		record, err = scanner.dao.GetCourse(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Equal(t, "Learn Go", record.Title)
+		require.Equal(t, "# Description", record.Description)
+		require.Equal(t, models.CourseAuthors{"Alex"}, record.Authors)
+		require.Equal(t, "en", record.Language)
+		require.Equal(t, "beginner", record.Difficulty)
+		require.Equal(t, "2024-01-02", record.ReleaseDate)
+		require.Equal(t, "https://example.com/go", record.SourceURL)
+		require.Equal(t, models.CourseModules{{Name: "02 Advanced", Title: "Advanced", Order: &order}}, record.Modules)
+
+		// Removing the files clears the details but keeps the title
+		require.NoError(t, scanner.appFs.Fs.Remove(filepath.Join(course.Path, coursemetadata.MetadataFileName)))
+		require.NoError(t, scanner.appFs.Fs.Remove(filepath.Join(course.Path, coursemetadata.DescriptionFileName)))
+		require.NoError(t, scanner.appFs.Fs.Remove(filepath.Join(course.Path, coursemetadata.AuthorFileName)))
+
+		scanState, err = scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		record, err = scanner.dao.GetCourse(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Equal(t, "Learn Go", record.Title)
+		require.Equal(t, "# Readme", record.Description)
+		require.Empty(t, record.Authors)
+		require.Empty(t, record.Language)
+		require.Empty(t, record.Modules)
+	})
+
+	t.Run("invalid course.json", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		require.NoError(t, scanner.appFs.Fs.MkdirAll(course.Path, os.ModePerm))
+		require.NoError(t, coursemetadata.WriteMetadata(scanner.appFs.Fs, course.Path, &coursemetadata.CourseMetadata{
+			Title:    "Learn Go",
+			Language: "en",
+		}))
+
+		scanState, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		// A half saved course.json fails the scan and keeps the stored details
+		require.NoError(t, afero.WriteFile(scanner.appFs.Fs, filepath.Join(course.Path, coursemetadata.MetadataFileName), []byte("{"), os.ModePerm))
+
+		scanState, err = scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.ErrorIs(t, Processor(ctx, scanner, scanState), ErrCourseMetadata)
+
+		dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: course.ID})
+		record, err := scanner.dao.GetCourse(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Equal(t, "Learn Go", record.Title)
+		require.Equal(t, "en", record.Language)
+		require.False(t, record.Maintenance)
+	})
+
 	t.Run("ignore files", func(t *testing.T) {
 		scanner, ctx := setup(t)
 
@@ -470,6 +645,215 @@ func TestScanner_Processor(t *testing.T) {
 		}
 	})
 
+	t.Run("archive", func(t *testing.T) {
+		scanner, ctx := setup(t)
+		scanner.appFs.Fs = appfs.NewArchiveFs(scanner.appFs.Fs, "/archives")
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1.zip"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+		for name, content := range map[string]string{
+			"01 Intro/01 file 1.mkv":         "hash 1",
+			"01 Intro/01 attachment 1.url":   "attachment 1",
+			"02 Setup/01 file 2.md":          "# setup",
+			"02 Setup/01 Extra/01 file 3.md": "# extra",
+		} {
+			f, err := w.Create(name)
+			require.NoError(t, err)
+			_, err = f.Write([]byte(content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+		require.NoError(t, afero.WriteFile(scanner.appFs.Fs, course.Path, buf.Bytes(), os.ModePerm))
+
+		scanState, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+
+		err = Processor(ctx, scanner, scanState)
+		require.NoError(t, err)
+
+		dbOpts := dao.NewOptions().
+			WithOrderBy(models.LESSON_TABLE_MODULE+" asc", models.LESSON_TABLE_PREFIX+" asc").
+			WithWhere(squirrel.Eq{models.LESSON_TABLE_COURSE_ID: course.ID})
+
+		lessons, err := scanner.dao.ListLessons(ctx, dbOpts)
+		require.NoError(t, err)
+		require.Len(t, lessons, 3)
+
+		require.Equal(t, "01 Intro", lessons[0].Module)
+		require.Len(t, lessons[0].Assets, 1)
+		require.Equal(t, filepath.Join(course.Path, "01 Intro", "01 file 1.mkv"), lessons[0].Assets[0].Path)
+		require.Equal(t, "0657190350cbea662b6c15d703d9c7482308e511504d3308306d0f1ede153a34", lessons[0].Assets[0].Hash)
+		require.Len(t, lessons[0].Attachments, 1)
+		require.Equal(t, filepath.Join(course.Path, "01 Intro", "01 attachment 1.url"), lessons[0].Attachments[0].Path)
+
+		require.Equal(t, "02 Setup", lessons[1].Module)
+		require.Len(t, lessons[1].Assets, 1)
+		require.Equal(t, filepath.Join(course.Path, "02 Setup", "01 file 2.md"), lessons[1].Assets[0].Path)
+
+		require.Equal(t, "02 Setup/01 Extra", lessons[2].Module)
+		require.Equal(t, filepath.Join(course.Path, "02 Setup", "01 Extra", "01 file 3.md"), lessons[2].Assets[0].Path)
+	})
+
+	t.Run("nested modules", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		for _, path := range []string{
+			"01 Part One/01 Setup/01 file 1.mkv",
+			"01 Part One/01 Setup/02 file 2.mkv",
+			"01 Part One/02 - Basics/01 file 3.mkv",
+			"Extras/01 file 4.mkv",
+			"01 Part One/01 Setup/01 Deep/01 file 5.mkv",
+			"01 Part One/01 Setup/01 Deep/01 Deeper/01 ignored.mkv",
+		} {
+			require.NoError(t, afero.WriteFile(scanner.appFs.Fs, filepath.Join(course.Path, path), []byte(path), os.ModePerm))
+		}
+
+		scanState, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		lessonOpts := dao.NewOptions().
+			WithOrderBy(models.LESSON_TABLE_MODULE + " asc").
+			WithWhere(squirrel.Eq{models.LESSON_TABLE_COURSE_ID: course.ID})
+
+		lessons, err := scanner.dao.ListLessons(ctx, lessonOpts)
+		require.NoError(t, err)
+		require.Len(t, lessons, 5)
+
+		modules := map[string]bool{}
+		for _, lesson := range lessons {
+			modules[lesson.Module] = true
+		}
+		require.Equal(t, map[string]bool{
+			"01 Part One/01 Setup":         true,
+			"01 Part One/01 Setup/01 Deep": true,
+			"01 Part One/02 - Basics":      true,
+			"Extras":                       true,
+		}, modules)
+
+		moduleOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.MODULE_TABLE_COURSE_ID: course.ID})
+		records, err := scanner.dao.ListModules(ctx, moduleOpts)
+		require.NoError(t, err)
+		require.Len(t, records, 5)
+
+		byPath := map[string]*models.Module{}
+		for _, record := range records {
+			byPath[record.Path] = record
+		}
+
+		partOne := byPath["01 Part One"]
+		require.NotNil(t, partOne)
+		require.False(t, partOne.ParentID.Valid)
+		require.Equal(t, "Part One", partOne.Title)
+		require.Equal(t, int16(1), partOne.Prefix.Int16)
+
+		basics := byPath["01 Part One/02 - Basics"]
+		require.NotNil(t, basics)
+		require.Equal(t, partOne.ID, basics.ParentID.String)
+		require.Equal(t, "Basics", basics.Title)
+		require.Equal(t, int16(2), basics.Prefix.Int16)
+
+		require.Equal(t, byPath["01 Part One/01 Setup"].ID, byPath["01 Part One/01 Setup/01 Deep"].ParentID.String)
+
+		extras := byPath["Extras"]
+		require.NotNil(t, extras)
+		require.Equal(t, "Extras", extras.Title)
+		require.False(t, extras.Prefix.Valid)
+
+		// Removing a module directory removes its module
+		require.NoError(t, scanner.appFs.Fs.RemoveAll(filepath.Join(course.Path, "Extras")))
+
+		scanState, err = scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		records, err = scanner.dao.ListModules(ctx, moduleOpts)
+		require.NoError(t, err)
+		require.Len(t, records, 4)
+		for _, record := range records {
+			require.NotEqual(t, "Extras", record.Path)
+		}
+	})
+
+	t.Run("subtitles", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: "/course-1"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		require.NoError(t, scanner.appFs.Fs.MkdirAll(course.Path, os.ModePerm))
+		for _, file := range []string{
+			"01 - Intro.mp4",
+			"01 - Intro.en.srt",
+			"01 - Intro.FR.vtt",
+			"02 Setup {1 - Install}.mp4",
+			"02 Setup.de {1}.ass",
+			"02 Setup {2}.srt",
+			"03 Orphan.en.srt",
+		} {
+			require.NoError(t, afero.WriteFile(scanner.appFs.Fs, filepath.Join(course.Path, file), []byte(file), os.ModePerm))
+		}
+
+		scanState, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		subtitleOpts := dao.NewOptions().
+			WithWhere(squirrel.Eq{models.ASSET_TABLE_COURSE_ID: course.ID}).
+			WithOrderBy(models.ASSET_SUBTITLE_TABLE_PATH + " asc")
+
+		subs, err := scanner.dao.ListAssetSubtitles(ctx, subtitleOpts)
+		require.NoError(t, err)
+		require.Len(t, subs, 3)
+
+		require.Equal(t, filepath.Join(course.Path, "01 - Intro.FR.vtt"), subs[0].Path)
+		require.Equal(t, "fr", subs[0].Language)
+		require.Equal(t, "vtt", subs[0].Format)
+
+		require.Equal(t, filepath.Join(course.Path, "01 - Intro.en.srt"), subs[1].Path)
+		require.Equal(t, "en", subs[1].Language)
+		require.Equal(t, "srt", subs[1].Format)
+		require.Equal(t, subs[0].AssetID, subs[1].AssetID)
+
+		require.Equal(t, filepath.Join(course.Path, "02 Setup.de {1}.ass"), subs[2].Path)
+		require.Equal(t, "de", subs[2].Language)
+		require.NotEqual(t, subs[0].AssetID, subs[2].AssetID)
+
+		// A subtitle without a matching video is an attachment
+		lessonOpts := dao.NewOptions().
+			WithWhere(squirrel.Eq{models.LESSON_TABLE_COURSE_ID: course.ID}).
+			WithOrderBy(models.LESSON_TABLE_PREFIX + " asc")
+
+		lessons, err := scanner.dao.ListLessons(ctx, lessonOpts)
+		require.NoError(t, err)
+		require.Len(t, lessons, 2)
+		require.Empty(t, lessons[0].Attachments)
+		require.Len(t, lessons[1].Attachments, 1)
+		require.Equal(t, filepath.Join(course.Path, "02 Setup {2}.srt"), lessons[1].Attachments[0].Path)
+
+		_, skipped := scanState.report()
+		require.Len(t, skipped, 1)
+		require.Equal(t, filepath.Join(course.Path, "03 Orphan.en.srt"), skipped[0].Path)
+
+		// Removed subtitles are deleted
+		require.NoError(t, scanner.appFs.Fs.Remove(filepath.Join(course.Path, "01 - Intro.FR.vtt")))
+
+		scanState, err = scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.NoError(t, Processor(ctx, scanner, scanState))
+
+		subs, err = scanner.dao.ListAssetSubtitles(ctx, subtitleOpts)
+		require.NoError(t, err)
+		require.Len(t, subs, 2)
+		require.Equal(t, "en", subs[0].Language)
+	})
+
 	t.Run("asset priority", func(t *testing.T) {
 		scanner, ctx := setup(t)
 
@@ -939,6 +1323,11 @@ func TestScanner_CategorizeFile(t *testing.T) {
 		{"3-file", Attachment},
 		{"6 --- file", Attachment},
 		{"1 - file.exe", Attachment},
+		// Subtitle
+		{"01 - file.en.srt", Subtitle},
+		{"01 file.VTT", Subtitle},
+		{"01 file {1}.ass", Subtitle},
+		{"01.ssa", Subtitle},
 	}
 
 	for _, tt := range tests {
@@ -1012,3 +1401,106 @@ func TestCardExtension_IsValid(t *testing.T) {
 		}
 	})
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_UnprobedAssets(t *testing.T) {
+	existing := []*models.Asset{
+		{Base: models.Base{ID: "probed"}, Type: types.AssetAudio, AssetMetadata: &models.AssetMetadata{AudioMetadata: &models.AudioMetadata{ProbeVersion: mediaProbeVersion}}},
+		{Base: models.Base{ID: "probed-video"}, Type: types.AssetVideo, AssetMetadata: &models.AssetMetadata{VideoMetadata: &models.VideoMetadata{ProbeVersion: mediaProbeVersion}}},
+		{Base: models.Base{ID: "outdated"}, Type: types.AssetVideo, AssetMetadata: &models.AssetMetadata{VideoMetadata: &models.VideoMetadata{}}},
+		{Base: models.Base{ID: "reclassified"}, Type: types.AssetAudio, AssetMetadata: &models.AssetMetadata{}},
+		{Base: models.Base{ID: "no-metadata"}, Type: types.AssetVideo},
+		{Base: models.Base{ID: "audio-only"}, Type: types.AssetVideo, AssetMetadata: &models.AssetMetadata{AudioMetadata: &models.AudioMetadata{}}},
+		{Base: models.Base{ID: "pdf"}, Type: types.AssetPDF},
+		{Base: models.Base{ID: "changed"}, Type: types.AssetVideo},
+	}
+
+	// Changed assets have no ID yet, as they are probed by their op
+	scanned := []*models.Asset{
+		{Base: models.Base{ID: "probed"}},
+		{Base: models.Base{ID: "probed-video"}},
+		{Base: models.Base{ID: "outdated"}},
+		{Base: models.Base{ID: "reclassified"}},
+		{Base: models.Base{ID: "no-metadata"}},
+		{Base: models.Base{ID: "audio-only"}},
+		{Base: models.Base{ID: "pdf"}},
+		{},
+	}
+
+	ids := []string{}
+	for _, asset := range unprobedAssets(scanned, existing) {
+		ids = append(ids, asset.ID)
+	}
+
+	require.Equal(t, []string{"outdated", "reclassified", "no-metadata", "audio-only"}, ids)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_RemovedAssets(t *testing.T) {
+	asset := func(id string) *models.Asset {
+		return &models.Asset{Base: models.Base{ID: id}}
+	}
+
+	ops := []Op{
+		CreateAssetOp{New: asset("created")},
+		UpdateAssetOp{New: asset("updated"), Existing: asset("kept")},
+		ReplaceAssetOp{New: asset("new"), Existing: asset("replaced")},
+		OverwriteAssetOp{Renamed: asset("renamed"), Existing: asset("overwritten"), Deleted: asset("overwrite-deleted")},
+		SwapAssetOp{NewA: asset("new-a"), NewB: asset("new-b"), ExistingA: asset("swapped-a"), ExistingB: asset("swapped-b")},
+		DeleteAssetOp{Deleted: asset("deleted")},
+	}
+
+	ids := []string{}
+	for _, asset := range removedAssets(ops) {
+		ids = append(ids, asset.ID)
+	}
+
+	require.Equal(t, []string{"replaced", "overwrite-deleted", "swapped-a", "swapped-b", "deleted"}, ids)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_RecalculateCourseDuration(t *testing.T) {
+	scanner, ctx := setup(t)
+
+	course := &models.Course{Title: "Course 1", Path: "/course-1"}
+	require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+	lesson := &models.Lesson{CourseID: course.ID, Title: "Lesson 1", Prefix: sql.NullInt16{Int16: 1, Valid: true}}
+	require.NoError(t, scanner.dao.CreateLesson(ctx, lesson))
+
+	createAsset := func(path, hash string, metadata *models.AssetMetadata) {
+		asset := &models.Asset{
+			CourseID: course.ID,
+			LessonID: lesson.ID,
+			Title:    "Lesson 1",
+			Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+			Type:     types.MustAsset(strings.TrimPrefix(filepath.Ext(path), ".")),
+			Path:     path,
+			FileSize: 1024,
+			ModTime:  time.Now().Format(time.RFC3339Nano),
+			Hash:     hash,
+		}
+		require.NoError(t, scanner.dao.CreateAsset(ctx, asset))
+
+		if metadata != nil {
+			metadata.AssetID = asset.ID
+			require.NoError(t, scanner.dao.CreateAssetMetadata(ctx, metadata))
+		}
+	}
+
+	createAsset("/course-1/01 lesson.mp4", "hash-1", &models.AssetMetadata{
+		VideoMetadata: &models.VideoMetadata{DurationSec: 100},
+		AudioMetadata: &models.AudioMetadata{Codec: "aac"},
+	})
+	createAsset("/course-1/01 lesson.mp3", "hash-2", &models.AssetMetadata{
+		AudioMetadata: &models.AudioMetadata{Codec: "mp3", DurationSec: 50},
+	})
+	createAsset("/course-1/01 lesson.pdf", "hash-3", nil)
+
+	duration, err := recalculateCourseDuration(ctx, scanner, course.ID)
+	require.NoError(t, err)
+	require.Equal(t, 150, duration)
+}
@@ -0,0 +1,221 @@
+package coursescan
+
+import (
+	"context"
+
+	"github.com/geerew/off-course/utils/types"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ScanPriority determines the order in which waiting scans are processed. Higher priorities
+// are processed first
+type ScanPriority int
+
+const (
+	// ScanPriorityLow is used for bulk scans, such as those queued by discovery or the watcher
+	ScanPriorityLow ScanPriority = iota
+
+	// ScanPriorityNormal is the default priority
+	ScanPriorityNormal
+
+	// ScanPriorityHigh is used for scans triggered by a user
+	ScanPriorityHigh
+)
+
+// String returns the name of the priority
+func (p ScanPriority) String() string {
+	switch p {
+	case ScanPriorityLow:
+		return "low"
+	case ScanPriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// QueueStatus describes the state of the scan queue
+type QueueStatus struct {
+	Paused     bool
+	Workers    int
+	Processing int
+	Waiting    int
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetQueueStatus returns the current state of the scan queue
+func (s *CourseScan) GetQueueStatus() QueueStatus {
+	status := QueueStatus{
+		Paused:  s.IsPaused(),
+		Workers: s.workers,
+	}
+
+	s.scans.Range(func(scanID string, scanState *ScanState) bool {
+		switch scanState.GetStatus() {
+		case types.ScanStatusWaiting:
+			status.Waiting++
+		case types.ScanStatusProcessing:
+			status.Processing++
+		}
+		return true
+	})
+
+	return status
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Pause stops the worker from starting new scans. Scans that are already processing run to
+// completion
+func (s *CourseScan) Pause() {
+	if !s.paused.Swap(true) {
+		s.logger.Info().Msg("Paused scan queue")
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Resume allows the worker to start new scans
+func (s *CourseScan) Resume() {
+	if s.paused.Swap(false) {
+		s.logger.Info().Msg("Resumed scan queue")
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// IsPaused returns whether the scan queue is paused
+func (s *CourseScan) IsPaused() bool {
+	return s.paused.Load()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// MoveScan moves a waiting scan to the given index within the waiting queue, where 0 is the
+// next scan to be processed. An index past the end of the queue moves the scan to the end.
+//
+// The scan takes the priority of the scan it is placed before (or after, when moved to the
+// end) so that it stays where it was put
+func (s *CourseScan) MoveScan(scanID string, index int) error {
+	if index < 0 {
+		return ErrInvalidQueuePosition
+	}
+
+	s.addMutex.Lock()
+	defer s.addMutex.Unlock()
+
+	scanState, exists := s.scans.Get(scanID)
+	if !exists {
+		return ErrScanNotFound
+	}
+
+	if !scanState.GetStatus().IsWaiting() {
+		return ErrScanNotWaiting
+	}
+
+	queue := []*ScanState{}
+	for _, waiting := range s.waitingScans() {
+		if waiting.ID != scanID {
+			queue = append(queue, waiting)
+		}
+	}
+
+	if index > len(queue) {
+		index = len(queue)
+	}
+
+	priority, _ := scanState.queuePosition()
+	if index < len(queue) {
+		priority = queue[index].GetPriority()
+	} else if len(queue) > 0 {
+		priority = queue[len(queue)-1].GetPriority()
+	}
+
+	queue = append(queue[:index], append([]*ScanState{scanState}, queue[index:]...)...)
+
+	// Renumber the waiting scans. New scans are always given a position after these
+	for i, waiting := range queue {
+		waitingPriority, _ := waiting.queuePosition()
+		if waiting.ID == scanID {
+			waitingPriority = priority
+		}
+
+		waiting.setQueuePosition(waitingPriority, int64(i))
+	}
+
+	if s.sequence < int64(len(queue)) {
+		s.sequence = int64(len(queue))
+	}
+
+	s.logger.Info().
+		Str("course_id", scanState.CourseID).
+		Str("scan_id", scanID).
+		Int("position", index).
+		Msg("Moved scan job")
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CancelWaitingScans cancels and removes all scans that have not started processing. It
+// returns the number of scans that were removed
+func (s *CourseScan) CancelWaitingScans() int {
+	s.addMutex.Lock()
+	defer s.addMutex.Unlock()
+
+	waitingScans := s.waitingScans()
+	for _, scanState := range waitingScans {
+		scanState.Cancel()
+		s.scans.Remove(scanState.ID)
+	}
+
+	if len(waitingScans) > 0 {
+		s.logger.Info().
+			Int("count", len(waitingScans)).
+			Msg("Cancelled waiting scan jobs")
+	}
+
+	return len(waitingScans)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// waitingScans returns the scans that are waiting to be processed, in the order they will be
+// processed
+func (s *CourseScan) waitingScans() []*ScanState {
+	var waitingScans []*ScanState
+	s.scans.Range(func(scanID string, scanState *ScanState) bool {
+		if scanState.GetStatus() == types.ScanStatusWaiting {
+			waitingScans = append(waitingScans, scanState)
+		}
+		return true
+	})
+
+	sortScans(waitingScans)
+
+	return waitingScans
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// acquireProbe blocks until a video probe slot is available or the context is cancelled
+func (s *CourseScan) acquireProbe(ctx context.Context) error {
+	select {
+	case s.probeSlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// releaseProbe releases a video probe slot acquired with acquireProbe
+func (s *CourseScan) releaseProbe() {
+	<-s.probeSlots
+}
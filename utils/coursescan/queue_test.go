@@ -0,0 +1,171 @@
+package coursescan
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func queueScans(t *testing.T, scanner *CourseScan, ctx context.Context, count int) []*ScanState {
+	t.Helper()
+
+	scans := []*ScanState{}
+	for i := range count {
+		course := &models.Course{Title: fmt.Sprintf("course %d", i), Path: fmt.Sprintf("/course-%d", i)}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		scan, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		scans = append(scans, scan)
+	}
+
+	return scans
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func waitingIDs(scanner *CourseScan) []string {
+	ids := []string{}
+	for _, scan := range scanner.waitingScans() {
+		ids = append(ids, scan.ID)
+	}
+	return ids
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_AddWithPriority(t *testing.T) {
+	t.Run("order", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		scans := queueScans(t, scanner, ctx, 2)
+
+		course := &models.Course{Title: "urgent", Path: "/urgent"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		urgent, err := scanner.AddWithPriority(ctx, course.ID, ScanPriorityHigh)
+		require.NoError(t, err)
+		require.Equal(t, ScanPriorityHigh, urgent.GetPriority())
+
+		require.Equal(t, []string{urgent.ID, scans[0].ID, scans[1].ID}, waitingIDs(scanner))
+	})
+
+	t.Run("raise existing", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		scans := queueScans(t, scanner, ctx, 2)
+
+		again, err := scanner.AddWithPriority(ctx, scans[1].CourseID, ScanPriorityHigh)
+		require.NoError(t, err)
+		require.Equal(t, scans[1].ID, again.ID)
+		require.Equal(t, ScanPriorityHigh, again.GetPriority())
+
+		// Never lowered
+		again, err = scanner.AddWithPriority(ctx, scans[1].CourseID, ScanPriorityLow)
+		require.NoError(t, err)
+		require.Equal(t, ScanPriorityHigh, again.GetPriority())
+
+		require.Equal(t, []string{scans[1].ID, scans[0].ID}, waitingIDs(scanner))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_MoveScan(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		scans := queueScans(t, scanner, ctx, 4)
+
+		require.NoError(t, scanner.MoveScan(scans[3].ID, 1))
+		require.Equal(t, []string{scans[0].ID, scans[3].ID, scans[1].ID, scans[2].ID}, waitingIDs(scanner))
+
+		// Past the end
+		require.NoError(t, scanner.MoveScan(scans[0].ID, 10))
+		require.Equal(t, []string{scans[3].ID, scans[1].ID, scans[2].ID, scans[0].ID}, waitingIDs(scanner))
+
+		// New scans are added after the moved scans
+		course := &models.Course{Title: "added", Path: "/added"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		added, err := scanner.Add(ctx, course.ID)
+		require.NoError(t, err)
+		require.Equal(t, added.ID, waitingIDs(scanner)[4])
+	})
+
+	t.Run("takes neighbour priority", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		scans := queueScans(t, scanner, ctx, 2)
+
+		course := &models.Course{Title: "bulk", Path: "/bulk"}
+		require.NoError(t, scanner.dao.CreateCourse(ctx, course))
+
+		bulk, err := scanner.AddWithPriority(ctx, course.ID, ScanPriorityLow)
+		require.NoError(t, err)
+
+		require.NoError(t, scanner.MoveScan(bulk.ID, 0))
+		require.Equal(t, ScanPriorityNormal, bulk.GetPriority())
+		require.Equal(t, []string{bulk.ID, scans[0].ID, scans[1].ID}, waitingIDs(scanner))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		scanner, ctx := setup(t)
+
+		scans := queueScans(t, scanner, ctx, 2)
+
+		require.ErrorIs(t, scanner.MoveScan("invalid", 0), ErrScanNotFound)
+		require.ErrorIs(t, scanner.MoveScan(scans[0].ID, -1), ErrInvalidQueuePosition)
+
+		scans[0].UpdateStatusAndMessage(types.ScanStatusProcessing, "")
+		require.ErrorIs(t, scanner.MoveScan(scans[0].ID, 1), ErrScanNotWaiting)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_CancelWaitingScans(t *testing.T) {
+	scanner, ctx := setup(t)
+
+	scans := queueScans(t, scanner, ctx, 3)
+	scans[0].UpdateStatusAndMessage(types.ScanStatusProcessing, "")
+
+	require.Equal(t, 2, scanner.CancelWaitingScans())
+	require.True(t, scans[1].IsCancelled())
+	require.True(t, scans[2].IsCancelled())
+	require.False(t, scans[0].IsCancelled())
+
+	allScans := scanner.GetAllScans()
+	require.Len(t, allScans, 1)
+	require.Equal(t, scans[0].ID, allScans[0].ID)
+
+	status := scanner.GetQueueStatus()
+	require.Equal(t, 1, status.Processing)
+	require.Zero(t, status.Waiting)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestScanner_AcquireProbe(t *testing.T) {
+	scanner, ctx := setup(t)
+
+	require.Equal(t, DefaultMaxProbes, cap(scanner.probeSlots))
+	for range DefaultMaxProbes {
+		require.NoError(t, scanner.acquireProbe(ctx))
+	}
+
+	// No slot is available until one is released
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, scanner.acquireProbe(cancelledCtx), context.Canceled)
+
+	scanner.releaseProbe()
+	require.NoError(t, scanner.acquireProbe(ctx))
+	scanner.releaseProbe()
+}
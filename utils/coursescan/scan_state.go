@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/geerew/off-course/models"
 	"github.com/geerew/off-course/utils/security"
 	"github.com/geerew/off-course/utils/types"
 )
@@ -24,15 +25,28 @@ type ScanState struct {
 	mu        sync.RWMutex
 	Status    types.ScanStatusType
 	Message   string
+	Priority  ScanPriority
 	cancelled bool
 
+	// Queue position (protected by mu). Waiting scans with the same priority are processed in
+	// ascending order
+	position int64
+
+	// Report (protected by mu), recorded in the scan history once the scan finishes
+	counts  operationCounts
+	skipped models.ScanSkippedFiles
+
+	// Set (protected by mu) when the processor stopped before scanning, such as when the course
+	// was deleted or its path is unavailable. No scan history is recorded for these scans
+	notScanned bool
+
 	// Cancellation
 	cancel context.CancelFunc
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// NewScanState creates a new scan state
+// NewScanState creates a new scan state with normal priority
 func NewScanState(courseID, coursePath, courseTitle string) *ScanState {
 	return &ScanState{
 		ID:          generateScanID(),
@@ -41,6 +55,7 @@ func NewScanState(courseID, coursePath, courseTitle string) *ScanState {
 		CourseTitle: courseTitle,
 		Status:      types.ScanStatusWaiting,
 		Message:     "",
+		Priority:    ScanPriorityNormal,
 		CreatedAt:   time.Now(),
 	}
 }
@@ -114,6 +129,80 @@ func (s *ScanState) GetMessage() string {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// GetPriority returns the scan priority (thread-safe read)
+func (s *ScanState) GetPriority() ScanPriority {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Priority
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// queuePosition returns the priority and position used to order the scan in the queue
+func (s *ScanState) queuePosition() (ScanPriority, int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Priority, s.position
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// setQueuePosition sets the priority and position used to order the scan in the queue
+func (s *ScanState) setQueuePosition(priority ScanPriority, position int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Priority = priority
+	s.position = position
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// setOperationCounts stores the operations applied by the scan
+func (s *ScanState) setOperationCounts(counts operationCounts) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts = counts
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// setSkipped stores the files that were found but not added to the course
+func (s *ScanState) setSkipped(skipped models.ScanSkippedFiles) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipped = skipped
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// setNotScanned marks the scan as having stopped before the course was scanned
+func (s *ScanState) setNotScanned() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notScanned = true
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// isNotScanned returns whether the scan stopped before the course was scanned (thread-safe
+// read)
+func (s *ScanState) isNotScanned() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.notScanned
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// report returns the operation counts and skipped files (thread-safe read)
+func (s *ScanState) report() (operationCounts, models.ScanSkippedFiles) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.counts, s.skipped
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // generateScanID generates a unique scan ID
 func generateScanID() string {
 	return security.PseudorandomString(10)
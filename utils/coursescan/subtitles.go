@@ -0,0 +1,144 @@
+package coursescan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/media/subtitles"
+	"github.com/geerew/off-course/utils/types"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// scannedSubtitle is a subtitle file that was paired with a video asset
+type scannedSubtitle struct {
+	path      string
+	assetPath string
+	language  string
+	format    subtitles.Format
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// isSubtitle returns true when the parsed file is a subtitle file
+func isSubtitle(p *parsedFile) bool {
+	_, ok := subtitles.FormatFromExt(p.Ext)
+	return ok
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// pairSubtitle pairs a subtitle file with the video asset in the lesson that has the same
+// sub-prefix. It returns nil when there is no such asset
+func pairSubtitle(p *parsedFile, assets []*models.Asset) *scannedSubtitle {
+	format, _ := subtitles.FormatFromExt(p.Ext)
+
+	for _, asset := range assets {
+		if asset.Type != types.AssetVideo {
+			continue
+		}
+
+		if asset.SubPrefix.Valid != (p.SubPrefix != nil) {
+			continue
+		}
+
+		if p.SubPrefix != nil && int(asset.SubPrefix.Int16) != *p.SubPrefix {
+			continue
+		}
+
+		// The language is the last part of the name, such as `01 - Intro.en.srt`, or of the
+		// title for grouped files, such as `01 Intro.en {1 - Part}.srt`
+		_, language := subtitles.SplitLanguage(p.Original[:len(p.Original)-len(p.Ext)-1])
+		if language == "" {
+			_, language = subtitles.SplitLanguage(p.Title)
+		}
+
+		return &scannedSubtitle{
+			path:      p.NormalizedPath,
+			assetPath: asset.Path,
+			language:  strings.ToLower(language),
+			format:    format,
+		}
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// applySubtitleOps syncs the subtitles in the database with the scanned subtitles. It must run
+// after the asset operations so that every scanned subtitle has an asset to belong to
+func applySubtitleOps(ctx context.Context, s *CourseScan, courseID string, scanned []*scannedSubtitle) error {
+	assetOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_COURSE_ID: courseID})
+	assets, err := s.dao.ListAssets(ctx, assetOpts)
+	if err != nil {
+		return fmt.Errorf("failed to list assets: %w", err)
+	}
+
+	assetIDs := make(map[string]string, len(assets))
+	for _, asset := range assets {
+		assetIDs[asset.Path] = asset.ID
+	}
+
+	subtitleOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_COURSE_ID: courseID})
+	existing, err := s.dao.ListAssetSubtitles(ctx, subtitleOpts)
+	if err != nil {
+		return fmt.Errorf("failed to list subtitles: %w", err)
+	}
+
+	existingByPath := make(map[string]*models.AssetSubtitle, len(existing))
+	for _, subtitle := range existing {
+		existingByPath[subtitle.Path] = subtitle
+	}
+
+	for _, sub := range scanned {
+		assetID, ok := assetIDs[sub.assetPath]
+		if !ok {
+			continue
+		}
+
+		if subtitle, ok := existingByPath[sub.path]; ok {
+			delete(existingByPath, sub.path)
+
+			if subtitle.AssetID == assetID && subtitle.Language == sub.language && subtitle.Format == string(sub.format) {
+				continue
+			}
+
+			subtitle.AssetID = assetID
+			subtitle.Language = sub.language
+			subtitle.Format = string(sub.format)
+
+			if err := s.dao.UpdateAssetSubtitle(ctx, subtitle); err != nil {
+				return fmt.Errorf("failed to update subtitle %s: %w", sub.path, err)
+			}
+
+			continue
+		}
+
+		subtitle := &models.AssetSubtitle{
+			AssetID:  assetID,
+			Path:     sub.path,
+			Language: sub.language,
+			Format:   string(sub.format),
+		}
+
+		if err := s.dao.CreateAssetSubtitle(ctx, subtitle); err != nil {
+			return fmt.Errorf("failed to create subtitle %s: %w", sub.path, err)
+		}
+	}
+
+	if len(existingByPath) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(existingByPath))
+	for _, subtitle := range existingByPath {
+		ids = append(ids, subtitle.ID)
+	}
+
+	return s.dao.DeleteAssetSubtitles(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.BASE_ID: ids}))
+}
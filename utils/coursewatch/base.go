@@ -0,0 +1,499 @@
+package coursewatch
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/fsnotify/fsnotify"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/coursemetadata"
+	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	// defaultDebounce is how long to wait after the last event for a course before a scan is
+	// queued
+	defaultDebounce = 5 * time.Second
+
+	// syncInterval is how often the watched courses are reconciled against the database
+	syncInterval = 5 * time.Minute
+
+	// watchDepth is how many directory levels below the course root are watched. This matches
+	// the depth the scanner reads (course root + module directories)
+	watchDepth = coursescan.MaxModuleDepth
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CourseWatch watches course directories for changes and queues a scan for the course when
+// its contents change
+type CourseWatch struct {
+	appFs      *appfs.AppFs
+	dao        *dao.DAO
+	logger     *logger.Logger
+	courseScan *coursescan.CourseScan
+	debounce   time.Duration
+
+	watcher *fsnotify.Watcher
+
+	// courses maps a course ID to the course path being watched
+	courses map[string]string
+
+	// dirs maps a watched directory to the course ID it belongs to
+	dirs map[string]string
+
+	// timers holds the pending debounce timer for each course
+	timers map[string]*time.Timer
+
+	// limitReached is set when the OS watch limit is hit, so the warning is only logged once
+	limitReached bool
+
+	lock sync.Mutex
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CourseWatchConfig is the config for a CourseWatch
+type CourseWatchConfig struct {
+	Db         database.Database
+	AppFs      *appfs.AppFs
+	Logger     *logger.Logger
+	CourseScan *coursescan.CourseScan
+
+	// How long to wait for events to settle before queuing a scan. Defaults to 5 seconds
+	Debounce time.Duration
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// New creates a new CourseWatch
+func New(config *CourseWatchConfig) *CourseWatch {
+	debounce := config.Debounce
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
+
+	return &CourseWatch{
+		appFs:      config.AppFs,
+		dao:        dao.New(config.Db),
+		logger:     config.Logger,
+		courseScan: config.CourseScan,
+		debounce:   debounce,
+		courses:    make(map[string]string),
+		dirs:       make(map[string]string),
+		timers:     make(map[string]*time.Timer),
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Start creates the underlying watcher, watches all courses that have watching enabled and
+// starts processing events in the background. Processing stops when the context is cancelled
+func (w *CourseWatch) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	w.watcher = watcher
+	w.lock.Unlock()
+
+	if err := w.Sync(ctx); err != nil {
+		w.logger.Error().Err(err).Msg("Failed to sync watched courses")
+	}
+
+	go w.run(ctx)
+
+	w.logger.Debug().Msg("Started course watcher")
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Sync reconciles the watched courses with the database. Courses that have watching enabled
+// are watched (if not already) and courses that were deleted or had watching disabled are
+// unwatched
+func (w *CourseWatch) Sync(ctx context.Context) error {
+	dbOpts := dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_WATCH: true})
+	courses, err := w.dao.ListCourses(ctx, dbOpts)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]string, len(courses))
+	for _, course := range courses {
+		wanted[course.ID] = filepath.Clean(utils.NormalizeWindowsDrive(course.Path))
+	}
+
+	w.lock.Lock()
+	var stale []string
+	for courseID, path := range w.courses {
+		if wantedPath, ok := wanted[courseID]; !ok || wantedPath != path {
+			stale = append(stale, courseID)
+		}
+	}
+	w.lock.Unlock()
+
+	for _, courseID := range stale {
+		w.Unwatch(courseID)
+	}
+
+	for courseID, path := range wanted {
+		if w.IsWatching(courseID) {
+			continue
+		}
+
+		if err := w.Watch(courseID, path); err != nil {
+			w.logger.Debug().
+				Err(err).
+				Str("course_id", courseID).
+				Str("course_path", path).
+				Msg("Unable to watch course")
+		}
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Watch starts watching a course directory, along with its module directories. If the course
+// is already watched at a different path, the old path is unwatched first
+//
+// When the OS watch limit is reached, the partial watch is rolled back and utils.ErrWatchLimit
+// is returned
+func (w *CourseWatch) Watch(courseID, path string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.watcher == nil {
+		return nil
+	}
+
+	path = filepath.Clean(utils.NormalizeWindowsDrive(path))
+
+	if existing, ok := w.courses[courseID]; ok {
+		if existing == path {
+			return nil
+		}
+
+		w.unwatchLocked(courseID)
+	}
+
+	if exists, err := appfs.DirOrArchiveExists(w.appFs.Fs, path); err != nil || !exists {
+		return utils.ErrWatchPath
+	}
+
+	w.courses[courseID] = path
+
+	if err := w.addDirLocked(courseID, path, 0); err != nil {
+		w.unwatchLocked(courseID)
+		return err
+	}
+
+	w.limitReached = false
+
+	w.logger.Debug().
+		Str("course_id", courseID).
+		Str("course_path", path).
+		Msg("Watching course")
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Unwatch stops watching a course and drops any pending scan for it
+func (w *CourseWatch) Unwatch(courseID string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.unwatchLocked(courseID)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// IsWatching returns true when the course is currently being watched
+func (w *CourseWatch) IsWatching(courseID string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	_, ok := w.courses[courseID]
+	return ok
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// run processes watcher events until the context is cancelled
+func (w *CourseWatch) run(ctx context.Context) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.close()
+			w.logger.Debug().Msg("Course watcher stopped")
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+
+			w.handleEvent(ctx, event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			// When the event queue overflows, events were dropped so every course is rescanned
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				w.logger.Warn().Err(err).Msg("Watch event queue overflowed, queuing a scan for all watched courses")
+
+				w.lock.Lock()
+				for courseID := range w.courses {
+					w.scheduleLocked(ctx, courseID)
+				}
+				w.lock.Unlock()
+
+				continue
+			}
+
+			w.logger.Error().Err(err).Msg("Course watcher error")
+		case <-ticker.C:
+			if err := w.Sync(ctx); err != nil {
+				w.logger.Error().Err(err).Msg("Failed to sync watched courses")
+			}
+		}
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// handleEvent maps an event to its course and schedules a scan. New directories within the
+// watch depth are added to the watcher
+func (w *CourseWatch) handleEvent(ctx context.Context, event fsnotify.Event) {
+	// Permission changes do not affect what the scanner finds
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+
+	// The temporary file course.json is written through only holds a change once renamed into
+	// place. Changes to course.json and its sidecar files are picked up like any other file
+	if filepath.Base(event.Name) == coursemetadata.MetadataFileName+".tmp" {
+		return
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	dir := filepath.Dir(event.Name)
+	courseID, ok := w.dirs[dir]
+	if !ok {
+		// The event may be for a watched directory itself (e.g. the course root was removed)
+		if courseID, ok = w.dirs[event.Name]; !ok {
+			return
+		}
+	}
+
+	// Watches are removed by the OS when a directory is removed or renamed
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		if _, watched := w.dirs[event.Name]; watched {
+			delete(w.dirs, event.Name)
+		}
+	}
+
+	if event.Has(fsnotify.Create) {
+		if isDir, _ := afero.IsDir(w.appFs.Fs, event.Name); isDir {
+			if depth := w.depthLocked(courseID, event.Name); depth >= 0 && depth <= watchDepth {
+				if err := w.addDirLocked(courseID, event.Name, depth); err != nil {
+					w.logger.Debug().
+						Err(err).
+						Str("course_id", courseID).
+						Str("path", event.Name).
+						Msg("Unable to watch new directory")
+				}
+			}
+		}
+	}
+
+	w.logger.Debug().
+		Str("course_id", courseID).
+		Str("path", event.Name).
+		Str("op", event.Op.String()).
+		Msg("Course change detected")
+
+	w.scheduleLocked(ctx, courseID)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// scheduleLocked (re)starts the debounce timer for a course. When the timer fires a scan is
+// queued, unless the course is currently being processed, in which case the timer is restarted
+// so changes made during the scan are not missed
+//
+// The lock must be held by the caller
+func (w *CourseWatch) scheduleLocked(ctx context.Context, courseID string) {
+	if timer, ok := w.timers[courseID]; ok {
+		timer.Reset(w.debounce)
+		return
+	}
+
+	w.timers[courseID] = time.AfterFunc(w.debounce, func() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		w.lock.Lock()
+		if _, ok := w.courses[courseID]; !ok {
+			delete(w.timers, courseID)
+			w.lock.Unlock()
+			return
+		}
+
+		if scan := w.courseScan.GetScanByCourseID(courseID); scan != nil && scan.GetStatus().IsProcessing() {
+			w.timers[courseID].Reset(w.debounce)
+			w.lock.Unlock()
+			return
+		}
+
+		delete(w.timers, courseID)
+		w.lock.Unlock()
+
+		if _, err := w.courseScan.AddWithPriority(ctx, courseID, coursescan.ScanPriorityLow); err != nil {
+			w.logger.Error().
+				Err(err).
+				Str("course_id", courseID).
+				Msg("Failed to queue scan for changed course")
+		}
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// addDirLocked adds a directory to the watcher and recurses into sub-directories until the
+// watch depth is reached
+//
+// The lock must be held by the caller
+func (w *CourseWatch) addDirLocked(courseID, path string, depth int) error {
+	if err := w.watcher.Add(path); err != nil {
+		if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE) {
+			if !w.limitReached {
+				w.logger.Warn().
+					Err(err).
+					Str("course_id", courseID).
+					Str("path", path).
+					Msg("Filesystem watch limit reached. Remaining courses will not be watched. On Linux, increase fs.inotify.max_user_watches")
+			}
+
+			w.limitReached = true
+			return utils.ErrWatchLimit
+		}
+
+		return err
+	}
+
+	w.dirs[path] = courseID
+
+	// An archive is watched as a single file, as its members cannot be watched
+	if depth >= watchDepth || appfs.IsArchive(path) {
+		return nil
+	}
+
+	items, err := afero.ReadDir(w.appFs.Fs, path)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if !item.IsDir() {
+			continue
+		}
+
+		if err := w.addDirLocked(courseID, filepath.Join(path, item.Name()), depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// unwatchLocked removes all watched directories for a course and stops its debounce timer
+//
+// The lock must be held by the caller
+func (w *CourseWatch) unwatchLocked(courseID string) {
+	for dir, id := range w.dirs {
+		if id != courseID {
+			continue
+		}
+
+		_ = w.watcher.Remove(dir)
+		delete(w.dirs, dir)
+	}
+
+	if timer, ok := w.timers[courseID]; ok {
+		timer.Stop()
+		delete(w.timers, courseID)
+	}
+
+	delete(w.courses, courseID)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// depthLocked returns how many levels below the course root a path is, or -1 when the path is
+// not within the course
+//
+// The lock must be held by the caller
+func (w *CourseWatch) depthLocked(courseID, path string) int {
+	root, ok := w.courses[courseID]
+	if !ok {
+		return -1
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return -1
+	}
+
+	if rel == "." {
+		return 0
+	}
+
+	return len(strings.Split(rel, string(filepath.Separator)))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// close stops all pending timers and closes the underlying watcher
+func (w *CourseWatch) close() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for courseID, timer := range w.timers {
+		timer.Stop()
+		delete(w.timers, courseID)
+	}
+
+	if w.watcher != nil {
+		_ = w.watcher.Close()
+	}
+}
@@ -0,0 +1,277 @@
+package coursewatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/coursescan"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func setup(t *testing.T) (*CourseWatch, context.Context) {
+	t.Helper()
+
+	testLogger := logger.NilLogger()
+
+	// The watcher relies on OS level notifications so a real filesystem is required
+	appFs := appfs.New(afero.NewOsFs())
+
+	dbManager, err := database.NewSQLiteManager(&database.DatabaseManagerConfig{
+		DataDir: t.TempDir(),
+		AppFs:   appFs,
+		Testing: true,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, dbManager)
+
+	courseScan := coursescan.New(&coursescan.CourseScanConfig{
+		Db:     dbManager.DataDb,
+		AppFs:  appFs,
+		Logger: testLogger.WithCourseScan(),
+	})
+
+	courseWatch := New(&CourseWatchConfig{
+		Db:         dbManager.DataDb,
+		AppFs:      appFs,
+		Logger:     testLogger.WithCourseWatch(),
+		CourseScan: courseScan,
+		Debounce:   50 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return courseWatch, ctx
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func createCourse(t *testing.T, w *CourseWatch, ctx context.Context, watch bool) *models.Course {
+	t.Helper()
+
+	coursePath := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(coursePath, "01 Module"), os.ModePerm))
+
+	course := &models.Course{Title: "Course 1", Path: coursePath, Watch: watch}
+	require.NoError(t, w.dao.CreateCourse(ctx, course))
+
+	return course
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourseWatch_Start(t *testing.T) {
+	t.Run("watches enabled courses", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		watched := createCourse(t, w, ctx, true)
+		unwatched := createCourse(t, w, ctx, false)
+
+		require.NoError(t, w.Start(ctx))
+
+		require.True(t, w.IsWatching(watched.ID))
+		require.False(t, w.IsWatching(unwatched.ID))
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := &models.Course{Title: "Course 1", Path: filepath.Join(t.TempDir(), "missing"), Watch: true}
+		require.NoError(t, w.dao.CreateCourse(ctx, course))
+
+		require.NoError(t, w.Start(ctx))
+		require.False(t, w.IsWatching(course.ID))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourseWatch_Events(t *testing.T) {
+	t.Run("root file", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := createCourse(t, w, ctx, true)
+		require.NoError(t, w.Start(ctx))
+
+		require.NoError(t, os.WriteFile(filepath.Join(course.Path, "01 video.mp4"), []byte("video"), 0644))
+
+		require.Eventually(t, func() bool {
+			return w.courseScan.GetScanByCourseID(course.ID) != nil
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("module file", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := createCourse(t, w, ctx, true)
+		require.NoError(t, w.Start(ctx))
+
+		require.NoError(t, os.WriteFile(filepath.Join(course.Path, "01 Module", "01 video.mp4"), []byte("video"), 0644))
+
+		require.Eventually(t, func() bool {
+			return w.courseScan.GetScanByCourseID(course.ID) != nil
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("new module", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := createCourse(t, w, ctx, true)
+		require.NoError(t, w.Start(ctx))
+
+		modulePath := filepath.Join(course.Path, "02 Module")
+		require.NoError(t, os.Mkdir(modulePath, os.ModePerm))
+
+		// The new directory is added to the watcher
+		require.Eventually(t, func() bool {
+			w.lock.Lock()
+			defer w.lock.Unlock()
+			_, ok := w.dirs[modulePath]
+			return ok
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("debounced", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := createCourse(t, w, ctx, true)
+		require.NoError(t, w.Start(ctx))
+
+		for i := range 5 {
+			require.NoError(t, os.WriteFile(filepath.Join(course.Path, "file.txt"), []byte{byte(i)}, 0644))
+		}
+
+		require.Eventually(t, func() bool {
+			return w.courseScan.GetScanByCourseID(course.ID) != nil
+		}, 2*time.Second, 20*time.Millisecond)
+
+		require.Len(t, w.courseScan.GetAllScans(), 1)
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		for _, name := range []string{"course.json", "description.md", "README.md", "author.txt"} {
+			t.Run(name, func(t *testing.T) {
+				w, ctx := setup(t)
+
+				course := createCourse(t, w, ctx, true)
+				require.NoError(t, w.Start(ctx))
+
+				require.NoError(t, os.WriteFile(filepath.Join(course.Path, name), []byte("{}"), 0644))
+
+				require.Eventually(t, func() bool {
+					return w.courseScan.GetScanByCourseID(course.ID) != nil
+				}, 2*time.Second, 20*time.Millisecond)
+			})
+		}
+	})
+
+	t.Run("ignore metadata temp file", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := createCourse(t, w, ctx, true)
+		require.NoError(t, w.Start(ctx))
+
+		require.NoError(t, os.WriteFile(filepath.Join(course.Path, "course.json.tmp"), []byte("{}"), 0644))
+
+		time.Sleep(200 * time.Millisecond)
+		require.Nil(t, w.courseScan.GetScanByCourseID(course.ID))
+	})
+
+	t.Run("unwatched", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := createCourse(t, w, ctx, true)
+		require.NoError(t, w.Start(ctx))
+
+		w.Unwatch(course.ID)
+		require.False(t, w.IsWatching(course.ID))
+
+		require.NoError(t, os.WriteFile(filepath.Join(course.Path, "01 video.mp4"), []byte("video"), 0644))
+
+		time.Sleep(200 * time.Millisecond)
+		require.Nil(t, w.courseScan.GetScanByCourseID(course.ID))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourseWatch_Watch(t *testing.T) {
+	t.Run("not started", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := createCourse(t, w, ctx, true)
+
+		require.NoError(t, w.Watch(course.ID, course.Path))
+		require.False(t, w.IsWatching(course.ID))
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		w, ctx := setup(t)
+		require.NoError(t, w.Start(ctx))
+
+		err := w.Watch("1234", filepath.Join(t.TempDir(), "missing"))
+		require.ErrorIs(t, err, utils.ErrWatchPath)
+		require.False(t, w.IsWatching("1234"))
+	})
+
+	t.Run("path changed", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := createCourse(t, w, ctx, true)
+		require.NoError(t, w.Start(ctx))
+		require.True(t, w.IsWatching(course.ID))
+
+		newPath := t.TempDir()
+		require.NoError(t, w.Watch(course.ID, newPath))
+
+		w.lock.Lock()
+		defer w.lock.Unlock()
+
+		require.Equal(t, newPath, w.courses[course.ID])
+		_, ok := w.dirs[course.Path]
+		require.False(t, ok)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCourseWatch_Sync(t *testing.T) {
+	t.Run("watch disabled", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := createCourse(t, w, ctx, true)
+		require.NoError(t, w.Start(ctx))
+		require.True(t, w.IsWatching(course.ID))
+
+		course.Watch = false
+		require.NoError(t, w.dao.UpdateCourse(ctx, course))
+
+		require.NoError(t, w.Sync(ctx))
+		require.False(t, w.IsWatching(course.ID))
+	})
+
+	t.Run("watch enabled", func(t *testing.T) {
+		w, ctx := setup(t)
+
+		course := createCourse(t, w, ctx, false)
+		require.NoError(t, w.Start(ctx))
+		require.False(t, w.IsWatching(course.ID))
+
+		course.Watch = true
+		require.NoError(t, w.dao.UpdateCourse(ctx, course))
+
+		require.NoError(t, w.Sync(ctx))
+		require.True(t, w.IsWatching(course.ID))
+	})
+}
@@ -37,6 +37,8 @@ var (
 	ErrPrefix              = errors.New("prefix cannot be empty or less than zero")
 	ErrPath                = errors.New("path cannot be empty")
 	ErrAssetCourseRelation = errors.New("asset does not belong to course")
+	ErrMaxDepth            = errors.New("max depth must be greater than zero")
+	ErrScanStatus          = errors.New("scan status is invalid")
 
 	// Media
 	ErrInvalidFFProbePath = errors.New("ffprobe path is invalid")
@@ -47,4 +49,24 @@ var (
 	ErrFFmpegNotFound     = errors.New("ffmpeg not found in path")
 	ErrFFmpegUnavailable  = errors.New("ffmpeg unavailable")
 	ErrFFmpegPathEmpty    = errors.New("ffmpeg path cannot be empty")
+
+	// Discovery
+	ErrDiscoveryRunning = errors.New("discovery already running")
+
+	// Watch
+	ErrWatchPath  = errors.New("watch path does not exist")
+	ErrWatchLimit = errors.New("filesystem watch limit reached")
+
+	// Archive
+	ErrArchiveReadOnly    = errors.New("archive is read-only")
+	ErrArchiveUnsupported = errors.New("archive member uses an unsupported compression method")
+
+	// Move
+	ErrMoveMode          = errors.New("move mode is invalid")
+	ErrMoveSamePath      = errors.New("course is already at the destination")
+	ErrMoveIntoCourse    = errors.New("destination cannot be within the course")
+	ErrMoveSourceMissing = errors.New("course path does not exist")
+	ErrMoveDestExists    = errors.New("destination already exists")
+	ErrMoveDestMissing   = errors.New("destination does not exist")
+	ErrMoveHashMismatch  = errors.New("destination files do not match the course assets")
 )
@@ -138,6 +138,27 @@ func (l *Logger) WithCourseScan() *Logger {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// WithCourseWatch creates a logger for the course watch component
+func (l *Logger) WithCourseWatch() *Logger {
+	return l.withComponent("coursewatch")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// WithCourseDiscovery creates a logger for the course discovery component
+func (l *Logger) WithCourseDiscovery() *Logger {
+	return l.withComponent("coursediscovery")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// WithCourseMove creates a logger for the course move component
+func (l *Logger) WithCourseMove() *Logger {
+	return l.withComponent("coursemove")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // WithCardCache creates a logger for the card cache component
 func (l *Logger) WithCardCache() *Logger {
 	return l.withComponent("cardcache")
@@ -145,6 +166,13 @@ func (l *Logger) WithCardCache() *Logger {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// WithThumbnails creates a logger for the thumbnails component
+func (l *Logger) WithThumbnails() *Logger {
+	return l.withComponent("thumbnails")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // WithCourseMetadata creates a logger for the course metadata component
 func (l *Logger) WithCourseMetadata() *Logger {
 	return l.withComponent("coursemetadata")
@@ -0,0 +1,452 @@
+package hls
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// cacheEnforceInterval is how often the cache limits are enforced
+const cacheEnforceInterval = 5 * time.Minute
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CacheEntry is the segment directory of a single video quality or audio track of an asset
+type CacheEntry struct {
+	AssetID string
+
+	// Stream is the quality, such as 720p, or audio-{index}. fMP4 streams end in -fmp4
+	Stream string
+
+	// Complete is true for a complete set written by the pre-transcoder
+	Complete bool
+
+	Path       string
+	Size       int64
+	LastAccess time.Time
+
+	// Active is true when an encoder is writing to the stream. Active entries are never evicted
+	Active bool
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CacheStats describes the HLS cache
+type CacheStats struct {
+	Size    int64
+	MaxSize int64
+	MaxAge  time.Duration
+	Entries []CacheEntry
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CachePurgeResult describes the outcome of a purge
+type CachePurgeResult struct {
+	Purged  int
+	Skipped int
+	Freed   int64
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Cache keeps the HLS cache within a max size and max age by evicting the least recently used
+// segment directories
+//
+// Last access times are tracked in memory as segments are served, falling back to the
+// modification time of the directory. Complete sets are never evicted for age and, for size,
+// only once every on-demand entry is gone
+type Cache struct {
+	transcoder *Transcoder
+
+	mu         sync.Mutex
+	lastAccess map[string]time.Time
+
+	// now returns the current time (overridable for tests)
+	now func() time.Time
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// newCache creates a new Cache for the transcoder
+func newCache(t *Transcoder) *Cache {
+	return &Cache{
+		transcoder: t,
+		lastAccess: make(map[string]time.Time),
+		now:        time.Now,
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// touch records that a segment in dir was served
+func (c *Cache) touch(dir string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastAccess[dir] = c.now()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Worker enforces the cache limits periodically until the context is cancelled
+func (c *Cache) Worker(ctx context.Context) {
+	ticker := time.NewTicker(cacheEnforceInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Enforce(); err != nil {
+			c.transcoder.config.Logger.Error().Err(err).Msg("Failed to enforce HLS cache limits")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Stats returns the size and entries of the cache, most recently used first
+func (c *Cache) Stats() (*CacheStats, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(entries, func(a, b CacheEntry) int {
+		return b.LastAccess.Compare(a.LastAccess)
+	})
+
+	stats := &CacheStats{
+		MaxSize: c.transcoder.config.CacheMaxSize,
+		MaxAge:  c.transcoder.config.CacheMaxAge,
+		Entries: entries,
+	}
+
+	for _, entry := range entries {
+		stats.Size += entry.Size
+	}
+
+	return stats, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Enforce evicts on-demand entries that have not been accessed within the max age, then the
+// least recently used entries until the cache is within the max size. A zero limit is
+// disabled
+func (c *Cache) Enforce() error {
+	maxSize := c.transcoder.config.CacheMaxSize
+	maxAge := c.transcoder.config.CacheMaxAge
+
+	if maxSize <= 0 && maxAge <= 0 {
+		return nil
+	}
+
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	now := c.now()
+	kept := []CacheEntry{}
+	size := int64(0)
+
+	for _, entry := range entries {
+		if maxAge > 0 && !entry.Complete && now.Sub(entry.LastAccess) > maxAge && c.evict(entry, "expired") {
+			continue
+		}
+
+		kept = append(kept, entry)
+		size += entry.Size
+	}
+
+	if maxSize <= 0 || size <= maxSize {
+		return nil
+	}
+
+	// On-demand entries go first, then complete sets, least recently used first
+	slices.SortFunc(kept, func(a, b CacheEntry) int {
+		if a.Complete != b.Complete {
+			if a.Complete {
+				return 1
+			}
+			return -1
+		}
+
+		return a.LastAccess.Compare(b.LastAccess)
+	})
+
+	for _, entry := range kept {
+		if size <= maxSize {
+			break
+		}
+
+		if c.evict(entry, "over max size") {
+			size -= entry.Size
+		}
+	}
+
+	if size > maxSize {
+		c.transcoder.config.Logger.Warn().
+			Int64("size", size).
+			Int64("max_size", maxSize).
+			Msg("HLS cache is over its max size as the remaining entries are in use")
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Purge removes the entries, including complete sets, of the given assets. When assetIDs is nil,
+// every entry is removed. Active entries are skipped
+func (c *Cache) Purge(assetIDs []string) (*CachePurgeResult, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CachePurgeResult{}
+
+	for _, entry := range entries {
+		if assetIDs != nil && !slices.Contains(assetIDs, entry.AssetID) {
+			continue
+		}
+
+		if c.evict(entry, "purged") {
+			result.Purged++
+			result.Freed += entry.Size
+		} else {
+			result.Skipped++
+		}
+	}
+
+	return result, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// evict removes an entry unless it is active. Open streams serving the entry are reset so the
+// segments are transcoded again when next requested
+func (c *Cache) evict(entry CacheEntry, reason string) bool {
+	fs := c.transcoder.config.AppFs.Fs
+	remove := func() error {
+		return fs.RemoveAll(entry.Path)
+	}
+
+	streams := c.findStreams(entry.AssetID, entry.Stream)
+
+	var err error
+	evicted := true
+
+	if len(streams) == 0 {
+		err = remove()
+	}
+
+	for _, stream := range streams {
+		if evicted, err = stream.evict(entry.Path, remove); !evicted || err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		c.transcoder.config.Logger.Error().
+			Err(err).
+			Str("asset_id", entry.AssetID).
+			Str("stream", entry.Stream).
+			Msg("Failed to evict HLS cache entry")
+		return false
+	}
+
+	if !evicted {
+		return false
+	}
+
+	c.mu.Lock()
+	delete(c.lastAccess, entry.Path)
+	c.mu.Unlock()
+
+	c.transcoder.config.Logger.Debug().
+		Str("asset_id", entry.AssetID).
+		Str("stream", entry.Stream).
+		Bool("complete", entry.Complete).
+		Int64("size", entry.Size).
+		Str("reason", reason).
+		Msg("Evicted HLS cache entry")
+
+	return true
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// entries lists the on-demand and complete entries in the cache
+func (c *Cache) entries() ([]CacheEntry, error) {
+	root := c.transcoder.cachePath
+	entries := []CacheEntry{}
+
+	assetIDs, err := c.transcoder.config.AppFs.PathItems(root)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, assetID := range assetIDs {
+		if assetID != completeDirName {
+			assetEntries, err := c.assetEntries(assetID, filepath.Join(root, assetID), false)
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, assetEntries...)
+			continue
+		}
+
+		completeIDs, err := c.transcoder.config.AppFs.PathItems(filepath.Join(root, completeDirName))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, completeID := range completeIDs {
+			assetEntries, err := c.assetEntries(completeID, filepath.Join(root, completeDirName, completeID), true)
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, assetEntries...)
+		}
+	}
+
+	return entries, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// assetEntries lists the video and audio segment directories of an asset. Subtitles and
+// unfinished complete sets are skipped
+func (c *Cache) assetEntries(assetID, dir string, complete bool) ([]CacheEntry, error) {
+	fs := c.transcoder.config.AppFs.Fs
+
+	items, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	entries := []CacheEntry{}
+
+	for _, item := range items {
+		name := item.Name()
+		if !item.IsDir() || strings.HasSuffix(name, ".tmp") || strings.HasPrefix(name, "subtitle-") {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+
+		size, err := dirSize(fs, path)
+		if err != nil {
+			return nil, err
+		}
+
+		lastAccess := item.ModTime()
+
+		c.mu.Lock()
+		if accessed, ok := c.lastAccess[path]; ok && accessed.After(lastAccess) {
+			lastAccess = accessed
+		}
+		c.mu.Unlock()
+
+		entries = append(entries, CacheEntry{
+			AssetID:    assetID,
+			Stream:     name,
+			Complete:   complete,
+			Path:       path,
+			Size:       size,
+			LastAccess: lastAccess,
+			Active:     c.isActive(assetID, name),
+		})
+	}
+
+	return entries, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// findStreams returns the open video and audio streams of an asset with the given name
+func (c *Cache) findStreams(assetID, name string) []*Stream {
+	streams := []*Stream{}
+
+	sw, ok := c.transcoder.streams.Get(assetID)
+	if !ok {
+		return streams
+	}
+
+	sw.videos.ForEach(func(_ VideoKey, s *VideoStream) {
+		if s.getName() == name {
+			streams = append(streams, &s.Stream)
+		}
+	})
+
+	sw.audios.ForEach(func(_ AudioKey, s *AudioStream) {
+		if s.getName() == name {
+			streams = append(streams, &s.Stream)
+		}
+	})
+
+	return streams
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// isActive returns true when an open stream of the asset with the given name has an encoder
+// running
+func (c *Cache) isActive(assetID, name string) bool {
+	for _, stream := range c.findStreams(assetID, name) {
+		stream.lock.RLock()
+		active := stream.hasActiveHeads()
+		stream.lock.RUnlock()
+
+		if active {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// dirSize returns the total size of the files in a directory
+func dirSize(fs afero.Fs, dir string) (int64, error) {
+	size := int64(0)
+
+	err := afero.Walk(fs, dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
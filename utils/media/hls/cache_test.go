@@ -0,0 +1,234 @@
+package hls
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// writeCacheEntry writes a segment of the given size into dir and sets its modification time
+func writeCacheEntry(t *testing.T, fs afero.Fs, dir string, size int, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(dir, "segment-0-0.ts"), make([]byte, size), 0o644))
+	require.NoError(t, fs.Chtimes(dir, modTime, modTime))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCache_Stats(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	p, _ := setupPretranscoder(t, fs)
+	cache := p.transcoder.Cache()
+	root := p.transcoder.cachePath
+
+	now := time.Now()
+	writeCacheEntry(t, fs, filepath.Join(root, "asset1", "720p"), 100, now.Add(-time.Hour))
+	writeCacheEntry(t, fs, filepath.Join(root, "asset1", "audio-0"), 10, now.Add(-2*time.Hour))
+	writeCompleteSet(t, fs, filepath.Join(root, completeDirName, "asset2", string(Original)), 2, FormatTS, "")
+
+	// Skipped
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(root, "asset1", "subtitle-0", "index.vtt"), []byte("vtt"), 0o644))
+	require.NoError(t, fs.MkdirAll(filepath.Join(root, completeDirName, "asset2", "720p.tmp"), 0o755))
+
+	// Touching an entry makes it the most recently used
+	cache.touch(filepath.Join(root, "asset1", "audio-0"))
+
+	stats, err := cache.Stats()
+	require.NoError(t, err)
+	require.Len(t, stats.Entries, 3)
+
+	require.Equal(t, "audio-0", stats.Entries[0].Stream)
+	require.Equal(t, "asset1", stats.Entries[0].AssetID)
+	require.False(t, stats.Entries[0].Complete)
+
+	complete := stats.Entries[1]
+	require.Equal(t, "asset2", complete.AssetID)
+	require.Equal(t, string(Original), complete.Stream)
+	require.True(t, complete.Complete)
+
+	require.Equal(t, "720p", stats.Entries[2].Stream)
+	require.Equal(t, int64(100), stats.Entries[2].Size)
+
+	require.Equal(t, int64(110)+complete.Size, stats.Size)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCache_Enforce(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		p, _ := setupPretranscoder(t, fs)
+
+		dir := filepath.Join(p.transcoder.cachePath, "asset1", "720p")
+		writeCacheEntry(t, fs, dir, 100, time.Now().Add(-24*time.Hour))
+
+		require.NoError(t, p.transcoder.Cache().Enforce())
+
+		exists, err := afero.DirExists(fs, dir)
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		p, _ := setupPretranscoder(t, fs)
+		p.transcoder.config.CacheMaxAge = time.Hour
+
+		root := p.transcoder.cachePath
+		now := time.Now()
+
+		oldDir := filepath.Join(root, "asset1", "720p")
+		writeCacheEntry(t, fs, oldDir, 100, now.Add(-2*time.Hour))
+
+		touchedDir := filepath.Join(root, "asset1", "480p")
+		writeCacheEntry(t, fs, touchedDir, 100, now.Add(-2*time.Hour))
+		p.transcoder.Cache().touch(touchedDir)
+
+		// Complete sets do not expire
+		completeDir := filepath.Join(root, completeDirName, "asset1", string(Original))
+		writeCompleteSet(t, fs, completeDir, 1, FormatTS, "")
+		require.NoError(t, fs.Chtimes(completeDir, now.Add(-2*time.Hour), now.Add(-2*time.Hour)))
+
+		require.NoError(t, p.transcoder.Cache().Enforce())
+
+		exists, err := afero.DirExists(fs, oldDir)
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		exists, err = afero.DirExists(fs, touchedDir)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		exists, err = afero.DirExists(fs, completeDir)
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		p, _ := setupPretranscoder(t, fs)
+		p.transcoder.config.CacheMaxSize = 250
+
+		root := p.transcoder.cachePath
+		now := time.Now()
+
+		completeDir := filepath.Join(root, completeDirName, "asset1", "720p")
+		require.NoError(t, afero.WriteFile(fs, filepath.Join(completeDir, "segment-0.ts"), make([]byte, 100), 0o644))
+		require.NoError(t, fs.Chtimes(completeDir, now.Add(-3*time.Hour), now.Add(-3*time.Hour)))
+
+		oldest := filepath.Join(root, "asset2", "720p")
+		writeCacheEntry(t, fs, oldest, 100, now.Add(-2*time.Hour))
+
+		older := filepath.Join(root, "asset3", "720p")
+		writeCacheEntry(t, fs, older, 100, now.Add(-time.Hour))
+
+		newest := filepath.Join(root, "asset4", "720p")
+		writeCacheEntry(t, fs, newest, 50, now)
+
+		require.NoError(t, p.transcoder.Cache().Enforce())
+
+		// On-demand entries are evicted first, least recently used first
+		for dir, expected := range map[string]bool{completeDir: true, oldest: false, older: true, newest: true} {
+			exists, err := afero.DirExists(fs, dir)
+			require.NoError(t, err)
+			require.Equal(t, expected, exists, dir)
+		}
+
+		// Complete sets go once the on-demand entries are gone
+		p.transcoder.config.CacheMaxSize = 40
+		require.NoError(t, p.transcoder.Cache().Enforce())
+
+		stats, err := p.transcoder.Cache().Stats()
+		require.NoError(t, err)
+		require.Empty(t, stats.Entries)
+	})
+
+	t.Run("active skipped", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		p, ctx := setupPretranscoder(t, fs)
+		p.transcoder.config.CacheMaxSize = 1
+
+		asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", []float64{0, 4, 8})
+
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+
+		stream, err := sw.getVideoStream(0, P720, FormatTS)
+		require.NoError(t, err)
+
+		writeCacheEntry(t, fs, stream.outDir(), 100, time.Now().Add(-time.Hour))
+
+		stream.lock.Lock()
+		stream.heads = append(stream.heads, Head{segment: 0, end: 3})
+		stream.lock.Unlock()
+
+		require.NoError(t, p.transcoder.Cache().Enforce())
+
+		stats, err := p.transcoder.Cache().Stats()
+		require.NoError(t, err)
+		require.Len(t, stats.Entries, 1)
+		require.True(t, stats.Entries[0].Active)
+
+		// Once the head is gone, the entry is evicted and the stream starts over
+		stream.lock.Lock()
+		stream.heads[0] = DeletedHead
+		stream.lock.Unlock()
+
+		require.NoError(t, p.transcoder.Cache().Enforce())
+
+		exists, err := afero.DirExists(fs, stream.outDir())
+		require.NoError(t, err)
+		require.False(t, exists)
+		require.False(t, stream.isSegmentReady(0))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestCache_Purge(t *testing.T) {
+	t.Run("assets", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		p, _ := setupPretranscoder(t, fs)
+		root := p.transcoder.cachePath
+
+		writeCacheEntry(t, fs, filepath.Join(root, "asset1", "720p"), 100, time.Now())
+		writeCompleteSet(t, fs, filepath.Join(root, completeDirName, "asset1", "480p"), 1, FormatTS, "")
+		writeCacheEntry(t, fs, filepath.Join(root, "asset2", "720p"), 100, time.Now())
+
+		result, err := p.transcoder.Cache().Purge([]string{"asset1"})
+		require.NoError(t, err)
+		require.Equal(t, 2, result.Purged)
+		require.Equal(t, 0, result.Skipped)
+		require.Greater(t, result.Freed, int64(100))
+
+		stats, err := p.transcoder.Cache().Stats()
+		require.NoError(t, err)
+		require.Len(t, stats.Entries, 1)
+		require.Equal(t, "asset2", stats.Entries[0].AssetID)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		p, _ := setupPretranscoder(t, fs)
+		root := p.transcoder.cachePath
+
+		writeCacheEntry(t, fs, filepath.Join(root, "asset1", "720p"), 100, time.Now())
+		writeCacheEntry(t, fs, filepath.Join(root, "asset2", "720p"), 100, time.Now())
+
+		result, err := p.transcoder.Cache().Purge(nil)
+		require.NoError(t, err)
+		require.Equal(t, 2, result.Purged)
+		require.Equal(t, int64(200), result.Freed)
+
+		stats, err := p.transcoder.Cache().Stats()
+		require.NoError(t, err)
+		require.Empty(t, stats.Entries)
+		require.Zero(t, stats.Size)
+	})
+}
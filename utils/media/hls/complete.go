@@ -0,0 +1,83 @@
+package hls
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	// completeDirName is the directory, within the HLS cache, holding complete segment sets. It
+	// survives restarts, unlike the rest of the cache
+	completeDirName = "complete"
+
+	// completeMarkerFile is written once every segment of a set exists
+	completeMarkerFile = "complete.json"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// completeMarker describes a complete segment set
+type completeMarker struct {
+	Segments int `json:"segments"`
+
+	// Fingerprint identifies the encoder settings the set was written with
+	Fingerprint string    `json:"fingerprint"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// completeSegmentPath returns the path of a segment within a complete set of the given format
+func completeSegmentPath(dir string, segment int32, format Format) string {
+	return filepath.Join(dir, fmt.Sprintf("segment-%d.%s", segment, format.segmentExt()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// completeFingerprint returns the fingerprint of a stream's encoder settings. It is a hash of
+// the ffmpeg arguments, so it changes with the bitrates, CRF, preset, audio bitrate, channels,
+// loudness gain and hardware acceleration the stream is encoded with
+func completeFingerprint(streamer Streamer) string {
+	sum := sha256.Sum256([]byte(strings.Join(streamer.getTranscodeArgs(""), " ")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// writeCompleteMarker writes the marker for a set of segments in dir
+func writeCompleteMarker(fs afero.Fs, dir string, segments int, fingerprint string) error {
+	data, err := json.Marshal(&completeMarker{Segments: segments, Fingerprint: fingerprint, CreatedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	return afero.WriteFile(fs, filepath.Join(dir, completeMarkerFile), data, 0o644)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// isCompleteSet returns true when dir holds a complete set with the given number of segments and
+// fingerprint. A set with a different number of segments was cut from keyframes that have since
+// changed, and one with a different fingerprint was encoded with settings that have since changed
+func isCompleteSet(fs afero.Fs, dir string, segments int, fingerprint string) bool {
+	data, err := afero.ReadFile(fs, filepath.Join(dir, completeMarkerFile))
+	if err != nil {
+		return false
+	}
+
+	var marker completeMarker
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return false
+	}
+
+	return segments > 0 && marker.Segments == segments && marker.Fingerprint == fingerprint
+}
@@ -0,0 +1,267 @@
+package hls
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	// dashTimescale is the number of ticks per second of the segment timelines
+	dashTimescale = 1000
+
+	// surroundBitrate is the bandwidth advertised for surround passthrough renditions, as the
+	// bitrate of audio tracks is not probed. It is the max bitrate of AC-3
+	surroundBitrate = 640_000
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// mpd is the root of a static MPEG-DASH manifest
+type mpd struct {
+	XMLName                   xml.Name  `xml:"MPD"`
+	Xmlns                     string    `xml:"xmlns,attr"`
+	Profiles                  string    `xml:"profiles,attr"`
+	Type                      string    `xml:"type,attr"`
+	MediaPresentationDuration string    `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string    `xml:"minBufferTime,attr"`
+	Period                    mpdPeriod `xml:"Period"`
+}
+
+// mpdPeriod is the single period of the manifest
+type mpdPeriod struct {
+	ID             string             `xml:"id,attr"`
+	Start          string             `xml:"start,attr"`
+	AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+// mpdAdaptationSet is a set of interchangeable representations, such as the qualities of the
+// video or a single audio track
+type mpdAdaptationSet struct {
+	ID               int                 `xml:"id,attr"`
+	ContentType      string              `xml:"contentType,attr"`
+	MimeType         string              `xml:"mimeType,attr"`
+	Lang             string              `xml:"lang,attr,omitempty"`
+	SegmentAlignment bool                `xml:"segmentAlignment,attr"`
+	StartWithSAP     int                 `xml:"startWithSAP,attr"`
+	Label            string              `xml:"Label,omitempty"`
+	Role             *mpdDescriptor      `xml:"Role"`
+	SegmentTemplate  mpdSegmentTemplate  `xml:"SegmentTemplate"`
+	Representations  []mpdRepresentation `xml:"Representation"`
+}
+
+// mpdDescriptor is a scheme and value pair, such as a role or channel configuration
+type mpdDescriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+// mpdSegmentTemplate describes the init and media segment URLs of the representations
+type mpdSegmentTemplate struct {
+	Timescale       int          `xml:"timescale,attr"`
+	Initialization  string       `xml:"initialization,attr"`
+	Media           string       `xml:"media,attr"`
+	StartNumber     int          `xml:"startNumber,attr"`
+	SegmentTimeline []mpdSegment `xml:"SegmentTimeline>S"`
+}
+
+// mpdSegment is a run of segments in a timeline. The run starts at T and holds R+1 segments
+// of D ticks
+type mpdSegment struct {
+	T *int64 `xml:"t,attr"`
+	D int64  `xml:"d,attr"`
+	R int    `xml:"r,attr,omitempty"`
+}
+
+// mpdRepresentation is a single encoding of the content
+type mpdRepresentation struct {
+	ID                        string         `xml:"id,attr"`
+	Codecs                    string         `xml:"codecs,attr"`
+	Bandwidth                 int            `xml:"bandwidth,attr"`
+	Width                     int            `xml:"width,attr,omitempty"`
+	Height                    int            `xml:"height,attr,omitempty"`
+	AudioChannelConfiguration *mpdDescriptor `xml:"AudioChannelConfiguration"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetDashManifest generates a static MPEG-DASH manifest over the fMP4 streams. Every quality of
+// the default video is a representation of a single video adaptation set and each audio track
+// (and surround passthrough) gets its own audio adaptation set. Segments are served by the
+// fMP4 HLS routes, so both share the same streams and cache
+func (sw *StreamWrapper) GetDashManifest(assetID string, audioLanguage string) (string, error) {
+	timeline := sw.dashTimeline()
+	base := fmt.Sprintf("/api/hls/%s/%s", assetID, FormatFMP4.pathPrefix())
+
+	manifest := mpd{
+		Xmlns:                     "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:                  "urn:mpeg:dash:profile:isoff-live:2011",
+		Type:                      "static",
+		MediaPresentationDuration: fmt.Sprintf("PT%.3fS", sw.Info.Duration),
+		MinBufferTime:             "PT6S",
+		Period:                    mpdPeriod{ID: "0", Start: "PT0S"},
+	}
+
+	template := func(dir string) mpdSegmentTemplate {
+		return mpdSegmentTemplate{
+			Timescale:       dashTimescale,
+			Initialization:  base + dir + "/" + fmp4InitFile,
+			Media:           base + dir + "/segment-$Number$.m4s",
+			SegmentTimeline: timeline,
+		}
+	}
+
+	if video := sw.defaultVideo(); video != nil {
+		set := mpdAdaptationSet{
+			ID:               len(manifest.Period.AdaptationSets),
+			ContentType:      "video",
+			MimeType:         "video/mp4",
+			SegmentAlignment: true,
+			StartWithSAP:     1,
+			SegmentTemplate:  template(fmt.Sprintf("video/%d/$RepresentationID$", video.Index)),
+		}
+
+		aspectRatio := float32(video.Width) / float32(video.Height)
+		transcodeCodec := "avc1.640028"
+
+		for _, quality := range sw.GetQualities() {
+			if quality == Original {
+				set.Representations = append(set.Representations, mpdRepresentation{
+					ID:        string(quality),
+					Codecs:    video.originalMimeCodec(FormatFMP4, transcodeCodec),
+					Bandwidth: int(video.Bitrate),
+					Width:     int(video.Width),
+					Height:    int(video.Height),
+				})
+				continue
+			}
+
+			set.Representations = append(set.Representations, mpdRepresentation{
+				ID:        string(quality),
+				Codecs:    transcodeCodec,
+				Bandwidth: int(sw.profile.MaxBitrate(quality)),
+				Width:     int(aspectRatio*float32(quality.Height()) + 0.5),
+				Height:    int(quality.Height()),
+			})
+		}
+
+		manifest.Period.AdaptationSets = append(manifest.Period.AdaptationSets, set)
+	}
+
+	defAudio := sw.defaultAudio(audioLanguage)
+	passthrough := sw.profile.SurroundPassthrough
+
+	for _, audio := range sw.Info.Audios {
+		isDefault := defAudio != nil && audio.Index == defAudio.Index
+
+		stereo := dashAudioSet(audio, isDefault, template(fmt.Sprintf("audio/%d", audio.Index)), mpdRepresentation{
+			ID:        fmt.Sprintf("audio-%d", audio.Index),
+			Codecs:    aacCodec,
+			Bandwidth: int(sw.profile.AudioBitrate),
+		}, sw.profile.AudioChannels)
+		stereo.ID = len(manifest.Period.AdaptationSets)
+		manifest.Period.AdaptationSets = append(manifest.Period.AdaptationSets, stereo)
+
+		if !passthrough || !audio.canPassthrough() {
+			continue
+		}
+
+		bitrate := int(audio.Bitrate)
+		if bitrate == 0 {
+			bitrate = surroundBitrate
+		}
+
+		surround := dashAudioSet(audio, false, template(fmt.Sprintf("audio/%d/surround", audio.Index)), mpdRepresentation{
+			ID:        fmt.Sprintf("audio-%d-surround", audio.Index),
+			Codecs:    surroundCodecs[audio.Codec],
+			Bandwidth: bitrate,
+		}, audio.Channels)
+		surround.ID = len(manifest.Period.AdaptationSets)
+		manifest.Period.AdaptationSets = append(manifest.Period.AdaptationSets, surround)
+	}
+
+	data, err := xml.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return xml.Header + string(data) + "\n", nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// dashTimeline returns the segment timeline shared by every stream, as segments of the video and
+// audio are cut at the same times. Runs of segments of the same length are collapsed
+func (sw *StreamWrapper) dashTimeline() []mpdSegment {
+	keyframes := getKeyframes(sw)
+	timeline := []mpdSegment{}
+
+	ticks := func(seconds float64) int64 {
+		return int64(math.Round(seconds * dashTimescale))
+	}
+
+	for i, keyframe := range keyframes {
+		end := sw.Info.Duration
+		if i+1 < len(keyframes) {
+			end = keyframes[i+1]
+		}
+
+		start := ticks(keyframe)
+		duration := ticks(end) - start
+
+		if last := len(timeline) - 1; last >= 0 && timeline[last].D == duration {
+			timeline[last].R++
+			continue
+		}
+
+		segment := mpdSegment{D: duration}
+		if i == 0 {
+			segment.T = &start
+		}
+
+		timeline = append(timeline, segment)
+	}
+
+	return timeline
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// dashAudioSet returns the adaptation set of an audio rendition. The label falls back to the
+// language, then to the index of the track
+func dashAudioSet(audio Audio, isDefault bool, template mpdSegmentTemplate, representation mpdRepresentation, channels int) mpdAdaptationSet {
+	set := mpdAdaptationSet{
+		ContentType:      "audio",
+		MimeType:         "audio/mp4",
+		SegmentAlignment: true,
+		StartWithSAP:     1,
+		SegmentTemplate:  template,
+	}
+
+	if audio.Language != nil {
+		set.Lang = *audio.Language
+	}
+
+	switch {
+	case audio.Title != nil:
+		set.Label = *audio.Title
+	case audio.Language != nil:
+		set.Label = *audio.Language
+	default:
+		set.Label = fmt.Sprintf("Audio %d", audio.Index)
+	}
+
+	if isDefault {
+		set.Role = &mpdDescriptor{SchemeIDURI: "urn:mpeg:dash:role:2011", Value: "main"}
+	}
+
+	representation.AudioChannelConfiguration = &mpdDescriptor{
+		SchemeIDURI: "urn:mpeg:dash:23003:3:audio_channel_configuration:2011",
+		Value:       fmt.Sprint(channels),
+	}
+	set.Representations = []mpdRepresentation{representation}
+
+	return set
+}
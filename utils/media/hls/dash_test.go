@@ -0,0 +1,123 @@
+package hls
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStreamWrapper_DashManifest(t *testing.T) {
+	french := "fre"
+	title := "Français"
+
+	newStreamWrapper := func(t *testing.T, profile *Profile) *StreamWrapper {
+		t.Helper()
+
+		p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+		asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", []float64{0, 4, 8})
+
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+
+		sw.profile = profile
+		sw.Info.Duration = 14.5
+		sw.Info.Videos = []Video{{Index: 0, Codec: "hevc", Width: 1280, Height: 720, Bitrate: 3000000, IsDefault: true}}
+		sw.Info.Audios = []Audio{
+			{Index: 0, Codec: "aac", Channels: 2, IsDefault: true},
+			{Index: 1, Language: &french, Title: &title, Codec: "eac3", Channels: 6},
+		}
+
+		return sw
+	}
+
+	parse := func(t *testing.T, manifest string) *mpd {
+		t.Helper()
+
+		parsed := &mpd{}
+		require.NoError(t, xml.Unmarshal([]byte(manifest), parsed))
+		return parsed
+	}
+
+	t.Run("video", func(t *testing.T) {
+		manifest, err := newStreamWrapper(t, DefaultProfile()).GetDashManifest("asset", "")
+		require.NoError(t, err)
+		require.Contains(t, manifest, `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="static" mediaPresentationDuration="PT14.500S"`)
+
+		parsed := parse(t, manifest)
+		require.Len(t, parsed.Period.AdaptationSets, 3)
+
+		video := parsed.Period.AdaptationSets[0]
+		require.Equal(t, "video", video.ContentType)
+		require.Equal(t, "/api/hls/asset/fmp4/video/0/$RepresentationID$/init.mp4", video.SegmentTemplate.Initialization)
+		require.Equal(t, "/api/hls/asset/fmp4/video/0/$RepresentationID$/segment-$Number$.m4s", video.SegmentTemplate.Media)
+
+		ids := []string{}
+		for _, representation := range video.Representations {
+			ids = append(ids, representation.ID)
+		}
+		require.Equal(t, []string{"original", "720p", "480p", "360p", "240p"}, ids)
+		require.Equal(t, "hvc1.1.6.L150.90", video.Representations[0].Codecs)
+		require.Equal(t, 3000000, video.Representations[0].Bandwidth)
+		require.Equal(t, 853, video.Representations[2].Width)
+
+		// Segments are cut at the keyframes
+		start := int64(0)
+		require.Equal(t, []mpdSegment{{T: &start, D: 4000, R: 1}, {D: 6500}}, video.SegmentTemplate.SegmentTimeline)
+		require.Contains(t, manifest, `<S t="0" d="4000" r="1"></S>`)
+		require.Contains(t, manifest, `<S d="6500"></S>`)
+	})
+
+	t.Run("timeline", func(t *testing.T) {
+		// Audio-only keyframes are every 6s
+		sw := newStreamWrapper(t, DefaultProfile())
+		sw.Info.Videos = nil
+
+		start := int64(0)
+		require.Equal(t, []mpdSegment{{T: &start, D: 6000, R: 1}, {D: 2500}}, sw.dashTimeline())
+	})
+
+	t.Run("audio", func(t *testing.T) {
+		sw := newStreamWrapper(t, DefaultProfile())
+		sw.Info.Videos = nil
+
+		manifest, err := sw.GetDashManifest("asset", "FRE")
+		require.NoError(t, err)
+
+		parsed := parse(t, manifest)
+		require.Len(t, parsed.Period.AdaptationSets, 2)
+
+		english := parsed.Period.AdaptationSets[0]
+		require.Equal(t, "Audio 0", english.Label)
+		require.Nil(t, english.Role)
+		require.Equal(t, "/api/hls/asset/fmp4/audio/0/segment-$Number$.m4s", english.SegmentTemplate.Media)
+
+		fre := parsed.Period.AdaptationSets[1]
+		require.Equal(t, 1, fre.ID)
+		require.Equal(t, "fre", fre.Lang)
+		require.Equal(t, "Français", fre.Label)
+		require.Equal(t, "main", fre.Role.Value)
+		require.Equal(t, "mp4a.40.2", fre.Representations[0].Codecs)
+		require.Equal(t, "2", fre.Representations[0].AudioChannelConfiguration.Value)
+	})
+
+	t.Run("surround", func(t *testing.T) {
+		profile := DefaultProfile()
+		profile.SurroundPassthrough = true
+
+		manifest, err := newStreamWrapper(t, profile).GetDashManifest("asset", "")
+		require.NoError(t, err)
+
+		parsed := parse(t, manifest)
+		require.Len(t, parsed.Period.AdaptationSets, 4)
+
+		surround := parsed.Period.AdaptationSets[3]
+		require.Equal(t, "/api/hls/asset/fmp4/audio/1/surround/init.mp4", surround.SegmentTemplate.Initialization)
+		require.Equal(t, "ec-3", surround.Representations[0].Codecs)
+		require.Equal(t, surroundBitrate, surround.Representations[0].Bandwidth)
+		require.Equal(t, "6", surround.Representations[0].AudioChannelConfiguration.Value)
+	})
+}
@@ -0,0 +1,180 @@
+package hls
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Format is the container segments are written in
+type Format string
+
+const (
+	// FormatTS writes each segment as a standalone MPEG-TS file
+	FormatTS Format = "ts"
+
+	// FormatFMP4 writes an init segment and a CMAF fragment (.m4s) per segment. It carries
+	// HEVC and AV1, which MPEG-TS does not
+	FormatFMP4 Format = "fmp4"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	// fmp4InitFile is the name of the init segment of a fMP4 stream
+	fmp4InitFile = "init.mp4"
+
+	// fmp4MuxerOptions are the mp4 muxer options for fMP4 segments. Each segment holds a single
+	// fragment, keeping its original timestamps so fragments from different encoders line up
+	fmp4MuxerOptions = "movflags=+empty_moov+default_base_moof+frag_discont:use_editlist=0"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// FormatFromString returns the format for a string. An empty string is MPEG-TS
+func FormatFromString(str string) (Format, error) {
+	switch Format(str) {
+	case "", FormatTS:
+		return FormatTS, nil
+	case FormatFMP4:
+		return FormatFMP4, nil
+	}
+
+	return "", fmt.Errorf("unknown format %q", str)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// segmentExt returns the extension of the segments served to the player
+func (f Format) segmentExt() string {
+	if f == FormatFMP4 {
+		return "m4s"
+	}
+
+	return "ts"
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// dirSuffix returns the suffix of the cache directory of a stream, so both formats of a stream
+// can be cached side by side
+func (f Format) dirSuffix() string {
+	if f == FormatFMP4 {
+		return "-fmp4"
+	}
+
+	return ""
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// pathPrefix returns the prefix of the API routes serving the format
+func (f Format) pathPrefix() string {
+	if f == FormatFMP4 {
+		return "fmp4/"
+	}
+
+	return ""
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// muxerArgs returns the ffmpeg arguments for the segment muxer to write the format
+func (f Format) muxerArgs() []string {
+	if f == FormatFMP4 {
+		return []string{"-segment_format", "mp4", "-segment_format_options", fmp4MuxerOptions}
+	}
+
+	return []string{"-segment_format", "mpegts"}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// splitFragment splits a fragmented mp4 file into its init segment (ftyp and moov) and its
+// media segment (everything else, such as moof and mdat)
+func splitFragment(data []byte) ([]byte, []byte, error) {
+	init := []byte{}
+	media := []byte{}
+
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 8 {
+			return nil, nil, errors.New("truncated box header")
+		}
+
+		size := uint64(binary.BigEndian.Uint32(data[offset:]))
+		boxType := string(data[offset+4 : offset+8])
+
+		switch size {
+		case 0:
+			// The box extends to the end of the file
+			size = uint64(len(data) - offset)
+		case 1:
+			// The size follows the type as a 64-bit integer
+			if len(data)-offset < 16 {
+				return nil, nil, errors.New("truncated box header")
+			}
+			size = binary.BigEndian.Uint64(data[offset+8:])
+		}
+
+		if size < 8 || size > uint64(len(data)-offset) {
+			return nil, nil, fmt.Errorf("invalid size for box %q", boxType)
+		}
+
+		box := data[offset : offset+int(size)]
+		if boxType == "ftyp" || boxType == "moov" {
+			init = append(init, box...)
+		} else {
+			media = append(media, box...)
+		}
+
+		offset += int(size)
+	}
+
+	if len(init) == 0 || len(media) == 0 {
+		return nil, nil, errors.New("not a fragmented mp4 file")
+	}
+
+	return init, media, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// writeFragment splits the mp4 file written by ffmpeg into the media segment at segmentPath and,
+// when it does not exist yet, the init segment of the stream at initPath. The mp4 file is removed
+func writeFragment(fs afero.Fs, mp4Path, segmentPath, initPath string) error {
+	data, err := afero.ReadFile(fs, mp4Path)
+	if err != nil {
+		return err
+	}
+
+	init, media, err := splitFragment(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(mp4Path), err)
+	}
+
+	// Every encoder writes the same init segment, so the first one wins. It is renamed into place
+	// so it is never read half written
+	if exists, err := afero.Exists(fs, initPath); err != nil {
+		return err
+	} else if !exists {
+		tmpPath := fmt.Sprintf("%s.%s.tmp", initPath, filepath.Base(mp4Path))
+		if err := afero.WriteFile(fs, tmpPath, init, 0o644); err != nil {
+			return err
+		}
+
+		if err := fs.Rename(tmpPath, initPath); err != nil {
+			return err
+		}
+	}
+
+	if err := afero.WriteFile(fs, segmentPath, media, 0o644); err != nil {
+		return err
+	}
+
+	return fs.Remove(mp4Path)
+}
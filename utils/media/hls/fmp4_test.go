@@ -0,0 +1,185 @@
+package hls
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// mp4Box returns an mp4 box of the given type holding the payload
+func mp4Box(boxType string, payload string) []byte {
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	box = append(box, boxType...)
+	return append(box, payload...)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestFormatFromString(t *testing.T) {
+	for str, expected := range map[string]Format{"": FormatTS, "ts": FormatTS, "fmp4": FormatFMP4} {
+		format, err := FormatFromString(str)
+		require.NoError(t, err)
+		require.Equal(t, expected, format)
+	}
+
+	_, err := FormatFromString("webm")
+	require.Error(t, err)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestSplitFragment(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// A 64-bit sized mdat
+		mdat := binary.BigEndian.AppendUint32(nil, 1)
+		mdat = append(mdat, "mdat"...)
+		mdat = binary.BigEndian.AppendUint64(mdat, 20)
+		mdat = append(mdat, "data"...)
+
+		data := append(mp4Box("ftyp", "iso6"), mp4Box("moov", "trak")...)
+		data = append(data, mp4Box("moof", "traf")...)
+		data = append(data, mdat...)
+
+		init, media, err := splitFragment(data)
+		require.NoError(t, err)
+		require.Equal(t, append(mp4Box("ftyp", "iso6"), mp4Box("moov", "trak")...), init)
+		require.Equal(t, append(mp4Box("moof", "traf"), mdat...), media)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := [][]byte{
+			nil,
+			mp4Box("ftyp", "iso6")[:6],
+			append(mp4Box("ftyp", "iso6"), mp4Box("moov", "trak")...),
+			append(mp4Box("moof", "traf"), mp4Box("mdat", "data")...),
+			binary.BigEndian.AppendUint32(append(mp4Box("ftyp", "iso6"), mp4Box("moov", "trak")...), 100),
+		}
+
+		for i, tt := range tests {
+			_, _, err := splitFragment(tt)
+			require.Error(t, err, i)
+		}
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestWriteFragment(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	dir := "/hls/asset/720p-fmp4"
+	initPath := filepath.Join(dir, fmp4InitFile)
+
+	write := func(moov string) string {
+		mp4Path := filepath.Join(dir, "segment-0-0.mp4")
+		data := append(mp4Box("ftyp", "iso6"), mp4Box("moov", moov)...)
+		data = append(data, mp4Box("moof", "traf")...)
+		require.NoError(t, afero.WriteFile(fs, mp4Path, data, 0o644))
+
+		segmentPath := filepath.Join(dir, "segment-0-0.m4s")
+		require.NoError(t, writeFragment(fs, mp4Path, segmentPath, initPath))
+
+		exists, err := afero.Exists(fs, mp4Path)
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		return segmentPath
+	}
+
+	segmentPath := write("trak")
+
+	media, err := afero.ReadFile(fs, segmentPath)
+	require.NoError(t, err)
+	require.Equal(t, mp4Box("moof", "traf"), media)
+
+	init, err := afero.ReadFile(fs, initPath)
+	require.NoError(t, err)
+	require.Equal(t, append(mp4Box("ftyp", "iso6"), mp4Box("moov", "trak")...), init)
+
+	// The first init segment is kept
+	write("mvex")
+
+	init, err = afero.ReadFile(fs, initPath)
+	require.NoError(t, err)
+	require.Contains(t, string(init), "trak")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStream_FMP4(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	p, ctx := setupPretranscoder(t, fs)
+
+	asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", []float64{0, 4, 8})
+
+	sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+	require.NoError(t, err)
+
+	stream, err := sw.getVideoStream(0, P720, FormatFMP4)
+	require.NoError(t, err)
+
+	t.Run("index", func(t *testing.T) {
+		index, err := stream.GetIndex()
+		require.NoError(t, err)
+		require.Contains(t, index, "#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4.000000\nsegment-0.m4s\n")
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		require.Equal(t, "720p-fmp4", stream.getName())
+		require.True(t, strings.HasSuffix(stream.completeDir(), "720p-fmp4"))
+		require.True(t, strings.HasSuffix(stream.getOutPath(1), "720p-fmp4/segment-1-%d.mp4"))
+		require.True(t, strings.HasSuffix(stream.getSegmentPath(1, 2), "720p-fmp4/segment-1-2.m4s"))
+
+		// Both formats are cached side by side
+		ts, err := sw.getVideoStream(0, P720, FormatTS)
+		require.NoError(t, err)
+		require.NotSame(t, stream, ts)
+		require.Equal(t, "720p", ts.getName())
+	})
+
+	t.Run("args", func(t *testing.T) {
+		args := strings.Join(stream.buildArgs(0, 3, stream.getOutPath(0)), " ")
+		require.Contains(t, args, "-segment_format mp4 -segment_format_options "+fmp4MuxerOptions)
+		require.NotContains(t, args, "mpegts")
+
+		original, err := NewVideoStream(sw, 0, Original, FormatFMP4)
+		require.NoError(t, err)
+
+		original.video.Codec = "hevc"
+		require.Equal(t, []string{"-map", "0:V:0", "-c:v", "copy", "-tag:v", "hvc1"}, original.getTranscodeArgs(""))
+	})
+
+	t.Run("audio", func(t *testing.T) {
+		as, err := sw.getAudioStream(0, AudioStereo, FormatFMP4)
+		require.NoError(t, err)
+		require.Equal(t, "audio-0-fmp4", as.getName())
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStreamWrapper_MasterPlaylistFMP4(t *testing.T) {
+	sw := &StreamWrapper{
+		profile: DefaultProfile(),
+		Info: &MediaInfo{
+			Duration: 90.5,
+			Videos:   []Video{{Index: 0, Codec: "av1", Width: 1920, Height: 1080, Bitrate: 5000000, IsDefault: true}},
+			Audios:   []Audio{{Index: 0, Codec: "aac", Channels: 2, IsDefault: true}},
+		},
+	}
+
+	master := sw.GetMasterPlaylistMulti("asset", FormatFMP4, "", nil)
+	require.Contains(t, master, "URI=\"fmp4/audio/0/index.m3u8\"")
+	require.Contains(t, master, "CODECS=\"av01.0.08M.08,mp4a.40.2\",AUDIO=\"audio\",CLOSED-CAPTIONS=NONE\n/api/hls/asset/fmp4/video/0/original/index.m3u8\n")
+	require.Contains(t, master, "/api/hls/asset/fmp4/video/0/720p/index.m3u8\n")
+
+	// MPEG-TS keeps the transcode codec
+	master = sw.GetMasterPlaylistMulti("asset", FormatTS, "", nil)
+	require.NotContains(t, master, "av01")
+	require.NotContains(t, master, "fmp4")
+}
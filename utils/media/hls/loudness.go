@@ -0,0 +1,215 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	// defaultLoudnessTarget is the integrated loudness, in LUFS, audio is normalized to when the
+	// profile has no target
+	defaultLoudnessTarget = -16.0
+
+	// maxLoudnessGain caps the gain, in dB, applied to an asset
+	maxLoudnessGain = 20.0
+
+	// silentLoudness is the loudness ebur128 reports for silence. Silent audio is never boosted
+	silentLoudness = -70.0
+
+	// loudnessTimeout is the longest a measurement may run
+	loudnessTimeout = time.Hour
+
+	// maxLoudnessMeasurements is the number of assets measured at once. A measurement decodes
+	// the whole of each track, so it costs about as much as a transcode
+	maxLoudnessMeasurements = 2
+
+	// loudnessRetryDelay is how long an asset whose measurement failed is left before it is
+	// measured again
+	loudnessRetryDelay = time.Hour
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// loudnessRe matches the integrated loudness in the summary of the ebur128 filter
+var loudnessRe = regexp.MustCompile(`I:\s+(-?[0-9.]+|-inf)\s+LUFS`)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// loudnessTarget returns the integrated loudness audio is normalized to
+func (p *Profile) loudnessTarget() float64 {
+	if p.LoudnessTarget == 0 {
+		return defaultLoudnessTarget
+	}
+
+	return p.LoudnessTarget
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// loudnessGain returns the gain, in dB, that brings audio of the measured loudness to the target.
+// It is rounded to 0.1 dB and capped at maxLoudnessGain
+func loudnessGain(target, measured float64) float64 {
+	if measured <= silentLoudness {
+		return 0
+	}
+
+	gain := max(-maxLoudnessGain, min(maxLoudnessGain, target-measured))
+
+	return math.Round(gain*10) / 10
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// measureLoudness runs ffmpeg's ebur128 filter over an audio track and returns its integrated
+// loudness in LUFS. The whole track is decoded, so this takes a while for long assets
+func measureLoudness(ctx context.Context, path string, track uint32) (float64, error) {
+	args := []string{
+		"-nostats",
+		"-hide_banner",
+		"-i", path,
+		"-map", fmt.Sprintf("0:a:%d", track),
+		"-af", "ebur128=framelog=quiet",
+		"-f", "null",
+		"-",
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	// The summary is written to stderr
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("error running ffmpeg: %w", err)
+	}
+
+	return parseLoudness(string(out))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// parseLoudness returns the integrated loudness from the output of the ebur128 filter. The
+// summary is printed last, so the last match is used
+func parseLoudness(output string) (float64, error) {
+	matches := loudnessRe.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0, errors.New("no integrated loudness in the ffmpeg output")
+	}
+
+	value := matches[len(matches)-1][1]
+	if value == "-inf" {
+		return silentLoudness, nil
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// measureAssetLoudness measures the loudness of the given audio tracks of an asset in the
+// background and stores them, so streams created afterwards are normalized. When trackMeta is
+// false the asset has no audio track metadata, and the loudness is stored with its audio metadata
+//
+// An asset is measured once at a time, and at most maxLoudnessMeasurements assets are measured at
+// once. An asset that finds no free slot is measured the next time it is played. An asset whose
+// measurement failed is not measured again until loudnessRetryDelay has passed
+func (t *Transcoder) measureAssetLoudness(assetID string, path string, tracks []uint32, trackMeta bool) {
+	t.measuringMu.Lock()
+	if t.measuring[assetID] {
+		t.measuringMu.Unlock()
+		return
+	}
+
+	if retryAt, ok := t.measureFailed[assetID]; ok && time.Now().Before(retryAt) {
+		t.measuringMu.Unlock()
+		return
+	}
+
+	select {
+	case t.measureSlots <- struct{}{}:
+	default:
+		t.measuringMu.Unlock()
+		t.config.Logger.Debug().
+			Str("asset_id", assetID).
+			Msg("Skipping loudness measurement as too many are running")
+		return
+	}
+
+	t.measuring[assetID] = true
+	t.measuringMu.Unlock()
+
+	go func() {
+		failed := true
+
+		defer func() {
+			<-t.measureSlots
+
+			t.measuringMu.Lock()
+			delete(t.measuring, assetID)
+
+			if failed {
+				t.measureFailed[assetID] = time.Now().Add(loudnessRetryDelay)
+			} else {
+				delete(t.measureFailed, assetID)
+			}
+
+			t.measuringMu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), loudnessTimeout)
+		defer cancel()
+
+		for _, track := range tracks {
+			// Archive members may be evicted between tracks, so the path is resolved each time
+			localPath, err := t.config.AppFs.LocalPath(path)
+			if err != nil {
+				t.config.Logger.Warn().
+					Err(err).
+					Str("asset_id", assetID).
+					Str("path", path).
+					Msg("Failed to extract asset from archive")
+				return
+			}
+
+			lufs, err := measureLoudness(ctx, localPath, track)
+			if err != nil {
+				t.config.Logger.Warn().
+					Err(err).
+					Str("asset_id", assetID).
+					Str("path", path).
+					Uint32("track", track).
+					Msg("Failed to measure loudness")
+				return
+			}
+
+			if trackMeta {
+				_, err = t.config.Dao.UpdateAudioTrackLoudness(ctx, assetID, int(track), lufs)
+			} else {
+				_, err = t.config.Dao.UpdateAudioLoudness(ctx, assetID, lufs)
+			}
+
+			if err != nil {
+				t.config.Logger.Error().
+					Err(err).
+					Str("asset_id", assetID).
+					Uint32("track", track).
+					Msg("Failed to save loudness")
+				return
+			}
+
+			t.config.Logger.Debug().
+				Str("asset_id", assetID).
+				Uint32("track", track).
+				Float64("loudness_lufs", lufs).
+				Msg("Measured loudness")
+		}
+
+		failed = false
+	}()
+}
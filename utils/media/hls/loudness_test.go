@@ -0,0 +1,202 @@
+package hls
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestLoudnessGain(t *testing.T) {
+	require.Equal(t, 3.4, loudnessGain(-16, -19.4))
+	require.Equal(t, -4.0, loudnessGain(-16, -12))
+	require.Equal(t, 0.0, loudnessGain(-16, -16))
+
+	// Capped
+	require.Equal(t, 20.0, loudnessGain(-16, -45))
+	require.Equal(t, -20.0, loudnessGain(-30, 0))
+
+	// Silence is never boosted
+	require.Equal(t, 0.0, loudnessGain(-16, silentLoudness))
+
+	profile := DefaultProfile()
+	require.Equal(t, defaultLoudnessTarget, profile.loudnessTarget())
+
+	profile.LoudnessTarget = -23
+	require.Equal(t, -23.0, profile.loudnessTarget())
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestParseLoudness(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		output := `Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'video.mp4':
+[Parsed_ebur128_0 @ 0x5581] Summary:
+
+  Integrated loudness:
+    I:         -19.6 LUFS
+    Threshold: -30.1 LUFS
+
+  Loudness range:
+    LRA:         6.2 LU
+    Threshold: -40.3 LUFS
+    LRA low:   -23.5 LUFS
+    LRA high:  -17.3 LUFS`
+
+		lufs, err := parseLoudness(output)
+		require.NoError(t, err)
+		require.Equal(t, -19.6, lufs)
+	})
+
+	t.Run("silence", func(t *testing.T) {
+		lufs, err := parseLoudness("  Integrated loudness:\n    I:          -inf LUFS\n")
+		require.NoError(t, err)
+		require.Equal(t, silentLoudness, lufs)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := parseLoudness("video.mp4: No such file or directory")
+		require.Error(t, err)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestTranscoder_LoudnessGain(t *testing.T) {
+	newAsset := func(t *testing.T) (*Pretranscoder, context.Context, *models.Asset) {
+		t.Helper()
+
+		p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+		asset := createPretranscodeAsset(t, p, ctx, "/course/lecture.mp3", nil)
+
+		require.NoError(t, p.dao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID: asset.ID,
+			AudioMetadata: &models.AudioMetadata{
+				Codec:        "mp3",
+				Channels:     2,
+				DurationSec:  10,
+				LoudnessLUFS: sql.NullFloat64{Float64: -20, Valid: true},
+			},
+		}))
+
+		return p, ctx, asset
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		p, ctx, asset := newAsset(t)
+
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+		require.Zero(t, sw.Info.Audios[0].LoudnessGain)
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		p, ctx, asset := newAsset(t)
+
+		profile := DefaultProfile()
+		profile.NormalizeLoudness = true
+		require.NoError(t, p.transcoder.SetProfile(ctx, profile))
+
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+		require.Equal(t, 4.0, sw.Info.Audios[0].LoudnessGain)
+	})
+
+	t.Run("course", func(t *testing.T) {
+		p, ctx, asset := newAsset(t)
+
+		course, err := p.dao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: asset.CourseID}))
+		require.NoError(t, err)
+		course.NormalizeAudio = true
+		require.NoError(t, p.dao.UpdateCourse(ctx, course))
+
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+		require.Equal(t, 4.0, sw.Info.Audios[0].LoudnessGain)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestTranscoder_LoudnessGainTracks(t *testing.T) {
+	p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+	asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", nil)
+
+	require.NoError(t, p.dao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+		AssetID: asset.ID,
+		AudioTrackMetadata: []*models.AudioTrackMetadata{
+			{StreamIndex: 1, TrackIndex: 0, Codec: "aac", Channels: 2, IsDefault: true, LoudnessLUFS: sql.NullFloat64{Float64: -20, Valid: true}},
+			{StreamIndex: 2, TrackIndex: 1, Codec: "aac", Channels: 2, LoudnessLUFS: sql.NullFloat64{Float64: -10, Valid: true}},
+		},
+	}))
+
+	profile := DefaultProfile()
+	profile.NormalizeLoudness = true
+	require.NoError(t, p.transcoder.SetProfile(ctx, profile))
+
+	// Each track is normalized by its own loudness
+	sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+	require.NoError(t, err)
+	require.Len(t, sw.Info.Audios, 2)
+	require.Equal(t, 4.0, sw.Info.Audios[0].LoudnessGain)
+	require.Equal(t, -6.0, sw.Info.Audios[1].LoudnessGain)
+
+	// A complete set written with another gain is not served
+	as, err := NewAudioStream(sw, sw.Info.Audios[0].Index, AudioStereo, FormatTS)
+	require.NoError(t, err)
+
+	fingerprint := completeFingerprint(as)
+	sw.Info.Audios[0].LoudnessGain = 2
+	require.NotEqual(t, fingerprint, completeFingerprint(as))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestTranscoder_MeasureAssetLoudness(t *testing.T) {
+	t.Run("failed", func(t *testing.T) {
+		p, _ := setupPretranscoder(t, afero.NewMemMapFs())
+		transcoder := p.transcoder
+
+		// The file does not exist, so the measurement fails
+		transcoder.measureAssetLoudness("asset", "/course/video.mp4", []uint32{0}, true)
+
+		require.Eventually(t, func() bool {
+			transcoder.measuringMu.Lock()
+			defer transcoder.measuringMu.Unlock()
+			return len(transcoder.measuring) == 0 && !transcoder.measureFailed["asset"].IsZero()
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// The asset is not measured again until the delay has passed
+		transcoder.measureAssetLoudness("asset", "/course/video.mp4", []uint32{0}, true)
+
+		transcoder.measuringMu.Lock()
+		require.Empty(t, transcoder.measuring)
+		transcoder.measuringMu.Unlock()
+		require.Empty(t, transcoder.measureSlots)
+	})
+
+	t.Run("no free slot", func(t *testing.T) {
+		p, _ := setupPretranscoder(t, afero.NewMemMapFs())
+		transcoder := p.transcoder
+
+		// Every slot is taken, so the asset is left for the next time it is played
+		for range maxLoudnessMeasurements {
+			transcoder.measureSlots <- struct{}{}
+		}
+
+		transcoder.measureAssetLoudness("asset", "/course/video.mp4", []uint32{0}, true)
+
+		transcoder.measuringMu.Lock()
+		require.Empty(t, transcoder.measuring)
+		transcoder.measuringMu.Unlock()
+		require.Len(t, transcoder.measureSlots, maxLoudnessMeasurements)
+	})
+}
@@ -0,0 +1,313 @@
+package hls
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// PlaybackMethod is how an asset is played by a client
+type PlaybackMethod string
+
+const (
+	// PlaybackDirectPlay plays the original file as-is
+	PlaybackDirectPlay PlaybackMethod = "direct_play"
+
+	// PlaybackRemux copies the video and audio into HLS segments without re-encoding them
+	PlaybackRemux PlaybackMethod = "remux"
+
+	// PlaybackTranscodeAudio copies the video and transcodes the audio
+	PlaybackTranscodeAudio PlaybackMethod = "transcode_audio"
+
+	// PlaybackTranscode transcodes the video and audio
+	PlaybackTranscode PlaybackMethod = "transcode"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// PlaybackMethodFromString returns the playback method for a string
+func PlaybackMethodFromString(str string) (PlaybackMethod, error) {
+	switch PlaybackMethod(str) {
+	case PlaybackDirectPlay, PlaybackRemux, PlaybackTranscodeAudio, PlaybackTranscode:
+		return PlaybackMethod(str), nil
+	}
+
+	return "", fmt.Errorf("unknown playback method %q", str)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ClientCapabilities describes what a client plays natively. Codecs and containers use the
+// names of ffprobe (h264, hevc, aac, mp4, mkv, ...). A max height or bitrate of 0 is unlimited
+type ClientCapabilities struct {
+	VideoCodecs []string
+	AudioCodecs []string
+	Containers  []string
+	MaxHeight   uint32
+	MaxBitrate  uint32
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// supports returns true when the list holds the name, ignoring case
+func supports(names []string, name string) bool {
+	return slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, name) })
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// PlaybackDecision is how a client should play an asset and why
+type PlaybackDecision struct {
+	Method PlaybackMethod
+	Reason string
+
+	// Format is the format of the HLS segments. It is unused for direct play
+	Format Format
+
+	// Quality is the quality of the video. It is Original unless the video is transcoded
+	Quality Quality
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// remuxAudioCodecs maps the audio codecs that can be copied into HLS segments to their RFC 6381
+// codec string
+var remuxAudioCodecs = map[string]string{
+	"aac":  aacCodec,
+	"mp3":  "mp4a.40.34",
+	"ac3":  "ac-3",
+	"eac3": "ec-3",
+	"opus": "Opus",
+	"flac": "fLaC",
+}
+
+// tsCodecs are the codecs that can be copied into MPEG-TS segments. Any other codec that can be
+// copied needs fMP4
+var tsCodecs = []string{"h264", "aac", "mp3", "ac3", "eac3"}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// canRemux returns true when the track can be copied into HLS segments
+func (a Audio) canRemux() bool {
+	_, ok := remuxAudioCodecs[a.Codec]
+	return ok
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// remuxMimeCodec returns the codec string of the track when copied
+func (a Audio) remuxMimeCodec() string {
+	if a.MimeCodec != nil {
+		return *a.MimeCodec
+	}
+
+	return remuxAudioCodecs[a.Codec]
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// canRemux returns true when the video can be copied into HLS segments, which is the original
+// quality
+func (v *Video) canRemux() bool {
+	_, ok := passthroughCodecs[v.Codec]
+	return ok || v.Codec == "h264"
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DecidePlayback decides how a client with the given capabilities plays the asset, from the
+// cheapest to the most expensive method:
+//   - Direct play when the client plays the file as-is
+//   - Remux when it plays the codecs but not the container
+//   - Transcode audio when it only plays the video
+//   - Transcode otherwise
+//
+// The format is used for the HLS segments, unless a copied codec needs fMP4
+func (sw *StreamWrapper) DecidePlayback(caps ClientCapabilities, format Format, audioLanguage string) PlaybackDecision {
+	video := sw.defaultVideo()
+	audio := sw.defaultAudio(audioLanguage)
+	container := strings.TrimPrefix(strings.ToLower(filepath.Ext(sw.Info.Path)), ".")
+
+	decision := PlaybackDecision{Format: format, Quality: Original}
+
+	// copyCodec switches to fMP4 when MPEG-TS cannot carry a copied codec
+	copyCodec := func(codec string) {
+		if !slices.Contains(tsCodecs, codec) {
+			decision.Format = FormatFMP4
+		}
+	}
+
+	if video != nil {
+		reason := ""
+		switch {
+		case !supports(caps.VideoCodecs, video.Codec):
+			reason = fmt.Sprintf("video codec %s is not supported", video.Codec)
+		case caps.MaxHeight > 0 && video.Height > caps.MaxHeight:
+			reason = fmt.Sprintf("video height %d exceeds the max height of %d", video.Height, caps.MaxHeight)
+		case caps.MaxBitrate > 0 && video.Bitrate > caps.MaxBitrate:
+			reason = fmt.Sprintf("video bitrate %d exceeds the max bitrate of %d", video.Bitrate, caps.MaxBitrate)
+		case !video.canRemux():
+			reason = fmt.Sprintf("video codec %s cannot be remuxed", video.Codec)
+		}
+
+		if reason != "" {
+			decision.Method = PlaybackTranscode
+			decision.Reason = reason
+			decision.Quality = sw.transcodeQuality(caps)
+			return decision
+		}
+
+		copyCodec(video.Codec)
+	}
+
+	if audio != nil {
+		reason := ""
+		switch {
+		case !supports(caps.AudioCodecs, audio.Codec):
+			reason = fmt.Sprintf("audio codec %s is not supported", audio.Codec)
+		case !audio.canRemux():
+			reason = fmt.Sprintf("audio codec %s cannot be remuxed", audio.Codec)
+		case audio.LoudnessGain != 0:
+			// Copied audio cannot be normalized
+			reason = "the audio is loudness normalized"
+		}
+
+		if reason != "" {
+			decision.Method = PlaybackTranscodeAudio
+			decision.Reason = reason
+			return decision
+		}
+
+		copyCodec(audio.Codec)
+	}
+
+	switch {
+	case !supports(caps.Containers, container):
+		decision.Method = PlaybackRemux
+		decision.Reason = fmt.Sprintf("container %s is not supported", container)
+	case audio != nil && len(sw.Info.Audios) > 1 && !audio.IsDefault:
+		// Players only play the default track of the file
+		decision.Method = PlaybackRemux
+		decision.Reason = "the preferred audio track is not the default track of the file"
+	default:
+		decision.Method = PlaybackDirectPlay
+		decision.Reason = "the client plays the original file"
+		decision.Format = ""
+	}
+
+	return decision
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// transcodeQuality returns the highest transcoded quality within the max height and bitrate of
+// the client, falling back to the lowest quality
+func (sw *StreamWrapper) transcodeQuality(caps ClientCapabilities) Quality {
+	qualities := GetQualitiesHighestToLowest(sw.GetQualities())
+	if len(qualities) == 0 {
+		return P720
+	}
+
+	for _, quality := range qualities {
+		if caps.MaxHeight > 0 && quality.Height() > caps.MaxHeight {
+			continue
+		}
+
+		if caps.MaxBitrate > 0 && sw.profile.MaxBitrate(quality) > caps.MaxBitrate {
+			continue
+		}
+
+		return quality
+	}
+
+	return qualities[len(qualities)-1]
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// remuxAudioGroup returns the audio group of the remux master playlist. Only the codec of the
+// default track is known to play on the client, so tracks in that codec are copied and the
+// others are transcoded to AAC, keeping every language available
+func (sw *StreamWrapper) remuxAudioGroup(audioLanguage string) audioGroup {
+	group := audioGroup{id: remuxAudioGroup}
+
+	if def_audio := sw.defaultAudio(audioLanguage); def_audio != nil && def_audio.canRemux() {
+		group.remuxCodec = def_audio.Codec
+	}
+
+	for _, audio := range sw.Info.Audios {
+		codec := aacCodec
+		if audio.Codec == group.remuxCodec {
+			codec = audio.remuxMimeCodec()
+		}
+
+		if !slices.Contains(group.codecs, codec) {
+			group.codecs = append(group.codecs, codec)
+		}
+	}
+
+	return group
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetMasterPlaylistPlayback returns the master playlist for a playback decision. Remuxed assets
+// copy the original video and audio tracks, assets with transcoded audio copy the original
+// video and other assets play a single transcoded quality
+func (sw *StreamWrapper) GetMasterPlaylistPlayback(assetID string, decision PlaybackDecision, audioLanguage string, subtitles []Subtitle) string {
+	if sw.isAudioOnly() {
+		if decision.Method != PlaybackRemux {
+			return sw.getAudioOnlyMasterPlaylist(assetID, decision.Format, audioLanguage, subtitles)
+		}
+
+		return sw.getAudioOnlyRemuxMasterPlaylist(assetID, decision.Format, audioLanguage, subtitles)
+	}
+
+	switch decision.Method {
+	case PlaybackRemux:
+		return sw.singleMasterPlaylist(assetID, decision.Format, Original, []audioGroup{sw.remuxAudioGroup(audioLanguage)}, audioLanguage, subtitles)
+	case PlaybackTranscode:
+		return sw.singleMasterPlaylist(assetID, decision.Format, decision.Quality, sw.audioGroups(), audioLanguage, subtitles)
+	default:
+		return sw.singleMasterPlaylist(assetID, decision.Format, Original, sw.audioGroups(), audioLanguage, subtitles)
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// getAudioOnlyRemuxMasterPlaylist returns the master playlist for a remuxed audio-only asset,
+// with the default track copied as-is
+func (sw *StreamWrapper) getAudioOnlyRemuxMasterPlaylist(assetID string, format Format, audioLanguage string, subtitles []Subtitle) string {
+	def_audio := sw.defaultAudio(audioLanguage)
+	if !def_audio.canRemux() {
+		return sw.getAudioOnlyMasterPlaylist(assetID, format, audioLanguage, subtitles)
+	}
+
+	master := "#EXTM3U\n"
+
+	// Add subtitle media groups
+	master += sw.subtitleMediaGroups(subtitles)
+
+	master += "\n"
+
+	// The bitrate of audio tracks is not probed, so the bitrate of the profile is assumed
+	bitrate := int(def_audio.Bitrate)
+	if bitrate == 0 {
+		bitrate = int(sw.profile.AudioBitrate)
+	}
+
+	master += "#EXT-X-STREAM-INF:"
+	master += fmt.Sprintf("AVERAGE-BANDWIDTH=%d,", int(float64(bitrate)*0.8))
+	master += fmt.Sprintf("BANDWIDTH=%d,", bitrate)
+	master += fmt.Sprintf("CODECS=\"%s\",", def_audio.remuxMimeCodec())
+	if sw.hasSubtitles(subtitles) {
+		master += "SUBTITLES=\"subs\","
+	}
+	master += "CLOSED-CAPTIONS=NONE\n"
+	master += fmt.Sprintf("/api/hls/%s/%saudio/%d/%s/index.m3u8\n", assetID, format.pathPrefix(), def_audio.Index, AudioRemux)
+
+	return master
+}
@@ -0,0 +1,192 @@
+package hls
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestAudioModeFromString(t *testing.T) {
+	for str, expected := range map[string]AudioMode{"": AudioStereo, "surround": AudioSurround, "remux": AudioRemux} {
+		mode, err := AudioModeFromString(str)
+		require.NoError(t, err)
+		require.Equal(t, expected, mode)
+	}
+
+	_, err := AudioModeFromString("atmos")
+	require.Error(t, err)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStreamWrapper_DecidePlayback(t *testing.T) {
+	english, french := "eng", "fre"
+
+	newStreamWrapper := func(path, videoCodec string, audios ...Audio) *StreamWrapper {
+		return &StreamWrapper{
+			profile: DefaultProfile(),
+			Info: &MediaInfo{
+				Path:     path,
+				Duration: 90.5,
+				Videos:   []Video{{Index: 0, Codec: videoCodec, Width: 1920, Height: 1080, Bitrate: 5000000, IsDefault: true}},
+				Audios:   audios,
+			},
+		}
+	}
+
+	aac := Audio{Index: 0, Language: &english, Codec: "aac", Channels: 2, IsDefault: true}
+	browser := ClientCapabilities{
+		VideoCodecs: []string{"h264", "av1"},
+		AudioCodecs: []string{"aac", "opus"},
+		Containers:  []string{"mp4", "webm"},
+	}
+
+	t.Run("direct play", func(t *testing.T) {
+		decision := newStreamWrapper("/course/video.MP4", "h264", aac).DecidePlayback(browser, FormatTS, "")
+		require.Equal(t, PlaybackDirectPlay, decision.Method)
+		require.Equal(t, "the client plays the original file", decision.Reason)
+	})
+
+	t.Run("remux", func(t *testing.T) {
+		decision := newStreamWrapper("/course/video.mkv", "h264", aac).DecidePlayback(browser, FormatTS, "")
+		require.Equal(t, PlaybackRemux, decision.Method)
+		require.Equal(t, "container mkv is not supported", decision.Reason)
+		require.Equal(t, FormatTS, decision.Format)
+		require.Equal(t, Original, decision.Quality)
+
+		// AV1 and Opus are only carried by fMP4
+		opus := Audio{Index: 0, Codec: "opus", Channels: 2, IsDefault: true}
+		decision = newStreamWrapper("/course/video.mkv", "av1", opus).DecidePlayback(browser, FormatTS, "")
+		require.Equal(t, PlaybackRemux, decision.Method)
+		require.Equal(t, FormatFMP4, decision.Format)
+
+		// Players only play the default track of the file
+		fre := Audio{Index: 1, Language: &french, Codec: "aac", Channels: 2}
+		decision = newStreamWrapper("/course/video.mp4", "h264", aac, fre).DecidePlayback(browser, FormatTS, "fre")
+		require.Equal(t, PlaybackRemux, decision.Method)
+		require.Equal(t, "the preferred audio track is not the default track of the file", decision.Reason)
+	})
+
+	t.Run("transcode audio", func(t *testing.T) {
+		eac3 := Audio{Index: 0, Codec: "eac3", Channels: 6, IsDefault: true}
+		decision := newStreamWrapper("/course/video.mp4", "h264", eac3).DecidePlayback(browser, FormatTS, "")
+		require.Equal(t, PlaybackTranscodeAudio, decision.Method)
+		require.Equal(t, "audio codec eac3 is not supported", decision.Reason)
+
+		// The client plays DTS, but it cannot be copied
+		dts := Audio{Index: 0, Codec: "dts", Channels: 6, IsDefault: true}
+		caps := browser
+		caps.AudioCodecs = []string{"DTS"}
+		decision = newStreamWrapper("/course/video.mp4", "h264", dts).DecidePlayback(caps, FormatTS, "")
+		require.Equal(t, PlaybackTranscodeAudio, decision.Method)
+		require.Equal(t, "audio codec dts cannot be remuxed", decision.Reason)
+
+		// Normalized audio is never copied
+		sw := newStreamWrapper("/course/video.mp4", "h264", aac)
+		sw.Info.Audios[0].LoudnessGain = -2.5
+		decision = sw.DecidePlayback(browser, FormatTS, "")
+		require.Equal(t, PlaybackTranscodeAudio, decision.Method)
+		require.Equal(t, "the audio is loudness normalized", decision.Reason)
+	})
+
+	t.Run("transcode", func(t *testing.T) {
+		decision := newStreamWrapper("/course/video.mp4", "hevc", aac).DecidePlayback(browser, FormatTS, "")
+		require.Equal(t, PlaybackTranscode, decision.Method)
+		require.Equal(t, "video codec hevc is not supported", decision.Reason)
+		require.Equal(t, P1080, decision.Quality)
+
+		caps := browser
+		caps.MaxHeight = 720
+		decision = newStreamWrapper("/course/video.mp4", "h264", aac).DecidePlayback(caps, FormatTS, "")
+		require.Equal(t, PlaybackTranscode, decision.Method)
+		require.Equal(t, "video height 1080 exceeds the max height of 720", decision.Reason)
+		require.Equal(t, P720, decision.Quality)
+
+		caps = browser
+		caps.MaxBitrate = DefaultProfile().MaxBitrate(P480)
+		decision = newStreamWrapper("/course/video.mp4", "h264", aac).DecidePlayback(caps, FormatTS, "")
+		require.Equal(t, PlaybackTranscode, decision.Method)
+		require.Contains(t, decision.Reason, "exceeds the max bitrate")
+		require.Equal(t, P480, decision.Quality)
+
+		// The lowest quality when nothing fits
+		caps.MaxBitrate = 1
+		decision = newStreamWrapper("/course/video.mp4", "h264", aac).DecidePlayback(caps, FormatTS, "")
+		require.Equal(t, P240, decision.Quality)
+	})
+
+	t.Run("audio only", func(t *testing.T) {
+		sw := newStreamWrapper("/course/lecture.flac", "", Audio{Index: 0, Codec: "flac", Channels: 2, IsDefault: true})
+		sw.Info.Videos = nil
+
+		caps := browser
+		caps.AudioCodecs = []string{"flac"}
+		decision := sw.DecidePlayback(caps, FormatTS, "")
+		require.Equal(t, PlaybackRemux, decision.Method)
+		require.Equal(t, FormatFMP4, decision.Format)
+
+		caps.Containers = []string{"flac"}
+		require.Equal(t, PlaybackDirectPlay, sw.DecidePlayback(caps, FormatTS, "").Method)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStreamWrapper_MasterPlaylistPlayback(t *testing.T) {
+	english, french := "eng", "fre"
+
+	sw := &StreamWrapper{
+		profile: DefaultProfile(),
+		Info: &MediaInfo{
+			Duration: 90.5,
+			Videos:   []Video{{Index: 0, Codec: "h264", Width: 1920, Height: 1080, Bitrate: 5000000, IsDefault: true}},
+			Audios: []Audio{
+				{Index: 0, Language: &english, Codec: "opus", Channels: 2, IsDefault: true},
+				{Index: 1, Language: &french, Codec: "aac", Channels: 2},
+			},
+		},
+	}
+
+	t.Run("remux", func(t *testing.T) {
+		master := sw.GetMasterPlaylistPlayback("asset", PlaybackDecision{Method: PlaybackRemux, Format: FormatFMP4, Quality: Original}, "", nil)
+		require.Contains(t, master, "GROUP-ID=\"audio-remux\",LANGUAGE=\"eng\",NAME=\"eng\",DEFAULT=YES,AUTOSELECT=YES,CHANNELS=\"2\",URI=\"fmp4/audio/0/remux/index.m3u8\"")
+
+		// Only tracks in the codec of the default track are copied
+		require.Contains(t, master, "LANGUAGE=\"fre\",NAME=\"fre\",AUTOSELECT=YES,CHANNELS=\"2\",URI=\"fmp4/audio/1/index.m3u8\"")
+		require.Contains(t, master, "RESOLUTION=1920x1080,CODECS=\"avc1.42E01E,Opus,mp4a.40.2\",AUDIO=\"audio-remux\",CLOSED-CAPTIONS=NONE\n/api/hls/asset/fmp4/video/0/original/index.m3u8\n")
+		require.NotContains(t, master, "GROUP-ID=\"audio\",")
+	})
+
+	t.Run("transcode audio", func(t *testing.T) {
+		master := sw.GetMasterPlaylistPlayback("asset", PlaybackDecision{Method: PlaybackTranscodeAudio, Format: FormatTS, Quality: Original}, "", nil)
+		require.Equal(t, sw.GetMasterPlaylistSingle("asset", FormatTS, false, "", nil), master)
+	})
+
+	t.Run("transcode", func(t *testing.T) {
+		master := sw.GetMasterPlaylistPlayback("asset", PlaybackDecision{Method: PlaybackTranscode, Format: FormatTS, Quality: P480}, "", nil)
+		require.Contains(t, master, "RESOLUTION=853x480,")
+		require.Contains(t, master, "/api/hls/asset/video/0/480p/index.m3u8\n")
+		require.NotContains(t, master, "remux")
+	})
+
+	t.Run("audio only", func(t *testing.T) {
+		audioOnly := &StreamWrapper{
+			profile: DefaultProfile(),
+			Info:    &MediaInfo{Duration: 60, Audios: []Audio{{Index: 0, Codec: "mp3", Channels: 2, IsDefault: true}}},
+		}
+
+		master := audioOnly.GetMasterPlaylistPlayback("asset", PlaybackDecision{Method: PlaybackRemux, Format: FormatTS}, "", nil)
+		require.Contains(t, master, "CODECS=\"mp4a.40.34\",CLOSED-CAPTIONS=NONE\n/api/hls/asset/audio/0/remux/index.m3u8\n")
+
+		master = audioOnly.GetMasterPlaylistPlayback("asset", PlaybackDecision{Method: PlaybackTranscodeAudio, Format: FormatTS}, "", nil)
+		require.Contains(t, master, "/api/hls/asset/audio/0/index.m3u8\n")
+	})
+
+	t.Run("remux stream", func(t *testing.T) {
+		as := &AudioStream{Stream: Stream{streamWrapper: sw, format: FormatFMP4}, index: 0, mode: AudioRemux}
+		require.Equal(t, "audio-0-remux-fmp4", as.getName())
+		require.Equal(t, []string{"-map", "0:a:0", "-c:a", "copy"}, as.getTranscodeArgs(""))
+	})
+}
@@ -0,0 +1,727 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/geerew/off-course/utils/security"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+var (
+	ErrPretranscodeJobNotFound = errors.New("pre-transcode job not found")
+	ErrInvalidTimeWindow       = errors.New("time window must be in the form HH:MM-HH:MM")
+	ErrNoQualities             = errors.New("at least one quality is required")
+	ErrAssetNotPlayable        = errors.New("asset is not a video or audio file")
+	ErrAssetNotFound           = errors.New("asset not found")
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// PretranscodeJobStatus is the state of a pre-transcode job
+type PretranscodeJobStatus string
+
+const (
+	PretranscodeWaiting    PretranscodeJobStatus = "waiting"
+	PretranscodeProcessing PretranscodeJobStatus = "processing"
+	PretranscodeCompleted  PretranscodeJobStatus = "completed"
+	PretranscodeFailed     PretranscodeJobStatus = "failed"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// PretranscodeJob is a request to fully transcode an asset to a set of qualities. The audio
+// tracks of the asset are always included
+type PretranscodeJob struct {
+	ID        string
+	AssetID   string
+	Qualities []Quality
+	Status    PretranscodeJobStatus
+	Error     string
+	Done      int
+	Total     int
+	CreatedAt time.Time
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// pretranscodeJob tracks the internal state of a job
+type pretranscodeJob struct {
+	PretranscodeJob
+	cancel      context.CancelFunc
+	cancelled   bool
+	interrupted bool
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// PretranscodeQueueStatus describes the state of the pre-transcode queue
+type PretranscodeQueueStatus struct {
+	Paused   bool
+	Window   TimeWindow
+	InWindow bool
+	Jobs     []PretranscodeJob
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Pretranscoder fully transcodes assets ahead of time, one at a time, so that playback is
+// served from a complete set of segments without running ffmpeg
+//
+// Jobs only run while the queue is not paused and the current time is within the window. A
+// running job that is paused or falls outside the window is stopped and starts again from the
+// beginning of the stream it was working on
+type Pretranscoder struct {
+	transcoder *Transcoder
+	dao        *dao.DAO
+	logger     *logger.Logger
+
+	mu     sync.Mutex
+	jobs   []*pretranscodeJob
+	window TimeWindow
+	paused atomic.Bool
+	wake   chan struct{}
+
+	// now returns the current time (overridable for tests)
+	now func() time.Time
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// PretranscoderConfig defines the configuration for a Pretranscoder
+type PretranscoderConfig struct {
+	Transcoder *Transcoder
+
+	// Window is the time of day, in the form HH:MM-HH:MM, during which jobs run. When empty,
+	// jobs run at any time
+	Window string
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewPretranscoder creates a new Pretranscoder
+func NewPretranscoder(config *PretranscoderConfig) (*Pretranscoder, error) {
+	window, err := ParseTimeWindow(config.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pretranscoder{
+		transcoder: config.Transcoder,
+		dao:        config.Transcoder.config.Dao,
+		logger:     config.Transcoder.config.Logger,
+		window:     window,
+		wake:       make(chan struct{}, 1),
+		now:        time.Now,
+	}, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AddAsset queues an asset to be transcoded to the given qualities. When the asset is already
+// waiting, the qualities are added to the existing job
+func (p *Pretranscoder) AddAsset(ctx context.Context, assetID string, qualities []Quality) (*PretranscodeJob, error) {
+	if len(qualities) == 0 {
+		return nil, ErrNoQualities
+	}
+
+	asset, err := p.dao.GetAsset(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_ID: assetID}))
+	if err != nil {
+		return nil, err
+	}
+
+	if asset == nil {
+		return nil, ErrAssetNotFound
+	}
+
+	if !asset.Type.IsVideo() && !asset.Type.IsAudio() {
+		return nil, ErrAssetNotPlayable
+	}
+
+	job := p.add(assetID, qualities)
+	p.signal()
+
+	return &job, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AddCourse queues every video and audio asset of a course to be transcoded to the given
+// qualities
+func (p *Pretranscoder) AddCourse(ctx context.Context, courseID string, qualities []Quality) ([]PretranscodeJob, error) {
+	if len(qualities) == 0 {
+		return nil, ErrNoQualities
+	}
+
+	course, err := p.dao.GetCourse(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseID}))
+	if err != nil {
+		return nil, err
+	}
+
+	if course == nil {
+		return nil, utils.ErrCourseNotFound
+	}
+
+	assets, err := p.dao.ListAssets(ctx, dao.NewOptions().
+		WithWhere(squirrel.Eq{
+			models.ASSET_TABLE_COURSE_ID: courseID,
+			models.ASSET_TABLE_TYPE:      []types.AssetType{types.AssetVideo, types.AssetAudio},
+		}))
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]PretranscodeJob, 0, len(assets))
+	for _, asset := range assets {
+		jobs = append(jobs, p.add(asset.ID, qualities))
+	}
+
+	p.signal()
+
+	return jobs, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// add queues a job for the asset, or merges the qualities into a waiting job for the same asset,
+// and returns a copy of the job
+func (p *Pretranscoder) add(assetID string, qualities []Quality) PretranscodeJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, job := range p.jobs {
+		if job.AssetID != assetID || job.Status != PretranscodeWaiting {
+			continue
+		}
+
+		for _, quality := range qualities {
+			if !slices.Contains(job.Qualities, quality) {
+				job.Qualities = append(job.Qualities, quality)
+			}
+		}
+
+		return job.copy()
+	}
+
+	job := &pretranscodeJob{
+		PretranscodeJob: PretranscodeJob{
+			ID:        security.PseudorandomString(10),
+			AssetID:   assetID,
+			Qualities: slices.Clone(qualities),
+			Status:    PretranscodeWaiting,
+			CreatedAt: p.now(),
+		},
+	}
+
+	p.jobs = append(p.jobs, job)
+
+	p.logger.Info().
+		Str("job_id", job.ID).
+		Str("asset_id", assetID).
+		Msg("Queued asset for pre-transcoding")
+
+	return job.copy()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Cancel removes a job from the queue. A processing job is stopped and removed once ffmpeg
+// exits
+func (p *Pretranscoder) Cancel(jobID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idx := slices.IndexFunc(p.jobs, func(job *pretranscodeJob) bool { return job.ID == jobID })
+	if idx == -1 {
+		return ErrPretranscodeJobNotFound
+	}
+
+	job := p.jobs[idx]
+	if job.Status == PretranscodeProcessing {
+		job.cancelled = true
+		job.cancel()
+	} else {
+		p.jobs = slices.Delete(p.jobs, idx, idx+1)
+	}
+
+	p.logger.Info().
+		Str("job_id", job.ID).
+		Str("asset_id", job.AssetID).
+		Msg("Cancelled pre-transcode job")
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetQueueStatus returns the current state of the queue
+func (p *Pretranscoder) GetQueueStatus() PretranscodeQueueStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := PretranscodeQueueStatus{
+		Paused:   p.IsPaused(),
+		Window:   p.window,
+		InWindow: p.window.Contains(p.now()),
+		Jobs:     make([]PretranscodeJob, 0, len(p.jobs)),
+	}
+
+	for _, job := range p.jobs {
+		status.Jobs = append(status.Jobs, job.copy())
+	}
+
+	return status
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Pause stops the running job and prevents new jobs from starting
+func (p *Pretranscoder) Pause() {
+	if !p.paused.Swap(true) {
+		p.logger.Info().Msg("Paused pre-transcode queue")
+		p.signal()
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Resume allows jobs to run again
+func (p *Pretranscoder) Resume() {
+	if p.paused.Swap(false) {
+		p.logger.Info().Msg("Resumed pre-transcode queue")
+		p.signal()
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// IsPaused returns whether the queue is paused
+func (p *Pretranscoder) IsPaused() bool {
+	return p.paused.Load()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// SetWindow changes the time of day during which jobs run. An empty string removes the window
+func (p *Pretranscoder) SetWindow(window string) error {
+	parsed, err := ParseTimeWindow(window)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.window = parsed
+	p.mu.Unlock()
+
+	p.logger.Info().Str("window", parsed.String()).Msg("Changed pre-transcode window")
+	p.signal()
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Worker removes complete sets of deleted assets, then runs jobs as they are queued, checking
+// the window every minute
+func (p *Pretranscoder) Worker(ctx context.Context) {
+	if err := p.pruneComplete(ctx); err != nil {
+		p.logger.Error().Err(err).Msg("Failed to prune complete segment sets")
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		p.schedule(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// signal wakes the worker
+func (p *Pretranscoder) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// schedule starts the next waiting job when the queue may run, or interrupts the processing job
+// when it may not
+func (p *Pretranscoder) schedule(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	canRun := !p.IsPaused() && p.window.Contains(p.now())
+
+	var next *pretranscodeJob
+	for _, job := range p.jobs {
+		if job.Status == PretranscodeProcessing {
+			if !canRun && !job.interrupted {
+				job.interrupted = true
+				job.cancel()
+			}
+
+			return
+		}
+
+		if next == nil && job.Status == PretranscodeWaiting {
+			next = job
+		}
+	}
+
+	if !canRun || next == nil {
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	next.Status = PretranscodeProcessing
+	next.cancel = cancel
+
+	go p.run(jobCtx, next)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// run processes a job and records the outcome
+func (p *Pretranscoder) run(ctx context.Context, job *pretranscodeJob) {
+	p.logger.Info().
+		Str("job_id", job.ID).
+		Str("asset_id", job.AssetID).
+		Msg("Started pre-transcode job")
+
+	err := p.process(ctx, job)
+
+	p.mu.Lock()
+
+	job.cancel()
+	job.cancel = nil
+
+	switch {
+	case job.cancelled:
+		p.jobs = slices.DeleteFunc(p.jobs, func(j *pretranscodeJob) bool { return j == job })
+	case err == nil:
+		job.Status = PretranscodeCompleted
+		p.logger.Info().
+			Str("job_id", job.ID).
+			Str("asset_id", job.AssetID).
+			Msg("Finished pre-transcode job")
+	case job.interrupted:
+		job.Status = PretranscodeWaiting
+		job.interrupted = false
+		p.logger.Info().
+			Str("job_id", job.ID).
+			Str("asset_id", job.AssetID).
+			Msg("Stopped pre-transcode job until the queue can run again")
+	default:
+		job.Status = PretranscodeFailed
+		job.Error = err.Error()
+		p.logger.Error().
+			Err(err).
+			Str("job_id", job.ID).
+			Str("asset_id", job.AssetID).
+			Msg("Failed pre-transcode job")
+	}
+
+	p.mu.Unlock()
+
+	p.signal()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// process transcodes the streams of a job that do not yet have a complete set. Qualities higher
+// than the video are skipped
+func (p *Pretranscoder) process(ctx context.Context, job *pretranscodeJob) error {
+	asset, err := p.dao.GetAsset(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_ID: job.AssetID}))
+	if err != nil {
+		return err
+	}
+
+	if asset == nil {
+		return ErrAssetNotFound
+	}
+
+	sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+	if err != nil {
+		return err
+	}
+
+	// Let the tracker clean up the stream wrapper once it is no longer used
+	p.transcoder.assetChan <- asset.ID
+
+	streams := []*Stream{}
+
+	// MPEG-TS streams by name, which the fMP4 sets of the same streams are remuxed from
+	sources := map[string]*Stream{}
+
+	// Both formats get a complete set, cut at the same keyframes. The MPEG-TS streams come first
+	// so the fMP4 sets can copy their segments instead of encoding again
+	for _, format := range []Format{FormatTS, FormatFMP4} {
+		if video := sw.defaultVideo(); video != nil {
+			available := append(sw.GetQualities(), NoResize)
+			for _, quality := range job.Qualities {
+				if !slices.Contains(available, quality) {
+					continue
+				}
+
+				vs, err := NewVideoStream(sw, video.Index, quality, format)
+				if err != nil {
+					return err
+				}
+
+				streams = append(streams, &vs.Stream)
+				if format == FormatTS {
+					sources[vs.streamer.getName()] = &vs.Stream
+				}
+			}
+		}
+
+		for _, audio := range sw.Info.Audios {
+			as, err := NewAudioStream(sw, audio.Index, AudioStereo, format)
+			if err != nil {
+				return err
+			}
+
+			streams = append(streams, &as.Stream)
+			if format == FormatTS {
+				sources[as.streamer.getName()] = &as.Stream
+			}
+
+			if sw.profile.SurroundPassthrough && audio.canPassthrough() {
+				as, err := NewAudioStream(sw, audio.Index, AudioSurround, format)
+				if err != nil {
+					return err
+				}
+
+				streams = append(streams, &as.Stream)
+				if format == FormatTS {
+					sources[as.streamer.getName()] = &as.Stream
+				}
+			}
+		}
+	}
+
+	total, done := 0, 0
+	for _, stream := range streams {
+		total += len(stream.keyframes)
+		if stream.complete != "" {
+			done += len(stream.keyframes)
+		}
+	}
+
+	p.setProgress(job, done, total)
+
+	for _, stream := range streams {
+		if stream.complete != "" {
+			continue
+		}
+
+		completed := 0
+		progress := func() {
+			completed++
+			p.setProgress(job, done+completed, total)
+		}
+
+		// The original quality is copied from the source, which MPEG-TS may not carry
+		source := sources[strings.TrimSuffix(stream.streamer.getName(), FormatFMP4.dirSuffix())]
+		if stream.format == FormatFMP4 && stream.streamer.getFlags()&Transmux == 0 && source != nil && source.complete != "" {
+			err = stream.remuxAll(ctx, source.complete, stream.completeDir(), progress)
+		} else {
+			err = stream.transcodeAll(ctx, stream.completeDir(), progress)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		stream.loadCompleteSet()
+
+		done += len(stream.keyframes)
+		p.setProgress(job, done, total)
+
+		// Streams already open for playback switch to the complete set
+		sw.loadCompleteSets()
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// setProgress updates the number of segments a job has written
+func (p *Pretranscoder) setProgress(job *pretranscodeJob, done, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	job.Done = done
+	job.Total = total
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// pruneComplete removes the complete sets of assets that no longer exist, along with any
+// temporary directories left by an interrupted transcode
+func (p *Pretranscoder) pruneComplete(ctx context.Context) error {
+	fs := p.transcoder.config.AppFs
+	dir := filepath.Join(p.transcoder.cachePath, completeDirName)
+
+	if exists, err := afero.DirExists(fs.Fs, dir); err != nil || !exists {
+		return err
+	}
+
+	assetIDs, err := fs.PathItems(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, assetID := range assetIDs {
+		assetDir := filepath.Join(dir, assetID)
+
+		count, err := p.dao.CountAssets(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.ASSET_TABLE_ID: assetID}))
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			if err := fs.Fs.RemoveAll(assetDir); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		items, err := fs.PathItems(assetDir)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			if strings.HasSuffix(item, ".tmp") {
+				if err := fs.Fs.RemoveAll(filepath.Join(assetDir, item)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// copy returns a copy of the public state of the job
+func (j *pretranscodeJob) copy() PretranscodeJob {
+	job := j.PretranscodeJob
+	job.Qualities = slices.Clone(j.Qualities)
+	return job
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// TimeWindow is a time of day, in minutes since midnight, during which pre-transcoding runs. A
+// window whose end is before its start spans midnight. The zero value is always open
+type TimeWindow struct {
+	Start int
+	End   int
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ParseTimeWindow parses a window in the form HH:MM-HH:MM, such as 23:00-06:00. An empty
+// string is the always open window
+func ParseTimeWindow(window string) (TimeWindow, error) {
+	window = strings.TrimSpace(window)
+	if window == "" {
+		return TimeWindow{}, nil
+	}
+
+	start, end, ok := strings.Cut(window, "-")
+	if !ok {
+		return TimeWindow{}, ErrInvalidTimeWindow
+	}
+
+	startMinutes, err := parseTimeOfDay(start)
+	if err != nil {
+		return TimeWindow{}, err
+	}
+
+	endMinutes, err := parseTimeOfDay(end)
+	if err != nil {
+		return TimeWindow{}, err
+	}
+
+	return TimeWindow{Start: startMinutes, End: endMinutes}, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// parseTimeOfDay parses HH:MM into minutes since midnight
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, ErrInvalidTimeWindow
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// IsAlways returns true when the window is always open
+func (w TimeWindow) IsAlways() bool {
+	return w.Start == w.End
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Contains returns true when the time of day of t is within the window. The start is inclusive
+// and the end is exclusive
+func (w TimeWindow) Contains(t time.Time) bool {
+	if w.IsAlways() {
+		return true
+	}
+
+	minutes := t.Hour()*60 + t.Minute()
+
+	if w.Start < w.End {
+		return minutes >= w.Start && minutes < w.End
+	}
+
+	return minutes >= w.Start || minutes < w.End
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// String returns the window in the form HH:MM-HH:MM, or an empty string when it is always open
+func (w TimeWindow) String() string {
+	if w.IsAlways() {
+		return ""
+	}
+
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
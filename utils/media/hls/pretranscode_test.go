@@ -0,0 +1,447 @@
+package hls
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func setupPretranscoder(t *testing.T, fs afero.Fs) (*Pretranscoder, context.Context) {
+	t.Helper()
+
+	dbManager, err := database.NewSQLiteManager(&database.DatabaseManagerConfig{
+		DataDir: "./oc_data",
+		AppFs:   appfs.New(afero.NewMemMapFs()),
+		Testing: true,
+	})
+	require.NoError(t, err)
+
+	transcoder, err := NewTranscoder(&TranscoderConfig{
+		CachePath: "/data",
+		AppFs:     appfs.New(fs),
+		Logger:    logger.NilLogger(),
+		Dao:       dao.New(dbManager.DataDb),
+	})
+	require.NoError(t, err)
+
+	pretranscoder, err := NewPretranscoder(&PretranscoderConfig{Transcoder: transcoder})
+	require.NoError(t, err)
+
+	return pretranscoder, context.Background()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// createPretranscodeAsset creates a course with a lesson holding an asset at the path. Video
+// assets are given 1080p metadata and, when set, keyframes
+func createPretranscodeAsset(t *testing.T, p *Pretranscoder, ctx context.Context, path string, keyframes []float64) *models.Asset {
+	t.Helper()
+
+	course := &models.Course{Title: filepath.Base(path), Path: filepath.Join(filepath.Dir(path), "course-"+filepath.Base(path))}
+	require.NoError(t, p.dao.CreateCourse(ctx, course))
+
+	lesson := &models.Lesson{CourseID: course.ID, Title: "Intro", Prefix: sql.NullInt16{Int16: 1, Valid: true}}
+	require.NoError(t, p.dao.CreateLesson(ctx, lesson))
+
+	assetType, err := types.NewAsset(filepath.Ext(path)[1:])
+	require.NoError(t, err)
+
+	asset := &models.Asset{
+		CourseID: course.ID,
+		LessonID: lesson.ID,
+		Title:    filepath.Base(path),
+		Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+		Type:     assetType,
+		Path:     path,
+		FileSize: 1024,
+		ModTime:  time.Now().Format(time.RFC3339Nano),
+		Hash:     path,
+	}
+	require.NoError(t, p.dao.CreateAsset(ctx, asset))
+
+	if asset.Type.IsVideo() {
+		require.NoError(t, p.dao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 10, Width: 1920, Height: 1080},
+		}))
+
+		if keyframes != nil {
+			require.NoError(t, p.dao.CreateAssetKeyframes(ctx, &models.AssetKeyframes{
+				AssetID:    asset.ID,
+				Keyframes:  keyframes,
+				IsComplete: true,
+			}))
+		}
+	}
+
+	return asset
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// videoFingerprint returns the fingerprint of a video stream of the asset
+func videoFingerprint(t *testing.T, p *Pretranscoder, ctx context.Context, asset *models.Asset, quality Quality, format Format) string {
+	t.Helper()
+
+	sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+	require.NoError(t, err)
+
+	vs, err := NewVideoStream(sw, sw.defaultVideo().Index, quality, format)
+	require.NoError(t, err)
+
+	return completeFingerprint(vs)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// writeCompleteSet writes a complete set of the given format with the given number of segments
+// and encoder fingerprint
+func writeCompleteSet(t *testing.T, fs afero.Fs, dir string, segments int, format Format, fingerprint string) {
+	t.Helper()
+
+	if format == FormatFMP4 {
+		require.NoError(t, afero.WriteFile(fs, filepath.Join(dir, fmp4InitFile), []byte("init"), 0o644))
+	}
+
+	for segment := range int32(segments) {
+		require.NoError(t, afero.WriteFile(fs, completeSegmentPath(dir, segment, format), []byte("segment"), 0o644))
+	}
+
+	require.NoError(t, writeCompleteMarker(fs, dir, segments, fingerprint))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestPretranscoder_Add(t *testing.T) {
+	t.Run("asset", func(t *testing.T) {
+		p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+
+		asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", nil)
+
+		job, err := p.AddAsset(ctx, asset.ID, []Quality{P720})
+		require.NoError(t, err)
+		require.Equal(t, asset.ID, job.AssetID)
+		require.Equal(t, PretranscodeWaiting, job.Status)
+
+		// A waiting job for the same asset gains the new qualities
+		merged, err := p.AddAsset(ctx, asset.ID, []Quality{P720, Original})
+		require.NoError(t, err)
+		require.Equal(t, job.ID, merged.ID)
+		require.Equal(t, []Quality{P720, Original}, merged.Qualities)
+
+		require.Len(t, p.GetQueueStatus().Jobs, 1)
+	})
+
+	t.Run("course", func(t *testing.T) {
+		p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+
+		video := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", nil)
+
+		pdf := &models.Asset{
+			CourseID: video.CourseID,
+			LessonID: video.LessonID,
+			Title:    "notes",
+			Prefix:   sql.NullInt16{Int16: 2, Valid: true},
+			Type:     types.AssetPDF,
+			Path:     "/course/notes.pdf",
+			FileSize: 1024,
+			ModTime:  time.Now().Format(time.RFC3339Nano),
+			Hash:     "notes",
+		}
+		require.NoError(t, p.dao.CreateAsset(ctx, pdf))
+
+		jobs, err := p.AddCourse(ctx, video.CourseID, []Quality{Original})
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		require.Equal(t, video.ID, jobs[0].AssetID)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+
+		asset := createPretranscodeAsset(t, p, ctx, "/course/notes.pdf", nil)
+
+		_, err := p.AddAsset(ctx, asset.ID, nil)
+		require.ErrorIs(t, err, ErrNoQualities)
+
+		_, err = p.AddAsset(ctx, asset.ID, []Quality{Original})
+		require.ErrorIs(t, err, ErrAssetNotPlayable)
+
+		_, err = p.AddAsset(ctx, "missing", []Quality{Original})
+		require.ErrorIs(t, err, ErrAssetNotFound)
+
+		_, err = p.AddCourse(ctx, "missing", []Quality{Original})
+		require.ErrorIs(t, err, utils.ErrCourseNotFound)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestPretranscoder_Cancel(t *testing.T) {
+	p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+
+	asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", nil)
+
+	job, err := p.AddAsset(ctx, asset.ID, []Quality{Original})
+	require.NoError(t, err)
+
+	require.NoError(t, p.Cancel(job.ID))
+	require.Empty(t, p.GetQueueStatus().Jobs)
+
+	require.ErrorIs(t, p.Cancel(job.ID), ErrPretranscodeJobNotFound)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestPretranscoder_Schedule(t *testing.T) {
+	t.Run("paused", func(t *testing.T) {
+		p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+
+		asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", nil)
+		_, err := p.AddAsset(ctx, asset.ID, []Quality{Original})
+		require.NoError(t, err)
+
+		p.Pause()
+		require.True(t, p.GetQueueStatus().Paused)
+
+		p.schedule(ctx)
+		require.Equal(t, PretranscodeWaiting, p.GetQueueStatus().Jobs[0].Status)
+
+		p.Resume()
+		require.False(t, p.GetQueueStatus().Paused)
+	})
+
+	t.Run("outside window", func(t *testing.T) {
+		p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+		p.now = func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local) }
+
+		asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", nil)
+		_, err := p.AddAsset(ctx, asset.ID, []Quality{Original})
+		require.NoError(t, err)
+
+		require.NoError(t, p.SetWindow("01:00-06:00"))
+
+		status := p.GetQueueStatus()
+		require.Equal(t, "01:00-06:00", status.Window.String())
+		require.False(t, status.InWindow)
+
+		p.schedule(ctx)
+		require.Equal(t, PretranscodeWaiting, p.GetQueueStatus().Jobs[0].Status)
+
+		require.ErrorIs(t, p.SetWindow("late"), ErrInvalidTimeWindow)
+	})
+
+	t.Run("complete set", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		p, ctx := setupPretranscoder(t, fs)
+
+		asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", []float64{0, 4, 8})
+		_, err := p.AddAsset(ctx, asset.ID, []Quality{Original, P1440})
+		require.NoError(t, err)
+
+		// Original already has complete sets and 1440p is higher than the video
+		writeCompleteSet(t, fs, filepath.Join(p.transcoder.cachePath, completeDirName, asset.ID, string(Original)), 3, FormatTS, videoFingerprint(t, p, ctx, asset, Original, FormatTS))
+		writeCompleteSet(t, fs, filepath.Join(p.transcoder.cachePath, completeDirName, asset.ID, string(Original)+"-fmp4"), 3, FormatFMP4, videoFingerprint(t, p, ctx, asset, Original, FormatFMP4))
+
+		p.schedule(ctx)
+
+		require.Eventually(t, func() bool {
+			return p.GetQueueStatus().Jobs[0].Status == PretranscodeCompleted
+		}, 5*time.Second, 10*time.Millisecond)
+
+		job := p.GetQueueStatus().Jobs[0]
+		require.Equal(t, 6, job.Done)
+		require.Equal(t, 6, job.Total)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+
+		// Without keyframes there is nothing to cut the video at
+		asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", nil)
+		_, err := p.AddAsset(ctx, asset.ID, []Quality{Original})
+		require.NoError(t, err)
+
+		p.schedule(ctx)
+
+		require.Eventually(t, func() bool {
+			return p.GetQueueStatus().Jobs[0].Status == PretranscodeFailed
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.Equal(t, "no keyframes", p.GetQueueStatus().Jobs[0].Error)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestPretranscoder_CompleteSet(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	p, ctx := setupPretranscoder(t, fs)
+
+	asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", []float64{0, 4, 8})
+	completeDir := filepath.Join(p.transcoder.cachePath, completeDirName, asset.ID)
+
+	t.Run("served without transcoding", func(t *testing.T) {
+		writeCompleteSet(t, fs, filepath.Join(completeDir, string(Original)), 3, FormatTS, videoFingerprint(t, p, ctx, asset, Original, FormatTS))
+
+		segment, err := p.transcoder.GetVideoSegment(ctx, asset.Path, 0, Original, FormatTS, 2, asset.ID)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(completeDir, string(Original), "segment-2.ts"), segment)
+	})
+
+	t.Run("fmp4 served without transcoding", func(t *testing.T) {
+		writeCompleteSet(t, fs, filepath.Join(completeDir, string(Original)+"-fmp4"), 3, FormatFMP4, videoFingerprint(t, p, ctx, asset, Original, FormatFMP4))
+
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+
+		stream, err := sw.getVideoStream(0, Original, FormatFMP4)
+		require.NoError(t, err)
+
+		segment, err := stream.GetSegment("user", 2)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(completeDir, string(Original)+"-fmp4", "segment-2.m4s"), segment)
+
+		init, err := stream.GetInit("user")
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(completeDir, string(Original)+"-fmp4", fmp4InitFile), init)
+	})
+
+	t.Run("stale set is ignored", func(t *testing.T) {
+		writeCompleteSet(t, fs, filepath.Join(completeDir, string(P720)), 2, FormatTS, videoFingerprint(t, p, ctx, asset, P720, FormatTS))
+
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+
+		stream, err := sw.getVideoStream(0, P720, FormatTS)
+		require.NoError(t, err)
+		require.Empty(t, stream.complete)
+	})
+
+	t.Run("set with other settings is ignored", func(t *testing.T) {
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+
+		// Written before the bitrate of the quality was changed
+		fingerprint := videoFingerprint(t, p, ctx, asset, P480, FormatTS)
+		sw.profile = sw.profile.Clone()
+		sw.profile.Rungs[slices.IndexFunc(sw.profile.Rungs, func(r Rung) bool { return r.Quality == P480 })].AverageBitrate++
+
+		writeCompleteSet(t, fs, filepath.Join(completeDir, string(P480)), 3, FormatTS, fingerprint)
+
+		stream, err := sw.getVideoStream(0, P480, FormatTS)
+		require.NoError(t, err)
+		require.Empty(t, stream.complete)
+
+		// The same settings are served from the set
+		writeCompleteSet(t, fs, filepath.Join(completeDir, string(P480)), 3, FormatTS, completeFingerprint(stream))
+		stream.loadCompleteSet()
+		require.NotEmpty(t, stream.complete)
+	})
+
+	t.Run("kept on startup", func(t *testing.T) {
+		require.NoError(t, afero.WriteFile(fs, filepath.Join(p.transcoder.cachePath, "other", "segment-0.ts"), []byte("ts"), 0o644))
+
+		_, err := NewTranscoder(p.transcoder.config)
+		require.NoError(t, err)
+
+		exists, err := afero.Exists(fs, filepath.Join(completeDir, string(Original), completeMarkerFile))
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		exists, err = afero.Exists(fs, filepath.Join(p.transcoder.cachePath, "other"))
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("pruned", func(t *testing.T) {
+		deletedDir := filepath.Join(p.transcoder.cachePath, completeDirName, "deleted")
+		writeCompleteSet(t, fs, filepath.Join(deletedDir, string(Original)), 3, FormatTS, "")
+		require.NoError(t, fs.MkdirAll(filepath.Join(completeDir, "720p.tmp"), 0o755))
+
+		require.NoError(t, p.pruneComplete(ctx))
+
+		exists, err := afero.Exists(fs, deletedDir)
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		exists, err = afero.Exists(fs, filepath.Join(completeDir, "720p.tmp"))
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		exists, err = afero.Exists(fs, filepath.Join(completeDir, string(Original)))
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_ParseTimeWindow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected TimeWindow
+		err      bool
+	}{
+		{"", TimeWindow{}, false},
+		{"01:00-06:30", TimeWindow{Start: 60, End: 390}, false},
+		{" 23:00 - 02:00 ", TimeWindow{Start: 1380, End: 120}, false},
+		{"01:00", TimeWindow{}, true},
+		{"25:00-02:00", TimeWindow{}, true},
+		{"night", TimeWindow{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			window, err := ParseTimeWindow(tt.input)
+			if tt.err {
+				require.ErrorIs(t, err, ErrInvalidTimeWindow)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, window)
+		})
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestTimeWindow_Contains(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	always := TimeWindow{}
+	require.True(t, always.Contains(at(12, 0)))
+	require.Equal(t, "", always.String())
+
+	day := TimeWindow{Start: 60, End: 360}
+	require.True(t, day.Contains(at(1, 0)))
+	require.True(t, day.Contains(at(5, 59)))
+	require.False(t, day.Contains(at(6, 0)))
+	require.False(t, day.Contains(at(0, 59)))
+	require.Equal(t, "01:00-06:00", day.String())
+
+	overnight := TimeWindow{Start: 1380, End: 120}
+	require.True(t, overnight.Contains(at(23, 30)))
+	require.True(t, overnight.Contains(at(1, 0)))
+	require.False(t, overnight.Contains(at(2, 0)))
+	require.False(t, overnight.Contains(at(12, 0)))
+	require.Equal(t, "23:00-02:00", overnight.String())
+}
@@ -0,0 +1,309 @@
+package hls
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ProfileParamKey is the key of the param holding the transcoding profile
+const ProfileParamKey = "hls_profile"
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+var (
+	ErrInvalidProfile = errors.New("invalid transcoding profile")
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// x264Presets are the presets accepted by libx264 and, for the most part, by the hardware
+// encoders
+var x264Presets = []string{
+	"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow",
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Rung is a single quality of the transcoding ladder
+type Rung struct {
+	Quality        Quality `json:"quality"`
+	Enabled        bool    `json:"enabled"`
+	AverageBitrate uint32  `json:"averageBitrate"`
+	MaxBitrate     uint32  `json:"maxBitrate"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Profile holds the settings used when transcoding
+type Profile struct {
+	// Rungs holds every quality of the ladder, lowest to highest
+	Rungs []Rung `json:"rungs"`
+
+	// Preset is the encoder preset. When empty, the preset of the hardware acceleration
+	// (OC_PRESET) is used
+	Preset string `json:"preset"`
+
+	// CRF switches software encoding to constant quality, capped at the max bitrate of the
+	// rung. 0 uses the average bitrate instead. Hardware encoders always use the bitrate
+	CRF int `json:"crf"`
+
+	// MaxQuality is the highest quality offered. Rungs above it are never offered and
+	// `transcode` scales larger videos down to it
+	MaxQuality Quality `json:"maxQuality"`
+
+	AudioBitrate  uint32 `json:"audioBitrate"`
+	AudioChannels int    `json:"audioChannels"`
+
+	// SurroundPassthrough offers AC-3 and E-AC-3 tracks with 6 or more channels as-is, alongside
+	// the AAC downmix
+	SurroundPassthrough bool `json:"surroundPassthrough"`
+
+	// SegmentDuration is the shortest length, in seconds, of a segment. Video segments are
+	// still cut on keyframes, so they may be longer. 0 cuts video on every keyframe and audio
+	// every 6s
+	SegmentDuration float64 `json:"segmentDuration"`
+
+	// MaxHeads and MaxUserHeads limit the encoders transcoding video at once, in total and per
+	// user. 0 is unlimited
+	MaxHeads     int `json:"maxHeads"`
+	MaxUserHeads int `json:"maxUserHeads"`
+
+	// NormalizeLoudness applies a gain to transcoded audio so every asset plays at the loudness
+	// target. Courses can also enable it on their own
+	NormalizeLoudness bool `json:"normalizeLoudness"`
+
+	// LoudnessTarget is the integrated loudness, in LUFS, audio is normalized to. 0 uses -16
+	LoudnessTarget float64 `json:"loudnessTarget"`
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DefaultProfile returns the built-in profile, with every quality up to 1440p enabled
+func DefaultProfile() *Profile {
+	profile := &Profile{
+		MaxQuality:    P2160,
+		AudioBitrate:  128_000,
+		AudioChannels: 2,
+	}
+
+	for _, quality := range Qualities {
+		profile.Rungs = append(profile.Rungs, Rung{
+			Quality:        quality,
+			Enabled:        quality != P2160,
+			AverageBitrate: quality.AverageBitrate(),
+			MaxBitrate:     quality.MaxBitrate(),
+		})
+	}
+
+	return profile
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Clone returns a deep copy of the profile
+func (p *Profile) Clone() *Profile {
+	clone := *p
+	clone.Rungs = slices.Clone(p.Rungs)
+	return &clone
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Validate checks the profile and sorts the rungs from lowest to highest. Rungs missing from the
+// profile are added, disabled, with their default bitrates
+func (p *Profile) Validate() error {
+	if _, err := QualityFromString(string(p.MaxQuality)); err != nil || p.MaxQuality == Original || p.MaxQuality == NoResize {
+		return fmt.Errorf("%w: max quality must be one of the ladder qualities", ErrInvalidProfile)
+	}
+
+	if p.Preset != "" && !slices.Contains(x264Presets, p.Preset) {
+		return fmt.Errorf("%w: unknown preset %q", ErrInvalidProfile, p.Preset)
+	}
+
+	if p.CRF < 0 || p.CRF > 51 {
+		return fmt.Errorf("%w: crf must be between 0 and 51", ErrInvalidProfile)
+	}
+
+	if p.AudioBitrate < 32_000 || p.AudioBitrate > 640_000 {
+		return fmt.Errorf("%w: audio bitrate must be between 32000 and 640000", ErrInvalidProfile)
+	}
+
+	if p.AudioChannels < 1 || p.AudioChannels > 8 {
+		return fmt.Errorf("%w: audio channels must be between 1 and 8", ErrInvalidProfile)
+	}
+
+	if p.SegmentDuration < 0 || p.SegmentDuration > 30 {
+		return fmt.Errorf("%w: segment duration must be between 0 and 30", ErrInvalidProfile)
+	}
+
+	if p.MaxHeads < 0 || p.MaxUserHeads < 0 {
+		return fmt.Errorf("%w: head limits must be 0 or more", ErrInvalidProfile)
+	}
+
+	if p.LoudnessTarget != 0 && (p.LoudnessTarget < -40 || p.LoudnessTarget > -5) {
+		return fmt.Errorf("%w: loudness target must be between -40 and -5", ErrInvalidProfile)
+	}
+
+	for i, rung := range p.Rungs {
+		if _, err := QualityFromString(string(rung.Quality)); err != nil || rung.Quality == Original || rung.Quality == NoResize {
+			return fmt.Errorf("%w: unknown quality %q", ErrInvalidProfile, rung.Quality)
+		}
+
+		if slices.ContainsFunc(p.Rungs[:i], func(r Rung) bool { return r.Quality == rung.Quality }) {
+			return fmt.Errorf("%w: %s is listed more than once", ErrInvalidProfile, rung.Quality)
+		}
+
+		if rung.AverageBitrate == 0 || rung.MaxBitrate < rung.AverageBitrate {
+			return fmt.Errorf("%w: %s max bitrate must be at least its average bitrate", ErrInvalidProfile, rung.Quality)
+		}
+	}
+
+	rungs := make([]Rung, 0, len(Qualities))
+	for _, quality := range Qualities {
+		rung, ok := p.rung(quality)
+		if !ok {
+			rung = Rung{Quality: quality, AverageBitrate: quality.AverageBitrate(), MaxBitrate: quality.MaxBitrate()}
+		}
+
+		rungs = append(rungs, rung)
+	}
+
+	p.Rungs = rungs
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Qualities returns the enabled qualities up to the max quality, lowest to highest
+func (p *Profile) Qualities() []Quality {
+	qualities := []Quality{}
+
+	for _, rung := range p.Rungs {
+		if rung.Enabled && rung.Quality.Height() <= p.MaxQuality.Height() {
+			qualities = append(qualities, rung.Quality)
+		}
+	}
+
+	return qualities
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AverageBitrate returns the average bitrate of a quality, falling back to its default
+func (p *Profile) AverageBitrate(quality Quality) uint32 {
+	if rung, ok := p.rung(quality); ok {
+		return rung.AverageBitrate
+	}
+
+	return quality.AverageBitrate()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// MaxBitrate returns the max bitrate of a quality, falling back to its default
+func (p *Profile) MaxBitrate(quality Quality) uint32 {
+	if rung, ok := p.rung(quality); ok {
+		return rung.MaxBitrate
+	}
+
+	return quality.MaxBitrate()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// rung returns the rung of a quality
+func (p *Profile) rung(quality Quality) (Rung, bool) {
+	idx := slices.IndexFunc(p.Rungs, func(r Rung) bool { return r.Quality == quality })
+	if idx == -1 {
+		return Rung{}, false
+	}
+
+	return p.Rungs[idx], true
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// segmentKeyframes drops keyframes so that each segment is at least the segment duration
+func (p *Profile) segmentKeyframes(keyframes []float64) []float64 {
+	if p.SegmentDuration <= 0 || len(keyframes) == 0 {
+		return keyframes
+	}
+
+	segments := []float64{keyframes[0]}
+	for _, keyframe := range keyframes[1:] {
+		if keyframe-segments[len(segments)-1] >= p.SegmentDuration {
+			segments = append(segments, keyframe)
+		}
+	}
+
+	return segments
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// audioSegmentDuration returns the length of a segment for audio-only assets
+func (p *Profile) audioSegmentDuration() float64 {
+	if p.SegmentDuration > 0 {
+		return p.SegmentDuration
+	}
+
+	return audioSegmentLength
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// loadProfile reads the profile from the params table, falling back to the default profile when
+// it has not been saved
+func loadProfile(ctx context.Context, d *dao.DAO) (*Profile, error) {
+	param, err := d.GetParam(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.PARAM_TABLE_KEY: ProfileParamKey}))
+	if err != nil {
+		return nil, err
+	}
+
+	if param == nil {
+		return DefaultProfile(), nil
+	}
+
+	profile := DefaultProfile()
+	if err := json.Unmarshal([]byte(param.Value), profile); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProfile, err)
+	}
+
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// saveProfile writes the profile to the params table
+func saveProfile(ctx context.Context, d *dao.DAO, profile *Profile) error {
+	value, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+
+	param, err := d.GetParam(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.PARAM_TABLE_KEY: ProfileParamKey}))
+	if err != nil {
+		return err
+	}
+
+	if param == nil {
+		return d.CreateParam(ctx, &models.Param{Key: ProfileParamKey, Value: string(value)})
+	}
+
+	param.Value = string(value)
+	return d.UpdateParam(ctx, param)
+}
@@ -0,0 +1,203 @@
+package hls
+
+import (
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/models"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestProfile_Validate(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		profile := DefaultProfile()
+		require.NoError(t, profile.Validate())
+		require.Equal(t, []Quality{P240, P360, P480, P720, P1080, P1440}, profile.Qualities())
+	})
+
+	t.Run("missing rungs", func(t *testing.T) {
+		profile := DefaultProfile()
+		profile.Rungs = []Rung{
+			{Quality: P2160, Enabled: true, AverageBitrate: 20_000_000, MaxBitrate: 30_000_000},
+			{Quality: P720, Enabled: true, AverageBitrate: 3_000_000, MaxBitrate: 5_000_000},
+		}
+
+		require.NoError(t, profile.Validate())
+		require.Len(t, profile.Rungs, len(Qualities))
+		require.Equal(t, []Quality{P720, P2160}, profile.Qualities())
+		require.Equal(t, uint32(20_000_000), profile.AverageBitrate(P2160))
+		require.Equal(t, P1080.MaxBitrate(), profile.MaxBitrate(P1080))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := []func(p *Profile){
+			func(p *Profile) { p.MaxQuality = Original },
+			func(p *Profile) { p.Preset = "fastest" },
+			func(p *Profile) { p.CRF = 52 },
+			func(p *Profile) { p.AudioBitrate = 1000 },
+			func(p *Profile) { p.AudioChannels = 0 },
+			func(p *Profile) { p.SegmentDuration = -1 },
+			func(p *Profile) { p.MaxHeads = -1 },
+			func(p *Profile) { p.MaxUserHeads = -1 },
+			func(p *Profile) { p.LoudnessTarget = -50 },
+			func(p *Profile) { p.LoudnessTarget = -2 },
+			func(p *Profile) { p.Rungs = append(p.Rungs, Rung{Quality: "4k", AverageBitrate: 1, MaxBitrate: 1}) },
+			func(p *Profile) { p.Rungs = append(p.Rungs, p.Rungs[0]) },
+			func(p *Profile) { p.Rungs[0].MaxBitrate = p.Rungs[0].AverageBitrate - 1 },
+		}
+
+		for i, tt := range tests {
+			profile := DefaultProfile()
+			tt(profile)
+			require.ErrorIs(t, profile.Validate(), ErrInvalidProfile, i)
+		}
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestProfile_Qualities(t *testing.T) {
+	profile := DefaultProfile()
+	profile.Rungs[len(profile.Rungs)-1].Enabled = true
+	require.Equal(t, P2160, profile.Qualities()[len(profile.Qualities())-1])
+
+	profile.MaxQuality = P720
+	require.Equal(t, []Quality{P240, P360, P480, P720}, profile.Qualities())
+
+	profile.Rungs[0].Enabled = false
+	require.Equal(t, P360, GetQualityForVideo(profile, 200, 100_000))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestProfile_SegmentKeyframes(t *testing.T) {
+	keyframes := []float64{0, 2, 4, 6, 8, 10, 15, 16}
+
+	profile := DefaultProfile()
+	require.Equal(t, keyframes, profile.segmentKeyframes(keyframes))
+	require.Equal(t, audioSegmentLength, profile.audioSegmentDuration())
+
+	profile.SegmentDuration = 5
+	require.Equal(t, []float64{0, 6, 15}, profile.segmentKeyframes(keyframes))
+	require.Equal(t, 5.0, profile.audioSegmentDuration())
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestProfile_TranscodeArgs(t *testing.T) {
+	profile := DefaultProfile()
+	profile.Preset = "slow"
+	profile.CRF = 23
+	profile.MaxQuality = P1080
+	profile.AudioBitrate = 192_000
+	profile.AudioChannels = 6
+	profile.Rungs[3].AverageBitrate = 3_000_000 // 720p
+	profile.Rungs[3].MaxBitrate = 5_000_000
+
+	sw := &StreamWrapper{
+		profile: profile,
+		Info:    &MediaInfo{Audios: []Audio{{Index: 0}, {Index: 1}}},
+		config: &TranscoderConfig{
+			HwAccel: HwAccelT{
+				Name:        "disabled",
+				EncodeFlags: []string{"-c:v", "libx264", "-preset", "fast"},
+				ScaleFilter: "scale=%d:%d",
+			},
+		},
+	}
+	video := &Video{Width: 3840, Height: 2160}
+
+	t.Run("video", func(t *testing.T) {
+		vs := &VideoStream{Stream: Stream{streamWrapper: sw}, video: video, quality: P720}
+		args := vs.getTranscodeArgs("0")
+
+		require.Subset(t, args, []string{"-preset", "slow", "-crf", "23", "-maxrate", "5000000"})
+		require.NotContains(t, args, "-b:v")
+		require.NotContains(t, args, "fast")
+
+		// Hardware encoders use the bitrate
+		sw.config.HwAccel.Name = "nvidia"
+		args = vs.getTranscodeArgs("0")
+		require.Subset(t, args, []string{"-b:v", "3000000"})
+		require.NotContains(t, args, "-crf")
+		sw.config.HwAccel.Name = "disabled"
+	})
+
+	t.Run("no resize over max", func(t *testing.T) {
+		vs := &VideoStream{Stream: Stream{streamWrapper: sw}, video: video, quality: NoResize}
+		require.Contains(t, vs.getTranscodeArgs("0"), "scale=1920:1080")
+	})
+
+	t.Run("audio", func(t *testing.T) {
+		as := &AudioStream{Stream: Stream{streamWrapper: sw}}
+		require.Subset(t, as.getTranscodeArgs(""), []string{"-ac", "6", "-b:a", "192000"})
+		require.NotContains(t, as.getTranscodeArgs(""), "-af")
+		require.Equal(t, "audio-0", as.getName())
+
+		// Boosted audio is limited
+		sw.Info.Audios[0].LoudnessGain = 4.5
+		require.Subset(t, as.getTranscodeArgs(""), []string{"-af", "volume=4.5dB,alimiter=limit=0.95"})
+		require.Equal(t, "audio-0-norm", as.getName())
+
+		sw.Info.Audios[0].LoudnessGain = -3
+		require.Subset(t, as.getTranscodeArgs(""), []string{"-af", "volume=-3.0dB"})
+
+		// Each track has its own gain
+		other := &AudioStream{Stream: Stream{streamWrapper: sw}, index: 1}
+		require.NotContains(t, other.getTranscodeArgs(""), "-af")
+		require.Equal(t, "audio-1", other.getName())
+
+		// Surround audio is copied
+		as.mode = AudioSurround
+		require.NotContains(t, as.getTranscodeArgs(""), "-af")
+		sw.Info.Audios[0].LoudnessGain = 0
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestTranscoder_SetProfile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	p, ctx := setupPretranscoder(t, fs)
+	transcoder := p.transcoder
+
+	asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", []float64{0, 2, 4, 6, 8})
+
+	sw, err := transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+	require.NoError(t, err)
+	require.Contains(t, sw.GetQualities(), P1080)
+
+	profile := transcoder.Profile()
+	profile.MaxQuality = P720
+	profile.SegmentDuration = 4
+	require.NoError(t, transcoder.SetProfile(ctx, profile))
+
+	// Saved to the params table
+	param, err := transcoder.config.Dao.GetParam(ctx, dao.NewOptions().WithWhere(squirrel.Eq{models.PARAM_TABLE_KEY: ProfileParamKey}))
+	require.NoError(t, err)
+	require.NotNil(t, param)
+
+	// Open streams are replaced
+	sw, err = transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+	require.NoError(t, err)
+	require.Equal(t, []Quality{Original, P720, P480, P360, P240}, sw.GetQualities())
+
+	stream, err := sw.getVideoStream(0, P720, FormatTS)
+	require.NoError(t, err)
+	require.Equal(t, []float64{0, 4, 8}, stream.keyframes)
+
+	// Loaded on startup
+	restarted, err := NewTranscoder(transcoder.config)
+	require.NoError(t, err)
+	require.Equal(t, P720, restarted.Profile().MaxQuality)
+	require.Equal(t, 4.0, restarted.Profile().SegmentDuration)
+
+	// Invalid profiles are not saved
+	profile.CRF = 100
+	require.ErrorIs(t, transcoder.SetProfile(ctx, profile), ErrInvalidProfile)
+	require.Equal(t, 0, transcoder.Profile().CRF)
+}
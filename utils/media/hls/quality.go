@@ -16,14 +16,20 @@ const (
 	P720     Quality = "720p"
 	P1080    Quality = "1080p"
 	P1440    Quality = "1440p"
+	P2160    Quality = "2160p"
 	NoResize Quality = "transcode"
 	Original Quality = "original"
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// ErrQualityUnavailable is returned when a quality is not offered for a video
+var ErrQualityUnavailable = errors.New("quality is not available")
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // Purposefully removing Original from this list (since it requires special treatment anyway)
-var Qualities = []Quality{P240, P360, P480, P720, P1080, P1440}
+var Qualities = []Quality{P240, P360, P480, P720, P1080, P1440, P2160}
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
@@ -46,7 +52,7 @@ func QualityFromString(str string) (Quality, error) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// AverageBitrate returns the average bitrate for a quality level
+// AverageBitrate returns the default average bitrate for a quality level
 func (v Quality) AverageBitrate() uint32 {
 	switch v {
 	case P240:
@@ -61,6 +67,8 @@ func (v Quality) AverageBitrate() uint32 {
 		return 4_800_000
 	case P1440:
 		return 9_600_000
+	case P2160:
+		return 16_000_000
 	case Original:
 		panic("Original quality must be handled specially")
 	}
@@ -69,7 +77,7 @@ func (v Quality) AverageBitrate() uint32 {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// MaxBitrate returns the maximum bitrate for a quality level
+// MaxBitrate returns the default maximum bitrate for a quality level
 func (v Quality) MaxBitrate() uint32 {
 	switch v {
 	case P240:
@@ -84,6 +92,8 @@ func (v Quality) MaxBitrate() uint32 {
 		return 8_000_000
 	case P1440:
 		return 12_000_000
+	case P2160:
+		return 24_000_000
 	case Original:
 		panic("Original quality must be handled specially")
 	}
@@ -107,6 +117,8 @@ func (q Quality) Height() uint32 {
 		return 1080
 	case P1440:
 		return 1440
+	case P2160:
+		return 2160
 	case Original:
 		panic("Original quality must be handled specially")
 	}
@@ -115,14 +127,21 @@ func (q Quality) Height() uint32 {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetQualityForVideo determines the appropriate quality for a video based on its height and bitrate
-func GetQualityForVideo(height uint32, bitrate uint32) Quality {
-	for _, quality := range Qualities {
-		if quality.Height() >= height || quality.AverageBitrate() >= bitrate {
+// GetQualityForVideo determines the appropriate quality for a video based on its height and
+// bitrate, from the qualities enabled in the profile
+func GetQualityForVideo(profile *Profile, height uint32, bitrate uint32) Quality {
+	qualities := profile.Qualities()
+
+	for _, quality := range qualities {
+		if quality.Height() >= height || profile.AverageBitrate(quality) >= bitrate {
 			return quality
 		}
 	}
 
+	if len(qualities) > 0 {
+		return qualities[0]
+	}
+
 	return P240
 }
 
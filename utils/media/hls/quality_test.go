@@ -21,6 +21,7 @@ func TestQuality_FromString(t *testing.T) {
 			{"720p", P720},
 			{"1080p", P1080},
 			{"1440p", P1440},
+			{"2160p", P2160},
 			{"transcode", NoResize},
 			{"original", Original},
 		}
@@ -61,6 +62,7 @@ func TestQuality_Height(t *testing.T) {
 		{P720, 720},
 		{P1080, 1080},
 		{P1440, 1440},
+		{P2160, 2160},
 	}
 
 	for _, tc := range testCases {
@@ -96,6 +98,7 @@ func TestQuality_AverageBitrate(t *testing.T) {
 		{P720, 2_400_000},
 		{P1080, 4_800_000},
 		{P1440, 9_600_000},
+		{P2160, 16_000_000},
 	}
 
 	for _, tc := range testCases {
@@ -131,6 +134,7 @@ func TestQuality_MaxBitrate(t *testing.T) {
 		{P720, 4_000_000},
 		{P1080, 8_000_000},
 		{P1440, 12_000_000},
+		{P2160, 24_000_000},
 	}
 
 	for _, tc := range testCases {
@@ -177,7 +181,7 @@ func TestGetQuality_ForVideo(t *testing.T) {
 
 		for _, tc := range testCases {
 			t.Run(fmt.Sprintf("height_%d", tc.height), func(t *testing.T) {
-				quality := GetQualityForVideo(tc.height, tc.bitrate)
+				quality := GetQualityForVideo(DefaultProfile(), tc.height, tc.bitrate)
 				require.Equal(t, tc.expected, quality)
 			})
 		}
@@ -198,7 +202,7 @@ func TestGetQuality_ForVideo(t *testing.T) {
 
 		for _, tc := range testCases {
 			t.Run(fmt.Sprintf("bitrate_%d", tc.bitrate), func(t *testing.T) {
-				quality := GetQualityForVideo(tc.height, tc.bitrate)
+				quality := GetQualityForVideo(DefaultProfile(), tc.height, tc.bitrate)
 				require.Equal(t, tc.expected, quality)
 			})
 		}
@@ -219,7 +223,7 @@ func TestQuality_SelectionLogic(t *testing.T) {
 			{720, []Quality{P240, P360, P480, P720, Original}},
 			{1080, []Quality{P240, P360, P480, P720, P1080, Original}},
 			{1440, []Quality{P240, P360, P480, P720, P1080, P1440, Original}},
-			{2160, []Quality{P240, P360, P480, P720, P1080, P1440, Original}}, // 4K video
+			{2160, []Quality{P240, P360, P480, P720, P1080, P1440, P2160, Original}}, // 4K video
 		}
 
 		for _, tc := range testCases {
@@ -242,7 +246,7 @@ func TestQuality_SelectionLogic(t *testing.T) {
 
 func TestQuality_Constants(t *testing.T) {
 	t.Run("qualities slice contains all standard qualities", func(t *testing.T) {
-		expected := []Quality{P240, P360, P480, P720, P1080, P1440}
+		expected := []Quality{P240, P360, P480, P720, P1080, P1440, P2160}
 		require.Equal(t, expected, Qualities)
 	})
 
@@ -253,6 +257,7 @@ func TestQuality_Constants(t *testing.T) {
 		require.Equal(t, "720p", string(P720))
 		require.Equal(t, "1080p", string(P1080))
 		require.Equal(t, "1440p", string(P1440))
+		require.Equal(t, "2160p", string(P2160))
 		require.Equal(t, "transcode", string(NoResize))
 		require.Equal(t, "original", string(Original))
 	})
@@ -0,0 +1,328 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+var (
+	ErrTranscodeLimit = errors.New("transcode limit reached")
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// clockTicks is the number of clock ticks per second used by /proc/<pid>/stat
+const clockTicks = 100
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// headLimiter counts the running encoders that transcode video, in total and per user, so they
+// stay within the head limits of the profile
+type headLimiter struct {
+	mu    sync.Mutex
+	total int
+	users map[string]int
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// newHeadLimiter creates a new headLimiter
+func newHeadLimiter() *headLimiter {
+	return &headLimiter{users: make(map[string]int)}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// acquire reserves a head for the user. It returns ErrTranscodeLimit when the head would exceed
+// the limits of the profile
+func (l *headLimiter) acquire(userID string, profile *Profile) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if profile.MaxHeads > 0 && l.total >= profile.MaxHeads {
+		return fmt.Errorf("%w: %d of %d encoders are running", ErrTranscodeLimit, l.total, profile.MaxHeads)
+	}
+
+	if profile.MaxUserHeads > 0 && userID != "" && l.users[userID] >= profile.MaxUserHeads {
+		return fmt.Errorf("%w: %d of %d encoders are running for the user", ErrTranscodeLimit, l.users[userID], profile.MaxUserHeads)
+	}
+
+	l.total++
+	if userID != "" {
+		l.users[userID]++
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// release frees a head reserved for the user
+func (l *headLimiter) release(userID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total = max(l.total-1, 0)
+
+	if userID == "" {
+		return
+	}
+
+	if l.users[userID] <= 1 {
+		delete(l.users, userID)
+		return
+	}
+
+	l.users[userID]--
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Session is an asset with running encoders
+type Session struct {
+	AssetID string
+	Path    string
+
+	// Users holds the IDs of the users the encoders were started for
+	Users []string
+
+	// Qualities holds the video qualities being produced
+	Qualities []Quality
+
+	Heads []SessionHead
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// SessionHead is a running encoder of a session
+type SessionHead struct {
+	// Stream is the name of the stream, such as 720p or audio-0
+	Stream string
+	UserID string
+	PID    int
+
+	// StartSegment and EndSegment are the range of segments of the encoder, with Segment the
+	// last segment written and Segments the number of segments it wrote
+	StartSegment int32
+	EndSegment   int32
+	Segment      int32
+	Segments     int32
+
+	Started time.Time
+
+	// CPUTime is the user and system time of ffmpeg, which is 0 when it cannot be read
+	CPUTime time.Duration
+
+	// Speed is the seconds of media written per second since the encoder started
+	Speed float64
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Sessions returns the assets with running encoders
+func (t *Transcoder) Sessions() []Session {
+	sessions := []Session{}
+
+	for _, sw := range t.streams.Values() {
+		if sw.err != nil || sw.Info == nil {
+			continue
+		}
+
+		session := sw.session()
+		if len(session.Heads) == 0 {
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	slices.SortFunc(sessions, func(a, b Session) int {
+		return strings.Compare(a.AssetID, b.AssetID)
+	})
+
+	return sessions
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// KillSession stops the encoders of an asset and destroys its streams, so the next request for
+// the asset starts over. Returns false when the asset has no streams
+func (t *Transcoder) KillSession(assetID string) bool {
+	sw, ok := t.streams.GetAndRemove(assetID)
+	if !ok {
+		return false
+	}
+
+	if sw.Info != nil {
+		sw.Destroy()
+	}
+
+	return true
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// session returns the running encoders of the video and audio streams
+func (sw *StreamWrapper) session() Session {
+	session := Session{
+		AssetID:   sw.assetID,
+		Path:      sw.Info.Path,
+		Users:     []string{},
+		Qualities: []Quality{},
+		Heads:     []SessionHead{},
+	}
+
+	add := func(heads []SessionHead) {
+		for _, head := range heads {
+			if head.UserID != "" && !slices.Contains(session.Users, head.UserID) {
+				session.Users = append(session.Users, head.UserID)
+			}
+		}
+
+		session.Heads = append(session.Heads, heads...)
+	}
+
+	// The height each quality is encoded at. NoResize has no height of its own, so the stream's
+	// output height is used
+	heights := map[Quality]uint32{}
+
+	sw.videos.ForEach(func(key VideoKey, s *VideoStream) {
+		heads := s.sessionHeads()
+		if len(heads) > 0 && !slices.Contains(session.Qualities, key.quality) {
+			session.Qualities = append(session.Qualities, key.quality)
+
+			if key.quality != Original {
+				heights[key.quality] = s.outputHeight()
+			}
+		}
+
+		add(heads)
+	})
+
+	sw.audios.ForEach(func(_ AudioKey, s *AudioStream) {
+		add(s.sessionHeads())
+	})
+
+	// Original first, then highest to lowest
+	slices.SortFunc(session.Qualities, func(a, b Quality) int {
+		switch {
+		case a == Original:
+			return -1
+		case b == Original:
+			return 1
+		}
+
+		return int(heights[b]) - int(heights[a])
+	})
+
+	slices.Sort(session.Users)
+	slices.SortFunc(session.Heads, func(a, b SessionHead) int {
+		if c := strings.Compare(a.Stream, b.Stream); c != 0 {
+			return c
+		}
+
+		return int(a.StartSegment - b.StartSegment)
+	})
+
+	return session
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// sessionHeads returns the running encoders of the stream
+func (s *Stream) sessionHeads() []SessionHead {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	heads := []SessionHead{}
+
+	for _, head := range s.heads {
+		if head.segment < 0 || head.command == nil || head.command.Process == nil {
+			continue
+		}
+
+		pid := head.command.Process.Pid
+		cpuTime, _ := processCPUTime(pid)
+
+		heads = append(heads, SessionHead{
+			Stream:       s.streamer.getName(),
+			UserID:       head.userID,
+			PID:          pid,
+			StartSegment: head.start,
+			EndSegment:   head.end,
+			Segment:      head.segment,
+			Segments:     head.produced,
+			Started:      head.started,
+			CPUTime:      cpuTime,
+			Speed:        s.headSpeed(head, time.Since(head.started)),
+		})
+	}
+
+	return heads
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// headSpeed returns the seconds of media an encoder wrote per second since it started
+func (s *Stream) headSpeed(head Head, elapsed time.Duration) float64 {
+	if head.produced <= 0 || elapsed <= 0 || int(head.start) >= len(s.keyframes) {
+		return 0
+	}
+
+	end := s.streamWrapper.Info.Duration
+	if next := int(head.start + head.produced); next < len(s.keyframes) {
+		end = s.keyframes[next]
+	}
+
+	return (end - s.keyframes[head.start]) / elapsed.Seconds()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// processCPUTime returns the user and system time of a process. It is only supported on Linux
+func processCPUTime(pid int) (time.Duration, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	return parseProcStat(string(data))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// parseProcStat returns the user and system time from the contents of /proc/<pid>/stat. The
+// command name may hold spaces, so fields are counted from its closing parenthesis
+func parseProcStat(stat string) (time.Duration, error) {
+	idx := strings.LastIndexByte(stat, ')')
+	if idx == -1 {
+		return 0, errors.New("invalid stat")
+	}
+
+	// The fields after the command start at the state (field 3), so utime (field 14) and
+	// stime (field 15) are at 11 and 12
+	fields := strings.Fields(stat[idx+1:])
+	if len(fields) < 13 {
+		return 0, errors.New("invalid stat")
+	}
+
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(utime+stime) * time.Second / clockTicks, nil
+}
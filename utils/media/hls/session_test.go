@@ -0,0 +1,221 @@
+package hls
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestHeadLimiter(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		l := newHeadLimiter()
+		profile := DefaultProfile()
+
+		for range 10 {
+			require.NoError(t, l.acquire("user", profile))
+		}
+
+		require.Equal(t, 10, l.total)
+		require.Equal(t, 10, l.users["user"])
+	})
+
+	t.Run("total", func(t *testing.T) {
+		l := newHeadLimiter()
+		profile := DefaultProfile()
+		profile.MaxHeads = 2
+
+		require.NoError(t, l.acquire("user", profile))
+		require.NoError(t, l.acquire("admin", profile))
+		require.ErrorIs(t, l.acquire("other", profile), ErrTranscodeLimit)
+
+		l.release("admin")
+		require.NoError(t, l.acquire("other", profile))
+	})
+
+	t.Run("user", func(t *testing.T) {
+		l := newHeadLimiter()
+		profile := DefaultProfile()
+		profile.MaxUserHeads = 1
+
+		require.NoError(t, l.acquire("user", profile))
+		require.ErrorIs(t, l.acquire("user", profile), ErrTranscodeLimit)
+		require.NoError(t, l.acquire("admin", profile))
+
+		// Encoders without a user only count towards the total
+		require.NoError(t, l.acquire("", profile))
+		require.NoError(t, l.acquire("", profile))
+
+		l.release("user")
+		require.NotContains(t, l.users, "user")
+		require.NoError(t, l.acquire("user", profile))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestParseProcStat(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		stat := "1234 (ffmpeg (copy)) S 1 1234 1234 0 -1 4194304 500 0 0 0 250 50 0 0 20 0 4 0 100 0 0"
+
+		cpuTime, err := parseProcStat(stat)
+		require.NoError(t, err)
+		require.Equal(t, 3*time.Second, cpuTime)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, stat := range []string{"", "1234 ffmpeg S 1", "1234 (ffmpeg) S 1 2 3", "1234 (ffmpeg) S 1 1234 1234 0 -1 4194304 500 0 0 0 x 50"} {
+			_, err := parseProcStat(stat)
+			require.Error(t, err, stat)
+		}
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestTranscoder_Sessions(t *testing.T) {
+	p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+	asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", []float64{0, 4, 8})
+
+	sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+	require.NoError(t, err)
+
+	stream, err := sw.getVideoStream(0, P720, FormatTS)
+	require.NoError(t, err)
+
+	// No encoders are running
+	require.Empty(t, p.transcoder.Sessions())
+
+	cmd := exec.Command("sleep", "30")
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+
+	stream.lock.Lock()
+	stream.heads = append(stream.heads, Head{
+		segment:  0,
+		end:      3,
+		command:  cmd,
+		userID:   "user",
+		started:  time.Now().Add(-2 * time.Second),
+		start:    0,
+		produced: 1,
+	})
+	stream.lock.Unlock()
+
+	sessions := p.transcoder.Sessions()
+	require.Len(t, sessions, 1)
+	require.Equal(t, asset.ID, sessions[0].AssetID)
+	require.Equal(t, []string{"user"}, sessions[0].Users)
+	require.Equal(t, []Quality{P720}, sessions[0].Qualities)
+
+	require.Len(t, sessions[0].Heads, 1)
+	head := sessions[0].Heads[0]
+	require.Equal(t, "720p", head.Stream)
+	require.Equal(t, cmd.Process.Pid, head.PID)
+	require.Equal(t, int32(3), head.EndSegment)
+	require.Equal(t, int32(1), head.Segments)
+	require.InDelta(t, 2.0, head.Speed, 0.1)
+
+	// Transcoding without resizing sorts by the height it is encoded at
+	noResize, err := sw.getVideoStream(0, NoResize, FormatTS)
+	require.NoError(t, err)
+
+	original, err := sw.getVideoStream(0, Original, FormatTS)
+	require.NoError(t, err)
+
+	for _, s := range []*VideoStream{noResize, original} {
+		s.lock.Lock()
+		s.heads = append(s.heads, Head{segment: 0, end: 3, command: cmd, userID: "user"})
+		s.lock.Unlock()
+	}
+
+	sessions = p.transcoder.Sessions()
+	require.Len(t, sessions, 1)
+	require.Equal(t, []Quality{Original, NoResize, P720}, sessions[0].Qualities)
+
+	// Killing the session stops ffmpeg and destroys the streams
+	require.True(t, p.transcoder.KillSession(asset.ID))
+	require.False(t, p.transcoder.KillSession(asset.ID))
+	require.Empty(t, p.transcoder.Sessions())
+
+	done := make(chan error)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the process was not stopped")
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStream_GetSegmentLimit(t *testing.T) {
+	newStream := func(t *testing.T) (*Transcoder, *VideoStream) {
+		t.Helper()
+
+		p, ctx := setupPretranscoder(t, afero.NewMemMapFs())
+		asset := createPretranscodeAsset(t, p, ctx, "/course/video.mp4", []float64{0, 70, 140})
+
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+
+		sw.Info.Duration = 200
+		sw.profile = DefaultProfile()
+		sw.profile.MaxHeads = 1
+
+		stream, err := sw.getVideoStream(0, P720, FormatTS)
+		require.NoError(t, err)
+
+		// Another user holds the only head
+		require.NoError(t, p.transcoder.limiter.acquire("other", sw.profile))
+
+		return p.transcoder, stream
+	}
+
+	t.Run("error", func(t *testing.T) {
+		_, stream := newStream(t)
+
+		_, err := stream.GetSegment("user", 1)
+		require.ErrorIs(t, err, ErrTranscodeLimit)
+		require.Empty(t, stream.heads)
+	})
+
+	t.Run("scheduled", func(t *testing.T) {
+		_, stream := newStream(t)
+
+		// An encoder far behind will reach the segment
+		stream.lock.Lock()
+		stream.heads = append(stream.heads, Head{segment: 0, end: 3, userID: "other"})
+		stream.lock.Unlock()
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			stream.lock.Lock()
+			close(stream.segments[1].channel)
+			stream.lock.Unlock()
+		}()
+
+		segmentPath, err := stream.GetSegment("user", 1)
+		require.NoError(t, err)
+		require.Contains(t, segmentPath, "segment-0-1.ts")
+		require.Len(t, stream.heads, 1)
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		transcoder, stream := newStream(t)
+
+		original, err := stream.streamWrapper.getVideoStream(0, Original, FormatTS)
+		require.NoError(t, err)
+
+		// Copying the video never counts towards the limit
+		require.False(t, original.isLimited())
+		require.True(t, stream.isLimited())
+		require.Equal(t, 1, transcoder.limiter.total)
+	})
+}
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/geerew/off-course/utils"
+	"github.com/spf13/afero"
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -26,6 +27,14 @@ type Head struct {
 	segment int32
 	end     int32
 	command *exec.Cmd
+
+	// The user the encoder was started for, when and from which segment
+	userID  string
+	started time.Time
+	start   int32
+
+	// produced is the number of segments written by the encoder
+	produced int32
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -47,6 +56,11 @@ type Segment struct {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// audioSegmentLength is the length, in seconds, of each segment for audio-only assets
+const audioSegmentLength = 6.0
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // Flags represents stream type flags
 type Flags int32
 
@@ -58,10 +72,20 @@ const (
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// VideoKey uniquely identifies a video stream by index and quality
+// VideoKey uniquely identifies a video stream by index, quality and format
 type VideoKey struct {
 	idx     uint32
 	quality Quality
+	format  Format
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AudioKey uniquely identifies an audio stream by index, mode and format
+type AudioKey struct {
+	idx    uint32
+	mode   AudioMode
+	format Format
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -69,7 +93,7 @@ type VideoKey struct {
 // Streamer represents a stream interface for transcoding operations
 type Streamer interface {
 	getTranscodeArgs(segments string) []string
-	getOutPath(encoderID int) string
+	getName() string
 	getFlags() Flags
 }
 
@@ -84,6 +108,13 @@ type Stream struct {
 	segments      []Segment
 	heads         []Head
 	lock          sync.RWMutex
+
+	// The container the segments are written in
+	format Format
+
+	// The directory holding a complete set of segments, written by the pre-transcoder. When
+	// set, segments are served from it and ffmpeg is never run
+	complete string
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -99,6 +130,67 @@ func (s *Stream) initializeSegments() {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// outDir returns the directory segments are written to when transcoding on demand
+func (s *Stream) outDir() string {
+	return filepath.Join(s.streamWrapper.Out, s.streamer.getName())
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// getOutPath returns the output path pattern ffmpeg writes the segments of an encoder to. fMP4
+// segments are written as whole mp4 files, which are split once written
+func (s *Stream) getOutPath(encoderID int) string {
+	ext := "ts"
+	if s.format == FormatFMP4 {
+		ext = "mp4"
+	}
+
+	return filepath.Join(s.outDir(), fmt.Sprintf("segment-%d-%%d.%s", encoderID, ext))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// getSegmentPath returns the path of a segment written by an encoder, as served to the player
+func (s *Stream) getSegmentPath(encoderID int, segment int32) string {
+	return filepath.Join(s.outDir(), fmt.Sprintf("segment-%d-%d.%s", encoderID, segment, s.format.segmentExt()))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// completeDir returns the directory of the complete segment set for the stream, or an empty
+// string when complete sets are not supported. Each format has its own set, as the stream name
+// includes the format
+func (s *Stream) completeDir() string {
+	if s.streamWrapper.Complete == "" {
+		return ""
+	}
+
+	return filepath.Join(s.streamWrapper.Complete, s.streamer.getName())
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// loadCompleteSet marks every segment as ready when a complete set of segments exists for the
+// stream
+func (s *Stream) loadCompleteSet() {
+	dir := s.completeDir()
+	if dir == "" || !isCompleteSet(s.streamWrapper.config.AppFs.Fs, dir, len(s.keyframes), completeFingerprint(s.streamer)) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.complete = dir
+	for seg := range s.segments {
+		if !s.isSegmentReady(int32(seg)) {
+			close(s.segments[seg].channel)
+		}
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // isSegmentReady checks if a segment is ready (non-blocking)
 //
 // Always lock before calling this
@@ -126,8 +218,59 @@ func (s *Stream) isSegmentTranscoding(segment int32) bool {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// run starts transcoding from the given segment
-func (s *Stream) run(startSegment int32) error {
+// hasActiveHeads returns true when an encoder is running or about to start
+//
+// Always lock before calling this
+func (s *Stream) hasActiveHeads() bool {
+	for _, head := range s.heads {
+		if head.segment >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// evict calls remove to delete the segments in dir, unless an encoder is active. When dir holds
+// the segments the stream is serving, the stream is reset so they are transcoded again on the
+// next request
+//
+// Returns false when the stream is active and nothing was removed
+func (s *Stream) evict(dir string, remove func() error) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.hasActiveHeads() {
+		return false, nil
+	}
+
+	if err := remove(); err != nil {
+		return false, err
+	}
+
+	if dir == s.complete || (s.complete == "" && dir == s.outDir()) {
+		s.complete = ""
+		s.initializeSegments()
+	}
+
+	return true, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// isLimited returns true when the encoders of the stream count towards the head limits of the
+// profile. Only video that is transcoded counts, as copying a stream is cheap
+func (s *Stream) isLimited() bool {
+	flags := s.streamer.getFlags()
+	return flags&VideoF != 0 && flags&Transmux == 0
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// run starts transcoding from the given segment for a user. It returns ErrTranscodeLimit when
+// the encoder would exceed the head limits of the profile
+func (s *Stream) run(userID string, startSegment int32) error {
 	// Start the transcode with adaptive buffer based on video length
 	length := len(s.keyframes)
 
@@ -162,10 +305,36 @@ func (s *Stream) run(startSegment int32) error {
 		return nil
 	}
 
+	limited := s.isLimited()
+	if limited {
+		if err := s.streamWrapper.limiter.acquire(userID, s.streamWrapper.profile); err != nil {
+			s.lock.Unlock()
+			return err
+		}
+	}
+
 	encoderID := len(s.heads)
-	s.heads = append(s.heads, Head{segment: startSegment, end: endSegment, command: nil})
+	s.heads = append(s.heads, Head{
+		segment: startSegment,
+		end:     endSegment,
+		command: nil,
+		userID:  userID,
+		started: time.Now(),
+		start:   startSegment,
+	})
 	s.lock.Unlock()
 
+	// abort releases the head when ffmpeg could not be started
+	abort := func() {
+		s.lock.Lock()
+		s.heads[encoderID] = DeletedHead
+		s.lock.Unlock()
+
+		if limited {
+			s.streamWrapper.limiter.release(userID)
+		}
+	}
+
 	s.streamWrapper.config.Logger.Debug().
 		Str("asset_id", s.streamWrapper.assetID).
 		Str("path", s.streamWrapper.Info.Path).
@@ -175,23 +344,8 @@ func (s *Stream) run(startSegment int32) error {
 		Int("total_segments", length).
 		Msg("Starting transcode")
 
-	// Calculate FFmpeg seek references for precise segment cutting
-	startRef, endRef := s.calculateSeekReferences(startSegment, endSegment, int32(length))
-
-	endPadding := int32(1)
-	if endSegment == int32(length) {
-		endPadding = 0
-	}
-
-	// Calculate the segments to transcode
-	segments := s.keyframes[startSegment+1 : endSegment+endPadding]
-	if len(segments) == 0 {
-		// ffmpeg errors out if the segments are empty
-		segments = []float64{9999999}
-	}
-
 	// Create output directory
-	outPath := s.streamer.getOutPath(encoderID)
+	outPath := s.getOutPath(encoderID)
 	err := s.streamWrapper.config.AppFs.Fs.MkdirAll(filepath.Dir(outPath), 0o755)
 	if err != nil {
 		s.streamWrapper.config.Logger.Error().
@@ -201,71 +355,22 @@ func (s *Stream) run(startSegment int32) error {
 			Int("encoder_id", encoderID).
 			Str("out_path", outPath).
 			Msg("Failed to create output directory for transcoding")
+		abort()
 		return err
 	}
 
-	// Build the FFmpeg arguments
-	args := []string{
-		"-nostats", "-hide_banner", "-loglevel", "warning",
-	}
-
-	// Add the hardware acceleration flags if the stream is a video
-	if s.streamer.getFlags()&VideoF != 0 {
-		args = append(args, s.streamWrapper.config.HwAccel.DecodeFlags...)
-	}
-
-	// Add -ss parameter when we are not at the beginning of the stream
-	if startRef != 0 {
-		// Required for video to force pre/post segment to work
-		if s.streamer.getFlags()&VideoF != 0 {
-			args = append(args, "-noaccurate_seek")
-		}
-
-		args = append(args, "-ss", fmt.Sprintf("%.6f", startRef))
-	}
-
-	// Add -to parameter when we don't want to go to the end of the stream
-	if endRef > 0 {
-		args = append(args, "-to", fmt.Sprintf("%.6f", endRef))
+	if err := s.streamWrapper.ensureInput(); err != nil {
+		s.streamWrapper.config.Logger.Error().
+			Err(err).
+			Str("asset_id", s.streamWrapper.assetID).
+			Str("path", s.streamWrapper.Info.Path).
+			Int("encoder_id", encoderID).
+			Msg("Failed to extract asset from archive")
+		abort()
+		return err
 	}
 
-	args = append(args,
-		// If timestamps (PTS) are missing or messy after the seek/streamcopy, generate new PTS so
-		// downstream muxers stay happy
-		"-fflags", "+genpts",
-		// Input file
-		"-i", s.streamWrapper.Info.Path,
-		// Ensure consistent behavior between software and hardware decoding
-		"-start_at_zero",
-		// Preserve input timestamps
-		"-copyts",
-		// Do not buffer at the muxer, instead write the packets as soon as possible
-		"-muxdelay", "0",
-	)
-
-	// Add the transcoding arguments for the type of stream (audio or video)
-	args = append(args, s.streamer.getTranscodeArgs(toSegmentStr(segments))...)
-
-	args = append(args,
-		//Uuse the segment muxer
-		"-f", "segment",
-		// Allow small timing variations for keyframe alignment
-		"-segment_time_delta", "0.05",
-		// Write each segment as a MPEG-TS file
-		"-segment_format", "mpegts",
-		// Explicit split times, relative to the seek point
-		"-segment_times", strings.Join(utils.Map(segments, func(seg float64) string {
-			return fmt.Sprintf("%.6f", seg-s.keyframes[startSegment])
-		}), ","),
-		// Wirte a flat list of segments
-		"-segment_list_type", "flat",
-		// Write the segment list to stdout, instead of to a file
-		"-segment_list", "pipe:1",
-		// The starting number of the segment to write to the output path
-		"-segment_start_number", fmt.Sprint(startSegment),
-		// The output path to write the segment to
-		outPath,
-	)
+	args := s.buildArgs(startSegment, endSegment, outPath)
 
 	// Run the FFmpeg command
 	cmd := exec.Command("ffmpeg", args...)
@@ -285,6 +390,7 @@ func (s *Stream) run(startSegment int32) error {
 			Str("path", s.streamWrapper.Info.Path).
 			Int("encoder_id", encoderID).
 			Msg("Failed to create stdout pipe for FFmpeg")
+		abort()
 		return err
 	}
 
@@ -301,6 +407,7 @@ func (s *Stream) run(startSegment int32) error {
 			Str("path", s.streamWrapper.Info.Path).
 			Int("encoder_id", encoderID).
 			Msg("Failed to start FFmpeg command")
+		abort()
 		return err
 	}
 
@@ -325,6 +432,26 @@ func (s *Stream) run(startSegment int32) error {
 				continue
 			}
 
+			// Split fMP4 segments into the init segment and the fragment served to the player
+			if s.format == FormatFMP4 {
+				err := writeFragment(
+					s.streamWrapper.config.AppFs.Fs,
+					fmt.Sprintf(outPath, segment),
+					s.getSegmentPath(encoderID, segment),
+					filepath.Join(s.outDir(), fmp4InitFile),
+				)
+				if err != nil {
+					s.streamWrapper.config.Logger.Error().
+						Err(err).
+						Str("asset_id", s.streamWrapper.assetID).
+						Str("path", s.streamWrapper.Info.Path).
+						Int("encoder_id", encoderID).
+						Int32("segment", segment).
+						Msg("Failed to split fMP4 segment")
+					continue
+				}
+			}
+
 			s.lock.Lock()
 			s.heads[encoderID].segment = segment
 
@@ -359,6 +486,7 @@ func (s *Stream) run(startSegment int32) error {
 			} else {
 				// Mark this segment as completed by this encoder
 				s.segments[segment].encoder = encoderID
+				s.heads[encoderID].produced++
 				close(s.segments[segment].channel)
 
 				// Check if we should stop encoding
@@ -431,6 +559,10 @@ func (s *Stream) run(startSegment int32) error {
 
 		// Mark as deleted instead of removing to preserve encoder IDs for other heads
 		s.heads[encoderID] = DeletedHead
+
+		if limited {
+			s.streamWrapper.limiter.release(userID)
+		}
 	}()
 
 	return nil
@@ -438,6 +570,298 @@ func (s *Stream) run(startSegment int32) error {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// buildArgs builds the FFmpeg arguments to transcode the segments from startSegment up to, but
+// not including, endSegment
+func (s *Stream) buildArgs(startSegment, endSegment int32, outPath string) []string {
+	length := len(s.keyframes)
+
+	// Calculate FFmpeg seek references for precise segment cutting
+	startRef, endRef := s.calculateSeekReferences(startSegment, endSegment, int32(length))
+
+	endPadding := int32(1)
+	if endSegment == int32(length) {
+		endPadding = 0
+	}
+
+	// Calculate the segments to transcode
+	segments := s.keyframes[startSegment+1 : endSegment+endPadding]
+	if len(segments) == 0 {
+		// ffmpeg errors out if the segments are empty
+		segments = []float64{9999999}
+	}
+
+	args := []string{
+		"-nostats", "-hide_banner", "-loglevel", "warning",
+	}
+
+	// Add the hardware acceleration flags if the stream is a video
+	if s.streamer.getFlags()&VideoF != 0 {
+		args = append(args, s.streamWrapper.config.HwAccel.DecodeFlags...)
+	}
+
+	// Add -ss parameter when we are not at the beginning of the stream
+	if startRef != 0 {
+		// Required for video to force pre/post segment to work
+		if s.streamer.getFlags()&VideoF != 0 {
+			args = append(args, "-noaccurate_seek")
+		}
+
+		args = append(args, "-ss", fmt.Sprintf("%.6f", startRef))
+	}
+
+	// Add -to parameter when we don't want to go to the end of the stream
+	if endRef > 0 {
+		args = append(args, "-to", fmt.Sprintf("%.6f", endRef))
+	}
+
+	args = append(args,
+		// If timestamps (PTS) are missing or messy after the seek/streamcopy, generate new PTS so
+		// downstream muxers stay happy
+		"-fflags", "+genpts",
+		// Input file
+		"-i", s.streamWrapper.Info.Path,
+		// Ensure consistent behavior between software and hardware decoding
+		"-start_at_zero",
+		// Preserve input timestamps
+		"-copyts",
+		// Do not buffer at the muxer, instead write the packets as soon as possible
+		"-muxdelay", "0",
+	)
+
+	// Add the transcoding arguments for the type of stream (audio or video)
+	args = append(args, s.streamer.getTranscodeArgs(toSegmentStr(segments))...)
+
+	args = append(args,
+		//Uuse the segment muxer
+		"-f", "segment",
+		// Allow small timing variations for keyframe alignment
+		"-segment_time_delta", "0.05",
+	)
+
+	// Write each segment as a MPEG-TS or mp4 file
+	args = append(args, s.format.muxerArgs()...)
+
+	args = append(args,
+		// Explicit split times, relative to the seek point
+		"-segment_times", strings.Join(utils.Map(segments, func(seg float64) string {
+			return fmt.Sprintf("%.6f", seg-s.keyframes[startSegment])
+		}), ","),
+		// Wirte a flat list of segments
+		"-segment_list_type", "flat",
+		// Write the segment list to stdout, instead of to a file
+		"-segment_list", "pipe:1",
+		// The starting number of the segment to write to the output path
+		"-segment_start_number", fmt.Sprint(startSegment),
+		// The output path to write the segment to
+		outPath,
+	)
+
+	return args
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// transcodeAll transcodes every segment of the stream into dir with a single ffmpeg run and
+// writes the complete marker. It blocks until ffmpeg exits or the context is cancelled, calling
+// progress as each segment is written
+//
+// Segments are written to a temporary directory that replaces dir once the set is complete, so
+// an interrupted run never leaves a partial set behind
+func (s *Stream) transcodeAll(ctx context.Context, dir string, progress func()) error {
+	length := len(s.keyframes)
+	if length == 0 {
+		return errors.New("no keyframes")
+	}
+
+	fs := s.streamWrapper.config.AppFs.Fs
+	tmpDir := dir + ".tmp"
+
+	if err := fs.RemoveAll(tmpDir); err != nil {
+		return err
+	}
+
+	if err := fs.MkdirAll(tmpDir, 0o755); err != nil {
+		return err
+	}
+
+	if err := s.streamWrapper.ensureInput(); err != nil {
+		return err
+	}
+
+	// fMP4 segments are written whole and split into the init segment and fragments as they finish
+	ext := "ts"
+	if s.format == FormatFMP4 {
+		ext = "mp4"
+	}
+
+	outPath := filepath.Join(tmpDir, "segment-%d."+ext)
+	args := s.buildArgs(0, int32(length), outPath)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	s.streamWrapper.config.Logger.Debug().
+		Str("asset_id", s.streamWrapper.assetID).
+		Str("path", s.streamWrapper.Info.Path).
+		Str("command", strings.Join(cmd.Args, " ")).
+		Msg("Running FFmpeg for complete transcode")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// The segment list holds a line per finished segment
+	var splitErr error
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if s.format == FormatFMP4 && splitErr == nil {
+			var segment int32
+			_, _ = fmt.Sscanf(scanner.Text(), filepath.Base(outPath), &segment)
+
+			splitErr = writeFragment(
+				fs,
+				fmt.Sprintf(outPath, segment),
+				completeSegmentPath(tmpDir, segment, s.format),
+				filepath.Join(tmpDir, fmp4InitFile),
+			)
+		}
+
+		if progress != nil {
+			progress()
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		_ = fs.RemoveAll(tmpDir)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if splitErr != nil {
+		_ = fs.RemoveAll(tmpDir)
+		return fmt.Errorf("failed to split fMP4 segment: %w", splitErr)
+	}
+
+	return s.finishCompleteSet(tmpDir, dir)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// remuxAll writes the complete fMP4 set of the stream into dir by copying the segments of the
+// complete MPEG-TS set in sourceDir, which was encoded with the same settings. Each segment keeps
+// its timestamps, so the fragments line up with those encoded on demand
+func (s *Stream) remuxAll(ctx context.Context, sourceDir, dir string, progress func()) error {
+	length := len(s.keyframes)
+	if length == 0 {
+		return errors.New("no keyframes")
+	}
+
+	fs := s.streamWrapper.config.AppFs.Fs
+	tmpDir := dir + ".tmp"
+
+	if err := fs.RemoveAll(tmpDir); err != nil {
+		return err
+	}
+
+	if err := fs.MkdirAll(tmpDir, 0o755); err != nil {
+		return err
+	}
+
+	for segment := range int32(length) {
+		outPath := filepath.Join(tmpDir, fmt.Sprintf("segment-%d.mp4", segment))
+
+		cmd := exec.CommandContext(
+			ctx,
+			"ffmpeg",
+			"-nostats", "-hide_banner", "-loglevel", "warning",
+			"-copyts",
+			"-i", completeSegmentPath(sourceDir, segment, FormatTS),
+			"-map", "0",
+			"-c", "copy",
+			"-f", "mp4",
+			"-movflags", "+empty_moov+default_base_moof+frag_discont",
+			"-use_editlist", "0",
+			"-y", outPath,
+		)
+
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			_ = fs.RemoveAll(tmpDir)
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+
+		err := writeFragment(
+			fs,
+			outPath,
+			completeSegmentPath(tmpDir, segment, FormatFMP4),
+			filepath.Join(tmpDir, fmp4InitFile),
+		)
+		if err != nil {
+			_ = fs.RemoveAll(tmpDir)
+			return fmt.Errorf("failed to split fMP4 segment: %w", err)
+		}
+
+		if progress != nil {
+			progress()
+		}
+	}
+
+	return s.finishCompleteSet(tmpDir, dir)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// finishCompleteSet ensures every segment was written to tmpDir, and the init segment of fMP4
+// sets, then writes the complete marker and moves the set into dir
+func (s *Stream) finishCompleteSet(tmpDir, dir string) error {
+	fs := s.streamWrapper.config.AppFs.Fs
+	length := len(s.keyframes)
+
+	if s.format == FormatFMP4 {
+		if _, err := fs.Stat(filepath.Join(tmpDir, fmp4InitFile)); err != nil {
+			_ = fs.RemoveAll(tmpDir)
+			return fmt.Errorf("init segment is missing: %w", err)
+		}
+	}
+
+	for segment := range int32(length) {
+		if _, err := fs.Stat(completeSegmentPath(tmpDir, segment, s.format)); err != nil {
+			_ = fs.RemoveAll(tmpDir)
+			return fmt.Errorf("segment %d is missing: %w", segment, err)
+		}
+	}
+
+	if err := writeCompleteMarker(fs, tmpDir, length, completeFingerprint(s.streamer)); err != nil {
+		_ = fs.RemoveAll(tmpDir)
+		return err
+	}
+
+	if err := fs.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	return fs.Rename(tmpDir, dir)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // calculateSeekReferences calculates the start and end seek references for FFmpeg
 func (s *Stream) calculateSeekReferences(startSegment, endSegment, length int32) (float64, float64) {
 	startRef := float64(0)
@@ -478,23 +902,38 @@ func (s *Stream) calculateSeekReferences(startSegment, endSegment, length int32)
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetIndex generates the HLS index playlist for the stream (video or audio)
+// GetIndex generates the HLS index playlist for the stream (video or audio). fMP4 playlists
+// reference the init segment with EXT-X-MAP
 func (s *Stream) GetIndex() (string, error) {
-	index := `#EXTM3U
-#EXT-X-VERSION:6
-#EXT-X-TARGETDURATION:6
-#EXT-X-MEDIA-SEQUENCE:0
-#EXT-X-INDEPENDENT-SEGMENTS
-`
 	length := len(s.keyframes)
 
-	for segment := int32(0); segment < int32(length)-1; segment++ {
-		index += fmt.Sprintf("#EXTINF:%.6f\n", s.keyframes[segment+1]-s.keyframes[segment])
-		index += fmt.Sprintf("segment-%d.ts\n", segment)
+	durations := make([]float64, length)
+	for segment := range length - 1 {
+		durations[segment] = s.keyframes[segment+1] - s.keyframes[segment]
+	}
+	durations[length-1] = float64(s.streamWrapper.Info.Duration) - s.keyframes[length-1]
+
+	// The target duration must be at least the longest segment, which may be longer than
+	// the segment duration of the profile when keyframes are far apart
+	targetDuration := 6
+	for _, duration := range durations {
+		targetDuration = max(targetDuration, int(math.Ceil(duration)))
+	}
+
+	index := "#EXTM3U\n"
+	index += "#EXT-X-VERSION:6\n"
+	index += fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", targetDuration)
+	index += "#EXT-X-MEDIA-SEQUENCE:0\n"
+	index += "#EXT-X-INDEPENDENT-SEGMENTS\n"
+
+	if s.format == FormatFMP4 {
+		index += fmt.Sprintf("#EXT-X-MAP:URI=\"%s\"\n", fmp4InitFile)
 	}
 
-	index += fmt.Sprintf("#EXTINF:%.6f\n", float64(s.streamWrapper.Info.Duration)-s.keyframes[length-1])
-	index += fmt.Sprintf("segment-%d.ts\n", length-1)
+	for segment, duration := range durations {
+		index += fmt.Sprintf("#EXTINF:%.6f\n", duration)
+		index += fmt.Sprintf("segment-%d.%s\n", segment, s.format.segmentExt())
+	}
 
 	index += `#EXT-X-ENDLIST`
 
@@ -503,9 +942,17 @@ func (s *Stream) GetIndex() (string, error) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetSegment retrieves a specific segment path, starting transcoding if needed
-func (s *Stream) GetSegment(segment int32) (string, error) {
+// GetSegment retrieves a specific segment path for a user, starting transcoding if needed. When
+// a new encoder would exceed the head limits, the segment is waited for if an existing encoder
+// will reach it
+func (s *Stream) GetSegment(userID string, segment int32) (string, error) {
 	s.lock.RLock()
+	if complete := s.complete; complete != "" {
+		s.lock.RUnlock()
+		s.streamWrapper.cache.touch(complete)
+		return completeSegmentPath(complete, segment, s.format), nil
+	}
+
 	ready := s.isSegmentReady(segment)
 
 	distance := 0.
@@ -534,8 +981,14 @@ func (s *Stream) GetSegment(segment int32) (string, error) {
 				Int32("segment", segment).
 				Float64("distance", distance).
 				Msg("Creating new head since closest head is far away")
-			err := s.run(segment)
-			if err != nil {
+			err := s.run(userID, segment)
+			if errors.Is(err, ErrTranscodeLimit) && isScheduled {
+				s.streamWrapper.config.Logger.Debug().
+					Str("asset_id", s.streamWrapper.assetID).
+					Str("path", s.streamWrapper.Info.Path).
+					Int32("segment", segment).
+					Msg("Waiting for segment since the transcode limit is reached")
+			} else if err != nil {
 				s.streamWrapper.config.Logger.Error().
 					Err(err).
 					Str("asset_id", s.streamWrapper.assetID).
@@ -567,14 +1020,51 @@ func (s *Stream) GetSegment(segment int32) (string, error) {
 		}
 	}
 
-	s.prepareNextSegments(segment)
-	return fmt.Sprintf(s.streamer.getOutPath(s.segments[segment].encoder), segment), nil
+	s.streamWrapper.cache.touch(s.outDir())
+	s.prepareNextSegments(userID, segment)
+	return s.getSegmentPath(s.segments[segment].encoder, segment), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetInit returns the path of the init segment of a fMP4 stream. The init segment is written
+// alongside the first segment of an encoder, so the first segment is transcoded for the user
+// when no encoder has run yet. A complete set holds its own init segment
+func (s *Stream) GetInit(userID string) (string, error) {
+	if s.format != FormatFMP4 {
+		return "", errors.New("only fMP4 streams have an init segment")
+	}
+
+	s.lock.RLock()
+	complete := s.complete
+	s.lock.RUnlock()
+
+	if complete != "" {
+		s.streamWrapper.cache.touch(complete)
+		return filepath.Join(complete, fmp4InitFile), nil
+	}
+
+	initPath := filepath.Join(s.outDir(), fmp4InitFile)
+
+	exists, err := afero.Exists(s.streamWrapper.config.AppFs.Fs, initPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !exists {
+		if _, err := s.GetSegment(userID, 0); err != nil {
+			return "", err
+		}
+	}
+
+	s.streamWrapper.cache.touch(s.outDir())
+	return initPath, nil
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// prepareNextSegments starts encoding future segments for video streams
-func (s *Stream) prepareNextSegments(segment int32) {
+// prepareNextSegments starts encoding future segments of video streams for a user
+func (s *Stream) prepareNextSegments(userID string, segment int32) {
 	// Skip audio streams as they are cheap to encode on-demand
 	if s.streamer.getFlags()&VideoF == 0 {
 		return
@@ -598,7 +1088,7 @@ func (s *Stream) prepareNextSegments(segment int32) {
 			Str("path", s.streamWrapper.Info.Path).
 			Int32("segment", i).
 			Msg("Creating new head for future segment")
-		go s.run(i)
+		go s.run(userID, i)
 
 		return
 	}
@@ -655,6 +1145,11 @@ func (s *Stream) KillHead(encoderID int) {
 
 // getKeyframes retrieves keyframes from the database, falling back to empty slice on error
 func getKeyframes(wrapper *StreamWrapper) []float64 {
+	// Audio-only assets have no video keyframes
+	if wrapper.isAudioOnly() {
+		return fixedKeyframes(wrapper.Info.Duration, wrapper.profile.audioSegmentDuration())
+	}
+
 	assetKeyframes, err := wrapper.config.Dao.GetAssetKeyframes(context.Background(), wrapper.assetID)
 	if err != nil {
 		wrapper.config.Logger.Error().
@@ -666,7 +1161,7 @@ func getKeyframes(wrapper *StreamWrapper) []float64 {
 	}
 
 	if assetKeyframes != nil && len(assetKeyframes.Keyframes) > 0 {
-		return assetKeyframes.Keyframes
+		return wrapper.profile.segmentKeyframes(assetKeyframes.Keyframes)
 	}
 
 	return []float64{}
@@ -674,6 +1169,19 @@ func getKeyframes(wrapper *StreamWrapper) []float64 {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// fixedKeyframes returns segment start times spaced `length` seconds apart, for streams that
+// can be cut anywhere
+func fixedKeyframes(duration, length float64) []float64 {
+	keyframes := []float64{0}
+	for t := length; t < duration; t += length {
+		keyframes = append(keyframes, t)
+	}
+
+	return keyframes
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // toSegmentStr converts keyframe timestamps to a comma-separated string
 func toSegmentStr(segments []float64) string {
 	return strings.Join(utils.Map(segments, func(seg float64) string {
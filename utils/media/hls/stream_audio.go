@@ -6,42 +6,98 @@ import (
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// AudioMode is how an audio track is written to its stream
+type AudioMode string
+
+const (
+	// AudioStereo transcodes the track to AAC with the bitrate and channels of the profile
+	AudioStereo AudioMode = ""
+
+	// AudioSurround passes a 5.1+ AC-3 or E-AC-3 track through as-is
+	AudioSurround AudioMode = "surround"
+
+	// AudioRemux copies the track as-is, whatever its codec, for clients that play it natively
+	AudioRemux AudioMode = "remux"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AudioModeFromString returns the audio mode for a string. An empty string is stereo
+func AudioModeFromString(str string) (AudioMode, error) {
+	switch AudioMode(str) {
+	case AudioStereo, AudioSurround, AudioRemux:
+		return AudioMode(str), nil
+	}
+
+	return "", fmt.Errorf("unknown audio mode %q", str)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // AudioStream represents an audio transcoding stream
 type AudioStream struct {
 	Stream
 	index uint32
+	mode  AudioMode
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// NewAudioStream creates a new audio stream for the given file and index
-func NewAudioStream(streamWrapper *StreamWrapper, audioIndex uint32) (*AudioStream, error) {
+// NewAudioStream creates a new audio stream for the given file, index, mode and format
+func NewAudioStream(streamWrapper *StreamWrapper, audioIndex uint32, mode AudioMode, format Format) (*AudioStream, error) {
 	streamWrapper.config.Logger.Debug().
 		Str("asset_id", streamWrapper.assetID).
 		Str("path", streamWrapper.Info.Path).
 		Uint32("audio_index", audioIndex).
+		Str("mode", string(mode)).
+		Str("format", string(format)).
 		Msg("Creating an audio stream")
 
 	audioStream := &AudioStream{
 		Stream: Stream{
 			streamWrapper: streamWrapper,
 			heads:         make([]Head, 0),
+			format:        format,
 		},
 		index: audioIndex,
+		mode:  mode,
 	}
 
 	audioStream.streamer = audioStream
 	audioStream.keyframes = getKeyframes(streamWrapper)
 	audioStream.initializeSegments()
+	audioStream.loadCompleteSet()
 
 	return audioStream, nil
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// getOutPath returns the output path pattern for segments
-func (as *AudioStream) getOutPath(encoderID int) string {
-	return fmt.Sprintf("%s/segment-a%d-%d-%%d.ts", as.streamWrapper.Out, as.index, encoderID)
+// getName returns the name of the directory holding the segments of the audio track
+func (as *AudioStream) getName() string {
+	if as.mode != AudioStereo {
+		return fmt.Sprintf("audio-%d-%s%s", as.index, as.mode, as.format.dirSuffix())
+	}
+
+	// Normalized segments are kept apart from those transcoded before the loudness was known
+	if as.loudnessGain() != 0 {
+		return fmt.Sprintf("audio-%d-norm%s", as.index, as.format.dirSuffix())
+	}
+
+	return fmt.Sprintf("audio-%d%s", as.index, as.format.dirSuffix())
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// loudnessGain returns the loudness gain of the audio track of the stream
+func (as *AudioStream) loudnessGain() float64 {
+	for _, audio := range as.streamWrapper.Info.Audios {
+		if audio.Index == as.index {
+			return audio.LoudnessGain
+		}
+	}
+
+	return 0
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -53,15 +109,32 @@ func (as *AudioStream) getFlags() Flags {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// getTranscodeArgs returns the FFmpeg arguments for audio transcoding
-//
-// TODO: Support 5.1 audio streams
-// TODO: Support multi audio qualities
+// getTranscodeArgs returns the FFmpeg arguments for audio transcoding, using the bitrate and
+// channels of the profile and the loudness gain of the track. Surround and remux streams are
+// copied, so they are never normalized
 func (as *AudioStream) getTranscodeArgs(_ string) []string {
-	return []string{
+	if as.mode != AudioStereo {
+		return []string{
+			"-map", fmt.Sprintf("0:a:%d", as.index),
+			"-c:a", "copy",
+		}
+	}
+
+	profile := as.streamWrapper.profile
+
+	args := []string{
 		"-map", fmt.Sprintf("0:a:%d", as.index),
 		"-c:a", "aac",
-		"-ac", "2",
-		"-b:a", "128k",
+		"-ac", fmt.Sprint(profile.AudioChannels),
+		"-b:a", fmt.Sprint(profile.AudioBitrate),
 	}
+
+	// A limiter stops boosted peaks from clipping
+	if gain := as.loudnessGain(); gain > 0 {
+		args = append(args, "-af", fmt.Sprintf("volume=%.1fdB,alimiter=limit=0.95", gain))
+	} else if gain < 0 {
+		args = append(args, "-af", fmt.Sprintf("volume=%.1fdB", gain))
+	}
+
+	return args
 }
@@ -0,0 +1,214 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/geerew/off-course/utils/media/subtitles"
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// subtitleSegmentLength is the length, in seconds, of each WebVTT segment
+const subtitleSegmentLength = 30.0
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// SubtitleStream represents an embedded subtitle stream that is extracted to segmented WebVTT
+//
+// Subtitles are small so, unlike video and audio, the whole stream is extracted by a single
+// ffmpeg run on the first segment request. A failed run, or segments since removed from the
+// cache, are extracted again on the next request
+type SubtitleStream struct {
+	streamWrapper *StreamWrapper
+	index         uint32
+	lock          sync.Mutex
+
+	// ctx is cancelled when the stream is killed, stopping a running extraction
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewSubtitleStream creates a new subtitle stream for the given stream index
+func NewSubtitleStream(streamWrapper *StreamWrapper, index uint32) *SubtitleStream {
+	streamWrapper.config.Logger.Debug().
+		Str("asset_id", streamWrapper.assetID).
+		Str("path", streamWrapper.Info.Path).
+		Uint32("subtitle_index", index).
+		Msg("Creating a subtitle stream")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &SubtitleStream{
+		streamWrapper: streamWrapper,
+		index:         index,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Kill stops a running extraction
+func (ss *SubtitleStream) Kill() {
+	ss.cancel()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// segmentCount returns the number of segments for the stream
+func (ss *SubtitleStream) segmentCount() int {
+	return max(1, int(math.Ceil(ss.streamWrapper.Info.Duration/subtitleSegmentLength)))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// getOutDir returns the directory the segments are written to
+func (ss *SubtitleStream) getOutDir() string {
+	return filepath.Join(ss.streamWrapper.Out, fmt.Sprintf("subtitle-%d", ss.index))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetIndex generates the HLS index playlist for the stream
+func (ss *SubtitleStream) GetIndex() string {
+	duration := ss.streamWrapper.Info.Duration
+	count := ss.segmentCount()
+
+	index := "#EXTM3U\n"
+	index += "#EXT-X-VERSION:3\n"
+	index += "#EXT-X-PLAYLIST-TYPE:VOD\n"
+	index += fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(subtitleSegmentLength))
+	index += "#EXT-X-MEDIA-SEQUENCE:0\n"
+
+	for segment := 0; segment < count; segment++ {
+		length := subtitleSegmentLength
+		if segment == count-1 && duration > 0 {
+			length = duration - float64(segment)*subtitleSegmentLength
+		}
+
+		index += fmt.Sprintf("#EXTINF:%.6f,\n", length)
+		index += fmt.Sprintf("segment-%d.vtt\n", segment)
+	}
+
+	index += "#EXT-X-ENDLIST\n"
+
+	return index
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetSegment returns the path to a segment, extracting the stream if needed
+func (ss *SubtitleStream) GetSegment(segment int32) (string, error) {
+	if segment < 0 || int(segment) >= ss.segmentCount() {
+		return "", fmt.Errorf("segment %d out of range", segment)
+	}
+
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	fs := ss.streamWrapper.config.AppFs.Fs
+	path := filepath.Join(ss.getOutDir(), fmt.Sprintf("segment-%d.vtt", segment))
+
+	if exists, err := afero.Exists(fs, path); err == nil && exists {
+		return path, nil
+	}
+
+	if err := ss.extract(); err != nil {
+		return "", err
+	}
+
+	if exists, err := afero.Exists(fs, path); err != nil || !exists {
+		return "", fmt.Errorf("subtitle segment %d is missing", segment)
+	}
+
+	return path, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// extract converts the subtitle stream to WebVTT with ffmpeg, then splits it into segments
+func (ss *SubtitleStream) extract() error {
+	fs := ss.streamWrapper.config.AppFs.Fs
+	outDir := ss.getOutDir()
+
+	if err := fs.MkdirAll(outDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create subtitle directory: %w", err)
+	}
+
+	if err := ss.streamWrapper.ensureInput(); err != nil {
+		return fmt.Errorf("failed to extract asset from archive: %w", err)
+	}
+
+	fullPath := filepath.Join(outDir, "full.vtt")
+
+	args := []string{
+		"-nostats", "-hide_banner", "-loglevel", "warning",
+		"-i", ss.streamWrapper.Info.Path,
+		"-map", fmt.Sprintf("0:%d", ss.index),
+		"-c:s", "webvtt",
+		"-f", "webvtt",
+		"-y", fullPath,
+	}
+
+	cmd := exec.CommandContext(ss.ctx, "ffmpeg", args...)
+	ss.streamWrapper.config.Logger.Debug().
+		Str("asset_id", ss.streamWrapper.assetID).
+		Str("path", ss.streamWrapper.Info.Path).
+		Uint32("subtitle_index", ss.index).
+		Str("command", strings.Join(cmd.Args, " ")).
+		Msg("Running FFmpeg")
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ss.ctx.Err() != nil {
+			return ss.ctx.Err()
+		}
+
+		ss.streamWrapper.config.Logger.Error().
+			Err(err).
+			Str("asset_id", ss.streamWrapper.assetID).
+			Str("path", ss.streamWrapper.Info.Path).
+			Uint32("subtitle_index", ss.index).
+			Str("stderr", stderr.String()).
+			Msg("Failed to extract subtitle stream")
+		return fmt.Errorf("failed to extract subtitle stream: %w", err)
+	}
+
+	data, err := afero.ReadFile(fs, fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to read subtitle stream: %w", err)
+	}
+
+	return ss.writeSegments(data)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// writeSegments splits a WebVTT file into segments and writes them to the output directory
+func (ss *SubtitleStream) writeSegments(data []byte) error {
+	segments, err := subtitles.SegmentWebVTT(data, subtitleSegmentLength, ss.segmentCount())
+	if err != nil {
+		return fmt.Errorf("failed to segment subtitle stream: %w", err)
+	}
+
+	fs := ss.streamWrapper.config.AppFs.Fs
+	for i, segment := range segments {
+		path := filepath.Join(ss.getOutDir(), fmt.Sprintf("segment-%d.vtt", i))
+		if err := afero.WriteFile(fs, path, segment, 0o644); err != nil {
+			return fmt.Errorf("failed to write subtitle segment: %w", err)
+		}
+	}
+
+	return nil
+}
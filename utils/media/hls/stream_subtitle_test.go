@@ -0,0 +1,120 @@
+package hls
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/geerew/off-course/utils"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func newTestSubtitleStream(duration float64) *SubtitleStream {
+	sw := &StreamWrapper{
+		config: &TranscoderConfig{
+			AppFs:  appfs.New(afero.NewMemMapFs()),
+			Logger: logger.NilLogger(),
+		},
+		assetID: "asset",
+		Out:     "/cache/hls/asset",
+		Info: &MediaInfo{
+			Path:      "/course/01 video.mkv",
+			Duration:  duration,
+			Subtitles: []EmbeddedSubtitle{{Index: 2, Codec: "subrip"}},
+		},
+	}
+
+	return NewSubtitleStream(sw, 2)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestSubtitleStream_GetIndex(t *testing.T) {
+	t.Run("multiple segments", func(t *testing.T) {
+		ss := newTestSubtitleStream(70)
+
+		require.Equal(t, "#EXTM3U\n"+
+			"#EXT-X-VERSION:3\n"+
+			"#EXT-X-PLAYLIST-TYPE:VOD\n"+
+			"#EXT-X-TARGETDURATION:30\n"+
+			"#EXT-X-MEDIA-SEQUENCE:0\n"+
+			"#EXTINF:30.000000,\nsegment-0.vtt\n"+
+			"#EXTINF:30.000000,\nsegment-1.vtt\n"+
+			"#EXTINF:10.000000,\nsegment-2.vtt\n"+
+			"#EXT-X-ENDLIST\n", ss.GetIndex())
+	})
+
+	t.Run("unknown duration", func(t *testing.T) {
+		ss := newTestSubtitleStream(0)
+		require.Contains(t, ss.GetIndex(), "#EXTINF:30.000000,\nsegment-0.vtt\n#EXT-X-ENDLIST\n")
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestSubtitleStream_WriteSegments(t *testing.T) {
+	ss := newTestSubtitleStream(45)
+	fs := ss.streamWrapper.config.AppFs.Fs
+
+	vtt := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nFirst\n\n00:00:40.000 --> 00:00:41.000\nSecond\n"
+	require.NoError(t, ss.writeSegments([]byte(vtt)))
+
+	data, err := afero.ReadFile(fs, filepath.Join(ss.getOutDir(), "segment-0.vtt"))
+	require.NoError(t, err)
+	require.Equal(t, "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nFirst\n", string(data))
+
+	data, err = afero.ReadFile(fs, filepath.Join(ss.getOutDir(), "segment-1.vtt"))
+	require.NoError(t, err)
+	require.Equal(t, "WEBVTT\n\n00:00:40.000 --> 00:00:41.000\nSecond\n", string(data))
+
+	// Out of range segments are rejected before extraction
+	_, err = ss.GetSegment(2)
+	require.ErrorContains(t, err, "out of range")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStreamWrapper_GetEmbeddedSubtitleIndex(t *testing.T) {
+	ss := newTestSubtitleStream(10)
+	sw := ss.streamWrapper
+	sw.subtitles = utils.NewCMap[uint32, *SubtitleStream]()
+
+	index, err := sw.GetEmbeddedSubtitleIndex(2)
+	require.NoError(t, err)
+	require.Contains(t, index, "segment-0.vtt")
+
+	_, err = sw.GetEmbeddedSubtitleIndex(5)
+	require.ErrorContains(t, err, "not found")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestSubtitleStream_GetSegment(t *testing.T) {
+	t.Run("retried after a failure", func(t *testing.T) {
+		ss := newTestSubtitleStream(45)
+
+		// The file does not exist, so ffmpeg fails
+		_, err := ss.GetSegment(0)
+		require.Error(t, err)
+
+		// The failure is not kept, so segments written since are served
+		require.NoError(t, ss.writeSegments([]byte("WEBVTT\n")))
+
+		path, err := ss.GetSegment(1)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(ss.getOutDir(), "segment-1.vtt"), path)
+	})
+
+	t.Run("killed", func(t *testing.T) {
+		ss := newTestSubtitleStream(45)
+		ss.Kill()
+
+		_, err := ss.GetSegment(0)
+		require.Error(t, err)
+		require.Error(t, ss.ctx.Err())
+	})
+}
@@ -2,6 +2,7 @@ package hls
 
 import (
 	"fmt"
+	"slices"
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -15,13 +16,14 @@ type VideoStream struct {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// NewVideoStream creates a new video stream for the given file, index and quality
-func NewVideoStream(streamWrapper *StreamWrapper, videoIndex uint32, quality Quality) (*VideoStream, error) {
+// NewVideoStream creates a new video stream for the given file, index, quality and format
+func NewVideoStream(streamWrapper *StreamWrapper, videoIndex uint32, quality Quality, format Format) (*VideoStream, error) {
 	streamWrapper.config.Logger.Debug().
 		Str("asset_id", streamWrapper.assetID).
 		Str("path", streamWrapper.Info.Path).
 		Uint32("video_index", videoIndex).
 		Str("quality", string(quality)).
+		Str("format", string(format)).
 		Msg("Creating a new video stream")
 
 	// Find the video metadata from the file's info
@@ -41,6 +43,7 @@ func NewVideoStream(streamWrapper *StreamWrapper, videoIndex uint32, quality Qua
 		Stream: Stream{
 			streamWrapper: streamWrapper,
 			heads:         make([]Head, 0),
+			format:        format,
 		},
 		quality: quality,
 		video:   video,
@@ -49,6 +52,7 @@ func NewVideoStream(streamWrapper *StreamWrapper, videoIndex uint32, quality Qua
 	videoStream.streamer = videoStream
 	videoStream.keyframes = getKeyframes(streamWrapper)
 	videoStream.initializeSegments()
+	videoStream.loadCompleteSet()
 
 	return videoStream, nil
 }
@@ -65,9 +69,9 @@ func (vs *VideoStream) getFlags() Flags {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// getOutPath returns the output path pattern for segments
-func (vs *VideoStream) getOutPath(encoderID int) string {
-	return fmt.Sprintf("%s/segment-%s-%d-%%d.ts", vs.streamWrapper.Out, vs.quality, encoderID)
+// getName returns the name of the directory holding the segments of the quality
+func (vs *VideoStream) getName() string {
+	return string(vs.quality) + vs.format.dirSuffix()
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -81,40 +85,60 @@ func (vs *VideoStream) getTranscodeArgs(segments string) []string {
 	// In original mode, we don't need to transcode the video
 	if vs.quality == Original {
 		args = append(args, "-c:v", "copy")
+
+		// Apple players only play HEVC in mp4 when tagged as hvc1
+		if vs.format == FormatFMP4 && vs.video.Codec == "hevc" {
+			args = append(args, "-tag:v", "hvc1")
+		}
+
 		return args
 	}
 
-	args = append(args, vs.streamWrapper.config.HwAccel.EncodeFlags...)
+	hwAccel := vs.streamWrapper.config.HwAccel
+	profile := vs.streamWrapper.profile
+
+	args = append(args, encodeFlags(hwAccel, profile.Preset)...)
 
 	quality := vs.quality
-	if vs.quality != NoResize {
-		width := int32(float64(vs.quality.Height()) / float64(vs.video.Height) * float64(vs.video.Width))
+	height := vs.outputHeight()
+	if vs.quality != NoResize || height != vs.video.Height {
+		width := int32(float64(height) / float64(vs.video.Height) * float64(vs.video.Width))
 		// force a width that is a multiple of two else some apps behave badly
 		width = closestMultiple(width, 2)
 		args = append(args,
-			"-vf", fmt.Sprintf(vs.streamWrapper.config.HwAccel.ScaleFilter, width, vs.quality.Height()),
+			"-vf", fmt.Sprintf(hwAccel.ScaleFilter, width, height),
 		)
-	} else {
+	} else if hwAccel.NoResizeFilter != "" {
 		// Only add video filter if NoResizeFilter is defined (not empty)
-		if vs.streamWrapper.config.HwAccel.NoResizeFilter != "" {
-			args = append(args, "-vf", vs.streamWrapper.config.HwAccel.NoResizeFilter)
-		}
+		args = append(args, "-vf", hwAccel.NoResizeFilter)
+	}
 
+	if vs.quality == NoResize {
 		// NoResize doesn't have bitrate info, fallback to a know quality higher or equal
+		quality = Qualities[len(Qualities)-1]
 		for _, q := range Qualities {
-			if q.Height() >= vs.video.Height {
+			if q.Height() >= height {
 				quality = q
 				break
 			}
 		}
 	}
 
+	// Even less sure but bufsize are 5x the average bitrate since the average bitrate is only
+	// useful for hls segments
+	args = append(args,
+		"-bufsize", fmt.Sprint(profile.MaxBitrate(quality)*5),
+		"-maxrate", fmt.Sprint(profile.MaxBitrate(quality)),
+	)
+
+	// Constant quality is only supported by the software encoder. The max rate still caps it
+	if profile.CRF > 0 && hwAccel.Name == "disabled" {
+		args = append(args, "-crf", fmt.Sprint(profile.CRF))
+	} else {
+		args = append(args, "-b:v", fmt.Sprint(profile.AverageBitrate(quality)))
+	}
+
 	args = append(args,
-		// Even less sure but bufsize are 5x the average bitrate since the average bitrate is only
-		// useful for hls segments
-		"-bufsize", fmt.Sprint(quality.MaxBitrate()*5),
-		"-b:v", fmt.Sprint(quality.AverageBitrate()),
-		"-maxrate", fmt.Sprint(quality.MaxBitrate()),
 		// Force segments to be split exactly on keyframes (only works when transcoding)
 		// forced-idr is needed to force keyframes to be an idr-frame (by default it can be any i frames)
 		// without this option, some hardware encoders uses others i-frames and the -f segment can't cut at them
@@ -129,6 +153,39 @@ func (vs *VideoStream) getTranscodeArgs(segments string) []string {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// outputHeight returns the height of the transcoded video. Videos taller than the max quality of
+// the profile are scaled down to it when transcoding without resizing
+func (vs *VideoStream) outputHeight() uint32 {
+	if vs.quality != NoResize {
+		return vs.quality.Height()
+	}
+
+	if maxHeight := vs.streamWrapper.profile.MaxQuality.Height(); vs.video.Height > maxHeight {
+		return maxHeight
+	}
+
+	return vs.video.Height
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// encodeFlags returns the encode flags of the hardware acceleration, replacing its preset when
+// one is given
+func encodeFlags(hwAccel HwAccelT, preset string) []string {
+	flags := slices.Clone(hwAccel.EncodeFlags)
+	if preset == "" {
+		return flags
+	}
+
+	if idx := slices.Index(flags, "-preset"); idx != -1 && idx+1 < len(flags) {
+		flags[idx+1] = preset
+	}
+
+	return flags
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // closestMultiple finds the closest multiple of x that is >= n
 func closestMultiple(n int32, x int32) int32 {
 	if x > n {
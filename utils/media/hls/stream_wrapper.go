@@ -2,6 +2,8 @@ package hls
 
 import (
 	"fmt"
+	"math"
+	"slices"
 	"strings"
 
 	"github.com/geerew/off-course/utils"
@@ -11,23 +13,45 @@ import (
 
 // StreamWrapper represents a file being transcoded into HLS streams
 type StreamWrapper struct {
-	config  *TranscoderConfig
-	assetID string
-	err     error
-	Out     string
-	Info    *MediaInfo
-	videos  utils.CMap[VideoKey, *VideoStream]
-	audios  utils.CMap[uint32, *AudioStream]
+	config    *TranscoderConfig
+	cache     *Cache
+	limiter   *headLimiter
+	profile   *Profile
+	assetID   string
+	err       error
+	Out       string
+	Complete  string
+	Info      *MediaInfo
+	videos    utils.CMap[VideoKey, *VideoStream]
+	audios    utils.CMap[AudioKey, *AudioStream]
+	subtitles utils.CMap[uint32, *SubtitleStream]
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ensureInput makes sure the file read by ffmpeg exists before ffmpeg is started. Archive
+// members are extracted again when they were evicted from the archive cache
+func (sw *StreamWrapper) ensureInput() error {
+	if sw.Info.Source == "" {
+		return nil
+	}
+
+	_, err := sw.config.AppFs.LocalPath(sw.Info.Source)
+	return err
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // MediaInfo represents media file information extracted for HLS
 type MediaInfo struct {
-	Path     string
-	Duration float64
-	Videos   []Video
-	Audios   []Audio
+	// Path is the file read by ffmpeg. For archive members this is the extracted copy, while
+	// Source is the path of the asset itself
+	Path      string
+	Source    string
+	Duration  float64
+	Videos    []Video
+	Audios    []Audio
+	Subtitles []EmbeddedSubtitle
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -47,6 +71,32 @@ type Video struct {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// passthroughCodecs maps the video codecs that only fMP4 passes through for the original quality
+// to their RFC 6381 codec string. The profile and level are not probed, so a common one is
+// assumed
+var passthroughCodecs = map[string]string{
+	"hevc": "hvc1.1.6.L150.90",
+	"av1":  "av01.0.08M.08",
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// originalMimeCodec returns the codec string of the original quality in the given format,
+// falling back to the given codec
+func (v *Video) originalMimeCodec(format Format, fallback string) string {
+	if v.MimeCodec != nil {
+		return *v.MimeCodec
+	}
+
+	if codec, ok := passthroughCodecs[v.Codec]; ok && format == FormatFMP4 {
+		return codec
+	}
+
+	return fallback
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // Audio represents audio metadata for a single audio track
 type Audio struct {
 	Index     uint32
@@ -55,17 +105,98 @@ type Audio struct {
 	Codec     string
 	MimeCodec *string
 	Bitrate   uint32
+	Channels  int
+	IsDefault bool
+
+	// LoudnessGain is the gain, in dB, applied when the track is transcoded. 0 when the track is
+	// not normalized
+	LoudnessGain float64
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// canPassthrough returns true when the track is a surround track that can be offered as-is
+func (a Audio) canPassthrough() bool {
+	_, ok := surroundCodecs[a.Codec]
+	return ok && a.Channels >= 6
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	// aacCodec is the codec of the transcoded (stereo) audio
+	aacCodec = "mp4a.40.2"
+
+	stereoAudioGroup   = "audio"
+	surroundAudioGroup = "audio-surround"
+	remuxAudioGroup    = "audio-remux"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// surroundCodecs maps the codecs that are passed through for surround renditions to their
+// RFC 6381 codec string
+var surroundCodecs = map[string]string{
+	"ac3":  "ac-3",
+	"eac3": "ec-3",
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// audioGroup is a group of audio renditions that a video variant plays with
+type audioGroup struct {
+	id string
+
+	// codecs are the codecs of the renditions in the group
+	codecs []string
+
+	// remuxCodec is the codec of the tracks copied by the remux group
+	remuxCodec string
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// variantAttributes returns the CODECS and AUDIO attributes of a variant that plays the video
+// codec with the group
+func (g audioGroup) variantAttributes(videoCodec string) string {
+	codecs := append([]string{videoCodec}, g.codecs...)
+	return fmt.Sprintf("CODECS=\"%s\",AUDIO=\"%s\",", strings.Join(codecs, ","), g.id)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// EmbeddedSubtitle represents metadata for a text subtitle stream embedded in the file
+type EmbeddedSubtitle struct {
+	Index     uint32
+	Title     *string
+	Language  *string
+	Codec     string
 	IsDefault bool
+	IsForced  bool
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Subtitle represents a subtitle file paired with the asset that is served alongside the
+// streams as a WebVTT rendition
+type Subtitle struct {
+	ID       string
+	Language string
+	Name     string
+	Default  bool
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// Kill stops all video and audio streams for this file
+// Kill stops all video, audio and subtitle streams for this file
 func (sw *StreamWrapper) Kill() {
 	sw.videos.ForEach(func(_ VideoKey, s *VideoStream) {
 		s.Kill()
 	})
-	sw.audios.ForEach(func(_ uint32, s *AudioStream) {
+	sw.audios.ForEach(func(_ AudioKey, s *AudioStream) {
+		s.Kill()
+	})
+	sw.subtitles.ForEach(func(_ uint32, s *SubtitleStream) {
 		s.Kill()
 	})
 }
@@ -84,39 +215,57 @@ func (sw *StreamWrapper) Destroy() {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetMasterPlaylistMulti generates the HLS master playlist with multiple quality options
-//
-// TODO Support multiples audio qualities (and original)
-func (sw *StreamWrapper) GetMasterPlaylistMulti(assetID string) string {
-	master := "#EXTM3U\n"
+// loadCompleteSets switches the open video and audio streams to their complete segment sets,
+// when they exist
+func (sw *StreamWrapper) loadCompleteSets() {
+	sw.videos.ForEach(func(_ VideoKey, s *VideoStream) {
+		s.loadCompleteSet()
+	})
+	sw.audios.ForEach(func(_ AudioKey, s *AudioStream) {
+		s.loadCompleteSet()
+	})
+}
 
-	// Add audio media groups
-	for _, audio := range sw.Info.Audios {
-		master += "#EXT-X-MEDIA:TYPE=AUDIO,"
-		master += "GROUP-ID=\"audio\","
-		if audio.Language != nil {
-			master += fmt.Sprintf("LANGUAGE=\"%s\",", *audio.Language)
-		}
-		if audio.Title != nil {
-			master += fmt.Sprintf("NAME=\"%s\",", *audio.Title)
-		} else if audio.Language != nil {
-			master += fmt.Sprintf("NAME=\"%s\",", *audio.Language)
-		} else {
-			master += fmt.Sprintf("NAME=\"Audio %d\",", audio.Index)
-		}
-		if audio.IsDefault {
-			master += "DEFAULT=YES,"
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// defaultVideo returns the default video track, falling back to the first track
+func (sw *StreamWrapper) defaultVideo() *Video {
+	for i := range sw.Info.Videos {
+		if sw.Info.Videos[i].IsDefault {
+			return &sw.Info.Videos[i]
 		}
-		master += "CHANNELS=\"2\","
-		master += fmt.Sprintf("URI=\"audio/%d/index.m3u8\"\n", audio.Index)
 	}
 
+	if len(sw.Info.Videos) > 0 {
+		return &sw.Info.Videos[0]
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetMasterPlaylistMulti generates the HLS master playlist with multiple quality options, with
+// segments in the given format. The audio track in audioLanguage, when there is one, is the
+// default rendition
+func (sw *StreamWrapper) GetMasterPlaylistMulti(assetID string, format Format, audioLanguage string, subtitles []Subtitle) string {
+	if sw.isAudioOnly() {
+		return sw.getAudioOnlyMasterPlaylist(assetID, format, audioLanguage, subtitles)
+	}
+
+	master := "#EXTM3U\n"
+
+	// Add audio media groups
+	master += sw.audioMediaGroups(sw.audioGroups(), format, audioLanguage)
+
+	// Add subtitle media groups
+	master += sw.subtitleMediaGroups(subtitles)
+
 	master += "\n"
 
 	// codec is the prefix + the level, the level is not part of the codec we want to compare for the same_codec check bellow
 	transcode_prefix := "avc1.6400"
 	transcode_codec := transcode_prefix + "28"
-	audio_codec := "mp4a.40.2"
 
 	var def_video *Video
 	for _, video := range sw.Info.Videos {
@@ -132,37 +281,40 @@ func (sw *StreamWrapper) GetMasterPlaylistMulti(assetID string) string {
 	if def_video != nil {
 		qualities := sw.GetQualities()
 		aspectRatio := float32(def_video.Width) / float32(def_video.Height)
+		groups := sw.audioGroups()
 
-		// Generate stream variants for each quality
+		// Generate stream variants for each quality and audio group
 		for _, quality := range qualities {
-			if quality == Original {
-				// original quality stream
-				bitrate := float64(def_video.Bitrate)
+			for _, group := range groups {
+				if quality == Original {
+					// original quality stream
+					bitrate := float64(def_video.Bitrate)
+					master += "#EXT-X-STREAM-INF:"
+					// For original quality, use the video's actual bitrate
+					master += fmt.Sprintf("AVERAGE-BANDWIDTH=%d,", int(bitrate*0.8))
+					master += fmt.Sprintf("BANDWIDTH=%d,", int(bitrate))
+					master += fmt.Sprintf("RESOLUTION=%dx%d,", def_video.Width, def_video.Height)
+					master += group.variantAttributes(def_video.originalMimeCodec(format, transcode_codec))
+					if sw.hasSubtitles(subtitles) {
+						master += "SUBTITLES=\"subs\","
+					}
+					master += "CLOSED-CAPTIONS=NONE\n"
+					master += fmt.Sprintf("/api/hls/%s/%svideo/%d/%s/index.m3u8\n", assetID, format.pathPrefix(), def_video.Index, quality)
+					continue
+				}
+
+				// transcoded quality streams
 				master += "#EXT-X-STREAM-INF:"
-				// For original quality, use the video's actual bitrate
-				master += fmt.Sprintf("AVERAGE-BANDWIDTH=%d,", int(bitrate*0.8))
-				master += fmt.Sprintf("BANDWIDTH=%d,", int(bitrate))
-				master += fmt.Sprintf("RESOLUTION=%dx%d,", def_video.Width, def_video.Height)
-				if def_video.MimeCodec != nil {
-					master += fmt.Sprintf("CODECS=\"%s\",", strings.Join([]string{*def_video.MimeCodec, audio_codec}, ","))
-				} else {
-					master += fmt.Sprintf("CODECS=\"%s\",", strings.Join([]string{transcode_codec, audio_codec}, ","))
+				master += fmt.Sprintf("AVERAGE-BANDWIDTH=%d,", sw.profile.AverageBitrate(quality))
+				master += fmt.Sprintf("BANDWIDTH=%d,", sw.profile.MaxBitrate(quality))
+				master += fmt.Sprintf("RESOLUTION=%dx%d,", int(aspectRatio*float32(quality.Height())+0.5), quality.Height())
+				master += group.variantAttributes(transcode_codec)
+				if sw.hasSubtitles(subtitles) {
+					master += "SUBTITLES=\"subs\","
 				}
-				master += "AUDIO=\"audio\","
 				master += "CLOSED-CAPTIONS=NONE\n"
-				master += fmt.Sprintf("/api/hls/%s/video/%d/%s/index.m3u8\n", assetID, def_video.Index, quality)
-				continue
+				master += fmt.Sprintf("/api/hls/%s/%svideo/%d/%s/index.m3u8\n", assetID, format.pathPrefix(), def_video.Index, quality)
 			}
-
-			// transcoded quality streams
-			master += "#EXT-X-STREAM-INF:"
-			master += fmt.Sprintf("AVERAGE-BANDWIDTH=%d,", quality.AverageBitrate())
-			master += fmt.Sprintf("BANDWIDTH=%d,", quality.MaxBitrate())
-			master += fmt.Sprintf("RESOLUTION=%dx%d,", int(aspectRatio*float32(quality.Height())+0.5), quality.Height())
-			master += fmt.Sprintf("CODECS=\"%s\",", strings.Join([]string{transcode_codec, audio_codec}, ","))
-			master += "AUDIO=\"audio\","
-			master += "CLOSED-CAPTIONS=NONE\n"
-			master += fmt.Sprintf("/api/hls/%s/video/%d/%s/index.m3u8\n", assetID, def_video.Index, quality)
 		}
 	}
 
@@ -171,99 +323,330 @@ func (sw *StreamWrapper) GetMasterPlaylistMulti(assetID string) string {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetMasterPlaylistSingle returns a simplified master playlist with only one stream
+// GetMasterPlaylistSingle returns a simplified master playlist with only one stream (per audio
+// group), with segments in the given format
 // - Mobile/tablet: Returns the highest available transcoded quality
 // - Desktop: Returns the original quality
-func (sw *StreamWrapper) GetMasterPlaylistSingle(assetID string, isMobile bool) string {
+func (sw *StreamWrapper) GetMasterPlaylistSingle(assetID string, format Format, isMobile bool, audioLanguage string, subtitles []Subtitle) string {
+	if sw.isAudioOnly() {
+		return sw.getAudioOnlyMasterPlaylist(assetID, format, audioLanguage, subtitles)
+	}
+
+	// For mobile, select the highest transcoded quality (not original). For desktop, select
+	// original quality
+	quality := Original
+	if isMobile {
+		quality = GetHighestTranscodedQuality(sw.GetQualities())
+	}
+
+	return sw.singleMasterPlaylist(assetID, format, quality, sw.audioGroups(), audioLanguage, subtitles)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// singleMasterPlaylist returns a master playlist with a single stream of the default video at
+// the given quality, once per audio group
+func (sw *StreamWrapper) singleMasterPlaylist(assetID string, format Format, quality Quality, groups []audioGroup, audioLanguage string, subtitles []Subtitle) string {
 	master := "#EXTM3U\n"
 
 	// Add audio media groups
-	for _, audio := range sw.Info.Audios {
-		master += "#EXT-X-MEDIA:TYPE=AUDIO,"
-		master += "GROUP-ID=\"audio\","
-		if audio.Language != nil {
-			master += fmt.Sprintf("LANGUAGE=\"%s\",", *audio.Language)
-		}
-		if audio.Title != nil {
-			master += fmt.Sprintf("NAME=\"%s\",", *audio.Title)
-		} else if audio.Language != nil {
-			master += fmt.Sprintf("NAME=\"%s\",", *audio.Language)
+	master += sw.audioMediaGroups(groups, format, audioLanguage)
+
+	// Add subtitle media groups
+	master += sw.subtitleMediaGroups(subtitles)
+
+	master += "\n"
+
+	def_video := sw.defaultVideo()
+	if def_video == nil {
+		return master
+	}
+
+	transcode_codec := "avc1.42E01E"
+
+	bitrate := float64(def_video.Bitrate)
+	resolution := fmt.Sprintf("%dx%d", def_video.Width, def_video.Height)
+
+	if quality != Original {
+		bitrate = float64(sw.profile.MaxBitrate(quality))
+		resolution = fmt.Sprintf("%dx%d", int(float32(def_video.Width)*float32(quality.Height())/float32(def_video.Height)+0.5), quality.Height())
+	}
+
+	// Generate the single stream entry for each audio group
+	for _, group := range groups {
+		master += "#EXT-X-STREAM-INF:"
+		master += fmt.Sprintf("AVERAGE-BANDWIDTH=%d,", int(bitrate*0.8))
+		master += fmt.Sprintf("BANDWIDTH=%d,", int(bitrate))
+		master += fmt.Sprintf("RESOLUTION=%s,", resolution)
+
+		if quality == Original {
+			master += group.variantAttributes(def_video.originalMimeCodec(format, transcode_codec))
 		} else {
-			master += fmt.Sprintf("NAME=\"Audio %d\",", audio.Index)
+			master += group.variantAttributes(transcode_codec)
 		}
-		if audio.IsDefault {
-			master += "DEFAULT=YES,"
+
+		if sw.hasSubtitles(subtitles) {
+			master += "SUBTITLES=\"subs\","
 		}
-		master += "CHANNELS=\"2\","
-		master += fmt.Sprintf("URI=\"audio/%d/index.m3u8\"\n", audio.Index)
+		master += "CLOSED-CAPTIONS=NONE\n"
+		master += fmt.Sprintf("/api/hls/%s/%svideo/%d/%s/index.m3u8\n", assetID, format.pathPrefix(), def_video.Index, quality)
 	}
 
+	return master
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// isAudioOnly returns true when the asset has audio but no video, such as an mp3 lecture
+func (sw *StreamWrapper) isAudioOnly() bool {
+	return len(sw.Info.Videos) == 0 && len(sw.Info.Audios) > 0
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// getAudioOnlyMasterPlaylist returns the master playlist for an audio-only asset. The single
+// variant is the audio playlist itself, so there is no audio media group
+func (sw *StreamWrapper) getAudioOnlyMasterPlaylist(assetID string, format Format, audioLanguage string, subtitles []Subtitle) string {
+	master := "#EXTM3U\n"
+
+	// Add subtitle media groups
+	master += sw.subtitleMediaGroups(subtitles)
+
 	master += "\n"
 
-	var def_video *Video
-	for _, video := range sw.Info.Videos {
-		if video.IsDefault {
-			def_video = &video
-			break
+	def_audio := sw.defaultAudio(audioLanguage)
+
+	// Audio is always transcoded to AAC at the bitrate of the profile
+	bitrate := int(sw.profile.AudioBitrate)
+
+	master += "#EXT-X-STREAM-INF:"
+	master += fmt.Sprintf("AVERAGE-BANDWIDTH=%d,", int(float64(bitrate)*0.8))
+	master += fmt.Sprintf("BANDWIDTH=%d,", bitrate)
+	master += fmt.Sprintf("CODECS=\"%s\",", aacCodec)
+	if sw.hasSubtitles(subtitles) {
+		master += "SUBTITLES=\"subs\","
+	}
+	master += "CLOSED-CAPTIONS=NONE\n"
+	master += fmt.Sprintf("/api/hls/%s/%saudio/%d/index.m3u8\n", assetID, format.pathPrefix(), def_audio.Index)
+
+	return master
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// defaultAudio returns the audio track played by default. A track in the preferred language
+// wins, then the default track of the container, then the first track
+func (sw *StreamWrapper) defaultAudio(language string) *Audio {
+	if language != "" {
+		for i := range sw.Info.Audios {
+			audio := &sw.Info.Audios[i]
+			if audio.Language != nil && strings.EqualFold(*audio.Language, language) {
+				return audio
+			}
 		}
 	}
-	if def_video == nil && len(sw.Info.Videos) > 0 {
-		def_video = &sw.Info.Videos[0]
+
+	for i := range sw.Info.Audios {
+		if sw.Info.Audios[i].IsDefault {
+			return &sw.Info.Audios[i]
+		}
 	}
 
-	if def_video == nil {
-		return master
+	if len(sw.Info.Audios) > 0 {
+		return &sw.Info.Audios[0]
 	}
 
-	audio_codec := "mp4a.40.2"
-	transcode_codec := "avc1.42E01E"
+	return nil
+}
 
-	var selectedQuality Quality
-	var selectedBitrate float64
-	var selectedResolution string
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-	if isMobile {
-		// For mobile, select the highest transcoded quality (not original)
-		qualities := sw.GetQualities()
-		selectedQuality = GetHighestTranscodedQuality(qualities)
-		selectedBitrate = float64(selectedQuality.MaxBitrate())
-		selectedResolution = fmt.Sprintf("%dx%d", int(float32(def_video.Width)*float32(selectedQuality.Height())/float32(def_video.Height)+0.5), selectedQuality.Height())
+// audioGroups returns the audio groups offered by the master playlists. The stereo group is
+// always offered. When surround passthrough is enabled and a track can be passed through, a
+// surround group is added with the passthrough renditions and the stereo rendition of the
+// remaining tracks, so every language is available in both
+func (sw *StreamWrapper) audioGroups() []audioGroup {
+	groups := []audioGroup{{id: stereoAudioGroup, codecs: []string{aacCodec}}}
+
+	if !sw.profile.SurroundPassthrough {
+		return groups
+	}
+
+	surround := audioGroup{id: surroundAudioGroup}
+	passthrough := false
+
+	for _, audio := range sw.Info.Audios {
+		codec := aacCodec
+		if audio.canPassthrough() {
+			codec = surroundCodecs[audio.Codec]
+			passthrough = true
+		}
+
+		if !slices.Contains(surround.codecs, codec) {
+			surround.codecs = append(surround.codecs, codec)
+		}
+	}
+
+	if !passthrough {
+		return groups
+	}
+
+	return append(groups, surround)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// audioMediaGroups returns the EXT-X-MEDIA entries for every audio track of each audio group
+func (sw *StreamWrapper) audioMediaGroups(audioGroups []audioGroup, format Format, audioLanguage string) string {
+	def_audio := sw.defaultAudio(audioLanguage)
+	groups := ""
+
+	for _, group := range audioGroups {
+		for _, audio := range sw.Info.Audios {
+			isDefault := def_audio != nil && audio.Index == def_audio.Index
+
+			if (group.id == surroundAudioGroup && audio.canPassthrough()) || (group.id == remuxAudioGroup && group.remuxCodec != "" && audio.Codec == group.remuxCodec) {
+				mode := AudioSurround
+				if group.id == remuxAudioGroup {
+					mode = AudioRemux
+				}
+
+				uri := fmt.Sprintf("%saudio/%d/%s/index.m3u8", format.pathPrefix(), audio.Index, mode)
+				groups += audioMediaGroup(group.id, audio, audio.Channels, uri, isDefault)
+				continue
+			}
+
+			uri := fmt.Sprintf("%saudio/%d/index.m3u8", format.pathPrefix(), audio.Index)
+			groups += audioMediaGroup(group.id, audio, sw.profile.AudioChannels, uri, isDefault)
+		}
+	}
+
+	return groups
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// audioMediaGroup returns a single EXT-X-MEDIA entry for an audio rendition. The name falls back
+// to the language, then to the index of the track
+func audioMediaGroup(group string, audio Audio, channels int, uri string, isDefault bool) string {
+	entry := "#EXT-X-MEDIA:TYPE=AUDIO,"
+	entry += fmt.Sprintf("GROUP-ID=\"%s\",", group)
+	if audio.Language != nil {
+		entry += fmt.Sprintf("LANGUAGE=\"%s\",", *audio.Language)
+	}
+	if audio.Title != nil {
+		entry += fmt.Sprintf("NAME=\"%s\",", *audio.Title)
+	} else if audio.Language != nil {
+		entry += fmt.Sprintf("NAME=\"%s\",", *audio.Language)
 	} else {
-		// For desktop, select original quality
-		selectedQuality = Original
-		selectedBitrate = float64(def_video.Bitrate)
-		selectedResolution = fmt.Sprintf("%dx%d", def_video.Width, def_video.Height)
+		entry += fmt.Sprintf("NAME=\"Audio %d\",", audio.Index)
+	}
+	if isDefault {
+		entry += "DEFAULT=YES,"
 	}
+	entry += "AUTOSELECT=YES,"
+	entry += fmt.Sprintf("CHANNELS=\"%d\",", channels)
+	entry += fmt.Sprintf("URI=\"%s\"\n", uri)
 
-	// Generate the single stream entry
-	master += "#EXT-X-STREAM-INF:"
-	master += fmt.Sprintf("AVERAGE-BANDWIDTH=%d,", int(selectedBitrate*0.8))
-	master += fmt.Sprintf("BANDWIDTH=%d,", int(selectedBitrate))
-	master += fmt.Sprintf("RESOLUTION=%s,", selectedResolution)
+	return entry
+}
 
-	if selectedQuality == Original {
-		if def_video.MimeCodec != nil {
-			master += fmt.Sprintf("CODECS=\"%s\",", strings.Join([]string{*def_video.MimeCodec, audio_codec}, ","))
-		} else {
-			master += fmt.Sprintf("CODECS=\"%s\",", strings.Join([]string{transcode_codec, audio_codec}, ","))
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// hasSubtitles returns true when the asset has embedded subtitle streams or subtitle files
+func (sw *StreamWrapper) hasSubtitles(subtitles []Subtitle) bool {
+	return len(sw.Info.Subtitles) > 0 || len(subtitles) > 0
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// subtitleMediaGroups returns the EXT-X-MEDIA entries for the embedded subtitle streams,
+// followed by the subtitle files paired with the asset
+func (sw *StreamWrapper) subtitleMediaGroups(subtitles []Subtitle) string {
+	groups := ""
+	count := 0
+
+	for _, subtitle := range sw.Info.Subtitles {
+		count++
+
+		language := ""
+		if subtitle.Language != nil {
+			language = *subtitle.Language
+		}
+
+		name := ""
+		if subtitle.Title != nil {
+			name = *subtitle.Title
 		}
+
+		uri := fmt.Sprintf("subtitles/embedded/%d/index.m3u8", subtitle.Index)
+		groups += subtitleMediaGroup(count, language, name, uri, subtitle.IsDefault, subtitle.IsForced)
+	}
+
+	for _, subtitle := range subtitles {
+		count++
+
+		uri := fmt.Sprintf("subtitles/%s/index.m3u8", subtitle.ID)
+		groups += subtitleMediaGroup(count, subtitle.Language, subtitle.Name, uri, subtitle.Default, false)
+	}
+
+	return groups
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// subtitleMediaGroup returns a single EXT-X-MEDIA entry for a subtitle rendition. The name
+// falls back to the language, then to the position of the rendition
+func subtitleMediaGroup(position int, language, name, uri string, isDefault, isForced bool) string {
+	group := "#EXT-X-MEDIA:TYPE=SUBTITLES,"
+	group += "GROUP-ID=\"subs\","
+	if language != "" {
+		group += fmt.Sprintf("LANGUAGE=\"%s\",", language)
+	}
+	if name != "" {
+		group += fmt.Sprintf("NAME=\"%s\",", name)
+	} else if language != "" {
+		group += fmt.Sprintf("NAME=\"%s\",", language)
 	} else {
-		master += fmt.Sprintf("CODECS=\"%s\",", strings.Join([]string{transcode_codec, audio_codec}, ","))
+		group += fmt.Sprintf("NAME=\"Subtitle %d\",", position)
+	}
+	if isDefault {
+		group += "DEFAULT=YES,"
 	}
+	if isForced {
+		group += "FORCED=YES,"
+	}
+	group += "AUTOSELECT=YES,"
+	group += fmt.Sprintf("URI=\"%s\"\n", uri)
 
-	master += "AUDIO=\"audio\","
-	master += "CLOSED-CAPTIONS=NONE\n"
-	master += fmt.Sprintf("/api/hls/%s/video/%d/%s/index.m3u8\n", assetID, def_video.Index, selectedQuality)
+	return group
+}
 
-	return master
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetSubtitleIndex returns the playlist for a subtitle rendition. The whole WebVTT file is
+// served as a single segment spanning the duration of the media
+func (sw *StreamWrapper) GetSubtitleIndex() string {
+	duration := sw.Info.Duration
+
+	index := "#EXTM3U\n"
+	index += "#EXT-X-VERSION:3\n"
+	index += "#EXT-X-PLAYLIST-TYPE:VOD\n"
+	index += fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(duration)))
+	index += "#EXT-X-MEDIA-SEQUENCE:0\n"
+	index += fmt.Sprintf("#EXTINF:%.6f,\n", duration)
+	index += "subtitles.vtt\n"
+	index += "#EXT-X-ENDLIST\n"
+
+	return index
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// getVideoStream returns a video stream for the given index and quality
-func (sw *StreamWrapper) getVideoStream(idx uint32, quality Quality) (*VideoStream, error) {
-	stream, _ := sw.videos.GetOrCreate(VideoKey{idx, quality}, func() *VideoStream {
-		ret, _ := NewVideoStream(sw, idx, quality)
+// getVideoStream returns a video stream for the given index, quality and format
+func (sw *StreamWrapper) getVideoStream(idx uint32, quality Quality, format Format) (*VideoStream, error) {
+	stream, _ := sw.videos.GetOrCreate(VideoKey{idx, quality, format}, func() *VideoStream {
+		ret, _ := NewVideoStream(sw, idx, quality, format)
 		return ret
 	})
 
@@ -273,8 +656,8 @@ func (sw *StreamWrapper) getVideoStream(idx uint32, quality Quality) (*VideoStre
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // GetVideoIndex returns the video index playlist for a given variant
-func (sw *StreamWrapper) GetVideoIndex(idx uint32, quality Quality) (string, error) {
-	stream, err := sw.getVideoStream(idx, quality)
+func (sw *StreamWrapper) GetVideoIndex(idx uint32, quality Quality, format Format) (string, error) {
+	stream, err := sw.getVideoStream(idx, quality, format)
 	if err != nil {
 		return "", err
 	}
@@ -284,22 +667,46 @@ func (sw *StreamWrapper) GetVideoIndex(idx uint32, quality Quality) (string, err
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetVideoSegment returns a video segment path, transcoding if necessary
-func (sw *StreamWrapper) GetVideoSegment(idx uint32, quality Quality, segment int32) (string, error) {
-	stream, err := sw.getVideoStream(idx, quality)
+// GetVideoSegment returns a video segment path, transcoding for the user if necessary
+func (sw *StreamWrapper) GetVideoSegment(userID string, idx uint32, quality Quality, format Format, segment int32) (string, error) {
+	stream, err := sw.getVideoStream(idx, quality, format)
 	if err != nil {
 		return "", err
 	}
 
-	return stream.GetSegment(segment)
+	return stream.GetSegment(userID, segment)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetVideoInit returns the init segment path of a fMP4 video variant, transcoding for the user
+// if necessary
+func (sw *StreamWrapper) GetVideoInit(userID string, idx uint32, quality Quality) (string, error) {
+	stream, err := sw.getVideoStream(idx, quality, FormatFMP4)
+	if err != nil {
+		return "", err
+	}
+
+	return stream.GetInit(userID)
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// getAudioStream returns an audio stream for the given audio index
-func (sw *StreamWrapper) getAudioStream(audio uint32) (*AudioStream, error) {
-	stream, _ := sw.audios.GetOrCreate(audio, func() *AudioStream {
-		ret, _ := NewAudioStream(sw, audio)
+// getAudioStream returns an audio stream for the given audio index, mode and format. Surround
+// streams are only available for tracks that can be passed through and remux streams for tracks
+// that can be copied
+func (sw *StreamWrapper) getAudioStream(audio uint32, mode AudioMode, format Format) (*AudioStream, error) {
+	if mode != AudioStereo {
+		idx := slices.IndexFunc(sw.Info.Audios, func(a Audio) bool { return a.Index == audio })
+		if idx == -1 ||
+			(mode == AudioSurround && !sw.Info.Audios[idx].canPassthrough()) ||
+			(mode == AudioRemux && !sw.Info.Audios[idx].canRemux()) {
+			return nil, fmt.Errorf("audio %d has no %s stream", audio, mode)
+		}
+	}
+
+	stream, _ := sw.audios.GetOrCreate(AudioKey{audio, mode, format}, func() *AudioStream {
+		ret, _ := NewAudioStream(sw, audio, mode, format)
 		return ret
 	})
 
@@ -308,9 +715,9 @@ func (sw *StreamWrapper) getAudioStream(audio uint32) (*AudioStream, error) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetAudioIndex returns the audio index playlist for the given audio index
-func (sw *StreamWrapper) GetAudioIndex(audio uint32) (string, error) {
-	stream, err := sw.getAudioStream(audio)
+// GetAudioIndex returns the audio index playlist for the given audio index and mode
+func (sw *StreamWrapper) GetAudioIndex(audio uint32, mode AudioMode, format Format) (string, error) {
+	stream, err := sw.getAudioStream(audio, mode, format)
 	if err != nil {
 		return "", err
 	}
@@ -320,9 +727,70 @@ func (sw *StreamWrapper) GetAudioIndex(audio uint32) (string, error) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetAudioSegment returns an audio segment path, transcoding if necessary
-func (sw *StreamWrapper) GetAudioSegment(audio uint32, segment int32) (string, error) {
-	stream, err := sw.getAudioStream(audio)
+// GetAudioSegment returns an audio segment path, transcoding for the user if necessary
+func (sw *StreamWrapper) GetAudioSegment(userID string, audio uint32, mode AudioMode, format Format, segment int32) (string, error) {
+	stream, err := sw.getAudioStream(audio, mode, format)
+	if err != nil {
+		return "", err
+	}
+
+	return stream.GetSegment(userID, segment)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetAudioInit returns the init segment path of a fMP4 audio stream, transcoding for the user if
+// necessary
+func (sw *StreamWrapper) GetAudioInit(userID string, audio uint32, mode AudioMode) (string, error) {
+	stream, err := sw.getAudioStream(audio, mode, FormatFMP4)
+	if err != nil {
+		return "", err
+	}
+
+	return stream.GetInit(userID)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// getSubtitleStream returns a subtitle stream for the given embedded subtitle index
+func (sw *StreamWrapper) getSubtitleStream(index uint32) (*SubtitleStream, error) {
+	found := false
+	for _, subtitle := range sw.Info.Subtitles {
+		if subtitle.Index == index {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("embedded subtitle %d not found", index)
+	}
+
+	stream, _ := sw.subtitles.GetOrCreate(index, func() *SubtitleStream {
+		return NewSubtitleStream(sw, index)
+	})
+
+	return stream, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetEmbeddedSubtitleIndex returns the index playlist for an embedded subtitle stream
+func (sw *StreamWrapper) GetEmbeddedSubtitleIndex(index uint32) (string, error) {
+	stream, err := sw.getSubtitleStream(index)
+	if err != nil {
+		return "", err
+	}
+
+	return stream.GetIndex(), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetEmbeddedSubtitleSegment returns an embedded subtitle segment path, extracting the stream
+// if necessary
+func (sw *StreamWrapper) GetEmbeddedSubtitleSegment(index uint32, segment int32) (string, error) {
+	stream, err := sw.getSubtitleStream(index)
 	if err != nil {
 		return "", err
 	}
@@ -353,9 +821,10 @@ func (sw *StreamWrapper) GetQualities() []Quality {
 
 	qualities = append(qualities, Original)
 
-	// Add qualities from highest to lowest
-	for i := len(Qualities) - 1; i >= 0; i-- {
-		q := Qualities[i]
+	// Add the qualities enabled in the profile from highest to lowest
+	profileQualities := sw.profile.Qualities()
+	for i := len(profileQualities) - 1; i >= 0; i-- {
+		q := profileQualities[i]
 		if q.Height() <= def_video.Height {
 			qualities = append(qualities, q)
 		}
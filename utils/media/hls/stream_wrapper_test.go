@@ -0,0 +1,254 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/geerew/off-course/models"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStreamWrapper_MasterPlaylistSubtitles(t *testing.T) {
+	language := "en"
+	sw := &StreamWrapper{
+		profile: DefaultProfile(),
+		Info: &MediaInfo{
+			Duration: 90.5,
+			Videos:   []Video{{Index: 0, Width: 1920, Height: 1080, Bitrate: 5000000, IsDefault: true}},
+			Audios:   []Audio{{Index: 0, Language: &language, IsDefault: true}},
+		},
+	}
+
+	t.Run("none", func(t *testing.T) {
+		master := sw.GetMasterPlaylistSingle("asset", FormatTS, false, "", nil)
+		require.NotContains(t, master, "TYPE=SUBTITLES")
+		require.NotContains(t, master, "SUBTITLES=\"subs\"")
+	})
+
+	t.Run("renditions", func(t *testing.T) {
+		master := sw.GetMasterPlaylistSingle("asset", FormatTS, false, "", []Subtitle{
+			{ID: "sub1", Language: "en"},
+			{ID: "sub2", Language: "fr", Name: "Français", Default: true},
+			{ID: "sub3"},
+		})
+
+		require.Contains(t, master, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",LANGUAGE=\"en\",NAME=\"en\",AUTOSELECT=YES,URI=\"subtitles/sub1/index.m3u8\"\n")
+		require.Contains(t, master, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",LANGUAGE=\"fr\",NAME=\"Français\",DEFAULT=YES,AUTOSELECT=YES,URI=\"subtitles/sub2/index.m3u8\"\n")
+		require.Contains(t, master, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=\"Subtitle 3\",AUTOSELECT=YES,URI=\"subtitles/sub3/index.m3u8\"\n")
+
+		for _, line := range strings.Split(master, "\n") {
+			if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+				require.Contains(t, line, "SUBTITLES=\"subs\"")
+			}
+		}
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStreamWrapper_MasterPlaylistEmbeddedSubtitles(t *testing.T) {
+	language := "eng"
+	title := "English (SDH)"
+
+	sw := &StreamWrapper{
+		profile: DefaultProfile(),
+		Info: &MediaInfo{
+			Duration: 90.5,
+			Videos:   []Video{{Index: 0, Width: 1920, Height: 1080, Bitrate: 5000000, IsDefault: true}},
+			Subtitles: []EmbeddedSubtitle{
+				{Index: 2, Language: &language, Title: &title, Codec: "mov_text", IsDefault: true},
+				{Index: 3, Codec: "subrip", IsForced: true},
+			},
+		},
+	}
+
+	master := sw.GetMasterPlaylistSingle("asset", FormatTS, false, "", []Subtitle{{ID: "sub1", Language: "fr"}})
+
+	require.Contains(t, master, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",LANGUAGE=\"eng\",NAME=\"English (SDH)\",DEFAULT=YES,AUTOSELECT=YES,URI=\"subtitles/embedded/2/index.m3u8\"\n")
+	require.Contains(t, master, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=\"Subtitle 2\",FORCED=YES,AUTOSELECT=YES,URI=\"subtitles/embedded/3/index.m3u8\"\n")
+	require.Contains(t, master, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",LANGUAGE=\"fr\",NAME=\"fr\",AUTOSELECT=YES,URI=\"subtitles/sub1/index.m3u8\"\n")
+
+	// Embedded streams alone enable the subtitle group
+	master = sw.GetMasterPlaylistSingle("asset", FormatTS, false, "", nil)
+	require.Contains(t, master, "SUBTITLES=\"subs\"")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStreamWrapper_MasterPlaylistAudio(t *testing.T) {
+	english, french := "eng", "fre"
+	commentary := "Commentary"
+
+	newStreamWrapper := func(profile *Profile) *StreamWrapper {
+		return &StreamWrapper{
+			profile: profile,
+			Info: &MediaInfo{
+				Duration: 90.5,
+				Videos:   []Video{{Index: 0, Width: 1920, Height: 1080, Bitrate: 5000000, IsDefault: true}},
+				Audios: []Audio{
+					{Index: 0, Language: &english, Codec: "aac", Channels: 2},
+					{Index: 1, Language: &french, Codec: "eac3", Channels: 6, IsDefault: true},
+					{Index: 2, Language: &english, Title: &commentary, Codec: "ac3", Channels: 2},
+				},
+			},
+		}
+	}
+
+	t.Run("tracks", func(t *testing.T) {
+		master := newStreamWrapper(DefaultProfile()).GetMasterPlaylistSingle("asset", FormatTS, false, "", nil)
+
+		require.Contains(t, master, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",LANGUAGE=\"eng\",NAME=\"eng\",AUTOSELECT=YES,CHANNELS=\"2\",URI=\"audio/0/index.m3u8\"\n")
+		require.Contains(t, master, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",LANGUAGE=\"fre\",NAME=\"fre\",DEFAULT=YES,AUTOSELECT=YES,CHANNELS=\"2\",URI=\"audio/1/index.m3u8\"\n")
+		require.Contains(t, master, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",LANGUAGE=\"eng\",NAME=\"Commentary\",AUTOSELECT=YES,CHANNELS=\"2\",URI=\"audio/2/index.m3u8\"\n")
+
+		require.NotContains(t, master, "audio-surround")
+		require.Equal(t, 1, strings.Count(master, "#EXT-X-STREAM-INF:"))
+		require.Equal(t, 1, strings.Count(master, "DEFAULT=YES"))
+	})
+
+	t.Run("preferred language", func(t *testing.T) {
+		master := newStreamWrapper(DefaultProfile()).GetMasterPlaylistSingle("asset", FormatTS, false, "ENG", nil)
+		require.Contains(t, master, "NAME=\"eng\",DEFAULT=YES,")
+		require.Equal(t, 1, strings.Count(master, "DEFAULT=YES"))
+
+		// Unknown languages fall back to the default track
+		master = newStreamWrapper(DefaultProfile()).GetMasterPlaylistSingle("asset", FormatTS, false, "ger", nil)
+		require.Contains(t, master, "NAME=\"fre\",DEFAULT=YES,")
+	})
+
+	t.Run("surround passthrough", func(t *testing.T) {
+		profile := DefaultProfile()
+		profile.SurroundPassthrough = true
+		sw := newStreamWrapper(profile)
+
+		master := sw.GetMasterPlaylistSingle("asset", FormatTS, false, "", nil)
+
+		// Only the E-AC-3 track has enough channels to be passed through
+		require.Contains(t, master, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio-surround\",LANGUAGE=\"fre\",NAME=\"fre\",DEFAULT=YES,AUTOSELECT=YES,CHANNELS=\"6\",URI=\"audio/1/surround/index.m3u8\"\n")
+		require.Contains(t, master, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio-surround\",LANGUAGE=\"eng\",NAME=\"Commentary\",AUTOSELECT=YES,CHANNELS=\"2\",URI=\"audio/2/index.m3u8\"\n")
+		require.Equal(t, 6, strings.Count(master, "TYPE=AUDIO"))
+
+		require.Contains(t, master, "CODECS=\"avc1.42E01E,mp4a.40.2\",AUDIO=\"audio\",")
+		require.Contains(t, master, "CODECS=\"avc1.42E01E,mp4a.40.2,ec-3\",AUDIO=\"audio-surround\",")
+
+		// Every quality is offered with both groups
+		multi := sw.GetMasterPlaylistMulti("asset", FormatTS, "", nil)
+		require.Equal(t, 2*len(sw.GetQualities()), strings.Count(multi, "#EXT-X-STREAM-INF:"))
+
+		// Without a surround track, there is no surround group
+		sw.Info.Audios = sw.Info.Audios[:1]
+		require.NotContains(t, sw.GetMasterPlaylistSingle("asset", FormatTS, false, "", nil), "audio-surround")
+	})
+
+	t.Run("surround stream", func(t *testing.T) {
+		sw := newStreamWrapper(DefaultProfile())
+
+		_, err := sw.getAudioStream(0, AudioSurround, FormatTS)
+		require.ErrorContains(t, err, "no surround stream")
+
+		_, err = sw.getAudioStream(3, AudioSurround, FormatTS)
+		require.Error(t, err)
+
+		as := &AudioStream{Stream: Stream{streamWrapper: sw}, index: 1, mode: AudioSurround}
+		require.Equal(t, "audio-1-surround", as.getName())
+		require.Equal(t, []string{"-map", "0:a:1", "-c:a", "copy"}, as.getTranscodeArgs(""))
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestTranscoder_AudioTracks(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	p, ctx := setupPretranscoder(t, fs)
+
+	t.Run("tracks", func(t *testing.T) {
+		asset := createPretranscodeAsset(t, p, ctx, "/course/tracks.mp4", []float64{0, 4, 8})
+		require.NoError(t, p.dao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID: asset.ID,
+			AudioTrackMetadata: []*models.AudioTrackMetadata{
+				{StreamIndex: 1, TrackIndex: 0, Language: "und", Codec: "aac", Channels: 2},
+				{StreamIndex: 2, TrackIndex: 1, Language: "fre", Title: "Français", Codec: "eac3", Channels: 6, IsDefault: true},
+			},
+		}))
+
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+		require.Len(t, sw.Info.Audios, 2)
+
+		require.Nil(t, sw.Info.Audios[0].Language)
+		require.Equal(t, uint32(1), sw.Info.Audios[1].Index)
+		require.Equal(t, "Français", *sw.Info.Audios[1].Title)
+		require.True(t, sw.Info.Audios[1].canPassthrough())
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		asset := createPretranscodeAsset(t, p, ctx, "/course/fallback.mp4", []float64{0, 4, 8})
+		require.NoError(t, p.dao.DeleteAssetMetadataByAssetIDs(ctx, asset.ID))
+		require.NoError(t, p.dao.CreateAssetMetadata(ctx, &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: 10, Width: 1920, Height: 1080},
+			AudioMetadata: &models.AudioMetadata{Codec: "ac3", Channels: 6},
+		}))
+
+		sw, err := p.transcoder.getStreamWrapper(ctx, asset.Path, asset.ID)
+		require.NoError(t, err)
+		require.Len(t, sw.Info.Audios, 1)
+		require.Equal(t, 6, sw.Info.Audios[0].Channels)
+		require.True(t, sw.Info.Audios[0].IsDefault)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStreamWrapper_GetSubtitleIndex(t *testing.T) {
+	sw := &StreamWrapper{profile: DefaultProfile(), Info: &MediaInfo{Duration: 90.5}}
+
+	index := sw.GetSubtitleIndex()
+	require.Contains(t, index, "#EXT-X-TARGETDURATION:91\n")
+	require.Contains(t, index, "#EXTINF:90.500000,\nsubtitles.vtt\n")
+	require.True(t, strings.HasSuffix(index, "#EXT-X-ENDLIST\n"))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestStreamWrapper_AudioOnly(t *testing.T) {
+	sw := &StreamWrapper{
+		profile: DefaultProfile(),
+		Info: &MediaInfo{
+			Duration: 14.5,
+			Audios:   []Audio{{Index: 0, Codec: "mp3", IsDefault: true}},
+		},
+	}
+
+	t.Run("master", func(t *testing.T) {
+		for _, master := range []string{
+			sw.GetMasterPlaylistSingle("asset", FormatTS, true, "", nil),
+			sw.GetMasterPlaylistMulti("asset", FormatTS, "", nil),
+		} {
+			require.NotContains(t, master, "TYPE=AUDIO")
+			require.NotContains(t, master, "/video/")
+			require.Contains(t, master, "#EXT-X-STREAM-INF:AVERAGE-BANDWIDTH=102400,BANDWIDTH=128000,CODECS=\"mp4a.40.2\",CLOSED-CAPTIONS=NONE\n/api/hls/asset/audio/0/index.m3u8\n")
+		}
+	})
+
+	t.Run("master with subtitles", func(t *testing.T) {
+		master := sw.GetMasterPlaylistSingle("asset", FormatTS, false, "", []Subtitle{{ID: "sub1", Language: "en"}})
+		require.Contains(t, master, "URI=\"subtitles/sub1/index.m3u8\"")
+		require.Contains(t, master, "SUBTITLES=\"subs\",")
+	})
+
+	t.Run("keyframes", func(t *testing.T) {
+		require.Equal(t, []float64{0, 6, 12}, getKeyframes(sw))
+	})
+
+	t.Run("index", func(t *testing.T) {
+		stream := &Stream{streamWrapper: sw, keyframes: getKeyframes(sw)}
+
+		index, err := stream.GetIndex()
+		require.NoError(t, err)
+		require.Contains(t, index, "#EXTINF:6.000000\nsegment-0.ts\n#EXTINF:6.000000\nsegment-1.ts\n#EXTINF:2.500000\nsegment-2.ts\n")
+	})
+}
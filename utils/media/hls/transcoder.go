@@ -2,8 +2,13 @@ package hls
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/geerew/off-course/dao"
@@ -11,6 +16,7 @@ import (
 	"github.com/geerew/off-course/utils"
 	"github.com/geerew/off-course/utils/appfs"
 	"github.com/geerew/off-course/utils/logger"
+	"github.com/geerew/off-course/utils/types"
 	"github.com/spf13/afero"
 )
 
@@ -23,6 +29,17 @@ type Transcoder struct {
 	streams   utils.CMap[string, *StreamWrapper]
 	assetChan chan string
 	tracker   *Tracker
+	cache     *Cache
+	limiter   *headLimiter
+	profile   atomic.Pointer[Profile]
+
+	// measuring holds the assets whose loudness is being measured, and measureSlots limits how
+	// many are measured at once. measureFailed holds when assets whose measurement failed may be
+	// measured again
+	measuringMu   sync.Mutex
+	measuring     map[string]bool
+	measureFailed map[string]time.Time
+	measureSlots  chan struct{}
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -34,6 +51,13 @@ type TranscoderConfig struct {
 	AppFs     *appfs.AppFs
 	Logger    *logger.Logger
 	Dao       *dao.DAO
+
+	// CacheMaxSize is the max size in bytes of the cache. 0 is unlimited
+	CacheMaxSize int64
+
+	// CacheMaxAge is how long an on-demand segment directory is kept after it was last
+	// accessed. 0 is unlimited
+	CacheMaxAge time.Duration
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -57,35 +81,116 @@ func NewTranscoder(config *TranscoderConfig) (*Transcoder, error) {
 
 	config.AppFs.Fs.MkdirAll(cachePath, 0o755)
 
-	// Empty the cache directory
-	err := config.AppFs.RemoveAllContents(cachePath)
+	// Empty the cache directory, keeping complete segment sets
+	items, err := config.AppFs.PathItems(cachePath)
 	if err != nil {
 		return nil, err
 	}
 
+	for _, item := range items {
+		if item == completeDirName {
+			continue
+		}
+
+		if err := config.AppFs.Fs.RemoveAll(filepath.Join(cachePath, item)); err != nil {
+			return nil, err
+		}
+	}
+
 	transcoder := &Transcoder{
-		config:    config,
-		cachePath: cachePath,
-		streams:   utils.NewCMap[string, *StreamWrapper](),
-		assetChan: make(chan string, 10),
+		config:        config,
+		cachePath:     cachePath,
+		streams:       utils.NewCMap[string, *StreamWrapper](),
+		assetChan:     make(chan string, 10),
+		limiter:       newHeadLimiter(),
+		measuring:     make(map[string]bool),
+		measureFailed: make(map[string]time.Time),
+		measureSlots:  make(chan struct{}, maxLoudnessMeasurements),
 	}
 
 	// Start tracker
 	transcoder.tracker = NewTracker(transcoder)
 
+	transcoder.cache = newCache(transcoder)
+
+	profile, err := loadProfile(context.Background(), config.Dao)
+	if err != nil {
+		config.Logger.Warn().Err(err).Msg("Failed to load the transcoding profile, using the default")
+		profile = DefaultProfile()
+	}
+
+	transcoder.profile.Store(profile)
+
 	return transcoder, nil
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// Cache returns the cache manager of the transcoder
+func (t *Transcoder) Cache() *Cache {
+	return t.cache
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Profile returns a copy of the transcoding profile
+func (t *Transcoder) Profile() *Profile {
+	return t.profile.Load().Clone()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// SetProfile validates and saves the transcoding profile. Open streams are destroyed so the next
+// request for an asset uses the new profile
+func (t *Transcoder) SetProfile(ctx context.Context, profile *Profile) error {
+	profile = profile.Clone()
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
+	if err := saveProfile(ctx, t.config.Dao, profile); err != nil {
+		return err
+	}
+
+	t.profile.Store(profile)
+
+	for _, assetID := range t.streams.Keys() {
+		if sw, ok := t.streams.GetAndRemove(assetID); ok {
+			sw.Destroy()
+		}
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// principalUserID returns the ID of the user of the principal in the context, or an empty
+// string when there is no principal
+func principalUserID(ctx context.Context) string {
+	principal, ok := ctx.Value(types.PrincipalContextKey).(types.Principal)
+	if !ok {
+		return ""
+	}
+
+	return principal.UserID
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // newStreamWrapper creates a new StreamWrapper and fetches metadata from the database
 func (t *Transcoder) newStreamWrapper(ctx context.Context, path string, assetID string) *StreamWrapper {
 	streamWrapper := &StreamWrapper{
-		config:  t.config,
-		Out:     filepath.Join(t.cachePath, assetID),
-		videos:  utils.NewCMap[VideoKey, *VideoStream](),
-		audios:  utils.NewCMap[uint32, *AudioStream](),
-		assetID: assetID,
+		config:    t.config,
+		cache:     t.cache,
+		limiter:   t.limiter,
+		profile:   t.profile.Load(),
+		Out:       filepath.Join(t.cachePath, assetID),
+		Complete:  filepath.Join(t.cachePath, completeDirName, assetID),
+		videos:    utils.NewCMap[VideoKey, *VideoStream](),
+		audios:    utils.NewCMap[AudioKey, *AudioStream](),
+		subtitles: utils.NewCMap[uint32, *SubtitleStream](),
+		assetID:   assetID,
 	}
 
 	// Get asset with metadata from database
@@ -106,6 +211,9 @@ func (t *Transcoder) newStreamWrapper(ctx context.Context, path string, assetID
 	var videos []Video
 	var audios []Audio
 
+	// The measured loudness of each audio track, by track index
+	loudness := map[uint32]sql.NullFloat64{}
+
 	// Video metadata
 	if asset.AssetMetadata != nil && asset.AssetMetadata.VideoMetadata != nil {
 		videoMeta := asset.AssetMetadata.VideoMetadata
@@ -123,8 +231,39 @@ func (t *Transcoder) newStreamWrapper(ctx context.Context, path string, assetID
 		videos = append(videos, video)
 	}
 
-	// Audio metadata
-	if asset.AssetMetadata != nil && asset.AssetMetadata.AudioMetadata != nil {
+	// Audio tracks
+	trackMeta, err := t.config.Dao.ListAudioTrackMetadata(ctx, dao.NewOptions().
+		WithWhere(squirrel.Eq{models.MEDIA_AUDIO_TRACK_TABLE_ASSET_ID: assetID}).
+		WithOrderBy(models.MEDIA_AUDIO_TRACK_TABLE_TRACK_INDEX+" asc"))
+	if err != nil {
+		t.config.Logger.Error().
+			Err(err).
+			Str("asset_id", assetID).
+			Str("path", path).
+			Msg("Failed to get audio track metadata")
+		streamWrapper.err = err
+		return streamWrapper
+	}
+
+	for _, meta := range trackMeta {
+		audio := Audio{
+			Index:     uint32(meta.TrackIndex),
+			Codec:     meta.Codec,
+			Channels:  meta.Channels,
+			IsDefault: meta.IsDefault,
+		}
+		if meta.Title != "" {
+			audio.Title = &meta.Title
+		}
+		if meta.Language != "" && meta.Language != "und" {
+			audio.Language = &meta.Language
+		}
+		audios = append(audios, audio)
+		loudness[audio.Index] = meta.LoudnessLUFS
+	}
+
+	// Audio metadata, for audio assets and videos scanned before audio tracks were recorded
+	if len(audios) == 0 && asset.AssetMetadata != nil && asset.AssetMetadata.AudioMetadata != nil {
 		audioMeta := asset.AssetMetadata.AudioMetadata
 		audio := Audio{
 			Index:     0,
@@ -133,30 +272,115 @@ func (t *Transcoder) newStreamWrapper(ctx context.Context, path string, assetID
 			Codec:     audioMeta.Codec,
 			MimeCodec: nil,
 			Bitrate:   uint32(audioMeta.BitRate),
+			Channels:  audioMeta.Channels,
 			IsDefault: true,
 		}
 		audios = append(audios, audio)
+		loudness[audio.Index] = audioMeta.LoudnessLUFS
 	}
 
-	// Duration
-	duration := 0.0
-	if asset.AssetMetadata != nil && asset.AssetMetadata.VideoMetadata != nil {
-		duration = float64(asset.AssetMetadata.VideoMetadata.DurationSec)
+	// Embedded subtitle streams
+	subtitleMeta, err := t.config.Dao.ListSubtitleMetadata(ctx, dao.NewOptions().
+		WithWhere(squirrel.Eq{models.MEDIA_SUBTITLE_TABLE_ASSET_ID: assetID}).
+		WithOrderBy(models.MEDIA_SUBTITLE_TABLE_STREAM_INDEX+" asc"))
+	if err != nil {
+		t.config.Logger.Error().
+			Err(err).
+			Str("asset_id", assetID).
+			Str("path", path).
+			Msg("Failed to get subtitle metadata")
+		streamWrapper.err = err
+		return streamWrapper
+	}
+
+	var subtitles []EmbeddedSubtitle
+	for _, meta := range subtitleMeta {
+		subtitle := EmbeddedSubtitle{
+			Index:     uint32(meta.StreamIndex),
+			Codec:     meta.Codec,
+			IsDefault: meta.IsDefault,
+			IsForced:  meta.IsForced,
+		}
+		if meta.Title != "" {
+			subtitle.Title = &meta.Title
+		}
+		if meta.Language != "" && meta.Language != "und" {
+			subtitle.Language = &meta.Language
+		}
+		subtitles = append(subtitles, subtitle)
+	}
+
+	// Duration (video or audio)
+	duration := float64(asset.AssetMetadata.DurationSec())
+
+	// Archive members are extracted, as ffmpeg needs a real file to seek within
+	localPath, err := t.config.AppFs.LocalPath(path)
+	if err != nil {
+		t.config.Logger.Error().
+			Err(err).
+			Str("asset_id", assetID).
+			Str("path", path).
+			Msg("Failed to extract asset from archive")
+		streamWrapper.err = err
+		return streamWrapper
 	}
 
 	info := &MediaInfo{
-		Path:     path,
-		Duration: duration,
-		Videos:   videos,
-		Audios:   audios,
+		Path:      localPath,
+		Source:    path,
+		Duration:  duration,
+		Videos:    videos,
+		Audios:    audios,
+		Subtitles: subtitles,
 	}
 	streamWrapper.Info = info
 
+	// Loudness normalization. Tracks are measured the first time the asset is played, and are
+	// normalized once their loudness is known
+	if len(audios) > 0 && t.normalizeAudio(ctx, streamWrapper.profile, asset.CourseID) {
+		target := streamWrapper.profile.loudnessTarget()
+		unmeasured := []uint32{}
+
+		for i := range info.Audios {
+			if lufs := loudness[info.Audios[i].Index]; lufs.Valid {
+				info.Audios[i].LoudnessGain = loudnessGain(target, lufs.Float64)
+			} else {
+				unmeasured = append(unmeasured, info.Audios[i].Index)
+			}
+		}
+
+		if len(unmeasured) > 0 {
+			t.measureAssetLoudness(assetID, path, unmeasured, len(trackMeta) > 0)
+		}
+	}
+
 	return streamWrapper
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// normalizeAudio returns whether loudness normalization is enabled for a course, either in the
+// profile or on the course itself
+func (t *Transcoder) normalizeAudio(ctx context.Context, profile *Profile, courseID string) bool {
+	if profile.NormalizeLoudness {
+		return true
+	}
+
+	course, err := t.config.Dao.GetCourse(ctx, dao.NewOptions().
+		WithWhere(squirrel.Eq{models.COURSE_TABLE_ID: courseID}))
+	if err != nil {
+		t.config.Logger.Warn().
+			Err(err).
+			Str("course_id", courseID).
+			Msg("Failed to get course for loudness normalization")
+		return false
+	}
+
+	return course != nil && course.NormalizeAudio
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // getStreamWrapper returns an existing StreamWrapper for the asset or creates one
 //
 // It blocks until the StreamWrapper is ready or returns an error
@@ -176,27 +400,107 @@ func (t *Transcoder) getStreamWrapper(ctx context.Context, path string, assetID
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // GetMasterPlaylistMulti returns the master HLS playlist with multiple quality options
-func (t *Transcoder) GetMasterPlaylistMulti(ctx context.Context, path string, assetID string) (string, error) {
+func (t *Transcoder) GetMasterPlaylistMulti(
+	ctx context.Context,
+	path string,
+	assetID string,
+	format Format,
+	audioLanguage string,
+	subtitles []Subtitle,
+) (string, error) {
 	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
 	if err != nil {
 		return "", err
 	}
 
 	t.assetChan <- assetID
-	return streamWrapper.GetMasterPlaylistMulti(assetID), nil
+	return streamWrapper.GetMasterPlaylistMulti(assetID, format, audioLanguage, subtitles), nil
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // GetMasterPlaylistSingle returns a master playlist with only one stream
-func (t *Transcoder) GetMasterPlaylistSingle(ctx context.Context, path string, assetID string, isMobile bool) (string, error) {
+func (t *Transcoder) GetMasterPlaylistSingle(
+	ctx context.Context,
+	path string,
+	assetID string,
+	format Format,
+	isMobile bool,
+	audioLanguage string,
+	subtitles []Subtitle,
+) (string, error) {
 	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
 	if err != nil {
 		return "", err
 	}
 
 	t.assetChan <- assetID
-	return streamWrapper.GetMasterPlaylistSingle(assetID, isMobile), nil
+	return streamWrapper.GetMasterPlaylistSingle(assetID, format, isMobile, audioLanguage, subtitles), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// DecidePlayback returns how a client with the given capabilities plays the asset
+func (t *Transcoder) DecidePlayback(
+	ctx context.Context,
+	path string,
+	assetID string,
+	caps ClientCapabilities,
+	format Format,
+	audioLanguage string,
+) (PlaybackDecision, error) {
+	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
+	if err != nil {
+		return PlaybackDecision{}, err
+	}
+
+	t.assetChan <- assetID
+	return streamWrapper.DecidePlayback(caps, format, audioLanguage), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetMasterPlaylistPlayback returns the master playlist for a playback decision. Returns
+// ErrQualityUnavailable when the video cannot be transcoded to the quality of the decision
+func (t *Transcoder) GetMasterPlaylistPlayback(
+	ctx context.Context,
+	path string,
+	assetID string,
+	decision PlaybackDecision,
+	audioLanguage string,
+	subtitles []Subtitle,
+) (string, error) {
+	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
+	if err != nil {
+		return "", err
+	}
+
+	// Only the qualities offered for the video can be transcoded to
+	if decision.Method == PlaybackTranscode && !streamWrapper.isAudioOnly() &&
+		!slices.Contains(streamWrapper.GetQualities(), decision.Quality) {
+		return "", ErrQualityUnavailable
+	}
+
+	t.assetChan <- assetID
+	return streamWrapper.GetMasterPlaylistPlayback(assetID, decision, audioLanguage, subtitles), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetDashManifest returns the MPEG-DASH manifest over the fMP4 streams of the asset
+func (t *Transcoder) GetDashManifest(
+	ctx context.Context,
+	path string,
+	assetID string,
+	audioLanguage string,
+) (string, error) {
+	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
+	if err != nil {
+		return "", err
+	}
+
+	t.assetChan <- assetID
+	return streamWrapper.GetDashManifest(assetID, audioLanguage)
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -207,6 +511,7 @@ func (t *Transcoder) GetVideoIndex(
 	path string,
 	video uint32,
 	quality Quality,
+	format Format,
 	assetID string,
 ) (string, error) {
 	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
@@ -215,17 +520,20 @@ func (t *Transcoder) GetVideoIndex(
 	}
 
 	t.assetChan <- assetID
-	return streamWrapper.GetVideoIndex(video, quality)
+	return streamWrapper.GetVideoIndex(video, quality, format)
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetVideoSegment returns the path to a requested video segment, transcoding if necessary
+// GetVideoSegment returns the path to a requested video segment, transcoding if necessary. The
+// encoder is started for the user of the principal in the context and ErrTranscodeLimit is
+// returned when it would exceed the head limits of the profile
 func (t *Transcoder) GetVideoSegment(
 	ctx context.Context,
 	path string,
 	video uint32,
 	quality Quality,
+	format Format,
 	segment int32,
 	assetID string,
 ) (string, error) {
@@ -235,16 +543,38 @@ func (t *Transcoder) GetVideoSegment(
 	}
 
 	t.assetChan <- assetID
-	return streamWrapper.GetVideoSegment(video, quality, segment)
+	return streamWrapper.GetVideoSegment(principalUserID(ctx), video, quality, format, segment)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetVideoInit returns the path to the init segment of a fMP4 video variant, transcoding if
+// necessary
+func (t *Transcoder) GetVideoInit(
+	ctx context.Context,
+	path string,
+	video uint32,
+	quality Quality,
+	assetID string,
+) (string, error) {
+	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
+	if err != nil {
+		return "", err
+	}
+
+	t.assetChan <- assetID
+	return streamWrapper.GetVideoInit(principalUserID(ctx), video, quality)
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
-// GetAudioIndex returns the audio index playlist for the specified audio index
+// GetAudioIndex returns the audio index playlist for the specified audio index and mode
 func (t *Transcoder) GetAudioIndex(
 	ctx context.Context,
 	path string,
 	audio uint32,
+	mode AudioMode,
+	format Format,
 	assetID string,
 ) (string, error) {
 	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
@@ -253,7 +583,7 @@ func (t *Transcoder) GetAudioIndex(
 	}
 
 	t.assetChan <- assetID
-	return streamWrapper.GetAudioIndex(audio)
+	return streamWrapper.GetAudioIndex(audio, mode, format)
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -263,6 +593,66 @@ func (t *Transcoder) GetAudioSegment(
 	ctx context.Context,
 	path string,
 	audio uint32,
+	mode AudioMode,
+	format Format,
+	segment int32,
+	assetID string,
+) (string, error) {
+	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
+	if err != nil {
+		return "", err
+	}
+
+	t.assetChan <- assetID
+	return streamWrapper.GetAudioSegment(principalUserID(ctx), audio, mode, format, segment)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetAudioInit returns the path to the init segment of a fMP4 audio stream, transcoding if
+// necessary
+func (t *Transcoder) GetAudioInit(
+	ctx context.Context,
+	path string,
+	audio uint32,
+	mode AudioMode,
+	assetID string,
+) (string, error) {
+	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
+	if err != nil {
+		return "", err
+	}
+
+	t.assetChan <- assetID
+	return streamWrapper.GetAudioInit(principalUserID(ctx), audio, mode)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetEmbeddedSubtitleIndex returns the index playlist for an embedded subtitle stream
+func (t *Transcoder) GetEmbeddedSubtitleIndex(
+	ctx context.Context,
+	path string,
+	subtitle uint32,
+	assetID string,
+) (string, error) {
+	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
+	if err != nil {
+		return "", err
+	}
+
+	t.assetChan <- assetID
+	return streamWrapper.GetEmbeddedSubtitleIndex(subtitle)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetEmbeddedSubtitleSegment returns the path to a requested embedded subtitle segment,
+// extracting the subtitle stream if necessary
+func (t *Transcoder) GetEmbeddedSubtitleSegment(
+	ctx context.Context,
+	path string,
+	subtitle uint32,
 	segment int32,
 	assetID string,
 ) (string, error) {
@@ -272,7 +662,20 @@ func (t *Transcoder) GetAudioSegment(
 	}
 
 	t.assetChan <- assetID
-	return streamWrapper.GetAudioSegment(audio, segment)
+	return streamWrapper.GetEmbeddedSubtitleSegment(subtitle, segment)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// GetSubtitleIndex returns the index playlist for a subtitle rendition
+func (t *Transcoder) GetSubtitleIndex(ctx context.Context, path string, assetID string) (string, error) {
+	streamWrapper, err := t.getStreamWrapper(ctx, path, assetID)
+	if err != nil {
+		return "", err
+	}
+
+	t.assetChan <- assetID
+	return streamWrapper.GetSubtitleIndex(), nil
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -22,6 +22,10 @@ type MediaInfo struct {
 	File        ContainerInfo
 	Video       VideoStream
 	Audio       *AudioStream
+	AudioTracks []AudioStream
+	Subtitles   []SubtitleStream
+	Chapters    []Chapter
+	Tags        MediaTags
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -48,9 +52,11 @@ type VideoStream struct {
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
 // Audio stream
-//
-// TODO: support multiple tracks
 type AudioStream struct {
+	Index         int    // absolute stream index in the container
+	Track         int    // index among the audio streams, as used by `-map 0:a:N`
+	Title         string // "English (Commentary)"
+	IsDefault     bool
 	Language      string // "eng" / "und"
 	Codec         string // "aac", "eac3", ...
 	Profile       string // "LC", "Dolby Digital Plus"
@@ -62,6 +68,54 @@ type AudioStream struct {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// Container tags. These are mostly set on audio files
+type MediaTags struct {
+	Title       string
+	Artist      string
+	Album       string
+	HasCoverArt bool // an attached picture stream is present
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Subtitle stream
+//
+// Only text-based subtitles are recorded as bitmap subtitles (PGS, VobSub) cannot be
+// converted to WebVTT
+type SubtitleStream struct {
+	Index     int    // absolute stream index in the container
+	Language  string // "eng" / "und"
+	Title     string // "English (SDH)"
+	Codec     string // "mov_text", "subrip", "ass", ...
+	IsDefault bool
+	IsForced  bool
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Chapter embedded in the container, such as MKV chapters and MP4 chapter atoms
+type Chapter struct {
+	Index    int     // position among the chapters
+	StartSec float64 // start time in seconds
+	EndSec   float64 // end time in seconds
+	Title    string  // "Introduction" (may be empty)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// textSubtitleCodecs are the subtitle codecs that can be converted to WebVTT
+var textSubtitleCodecs = map[string]bool{
+	"mov_text": true,
+	"subrip":   true,
+	"srt":      true,
+	"ass":      true,
+	"ssa":      true,
+	"webvtt":   true,
+	"text":     true,
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 type MediaProbe struct {
 	FFmpeg *media.FFmpeg
 }
@@ -71,8 +125,75 @@ type MediaProbe struct {
 // ProbeVideo uses ffprobe to extract metadata from a video file
 // Returns MediaInfo, video stream index, and error
 func (mp MediaProbe) ProbeVideo(ctx context.Context, path string) (*MediaInfo, int, error) {
+	p, err := mp.run(ctx, path)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	// pick first video stream with dimensions, ignoring cover art
+	var v *stream
+	var videoStreamIndex int = -1
+	for i := range p.Streams {
+		s := &p.Streams[i]
+		if s.CodecType == "video" && s.Width > 0 && s.Height > 0 && s.Disposition.AttachedPic == 0 {
+			v = s
+			videoStreamIndex = s.Index
+			break
+		}
+	}
+	if v == nil {
+		return nil, -1, fmt.Errorf("no video stream")
+	}
+
+	// duration (prefer stream, fallback to container)
+	durStr := v.Duration
+	if durStr == "" {
+		durStr = p.Format.Duration
+	}
+	durF, _ := strconv.ParseFloat(durStr, 64)
+	durationSec := int(math.Round(durF))
+
+	// fps from video stream
+	fpsN, fpsD := parseFPS(v.AvgFrameRate)
+
+	info := &MediaInfo{
+		DurationSec: durationSec,
+		File:        containerInfo(p.Format),
+		Video: VideoStream{
+			Codec:  v.CodecName,
+			Width:  v.Width,
+			Height: v.Height,
+			FPSNum: fpsN,
+			FPSDen: fpsD,
+		},
+		Audio: audioStream(p.Streams),
+	}
+
+	info.AudioTracks = audioStreams(p.Streams)
+	info.Subtitles = subtitleStreams(p.Streams)
+	info.Chapters = chapters(p.Chapters)
+
+	return info, videoStreamIndex, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ProbeAudio uses ffprobe to extract metadata from an audio file
+func (mp MediaProbe) ProbeAudio(ctx context.Context, path string) (*MediaInfo, error) {
+	p, err := mp.run(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return audioInfo(p)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// run runs ffprobe against a file and parses the output
+func (mp MediaProbe) run(ctx context.Context, path string) (*probeOutput, error) {
 	if mp.FFmpeg == nil {
-		return nil, -1, fmt.Errorf("ffprobe unavailable: %w", utils.ErrFFProbeUnavailable)
+		return nil, fmt.Errorf("ffprobe unavailable: %w", utils.ErrFFProbeUnavailable)
 	}
 
 	ffprobePath := mp.FFmpeg.GetFFProbePath()
@@ -80,6 +201,7 @@ func (mp MediaProbe) ProbeVideo(ctx context.Context, path string) (*MediaInfo, i
 	entries := []string{
 		// format (container/file)
 		"format=format_name,filename,size,bit_rate,duration",
+		"format_tags=title,artist,album",
 		// common stream fields
 		"stream=index,codec_type,codec_name,profile,bit_rate",
 		// video
@@ -87,8 +209,11 @@ func (mp MediaProbe) ProbeVideo(ctx context.Context, path string) (*MediaInfo, i
 		// audio
 		"stream=channels,channel_layout,sample_rate",
 		// selection helpers
-		"stream=disposition=default",
-		"stream=tags=language",
+		"stream=disposition=default,forced,attached_pic",
+		"stream=tags=language,title",
+		// chapters
+		"chapter=id,start_time,end_time",
+		"chapter_tags=title",
 	}
 
 	cmd := exec.CommandContext(ctx,
@@ -97,97 +222,193 @@ func (mp MediaProbe) ProbeVideo(ctx context.Context, path string) (*MediaInfo, i
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
+		"-show_chapters",
 		"-show_entries", strings.Join(entries, ","),
 		path,
 	)
 
 	out, err := cmd.Output()
 	if err != nil {
-		return nil, -1, fmt.Errorf("error running ffprobe: %w", err)
+		return nil, fmt.Errorf("error running ffprobe: %w", err)
 	}
 
 	var p probeOutput
 	if err := json.Unmarshal(out, &p); err != nil {
-		return nil, -1, fmt.Errorf("parse: %w", err)
+		return nil, fmt.Errorf("parse: %w", err)
 	}
 
-	// pick first video stream with dimensions
-	var v *stream
-	var videoStreamIndex int = -1
-	for i := range p.Streams {
-		s := &p.Streams[i]
-		if s.CodecType == "video" && s.Width > 0 && s.Height > 0 {
-			v = s
-			videoStreamIndex = s.Index
+	return &p, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// audioInfo builds the MediaInfo for an audio file. The duration is taken from the audio
+// stream, falling back to the container
+func audioInfo(p *probeOutput) (*MediaInfo, error) {
+	a := audioStream(p.Streams)
+	if a == nil {
+		return nil, fmt.Errorf("no audio stream")
+	}
+
+	durStr := ""
+	for _, s := range p.Streams {
+		if s.CodecType == "audio" && s.Duration != "" {
+			durStr = s.Duration
 			break
 		}
 	}
-	if v == nil {
-		return nil, -1, fmt.Errorf("no video stream")
+	if durStr == "" {
+		durStr = p.Format.Duration
 	}
+	durF, _ := strconv.ParseFloat(durStr, 64)
 
-	// pick audio: default if present, else first audio
-	var a *stream
-	for i := range p.Streams {
-		s := &p.Streams[i]
-		if s.CodecType == "audio" && s.Disposition.Default == 1 {
-			a = s
+	info := &MediaInfo{
+		DurationSec: int(math.Round(durF)),
+		File:        containerInfo(p.Format),
+		Audio:       a,
+		Chapters:    chapters(p.Chapters),
+		Tags: MediaTags{
+			Title:  strings.TrimSpace(formatTag(p.Format.Tags, "title")),
+			Artist: strings.TrimSpace(formatTag(p.Format.Tags, "artist")),
+			Album:  strings.TrimSpace(formatTag(p.Format.Tags, "album")),
+		},
+	}
+
+	for _, s := range p.Streams {
+		if s.CodecType == "video" && s.Disposition.AttachedPic == 1 {
+			info.Tags.HasCoverArt = true
 			break
 		}
 	}
-	if a == nil {
-		for i := range p.Streams {
-			s := &p.Streams[i]
-			if s.CodecType == "audio" {
-				a = s
-				break
-			}
+
+	return info, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// containerInfo returns the container (file) facts from the ffprobe format
+func containerInfo(f format) ContainerInfo {
+	return ContainerInfo{
+		Container:  f.FormatName,
+		MIMEType:   guessMIME(f.FormatName, f.Filename),
+		SizeBytes:  parseInt64(f.Size),
+		OverallBPS: parseInt(f.BitRate),
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// audioStream picks the default audio stream, falling back to the first audio stream. Returns
+// nil when there are no audio streams
+func audioStream(streams []stream) *AudioStream {
+	tracks := audioStreams(streams)
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	for i := range tracks {
+		if tracks[i].IsDefault {
+			return &tracks[i]
 		}
 	}
 
-	// duration (prefer stream, fallback to container)
-	durStr := v.Duration
-	if durStr == "" {
-		durStr = p.Format.Duration
+	return &tracks[0]
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// audioStreams returns every audio stream, in container order
+func audioStreams(streams []stream) []AudioStream {
+	var tracks []AudioStream
+
+	for _, s := range streams {
+		if s.CodecType != "audio" {
+			continue
+		}
+
+		tracks = append(tracks, AudioStream{
+			Index:         s.Index,
+			Track:         len(tracks),
+			Title:         strings.TrimSpace(s.Tags["title"]),
+			IsDefault:     s.Disposition.Default == 1,
+			Language:      strings.ToLower(s.Tags["language"]),
+			Codec:         s.CodecName,
+			Profile:       s.Profile,
+			Channels:      normalizeChannels(s.Channels, s.ChannelLayout),
+			ChannelLayout: s.ChannelLayout,
+			SampleRate:    parseInt(s.SampleRate),
+			BitRate:       parseInt(s.BitRate),
+		})
 	}
-	durF, _ := strconv.ParseFloat(durStr, 64)
-	durationSec := int(math.Round(durF))
 
-	// fps from video stream
-	fpsN, fpsD := parseFPS(v.AvgFrameRate)
+	return tracks
+}
 
-	info := &MediaInfo{
-		DurationSec: durationSec,
-		File: ContainerInfo{
-			Container:  p.Format.FormatName,
-			MIMEType:   guessMIME(p.Format.FormatName, p.Format.Filename),
-			SizeBytes:  parseInt64(p.Format.Size),
-			OverallBPS: parseInt(p.Format.BitRate),
-		},
-		Video: VideoStream{
-			Codec:  v.CodecName,
-			Width:  v.Width,
-			Height: v.Height,
-			FPSNum: fpsN,
-			FPSDen: fpsD,
-		},
-		Audio: nil,
-	}
-
-	if a != nil {
-		sr := parseInt(a.SampleRate)
-		info.Audio = &AudioStream{
-			Language:      strings.ToLower(a.Tags["language"]),
-			Codec:         a.CodecName,
-			Profile:       a.Profile,
-			Channels:      normalizeChannels(a.Channels, a.ChannelLayout),
-			ChannelLayout: a.ChannelLayout,
-			SampleRate:    sr,
-			BitRate:       parseInt(a.BitRate),
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// formatTag returns a container tag. The case of tag keys differs between containers (ID3
+// uses "TITLE" in some files, MP4 uses "title")
+func formatTag(tags map[string]string, key string) string {
+	if v, ok := tags[key]; ok {
+		return v
+	}
+
+	for k, v := range tags {
+		if strings.EqualFold(k, key) {
+			return v
 		}
 	}
 
-	return info, videoStreamIndex, nil
+	return ""
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// subtitleStreams returns the text-based subtitle streams, in container order
+func subtitleStreams(streams []stream) []SubtitleStream {
+	var subtitles []SubtitleStream
+
+	for _, s := range streams {
+		if s.CodecType != "subtitle" || !textSubtitleCodecs[strings.ToLower(s.CodecName)] {
+			continue
+		}
+
+		subtitles = append(subtitles, SubtitleStream{
+			Index:     s.Index,
+			Language:  strings.ToLower(s.Tags["language"]),
+			Title:     strings.TrimSpace(s.Tags["title"]),
+			Codec:     strings.ToLower(s.CodecName),
+			IsDefault: s.Disposition.Default == 1,
+			IsForced:  s.Disposition.Forced == 1,
+		})
+	}
+
+	return subtitles
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// chapters returns the chapters of the container, in order. Chapters without a positive length
+// are dropped as players cannot seek into them
+func chapters(raw []chapter) []Chapter {
+	var out []Chapter
+
+	for _, c := range raw {
+		start, _ := strconv.ParseFloat(c.StartTime, 64)
+		end, _ := strconv.ParseFloat(c.EndTime, 64)
+		if end <= start {
+			continue
+		}
+
+		out = append(out, Chapter{
+			Index:    len(out),
+			StartSec: start,
+			EndSec:   end,
+			Title:    strings.TrimSpace(formatTag(c.Tags, "title")),
+		})
+	}
+
+	return out
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -251,6 +472,16 @@ func guessMIME(formatName, filename string) string {
 		return "video/webm"
 	case ".mkv":
 		return "video/x-matroska"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".m4a", ".m4b", ".aac":
+		return "audio/mp4"
+	case ".flac":
+		return "audio/flac"
+	case ".ogg", ".oga", ".opus":
+		return "audio/ogg"
+	case ".wav":
+		return "audio/wav"
 	}
 	if strings.Contains(formatName, "matroska") {
 		return "video/x-matroska"
@@ -270,7 +501,10 @@ func normalizeChannels(ch int, layout string) int {
 	if ch > 0 {
 		return ch
 	}
-	switch strings.ToLower(layout) {
+	// Variants such as "5.1(side)" have the same channel count as the base layout
+	layout, _, _ = strings.Cut(strings.ToLower(layout), "(")
+
+	switch layout {
 	case "mono":
 		return 1
 	case "stereo", "2.0":
@@ -2,6 +2,7 @@ package probe
 
 import (
 	"context"
+	"encoding/json"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -58,6 +59,9 @@ func TestProbeVideo(t *testing.T) {
 		require.Equal(t, "aac", strings.ToLower(info.Audio.Codec))
 		require.Equal(t, 48000, info.Audio.SampleRate)
 		require.GreaterOrEqual(t, info.Audio.Channels, 1) // mono on the synthetic sample
+
+		// No embedded subtitles
+		require.Empty(t, info.Subtitles)
 	})
 
 	t.Run("invalid video", func(t *testing.T) {
@@ -72,3 +76,136 @@ func TestProbeVideo(t *testing.T) {
 }
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestSubtitleStreams(t *testing.T) {
+	streams := []stream{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "audio", CodecName: "aac"},
+		{Index: 2, CodecType: "subtitle", CodecName: "mov_text", Tags: map[string]string{"language": "ENG", "title": " English "}},
+		{Index: 3, CodecType: "subtitle", CodecName: "hdmv_pgs_subtitle", Tags: map[string]string{"language": "fre"}},
+		{Index: 4, CodecType: "subtitle", CodecName: "ASS"},
+	}
+	streams[4].Disposition.Default = 1
+	streams[4].Disposition.Forced = 1
+
+	subtitles := subtitleStreams(streams)
+	require.Equal(t, []SubtitleStream{
+		{Index: 2, Language: "eng", Title: "English", Codec: "mov_text"},
+		{Index: 4, Codec: "ass", IsDefault: true, IsForced: true},
+	}, subtitles)
+
+	require.Nil(t, subtitleStreams(streams[:2]))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestAudioStreams(t *testing.T) {
+	streams := []stream{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "audio", CodecName: "aac", Channels: 2, ChannelLayout: "stereo", Tags: map[string]string{"language": "ENG"}},
+		{Index: 2, CodecType: "subtitle", CodecName: "subrip"},
+		{Index: 3, CodecType: "audio", CodecName: "eac3", ChannelLayout: "5.1(side)", Tags: map[string]string{"language": "fre", "title": " Français 5.1 "}},
+	}
+	streams[3].Disposition.Default = 1
+
+	tracks := audioStreams(streams)
+	require.Len(t, tracks, 2)
+
+	require.Equal(t, 1, tracks[0].Index)
+	require.Equal(t, 0, tracks[0].Track)
+	require.Equal(t, "eng", tracks[0].Language)
+	require.Equal(t, 2, tracks[0].Channels)
+	require.False(t, tracks[0].IsDefault)
+
+	require.Equal(t, 3, tracks[1].Index)
+	require.Equal(t, 1, tracks[1].Track)
+	require.Equal(t, "Français 5.1", tracks[1].Title)
+	require.Equal(t, 6, tracks[1].Channels)
+	require.True(t, tracks[1].IsDefault)
+
+	// The default track is picked as the audio of the asset
+	require.Equal(t, 3, audioStream(streams).Index)
+
+	require.Nil(t, audioStreams(streams[:1]))
+	require.Nil(t, audioStream(streams[:1]))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestChapters(t *testing.T) {
+	raw := `{
+		"chapters": [
+			{"id": 0, "start_time": "0.000000", "end_time": "62.500000", "tags": {"title": " Introduction "}},
+			{"id": 1, "start_time": "62.500000", "end_time": "62.500000", "tags": {"title": "Empty"}},
+			{"id": 2, "start_time": "62.500000", "end_time": "180.000000", "tags": {"TITLE": "Setup"}},
+			{"id": 3, "start_time": "180.000000", "end_time": "240.250000"}
+		]
+	}`
+
+	var p probeOutput
+	require.NoError(t, json.Unmarshal([]byte(raw), &p))
+
+	require.Equal(t, []Chapter{
+		{Index: 0, StartSec: 0, EndSec: 62.5, Title: "Introduction"},
+		{Index: 1, StartSec: 62.5, EndSec: 180, Title: "Setup"},
+		{Index: 2, StartSec: 180, EndSec: 240.25},
+	}, chapters(p.Chapters))
+
+	require.Nil(t, chapters(nil))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestAudioInfo(t *testing.T) {
+	t.Run("mp3 with cover art", func(t *testing.T) {
+		raw := `{
+			"streams": [
+				{"index": 0, "codec_type": "audio", "codec_name": "mp3", "channels": 2, "channel_layout": "stereo", "sample_rate": "44100", "bit_rate": "192000", "duration": "1802.6", "disposition": {"default": 0}},
+				{"index": 1, "codec_type": "video", "codec_name": "mjpeg", "width": 600, "height": 600, "disposition": {"attached_pic": 1}}
+			],
+			"format": {"format_name": "mp3", "filename": "/course/01 episode.mp3", "size": "43262400", "bit_rate": "192000", "duration": "1802.65", "tags": {"TITLE": " Episode 1 ", "artist": "Jane", "album": "Podcast"}}
+		}`
+
+		var p probeOutput
+		require.NoError(t, json.Unmarshal([]byte(raw), &p))
+
+		info, err := audioInfo(&p)
+		require.NoError(t, err)
+
+		require.Equal(t, 1803, info.DurationSec)
+		require.Equal(t, "audio/mpeg", info.File.MIMEType)
+		require.Equal(t, int64(43262400), info.File.SizeBytes)
+		require.Equal(t, 192000, info.File.OverallBPS)
+
+		require.NotNil(t, info.Audio)
+		require.Equal(t, "mp3", info.Audio.Codec)
+		require.Equal(t, 2, info.Audio.Channels)
+		require.Equal(t, 44100, info.Audio.SampleRate)
+
+		require.Equal(t, MediaTags{Title: "Episode 1", Artist: "Jane", Album: "Podcast", HasCoverArt: true}, info.Tags)
+	})
+
+	t.Run("container duration", func(t *testing.T) {
+		raw := `{
+			"streams": [{"index": 0, "codec_type": "audio", "codec_name": "opus", "channel_layout": "mono"}],
+			"format": {"format_name": "ogg", "filename": "/course/01 lecture.opus", "duration": "59.4"}
+		}`
+
+		var p probeOutput
+		require.NoError(t, json.Unmarshal([]byte(raw), &p))
+
+		info, err := audioInfo(&p)
+		require.NoError(t, err)
+		require.Equal(t, 59, info.DurationSec)
+		require.Equal(t, 1, info.Audio.Channels)
+		require.Equal(t, "audio/ogg", info.File.MIMEType)
+		require.Equal(t, MediaTags{}, info.Tags)
+	})
+
+	t.Run("no audio stream", func(t *testing.T) {
+		p := probeOutput{Streams: []stream{{Index: 0, CodecType: "video", CodecName: "h264"}}}
+
+		_, err := audioInfo(&p)
+		require.EqualError(t, err, "no audio stream")
+	})
+}
@@ -4,7 +4,7 @@ package probe
 
 type stream struct {
 	Index     int    `json:"index"`
-	CodecType string `json:"codec_type"` // "video" | "audio"
+	CodecType string `json:"codec_type"` // "video" | "audio" | "subtitle"
 	CodecName string `json:"codec_name"`
 	Profile   string `json:"profile"`
 	BitRate   string `json:"bit_rate"`
@@ -20,19 +20,30 @@ type stream struct {
 	// selection helpers
 	Tags        map[string]string `json:"tags"` // language, etc.
 	Disposition struct {
-		Default int `json:"default"`
+		Default     int `json:"default"`
+		Forced      int `json:"forced"`
+		AttachedPic int `json:"attached_pic"` // cover art
 	} `json:"disposition"`
 }
 
 type format struct {
-	FormatName string `json:"format_name"`
-	Filename   string `json:"filename"`
-	Size       string `json:"size"`
-	BitRate    string `json:"bit_rate"`
-	Duration   string `json:"duration"`
+	FormatName string            `json:"format_name"`
+	Filename   string            `json:"filename"`
+	Size       string            `json:"size"`
+	BitRate    string            `json:"bit_rate"`
+	Duration   string            `json:"duration"`
+	Tags       map[string]string `json:"tags"` // title, artist, album
+}
+
+type chapter struct {
+	ID        int64             `json:"id"`
+	StartTime string            `json:"start_time"` // seconds, e.g. "62.500000"
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags"` // title
 }
 
 type probeOutput struct {
-	Streams []stream `json:"streams"`
-	Format  format   `json:"format"`
+	Streams  []stream  `json:"streams"`
+	Format   format    `json:"format"`
+	Chapters []chapter `json:"chapters"`
 }
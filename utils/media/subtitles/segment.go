@@ -0,0 +1,150 @@
+package subtitles
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// vttCue is a cue from a WebVTT file. The block holds the cue as written, including its
+// identifier, timing line and text
+type vttCue struct {
+	start float64
+	end   float64
+	block string
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// SegmentWebVTT splits a WebVTT file into `count` segments of `segmentLength` seconds, for
+// use in an HLS subtitle playlist. A cue that spans a segment boundary is written to every
+// segment it overlaps and cues after the last segment are written to the last segment
+func SegmentWebVTT(data []byte, segmentLength float64, count int) ([][]byte, error) {
+	if segmentLength <= 0 || count < 1 {
+		return nil, fmt.Errorf("invalid segment length %f or count %d", segmentLength, count)
+	}
+
+	data = normalize(data)
+	if !bytes.HasPrefix(data, []byte("WEBVTT")) {
+		return nil, fmt.Errorf("%w: missing WEBVTT header", ErrInvalidSubtitle)
+	}
+
+	cues, err := parseWebVTTCues(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]bytes.Buffer, count)
+	for i := range segments {
+		segments[i].WriteString("WEBVTT\n")
+	}
+
+	for _, cue := range cues {
+		first := int(cue.start / segmentLength)
+		last := int(cue.end / segmentLength)
+
+		// A cue ending exactly on a boundary does not belong to the next segment
+		if last > first && float64(last)*segmentLength == cue.end {
+			last--
+		}
+
+		first = min(first, count-1)
+		last = min(last, count-1)
+
+		for i := first; i <= last; i++ {
+			segments[i].WriteString("\n")
+			segments[i].WriteString(cue.block)
+			segments[i].WriteString("\n")
+		}
+	}
+
+	out := make([][]byte, count)
+	for i := range segments {
+		out[i] = segments[i].Bytes()
+	}
+
+	return out, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// parseWebVTTCues parses the cues of a WebVTT file. The header, NOTE, STYLE and REGION blocks
+// are dropped
+func parseWebVTTCues(data string) ([]vttCue, error) {
+	var cues []vttCue
+
+	for i, block := range strings.Split(data, "\n\n") {
+		block = strings.Trim(block, "\n")
+		if i == 0 || block == "" {
+			continue
+		}
+
+		lines := strings.Split(block, "\n")
+
+		timing := -1
+		for j, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = j
+				break
+			}
+		}
+
+		// NOTE, STYLE and REGION blocks have no timing line
+		if timing == -1 || timing > 1 {
+			continue
+		}
+
+		fields := strings.Fields(lines[timing])
+		if len(fields) < 3 || fields[1] != "-->" {
+			return nil, fmt.Errorf("%w: invalid timing line %q", ErrInvalidSubtitle, lines[timing])
+		}
+
+		start, err := parseVTTTime(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSubtitle, err)
+		}
+
+		end, err := parseVTTTime(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSubtitle, err)
+		}
+
+		cues = append(cues, vttCue{start: start, end: end, block: block})
+	}
+
+	return cues, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// parseVTTTime parses a WebVTT timestamp (HH:MM:SS.mmm or MM:SS.mmm) into seconds
+func parseVTTTime(value string) (float64, error) {
+	if strings.Count(value, ":") == 1 {
+		value = "0:" + value
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	return float64(hours*3600+minutes*60) + seconds, nil
+}
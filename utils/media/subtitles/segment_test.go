@@ -0,0 +1,43 @@
+package subtitles
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestSubtitles_SegmentWebVTT(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		vtt := "WEBVTT\nKind: captions\n\nNOTE a comment\n\n" +
+			"1\n00:00:01.000 --> 00:00:02.000 align:start\nFirst\n\n" +
+			"00:09.000 --> 00:11.000\nSpans\ntwo lines\n\n" +
+			"00:00:15.000 --> 00:00:20.000\nOn boundary\n\n" +
+			"00:01:00.000 --> 00:01:01.000\nPast the end\n"
+
+		segments, err := SegmentWebVTT([]byte(vtt), 10, 2)
+		require.NoError(t, err)
+		require.Len(t, segments, 2)
+
+		require.Equal(t, "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000 align:start\nFirst\n\n00:09.000 --> 00:11.000\nSpans\ntwo lines\n", string(segments[0]))
+		require.Equal(t, "WEBVTT\n\n00:09.000 --> 00:11.000\nSpans\ntwo lines\n\n00:00:15.000 --> 00:00:20.000\nOn boundary\n\n00:01:00.000 --> 00:01:01.000\nPast the end\n", string(segments[1]))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		segments, err := SegmentWebVTT([]byte("WEBVTT\n"), 10, 3)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("WEBVTT\n"), []byte("WEBVTT\n"), []byte("WEBVTT\n")}, segments)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := SegmentWebVTT([]byte("1\n00:00:01,000 --> 00:00:02,000\nHello\n"), 10, 1)
+		require.ErrorIs(t, err, ErrInvalidSubtitle)
+
+		_, err = SegmentWebVTT([]byte("WEBVTT\n\nabc --> 00:00:02.000\nHello\n"), 10, 1)
+		require.ErrorIs(t, err, ErrInvalidSubtitle)
+
+		_, err = SegmentWebVTT([]byte("WEBVTT\n"), 0, 1)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,266 @@
+package subtitles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported subtitle format")
+	ErrInvalidSubtitle   = errors.New("invalid subtitle file")
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Format is the format of a subtitle file
+type Format string
+
+const (
+	FormatSRT Format = "srt"
+	FormatVTT Format = "vtt"
+	FormatASS Format = "ass"
+	FormatSSA Format = "ssa"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// FormatFromExt returns the subtitle format for a file extension (without the dot). The
+// second return value is false when the extension is not a subtitle format
+func FormatFromExt(ext string) (Format, bool) {
+	switch f := Format(strings.ToLower(ext)); f {
+	case FormatSRT, FormatVTT, FormatASS, FormatSSA:
+		return f, true
+	}
+
+	return "", false
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// languageRegex matches a language suffix, such as `en`, `eng` or `pt-BR`
+var languageRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}(?:[-_][a-zA-Z0-9]{2,8})?$`)
+
+// SplitLanguage splits a language suffix from a name without its extension. For example
+// `Intro.en` => (`Intro`, `en`). The language is empty when there is no suffix
+func SplitLanguage(name string) (string, string) {
+	idx := strings.LastIndex(name, ".")
+	if idx == -1 {
+		return name, ""
+	}
+
+	suffix := name[idx+1:]
+	if !languageRegex.MatchString(suffix) {
+		return name, ""
+	}
+
+	return strings.TrimSpace(name[:idx]), strings.ReplaceAll(suffix, "_", "-")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ToWebVTT converts a subtitle file to WebVTT. WebVTT files are returned as is
+func ToWebVTT(data []byte, format Format) ([]byte, error) {
+	data = normalize(data)
+
+	switch format {
+	case FormatVTT:
+		if !bytes.HasPrefix(data, []byte("WEBVTT")) {
+			return nil, fmt.Errorf("%w: missing WEBVTT header", ErrInvalidSubtitle)
+		}
+		return data, nil
+	case FormatSRT:
+		return srtToWebVTT(data), nil
+	case FormatASS, FormatSSA:
+		return assToWebVTT(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// normalize removes a byte order mark and converts line endings to `\n`
+func normalize(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// srtTimingRegex matches an SRT timing line, such as `00:00:01,000 --> 00:00:02,500`
+var srtTimingRegex = regexp.MustCompile(`^(\d+:\d{2}:\d{2})[,.](\d{3})\s*-->\s*(\d+:\d{2}:\d{2})[,.](\d{3})(.*)$`)
+
+// srtToWebVTT converts SRT to WebVTT. Cue numbers are kept as cue identifiers
+func srtToWebVTT(data []byte) []byte {
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n\n")
+
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if m := srtTimingRegex.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			line = fmt.Sprintf("%s.%s --> %s.%s%s", m[1], m[2], m[3], m[4], m[5])
+		}
+
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	return out.Bytes()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// assCue is a dialogue line from an ASS/SSA file
+type assCue struct {
+	start float64
+	end   float64
+	text  string
+}
+
+// assOverrideRegex matches ASS override blocks, such as `{\b1}`
+var assOverrideRegex = regexp.MustCompile(`\{[^}]*\}`)
+
+// assToWebVTT converts the dialogue lines of an ASS/SSA file to WebVTT. Styling and
+// positioning are dropped
+func assToWebVTT(data []byte) ([]byte, error) {
+	var fields []string
+	var cues []assCue
+	inEvents := false
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+
+		if !inEvents {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "Format":
+			fields = nil
+			for _, field := range strings.Split(value, ",") {
+				fields = append(fields, strings.ToLower(strings.TrimSpace(field)))
+			}
+
+		case "Dialogue":
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("%w: dialogue before format", ErrInvalidSubtitle)
+			}
+
+			// The text is the last field and may contain commas
+			values := strings.SplitN(value, ",", len(fields))
+			if len(values) != len(fields) {
+				continue
+			}
+
+			cue := assCue{}
+			valid := 0
+			for i, field := range fields {
+				v := strings.TrimSpace(values[i])
+				switch field {
+				case "start":
+					if t, err := parseASSTime(v); err == nil {
+						cue.start = t
+						valid++
+					}
+				case "end":
+					if t, err := parseASSTime(v); err == nil {
+						cue.end = t
+						valid++
+					}
+				case "text":
+					cue.text = assText(values[i])
+				}
+			}
+
+			if valid == 2 && cue.text != "" && cue.end > cue.start {
+				cues = append(cues, cue)
+			}
+		}
+	}
+
+	if fields == nil {
+		return nil, fmt.Errorf("%w: missing events", ErrInvalidSubtitle)
+	}
+
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].start < cues[j].start })
+
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n\n")
+
+	for _, cue := range cues {
+		fmt.Fprintf(&out, "%s --> %s\n%s\n\n", FormatVTTTime(cue.start), FormatVTTTime(cue.end), cue.text)
+	}
+
+	return out.Bytes(), nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// assText converts the text of an ASS dialogue line to plain text
+func assText(text string) string {
+	text = assOverrideRegex.ReplaceAllString(text, "")
+	text = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(text)
+
+	// Blank lines would end the cue
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// parseASSTime parses an ASS timestamp (H:MM:SS.cc) into seconds
+func parseASSTime(value string) (float64, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(hours*3600+minutes*60) + seconds, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// FormatVTTTime formats seconds as a WebVTT timestamp (HH:MM:SS.mmm)
+func FormatVTTTime(seconds float64) string {
+	ms := int64(seconds*1000 + 0.5)
+
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, (ms/60000)%60, (ms/1000)%60, ms%1000)
+}
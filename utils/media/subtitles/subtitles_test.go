@@ -0,0 +1,108 @@
+package subtitles
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestSubtitles_FormatFromExt(t *testing.T) {
+	testCases := []struct {
+		ext      string
+		expected Format
+		ok       bool
+	}{
+		{"srt", FormatSRT, true},
+		{"VTT", FormatVTT, true},
+		{"ass", FormatASS, true},
+		{"ssa", FormatSSA, true},
+		{"sub", "", false},
+		{"", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ext, func(t *testing.T) {
+			format, ok := FormatFromExt(tc.ext)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, format)
+		})
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestSubtitles_SplitLanguage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		title    string
+		language string
+	}{
+		{"Intro.en", "Intro", "en"},
+		{"Intro.eng", "Intro", "eng"},
+		{"Intro.pt_BR", "Intro", "pt-BR"},
+		{"Intro", "Intro", ""},
+		{"Intro.forced", "Intro.forced", ""},
+		{"Part 1.5", "Part 1.5", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			title, language := SplitLanguage(tc.name)
+			require.Equal(t, tc.title, title)
+			require.Equal(t, tc.language, language)
+		})
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestSubtitles_ToWebVTT(t *testing.T) {
+	t.Run("srt", func(t *testing.T) {
+		srt := "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:02,500\r\nHello\r\n\r\n2\r\n00:01:00,000 --> 00:01:01,000\r\nWorld\r\nAgain\r\n"
+
+		vtt, err := ToWebVTT([]byte(srt), FormatSRT)
+		require.NoError(t, err)
+		require.Equal(t, "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nHello\n\n2\n00:01:00.000 --> 00:01:01.000\nWorld\nAgain\n", string(vtt))
+	})
+
+	t.Run("vtt", func(t *testing.T) {
+		vtt, err := ToWebVTT([]byte("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n"), FormatVTT)
+		require.NoError(t, err)
+		require.Equal(t, "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n", string(vtt))
+
+		_, err = ToWebVTT([]byte("00:00:01.000 --> 00:00:02.000\nHello\n"), FormatVTT)
+		require.ErrorIs(t, err, ErrInvalidSubtitle)
+	})
+
+	t.Run("ass", func(t *testing.T) {
+		ass := `[Script Info]
+Title: Test
+
+[V4+ Styles]
+Format: Name, Fontname
+Style: Default,Arial
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:00:05.00,0:00:06.50,Default,,0,0,0,,Second, with a comma
+Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,{\b1}Hello{\b0}\NWorld
+Comment: 0,0:00:03.00,0:00:04.00,Default,,0,0,0,,Ignored
+`
+
+		vtt, err := ToWebVTT([]byte(ass), FormatASS)
+		require.NoError(t, err)
+		require.Equal(t, "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\nWorld\n\n00:00:05.000 --> 00:00:06.500\nSecond, with a comma\n\n", string(vtt))
+	})
+
+	t.Run("ass without events", func(t *testing.T) {
+		_, err := ToWebVTT([]byte("[Script Info]\nTitle: Test\n"), FormatASS)
+		require.ErrorIs(t, err, ErrInvalidSubtitle)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := ToWebVTT([]byte(""), Format("sub"))
+		require.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+}
@@ -0,0 +1,565 @@
+package thumbnails
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/geerew/off-course/dao"
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/geerew/off-course/utils/media"
+	"github.com/geerew/off-course/utils/media/subtitles"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/spf13/afero"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	// Interval is the number of seconds covered by each sprite frame
+	Interval = 10
+
+	// The width of each sprite frame. The height follows the aspect ratio of the video
+	frameWidth = 160
+
+	// The height of each sprite frame when the aspect ratio of the video is unknown
+	defaultFrameHeight = 90
+
+	// The number of frames across and down a sprite sheet
+	spriteColumns = 10
+	spriteRows    = 10
+
+	// The width of the lesson thumbnail
+	thumbnailWidth = 480
+
+	// File names within the cache directory of an asset
+	ThumbnailFile = "thumbnail.webp"
+	VttFile       = "thumbnails.vtt"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Thumbnailer defines the interface used by other services to queue and remove the thumbnails
+// of an asset
+type Thumbnailer interface {
+	Add(assetID string)
+	Delete(assetID string) error
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Thumbnails generates, in the background, a thumbnail for each video asset along with sprite
+// sheets and a WebVTT track used to show a preview when scrubbing
+type Thumbnails struct {
+	appFs     *appfs.AppFs
+	dao       *dao.DAO
+	logger    *logger.Logger
+	ffmpeg    *media.FFmpeg
+	cachePath string
+
+	// Assets waiting to be processed, in the order they were added
+	mu      sync.Mutex
+	pending []string
+	queued  map[string]struct{}
+
+	// wake signals the worker that an asset was added
+	wake chan struct{}
+}
+
+// Ensure Thumbnails implements Thumbnailer
+var _ Thumbnailer = (*Thumbnails)(nil)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ThumbnailsConfig is the config for a Thumbnails
+type ThumbnailsConfig struct {
+	CachePath string
+	Db        database.Database
+	AppFs     *appfs.AppFs
+	Logger    *logger.Logger
+	FFmpeg    *media.FFmpeg
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// New creates a new Thumbnails and prepares the cache directory
+func New(config *ThumbnailsConfig) (*Thumbnails, error) {
+	var cachePath string
+
+	if _, ok := config.AppFs.Fs.(*afero.MemMapFs); ok {
+		cachePath = filepath.Join(config.CachePath, "thumbnails")
+	} else {
+		absDataDir, err := filepath.Abs(config.CachePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path for cache path: %w", err)
+		}
+
+		cachePath = filepath.Join(absDataDir, "thumbnails")
+	}
+
+	if err := config.AppFs.Fs.MkdirAll(cachePath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create thumbnails directory: %w", err)
+	}
+
+	return &Thumbnails{
+		appFs:     config.AppFs,
+		dao:       dao.New(config.Db),
+		logger:    config.Logger,
+		ffmpeg:    config.FFmpeg,
+		cachePath: cachePath,
+		queued:    map[string]struct{}{},
+		wake:      make(chan struct{}, 1),
+	}, nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Dir returns the cache directory for an asset
+func (t *Thumbnails) Dir(assetID string) string {
+	return filepath.Join(t.cachePath, assetID)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// ThumbnailPath returns the path to the thumbnail of an asset
+func (t *Thumbnails) ThumbnailPath(assetID string) string {
+	return filepath.Join(t.Dir(assetID), ThumbnailFile)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// VttPath returns the path to the WebVTT thumbnail track of an asset
+func (t *Thumbnails) VttPath(assetID string) string {
+	return filepath.Join(t.Dir(assetID), VttFile)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// SpritePath returns the path to a sprite sheet of an asset
+func (t *Thumbnails) SpritePath(assetID string, sprite int) string {
+	return filepath.Join(t.Dir(assetID), spriteName(sprite))
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Exists checks if the thumbnails for an asset have been generated. The WebVTT track is
+// written last, so its existence means every other file exists
+func (t *Thumbnails) Exists(assetID string) bool {
+	exists, _ := afero.Exists(t.appFs.Fs, t.VttPath(assetID))
+	return exists
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Add queues an asset to have its thumbnails generated. Assets that are already queued are
+// ignored
+func (t *Thumbnails) Add(assetID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.queued[assetID]; exists {
+		return
+	}
+
+	t.queued[assetID] = struct{}{}
+	t.pending = append(t.pending, assetID)
+
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Delete removes an asset from the queue and deletes its thumbnails
+func (t *Thumbnails) Delete(assetID string) error {
+	t.mu.Lock()
+	if _, exists := t.queued[assetID]; exists {
+		delete(t.queued, assetID)
+		for i, id := range t.pending {
+			if id == assetID {
+				t.pending = append(t.pending[:i], t.pending[i+1:]...)
+				break
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	if err := t.appFs.Fs.RemoveAll(t.Dir(assetID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete thumbnails: %w", err)
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Pending returns the number of assets waiting to be processed
+func (t *Thumbnails) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.pending)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// next removes and returns the next queued asset
+func (t *Thumbnails) next() (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.pending) == 0 {
+		return "", false
+	}
+
+	assetID := t.pending[0]
+	t.pending = t.pending[1:]
+	delete(t.queued, assetID)
+
+	return assetID, true
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Worker queues every video asset that is missing thumbnails, then processes the queue until
+// the context is cancelled
+func (t *Thumbnails) Worker(ctx context.Context) {
+	t.logger.Debug().Msg("Started thumbnails worker")
+
+	if err := t.AddMissing(ctx); err != nil && ctx.Err() == nil {
+		t.logger.Error().Err(err).Msg("Failed to queue assets missing thumbnails")
+	}
+
+	for {
+		assetID, ok := t.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				t.logger.Debug().Msg("Thumbnails worker stopped")
+				return
+			case <-t.wake:
+				continue
+			}
+		}
+
+		if err := t.Generate(ctx, assetID); err != nil {
+			if ctx.Err() != nil {
+				t.logger.Debug().Msg("Thumbnails worker stopped")
+				return
+			}
+
+			t.logger.Error().
+				Err(err).
+				Str("asset_id", assetID).
+				Msg("Failed to generate thumbnails")
+		}
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// AddMissing queues every video asset whose thumbnails have not been generated
+func (t *Thumbnails) AddMissing(ctx context.Context) error {
+	dbOpts := dao.NewOptions().
+		WithWhere(squirrel.Eq{models.ASSET_TABLE_TYPE: types.AssetVideo}).
+		WithOrderBy(models.ASSET_TABLE_CREATED_AT + " ASC")
+
+	assets, err := t.dao.ListAssets(ctx, dbOpts)
+	if err != nil {
+		return err
+	}
+
+	for _, asset := range assets {
+		if !t.Exists(asset.ID) {
+			t.Add(asset.ID)
+		}
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Generate generates the thumbnail, sprite sheets and WebVTT track for a video asset. A frame
+// is grabbed for every interval, using the stored keyframe within the interval when there is
+// one, as seeking to a keyframe is fast and never lands on a partially decoded frame.
+// Everything is written to a temporary directory that then replaces the cache directory of
+// the asset
+func (t *Thumbnails) Generate(ctx context.Context, assetID string) error {
+	if t.Exists(assetID) {
+		return nil
+	}
+
+	dbOpts := dao.NewOptions().
+		WithWhere(squirrel.Eq{models.ASSET_TABLE_ID: assetID}).
+		WithAssetMetadata()
+
+	asset, err := t.dao.GetAsset(ctx, dbOpts)
+	if err != nil {
+		return err
+	}
+
+	// The asset was deleted or is not a video
+	if asset == nil || !asset.Type.IsVideo() {
+		return nil
+	}
+
+	duration := float64(asset.AssetMetadata.DurationSec())
+	if duration <= 0 {
+		return fmt.Errorf("unknown duration")
+	}
+
+	var keyframes []float64
+	if assetKeyframes, err := t.dao.GetAssetKeyframes(ctx, assetID); err != nil {
+		return err
+	} else if assetKeyframes != nil {
+		keyframes = assetKeyframes.Keyframes
+	}
+
+	// Videos within an archive are extracted, as ffmpeg needs a real file
+	inputPath, err := t.appFs.LocalPath(asset.Path)
+	if err != nil {
+		return fmt.Errorf("failed to extract video: %w", err)
+	}
+
+	frameHeight := defaultFrameHeight
+	if video := asset.AssetMetadata.VideoMetadata; video != nil && video.Width > 0 && video.Height > 0 {
+		frameHeight = max(evenRound(float64(frameWidth*video.Height)/float64(video.Width)), 2)
+	}
+
+	tmpDir := t.Dir(assetID) + ".tmp"
+	framesDir := filepath.Join(tmpDir, "frames")
+
+	if err := t.appFs.Fs.RemoveAll(tmpDir); err != nil {
+		return fmt.Errorf("failed to clear temporary directory: %w", err)
+	}
+
+	if err := t.appFs.Fs.MkdirAll(framesDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	defer t.appFs.Fs.RemoveAll(tmpDir)
+
+	t.logger.Debug().
+		Str("asset_id", assetID).
+		Str("asset_path", asset.Path).
+		Int("keyframes", len(keyframes)).
+		Msg("Generating thumbnails")
+
+	// Grab a frame for every interval
+	times := FrameTimes(duration, keyframes, Interval)
+	scale := fmt.Sprintf("scale=%d:%d", frameWidth, frameHeight)
+
+	for i, at := range times {
+		framePath := filepath.Join(framesDir, fmt.Sprintf("frame-%05d.jpg", i))
+		if err := t.extractFrame(ctx, inputPath, framePath, at, scale); err != nil {
+			if ctx.Err() != nil || i == 0 {
+				return err
+			}
+
+			// Seeking close to the end of a video may not produce a frame, so repeat the
+			// previous frame to keep the sprite sheets aligned with the track
+			previous := filepath.Join(framesDir, fmt.Sprintf("frame-%05d.jpg", i-1))
+			data, err := afero.ReadFile(t.appFs.Fs, previous)
+			if err != nil {
+				return err
+			}
+
+			if err := afero.WriteFile(t.appFs.Fs, framePath, data, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Tile the frames into sprite sheets
+	if err := t.run(ctx, assetID,
+		"-framerate", "1",
+		"-i", filepath.Join(framesDir, "frame-%05d.jpg"),
+		"-vf", fmt.Sprintf("tile=%dx%d", spriteColumns, spriteRows),
+		"-q:v", "5",
+		"-start_number", "0",
+		"-y", filepath.Join(tmpDir, "sprite-%d.jpg"),
+	); err != nil {
+		return err
+	}
+
+	// The lesson thumbnail is taken a little way into the video, to skip any title screen
+	if err := t.run(ctx, assetID,
+		"-ss", formatSeconds(times[len(times)/10]),
+		"-i", inputPath,
+		"-an",
+		"-sn",
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", thumbnailWidth),
+		"-quality", "80",
+		"-y", filepath.Join(tmpDir, ThumbnailFile),
+	); err != nil {
+		return err
+	}
+
+	vtt := BuildVtt(times, duration, frameWidth, frameHeight)
+	if err := afero.WriteFile(t.appFs.Fs, filepath.Join(tmpDir, VttFile), []byte(vtt), 0o644); err != nil {
+		return fmt.Errorf("failed to write thumbnails track: %w", err)
+	}
+
+	if err := t.appFs.Fs.RemoveAll(framesDir); err != nil {
+		return fmt.Errorf("failed to remove frames: %w", err)
+	}
+
+	if err := t.appFs.Fs.RemoveAll(t.Dir(assetID)); err != nil {
+		return fmt.Errorf("failed to clear thumbnails directory: %w", err)
+	}
+
+	if err := t.appFs.Fs.Rename(tmpDir, t.Dir(assetID)); err != nil {
+		return fmt.Errorf("failed to move thumbnails: %w", err)
+	}
+
+	t.logger.Info().
+		Str("asset_id", assetID).
+		Str("asset_path", asset.Path).
+		Int("frames", len(times)).
+		Msg("Generated thumbnails")
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// extractFrame extracts a single scaled frame of a video at the timestamp
+func (t *Thumbnails) extractFrame(ctx context.Context, inputPath, framePath string, at float64, scale string) error {
+	if err := t.run(ctx, "",
+		"-ss", formatSeconds(at),
+		"-i", inputPath,
+		"-an",
+		"-sn",
+		"-frames:v", "1",
+		"-vf", scale,
+		"-q:v", "5",
+		"-y", framePath,
+	); err != nil {
+		return err
+	}
+
+	if exists, _ := afero.Exists(t.appFs.Fs, framePath); !exists {
+		return fmt.Errorf("no frame at %s", formatSeconds(at))
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// run runs ffmpeg with the arguments
+func (t *Thumbnails) run(ctx context.Context, assetID string, args ...string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	args = append([]string{"-nostats", "-hide_banner", "-loglevel", "warning"}, args...)
+	cmd := exec.CommandContext(ctx, t.ffmpeg.GetFFmpegPath(), args...)
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		t.logger.Debug().
+			Err(err).
+			Str("asset_id", assetID).
+			Str("command", strings.Join(cmd.Args, " ")).
+			Str("stderr", stderr.String()).
+			Msg("FFmpeg failed")
+
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	return nil
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// FrameTimes returns the timestamp of the frame to grab for each interval of the video. The
+// first keyframe within an interval is used, falling back to the start of the interval
+func FrameTimes(duration float64, keyframes []float64, interval float64) []float64 {
+	sorted := append([]float64{}, keyframes...)
+	sort.Float64s(sorted)
+
+	times := []float64{}
+	for start := 0.0; start < duration; start += interval {
+		at := start
+
+		i := sort.SearchFloat64s(sorted, start)
+		if i < len(sorted) && sorted[i] < start+interval && sorted[i] < duration {
+			at = sorted[i]
+		}
+
+		times = append(times, at)
+	}
+
+	return times
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// BuildVtt builds a WebVTT track with a cue for each interval of the video, pointing at the
+// frame in the sprite sheets. Sprite sheets are referenced relative to the track
+func BuildVtt(times []float64, duration float64, width, height int) string {
+	perSprite := spriteColumns * spriteRows
+
+	var out strings.Builder
+	out.WriteString("WEBVTT\n\n")
+
+	for i := range times {
+		start := float64(i * Interval)
+		end := min(start+Interval, duration)
+
+		position := i % perSprite
+		x := (position % spriteColumns) * width
+		y := (position / spriteColumns) * height
+
+		fmt.Fprintf(&out, "%s --> %s\n%s#xywh=%d,%d,%d,%d\n\n",
+			subtitles.FormatVTTTime(start),
+			subtitles.FormatVTTTime(end),
+			spriteName(i/perSprite),
+			x, y, width, height,
+		)
+	}
+
+	return out.String()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// spriteName returns the file name of a sprite sheet
+func spriteName(sprite int) string {
+	return "sprite-" + strconv.Itoa(sprite) + ".jpg"
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// formatSeconds formats seconds for an ffmpeg argument
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// evenRound rounds to the nearest even number, as required by most encoders
+func evenRound(value float64) int {
+	return int(value/2+0.5) * 2
+}
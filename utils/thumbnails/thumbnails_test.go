@@ -0,0 +1,241 @@
+package thumbnails
+
+import (
+	"context"
+	"database/sql"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geerew/off-course/database"
+	"github.com/geerew/off-course/models"
+	"github.com/geerew/off-course/utils/appfs"
+	"github.com/geerew/off-course/utils/logger"
+	"github.com/geerew/off-course/utils/media"
+	"github.com/geerew/off-course/utils/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func setup(t *testing.T, fs afero.Fs, cachePath string, ffmpeg *media.FFmpeg) (*Thumbnails, context.Context) {
+	t.Helper()
+
+	dbManager, err := database.NewSQLiteManager(&database.DatabaseManagerConfig{
+		DataDir: "./oc_data",
+		AppFs:   appfs.New(afero.NewMemMapFs()),
+		Testing: true,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, dbManager)
+
+	thumbnails, err := New(&ThumbnailsConfig{
+		CachePath: cachePath,
+		Db:        dbManager.DataDb,
+		AppFs:     appfs.New(fs),
+		Logger:    logger.NilLogger().WithThumbnails(),
+		FFmpeg:    ffmpeg,
+	})
+	require.NoError(t, err)
+
+	return thumbnails, context.Background()
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// createAsset creates a course with a lesson holding an asset at the path. Video assets are
+// given metadata with the duration
+func createAsset(t *testing.T, th *Thumbnails, ctx context.Context, path string, durationSec int) *models.Asset {
+	t.Helper()
+
+	course := &models.Course{Title: filepath.Base(path), Path: filepath.Join(filepath.Dir(path), "course-"+filepath.Base(path))}
+	require.NoError(t, th.dao.CreateCourse(ctx, course))
+
+	lesson := &models.Lesson{CourseID: course.ID, Title: "Intro", Prefix: sql.NullInt16{Int16: 1, Valid: true}}
+	require.NoError(t, th.dao.CreateLesson(ctx, lesson))
+
+	asset := &models.Asset{
+		CourseID: course.ID,
+		LessonID: lesson.ID,
+		Title:    filepath.Base(path),
+		Prefix:   sql.NullInt16{Int16: 1, Valid: true},
+		Type:     types.MustAsset(strings.TrimPrefix(filepath.Ext(path), ".")),
+		Path:     path,
+		FileSize: 1024,
+		ModTime:  time.Now().Format(time.RFC3339Nano),
+		Hash:     path,
+	}
+	require.NoError(t, th.dao.CreateAsset(ctx, asset))
+
+	if asset.Type.IsVideo() {
+		metadata := &models.AssetMetadata{
+			AssetID:       asset.ID,
+			VideoMetadata: &models.VideoMetadata{DurationSec: durationSec, Width: 160, Height: 120},
+		}
+		require.NoError(t, th.dao.CreateAssetMetadata(ctx, metadata))
+	}
+
+	return asset
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestThumbnails_Queue(t *testing.T) {
+	th, _ := setup(t, afero.NewMemMapFs(), "/data", nil)
+
+	th.Add("1")
+	th.Add("2")
+	th.Add("1")
+	require.Equal(t, 2, th.Pending())
+
+	// Deleting removes the asset from the queue and deletes its thumbnails
+	require.NoError(t, afero.WriteFile(th.appFs.Fs, th.VttPath("1"), []byte("WEBVTT"), 0o644))
+	require.True(t, th.Exists("1"))
+
+	require.NoError(t, th.Delete("1"))
+	require.False(t, th.Exists("1"))
+	require.Equal(t, 1, th.Pending())
+
+	exists, err := afero.DirExists(th.appFs.Fs, th.Dir("1"))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// Deleting an asset without thumbnails is a no-op
+	require.NoError(t, th.Delete("missing"))
+
+	assetID, ok := th.next()
+	require.True(t, ok)
+	require.Equal(t, "2", assetID)
+
+	_, ok = th.next()
+	require.False(t, ok)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestThumbnails_AddMissing(t *testing.T) {
+	th, ctx := setup(t, afero.NewMemMapFs(), "/data", nil)
+
+	generated := createAsset(t, th, ctx, "/course/a.mp4", 60)
+	missing := createAsset(t, th, ctx, "/course/b.mp4", 60)
+	createAsset(t, th, ctx, "/course/c.pdf", 0)
+
+	require.NoError(t, afero.WriteFile(th.appFs.Fs, th.VttPath(generated.ID), []byte("WEBVTT"), 0o644))
+
+	require.NoError(t, th.AddMissing(ctx))
+	require.Equal(t, 1, th.Pending())
+
+	assetID, ok := th.next()
+	require.True(t, ok)
+	require.Equal(t, missing.ID, assetID)
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func TestThumbnails_Generate(t *testing.T) {
+	t.Run("skips missing and non-video assets", func(t *testing.T) {
+		th, ctx := setup(t, afero.NewMemMapFs(), "/data", nil)
+
+		pdf := createAsset(t, th, ctx, "/course/a.pdf", 0)
+
+		require.NoError(t, th.Generate(ctx, "missing"))
+		require.NoError(t, th.Generate(ctx, pdf.ID))
+		require.False(t, th.Exists(pdf.ID))
+	})
+
+	t.Run("generates", func(t *testing.T) {
+		ffmpeg, err := media.NewFFmpeg()
+		if err != nil {
+			t.Skip("FFmpeg not available for testing")
+		}
+
+		tmpDir := t.TempDir()
+		th, ctx := setup(t, afero.NewOsFs(), tmpDir, ffmpeg)
+
+		videoPath := filepath.Join(tmpDir, "video.mp4")
+		createVideoCmd := exec.Command(ffmpeg.GetFFmpegPath(),
+			"-f", "lavfi",
+			"-i", "testsrc=duration=25:size=160x120:rate=5",
+			"-y",
+			videoPath,
+		)
+		if err := createVideoCmd.Run(); err != nil {
+			t.Skipf("Failed to create test video: %v", err)
+		}
+
+		asset := createAsset(t, th, ctx, videoPath, 25)
+		require.NoError(t, th.dao.CreateAssetKeyframes(ctx, &models.AssetKeyframes{
+			AssetID:    asset.ID,
+			Keyframes:  []float64{0, 12, 24},
+			IsComplete: true,
+		}))
+
+		require.NoError(t, th.Generate(ctx, asset.ID))
+		require.True(t, th.Exists(asset.ID))
+
+		for _, path := range []string{th.ThumbnailPath(asset.ID), th.SpritePath(asset.ID, 0)} {
+			exists, err := afero.Exists(th.appFs.Fs, path)
+			require.NoError(t, err)
+			require.True(t, exists, path)
+		}
+
+		vtt, err := afero.ReadFile(th.appFs.Fs, th.VttPath(asset.ID))
+		require.NoError(t, err)
+		require.Equal(t, BuildVtt([]float64{0, 12, 24}, 25, 160, 120), string(vtt))
+
+		// The temporary directory is removed
+		exists, err := afero.DirExists(th.appFs.Fs, th.Dir(asset.ID)+".tmp")
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_FrameTimes(t *testing.T) {
+	tests := []struct {
+		name      string
+		duration  float64
+		keyframes []float64
+		expected  []float64
+	}{
+		{"no keyframes", 35, nil, []float64{0, 10, 20, 30}},
+		{"keyframes", 35, []float64{0, 4, 12, 25, 31.5}, []float64{0, 12, 25, 31.5}},
+		{"unsorted keyframes", 35, []float64{25, 12, 0}, []float64{0, 12, 25, 30}},
+		{"keyframe past the end", 32, []float64{0, 33}, []float64{0, 10, 20, 30}},
+		{"shorter than an interval", 4, []float64{0, 2}, []float64{0}},
+		{"no duration", 0, nil, []float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, FrameTimes(tt.duration, tt.keyframes, Interval))
+		})
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_BuildVtt(t *testing.T) {
+	times := make([]float64, 101)
+	for i := range times {
+		times[i] = float64(i * Interval)
+	}
+
+	vtt := BuildVtt(times, 1005, 160, 90)
+	require.True(t, strings.HasPrefix(vtt, "WEBVTT\n\n"))
+
+	cues := strings.Split(strings.TrimSpace(strings.TrimPrefix(vtt, "WEBVTT\n\n")), "\n\n")
+	require.Len(t, cues, 101)
+
+	require.Equal(t, "00:00:00.000 --> 00:00:10.000\nsprite-0.jpg#xywh=0,0,160,90", cues[0])
+	require.Equal(t, "00:00:10.000 --> 00:00:20.000\nsprite-0.jpg#xywh=160,0,160,90", cues[1])
+	require.Equal(t, "00:01:40.000 --> 00:01:50.000\nsprite-0.jpg#xywh=0,90,160,90", cues[10])
+	require.Equal(t, "00:16:30.000 --> 00:16:40.000\nsprite-0.jpg#xywh=1440,810,160,90", cues[99])
+
+	// The last cue starts a new sprite sheet and ends with the video
+	require.Equal(t, "00:16:40.000 --> 00:16:45.000\nsprite-1.jpg#xywh=0,0,160,90", cues[100])
+}
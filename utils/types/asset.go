@@ -18,6 +18,7 @@ type AssetType string
 
 const (
 	AssetVideo    AssetType = "video"
+	AssetAudio    AssetType = "audio"
 	AssetPDF      AssetType = "pdf"
 	AssetMarkdown AssetType = "markdown"
 	AssetText     AssetType = "text"
@@ -31,18 +32,21 @@ func NewAsset(ext string) (AssetType, error) {
 	switch strings.ToLower(ext) {
 	case "avi",
 		"mkv",
-		"flac",
 		"mp4",
-		"m4a",
-		"mp3",
 		"ogv",
 		"ogm",
+		"webm":
+		return AssetVideo, nil
+	case "aac",
+		"flac",
+		"m4a",
+		"m4b",
+		"mp3",
 		"ogg",
 		"oga",
 		"opus",
-		"webm",
 		"wav":
-		return AssetVideo, nil
+		return AssetAudio, nil
 	case "pdf":
 		return AssetPDF, nil
 	case "md":
@@ -72,7 +76,7 @@ func MustAsset(ext string) AssetType {
 // IsValid checks if the asset type is valid
 func (a AssetType) IsValid() bool {
 	switch a {
-	case AssetVideo, AssetPDF, AssetMarkdown, AssetText:
+	case AssetVideo, AssetAudio, AssetPDF, AssetMarkdown, AssetText:
 		return true
 	}
 	return false
@@ -87,6 +91,21 @@ func (a AssetType) IsVideo() bool {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// IsAudio returns true if the asset is of type audio
+func (a AssetType) IsAudio() bool {
+	return a == AssetAudio
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// IsMedia returns true if the asset is of type video or audio. These assets are probed with
+// ffprobe, streamed and have their position tracked
+func (a AssetType) IsMedia() bool {
+	return a == AssetVideo || a == AssetAudio
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // IsPDF returns true if the asset is of type PDF
 func (a AssetType) IsPDF() bool {
 	return a == AssetPDF
@@ -154,6 +173,8 @@ func (a *AssetType) Scan(value any) error {
 	switch vv {
 	case string(AssetVideo):
 		*a = AssetVideo
+	case string(AssetAudio):
+		*a = AssetAudio
 	case string(AssetPDF):
 		*a = AssetPDF
 	case string(AssetMarkdown):
@@ -17,17 +17,21 @@ func TestAsset_NewAsset(t *testing.T) {
 		// Video
 		{"avi", AssetVideo},
 		{"mkv", AssetVideo},
-		{"flac", AssetVideo},
 		{"mp4", AssetVideo},
-		{"m4a", AssetVideo},
-		{"mp3", AssetVideo},
 		{"ogv", AssetVideo},
 		{"ogm", AssetVideo},
-		{"ogg", AssetVideo},
-		{"oga", AssetVideo},
-		{"opus", AssetVideo},
 		{"webm", AssetVideo},
-		{"wav", AssetVideo},
+		// Audio
+		{"aac", AssetAudio},
+		{"flac", AssetAudio},
+		{"m4a", AssetAudio},
+		{"m4b", AssetAudio},
+		{"mp3", AssetAudio},
+		{"MP3", AssetAudio},
+		{"ogg", AssetAudio},
+		{"oga", AssetAudio},
+		{"opus", AssetAudio},
+		{"wav", AssetAudio},
 		// document
 		{"pdf", AssetPDF},
 		// markdown
@@ -57,6 +61,7 @@ func TestAsset_MustAsset(t *testing.T) {
 			expected AssetType
 		}{
 			{"mp4", AssetVideo},
+			{"mp3", AssetAudio},
 			{"pdf", AssetPDF},
 			{"md", AssetMarkdown},
 			{"txt", AssetText},
@@ -87,11 +92,20 @@ func TestAsset_Is(t *testing.T) {
 	// Is video
 	a, _ := NewAsset("mp4")
 	require.True(t, a.IsVideo())
+	require.True(t, a.IsMedia())
+	require.True(t, a.IsValid())
+
+	// Is audio
+	a, _ = NewAsset("mp3")
+	require.True(t, a.IsAudio())
+	require.True(t, a.IsMedia())
+	require.False(t, a.IsVideo())
 	require.True(t, a.IsValid())
 
 	// Is PDF
 	a, _ = NewAsset("pdf")
 	require.True(t, a.IsPDF())
+	require.False(t, a.IsMedia())
 	require.True(t, a.IsValid())
 
 	// Is Markdown
@@ -115,6 +129,9 @@ func TestAsset_String(t *testing.T) {
 	a, _ := NewAsset("mp4")
 	require.Equal(t, "video", a.String())
 
+	a, _ = NewAsset("mp3")
+	require.Equal(t, "audio", a.String())
+
 	a, _ = NewAsset("pdf")
 	require.Equal(t, "pdf", a.String())
 
@@ -134,6 +151,7 @@ func TestAsset_MarshalJSON(t *testing.T) {
 		hasError bool
 	}{
 		{"mp4", `"video"`, false},
+		{"mp3", `"audio"`, false},
 		{"pdf", `"pdf"`, false},
 		{"md", `"markdown"`, false},
 		{"txt", `"text"`, false},
@@ -174,6 +192,7 @@ func TestAsset_UnmarshalJSON(t *testing.T) {
 		{`"bob"`, "", "invalid asset type"},
 		// Success
 		{`"video"`, AssetVideo, ""},
+		{`"audio"`, AssetAudio, ""},
 		{`"pdf"`, AssetPDF, ""},
 		{`"markdown"`, AssetMarkdown, ""},
 		{`"text"`, AssetText, ""},
@@ -202,6 +221,7 @@ func TestAsset_Value(t *testing.T) {
 		hasError bool
 	}{
 		{"mp4", "video", false},
+		{"mp3", "audio", false},
 		{"pdf", "pdf", false},
 		{"md", "markdown", false},
 		{"txt", "text", false},
@@ -235,6 +255,7 @@ func TestAsset_Scan(t *testing.T) {
 			expected AssetType
 		}{
 			{"video", AssetVideo},
+			{"audio", AssetAudio},
 			{"pdf", AssetPDF},
 			{"markdown", AssetMarkdown},
 			{"text", AssetText},
@@ -39,3 +39,24 @@ func (c CardExtension) IsValid() bool {
 func (c CardExtension) String() string {
 	return string(c)
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// CardSource is where the card for a course comes from
+type CardSource string
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+const (
+	// The course has no card and is served the fallback card
+	CardSourceNone CardSource = ""
+
+	// A card.* file in the root of the course directory
+	CardSourceFile CardSource = "file"
+
+	// A frame grabbed from the first video of the course. A card file replaces it
+	CardSourceGenerated CardSource = "generated"
+
+	// A frame or image chosen by an admin. A scan never replaces it
+	CardSourceCustom CardSource = "custom"
+)
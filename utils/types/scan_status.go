@@ -18,6 +18,11 @@ type ScanStatusType string
 const (
 	ScanStatusWaiting    ScanStatusType = "waiting"
 	ScanStatusProcessing ScanStatusType = "processing"
+
+	// Final statuses, used for scan history
+	ScanStatusCompleted ScanStatusType = "completed"
+	ScanStatusFailed    ScanStatusType = "failed"
+	ScanStatusCancelled ScanStatusType = "cancelled"
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -36,10 +41,31 @@ func NewScanStatusProcessing() ScanStatusType {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// NewScanStatusCompleted creates a ScanStatusType with the status of completed
+func NewScanStatusCompleted() ScanStatusType {
+	return ScanStatusCompleted
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewScanStatusFailed creates a ScanStatusType with the status of failed
+func NewScanStatusFailed() ScanStatusType {
+	return ScanStatusFailed
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NewScanStatusCancelled creates a ScanStatusType with the status of cancelled
+func NewScanStatusCancelled() ScanStatusType {
+	return ScanStatusCancelled
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // IsValid checks if the scan status is valid
 func (s ScanStatusType) IsValid() bool {
 	switch s {
-	case ScanStatusWaiting, ScanStatusProcessing:
+	case ScanStatusWaiting, ScanStatusProcessing, ScanStatusCompleted, ScanStatusFailed, ScanStatusCancelled:
 		return true
 	}
 	return false
@@ -61,6 +87,14 @@ func (s ScanStatusType) IsProcessing() bool {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+// IsFinished returns true if the status is one of the final statuses (completed, failed or
+// cancelled)
+func (s ScanStatusType) IsFinished() bool {
+	return s == ScanStatusCompleted || s == ScanStatusFailed || s == ScanStatusCancelled
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 // String implements the `Stringer` interface
 func (s ScanStatusType) String() string {
 	return string(s)
@@ -109,6 +143,12 @@ func (s *ScanStatusType) Scan(value any) error {
 		*s = ScanStatusWaiting
 	case string(ScanStatusProcessing):
 		*s = ScanStatusProcessing
+	case string(ScanStatusCompleted):
+		*s = ScanStatusCompleted
+	case string(ScanStatusFailed):
+		*s = ScanStatusFailed
+	case string(ScanStatusCancelled):
+		*s = ScanStatusCancelled
 	default:
 		return fmt.Errorf("invalid scan status: %s", vv)
 	}
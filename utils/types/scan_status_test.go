@@ -20,6 +20,14 @@ func TestScanStatus_NewScanStatusProcessing(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func TestScanStatus_NewScanStatusFinished(t *testing.T) {
+	require.Equal(t, ScanStatusCompleted, NewScanStatusCompleted())
+	require.Equal(t, ScanStatusFailed, NewScanStatusFailed())
+	require.Equal(t, ScanStatusCancelled, NewScanStatusCancelled())
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func TestScanStatus_IsWaiting(t *testing.T) {
 	require.True(t, NewScanStatusWaiting().IsWaiting())
 	require.False(t, NewScanStatusProcessing().IsWaiting())
@@ -36,9 +44,23 @@ func TestScanStatus_IsProcessing(t *testing.T) {
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 
+func TestScanStatus_IsFinished(t *testing.T) {
+	require.False(t, NewScanStatusWaiting().IsFinished())
+	require.False(t, NewScanStatusProcessing().IsFinished())
+	require.True(t, NewScanStatusCompleted().IsFinished())
+	require.True(t, NewScanStatusFailed().IsFinished())
+	require.True(t, NewScanStatusCancelled().IsFinished())
+	require.False(t, ScanStatusType("").IsFinished())
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
 func TestScanStatus_IsValid(t *testing.T) {
 	require.True(t, NewScanStatusWaiting().IsValid())
 	require.True(t, NewScanStatusProcessing().IsValid())
+	require.True(t, NewScanStatusCompleted().IsValid())
+	require.True(t, NewScanStatusFailed().IsValid())
+	require.True(t, NewScanStatusCancelled().IsValid())
 	require.False(t, ScanStatusType("").IsValid())
 	require.False(t, ScanStatusType("invalid").IsValid())
 }
@@ -79,6 +101,9 @@ func TestScanStatus_UnmarshalJSON(t *testing.T) {
 		// Success
 		{`"waiting"`, ScanStatusWaiting, ""},
 		{`"processing"`, ScanStatusProcessing, ""},
+		{`"completed"`, ScanStatusCompleted, ""},
+		{`"failed"`, ScanStatusFailed, ""},
+		{`"cancelled"`, ScanStatusCancelled, ""},
 	}
 
 	for _, tt := range tests {
@@ -124,6 +149,9 @@ func TestScanStatus_Scan(t *testing.T) {
 		}{
 			{"waiting", ScanStatusWaiting},
 			{"processing", ScanStatusProcessing},
+			{"completed", ScanStatusCompleted},
+			{"failed", ScanStatusFailed},
+			{"cancelled", ScanStatusCancelled},
 		}
 
 		for _, tt := range tests {
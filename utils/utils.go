@@ -6,6 +6,9 @@ import (
 	"net/url"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -87,3 +90,104 @@ func Map[T, V any](ts []T, fn func(T) V) []V {
 	}
 	return result
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// NaturalLess reports whether a sorts before b in natural order. Runs of digits are compared
+// by their numeric value, so "file 2" sorts before "file 10", and everything else is compared
+// case-insensitively
+func NaturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		aDigit, bDigit := isDigit(a[i]), isDigit(b[j])
+
+		if aDigit && bDigit {
+			aStart, bStart := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+
+			aNum := strings.TrimLeft(a[aStart:i], "0")
+			bNum := strings.TrimLeft(b[bStart:j], "0")
+
+			if len(aNum) != len(bNum) {
+				return len(aNum) < len(bNum)
+			}
+			if aNum != bNum {
+				return aNum < bNum
+			}
+
+			continue
+		}
+
+		aChar, bChar := unicode.ToLower(rune(a[i])), unicode.ToLower(rune(b[j]))
+		if aChar != bChar {
+			return aChar < bChar
+		}
+
+		i++
+		j++
+	}
+
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+
+	return a < b
+}
+
+// isDigit reports whether the byte is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// byteSizeUnits maps a size suffix to its multiplier. Units are 1024 based
+var byteSizeUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KB":  1 << 10,
+	"KIB": 1 << 10,
+	"M":   1 << 20,
+	"MB":  1 << 20,
+	"MIB": 1 << 20,
+	"G":   1 << 30,
+	"GB":  1 << 30,
+	"GIB": 1 << 30,
+	"T":   1 << 40,
+	"TB":  1 << 40,
+	"TIB": 1 << 40,
+}
+
+// ParseByteSize parses a human readable size, such as 512MB or 1.5GB, into bytes. An empty
+// string is 0
+func ParseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if i == -1 {
+		i = len(s)
+	}
+
+	multiplier, ok := byteSizeUnits[strings.ToUpper(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", s)
+	}
+
+	value, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return int64(value * multiplier), nil
+}
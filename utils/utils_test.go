@@ -84,3 +84,63 @@ func Test_GetEnvOr(t *testing.T) {
 	t.Setenv("UTILS_TEST_ENV", "value")
 	require.Equal(t, "value", GetEnvOr("UTILS_TEST_ENV", "default"))
 }
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_NaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"file 2", "file 10", true},
+		{"file 10", "file 2", false},
+		{"file 02", "file 2", true},
+		{"file 2", "file 02", false},
+		{"Intro", "outro", true},
+		{"intro", "Intro", false},
+		{"a", "ab", true},
+		{"ab", "a", false},
+		{"S01E09", "S01E10", true},
+		{"S02E01", "S01E10", false},
+		{"same", "same", false},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, NaturalLess(tt.a, tt.b), "%q < %q", tt.a, tt.b)
+	}
+}
+
+// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+func Test_ParseByteSize(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		tests := []struct {
+			in       string
+			expected int64
+		}{
+			{"", 0},
+			{"0", 0},
+			{"512", 512},
+			{"512B", 512},
+			{"1KB", 1024},
+			{"1 kib", 1024},
+			{"100MB", 100 << 20},
+			{"1.5GB", 3 << 29},
+			{"20G", 20 << 30},
+			{"2TB", 2 << 40},
+		}
+
+		for _, tt := range tests {
+			res, err := ParseByteSize(tt.in)
+			require.NoError(t, err, tt.in)
+			require.Equal(t, tt.expected, res, tt.in)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, in := range []string{"GB", "10XB", "1.2.3MB", "-5MB"} {
+			_, err := ParseByteSize(in)
+			require.Error(t, err, in)
+		}
+	})
+}